@@ -6,7 +6,7 @@ CREATE TABLE IF NOT EXISTS metrics (
     time TIMESTAMPTZ NOT NULL,
     run_id UUID NOT NULL,
     metric_name VARCHAR(255) NOT NULL,
-    step INTEGER,
+    step BIGINT,
     value DOUBLE PRECISION NOT NULL,
     metadata JSONB
 );
@@ -23,20 +23,35 @@ CREATE INDEX IF NOT EXISTS idx_metrics_step ON metrics (run_id, step);
 CREATE TABLE IF NOT EXISTS system_metrics (
     time TIMESTAMPTZ NOT NULL,
     run_id UUID NOT NULL,
-    cpu_percent DOUBLE PRECISION,
-    memory_percent DOUBLE PRECISION,
-    memory_used_mb DOUBLE PRECISION,
-    gpu_utilization JSONB,
-    disk_io JSONB,
-    network_io JSONB
+    metric_type VARCHAR(32) NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    metadata JSONB
 );
 
+-- Databases created with the earlier wide layout lack the columns the service writes
+ALTER TABLE system_metrics ADD COLUMN IF NOT EXISTS metric_type VARCHAR(32);
+ALTER TABLE system_metrics ADD COLUMN IF NOT EXISTS value DOUBLE PRECISION;
+ALTER TABLE system_metrics ADD COLUMN IF NOT EXISTS metadata JSONB;
+
 -- Convert to hypertable
 SELECT create_hypertable('system_metrics', 'time', if_not_exists => TRUE);
 
 -- Create indexes
 CREATE INDEX IF NOT EXISTS idx_system_metrics_run_id_time ON system_metrics (run_id, time DESC);
 
+-- The hourly aggregate counts numeric values only, like its other statistics,
+-- since string and bool metrics have a NULL value. Aggregates created by
+-- earlier versions counted every row and are recreated below.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM timescaledb_information.continuous_aggregates
+        WHERE view_name = 'metrics_hourly' AND view_definition ILIKE '%count(*)%'
+    ) THEN
+        DROP MATERIALIZED VIEW metrics_hourly;
+    END IF;
+END $$;
+
 -- Create continuous aggregates for hourly metrics
 CREATE MATERIALIZED VIEW IF NOT EXISTS metrics_hourly
 WITH (timescaledb.continuous) AS
@@ -48,7 +63,7 @@ SELECT
     MIN(value) as min_value,
     MAX(value) as max_value,
     STDDEV(value) as stddev_value,
-    COUNT(*) as count
+    COUNT(value) as count
 FROM metrics
 GROUP BY bucket, run_id, metric_name
 WITH NO DATA;
@@ -61,6 +76,10 @@ SELECT add_continuous_aggregate_policy('metrics_hourly',
     if_not_exists => TRUE
 );
 
+-- Materialize the history of a recreated aggregate; buckets already
+-- materialized and not since invalidated are left as they are
+CALL refresh_continuous_aggregate('metrics_hourly', NULL, NOW() - INTERVAL '1 hour');
+
 -- Data retention policy (keep metrics for 90 days)
 SELECT add_retention_policy('metrics', INTERVAL '90 days', if_not_exists => TRUE);
 SELECT add_retention_policy('system_metrics', INTERVAL '30 days', if_not_exists => TRUE);
@@ -110,3 +129,432 @@ BEGIN
       AND m.metric_name = p_metric_name;
 END;
 $$ LANGUAGE plpgsql;
+
+-- Anomaly events flagged by the metric service's rolling-statistics detector
+CREATE TABLE IF NOT EXISTS metric_anomalies (
+    time TIMESTAMPTZ NOT NULL,
+    run_id UUID NOT NULL,
+    metric_name VARCHAR(255) NOT NULL,
+    step BIGINT,
+    value DOUBLE PRECISION,
+    kind VARCHAR(32) NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    expected_mean DOUBLE PRECISION NOT NULL,
+    expected_stddev DOUBLE PRECISION NOT NULL,
+    message TEXT NOT NULL
+);
+
+SELECT create_hypertable('metric_anomalies', 'time', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_metric_anomalies_run_time ON metric_anomalies (run_id, time DESC);
+
+-- Hyperparameter sweeps coordinated by the metric service
+CREATE TABLE IF NOT EXISTS sweeps (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    objective_metric VARCHAR(255) NOT NULL,
+    goal VARCHAR(8) NOT NULL,
+    strategy VARCHAR(16) NOT NULL,
+    search_space JSONB NOT NULL,
+    max_trials INTEGER,
+    status VARCHAR(16) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS sweep_trials (
+    id UUID PRIMARY KEY,
+    sweep_id UUID NOT NULL REFERENCES sweeps (id) ON DELETE CASCADE,
+    trial_number INTEGER NOT NULL,
+    run_id UUID,
+    config JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    objective_value DOUBLE PRECISION,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (sweep_id, trial_number)
+);
+
+-- Run tags (labels are stored with an empty value)
+CREATE TABLE IF NOT EXISTS run_tags (
+    run_id UUID NOT NULL,
+    tag_key VARCHAR(128) NOT NULL,
+    tag_value VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (run_id, tag_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_run_tags_key_value ON run_tags (tag_key, tag_value);
+
+-- Saved comparison reports with their data snapshot
+CREATE TABLE IF NOT EXISTS reports (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    run_ids UUID[] NOT NULL,
+    metric_names TEXT[] NOT NULL,
+    config JSONB NOT NULL DEFAULT '{}',
+    snapshot JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Final and best value per run and metric, maintained on write
+CREATE TABLE IF NOT EXISTS run_summary (
+    run_id UUID NOT NULL,
+    metric_name VARCHAR(255) NOT NULL,
+    goal VARCHAR(8) NOT NULL,
+    final_value DOUBLE PRECISION NOT NULL,
+    final_step BIGINT,
+    final_time TIMESTAMPTZ NOT NULL,
+    best_value DOUBLE PRECISION NOT NULL,
+    best_step BIGINT,
+    best_time TIMESTAMPTZ NOT NULL,
+    count BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (run_id, metric_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_run_summary_metric_best ON run_summary (metric_name, best_value);
+
+-- Metric definitions registry (nil project_id holds installation-wide defaults)
+CREATE TABLE IF NOT EXISTS metric_definitions (
+    project_id UUID NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    display_name VARCHAR(255) NOT NULL,
+    unit VARCHAR(64) NOT NULL DEFAULT '',
+    goal VARCHAR(8) NOT NULL,
+    expected_min DOUBLE PRECISION,
+    expected_max DOUBLE PRECISION,
+    enforce_range BOOLEAN NOT NULL DEFAULT FALSE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (project_id, name)
+);
+
+-- Artifact references (checkpoints, dataset versions) linked to run steps
+CREATE TABLE IF NOT EXISTS run_artifacts (
+    id UUID PRIMARY KEY,
+    run_id UUID NOT NULL,
+    step BIGINT,
+    kind VARCHAR(32) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    uri TEXT NOT NULL,
+    digest VARCHAR(255) NOT NULL DEFAULT '',
+    metadata JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_run_artifacts_run_step ON run_artifacts (run_id, step);
+
+-- Media samples (images, audio, plots); the bytes live in object storage
+CREATE TABLE IF NOT EXISTS run_media (
+    id UUID PRIMARY KEY,
+    run_id UUID NOT NULL,
+    step BIGINT,
+    key VARCHAR(255) NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    digest VARCHAR(255) NOT NULL,
+    object_key TEXT NOT NULL,
+    caption TEXT NOT NULL DEFAULT '',
+    metadata JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_run_media_run_step ON run_media (run_id, step);
+
+-- Histogram metrics (bin edges + counts per step)
+CREATE TABLE IF NOT EXISTS metric_histograms (
+    time TIMESTAMPTZ NOT NULL,
+    run_id UUID NOT NULL,
+    metric_name VARCHAR(255) NOT NULL,
+    step BIGINT,
+    bin_edges DOUBLE PRECISION[] NOT NULL,
+    counts DOUBLE PRECISION[] NOT NULL,
+    metadata JSONB
+);
+
+SELECT create_hypertable('metric_histograms', 'time', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_metric_histograms_run_name_step ON metric_histograms (run_id, metric_name, step);
+
+-- Console output captured from runs
+CREATE TABLE IF NOT EXISTS run_logs (
+    time TIMESTAMPTZ NOT NULL,
+    run_id UUID NOT NULL,
+    level VARCHAR(16) NOT NULL,
+    line TEXT NOT NULL
+);
+
+SELECT create_hypertable('run_logs', 'time', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_run_logs_run_time ON run_logs (run_id, time DESC);
+
+-- Timeline annotations (event markers) on runs
+CREATE TABLE IF NOT EXISTS run_annotations (
+    id UUID PRIMARY KEY,
+    run_id UUID NOT NULL,
+    time TIMESTAMPTZ NOT NULL,
+    step BIGINT,
+    text VARCHAR(1024) NOT NULL,
+    metadata JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_run_annotations_run_time ON run_annotations (run_id, time);
+
+-- Structured per-device GPU samples
+CREATE TABLE IF NOT EXISTS gpu_metrics (
+    time TIMESTAMPTZ NOT NULL,
+    run_id UUID NOT NULL,
+    device_index INTEGER NOT NULL,
+    device_uuid VARCHAR(64) NOT NULL DEFAULT '',
+    device_name VARCHAR(255) NOT NULL DEFAULT '',
+    utilization DOUBLE PRECISION,
+    memory_utilization DOUBLE PRECISION,
+    memory_used_mb DOUBLE PRECISION,
+    memory_total_mb DOUBLE PRECISION,
+    temperature_c DOUBLE PRECISION,
+    power_w DOUBLE PRECISION,
+    metadata JSONB
+);
+
+SELECT create_hypertable('gpu_metrics', 'time', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_gpu_metrics_run_device_time ON gpu_metrics (run_id, device_index, time DESC);
+
+-- Node and rank of samples from distributed training runs
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS node_id VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS rank INTEGER;
+ALTER TABLE system_metrics ADD COLUMN IF NOT EXISTS node_id VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE system_metrics ADD COLUMN IF NOT EXISTS rank INTEGER;
+ALTER TABLE gpu_metrics ADD COLUMN IF NOT EXISTS node_id VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_metrics_run_name_step_rank ON metrics (run_id, metric_name, step, rank);
+CREATE INDEX IF NOT EXISTS idx_system_metrics_run_type_time ON system_metrics (run_id, metric_type, time DESC);
+
+-- Per-rank values of metrics reduced on ingest; the canonical series in
+-- metrics is recomputed from these as each rank reports a step
+ALTER TABLE metric_definitions ADD COLUMN IF NOT EXISTS rank_reduce VARCHAR(8) NOT NULL DEFAULT '';
+ALTER TABLE metric_definitions ADD COLUMN IF NOT EXISTS keep_rank_values BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS metric_rank_values (
+    run_id UUID NOT NULL,
+    metric_name VARCHAR(255) NOT NULL,
+    step BIGINT NOT NULL,
+    rank INTEGER NOT NULL,
+    node_id VARCHAR(255) NOT NULL DEFAULT '',
+    time TIMESTAMPTZ NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (run_id, metric_name, step, rank)
+);
+
+-- Run groups (e.g. the seeds of one experiment) and job types
+CREATE TABLE IF NOT EXISTS run_groups (
+    run_id UUID PRIMARY KEY,
+    group_name VARCHAR(255) NOT NULL,
+    job_type VARCHAR(64) NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_run_groups_group ON run_groups (group_name, job_type);
+
+-- Project and experiment of each run, registered from metric batches
+CREATE TABLE IF NOT EXISTS run_projects (
+    run_id UUID PRIMARY KEY,
+    project_id UUID NOT NULL,
+    experiment_id UUID,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_run_projects_project_seen ON run_projects (project_id, last_seen_at DESC);
+
+-- Per-project leaderboards: the objective each project ranks its runs by, and
+-- each run's standing, maintained on ingest
+CREATE TABLE IF NOT EXISTS project_leaderboards (
+    project_id UUID PRIMARY KEY,
+    metric_name VARCHAR(255) NOT NULL,
+    goal VARCHAR(8) NOT NULL,
+    mode VARCHAR(8) NOT NULL DEFAULT 'best',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS leaderboard_entries (
+    project_id UUID NOT NULL,
+    run_id UUID NOT NULL,
+    goal VARCHAR(8) NOT NULL,
+    mode VARCHAR(8) NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    step BIGINT,
+    time TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (project_id, run_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_leaderboard_entries_value ON leaderboard_entries (project_id, value);
+
+-- Cumulative counters (tokens, samples) get a per-second rate series derived on ingest
+ALTER TABLE metric_definitions ADD COLUMN IF NOT EXISTS cumulative BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Hyperparameters and configuration reported by each run
+CREATE TABLE IF NOT EXISTS run_configs (
+    run_id UUID PRIMARY KEY,
+    config JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Embedding vectors logged per item and step
+CREATE TABLE IF NOT EXISTS run_embeddings (
+    time TIMESTAMPTZ NOT NULL,
+    run_id UUID NOT NULL,
+    metric_name VARCHAR(255) NOT NULL,
+    step BIGINT,
+    key VARCHAR(255) NOT NULL,
+    dim INTEGER NOT NULL,
+    vector REAL[] NOT NULL,
+    metadata JSONB
+);
+
+SELECT create_hypertable('run_embeddings', 'time', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_run_embeddings_run_name_step ON run_embeddings (run_id, metric_name, step, key);
+
+-- Tables and confusion matrices logged per step
+CREATE TABLE IF NOT EXISTS metric_tables (
+    time TIMESTAMPTZ NOT NULL,
+    run_id UUID NOT NULL,
+    metric_name VARCHAR(255) NOT NULL,
+    step BIGINT,
+    kind VARCHAR(32) NOT NULL,
+    data JSONB NOT NULL,
+    metadata JSONB
+);
+
+SELECT create_hypertable('metric_tables', 'time', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_metric_tables_run_name_step ON metric_tables (run_id, metric_name, step);
+
+-- Run state changes (created, running, paused, finished, crashed, killed)
+CREATE TABLE IF NOT EXISTS run_events (
+    id UUID PRIMARY KEY,
+    run_id UUID NOT NULL,
+    time TIMESTAMPTZ NOT NULL,
+    state VARCHAR(16) NOT NULL,
+    previous_state VARCHAR(16) NOT NULL DEFAULT '',
+    step BIGINT,
+    reason VARCHAR(1024) NOT NULL DEFAULT '',
+    source VARCHAR(64) NOT NULL DEFAULT '',
+    metadata JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_run_events_run_time ON run_events (run_id, time);
+
+-- Objective metrics a run's checkpoints are ranked by
+CREATE TABLE IF NOT EXISTS run_checkpoint_objectives (
+    run_id UUID NOT NULL,
+    metric_name VARCHAR(255) NOT NULL,
+    goal VARCHAR(8) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (run_id, metric_name)
+);
+
+-- Best checkpoint so far of each run objective
+CREATE TABLE IF NOT EXISTS run_best_checkpoints (
+    run_id UUID NOT NULL,
+    metric_name VARCHAR(255) NOT NULL,
+    goal VARCHAR(8) NOT NULL,
+    artifact_id UUID NOT NULL,
+    step BIGINT NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    time TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (run_id, metric_name)
+);
+
+-- Metrics logged in each run, maintained on write so that listing a run's
+-- metrics does not scan the hypertable
+CREATE TABLE IF NOT EXISTS run_metric_names (
+    run_id UUID NOT NULL,
+    metric_name VARCHAR(255) NOT NULL,
+    first_seen TIMESTAMPTZ NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL,
+    count BIGINT NOT NULL,
+    PRIMARY KEY (run_id, metric_name)
+);
+
+-- Index the runs written before the table existed, once
+INSERT INTO run_metric_names (run_id, metric_name, first_seen, last_seen, count)
+SELECT run_id, metric_name, MIN(time), MAX(time), COUNT(*)
+FROM metrics
+WHERE NOT EXISTS (SELECT 1 FROM run_metric_names)
+GROUP BY run_id, metric_name
+ON CONFLICT (run_id, metric_name) DO NOTHING;
+
+-- Steps are 64-bit, for runs counting tokens or samples as steps. Columns
+-- created as INTEGER by earlier versions are widened once; widening rewrites
+-- the table.
+DO $$
+DECLARE
+    col RECORD;
+BEGIN
+    FOR col IN
+        SELECT table_name, column_name FROM information_schema.columns
+        WHERE table_schema = current_schema()
+          AND column_name IN ('step', 'final_step', 'best_step')
+          AND data_type = 'integer'
+    LOOP
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE BIGINT', col.table_name, col.column_name);
+    END LOOP;
+END $$;
+
+-- String and bool metric values are kept in text_value, typed by value_type,
+-- with a NULL value so numeric aggregates leave them out. value_type is NULL
+-- for numbers.
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS value_type VARCHAR(8);
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS text_value TEXT;
+ALTER TABLE metrics ALTER COLUMN value DROP NOT NULL;
+
+-- Audit records of metric values deleted from runs by step range
+CREATE TABLE IF NOT EXISTS metric_deletions (
+    id UUID PRIMARY KEY,
+    run_id UUID NOT NULL,
+    metric_name VARCHAR(255) NOT NULL,
+    min_step BIGINT,
+    max_step BIGINT,
+    deleted_rows BIGINT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_metric_deletions_run_time ON metric_deletions (run_id, deleted_at DESC);
+
+-- Runs that logged a metric recently, for the Prometheus exposition
+CREATE INDEX IF NOT EXISTS idx_run_metric_names_name_seen ON run_metric_names (metric_name, last_seen DESC);
+
+-- Metric histories ordered by step, as order_by=step reads them; steps left
+-- unset order as -1, and ties are broken by time
+CREATE INDEX IF NOT EXISTS idx_metrics_run_name_step_time ON metrics (run_id, metric_name, (COALESCE(step, -1)), time);
+
+-- Latest export of each ended run's metrics to object storage, by the run
+-- export job. ended_at is the time of the state change exported, so a run
+-- resumed and ended again is exported anew.
+CREATE TABLE IF NOT EXISTS run_exports (
+    run_id UUID PRIMARY KEY,
+    state VARCHAR(16) NOT NULL,
+    ended_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    format VARCHAR(16) NOT NULL,
+    key TEXT NOT NULL DEFAULT '',
+    rows BIGINT NOT NULL DEFAULT 0,
+    size BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_run_exports_updated ON run_exports (updated_at DESC);
@@ -0,0 +1,188 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.1
+// source: metrics/v1/ingest.proto
+
+// Write API of the metric service, for training agents that log at rates
+// where JSON encoding over REST costs more than the write itself. The batch
+// messages are also accepted as protobuf bodies by the REST batch endpoints.
+
+package metricsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	MetricIngestService_BatchWrite_FullMethodName = "/wanllmdb.metrics.v1.MetricIngestService/BatchWrite"
+	MetricIngestService_Subscribe_FullMethodName  = "/wanllmdb.metrics.v1.MetricIngestService/Subscribe"
+)
+
+// MetricIngestServiceClient is the client API for MetricIngestService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MetricIngestServiceClient interface {
+	// BatchWrite writes a batch of metrics, as POST /metrics/batch does.
+	// Invalid batches fail with INVALID_ARGUMENT and a BadRequest detail
+	// listing every invalid metric.
+	BatchWrite(ctx context.Context, in *BatchWriteRequest, opts ...grpc.CallOption) (*BatchWriteResponse, error)
+	// Subscribe streams the metrics of a run as they are written, until the
+	// client cancels, as the WebSocket stream does.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (MetricIngestService_SubscribeClient, error)
+}
+
+type metricIngestServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMetricIngestServiceClient(cc grpc.ClientConnInterface) MetricIngestServiceClient {
+	return &metricIngestServiceClient{cc}
+}
+
+func (c *metricIngestServiceClient) BatchWrite(ctx context.Context, in *BatchWriteRequest, opts ...grpc.CallOption) (*BatchWriteResponse, error) {
+	out := new(BatchWriteResponse)
+	err := c.cc.Invoke(ctx, MetricIngestService_BatchWrite_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metricIngestServiceClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (MetricIngestService_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MetricIngestService_ServiceDesc.Streams[0], MetricIngestService_Subscribe_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &metricIngestServiceSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MetricIngestService_SubscribeClient interface {
+	Recv() (*MetricBatch, error)
+	grpc.ClientStream
+}
+
+type metricIngestServiceSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *metricIngestServiceSubscribeClient) Recv() (*MetricBatch, error) {
+	m := new(MetricBatch)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MetricIngestServiceServer is the server API for MetricIngestService service.
+// All implementations must embed UnimplementedMetricIngestServiceServer
+// for forward compatibility
+type MetricIngestServiceServer interface {
+	// BatchWrite writes a batch of metrics, as POST /metrics/batch does.
+	// Invalid batches fail with INVALID_ARGUMENT and a BadRequest detail
+	// listing every invalid metric.
+	BatchWrite(context.Context, *BatchWriteRequest) (*BatchWriteResponse, error)
+	// Subscribe streams the metrics of a run as they are written, until the
+	// client cancels, as the WebSocket stream does.
+	Subscribe(*SubscribeRequest, MetricIngestService_SubscribeServer) error
+	mustEmbedUnimplementedMetricIngestServiceServer()
+}
+
+// UnimplementedMetricIngestServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedMetricIngestServiceServer struct {
+}
+
+func (UnimplementedMetricIngestServiceServer) BatchWrite(context.Context, *BatchWriteRequest) (*BatchWriteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchWrite not implemented")
+}
+func (UnimplementedMetricIngestServiceServer) Subscribe(*SubscribeRequest, MetricIngestService_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedMetricIngestServiceServer) mustEmbedUnimplementedMetricIngestServiceServer() {}
+
+// UnsafeMetricIngestServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MetricIngestServiceServer will
+// result in compilation errors.
+type UnsafeMetricIngestServiceServer interface {
+	mustEmbedUnimplementedMetricIngestServiceServer()
+}
+
+func RegisterMetricIngestServiceServer(s grpc.ServiceRegistrar, srv MetricIngestServiceServer) {
+	s.RegisterService(&MetricIngestService_ServiceDesc, srv)
+}
+
+func _MetricIngestService_BatchWrite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchWriteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricIngestServiceServer).BatchWrite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MetricIngestService_BatchWrite_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricIngestServiceServer).BatchWrite(ctx, req.(*BatchWriteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MetricIngestService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MetricIngestServiceServer).Subscribe(m, &metricIngestServiceSubscribeServer{stream})
+}
+
+type MetricIngestService_SubscribeServer interface {
+	Send(*MetricBatch) error
+	grpc.ServerStream
+}
+
+type metricIngestServiceSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *metricIngestServiceSubscribeServer) Send(m *MetricBatch) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// MetricIngestService_ServiceDesc is the grpc.ServiceDesc for MetricIngestService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MetricIngestService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "wanllmdb.metrics.v1.MetricIngestService",
+	HandlerType: (*MetricIngestServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "BatchWrite",
+			Handler:    _MetricIngestService_BatchWrite_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _MetricIngestService_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "metrics/v1/ingest.proto",
+}
@@ -0,0 +1,292 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.1
+// source: metrics/v1/query.proto
+
+// Read API of the metric service, mirroring the REST queries for backend
+// consumers such as evaluation pipelines and schedulers.
+
+package metricsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	MetricQueryService_GetRunMetrics_FullMethodName    = "/wanllmdb.metrics.v1.MetricQueryService/GetRunMetrics"
+	MetricQueryService_GetMetricHistory_FullMethodName = "/wanllmdb.metrics.v1.MetricQueryService/GetMetricHistory"
+	MetricQueryService_GetMetricStats_FullMethodName   = "/wanllmdb.metrics.v1.MetricQueryService/GetMetricStats"
+	MetricQueryService_Compare_FullMethodName          = "/wanllmdb.metrics.v1.MetricQueryService/Compare"
+)
+
+// MetricQueryServiceClient is the client API for MetricQueryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MetricQueryServiceClient interface {
+	// GetRunMetrics streams the metrics of a run in batches, newest first, as
+	// GET /runs/{run_id}/metrics?stream=true does.
+	GetRunMetrics(ctx context.Context, in *GetRunMetricsRequest, opts ...grpc.CallOption) (MetricQueryService_GetRunMetricsClient, error)
+	// GetMetricHistory streams the values of one metric of a run in batches.
+	GetMetricHistory(ctx context.Context, in *GetMetricHistoryRequest, opts ...grpc.CallOption) (MetricQueryService_GetMetricHistoryClient, error)
+	// GetMetricStats returns the statistics of a metric of a run, NOT_FOUND
+	// when the run never logged it.
+	GetMetricStats(ctx context.Context, in *GetMetricStatsRequest, opts ...grpc.CallOption) (*MetricStats, error)
+	// Compare returns the summaries of a metric across runs, as GET /summaries
+	// does.
+	Compare(ctx context.Context, in *CompareRequest, opts ...grpc.CallOption) (*CompareResponse, error)
+}
+
+type metricQueryServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMetricQueryServiceClient(cc grpc.ClientConnInterface) MetricQueryServiceClient {
+	return &metricQueryServiceClient{cc}
+}
+
+func (c *metricQueryServiceClient) GetRunMetrics(ctx context.Context, in *GetRunMetricsRequest, opts ...grpc.CallOption) (MetricQueryService_GetRunMetricsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MetricQueryService_ServiceDesc.Streams[0], MetricQueryService_GetRunMetrics_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &metricQueryServiceGetRunMetricsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MetricQueryService_GetRunMetricsClient interface {
+	Recv() (*MetricBatch, error)
+	grpc.ClientStream
+}
+
+type metricQueryServiceGetRunMetricsClient struct {
+	grpc.ClientStream
+}
+
+func (x *metricQueryServiceGetRunMetricsClient) Recv() (*MetricBatch, error) {
+	m := new(MetricBatch)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *metricQueryServiceClient) GetMetricHistory(ctx context.Context, in *GetMetricHistoryRequest, opts ...grpc.CallOption) (MetricQueryService_GetMetricHistoryClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MetricQueryService_ServiceDesc.Streams[1], MetricQueryService_GetMetricHistory_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &metricQueryServiceGetMetricHistoryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MetricQueryService_GetMetricHistoryClient interface {
+	Recv() (*MetricBatch, error)
+	grpc.ClientStream
+}
+
+type metricQueryServiceGetMetricHistoryClient struct {
+	grpc.ClientStream
+}
+
+func (x *metricQueryServiceGetMetricHistoryClient) Recv() (*MetricBatch, error) {
+	m := new(MetricBatch)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *metricQueryServiceClient) GetMetricStats(ctx context.Context, in *GetMetricStatsRequest, opts ...grpc.CallOption) (*MetricStats, error) {
+	out := new(MetricStats)
+	err := c.cc.Invoke(ctx, MetricQueryService_GetMetricStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metricQueryServiceClient) Compare(ctx context.Context, in *CompareRequest, opts ...grpc.CallOption) (*CompareResponse, error) {
+	out := new(CompareResponse)
+	err := c.cc.Invoke(ctx, MetricQueryService_Compare_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MetricQueryServiceServer is the server API for MetricQueryService service.
+// All implementations must embed UnimplementedMetricQueryServiceServer
+// for forward compatibility
+type MetricQueryServiceServer interface {
+	// GetRunMetrics streams the metrics of a run in batches, newest first, as
+	// GET /runs/{run_id}/metrics?stream=true does.
+	GetRunMetrics(*GetRunMetricsRequest, MetricQueryService_GetRunMetricsServer) error
+	// GetMetricHistory streams the values of one metric of a run in batches.
+	GetMetricHistory(*GetMetricHistoryRequest, MetricQueryService_GetMetricHistoryServer) error
+	// GetMetricStats returns the statistics of a metric of a run, NOT_FOUND
+	// when the run never logged it.
+	GetMetricStats(context.Context, *GetMetricStatsRequest) (*MetricStats, error)
+	// Compare returns the summaries of a metric across runs, as GET /summaries
+	// does.
+	Compare(context.Context, *CompareRequest) (*CompareResponse, error)
+	mustEmbedUnimplementedMetricQueryServiceServer()
+}
+
+// UnimplementedMetricQueryServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedMetricQueryServiceServer struct {
+}
+
+func (UnimplementedMetricQueryServiceServer) GetRunMetrics(*GetRunMetricsRequest, MetricQueryService_GetRunMetricsServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetRunMetrics not implemented")
+}
+func (UnimplementedMetricQueryServiceServer) GetMetricHistory(*GetMetricHistoryRequest, MetricQueryService_GetMetricHistoryServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetMetricHistory not implemented")
+}
+func (UnimplementedMetricQueryServiceServer) GetMetricStats(context.Context, *GetMetricStatsRequest) (*MetricStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMetricStats not implemented")
+}
+func (UnimplementedMetricQueryServiceServer) Compare(context.Context, *CompareRequest) (*CompareResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Compare not implemented")
+}
+func (UnimplementedMetricQueryServiceServer) mustEmbedUnimplementedMetricQueryServiceServer() {}
+
+// UnsafeMetricQueryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MetricQueryServiceServer will
+// result in compilation errors.
+type UnsafeMetricQueryServiceServer interface {
+	mustEmbedUnimplementedMetricQueryServiceServer()
+}
+
+func RegisterMetricQueryServiceServer(s grpc.ServiceRegistrar, srv MetricQueryServiceServer) {
+	s.RegisterService(&MetricQueryService_ServiceDesc, srv)
+}
+
+func _MetricQueryService_GetRunMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetRunMetricsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MetricQueryServiceServer).GetRunMetrics(m, &metricQueryServiceGetRunMetricsServer{stream})
+}
+
+type MetricQueryService_GetRunMetricsServer interface {
+	Send(*MetricBatch) error
+	grpc.ServerStream
+}
+
+type metricQueryServiceGetRunMetricsServer struct {
+	grpc.ServerStream
+}
+
+func (x *metricQueryServiceGetRunMetricsServer) Send(m *MetricBatch) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _MetricQueryService_GetMetricHistory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetMetricHistoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MetricQueryServiceServer).GetMetricHistory(m, &metricQueryServiceGetMetricHistoryServer{stream})
+}
+
+type MetricQueryService_GetMetricHistoryServer interface {
+	Send(*MetricBatch) error
+	grpc.ServerStream
+}
+
+type metricQueryServiceGetMetricHistoryServer struct {
+	grpc.ServerStream
+}
+
+func (x *metricQueryServiceGetMetricHistoryServer) Send(m *MetricBatch) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _MetricQueryService_GetMetricStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMetricStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricQueryServiceServer).GetMetricStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MetricQueryService_GetMetricStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricQueryServiceServer).GetMetricStats(ctx, req.(*GetMetricStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MetricQueryService_Compare_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricQueryServiceServer).Compare(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MetricQueryService_Compare_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricQueryServiceServer).Compare(ctx, req.(*CompareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// MetricQueryService_ServiceDesc is the grpc.ServiceDesc for MetricQueryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MetricQueryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "wanllmdb.metrics.v1.MetricQueryService",
+	HandlerType: (*MetricQueryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetMetricStats",
+			Handler:    _MetricQueryService_GetMetricStats_Handler,
+		},
+		{
+			MethodName: "Compare",
+			Handler:    _MetricQueryService_Compare_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetRunMetrics",
+			Handler:       _MetricQueryService_GetRunMetrics_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetMetricHistory",
+			Handler:       _MetricQueryService_GetMetricHistory_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "metrics/v1/query.proto",
+}
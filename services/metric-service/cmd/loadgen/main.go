@@ -0,0 +1,185 @@
+// Command loadgen drives a synthetic workload against a metric service
+// instance — runs writing metrics at a fixed rate, readers querying them and
+// WebSocket subscribers following them — and reports the throughput and
+// latency percentiles of each, so that changes to the ingest and query paths
+// can be measured.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type options struct {
+	serverURL   string
+	runs        int
+	metrics     int
+	rate        float64
+	readers     int
+	readLimit   int
+	subscribers int
+	duration    time.Duration
+	format      string
+}
+
+func main() {
+	opts, err := parseOptions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	l := newLoad(opts)
+	fmt.Fprintf(os.Stderr, "loadgen: %d runs x %d metrics at %g steps/s (%g points/s), %d readers, %d subscribers for %s against %s\n",
+		opts.runs, opts.metrics, opts.rate, float64(opts.runs*opts.metrics)*opts.rate,
+		opts.readers, opts.subscribers, opts.duration, opts.serverURL)
+
+	elapsed := l.Run(ctx)
+
+	report := l.stats.Report(elapsed)
+	if opts.format == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteTable(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func parseOptions() (options, error) {
+	var opts options
+	flag.StringVar(&opts.serverURL, "server", envOr("METRIC_SERVICE_URL", "http://localhost:8001"), "metric service base URL")
+	flag.IntVar(&opts.runs, "runs", 100, "concurrently writing runs")
+	flag.IntVar(&opts.metrics, "metrics", 20, "metrics each run writes per step")
+	flag.Float64Var(&opts.rate, "rate", 1, "steps each run writes per second, one batch per step")
+	flag.IntVar(&opts.readers, "readers", 4, "concurrent readers querying random runs and metrics")
+	flag.IntVar(&opts.readLimit, "read-limit", 1000, "values each history query asks for")
+	flag.IntVar(&opts.subscribers, "subscribers", 0, "WebSocket subscribers, spread over the runs")
+	flag.DurationVar(&opts.duration, "duration", time.Minute, "how long to apply the load")
+	flag.StringVar(&opts.format, "format", "table", "report format: table or json")
+	flag.Parse()
+
+	opts.serverURL = strings.TrimRight(opts.serverURL, "/")
+	if opts.runs < 1 || opts.metrics < 1 {
+		return opts, fmt.Errorf("runs and metrics must be at least 1")
+	}
+	if opts.metrics > 1000 {
+		return opts, fmt.Errorf("metrics must be at most 1000, the largest batch the service accepts")
+	}
+	if opts.rate <= 0 {
+		return opts, fmt.Errorf("rate must be positive")
+	}
+	if opts.readers < 0 || opts.subscribers < 0 {
+		return opts, fmt.Errorf("readers and subscribers must not be negative")
+	}
+	if opts.readLimit < 1 || opts.readLimit > 10000 {
+		return opts, fmt.Errorf("read limit must be between 1 and 10000")
+	}
+	if opts.duration <= 0 {
+		return opts, fmt.Errorf("duration must be positive")
+	}
+	if opts.format != "table" && opts.format != "json" {
+		return opts, fmt.Errorf("unknown format %q", opts.format)
+	}
+	return opts, nil
+}
+
+// load is one workload: its runs, the names of the metrics each writes, and
+// the statistics gathered while applying it
+type load struct {
+	opts        options
+	runIDs      []uuid.UUID
+	metricNames []string
+	client      *http.Client
+	stats       *stats
+}
+
+func newLoad(opts options) *load {
+	l := &load{
+		opts:  opts,
+		stats: newStats(),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			// Every writer and reader keeps its connection instead of
+			// measuring connection setup
+			Transport: &http.Transport{
+				MaxIdleConns:        opts.runs + opts.readers,
+				MaxIdleConnsPerHost: opts.runs + opts.readers,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+	for i := 0; i < opts.runs; i++ {
+		l.runIDs = append(l.runIDs, uuid.New())
+	}
+	for i := 0; i < opts.metrics; i++ {
+		l.metricNames = append(l.metricNames, "loadgen/metric_"+strconv.Itoa(i))
+	}
+	return l
+}
+
+// Run applies the load until the duration passes or ctx is done, returning
+// how long it ran
+func (l *load) Run(ctx context.Context) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, l.opts.duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	// Subscribers connect first so that they see the first writes
+	for i := 0; i < l.opts.subscribers; i++ {
+		runID := l.runIDs[i%len(l.runIDs)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.subscribe(ctx, runID)
+		}()
+	}
+	for i, runID := range l.runIDs {
+		runID := runID
+		// Runs start spread over one step instead of writing in lockstep
+		offset := time.Duration(float64(i) / float64(len(l.runIDs)) / l.opts.rate * float64(time.Second))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.write(ctx, runID, offset)
+		}()
+	}
+	for i := 0; i < l.opts.readers; i++ {
+		seed := int64(i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.read(ctx, seed)
+		}()
+	}
+
+	<-ctx.Done()
+	elapsed := time.Since(start)
+	wg.Wait()
+	return elapsed
+}
+
+func envOr(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// maxErrorSamples is how many distinct error messages an operation keeps
+const maxErrorSamples = 5
+
+// stats collects the outcome of every operation of a load
+type stats struct {
+	mu  sync.Mutex
+	ops map[string]*opStats
+}
+
+type opStats struct {
+	latencies []time.Duration
+	values    int
+	errors    int
+	samples   map[string]int
+}
+
+func newStats() *stats {
+	return &stats{ops: make(map[string]*opStats)}
+}
+
+// Record adds one operation that took latency and carried values values.
+// Failed operations count towards errors only.
+func (s *stats) Record(op string, latency time.Duration, values int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.ops[op]
+	if !ok {
+		o = &opStats{samples: make(map[string]int)}
+		s.ops[op] = o
+	}
+	if err != nil {
+		o.errors++
+		msg := err.Error()
+		if _, ok := o.samples[msg]; ok || len(o.samples) < maxErrorSamples {
+			o.samples[msg]++
+		}
+		return
+	}
+	o.latencies = append(o.latencies, latency)
+	o.values += values
+}
+
+// Report summarizes the operations of a load that ran for elapsed
+func (s *stats) Report(elapsed time.Duration) *report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := &report{Elapsed: elapsed.Round(time.Millisecond).String()}
+	for name, o := range s.ops {
+		latencies := append([]time.Duration(nil), o.latencies...)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		op := opReport{
+			Operation:    name,
+			Count:        len(latencies),
+			Errors:       o.errors,
+			PerSecond:    float64(len(latencies)) / elapsed.Seconds(),
+			Values:       o.values,
+			ValuesPerSec: float64(o.values) / elapsed.Seconds(),
+			ErrorSamples: o.samples,
+		}
+		if len(latencies) > 0 {
+			op.P50 = millis(percentile(latencies, 50))
+			op.P90 = millis(percentile(latencies, 90))
+			op.P99 = millis(percentile(latencies, 99))
+			op.Max = millis(latencies[len(latencies)-1])
+		}
+		r.Operations = append(r.Operations, op)
+	}
+	sort.Slice(r.Operations, func(i, j int) bool { return r.Operations[i].Operation < r.Operations[j].Operation })
+	return r
+}
+
+// percentile returns the nearest-rank percentile p of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type report struct {
+	Elapsed    string     `json:"elapsed"`
+	Operations []opReport `json:"operations"`
+}
+
+// opReport gives the latencies of an operation in milliseconds
+type opReport struct {
+	Operation    string         `json:"operation"`
+	Count        int            `json:"count"`
+	Errors       int            `json:"errors"`
+	PerSecond    float64        `json:"per_second"`
+	Values       int            `json:"values"`
+	ValuesPerSec float64        `json:"values_per_second"`
+	P50          float64        `json:"p50_ms"`
+	P90          float64        `json:"p90_ms"`
+	P99          float64        `json:"p99_ms"`
+	Max          float64        `json:"max_ms"`
+	ErrorSamples map[string]int `json:"error_samples,omitempty"`
+}
+
+func (r *report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r *report) WriteTable(w io.Writer) error {
+	fmt.Fprintf(w, "elapsed %s\n\n", r.Elapsed)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\tcount\terrors\tper sec\tvalues/sec\tp50 ms\tp90 ms\tp99 ms\tmax ms\t")
+	for _, op := range r.Operations {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			op.Operation, op.Count, op.Errors, op.PerSecond, op.ValuesPerSec, op.P50, op.P90, op.P99, op.Max)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	separated := false
+	for _, op := range r.Operations {
+		if len(op.ErrorSamples) > 0 && !separated {
+			fmt.Fprintln(w)
+			separated = true
+		}
+		for msg, n := range op.ErrorSamples {
+			fmt.Fprintf(w, "%s: %d x %s\n", op.Operation, n, msg)
+		}
+	}
+	return nil
+}
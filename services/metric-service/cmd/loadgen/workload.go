@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// Operations reported on
+const (
+	opWrite     = "write"
+	opHistory   = "read history"
+	opLatest    = "read latest"
+	opReadStats = "read stats"
+	opDeliver   = "ws delivery"
+)
+
+// write posts one batch per step for a run, stamping each value with the
+// time the batch is built so subscribers can measure delivery latency. A
+// batch slower than the step interval delays the next one rather than
+// piling up requests, so the achieved rate shows in the report.
+func (l *load) write(ctx context.Context, runID uuid.UUID, offset time.Duration) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(offset):
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / l.opts.rate))
+	defer ticker.Stop()
+
+	for step := 0; ; step++ {
+		now := time.Now()
+		s := int64(step)
+		batch := model.MetricBatchRequest{Metrics: make([]model.Metric, len(l.metricNames))}
+		for i, name := range l.metricNames {
+			batch.Metrics[i] = model.Metric{
+				Time:       now,
+				RunID:      runID,
+				MetricName: name,
+				Step:       &s,
+				Value:      math.Exp(-float64(step)/1000) + float64(i),
+			}
+		}
+
+		err := l.post(ctx, "/api/v1/metrics/batch", batch)
+		if ctx.Err() != nil {
+			// Requests cut short by the end of the run are not failures
+			return
+		}
+		l.stats.Record(opWrite, time.Since(now), len(batch.Metrics), err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// read queries random runs and metrics back to back, cycling through the
+// history, latest value and statistics endpoints. A metric not written yet
+// answers 404, which counts as a completed request.
+func (l *load) read(ctx context.Context, seed int64) {
+	rng := rand.New(rand.NewSource(seed))
+	ops := []string{opHistory, opLatest, opReadStats}
+
+	for i := 0; ; i++ {
+		runID := l.runIDs[rng.Intn(len(l.runIDs))]
+		path := "/api/v1/runs/" + runID.String() + "/metrics/" + url.PathEscape(l.metricNames[rng.Intn(len(l.metricNames))])
+		op := ops[i%len(ops)]
+		switch op {
+		case opHistory:
+			path += "?limit=" + strconv.Itoa(l.opts.readLimit)
+		case opLatest:
+			path += "/latest"
+		case opReadStats:
+			path += "/stats"
+		}
+
+		start := time.Now()
+		n, err := l.get(ctx, path)
+		if ctx.Err() != nil {
+			return
+		}
+		l.stats.Record(op, time.Since(start), n, err)
+	}
+}
+
+// subscribe follows a run over WebSocket, recording for every value received
+// how long after it was stamped by its writer it arrived
+func (l *load) subscribe(ctx context.Context, runID uuid.UUID) {
+	wsURL := "ws" + strings.TrimPrefix(l.opts.serverURL, "http") + "/ws/metrics/" + runID.String()
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		l.stats.Record(opDeliver, 0, 0, fmt.Errorf("failed to connect: %w", err))
+		return
+	}
+	defer conn.Close()
+
+	// Closing the connection unblocks the read loop when the load ends
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	for {
+		var msg struct {
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			if ctx.Err() == nil {
+				l.stats.Record(opDeliver, 0, 0, fmt.Errorf("connection closed: %w", err))
+			}
+			return
+		}
+		if msg.Type != "metric" {
+			continue
+		}
+
+		received := time.Now()
+		var payload model.MetricPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			l.stats.Record(opDeliver, 0, 0, err)
+			continue
+		}
+		for _, m := range payload.Metrics {
+			l.stats.Record(opDeliver, received.Sub(m.Time), 1, nil)
+		}
+	}
+}
+
+// post sends body as JSON, failing on any status but 2xx
+func (l *load) post(ctx context.Context, path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.opts.serverURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+	return checkStatus(resp, false)
+}
+
+// get fetches path and returns the number of values in its body: the length
+// of its metrics list, one for any other body, or none when not found. The
+// whole body is read, so that the connection is reused.
+func (l *load) get(ctx context.Context, path string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.opts.serverURL+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+	if err := checkStatus(resp, true); err != nil || resp.StatusCode == http.StatusNotFound {
+		return 0, err
+	}
+
+	var body struct {
+		Metrics []json.RawMessage `json:"metrics"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, err
+	}
+	if body.Metrics == nil {
+		return 1, nil
+	}
+	return len(body.Metrics), nil
+}
+
+// checkStatus fails on statuses other than 2xx, and 404 unless allowed,
+// with the start of the response body
+func checkStatus(resp *http.Response, allowNotFound bool) error {
+	if resp.StatusCode < 300 || (allowNotFound && resp.StatusCode == http.StatusNotFound) {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+}
@@ -2,21 +2,37 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
 
+	metricsv1 "github.com/wanllmdb/metric-service/api/metrics/v1"
 	"github.com/wanllmdb/metric-service/internal/config"
 	"github.com/wanllmdb/metric-service/internal/db"
+	"github.com/wanllmdb/metric-service/internal/export"
+	"github.com/wanllmdb/metric-service/internal/grpcapi"
 	"github.com/wanllmdb/metric-service/internal/handler"
+	"github.com/wanllmdb/metric-service/internal/kafka"
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/mqttbridge"
+	"github.com/wanllmdb/metric-service/internal/openapi"
+	"github.com/wanllmdb/metric-service/internal/pubsub"
 	"github.com/wanllmdb/metric-service/internal/repository"
+	"github.com/wanllmdb/metric-service/internal/runservice"
 	"github.com/wanllmdb/metric-service/internal/service"
+	"github.com/wanllmdb/metric-service/internal/sns"
+	"github.com/wanllmdb/metric-service/internal/storage"
 )
 
 func main() {
@@ -35,7 +51,15 @@ func main() {
 	}
 
 	// Initialize database connection
-	dbPool, err := db.NewPool(context.Background(), cfg.TimescaleURL)
+	model.SetDefaultTimezone(cfg.DefaultTimezone)
+	poolOptions, err := cfg.PoolOptions()
+	if err != nil {
+		logger.Fatal("Invalid database pool configuration", zap.Error(err))
+	}
+	if cfg.DBPrepareStatements {
+		poolOptions.Prepare = repository.PreparedStatements()
+	}
+	dbPool, err := db.NewPool(context.Background(), cfg.TimescaleURL, poolOptions)
 	if err != nil {
 		logger.Fatal("Failed to connect to database", zap.Error(err))
 	}
@@ -45,15 +69,304 @@ func main() {
 	redisClient := db.NewRedisClient(cfg.RedisURL)
 	defer redisClient.Close()
 
-	// Initialize repository
-	metricRepo := repository.NewMetricRepository(dbPool, logger)
+	// Background workers share this context and stop on shutdown
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Initialize repository. Repositories reading unbounded data are capped
+	rowLimits := repository.RowLimits{
+		MaxResultRows: cfg.DBMaxResultRows,
+		MaxScanRows:   cfg.DBMaxScanRows,
+		MaxStreamRows: cfg.DBMaxStreamRows,
+	}
+	if err := rowLimits.Validate(); err != nil {
+		logger.Fatal("Invalid DB_MAX_RESULT_ROWS, DB_MAX_SCAN_ROWS or DB_MAX_STREAM_ROWS", zap.Error(err))
+	}
+	metricRepo := repository.NewMetricRepository(dbPool, rowLimits, logger)
+	metricRepo.UseCopy(cfg.DBCopyThreshold)
+	anomalyRepo := repository.NewAnomalyRepository(dbPool, logger)
+	sweepRepo := repository.NewSweepRepository(dbPool, rowLimits, logger)
+	tagRepo := repository.NewTagRepository(dbPool, logger)
+	reportRepo := repository.NewReportRepository(dbPool, logger)
+	summaryRepo := repository.NewSummaryRepository(dbPool, logger)
+	definitionRepo := repository.NewDefinitionRepository(dbPool, logger)
+	artifactRepo := repository.NewArtifactRepository(dbPool, logger)
+	mediaRepo := repository.NewMediaRepository(dbPool, logger)
+	histogramRepo := repository.NewHistogramRepository(dbPool, rowLimits, logger)
+	embeddingRepo := repository.NewEmbeddingRepository(dbPool, rowLimits, logger)
+	tableRepo := repository.NewTableRepository(dbPool, rowLimits, logger)
+	logRepo := repository.NewLogRepository(dbPool, logger)
+	annotationRepo := repository.NewAnnotationRepository(dbPool, rowLimits, logger)
+	runEventRepo := repository.NewRunEventRepository(dbPool, logger)
+	gpuRepo := repository.NewGPURepository(dbPool, rowLimits, logger)
+	nodeRepo := repository.NewNodeRepository(dbPool, rowLimits, logger)
+	groupRepo := repository.NewGroupRepository(dbPool, rowLimits, logger)
+	projectRepo := repository.NewProjectRepository(dbPool, rowLimits, logger)
+	leaderboardRepo := repository.NewLeaderboardRepository(dbPool, logger)
+	runConfigRepo := repository.NewRunConfigRepository(dbPool, logger)
+	maintenanceRepo := repository.NewMaintenanceRepository(dbPool, logger)
+	checkpointRepo := repository.NewCheckpointRepository(dbPool, logger)
+	crashRepo := repository.NewCrashRepository(dbPool, logger)
+	exportRepo := repository.NewExportRepository(dbPool, logger)
+
+	// Initialize object storage
+	objectStore, err := newObjectStore(cfg)
+	if err != nil {
+		logger.Fatal("Failed to initialize object storage", zap.Error(err))
+	}
 
 	// Initialize service
-	metricService := service.NewMetricService(metricRepo, redisClient, logger)
+	definitionService := service.NewDefinitionService(definitionRepo, logger)
+	if err := definitionService.Refresh(context.Background()); err != nil {
+		logger.Error("Failed to load metric definitions", zap.Error(err))
+	}
+	go definitionService.Run(bgCtx)
+
+	metricService := service.NewMetricService(metricRepo, definitionService, redisClient, logger)
+	nonFinitePolicy, err := model.ParseNonFinitePolicy(cfg.NonFinitePolicy)
+	if err != nil {
+		logger.Fatal("Invalid NON_FINITE_POLICY", zap.Error(err))
+	}
+	metricService.UseNonFinitePolicy(nonFinitePolicy)
+	metricService.UseCachePolicy(service.CachePolicy{
+		ActiveTTL:     cfg.CacheActiveTTL,
+		ActiveWindow:  cfg.CacheActiveWindow,
+		IdleTTL:       time.Duration(cfg.CacheTimeout) * time.Second,
+		FinishedAfter: cfg.CacheFinishedAfter,
+		FinishedTTL:   cfg.CacheFinishedTTL,
+	})
+	healthService := service.NewHealthService(metricRepo, redisClient, logger)
+	ingestLimiter := service.NewIngestLimiter(service.IngestLimits{
+		Rate:           cfg.IngestRateLimit,
+		Burst:          cfg.IngestBurst,
+		RunRate:        cfg.IngestRunRateLimit,
+		RunBurst:       cfg.IngestRunBurst,
+		QueueThreshold: cfg.IngestQueueThreshold,
+		RetryAfter:     cfg.IngestRetryAfter,
+	})
+	if cfg.IngestShards > 0 {
+		ingestPool := service.NewIngestPool(cfg.IngestShards, cfg.IngestQueueSize)
+		metricService.UseIngestPool(ingestPool)
+		healthService.UseIngestPool(ingestPool)
+		ingestLimiter.UseIngestPool(ingestPool)
+		go ingestPool.Run(bgCtx)
+	}
+	// Buffered metrics are written before the service exits. The buffer stops
+	// only once the servers enqueueing into it have drained.
+	bufferCtx, stopBuffer := context.WithCancel(context.Background())
+	defer stopBuffer()
+	bufferFlushed := make(chan struct{})
+	if cfg.WriteBufferEnabled {
+		writeBuffer := service.NewWriteBuffer(metricService, cfg.BatchSize, cfg.FlushInterval, logger)
+		metricService.UseWriteBuffer(writeBuffer)
+		ingestLimiter.UseWriteBuffer(writeBuffer)
+		go func() {
+			writeBuffer.Run(bufferCtx)
+			close(bufferFlushed)
+		}()
+	} else {
+		close(bufferFlushed)
+	}
+	anomalyDetector := service.NewAnomalyDetector(anomalyRepo, redisClient, service.AnomalyOptions{
+		ZScoreThreshold: cfg.AnomalyZScoreThreshold,
+		EWMAAlpha:       cfg.AnomalyEWMAAlpha,
+		WarmupSamples:   cfg.AnomalyWarmupSamples,
+		NaNStreak:       cfg.AnomalyNaNStreak,
+	}, logger)
+	earlyStoppingService := service.NewEarlyStoppingService(metricRepo, anomalyRepo, redisClient, logger)
+	sweepService := service.NewSweepService(sweepRepo, metricRepo, logger)
+	tagService := service.NewTagService(tagRepo, metricRepo, logger)
+	reportService := service.NewReportService(reportRepo, metricRepo, cfg.QueryParallelism, logger)
+	summaryService := service.NewSummaryService(summaryRepo, definitionService, logger)
+	artifactService := service.NewArtifactService(artifactRepo, summaryRepo, logger)
+	checkpointService := service.NewCheckpointService(checkpointRepo, artifactRepo, summaryService, logger)
+	mediaService := service.NewMediaService(mediaRepo, objectStore, logger)
+	mediaService.UseSignedURLs(cfg.ObjectStorageSignedURLTTL)
+	histogramService := service.NewHistogramService(histogramRepo, logger)
+	embeddingService := service.NewEmbeddingService(embeddingRepo, logger)
+	tableService := service.NewTableService(tableRepo, logger)
+	logService := service.NewLogService(logRepo, redisClient, logger)
+	annotationService := service.NewAnnotationService(annotationRepo, redisClient, logger)
+	runEventService := service.NewRunEventService(runEventRepo, redisClient, logger)
+	gpuService := service.NewGPUService(gpuRepo, logger)
+	nodeService := service.NewNodeService(nodeRepo, logger)
+	groupService := service.NewGroupService(groupRepo, logger)
+	projectService := service.NewProjectService(projectRepo, logger)
+	grafanaService := service.NewGrafanaService(metricService, annotationService, logger)
+	forecastService := service.NewForecastService(metricRepo, logger)
+	runConfigService := service.NewRunConfigService(runConfigRepo, tagRepo, groupRepo, projectRepo, summaryRepo, cfg.QueryParallelism, logger)
+	leaderboardService := service.NewLeaderboardService(leaderboardRepo, logger)
+	if err := leaderboardService.Refresh(context.Background()); err != nil {
+		logger.Error("Failed to load leaderboard objectives", zap.Error(err))
+	}
+	go leaderboardService.Run(bgCtx)
+
+	metricService.RegisterObserver(summaryService)
+	metricService.RegisterObserver(projectService)
+	metricService.RegisterObserver(checkpointService)
+	// Registered after projectService so runs first seen in a batch are ranked
+	metricService.RegisterObserver(leaderboardService)
+
+	if len(cfg.WebhookURLs) > 0 {
+		thresholds, err := model.ParseWebhookThresholds(cfg.WebhookThresholds)
+		if err != nil {
+			logger.Fatal("Invalid WEBHOOK_THRESHOLDS", zap.Error(err))
+		}
+		webhooks := service.NewWebhookNotifier(redisClient, service.WebhookOptions{
+			URLs:         cfg.WebhookURLs,
+			Secret:       cfg.WebhookSecret,
+			EveryNPoints: int64(cfg.WebhookEveryNPoints),
+			Thresholds:   thresholds,
+			Timeout:      cfg.WebhookTimeout,
+		}, logger)
+		metricService.RegisterObserver(webhooks)
+		go webhooks.Run(bgCtx)
+	}
+
+	if len(cfg.KafkaBrokers) > 0 {
+		kafkaEgress := service.NewKafkaEgress(newKafkaProducer(cfg), projectService, cfg.KafkaTopicPrefix, logger)
+		metricService.RegisterObserver(kafkaEgress)
+		go kafkaEgress.Run(bgCtx)
+	}
+
+	if sinks := cloudEventSinks(cfg, logger); len(sinks) > 0 {
+		events := service.NewCloudEventPublisher(cfg.CloudEventsSource, sinks, logger)
+		metricService.UseCloudEvents(events)
+		runEventService.UseCloudEvents(events)
+		earlyStoppingService.UseCloudEvents(events)
+		anomalyDetector.UseCloudEvents(events)
+		go events.Run(bgCtx)
+	}
+
+	if cfg.MQTTBrokerURL != "" {
+		bridge := mqttbridge.New(metricService, mqttbridge.Options{
+			BrokerURL:     cfg.MQTTBrokerURL,
+			ClientID:      mqttClientID(cfg),
+			Username:      cfg.MQTTUsername,
+			Password:      cfg.MQTTPassword,
+			TopicPrefix:   cfg.MQTTTopicPrefix,
+			SharedGroup:   cfg.MQTTSharedGroup,
+			QoS:           byte(cfg.MQTTQoS),
+			BatchSize:     cfg.MQTTBatchSize,
+			FlushInterval: cfg.MQTTFlushInterval,
+		}, logger)
+		go bridge.Run(bgCtx)
+	}
+
+	if cfg.StatsDAddr != "" {
+		statsd, err := service.NewStatsDForwarder(service.StatsDOptions{
+			Addr:    cfg.StatsDAddr,
+			Prefix:  cfg.StatsDPrefix,
+			Metrics: cfg.StatsDMetrics,
+			Tags:    cfg.StatsDTags,
+		}, logger)
+		if err != nil {
+			logger.Fatal("Failed to set up StatsD forwarding", zap.Error(err))
+		}
+		defer statsd.Close()
+		metricService.RegisterObserver(statsd)
+		metricService.RegisterSystemObserver(statsd)
+		gpuService.RegisterObserver(statsd)
+	}
+
+	if cfg.AnomalyDetectionEnabled {
+		metricService.RegisterObserver(anomalyDetector)
+		go anomalyDetector.Run(bgCtx)
+	}
+
+	// Periodic jobs run on whichever instance holds the scheduler lease
+	maintenanceService := service.NewMaintenanceService(maintenanceRepo, summaryService, objectStore, logger)
+	scheduler := service.NewScheduler(redisClient, cfg.SchedulerLease, logger)
+	if len(cfg.RetentionPeriods) > 0 {
+		for table := range cfg.RetentionPeriods {
+			if !slices.Contains(repository.RetentionTables, table) {
+				logger.Fatal("Unknown table in RETENTION_PERIODS", zap.String("table", table))
+			}
+		}
+		scheduler.Register(maintenanceService.RetentionJob(cfg.RetentionPeriods, cfg.RetentionInterval))
+	}
+	if cfg.SummaryRecomputeInterval > 0 {
+		scheduler.Register(maintenanceService.SummaryRecomputeJob(cfg.SummaryRecomputeInterval))
+	}
+	if cfg.LakehouseExportInterval > 0 {
+		scheduler.Register(maintenanceService.LakehouseExportJob(service.LakehouseExportOptions{
+			Prefix:  cfg.LakehouseExportPrefix,
+			Delay:   cfg.LakehouseExportDelay,
+			MaxDays: cfg.LakehouseExportMaxDays,
+		}, cfg.LakehouseExportInterval))
+	}
+	exportManager, err := export.NewManager(exportRepo, maintenanceRepo, objectStore, export.Options{
+		Prefix:  cfg.RunExportPrefix,
+		Format:  cfg.RunExportFormat,
+		Settle:  cfg.RunExportSettle,
+		MaxRuns: cfg.RunExportMaxRuns,
+	}, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize run export", zap.Error(err))
+	}
+	if cfg.RunExportInterval > 0 {
+		scheduler.Register(exportManager.Job(cfg.RunExportInterval))
+	}
+	if cfg.CrashDetectionEnabled {
+		crashDetector := service.NewCrashDetector(crashRepo, runEventService, service.CrashOptions{
+			Silence:         cfg.CrashSilence,
+			Lookback:        cfg.CrashLookback,
+			MemoryThreshold: cfg.CrashMemoryThreshold,
+		}, logger)
+		scheduler.Register(crashDetector.Job(cfg.CrashDetectionInterval))
+	}
+	if cfg.SchedulerEnabled {
+		healthService.UseScheduler(scheduler)
+		go scheduler.Run(bgCtx)
+	}
 
 	// Initialize handlers
-	metricHandler := handler.NewMetricHandler(metricService, logger)
+	metricHandler := handler.NewMetricHandler(metricService, artifactService, annotationService, cfg.StrictQueryParams, logger)
+	metricStreamHandler := handler.NewMetricStreamHandler(metricService, cfg.MetricStreamChunkSize, cfg.MetricStreamFlushInterval, logger)
+	anomalyHandler := handler.NewAnomalyHandler(anomalyDetector, logger)
+	earlyStoppingHandler := handler.NewEarlyStoppingHandler(earlyStoppingService, logger)
+	sweepHandler := handler.NewSweepHandler(sweepService, cfg.StrictQueryParams, logger)
+	tagHandler := handler.NewTagHandler(tagService, cfg.StrictQueryParams, logger)
+	reportHandler := handler.NewReportHandler(reportService, cfg.StrictQueryParams, logger)
+	summaryHandler := handler.NewSummaryHandler(summaryService, logger)
+	definitionHandler := handler.NewDefinitionHandler(definitionService, logger)
+	artifactHandler := handler.NewArtifactHandler(artifactService, logger)
+	checkpointHandler := handler.NewCheckpointHandler(checkpointService, logger)
+	mediaHandler := handler.NewMediaHandler(mediaService, cfg.MediaMaxUploadBytes, logger)
+	histogramHandler := handler.NewHistogramHandler(histogramService, logger)
+	embeddingHandler := handler.NewEmbeddingHandler(embeddingService, logger)
+	tableHandler := handler.NewTableHandler(tableService, logger)
+	logHandler := handler.NewLogHandler(logService, cfg.StrictQueryParams, logger)
+	annotationHandler := handler.NewAnnotationHandler(annotationService, logger)
+	runEventHandler := handler.NewRunEventHandler(runEventService, logger)
+	gpuHandler := handler.NewGPUHandler(gpuService, logger)
+	nodeHandler := handler.NewNodeHandler(nodeService, logger)
+	groupHandler := handler.NewGroupHandler(groupService, logger)
+	projectHandler := handler.NewProjectHandler(projectService, logger)
+	leaderboardHandler := handler.NewLeaderboardHandler(leaderboardService, logger)
+	forecastHandler := handler.NewForecastHandler(forecastService, logger)
+	runConfigHandler := handler.NewRunConfigHandler(runConfigService, logger)
+	schedulerHandler := handler.NewSchedulerHandler(scheduler, logger)
+	exportHandler := handler.NewExportHandler(exportManager, logger)
 	wsHandler := handler.NewWebSocketHandler(metricService, logger)
+	healthHandler := handler.NewHealthHandler(healthService, logger)
+	grafanaHandler := handler.NewGrafanaHandler(grafanaService, logger)
+	prometheusHandler := handler.NewPrometheusHandler(metricService, cfg.PrometheusMetrics, cfg.PrometheusActiveWithin, logger)
+
+	// Run validation against the run service
+	var runClient *runservice.Client
+	var runValidator *handler.RunValidator
+	if cfg.RunServiceURL != "" {
+		runClient = runservice.NewClient(runservice.Options{
+			BaseURL:          cfg.RunServiceURL,
+			Timeout:          cfg.RunServiceTimeout,
+			CacheTTL:         cfg.RunValidationCacheTTL,
+			NegativeCacheTTL: 30 * time.Second,
+			FailureThreshold: 5,
+			Cooldown:         30 * time.Second,
+		}, logger)
+		runValidator = handler.NewRunValidator(runClient, cfg.RunValidationFailOpen, logger)
+	}
 
 	// Setup Gin router
 	if cfg.Environment == "production" {
@@ -64,29 +377,227 @@ func main() {
 	router.Use(gin.Recovery())
 	router.Use(corsMiddleware())
 	router.Use(loggingMiddleware(logger))
+	if cfg.CompressionEnabled {
+		router.Use(handler.Compression(cfg.CompressionLevel, cfg.CompressionMinSize))
+	}
 
 	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "healthy"})
-	})
+	router.GET("/health", healthHandler.GetHealth)
+
+	// API routes. v2 serves the same handlers with the v2 response envelope
+	// and error model; v1 keeps its response shapes but is marked deprecated.
+	apiDoc := openapi.New("wanLLMDB Metric Service API", "1.0.0", "/api/v1")
+	registerRoutes := func(api *gin.RouterGroup) {
+		api.Use(handler.NormalizeTimestamps(cfg.StrictQueryParams, "start_time", "end_time"))
+		if cfg.OpenAPIValidateRequests {
+			api.Use(handler.ValidateRequests(apiDoc))
+		}
+		api.Use(handler.FieldMask())
+		api.Use(handler.QueryTimeout(cfg.QueryTimeout, cfg.QueryTimeoutOverrides, cfg.ExportTimeout))
+		if runValidator != nil {
+			api.Use(runValidator.Middleware())
+		}
 
-	// API routes
-	v1 := router.Group("/api/v1")
-	{
-		// Metric endpoints
-		v1.POST("/metrics/batch", metricHandler.BatchWrite)
-		v1.GET("/runs/:run_id/metrics", metricHandler.GetRunMetrics)
-		v1.GET("/runs/:run_id/metrics/:metric_name", metricHandler.GetMetricHistory)
-		v1.GET("/runs/:run_id/metrics/:metric_name/latest", metricHandler.GetLatestMetric)
-		v1.GET("/runs/:run_id/metrics/:metric_name/stats", metricHandler.GetMetricStats)
+		// Metric endpoints. Batches may be sent gzip or zstd compressed, and
+		// are shed with 429 when ingest is saturated or over its rates.
+		decompress := handler.Decompression(cfg.MaxDecompressedBodyBytes)
+		limitIngest := handler.IngestLimit(ingestLimiter)
+		api.POST("/metrics/batch", limitIngest, decompress, metricHandler.BatchWrite)
+		api.POST("/metrics/stream", limitIngest, metricStreamHandler.Ingest)
+		api.GET("/runs/:run_id/metrics", metricHandler.GetRunMetrics)
+		api.GET("/runs/:run_id/metrics/tree", metricHandler.GetMetricTree)
+		api.GET("/runs/:run_id/metric-names", metricHandler.GetMetricNames)
+		api.GET("/runs/:run_id/metrics/export", metricHandler.ExportMetrics)
+		api.GET("/runs/:run_id/metrics/openmetrics", metricHandler.ScrapeRunMetrics)
+		api.GET("/runs/:run_id/metrics/count", metricHandler.CountRunMetrics)
+		api.HEAD("/runs/:run_id/metrics", metricHandler.CountRunMetrics)
+		api.HEAD("/runs/:run_id/metrics/:metric_name", metricHandler.CountRunMetrics)
+		api.GET("/runs/:run_id/metrics/:metric_name", metricHandler.GetMetricHistory)
+		api.DELETE("/runs/:run_id/metrics/:metric_name", metricHandler.DeleteMetricRange)
+		api.GET("/runs/:run_id/metric-deletions", metricHandler.GetMetricDeletions)
+		api.GET("/runs/:run_id/metrics/:metric_name/latest", metricHandler.GetLatestMetric)
+		api.GET("/runs/:run_id/metrics/:metric_name/stats", metricHandler.GetMetricStats)
+		api.GET("/runs/:run_id/metrics/:metric_name/aggregate", metricHandler.GetMetricAggregate)
+		api.POST("/runs/:run_id/metrics/stats", metricHandler.GetMetricStatsBatch)
+		api.POST("/metrics/compare", metricHandler.CompareMetrics)
+		api.GET("/runs/:run_id/metrics/:metric_name/forecast", forecastHandler.GetForecast)
 
 		// System metrics
-		v1.POST("/metrics/system/batch", metricHandler.BatchWriteSystemMetrics)
-		v1.GET("/runs/:run_id/system-metrics", metricHandler.GetSystemMetrics)
+		api.POST("/metrics/system/batch", limitIngest, decompress, metricHandler.BatchWriteSystemMetrics)
+		api.GET("/runs/:run_id/system-metrics", metricHandler.GetSystemMetrics)
+
+		// GPU metrics
+		api.POST("/metrics/gpu/batch", gpuHandler.BatchWrite)
+		api.GET("/runs/:run_id/gpu-metrics", gpuHandler.GetGPUMetrics)
+		api.GET("/runs/:run_id/gpu-metrics/summary", gpuHandler.GetGPUSummary)
+
+		// Distributed runs: aggregate across nodes and ranks, or break out per node/rank
+		api.GET("/runs/:run_id/metrics/:metric_name/ranks", nodeHandler.GetMetricByRank)
+		api.GET("/runs/:run_id/system-metrics/nodes", nodeHandler.GetSystemMetricsByNode)
+		api.GET("/runs/:run_id/gpu-metrics/nodes", nodeHandler.GetGPUMetricsByNode)
+
+		// Anomaly events
+		api.GET("/runs/:run_id/anomalies", anomalyHandler.GetRunAnomalies)
+
+		// Early stopping
+		api.GET("/runs/:run_id/should-stop", earlyStoppingHandler.ShouldStop)
+		api.POST("/runs/:run_id/stop", earlyStoppingHandler.RequestStop)
+		api.DELETE("/runs/:run_id/stop", earlyStoppingHandler.ClearStop)
+
+		// Hyperparameter sweeps
+		api.POST("/sweeps", sweepHandler.CreateSweep)
+		api.GET("/sweeps/:sweep_id", sweepHandler.GetSweep)
+		api.POST("/sweeps/:sweep_id/next", sweepHandler.NextTrial)
+		api.POST("/sweeps/:sweep_id/trials/:trial_id/report", sweepHandler.ReportTrial)
+		api.GET("/sweeps/:sweep_id/leaderboard", sweepHandler.GetLeaderboard)
+
+		// Run tags
+		api.GET("/runs", tagHandler.ListRuns)
+		api.GET("/runs/:run_id/tags", tagHandler.GetRunTags)
+		api.PUT("/runs/:run_id/tags", tagHandler.SetRunTags)
+		api.DELETE("/runs/:run_id/tags/:key", tagHandler.DeleteRunTag)
+		api.GET("/metrics/stats", tagHandler.GetMetricStatsByTags)
+
+		// Saved comparison reports
+		api.POST("/reports", reportHandler.CreateReport)
+		api.GET("/reports", reportHandler.ListReports)
+		api.GET("/reports/:report_id", reportHandler.GetReport)
+		api.GET("/reports/:report_id/data", reportHandler.GetReportData)
+		api.PUT("/reports/:report_id", reportHandler.UpdateReport)
+		api.DELETE("/reports/:report_id", reportHandler.DeleteReport)
+
+		// Run summaries
+		api.GET("/summaries", summaryHandler.ListSummaries)
+		api.GET("/runs/:run_id/summary", summaryHandler.GetRunSummary)
+		api.POST("/runs/:run_id/summary/recompute", summaryHandler.RecomputeRunSummary)
+
+		// Run groups
+		api.GET("/groups", groupHandler.ListGroups)
+		api.GET("/groups/:group/metrics/*metric_name", groupHandler.GetGroupMetric)
+		api.GET("/runs/:run_id/group", groupHandler.GetRunGroup)
+		api.PUT("/runs/:run_id/group", groupHandler.SetRunGroup)
+		api.DELETE("/runs/:run_id/group", groupHandler.DeleteRunGroup)
+
+		// Projects and experiments
+		api.GET("/projects/:project_id/runs", projectHandler.ListProjectRuns)
+		api.GET("/projects/:project_id/runs/latest-metrics", projectHandler.ListLatestMetrics)
+		api.GET("/projects/:project_id/experiments", projectHandler.ListExperiments)
+		api.GET("/runs/:run_id/project", projectHandler.GetRunProject)
+		api.PUT("/runs/:run_id/project", projectHandler.SetRunProject)
+
+		// Run configs and diffs
+		api.GET("/runs/:run_id/config", runConfigHandler.GetRunConfig)
+		api.PUT("/runs/:run_id/config", runConfigHandler.SetRunConfig)
+		api.POST("/runs/diff", runConfigHandler.DiffRuns)
+
+		// Project leaderboards
+		api.GET("/projects/:project_id/leaderboard", leaderboardHandler.ListEntries)
+		api.GET("/projects/:project_id/leaderboard/config", leaderboardHandler.GetLeaderboard)
+		api.PUT("/projects/:project_id/leaderboard/config", leaderboardHandler.SetLeaderboard)
+		api.DELETE("/projects/:project_id/leaderboard/config", leaderboardHandler.DeleteLeaderboard)
+
+		// Metric definitions registry
+		api.GET("/projects/:project_id/metric-definitions", definitionHandler.ListDefinitions)
+		api.POST("/projects/:project_id/metric-definitions", definitionHandler.SaveDefinition)
+		api.GET("/projects/:project_id/metric-definitions/*name", definitionHandler.GetDefinition)
+		api.DELETE("/projects/:project_id/metric-definitions/*name", definitionHandler.DeleteDefinition)
+
+		// Artifact linkage
+		api.POST("/runs/:run_id/artifacts", artifactHandler.CreateArtifact)
+		api.GET("/runs/:run_id/artifacts", artifactHandler.GetRunArtifacts)
+		api.GET("/runs/:run_id/artifacts/lookup", artifactHandler.LookupArtifact)
+
+		// Checkpoints ranked by objective metrics
+		api.POST("/runs/:run_id/checkpoints", checkpointHandler.RegisterCheckpoint)
+		api.GET("/runs/:run_id/checkpoints/best", checkpointHandler.GetBestCheckpoints)
+		api.GET("/runs/:run_id/checkpoints/objectives", checkpointHandler.GetObjectives)
+		api.PUT("/runs/:run_id/checkpoints/objectives", checkpointHandler.SetObjectives)
+
+		// Media logging
+		api.POST("/runs/:run_id/media", mediaHandler.UploadMedia)
+		api.GET("/runs/:run_id/media", mediaHandler.GetRunMedia)
+		api.GET("/runs/:run_id/media/:media_id/content", mediaHandler.GetMediaContent)
+
+		// Histogram metrics
+		api.POST("/metrics/histograms/batch", histogramHandler.BatchWrite)
+		api.GET("/runs/:run_id/histograms", histogramHandler.ListHistograms)
+		api.GET("/runs/:run_id/histograms/:metric_name", histogramHandler.GetHistogramHistory)
+
+		// Embedding vectors
+		api.POST("/metrics/embeddings/batch", embeddingHandler.BatchWrite)
+		api.GET("/runs/:run_id/embeddings", embeddingHandler.ListEmbeddings)
+		api.GET("/runs/:run_id/embeddings/:metric_name", embeddingHandler.GetEmbeddings)
+		api.POST("/runs/:run_id/embeddings/:metric_name/nearest", embeddingHandler.Nearest)
+		api.GET("/runs/:run_id/embeddings/:metric_name/drift", embeddingHandler.GetDrift)
+
+		// Tables and confusion matrices
+		api.POST("/metrics/tables/batch", tableHandler.BatchWrite)
+		api.GET("/runs/:run_id/tables", tableHandler.ListTables)
+		api.GET("/runs/:run_id/tables/:metric_name", tableHandler.GetTableHistory)
+		api.GET("/runs/:run_id/tables/:metric_name/latest", tableHandler.GetLatestTable)
+
+		// Console logs
+		api.POST("/runs/:run_id/logs", logHandler.WriteLogs)
+		api.GET("/runs/:run_id/logs", logHandler.GetRunLogs)
+
+		// Timeline annotations
+		api.POST("/runs/:run_id/annotations", annotationHandler.CreateAnnotation)
+		api.GET("/runs/:run_id/annotations", annotationHandler.GetRunAnnotations)
+		api.DELETE("/runs/:run_id/annotations/:annotation_id", annotationHandler.DeleteAnnotation)
+
+		// Run state events
+		api.POST("/runs/:run_id/events", runEventHandler.AppendEvent)
+		api.GET("/runs/:run_id/events", runEventHandler.GetRunEvents)
+		api.GET("/runs/:run_id/state", runEventHandler.GetRunState)
+
+		// Background jobs
+		api.GET("/admin/jobs", schedulerHandler.ListJobs)
+		api.POST("/admin/jobs/:name/run", schedulerHandler.RunJob)
+
+		// Exports of ended runs to object storage
+		api.GET("/admin/exports", exportHandler.ListExports)
+		api.POST("/admin/exports/:run_id", exportHandler.ExportRun)
+
+		// Grafana JSON datasource
+		api.GET("/grafana", grafanaHandler.TestConnection)
+		api.POST("/grafana/search", grafanaHandler.Search)
+		api.POST("/grafana/query", grafanaHandler.Query)
+		api.POST("/grafana/annotations", grafanaHandler.Annotations)
+
+		// Prometheus exposition of the configured metrics
+		api.GET("/prometheus/metrics", prometheusHandler.Scrape)
+
+		// Prometheus remote read of run metrics
+		api.POST("/prom/read", prometheusHandler.Read)
+
+		// OpenMetrics export of the hourly rollups
+		api.GET("/rollups/openmetrics", metricHandler.ExportRollups)
+	}
+
+	v1 := router.Group("/api/v1", handler.DeprecatedVersion("/api/v1", "/api/v2", cfg.APIV1DeprecatedAt, cfg.APIV1Sunset))
+	registerRoutes(v1)
+	v2 := router.Group("/api/v2", handler.V2Envelope())
+	registerRoutes(v2)
+
+	routeKeys := make(map[string]bool)
+	for _, route := range router.Routes() {
+		routeKeys[handler.RouteKey(route.Path)] = true
+	}
+	for route := range cfg.QueryTimeoutOverrides {
+		if !routeKeys[route] {
+			logger.Fatal("Unknown route in QUERY_TIMEOUT_OVERRIDES", zap.String("route", route))
+		}
 	}
 
+	// OpenAPI document of the routes registered above
+	for _, route := range apiDoc.AddRoutes(router.Routes(), handler.APIRoutes) {
+		logger.Warn("Described route is not registered", zap.String("route", route))
+	}
+	router.GET("/api/v1/openapi.json", handler.ServeOpenAPI(apiDoc))
+
 	// WebSocket endpoint
 	router.GET("/ws/metrics/:run_id", wsHandler.HandleConnection)
+	router.GET("/ws/logs/:run_id", logHandler.TailLogs)
 
 	// Start server
 	srv := &http.Server{
@@ -103,20 +614,83 @@ func main() {
 
 	logger.Info("Metric service started", zap.Int("port", cfg.Port))
 
+	// gRPC API, next to the REST API
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort > 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+		if err != nil {
+			logger.Fatal("Failed to listen for gRPC", zap.Error(err))
+		}
+		grpcServer = grpc.NewServer()
+		metricsv1.RegisterMetricQueryServiceServer(grpcServer, grpcapi.NewQueryServer(metricService, summaryService, logger))
+		ingestServer := grpcapi.NewIngestServer(metricService, logger)
+		ingestServer.UseIngestLimiter(ingestLimiter)
+		if runClient != nil {
+			ingestServer.UseRunValidation(runClient, cfg.RunValidationFailOpen)
+		}
+		metricsv1.RegisterMetricIngestServiceServer(grpcServer, ingestServer)
+		reflection.Register(grpcServer)
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				logger.Fatal("Failed to serve gRPC", zap.Error(err))
+			}
+		}()
+		logger.Info("gRPC API started", zap.Int("port", cfg.GRPCPort))
+	}
+
+	// Arrow Flight service, on a gRPC server of its own
+	var flightServer *grpc.Server
+	if cfg.FlightPort > 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.FlightPort))
+		if err != nil {
+			logger.Fatal("Failed to listen for Arrow Flight", zap.Error(err))
+		}
+		flightServer = grpc.NewServer(grpcapi.FlightCodec())
+		grpcapi.RegisterFlightServer(flightServer, grpcapi.NewFlightServer(metricService, logger))
+		go func() {
+			if err := flightServer.Serve(lis); err != nil {
+				logger.Fatal("Failed to serve Arrow Flight", zap.Error(err))
+			}
+		}()
+		logger.Info("Arrow Flight service started", zap.Int("port", cfg.FlightPort))
+	}
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	logger.Info("Shutting down server...")
+	stopBackground()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	for _, server := range []*grpc.Server{grpcServer, flightServer} {
+		if server == nil {
+			continue
+		}
+		// Streams still running at the deadline are cut off
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			server.Stop()
+		}
+	}
+
 	if err := srv.Shutdown(ctx); err != nil {
-		logger.Fatal("Server forced to shutdown", zap.Error(err))
+		logger.Error("Server forced to shutdown", zap.Error(err))
 	}
 
+	// Batches answered 202 are written before the process exits
+	stopBuffer()
+	<-bufferFlushed
+
 	logger.Info("Server exited")
 }
 
@@ -125,7 +699,8 @@ func corsMiddleware() gin.HandlerFunc {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, HEAD, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Deprecation, Sunset, Link, X-Total-Count, X-Next-Cursor")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -155,3 +730,90 @@ func loggingMiddleware(logger *zap.Logger) gin.HandlerFunc {
 		)
 	}
 }
+
+// newObjectStore opens the configured object store
+func newObjectStore(cfg *config.Config) (storage.ObjectStore, error) {
+	if cfg.ObjectStorageBackend != "s3" {
+		return storage.NewLocalStore(cfg.ObjectStorageDir)
+	}
+	return storage.NewS3Store(storage.S3Options{
+		Endpoint:             cfg.S3Endpoint,
+		Region:               cfg.S3Region,
+		Bucket:               cfg.S3Bucket,
+		AccessKeyID:          cfg.S3AccessKeyID,
+		SecretAccessKey:      cfg.S3SecretAccessKey,
+		SessionToken:         cfg.S3SessionToken,
+		PathStyle:            cfg.S3PathStyle,
+		ServerSideEncryption: cfg.S3ServerSideEncryption,
+		KMSKeyID:             cfg.S3KMSKeyID,
+		PartSize:             int64(cfg.S3PartSize),
+		Timeout:              cfg.S3Timeout,
+	})
+}
+
+func newKafkaProducer(cfg *config.Config) *kafka.Producer {
+	opts := kafka.Options{
+		Brokers:      cfg.KafkaBrokers,
+		ClientID:     cfg.KafkaClientID,
+		RequiredAcks: kafka.AcksAll,
+		Timeout:      cfg.KafkaTimeout,
+	}
+	if cfg.KafkaAcks == "leader" {
+		opts.RequiredAcks = kafka.AcksLeader
+	}
+	if cfg.KafkaTLS {
+		opts.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return kafka.NewProducer(opts)
+}
+
+// cloudEventSinks are the configured destinations of CloudEvents
+func cloudEventSinks(cfg *config.Config, logger *zap.Logger) []service.EventSink {
+	var sinks []service.EventSink
+	for _, url := range cfg.CloudEventsURLs {
+		sinks = append(sinks, service.NewWebhookEventSink(url, cfg.WebhookSecret, cfg.WebhookTimeout, logger))
+	}
+	if cfg.CloudEventsKafkaTopic != "" {
+		sinks = append(sinks, service.NewKafkaEventSink(newKafkaProducer(cfg), cfg.CloudEventsKafkaTopic))
+	}
+	if cfg.CloudEventsPubSubTopic != "" {
+		client, err := pubsub.NewClient(pubsub.Options{
+			Topic:           cfg.CloudEventsPubSubTopic,
+			CredentialsFile: cfg.GoogleCredentialsFile,
+			EmulatorHost:    cfg.PubSubEmulatorHost,
+			Timeout:         cfg.WebhookTimeout,
+		})
+		if err != nil {
+			logger.Fatal("Failed to create Pub/Sub client", zap.Error(err))
+		}
+		sinks = append(sinks, service.NewPubSubEventSink(client, cfg.CloudEventsPubSubTopic))
+	}
+	if cfg.CloudEventsSNSTopicARN != "" {
+		client, err := sns.NewClient(sns.Options{
+			TopicARN:        cfg.CloudEventsSNSTopicARN,
+			Endpoint:        cfg.SNSEndpoint,
+			AccessKeyID:     cfg.AWSAccessKeyID,
+			SecretAccessKey: cfg.AWSSecretAccessKey,
+			SessionToken:    cfg.AWSSessionToken,
+			Timeout:         cfg.WebhookTimeout,
+		})
+		if err != nil {
+			logger.Fatal("Failed to create SNS client", zap.Error(err))
+		}
+		sinks = append(sinks, service.NewSNSEventSink(client, cfg.CloudEventsSNSTopicARN))
+	}
+	return sinks
+}
+
+// mqttClientID is the configured MQTT client ID, or one per host. The broker
+// keeps the session of an ID across restarts, with the messages it missed.
+func mqttClientID(cfg *config.Config) string {
+	if cfg.MQTTClientID != "" {
+		return cfg.MQTTClientID
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return "metric-service-" + host
+}
@@ -0,0 +1,95 @@
+//go:build linux && cgo
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type nvmlDevice struct {
+	index  int
+	handle nvml.Device
+	uuid   string
+	name   string
+}
+
+// nvmlCollector reads per-device statistics through NVML, which is loaded
+// from the driver's libnvidia-ml.so at runtime
+type nvmlCollector struct {
+	devices []nvmlDevice
+}
+
+func newGPUCollector() (gpuCollector, error) {
+	ret := nvml.Init()
+	if ret == nvml.ERROR_LIBRARY_NOT_FOUND {
+		// ErrorString itself lives in the missing library
+		return nil, fmt.Errorf("NVML library not found; no NVIDIA driver installed")
+	}
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to initialize NVML: %s", nvml.ErrorString(ret))
+	}
+
+	count, ret := nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		nvml.Shutdown()
+		return nil, fmt.Errorf("failed to count GPUs: %s", nvml.ErrorString(ret))
+	}
+
+	c := &nvmlCollector{}
+	for i := 0; i < count; i++ {
+		handle, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			continue
+		}
+		uuid, _ := nvml.DeviceGetUUID(handle)
+		name, _ := nvml.DeviceGetName(handle)
+		c.devices = append(c.devices, nvmlDevice{index: i, handle: handle, uuid: uuid, name: name})
+	}
+
+	return c, nil
+}
+
+// Collect samples utilization, memory, temperature and power of every GPU;
+// readings a device doesn't support are left nil
+func (c *nvmlCollector) Collect(now time.Time) ([]model.GPUMetric, error) {
+	samples := make([]model.GPUMetric, 0, len(c.devices))
+	for _, d := range c.devices {
+		sample := model.GPUMetric{
+			Time:        now,
+			DeviceIndex: d.index,
+			DeviceUUID:  d.uuid,
+			DeviceName:  d.name,
+		}
+
+		if util, ret := nvml.DeviceGetUtilizationRates(d.handle); ret == nvml.SUCCESS {
+			sample.Utilization = float64Ptr(float64(util.Gpu))
+			sample.MemoryUtilization = float64Ptr(float64(util.Memory))
+		}
+		if mem, ret := nvml.DeviceGetMemoryInfo(d.handle); ret == nvml.SUCCESS {
+			sample.MemoryUsedMB = float64Ptr(float64(mem.Used) / (1 << 20))
+			sample.MemoryTotalMB = float64Ptr(float64(mem.Total) / (1 << 20))
+		}
+		if temp, ret := nvml.DeviceGetTemperature(d.handle, nvml.TEMPERATURE_GPU); ret == nvml.SUCCESS {
+			sample.TemperatureC = float64Ptr(float64(temp))
+		}
+		if power, ret := nvml.DeviceGetPowerUsage(d.handle); ret == nvml.SUCCESS {
+			sample.PowerW = float64Ptr(float64(power) / 1000)
+		}
+
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func (c *nvmlCollector) Close() {
+	nvml.Shutdown()
+}
@@ -0,0 +1,9 @@
+//go:build !linux || !cgo
+
+package main
+
+import "errors"
+
+func newGPUCollector() (gpuCollector, error) {
+	return nil, errors.New("NVML support requires a Linux build with cgo enabled")
+}
@@ -0,0 +1,244 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// diskSectorBytes is the unit of the sector counters in /proc/diskstats
+const diskSectorBytes = 512
+
+type cpuTimes struct {
+	idle  uint64
+	total uint64
+}
+
+type ioCounters struct {
+	read    uint64
+	written uint64
+}
+
+// hostCollector reads CPU, memory, disk and network statistics from /proc.
+// Rates are computed from the previous sample, so the first call only
+// reports gauges.
+type hostCollector struct {
+	diskPath string
+
+	prevTime time.Time
+	prevCPU  *cpuTimes
+	prevDisk *ioCounters
+	prevNet  *ioCounters
+}
+
+func newHostCollector(diskPath string) *hostCollector {
+	return &hostCollector{diskPath: diskPath}
+}
+
+// Collect takes one sample of every host statistic that can be read
+func (c *hostCollector) Collect(now time.Time) []model.SystemMetric {
+	var samples []model.SystemMetric
+	elapsed := now.Sub(c.prevTime).Seconds()
+
+	if cpu, err := readCPUTimes(); err == nil {
+		if c.prevCPU != nil && cpu.total > c.prevCPU.total {
+			busy := 1 - float64(cpu.idle-c.prevCPU.idle)/float64(cpu.total-c.prevCPU.total)
+			metadata := map[string]interface{}{"cores": runtime.NumCPU()}
+			if load, err := readLoadAverage(); err == nil {
+				metadata["load1"] = load
+			}
+			samples = append(samples, model.SystemMetric{
+				Time:       now,
+				MetricType: "cpu",
+				Value:      busy * 100,
+				Metadata:   metadata,
+			})
+		}
+		c.prevCPU = &cpu
+	}
+
+	if total, available, err := readMemInfo(); err == nil && total > 0 {
+		used := total - available
+		samples = append(samples, model.SystemMetric{
+			Time:       now,
+			MetricType: "memory",
+			Value:      float64(used) / float64(total) * 100,
+			Metadata: map[string]interface{}{
+				"used_mb":  used / 1024,
+				"total_mb": total / 1024,
+			},
+		})
+	}
+
+	if disk, err := readDiskCounters(); err == nil {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(c.diskPath, &stat); err == nil && stat.Blocks > 0 {
+			metadata := map[string]interface{}{
+				"path":     c.diskPath,
+				"used_gb":  float64((stat.Blocks-stat.Bfree)*uint64(stat.Bsize)) / (1 << 30),
+				"total_gb": float64(stat.Blocks*uint64(stat.Bsize)) / (1 << 30),
+			}
+			if c.prevDisk != nil && elapsed > 0 {
+				metadata["read_bytes_per_sec"] = float64(disk.read-c.prevDisk.read) / elapsed
+				metadata["write_bytes_per_sec"] = float64(disk.written-c.prevDisk.written) / elapsed
+			}
+			samples = append(samples, model.SystemMetric{
+				Time:       now,
+				MetricType: "disk",
+				Value:      float64(stat.Blocks-stat.Bfree) / float64(stat.Blocks) * 100,
+				Metadata:   metadata,
+			})
+		}
+		c.prevDisk = &disk
+	}
+
+	if net, err := readNetCounters(); err == nil {
+		if c.prevNet != nil && elapsed > 0 {
+			rx := float64(net.read-c.prevNet.read) / elapsed
+			tx := float64(net.written-c.prevNet.written) / elapsed
+			samples = append(samples, model.SystemMetric{
+				Time:       now,
+				MetricType: "network",
+				Value:      rx + tx,
+				Metadata: map[string]interface{}{
+					"rx_bytes_per_sec": rx,
+					"tx_bytes_per_sec": tx,
+				},
+			})
+		}
+		c.prevNet = &net
+	}
+
+	c.prevTime = now
+	return samples
+}
+
+// readCPUTimes parses the aggregate cpu line of /proc/stat
+func readCPUTimes() (cpuTimes, error) {
+	var times cpuTimes
+	err := scanProcFile("/proc/stat", func(fields []string) bool {
+		if fields[0] != "cpu" {
+			return true
+		}
+		for i, f := range fields[1:] {
+			// guest time is already included in user and nice
+			if i >= 8 {
+				break
+			}
+			v, _ := strconv.ParseUint(f, 10, 64)
+			times.total += v
+			if i == 3 || i == 4 { // idle, iowait
+				times.idle += v
+			}
+		}
+		return false
+	})
+	return times, err
+}
+
+func readLoadAverage() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, os.ErrInvalid
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// readMemInfo returns total and available memory in KiB
+func readMemInfo() (total, available uint64, err error) {
+	err = scanProcFile("/proc/meminfo", func(fields []string) bool {
+		if len(fields) < 2 {
+			return true
+		}
+		v, _ := strconv.ParseUint(fields[1], 10, 64)
+		switch fields[0] {
+		case "MemTotal:":
+			total = v
+		case "MemAvailable:":
+			available = v
+		}
+		return true
+	})
+	return total, available, err
+}
+
+// readDiskCounters sums bytes read and written by whole block devices,
+// skipping partitions so I/O is not counted twice
+func readDiskCounters() (ioCounters, error) {
+	var counters ioCounters
+	err := scanProcFile("/proc/diskstats", func(fields []string) bool {
+		if len(fields) < 10 {
+			return true
+		}
+		name := fields[2]
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			return true
+		}
+		if _, err := os.Stat("/sys/block/" + name); err != nil {
+			return true
+		}
+		read, _ := strconv.ParseUint(fields[5], 10, 64)
+		written, _ := strconv.ParseUint(fields[9], 10, 64)
+		counters.read += read * diskSectorBytes
+		counters.written += written * diskSectorBytes
+		return true
+	})
+	return counters, err
+}
+
+// readNetCounters sums bytes received and sent on every interface but loopback
+func readNetCounters() (ioCounters, error) {
+	var counters ioCounters
+	err := scanProcFile("/proc/net/dev", func(fields []string) bool {
+		// Large counters can run into the interface name ("eth0:123456")
+		name, first, found := strings.Cut(fields[0], ":")
+		if !found || name == "lo" {
+			return true
+		}
+		if first != "" {
+			fields = append([]string{name + ":", first}, fields[1:]...)
+		}
+		if len(fields) < 10 {
+			return true
+		}
+		rx, _ := strconv.ParseUint(fields[1], 10, 64)
+		tx, _ := strconv.ParseUint(fields[9], 10, 64)
+		counters.read += rx
+		counters.written += tx
+		return true
+	})
+	return counters, err
+}
+
+// scanProcFile calls fn with the fields of each non-empty line until fn returns false
+func scanProcFile(path string, fn func(fields []string) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if !fn(fields) {
+			break
+		}
+	}
+	return scanner.Err()
+}
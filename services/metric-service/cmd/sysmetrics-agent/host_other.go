@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import (
+	"time"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// hostCollector is only implemented on Linux, where training nodes run
+type hostCollector struct{}
+
+func newHostCollector(diskPath string) *hostCollector {
+	return &hostCollector{}
+}
+
+func (c *hostCollector) Collect(now time.Time) []model.SystemMetric {
+	return nil
+}
@@ -0,0 +1,184 @@
+// Command sysmetrics-agent samples host and GPU statistics on a training node
+// and posts them to the metric service's system and GPU metrics endpoints.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type options struct {
+	serverURL     string
+	token         string
+	runID         uuid.UUID
+	node          string
+	diskPath      string
+	interval      time.Duration
+	flushInterval time.Duration
+	batchSize     int
+	bufferSize    int
+	gpu           bool
+}
+
+func main() {
+	logger, err := zap.NewProduction()
+	if err != nil {
+		fmt.Printf("Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	opts, err := parseOptions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	collector := newHostCollector(opts.diskPath)
+
+	var gpus gpuCollector = noGPUCollector{}
+	if opts.gpu {
+		gpus, err = newGPUCollector()
+		if err != nil {
+			logger.Warn("GPU metrics disabled", zap.Error(err))
+			gpus = noGPUCollector{}
+		}
+	}
+	defer gpus.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	hostSender := newSystemMetricSender(opts.serverURL, opts.token, opts.bufferSize, opts.batchSize, logger)
+	gpuSender := newGPUMetricSender(opts.serverURL, opts.token, opts.bufferSize, opts.batchSize, logger)
+
+	logger.Info("System metrics agent started",
+		zap.String("run_id", opts.runID.String()),
+		zap.String("node", opts.node),
+		zap.String("server", opts.serverURL),
+		zap.Duration("interval", opts.interval))
+
+	sampleTicker := time.NewTicker(opts.interval)
+	defer sampleTicker.Stop()
+	flushTicker := time.NewTicker(opts.flushInterval)
+	defer flushTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Flushing buffered metrics before exit",
+				zap.Int("buffered", hostSender.Buffered()+gpuSender.Buffered()))
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			hostSender.FlushAll(flushCtx)
+			gpuSender.FlushAll(flushCtx)
+			cancel()
+			return
+
+		case now := <-sampleTicker.C:
+			samples := collector.Collect(now)
+			for i := range samples {
+				samples[i].RunID = opts.runID
+				samples[i].NodeID = opts.node
+			}
+			hostSender.Add(samples)
+
+			gpuSamples, err := gpus.Collect(now)
+			if err != nil {
+				logger.Warn("Failed to collect GPU metrics", zap.Error(err))
+			}
+			for i := range gpuSamples {
+				gpuSamples[i].RunID = opts.runID
+				gpuSamples[i].NodeID = opts.node
+			}
+			gpuSender.Add(gpuSamples)
+
+			if hostSender.Buffered() >= opts.batchSize {
+				hostSender.Flush(ctx)
+			}
+			if gpuSender.Buffered() >= opts.batchSize {
+				gpuSender.Flush(ctx)
+			}
+
+		case <-flushTicker.C:
+			hostSender.Flush(ctx)
+			gpuSender.Flush(ctx)
+		}
+	}
+}
+
+func parseOptions() (options, error) {
+	hostname, _ := os.Hostname()
+
+	var opts options
+	var runID string
+	flag.StringVar(&opts.serverURL, "server", envOr("METRIC_SERVICE_URL", "http://localhost:8001"), "metric service base URL")
+	flag.StringVar(&opts.token, "token", os.Getenv("WANLLMDB_API_TOKEN"), "bearer token sent with uploads, required when the server validates runs")
+	flag.StringVar(&runID, "run-id", os.Getenv("WANLLMDB_RUN_ID"), "run to attach metrics to")
+	flag.StringVar(&opts.node, "node", envOr("WANLLMDB_NODE", hostname), "node ID recorded with every sample")
+	flag.StringVar(&opts.diskPath, "disk-path", "/", "filesystem whose usage is reported")
+	flag.DurationVar(&opts.interval, "interval", 10*time.Second, "sampling interval")
+	flag.DurationVar(&opts.flushInterval, "flush-interval", 30*time.Second, "maximum time between uploads")
+	flag.IntVar(&opts.batchSize, "batch-size", 500, "samples per upload (at most 1000)")
+	flag.IntVar(&opts.bufferSize, "buffer-size", envOrInt("WANLLMDB_AGENT_BUFFER", 50000), "samples kept while the server is unreachable")
+	flag.BoolVar(&opts.gpu, "gpu", true, "collect NVIDIA GPU metrics through NVML")
+	flag.Parse()
+
+	if runID == "" {
+		return opts, fmt.Errorf("a run ID is required (-run-id or WANLLMDB_RUN_ID)")
+	}
+	parsed, err := uuid.Parse(runID)
+	if err != nil {
+		return opts, fmt.Errorf("invalid run ID %q: %w", runID, err)
+	}
+	opts.runID = parsed
+
+	if opts.interval <= 0 || opts.flushInterval <= 0 {
+		return opts, fmt.Errorf("intervals must be positive")
+	}
+	if opts.batchSize < 1 || opts.batchSize > 1000 {
+		return opts, fmt.Errorf("batch size must be between 1 and 1000")
+	}
+	if opts.bufferSize < opts.batchSize {
+		return opts, fmt.Errorf("buffer size must be at least the batch size")
+	}
+	return opts, nil
+}
+
+func envOr(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func envOrInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+// gpuCollector samples per-device GPU statistics
+type gpuCollector interface {
+	Collect(now time.Time) ([]model.GPUMetric, error)
+	Close()
+}
+
+type noGPUCollector struct{}
+
+func (noGPUCollector) Collect(time.Time) ([]model.GPUMetric, error) { return nil, nil }
+func (noGPUCollector) Close()                                       {}
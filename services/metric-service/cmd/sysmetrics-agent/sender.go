@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+const (
+	minRetryBackoff = time.Second
+	maxRetryBackoff = time.Minute
+)
+
+// permanentError marks a batch the server will never accept
+type permanentError struct {
+	status int
+	body   string
+}
+
+func (e *permanentError) Error() string {
+	return fmt.Sprintf("server rejected batch with status %d: %s", e.status, e.body)
+}
+
+// sender buffers samples in memory and uploads them in batches to one batch
+// endpoint, backing off while the server is unreachable and dropping the
+// oldest samples when full
+type sender[T any] struct {
+	url       string
+	token     string
+	wrap      func(batch []T) interface{}
+	client    *http.Client
+	capacity  int
+	batchSize int
+	logger    *zap.Logger
+
+	buffer    []T
+	dropped   int
+	backoff   time.Duration
+	nextRetry time.Time
+}
+
+func newSender[T any](serverURL, token, path string, wrap func(batch []T) interface{}, capacity, batchSize int, logger *zap.Logger) *sender[T] {
+	return &sender[T]{
+		url:       strings.TrimRight(serverURL, "/") + path,
+		token:     token,
+		wrap:      wrap,
+		client:    &http.Client{Timeout: 15 * time.Second},
+		capacity:  capacity,
+		batchSize: batchSize,
+		logger:    logger.With(zap.String("endpoint", path)),
+	}
+}
+
+func newSystemMetricSender(serverURL, token string, capacity, batchSize int, logger *zap.Logger) *sender[model.SystemMetric] {
+	return newSender(serverURL, token, "/api/v1/metrics/system/batch", func(batch []model.SystemMetric) interface{} {
+		return model.SystemMetricBatchRequest{Metrics: batch}
+	}, capacity, batchSize, logger)
+}
+
+func newGPUMetricSender(serverURL, token string, capacity, batchSize int, logger *zap.Logger) *sender[model.GPUMetric] {
+	return newSender(serverURL, token, "/api/v1/metrics/gpu/batch", func(batch []model.GPUMetric) interface{} {
+		return model.GPUMetricBatchRequest{Metrics: batch}
+	}, capacity, batchSize, logger)
+}
+
+// Add queues samples, evicting the oldest when the buffer is full
+func (s *sender[T]) Add(samples []T) {
+	s.buffer = append(s.buffer, samples...)
+	if overflow := len(s.buffer) - s.capacity; overflow > 0 {
+		s.buffer = append(s.buffer[:0], s.buffer[overflow:]...)
+		s.dropped += overflow
+		s.logger.Warn("Buffer full, dropped oldest samples",
+			zap.Int("dropped", overflow),
+			zap.Int("dropped_total", s.dropped))
+	}
+}
+
+// Buffered returns the number of samples waiting to be sent
+func (s *sender[T]) Buffered() int {
+	return len(s.buffer)
+}
+
+// Flush uploads buffered samples batch by batch until the buffer is empty or
+// an upload fails; it does nothing while backing off
+func (s *sender[T]) Flush(ctx context.Context) {
+	if time.Now().Before(s.nextRetry) {
+		return
+	}
+
+	for len(s.buffer) > 0 {
+		n := s.batchSize
+		if n > len(s.buffer) {
+			n = len(s.buffer)
+		}
+
+		err := s.post(ctx, s.buffer[:n])
+		var permanent *permanentError
+		switch {
+		case err == nil:
+			s.backoff = 0
+		case errors.As(err, &permanent):
+			s.logger.Error("Dropping rejected batch", zap.Int("count", n), zap.Error(err))
+		default:
+			s.scheduleRetry(err)
+			return
+		}
+		s.buffer = s.buffer[n:]
+	}
+}
+
+// FlushAll makes a best-effort attempt to empty the buffer, ignoring backoff
+func (s *sender[T]) FlushAll(ctx context.Context) {
+	s.nextRetry = time.Time{}
+	s.Flush(ctx)
+	if len(s.buffer) > 0 {
+		s.logger.Warn("Exiting with unsent samples", zap.Int("count", len(s.buffer)))
+	}
+}
+
+func (s *sender[T]) scheduleRetry(err error) {
+	if s.backoff == 0 {
+		s.backoff = minRetryBackoff
+	} else if s.backoff < maxRetryBackoff {
+		s.backoff *= 2
+		if s.backoff > maxRetryBackoff {
+			s.backoff = maxRetryBackoff
+		}
+	}
+	s.nextRetry = time.Now().Add(s.backoff)
+
+	s.logger.Warn("Failed to upload metrics, will retry",
+		zap.Error(err),
+		zap.Duration("backoff", s.backoff),
+		zap.Int("buffered", len(s.buffer)))
+}
+
+func (s *sender[T]) post(ctx context.Context, batch []T) error {
+	body, err := json.Marshal(s.wrap(batch))
+	if err != nil {
+		return &permanentError{body: err.Error()}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	// Client errors other than throttling will fail the same way on retry
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{status: resp.StatusCode, body: string(msg)}
+	}
+	return fmt.Errorf("server returned status %d: %s", resp.StatusCode, msg)
+}
@@ -0,0 +1,306 @@
+// Command wanllmdb-admin runs data lifecycle maintenance against the metric
+// service's database, Redis cache and object storage: retention, archiving
+// runs, recomputing summaries, flushing caches and applying the schema.
+//
+// It reads the same environment as the server (TIMESCALE_URL, REDIS_URL,
+// OBJECT_STORAGE_DIR).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/config"
+	"github.com/wanllmdb/metric-service/internal/db"
+	"github.com/wanllmdb/metric-service/internal/repository"
+	"github.com/wanllmdb/metric-service/internal/service"
+	"github.com/wanllmdb/metric-service/internal/storage"
+)
+
+const usage = `Usage: wanllmdb-admin <command> [flags] [args]
+
+Commands:
+  retention             drop hypertable chunks older than each table's retention period
+  archive RUN_ID...     export runs' metrics to object storage, optionally deleting them
+  recompute-summaries   rebuild run summaries from metric history (all runs when none given)
+  flush-cache           delete cached query results from Redis
+  migrate               apply the idempotent database schema
+
+Run "wanllmdb-admin <command> -h" for the flags of a command.
+`
+
+// app holds the configuration shared by the commands. The database is only
+// connected once a command has parsed its flags.
+type app struct {
+	cfg    *config.Config
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func (a *app) connect(ctx context.Context) error {
+	if a.pool != nil {
+		return nil
+	}
+	opts, err := a.cfg.PoolOptions()
+	if err != nil {
+		return fmt.Errorf("invalid database pool configuration: %w", err)
+	}
+	pool, err := db.NewPool(ctx, a.cfg.TimescaleURL, opts)
+	if err != nil {
+		return err
+	}
+	a.pool = pool
+	return nil
+}
+
+// rowLimits returns the configured caps on the rows of repository queries
+func (a *app) rowLimits() repository.RowLimits {
+	return repository.RowLimits{
+		MaxResultRows: a.cfg.DBMaxResultRows,
+		MaxScanRows:   a.cfg.DBMaxScanRows,
+		MaxStreamRows: a.cfg.DBMaxStreamRows,
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	commands := map[string]func(context.Context, *app, []string) error{
+		"retention":           runRetention,
+		"archive":             runArchive,
+		"recompute-summaries": runRecomputeSummaries,
+		"flush-cache":         runFlushCache,
+		"migrate":             runMigrate,
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		fmt.Printf("Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a := &app{cfg: cfg, logger: logger}
+	err = command(ctx, a, os.Args[2:])
+	if a.pool != nil {
+		a.pool.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func (a *app) maintenance(ctx context.Context) (*service.MaintenanceService, error) {
+	if err := a.connect(ctx); err != nil {
+		return nil, err
+	}
+	store, err := a.objectStore()
+	if err != nil {
+		return nil, err
+	}
+	summaries := service.NewSummaryService(repository.NewSummaryRepository(a.pool, a.logger), a.definitions(), a.logger)
+	return service.NewMaintenanceService(repository.NewMaintenanceRepository(a.pool, a.logger), summaries, store, a.logger), nil
+}
+
+// objectStore opens the configured object store
+func (a *app) objectStore() (storage.ObjectStore, error) {
+	if a.cfg.ObjectStorageBackend != "s3" {
+		return storage.NewLocalStore(a.cfg.ObjectStorageDir)
+	}
+	return storage.NewS3Store(storage.S3Options{
+		Endpoint:             a.cfg.S3Endpoint,
+		Region:               a.cfg.S3Region,
+		Bucket:               a.cfg.S3Bucket,
+		AccessKeyID:          a.cfg.S3AccessKeyID,
+		SecretAccessKey:      a.cfg.S3SecretAccessKey,
+		SessionToken:         a.cfg.S3SessionToken,
+		PathStyle:            a.cfg.S3PathStyle,
+		ServerSideEncryption: a.cfg.S3ServerSideEncryption,
+		KMSKeyID:             a.cfg.S3KMSKeyID,
+		PartSize:             int64(a.cfg.S3PartSize),
+		Timeout:              a.cfg.S3Timeout,
+	})
+}
+
+func (a *app) definitions() *service.DefinitionService {
+	definitions := service.NewDefinitionService(repository.NewDefinitionRepository(a.pool, a.logger), a.logger)
+	if err := definitions.Refresh(context.Background()); err != nil {
+		a.logger.Warn("Failed to load metric definitions; goals fall back to name hints", zap.Error(err))
+	}
+	return definitions
+}
+
+func runRetention(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("retention", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "list the chunks that would be dropped without dropping them")
+	defaults := map[string]time.Duration{
+		"metrics":        90 * 24 * time.Hour,
+		"system_metrics": 30 * 24 * time.Hour,
+		"gpu_metrics":    30 * 24 * time.Hour,
+	}
+	periods := make(map[string]*time.Duration, len(repository.RetentionTables))
+	for _, table := range repository.RetentionTables {
+		periods[table] = fs.Duration(table, defaults[table], "retention period of "+table+" (0 keeps everything)")
+	}
+	fs.Parse(args)
+
+	maintenance, err := a.maintenance(ctx)
+	if err != nil {
+		return err
+	}
+
+	resolved := make(map[string]time.Duration, len(periods))
+	for table, period := range periods {
+		resolved[table] = *period
+	}
+	results, err := maintenance.EnforceRetention(ctx, resolved, *dryRun)
+	for _, r := range results {
+		verb := "dropped"
+		if r.DryRun {
+			verb = "would drop"
+		}
+		fmt.Printf("%s: %s %d chunks older than %s\n", r.Table, verb, len(r.Chunks), r.OlderThan)
+		for _, chunk := range r.Chunks {
+			fmt.Printf("  %s\n", chunk)
+		}
+	}
+	return err
+}
+
+func runArchive(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("archive", flag.ExitOnError)
+	deleteAfter := fs.Bool("delete", false, "delete the runs' metrics from the database once archived")
+	fs.Parse(args)
+
+	runIDs, err := parseRunIDs(fs.Args())
+	if err != nil {
+		return err
+	}
+	if len(runIDs) == 0 {
+		return fmt.Errorf("at least one run ID is required")
+	}
+
+	maintenance, err := a.maintenance(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, runID := range runIDs {
+		archive, err := maintenance.ArchiveRun(ctx, runID, *deleteAfter)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: archived %d metrics to %s", runID, archive.MetricCount, archive.Key)
+		if *deleteAfter {
+			fmt.Printf(", deleted %d rows", archive.DeletedRows)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func runRecomputeSummaries(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("recompute-summaries", flag.ExitOnError)
+	fs.Parse(args)
+
+	runIDs, err := parseRunIDs(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	maintenance, err := a.maintenance(ctx)
+	if err != nil {
+		return err
+	}
+
+	count, err := maintenance.RecomputeSummaries(ctx, runIDs)
+	fmt.Printf("recomputed summaries of %d runs\n", count)
+	return err
+}
+
+func runFlushCache(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("flush-cache", flag.ExitOnError)
+	run := fs.String("run", "", "only flush the cached results of this run")
+	fs.Parse(args)
+
+	var runID *uuid.UUID
+	if *run != "" {
+		id, err := uuid.Parse(*run)
+		if err != nil {
+			return fmt.Errorf("invalid run ID %q", *run)
+		}
+		runID = &id
+	}
+
+	if err := a.connect(ctx); err != nil {
+		return err
+	}
+	redisClient := db.NewRedisClient(a.cfg.RedisURL)
+	defer redisClient.Close()
+
+	metrics := service.NewMetricService(repository.NewMetricRepository(a.pool, a.rowLimits(), a.logger), a.definitions(), redisClient, a.logger)
+	deleted, err := metrics.FlushCache(ctx, runID)
+	fmt.Printf("deleted %d cache keys\n", deleted)
+	return err
+}
+
+func runMigrate(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	file := fs.String("file", "scripts/init-timescaledb.sql", "schema script to apply")
+	fs.Parse(args)
+
+	script, err := os.ReadFile(*file)
+	if err != nil {
+		return fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	maintenance, err := a.maintenance(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := maintenance.ApplySchema(ctx, string(script)); err != nil {
+		return err
+	}
+	fmt.Printf("applied %s\n", *file)
+	return nil
+}
+
+func parseRunIDs(args []string) ([]uuid.UUID, error) {
+	runIDs := make([]uuid.UUID, 0, len(args))
+	for _, arg := range args {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid run ID %q", arg)
+		}
+		runIDs = append(runIDs, id)
+	}
+	return runIDs, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// maxPageSize is the largest limit the metric query endpoints accept
+const maxPageSize = 10000
+
+// client calls the metric service's REST API
+type client struct {
+	baseURL string
+	http    *http.Client
+}
+
+func newClient(serverURL string) *client {
+	return &client{
+		baseURL: strings.TrimRight(serverURL, "/"),
+		http:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// get fetches an API path and decodes its JSON response into out
+func (c *client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	u := c.baseURL + "/api/v1" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("server returned status %d: %s", resp.StatusCode, apiErr.Error)
+		}
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, body)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// metricFilter selects the metrics of a run to fetch
+type metricFilter struct {
+	metricName string
+	startTime  string
+	endTime    string
+	minStep    int64
+	maxStep    int64
+}
+
+func (f metricFilter) query() url.Values {
+	query := url.Values{}
+	if f.metricName != "" {
+		query.Set("metric_name", f.metricName)
+	}
+	if f.startTime != "" {
+		query.Set("start_time", f.startTime)
+	}
+	if f.endTime != "" {
+		query.Set("end_time", f.endTime)
+	}
+	if f.minStep >= 0 {
+		query.Set("min_step", strconv.FormatInt(f.minStep, 10))
+	}
+	if f.maxStep >= 0 {
+		query.Set("max_step", strconv.FormatInt(f.maxStep, 10))
+	}
+	return query
+}
+
+// metricsPage is a page of a run's metrics, newest first, with the cursor of
+// the next page; NextCursor is nil on the last page
+type metricsPage struct {
+	Metrics    []model.Metric `json:"metrics"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+// getMetrics fetches up to limit of the newest metrics matching the filter,
+// newest first, resuming after cursor when set
+func (c *client) getMetrics(ctx context.Context, runID uuid.UUID, f metricFilter, limit int, cursor string) (*metricsPage, error) {
+	// metric_name is a query parameter rather than a path segment so that
+	// names containing slashes, such as train/loss, are matched whole
+	path := "/runs/" + runID.String() + "/metrics"
+	query := f.query()
+	query.Set("limit", strconv.Itoa(limit))
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	var page metricsPage
+	if err := c.get(ctx, path, query, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// getAllMetrics pages through every metric matching the filter, following
+// the next_cursor of each page, and returns them oldest first
+func (c *client) getAllMetrics(ctx context.Context, runID uuid.UUID, f metricFilter, pageSize int, progress func(int)) ([]model.Metric, error) {
+	var all []model.Metric
+	cursor := ""
+	for {
+		page, err := c.getMetrics(ctx, runID, f, pageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page.Metrics...)
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
+		if progress != nil {
+			progress(len(all))
+		}
+	}
+
+	reverse(all)
+	return all, nil
+}
+
+func reverse(metrics []model.Metric) {
+	for i, j := 0, len(metrics)-1; i < j; i, j = i+1, j-1 {
+		metrics[i], metrics[j] = metrics[j], metrics[i]
+	}
+}
+
+// wsURL turns the server URL into the WebSocket URL of a run's metric stream
+func (c *client) wsURL(runID uuid.UUID) (string, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL: %w", err)
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/ws/metrics/" + runID.String()
+	return u.String(), nil
+}
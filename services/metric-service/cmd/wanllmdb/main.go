@@ -0,0 +1,413 @@
+// Command wanllmdb queries the metric service from the terminal: it lists
+// runs, prints a metric's history, follows metrics live over the WebSocket
+// stream and exports a run's metrics to CSV, JSON lines or Parquet.
+//
+// The server defaults to METRIC_SERVICE_URL, or http://localhost:8001.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+const usage = `Usage: wanllmdb <command> [flags] [args]
+
+Commands:
+  runs                  list the runs of a project or the runs matching tags
+  history RUN_ID        print the history of a run's metrics
+  watch RUN_ID          follow a run's metrics as they are written
+  export RUN_ID         write every metric of a run to CSV, JSON lines or Parquet
+
+Run "wanllmdb <command> -h" for the flags of a command.
+`
+
+// stringList collects a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	commands := map[string]func(context.Context, []string) error{
+		"runs":    runRuns,
+		"history": runHistory,
+		"watch":   runWatch,
+		"export":  runExport,
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := command(ctx, os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func serverFlag(fs *flag.FlagSet) *string {
+	return fs.String("server", envOr("METRIC_SERVICE_URL", "http://localhost:8001"), "metric service base URL")
+}
+
+// filterFlags registers the flags that select a run's metrics
+func filterFlags(fs *flag.FlagSet, f *metricFilter) {
+	fs.StringVar(&f.startTime, "start-time", "", "only metrics at or after this RFC 3339 time")
+	fs.StringVar(&f.endTime, "end-time", "", "only metrics at or before this RFC 3339 time")
+	fs.Int64Var(&f.minStep, "min-step", -1, "only metrics at or after this step")
+	fs.Int64Var(&f.maxStep, "max-step", -1, "only metrics at or before this step")
+}
+
+func (f metricFilter) validate() error {
+	for _, t := range []string{f.startTime, f.endTime} {
+		if t == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339Nano, t); err != nil {
+			return fmt.Errorf("invalid time %q: expected RFC 3339, e.g. 2024-01-02T15:04:05Z", t)
+		}
+	}
+	return nil
+}
+
+// parseRunArg parses the leading run ID argument and the flags after it
+func parseRunArg(fs *flag.FlagSet, args []string) (uuid.UUID, error) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		fs.Parse(args[1:])
+		runID, err := uuid.Parse(args[0])
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("invalid run ID %q", args[0])
+		}
+		return runID, nil
+	}
+
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return uuid.Nil, fmt.Errorf("a run ID is required")
+	}
+	runID, err := uuid.Parse(fs.Arg(0))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid run ID %q", fs.Arg(0))
+	}
+	return runID, nil
+}
+
+func runRuns(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("runs", flag.ExitOnError)
+	server := serverFlag(fs)
+	project := fs.String("project", "", "list the runs of this project")
+	experiment := fs.String("experiment", "", "with -project, only runs of this experiment")
+	activeWithin := fs.Duration("active-within", 0, "with -project, only runs that reported within this duration")
+	metric := fs.String("metric", "", "with -project, metric whose latest value is shown (default loss)")
+	var tags stringList
+	fs.Var(&tags, "tag", "only runs with this tag, as key or key:value (repeatable)")
+	limit := fs.Int("limit", 100, "maximum number of runs")
+	format := fs.String("format", "table", "output format: table or json")
+	fs.Parse(args)
+
+	if (*project == "") == (len(tags) == 0) {
+		return fmt.Errorf("exactly one of -project or -tag is required")
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	c := newClient(*server)
+	query := url.Values{"limit": {strconv.Itoa(*limit)}}
+
+	if len(tags) > 0 {
+		query["tag"] = tags
+		var resp struct {
+			Runs []model.TaggedRun `json:"runs"`
+		}
+		if err := c.get(ctx, "/runs", query, &resp); err != nil {
+			return err
+		}
+		if *format == "json" {
+			return printJSON(resp.Runs)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "RUN_ID\tTAGS")
+		for _, run := range resp.Runs {
+			pairs := make([]string, 0, len(run.Tags))
+			for k, v := range run.Tags {
+				pairs = append(pairs, k+"="+v)
+			}
+			sort.Strings(pairs)
+			fmt.Fprintf(tw, "%s\t%s\n", run.RunID, strings.Join(pairs, " "))
+		}
+		return tw.Flush()
+	}
+
+	if *experiment != "" {
+		query["experiment_id"] = []string{*experiment}
+	}
+	if *activeWithin > 0 {
+		query["active_within"] = []string{activeWithin.String()}
+	}
+	if *metric != "" {
+		query["metric_name"] = []string{*metric}
+	}
+	var resp struct {
+		Runs []model.ProjectRun `json:"runs"`
+	}
+	if err := c.get(ctx, "/projects/"+*project+"/runs", query, &resp); err != nil {
+		return err
+	}
+	if *format == "json" {
+		return printJSON(resp.Runs)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RUN_ID\tEXPERIMENT\tLAST_SEEN\tMETRIC\tSTEP\tLATEST")
+	for _, run := range resp.Runs {
+		experimentID := "-"
+		if run.ExperimentID != nil {
+			experimentID = run.ExperimentID.String()
+		}
+		latest := "-"
+		if run.LatestValue != nil {
+			latest = fmt.Sprintf("%.6g", *run.LatestValue)
+		}
+		step := optionalInt(run.LatestStep)
+		if step == "" {
+			step = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			run.RunID, experimentID, run.LastSeenAt.Local().Format("2006-01-02 15:04:05"), run.MetricName, step, latest)
+	}
+	return tw.Flush()
+}
+
+func runHistory(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	server := serverFlag(fs)
+	var filter metricFilter
+	fs.StringVar(&filter.metricName, "metric", "", "only this metric (default every metric of the run)")
+	filterFlags(fs, &filter)
+	limit := fs.Int("limit", 1000, "print at most this many of the newest values (at most 10000)")
+	format := fs.String("format", "table", "output format: table, csv, json or jsonl")
+	runID, err := parseRunArg(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if err := filter.validate(); err != nil {
+		return err
+	}
+	if *limit < 1 || *limit > maxPageSize {
+		return fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+	if *format == "parquet" {
+		return fmt.Errorf("use export for parquet output")
+	}
+	out, err := newMetricWriter(*format, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	page, err := newClient(*server).getMetrics(ctx, runID, filter, *limit, "")
+	if err != nil {
+		return err
+	}
+	reverse(page.Metrics)
+
+	for _, m := range page.Metrics {
+		if err := out.Write(m); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
+
+func runWatch(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	server := serverFlag(fs)
+	var metrics stringList
+	fs.Var(&metrics, "metric", "only this metric (repeatable; default every metric)")
+	format := fs.String("format", "table", "output format: table or jsonl")
+	runID, err := parseRunArg(fs, args)
+	if err != nil {
+		return err
+	}
+	if *format != "table" && *format != "jsonl" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	wsURL, err := newClient(*server).wsURL(runID)
+	if err != nil {
+		return err
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", wsURL, err)
+	}
+	defer conn.Close()
+
+	if len(metrics) > 0 {
+		subscribe := model.WebSocketMessage{
+			Type:    "subscribe",
+			Payload: model.SubscribePayload{RunID: runID, MetricNames: metrics},
+		}
+		if err := conn.WriteJSON(subscribe); err != nil {
+			return fmt.Errorf("failed to subscribe: %w", err)
+		}
+	}
+
+	// Closing the connection unblocks the read loop on interrupt
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "watching run %s, press Ctrl-C to stop\n", runID)
+	enc := json.NewEncoder(os.Stdout)
+
+	for {
+		var msg struct {
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("connection closed: %w", err)
+		}
+
+		switch msg.Type {
+		case "metric":
+			var payload model.MetricPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				continue
+			}
+			for _, m := range payload.Metrics {
+				if *format == "jsonl" {
+					enc.Encode(m)
+					continue
+				}
+				// Values are printed as they arrive, so columns are padded rather than aligned
+				fmt.Printf("%s  %-32s step=%-8s %s\n",
+					m.Time.Local().Format("2006-01-02 15:04:05.000"), m.MetricName, optionalInt(m.Step),
+					formatValue(m, 8))
+			}
+		case "annotation":
+			var payload model.AnnotationPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				continue
+			}
+			for _, a := range payload.Annotations {
+				fmt.Fprintf(os.Stderr, "# %s annotation at step %s: %s\n",
+					a.Time.Local().Format("2006-01-02 15:04:05"), optionalInt(a.Step), a.Text)
+			}
+		}
+	}
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	server := serverFlag(fs)
+	var filter metricFilter
+	fs.StringVar(&filter.metricName, "metric", "", "only this metric (default every metric of the run)")
+	filterFlags(fs, &filter)
+	format := fs.String("format", "csv", "output format: csv, jsonl or parquet")
+	output := fs.String("o", "", "output file (default standard output)")
+	pageSize := fs.Int("page-size", maxPageSize, "metrics fetched per request")
+	runID, err := parseRunArg(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if err := filter.validate(); err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "jsonl" && *format != "parquet" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if *pageSize < 1 || *pageSize > maxPageSize {
+		return fmt.Errorf("page size must be between 1 and %d", maxPageSize)
+	}
+
+	progress := func(n int) {
+		fmt.Fprintf(os.Stderr, "\rfetched %d metrics", n)
+	}
+	metrics, err := newClient(*server).getAllMetrics(ctx, runID, filter, *pageSize, progress)
+	if err != nil {
+		return err
+	}
+
+	// The file is only created once every page was fetched, so a failed export leaves nothing behind
+	dest := os.Stdout
+	if *output != "" {
+		dest, err = os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer dest.Close()
+	}
+
+	out, err := newMetricWriter(*format, dest)
+	if err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		if err := out.Write(m); err != nil {
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if *output != "" {
+		if err := dest.Close(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "\rexported %d metrics\n", len(metrics))
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func envOr(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/parquet"
+)
+
+// metricWriter writes metrics one at a time in an output format
+type metricWriter interface {
+	Write(m model.Metric) error
+	Close() error
+}
+
+func newMetricWriter(format string, w io.Writer) (metricWriter, error) {
+	switch format {
+	case "table":
+		return newTableWriter(w), nil
+	case "csv":
+		return newCSVWriter(w), nil
+	case "jsonl":
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	case "json":
+		return &jsonWriter{w: w, metrics: []model.Metric{}}, nil
+	case "parquet":
+		return parquet.NewWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+var metricColumns = []string{"time", "run_id", "metric_name", "step", "value", "node_id", "rank"}
+
+func metricRecord(m model.Metric) []string {
+	return []string{
+		m.Time.Format(time.RFC3339Nano),
+		m.RunID.String(),
+		m.MetricName,
+		optionalInt(m.Step),
+		formatValue(m, -1),
+		m.NodeID,
+		optionalInt(m.Rank),
+	}
+}
+
+// formatValue formats a metric value with prec significant digits, or
+// returns the text of a string or bool value
+func formatValue(m model.Metric, prec int) string {
+	if !m.IsNumeric() && m.Text != nil {
+		return *m.Text
+	}
+	return strconv.FormatFloat(m.Value, 'g', prec, 64)
+}
+
+func optionalInt[T int | int64](v *T) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(int64(*v), 10)
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	cw.w.Write(metricColumns)
+	return cw
+}
+
+func (cw *csvWriter) Write(m model.Metric) error {
+	return cw.w.Write(metricRecord(m))
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// tableWriter aligns metrics into columns for the terminal, leaving out the
+// run ID every row shares
+type tableWriter struct {
+	w *tabwriter.Writer
+}
+
+func newTableWriter(w io.Writer) *tableWriter {
+	tw := &tableWriter{w: tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)}
+	fmt.Fprintln(tw.w, "TIME\tMETRIC\tSTEP\tVALUE\tNODE\tRANK")
+	return tw
+}
+
+func (tw *tableWriter) Write(m model.Metric) error {
+	_, err := fmt.Fprintf(tw.w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+		m.Time.Local().Format("2006-01-02 15:04:05.000"),
+		m.MetricName,
+		optionalInt(m.Step),
+		formatValue(m, 8),
+		m.NodeID,
+		optionalInt(m.Rank),
+	)
+	return err
+}
+
+func (tw *tableWriter) Close() error {
+	return tw.w.Flush()
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (jw *jsonlWriter) Write(m model.Metric) error {
+	return jw.enc.Encode(m)
+}
+
+func (jw *jsonlWriter) Close() error {
+	return nil
+}
+
+// jsonWriter writes the metrics as one JSON array once closed
+type jsonWriter struct {
+	w       io.Writer
+	metrics []model.Metric
+}
+
+func (jw *jsonWriter) Write(m model.Metric) error {
+	jw.metrics = append(jw.metrics, m)
+	return nil
+}
+
+func (jw *jsonWriter) Close() error {
+	enc := json.NewEncoder(jw.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jw.metrics)
+}
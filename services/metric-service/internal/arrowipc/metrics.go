@@ -0,0 +1,60 @@
+package arrowipc
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// Columns of the tables of metric histories. Strings and bools are in text,
+// with a null value.
+const (
+	metricTime = iota
+	metricStep
+	metricValue
+	metricText
+	metricNode
+	metricRank
+)
+
+// MetricSchema is the schema of the history of a run's metric, which names
+// them in its metadata
+func MetricSchema(runID uuid.UUID, metricName string) Schema {
+	return Schema{
+		Fields: []Field{
+			{Name: "time", Type: Timestamp},
+			{Name: "step", Type: Int64},
+			{Name: "value", Type: Float64},
+			{Name: "text", Type: String},
+			{Name: "node_id", Type: String},
+			{Name: "rank", Type: Int32},
+		},
+		Metadata: map[string]string{"run_id": runID.String(), "metric_name": metricName},
+	}
+}
+
+// AppendMetric appends m as a row to a batch of MetricSchema
+func AppendMetric(b *Batch, m model.Metric) {
+	b.Column(metricTime).AppendTime(m.Time)
+	if m.Step != nil {
+		b.Column(metricStep).AppendInt64(*m.Step)
+	} else {
+		b.Column(metricStep).AppendNull()
+	}
+	if m.IsNumeric() {
+		b.Column(metricValue).AppendFloat64(m.Value)
+	} else {
+		b.Column(metricValue).AppendNull()
+	}
+	if m.Text != nil {
+		b.Column(metricText).AppendString(*m.Text)
+	} else {
+		b.Column(metricText).AppendNull()
+	}
+	b.Column(metricNode).AppendString(m.NodeID)
+	if m.Rank != nil {
+		b.Column(metricRank).AppendInt32(int32(*m.Rank))
+	} else {
+		b.Column(metricRank).AppendNull()
+	}
+}
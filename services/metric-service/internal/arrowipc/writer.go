@@ -0,0 +1,373 @@
+// Package arrowipc writes the Arrow IPC streaming format, so that notebooks
+// load query results into pandas or polars without parsing JSON. It covers
+// the flat tables the service returns: 32 and 64-bit integers, doubles, UTF-8
+// strings and UTC timestamps, all nullable, without dictionaries or
+// compression.
+package arrowipc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// ContentType is the media type of an Arrow IPC stream
+const ContentType = "application/vnd.apache.arrow.stream"
+
+// Type is the type of a column
+type Type int
+
+const (
+	Int32 Type = iota
+	Int64
+	Float64
+	String
+	Timestamp // microseconds since the epoch, in UTC
+)
+
+// Field is a column of a schema
+type Field struct {
+	Name string
+	Type Type
+}
+
+// Schema is the columns of the record batches of a stream, and metadata
+// readers see as the schema's key-value metadata
+type Schema struct {
+	Fields   []Field
+	Metadata map[string]string
+}
+
+// Values of the Arrow format's flatbuffers (Schema.fbs, Message.fbs)
+const (
+	metadataV5        = 4
+	headerSchema      = 1
+	headerRecordBatch = 3
+	typeInt           = 2
+	typeFloatingPoint = 3
+	typeUtf8          = 5
+	typeTimestamp     = 10
+	precisionDouble   = 2
+	unitMicrosecond   = 2
+)
+
+// Writer writes a stream of record batches of one schema
+type Writer struct {
+	w      io.Writer
+	emit   func(metadata, body []byte) error
+	schema Schema
+	err    error
+	began  bool
+}
+
+// NewWriter returns a writer of a stream; the schema is written with the
+// first batch, or on Close for a stream without batches
+func NewWriter(w io.Writer, schema Schema) *Writer {
+	return &Writer{w: w, schema: schema}
+}
+
+// NewMessageWriter returns a writer passing each message to emit as its
+// flatbuffer metadata and its body, unframed, as Arrow Flight carries them.
+// Close emits the schema of a stream without batches, and no end marker.
+func NewMessageWriter(emit func(metadata, body []byte) error, schema Schema) *Writer {
+	return &Writer{emit: emit, schema: schema}
+}
+
+// NewBatch returns an empty batch of the writer's schema
+func (w *Writer) NewBatch() *Batch {
+	b := &Batch{columns: make([]*Column, len(w.schema.Fields))}
+	for i, f := range w.schema.Fields {
+		b.columns[i] = &Column{typ: f.Type}
+	}
+	return b
+}
+
+// Write writes a batch, whose columns must all hold the same number of values
+func (w *Writer) Write(b *Batch) error {
+	if err := w.begin(); err != nil {
+		return err
+	}
+	rows := b.Rows()
+	for i, c := range b.columns {
+		if c.length != rows {
+			return fmt.Errorf("column %s holds %d values, want %d", w.schema.Fields[i].Name, c.length, rows)
+		}
+	}
+
+	var body []byte
+	var buffers [][2]int64
+	addBuffer := func(data []byte) {
+		buffers = append(buffers, [2]int64{int64(len(body)), int64(len(data))})
+		body = append(body, data...)
+		body = append(body, make([]byte, pad8(len(data)))...)
+	}
+	for _, c := range b.columns {
+		if c.nulls > 0 {
+			addBuffer(c.validity)
+		} else {
+			addBuffer(nil)
+		}
+		if c.typ == String {
+			if c.length == 0 {
+				c.appendOffset()
+			}
+			addBuffer(c.offsets)
+		}
+		addBuffer(c.data)
+	}
+
+	fb := flatbuffers.NewBuilder(256)
+	fb.StartVector(16, len(buffers), 8)
+	for i := len(buffers) - 1; i >= 0; i-- {
+		fb.Prep(8, 16)
+		fb.PrependInt64(buffers[i][1])
+		fb.PrependInt64(buffers[i][0])
+	}
+	buffersVec := fb.EndVector(len(buffers))
+	fb.StartVector(16, len(b.columns), 8)
+	for i := len(b.columns) - 1; i >= 0; i-- {
+		fb.Prep(8, 16)
+		fb.PrependInt64(int64(b.columns[i].nulls))
+		fb.PrependInt64(int64(b.columns[i].length))
+	}
+	nodesVec := fb.EndVector(len(b.columns))
+	fb.StartObject(5)
+	fb.PrependInt64Slot(0, int64(rows), 0)
+	fb.PrependUOffsetTSlot(1, nodesVec, 0)
+	fb.PrependUOffsetTSlot(2, buffersVec, 0)
+	header := fb.EndObject()
+
+	return w.writeMessage(fb, headerRecordBatch, header, body)
+}
+
+// Close ends the stream. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.begin(); err != nil || w.emit != nil {
+		return err
+	}
+	_, err := w.w.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	return err
+}
+
+func (w *Writer) begin() error {
+	if w.began || w.err != nil {
+		return w.err
+	}
+	w.began = true
+
+	fb := flatbuffers.NewBuilder(512)
+	fields := make([]flatbuffers.UOffsetT, len(w.schema.Fields))
+	for i, f := range w.schema.Fields {
+		fields[i] = buildField(fb, f)
+	}
+	fieldsVec := fb.CreateVectorOfTables(fields)
+	metadataVec := buildMetadata(fb, w.schema.Metadata)
+	fb.StartObject(4)
+	fb.PrependUOffsetTSlot(1, fieldsVec, 0)
+	if metadataVec != 0 {
+		fb.PrependUOffsetTSlot(2, metadataVec, 0)
+	}
+	header := fb.EndObject()
+
+	return w.writeMessage(fb, headerSchema, header, nil)
+}
+
+// writeMessage finishes a message with header and writes it, followed by
+// body: an encapsulated message is the continuation marker, the length of
+// the metadata padded to 8 bytes, the metadata and the body
+func (w *Writer) writeMessage(fb *flatbuffers.Builder, headerType byte, header flatbuffers.UOffsetT, body []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	fb.StartObject(5)
+	fb.PrependInt64Slot(3, int64(len(body)), 0)
+	fb.PrependUOffsetTSlot(2, header, 0)
+	fb.PrependInt16Slot(0, metadataV5, 0)
+	fb.PrependByteSlot(1, headerType, 0)
+	fb.Finish(fb.EndObject())
+	metadata := fb.FinishedBytes()
+	if w.emit != nil {
+		w.err = w.emit(metadata, body)
+		return w.err
+	}
+
+	size := len(metadata) + pad8(len(metadata))
+	buf := make([]byte, 8, 8+size+len(body))
+	binary.LittleEndian.PutUint32(buf, 0xffffffff)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(size))
+	buf = append(buf, metadata...)
+	buf = append(buf, make([]byte, size-len(metadata))...)
+	buf = append(buf, body...)
+	_, w.err = w.w.Write(buf)
+	return w.err
+}
+
+func buildField(fb *flatbuffers.Builder, f Field) flatbuffers.UOffsetT {
+	name := fb.CreateString(f.Name)
+	var typeType byte
+	var typ flatbuffers.UOffsetT
+	switch f.Type {
+	case Int32, Int64:
+		bits := int32(64)
+		if f.Type == Int32 {
+			bits = 32
+		}
+		fb.StartObject(2)
+		fb.PrependInt32Slot(0, bits, 0)
+		fb.PrependBoolSlot(1, true, false)
+		typeType, typ = typeInt, fb.EndObject()
+	case Float64:
+		fb.StartObject(1)
+		fb.PrependInt16Slot(0, precisionDouble, 0)
+		typeType, typ = typeFloatingPoint, fb.EndObject()
+	case String:
+		fb.StartObject(0)
+		typeType, typ = typeUtf8, fb.EndObject()
+	case Timestamp:
+		tz := fb.CreateString("UTC")
+		fb.StartObject(2)
+		fb.PrependInt16Slot(0, unitMicrosecond, 0)
+		fb.PrependUOffsetTSlot(1, tz, 0)
+		typeType, typ = typeTimestamp, fb.EndObject()
+	default:
+		panic(fmt.Sprintf("arrowipc: unknown type %d", f.Type))
+	}
+	children := fb.CreateVectorOfTables(nil)
+
+	fb.StartObject(7)
+	fb.PrependUOffsetTSlot(0, name, 0)
+	fb.PrependBoolSlot(1, true, false)
+	fb.PrependByteSlot(2, typeType, 0)
+	fb.PrependUOffsetTSlot(3, typ, 0)
+	fb.PrependUOffsetTSlot(5, children, 0)
+	return fb.EndObject()
+}
+
+// buildMetadata builds a vector of KeyValue tables, or returns 0 for none
+func buildMetadata(fb *flatbuffers.Builder, metadata map[string]string) flatbuffers.UOffsetT {
+	if len(metadata) == 0 {
+		return 0
+	}
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	pairs := make([]flatbuffers.UOffsetT, len(keys))
+	for i, k := range keys {
+		key, value := fb.CreateString(k), fb.CreateString(metadata[k])
+		fb.StartObject(2)
+		fb.PrependUOffsetTSlot(0, key, 0)
+		fb.PrependUOffsetTSlot(1, value, 0)
+		pairs[i] = fb.EndObject()
+	}
+	return fb.CreateVectorOfTables(pairs)
+}
+
+// Batch is a record batch being built, column by column
+type Batch struct {
+	columns []*Column
+}
+
+// Column returns the i-th column of the batch
+func (b *Batch) Column(i int) *Column {
+	return b.columns[i]
+}
+
+// Rows is the number of values of the batch's first column
+func (b *Batch) Rows() int {
+	if len(b.columns) == 0 {
+		return 0
+	}
+	return b.columns[0].length
+}
+
+// Column accumulates the values of a column. Appending a value of another
+// type than the column's panics.
+type Column struct {
+	typ      Type
+	length   int
+	nulls    int
+	validity []byte
+	offsets  []byte // of strings, the int32 start of each value and the end
+	data     []byte
+}
+
+func (c *Column) AppendInt32(v int32) {
+	c.check(Int32)
+	c.data = binary.LittleEndian.AppendUint32(c.data, uint32(v))
+	c.appendValid(true)
+}
+
+func (c *Column) AppendInt64(v int64) {
+	c.check(Int64)
+	c.data = binary.LittleEndian.AppendUint64(c.data, uint64(v))
+	c.appendValid(true)
+}
+
+func (c *Column) AppendFloat64(v float64) {
+	c.check(Float64)
+	c.data = binary.LittleEndian.AppendUint64(c.data, math.Float64bits(v))
+	c.appendValid(true)
+}
+
+func (c *Column) AppendString(v string) {
+	c.check(String)
+	c.data = append(c.data, v...)
+	c.appendOffset()
+	c.appendValid(true)
+}
+
+func (c *Column) AppendTime(v time.Time) {
+	c.check(Timestamp)
+	c.data = binary.LittleEndian.AppendUint64(c.data, uint64(v.UnixMicro()))
+	c.appendValid(true)
+}
+
+// AppendNull appends a null, which takes a zero slot in fixed-width columns
+func (c *Column) AppendNull() {
+	switch c.typ {
+	case Int32:
+		c.data = append(c.data, 0, 0, 0, 0)
+	case String:
+		c.appendOffset()
+	default:
+		c.data = append(c.data, 0, 0, 0, 0, 0, 0, 0, 0)
+	}
+	c.nulls++
+	c.appendValid(false)
+}
+
+func (c *Column) check(t Type) {
+	if c.typ != t {
+		panic(fmt.Sprintf("arrowipc: appending a value of type %d to a column of type %d", t, c.typ))
+	}
+}
+
+func (c *Column) appendOffset() {
+	if len(c.offsets) == 0 {
+		c.offsets = binary.LittleEndian.AppendUint32(c.offsets, 0)
+	}
+	c.offsets = binary.LittleEndian.AppendUint32(c.offsets, uint32(len(c.data)))
+}
+
+// appendValid sets the validity bit of the next value; bits are LSB first
+func (c *Column) appendValid(valid bool) {
+	if c.length%8 == 0 {
+		c.validity = append(c.validity, 0)
+	}
+	if valid {
+		c.validity[c.length/8] |= 1 << (c.length % 8)
+	}
+	c.length++
+}
+
+// pad8 is the padding that aligns n bytes to 8
+func pad8(n int) int {
+	return (8 - n%8) % 8
+}
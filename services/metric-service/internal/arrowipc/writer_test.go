@@ -0,0 +1,298 @@
+package arrowipc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// message is an encapsulated message read back from a stream
+type message struct {
+	table flatbuffers.Table // the Message
+	body  []byte
+}
+
+func readMessages(t *testing.T, stream []byte) []message {
+	t.Helper()
+	var msgs []message
+	for {
+		if len(stream) < 8 || binary.LittleEndian.Uint32(stream) != 0xffffffff {
+			t.Fatalf("missing continuation marker")
+		}
+		size := int(binary.LittleEndian.Uint32(stream[4:]))
+		if size == 0 {
+			if len(stream) != 8 {
+				t.Errorf("%d bytes after the end of the stream", len(stream)-8)
+			}
+			return msgs
+		}
+		if (8+size)%8 != 0 {
+			t.Errorf("metadata of %d bytes is not padded to 8", size)
+		}
+		meta := stream[8 : 8+size]
+		root := flatbuffers.GetUOffsetT(meta)
+		m := message{table: flatbuffers.Table{Bytes: meta, Pos: root}}
+		if version := fieldInt16(m.table, 0); version != metadataV5 {
+			t.Errorf("metadata version = %d, want %d", version, metadataV5)
+		}
+		bodyLength := int(fieldInt64(m.table, 3))
+		m.body = stream[8+size : 8+size+bodyLength]
+		stream = stream[8+size+bodyLength:]
+		msgs = append(msgs, m)
+	}
+}
+
+func field(tab flatbuffers.Table, slot int) flatbuffers.UOffsetT {
+	return flatbuffers.UOffsetT(tab.Offset(flatbuffers.VOffsetT(4 + 2*slot)))
+}
+
+func fieldInt16(tab flatbuffers.Table, slot int) int16 {
+	if o := field(tab, slot); o != 0 {
+		return tab.GetInt16(o + tab.Pos)
+	}
+	return 0
+}
+
+func fieldInt64(tab flatbuffers.Table, slot int) int64 {
+	if o := field(tab, slot); o != 0 {
+		return tab.GetInt64(o + tab.Pos)
+	}
+	return 0
+}
+
+func fieldByte(tab flatbuffers.Table, slot int) byte {
+	if o := field(tab, slot); o != 0 {
+		return tab.GetByte(o + tab.Pos)
+	}
+	return 0
+}
+
+func fieldTable(tab flatbuffers.Table, slot int) flatbuffers.Table {
+	o := field(tab, slot)
+	return flatbuffers.Table{Bytes: tab.Bytes, Pos: tab.Indirect(o + tab.Pos)}
+}
+
+func fieldString(tab flatbuffers.Table, slot int) string {
+	o := field(tab, slot)
+	if o == 0 {
+		return ""
+	}
+	return tab.String(o + tab.Pos)
+}
+
+// vector returns the start and length of a vector field
+func vector(tab flatbuffers.Table, slot int) (flatbuffers.UOffsetT, int) {
+	o := field(tab, slot)
+	if o == 0 {
+		return 0, 0
+	}
+	return tab.Vector(o), tab.VectorLen(o)
+}
+
+func vectorTable(tab flatbuffers.Table, start flatbuffers.UOffsetT, i int) flatbuffers.Table {
+	return flatbuffers.Table{Bytes: tab.Bytes, Pos: tab.Indirect(start + flatbuffers.UOffsetT(4*i))}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, Schema{
+		Fields: []Field{
+			{Name: "time", Type: Timestamp},
+			{Name: "step", Type: Int64},
+			{Name: "value", Type: Float64},
+			{Name: "node_id", Type: String},
+			{Name: "rank", Type: Int32},
+		},
+		Metadata: map[string]string{"run_id": "r1", "metric_name": "loss"},
+	})
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 6000, time.UTC)
+	b := w.NewBatch()
+	for i := 0; i < 10; i++ {
+		b.Column(0).AppendTime(t0.Add(time.Duration(i) * time.Second))
+		if i == 3 {
+			b.Column(1).AppendNull()
+		} else {
+			b.Column(1).AppendInt64(int64(i * 100))
+		}
+		b.Column(2).AppendFloat64(float64(i) / 2)
+		if i%2 == 0 {
+			b.Column(3).AppendString("node-" + string(rune('a'+i)))
+		} else {
+			b.Column(3).AppendNull()
+		}
+		b.Column(4).AppendInt32(int32(i))
+	}
+	if err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := readMessages(t, buf.Bytes())
+	if len(msgs) != 2 {
+		t.Fatalf("%d messages, want a schema and a record batch", len(msgs))
+	}
+
+	// Schema
+	if typ := fieldByte(msgs[0].table, 1); typ != headerSchema {
+		t.Fatalf("first message type = %d, want schema", typ)
+	}
+	schema := fieldTable(msgs[0].table, 2)
+	fields, n := vector(schema, 1)
+	wantTypes := []struct {
+		name     string
+		typeType byte
+	}{{"time", typeTimestamp}, {"step", typeInt}, {"value", typeFloatingPoint}, {"node_id", typeUtf8}, {"rank", typeInt}}
+	if n != len(wantTypes) {
+		t.Fatalf("%d fields, want %d", n, len(wantTypes))
+	}
+	for i, want := range wantTypes {
+		f := vectorTable(schema, fields, i)
+		if name := fieldString(f, 0); name != want.name || fieldByte(f, 1) != 1 || fieldByte(f, 2) != want.typeType {
+			t.Errorf("field %d = %s of type %d, want nullable %s of type %d", i, name, fieldByte(f, 2), want.name, want.typeType)
+		}
+		typ := fieldTable(f, 3)
+		switch want.name {
+		case "time":
+			if unit, tz := fieldInt16(typ, 0), fieldString(typ, 1); unit != unitMicrosecond || tz != "UTC" {
+				t.Errorf("timestamp unit %d in %q, want microseconds in UTC", unit, tz)
+			}
+		case "step", "rank":
+			bits := int32(64)
+			if want.name == "rank" {
+				bits = 32
+			}
+			if o := field(typ, 0); typ.GetInt32(o+typ.Pos) != bits || fieldByte(typ, 1) != 1 {
+				t.Errorf("%s is not a signed %d-bit integer", want.name, bits)
+			}
+		case "value":
+			if fieldInt16(typ, 0) != precisionDouble {
+				t.Errorf("value is not a double")
+			}
+		}
+	}
+	metadata, n := vector(schema, 2)
+	got := make(map[string]string)
+	for i := 0; i < n; i++ {
+		kv := vectorTable(schema, metadata, i)
+		got[fieldString(kv, 0)] = fieldString(kv, 1)
+	}
+	if len(got) != 2 || got["run_id"] != "r1" || got["metric_name"] != "loss" {
+		t.Errorf("schema metadata = %v", got)
+	}
+
+	// Record batch
+	if typ := fieldByte(msgs[1].table, 1); typ != headerRecordBatch {
+		t.Fatalf("second message type = %d, want record batch", typ)
+	}
+	batch := fieldTable(msgs[1].table, 2)
+	if rows := fieldInt64(batch, 0); rows != 10 {
+		t.Errorf("batch length = %d, want 10", rows)
+	}
+	nodes, n := vector(batch, 1)
+	wantNulls := []int64{0, 1, 0, 5, 0}
+	for i := 0; i < n; i++ {
+		pos := nodes + flatbuffers.UOffsetT(16*i)
+		if length, nulls := batch.GetInt64(pos), batch.GetInt64(pos+8); length != 10 || nulls != wantNulls[i] {
+			t.Errorf("node %d = %d values with %d nulls, want 10 with %d", i, length, nulls, wantNulls[i])
+		}
+	}
+	start, n := vector(batch, 2)
+	if n != 11 {
+		t.Fatalf("%d buffers, want 11", n)
+	}
+	buffer := func(i int) []byte {
+		pos := start + flatbuffers.UOffsetT(16*i)
+		offset, length := batch.GetInt64(pos), batch.GetInt64(pos+8)
+		if offset%8 != 0 {
+			t.Errorf("buffer %d at unaligned offset %d", i, offset)
+		}
+		return msgs[1].body[offset : offset+length]
+	}
+
+	if len(buffer(0)) != 0 {
+		t.Errorf("validity of a column without nulls holds %d bytes", len(buffer(0)))
+	}
+	if got := int64(binary.LittleEndian.Uint64(buffer(1)[8:])); got != t0.Add(time.Second).UnixMicro() {
+		t.Errorf("time[1] = %d, want %d", got, t0.Add(time.Second).UnixMicro())
+	}
+	if validity := buffer(2); validity[0] != 0xf7 || validity[1] != 0x03 {
+		t.Errorf("step validity = %x, want f7 03", validity)
+	}
+	if got := int64(binary.LittleEndian.Uint64(buffer(3)[16:])); got != 200 {
+		t.Errorf("step[2] = %d, want 200", got)
+	}
+	if got := math.Float64frombits(binary.LittleEndian.Uint64(buffer(5)[8*9:])); got != 4.5 {
+		t.Errorf("value[9] = %v, want 4.5", got)
+	}
+	offsets, data := buffer(7), buffer(8)
+	if len(offsets) != 4*11 {
+		t.Fatalf("node_id offsets hold %d bytes, want %d", len(offsets), 4*11)
+	}
+	at := func(i int) string {
+		return string(data[binary.LittleEndian.Uint32(offsets[4*i:]):binary.LittleEndian.Uint32(offsets[4*i+4:])])
+	}
+	if at(0) != "node-a" || at(1) != "" || at(8) != "node-i" {
+		t.Errorf("node_id = %q, %q, %q; want node-a, null, node-i", at(0), at(1), at(8))
+	}
+	if got := int32(binary.LittleEndian.Uint32(buffer(10)[4*7:])); got != 7 {
+		t.Errorf("rank[7] = %d, want 7", got)
+	}
+}
+
+func TestWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, Schema{Fields: []Field{{Name: "name", Type: String}}})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := readMessages(t, buf.Bytes()); len(msgs) != 1 || fieldByte(msgs[0].table, 1) != headerSchema {
+		t.Errorf("an empty stream holds %d messages, want the schema only", len(msgs))
+	}
+}
+
+func TestWriterMismatchedColumns(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{}, Schema{Fields: []Field{{Name: "a", Type: Int64}, {Name: "b", Type: Int64}}})
+	b := w.NewBatch()
+	b.Column(0).AppendInt64(1)
+	if err := w.Write(b); err == nil {
+		t.Error("Write() of columns of different lengths succeeded")
+	}
+}
+
+func TestMessageWriter(t *testing.T) {
+	schema := Schema{Fields: []Field{{Name: "step", Type: Int64}}}
+	var stream bytes.Buffer
+	w := NewWriter(&stream, schema)
+	var emitted [][2][]byte
+	mw := NewMessageWriter(func(metadata, body []byte) error {
+		emitted = append(emitted, [2][]byte{bytes.Clone(metadata), bytes.Clone(body)})
+		return nil
+	}, schema)
+	for _, w := range []*Writer{w, mw} {
+		b := w.NewBatch()
+		b.Column(0).AppendInt64(42)
+		if err := w.Write(b); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The same messages, unframed and without the end marker
+	msgs := readMessages(t, stream.Bytes())
+	if len(emitted) != len(msgs) {
+		t.Fatalf("emitted %d messages, want %d", len(emitted), len(msgs))
+	}
+	for i, m := range msgs {
+		if !bytes.Equal(emitted[i][0], m.table.Bytes[:len(emitted[i][0])]) || !bytes.Equal(emitted[i][1], m.body) {
+			t.Errorf("message %d differs from the stream's", i)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package awsv4 signs requests to AWS services with Signature Version 4, for
+// the service's hand-written clients of S3-compatible storage and SNS
+package awsv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DateFormat is the timestamp format of SigV4
+const DateFormat = "20060102T150405Z"
+
+// UnsignedPayload stands in for the hash of request bodies that are streamed
+// rather than read twice, which S3 accepts
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// Signer signs the requests of one service in one region
+type Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Service         string
+}
+
+// Sign sets the date, security token and Authorization headers of req, with
+// payloadHash the hex SHA-256 of its body or UnsignedPayload
+func (s Signer) Sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(DateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	header := req.Header.Clone()
+	header.Set("Host", req.URL.Host)
+	signed := SignedHeaders(header)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, s.Scope(now), strings.Join(signed, ";"),
+		s.Signature(req.Method, req.URL, req.URL.Query(), header, payloadHash, now)))
+}
+
+// Scope is the credential scope of signatures made at now
+func (s Signer) Scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+// Signature signs a request as SigV4 describes: a canonical form of the
+// request, hashed into a string to sign, signed with a key derived from the
+// secret for the day, region and service
+func (s Signer) Signature(method string, u *url.URL, query url.Values, header http.Header, payloadHash string, now time.Time) string {
+	signed := SignedHeaders(header)
+	var headers strings.Builder
+	for _, name := range signed {
+		var values []string
+		for _, v := range header.Values(name) {
+			values = append(values, strings.Join(strings.Fields(v), " "))
+		}
+		headers.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		method,
+		EncodePath(u.Path),
+		CanonicalQuery(query),
+		headers.String(),
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format(DateFormat) + "\n" + s.Scope(now) + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// PayloadHash is the hex SHA-256 of a request body
+func PayloadHash(body []byte) string {
+	hash := sha256.Sum256(body)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// SignedHeaders lists the lowercase names of the headers signed: the host,
+// the content type and the x-amz- headers
+func SignedHeaders(header http.Header) []string {
+	var names []string
+	for name := range header {
+		lower := strings.ToLower(name)
+		if lower == "host" || lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CanonicalQuery encodes query sorted by name, percent-encoding everything
+// but unreserved characters
+func CanonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, URIEncode(name, true)+"="+URIEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// EncodePath percent-encodes a path, keeping its slashes
+func EncodePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return URIEncode(path, false)
+}
+
+// URIEncode percent-encodes all but the unreserved characters of RFC 3986,
+// and slashes unless encodeSlash
+func URIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
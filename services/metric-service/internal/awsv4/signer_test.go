@@ -0,0 +1,58 @@
+package awsv4
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignature signs the GET request of the SigV4 documentation's example
+func TestSignature(t *testing.T) {
+	s := Signer{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "iam",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	u, _ := url.Parse("https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08")
+	header := http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded; charset=utf-8"},
+		"Host":         {"iam.amazonaws.com"},
+		"X-Amz-Date":   {now.Format(DateFormat)},
+	}
+	const want = "5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := s.Signature(http.MethodGet, u, u.Query(), header, PayloadHash(nil), now); got != want {
+		t.Errorf("Signature() = %s, want %s", got, want)
+	}
+	if got := s.Scope(now); got != "20150830/us-east-1/iam/aws4_request" {
+		t.Errorf("Scope() = %s", got)
+	}
+}
+
+func TestSign(t *testing.T) {
+	s := Signer{AccessKeyID: "key", SecretAccessKey: "secret", SessionToken: "token", Region: "eu-west-1", Service: "sns"}
+	req, _ := http.NewRequest(http.MethodPost, "https://sns.eu-west-1.amazonaws.com/", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.Sign(req, PayloadHash(nil), time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/20260102/eu-west-1/sns/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date;x-amz-security-token, Signature=") {
+		t.Errorf("Authorization = %s", auth)
+	}
+	if req.Header.Get("X-Amz-Date") != "20260102T030405Z" || req.Header.Get("X-Amz-Security-Token") != "token" {
+		t.Errorf("headers = %v", req.Header)
+	}
+}
+
+func TestURIEncode(t *testing.T) {
+	if got := URIEncode("a b/c~d+e", false); got != "a%20b/c~d%2Be" {
+		t.Errorf("URIEncode() = %s", got)
+	}
+	if got := CanonicalQuery(url.Values{"b": {"2"}, "a": {"x/y"}}); got != "a=x%2Fy&b=2" {
+		t.Errorf("CanonicalQuery() = %s", got)
+	}
+}
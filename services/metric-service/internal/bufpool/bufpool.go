@@ -0,0 +1,30 @@
+// Package bufpool pools the buffers messages and payloads are encoded into
+package bufpool
+
+import (
+	"bytes"
+	"sync"
+)
+
+// MaxSize keeps the buffers of rare large messages out of the pool, so that
+// one of them does not stay allocated for good
+const MaxSize = 64 << 10
+
+var buffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// Get returns an empty buffer from the pool
+func Get() *bytes.Buffer {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// Put returns a buffer the caller is done with to the pool, unless it grew
+// past MaxSize
+func Put(buf *bytes.Buffer) {
+	if buf.Cap() <= MaxSize {
+		buffers.Put(buf)
+	}
+}
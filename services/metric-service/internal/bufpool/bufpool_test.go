@@ -0,0 +1,24 @@
+package bufpool
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGetReturnsEmptyBuffer(t *testing.T) {
+	buf := Get()
+	buf.WriteString("payload")
+	Put(buf)
+
+	if got := Get(); got.Len() != 0 {
+		t.Fatalf("Get() returned a buffer holding %q", got.String())
+	}
+}
+
+func TestPutDropsLargeBuffers(t *testing.T) {
+	large := bytes.NewBuffer(make([]byte, 0, MaxSize+1))
+	Put(large)
+	if buf := Get(); buf == large {
+		t.Fatal("Get() returned a buffer larger than MaxSize")
+	}
+}
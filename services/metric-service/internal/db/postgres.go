@@ -3,21 +3,96 @@ package db
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewPool(ctx context.Context, connString string) (*pgxpool.Pool, error) {
+// PoolOptions size the connection pool and bound the life of its connections
+type PoolOptions struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	ConnectTimeout    time.Duration
+
+	// QueryExecMode is how queries without a prepared statement are sent;
+	// StatementCacheCapacity bounds the per-connection statement or
+	// description cache of the caching modes
+	QueryExecMode          pgx.QueryExecMode
+	StatementCacheCapacity int
+
+	// Prepare holds statements prepared on every new connection. Each is
+	// prepared under its own text, so queries issued with exactly that text use
+	// it in any mode but the simple protocol, where preparing is skipped.
+	Prepare []string
+}
+
+// queryExecModes maps the names accepted by ParseQueryExecMode, which match
+// pgx's default_query_exec_mode connection string parameter
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// ParseQueryExecMode parses the name of a pgx query exec mode
+func ParseQueryExecMode(name string) (pgx.QueryExecMode, error) {
+	mode, ok := queryExecModes[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown query exec mode %q", name)
+	}
+	return mode, nil
+}
+
+// Validate checks that the options are consistent
+func (o PoolOptions) Validate() error {
+	if o.MaxConns < 1 || o.MinConns < 0 || o.MinConns > o.MaxConns {
+		return fmt.Errorf("pool must allow at least one connection, and keep at most its maximum open")
+	}
+	if o.StatementCacheCapacity == 0 && (o.QueryExecMode == pgx.QueryExecModeCacheStatement || o.QueryExecMode == pgx.QueryExecModeCacheDescribe) {
+		return fmt.Errorf("statement cache capacity must be positive with a caching query exec mode")
+	}
+	return nil
+}
+
+func NewPool(ctx context.Context, connString string, opts PoolOptions) (*pgxpool.Pool, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid pool options: %w", err)
+	}
+
 	config, err := pgxpool.ParseConfig(connString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse connection string: %w", err)
 	}
 
 	// Configure pool settings
-	config.MaxConns = 20
-	config.MinConns = 5
-	config.MaxConnLifetime = 1 * 60 * 60 * 1000000000  // 1 hour
-	config.MaxConnIdleTime = 30 * 60 * 1000000000     // 30 minutes
+	config.MaxConns = opts.MaxConns
+	config.MinConns = opts.MinConns
+	config.MaxConnLifetime = opts.MaxConnLifetime
+	config.MaxConnIdleTime = opts.MaxConnIdleTime
+	config.HealthCheckPeriod = opts.HealthCheckPeriod
+	config.ConnConfig.ConnectTimeout = opts.ConnectTimeout
+
+	// Configure query execution
+	config.ConnConfig.DefaultQueryExecMode = opts.QueryExecMode
+	config.ConnConfig.StatementCacheCapacity = opts.StatementCacheCapacity
+	config.ConnConfig.DescriptionCacheCapacity = opts.StatementCacheCapacity
+	if len(opts.Prepare) > 0 && opts.QueryExecMode != pgx.QueryExecModeSimpleProtocol {
+		statements := opts.Prepare
+		config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			for _, sql := range statements {
+				if _, err := conn.Prepare(ctx, sql, sql); err != nil {
+					return fmt.Errorf("failed to prepare statement: %w", err)
+				}
+			}
+			return nil
+		}
+	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
@@ -25,7 +100,10 @@ func NewPool(ctx context.Context, connString string) (*pgxpool.Pool, error) {
 	}
 
 	// Test connection
-	if err := pool.Ping(ctx); err != nil {
+	pingCtx, cancel := context.WithTimeout(ctx, opts.ConnectTimeout)
+	defer cancel()
+	if err := pool.Ping(pingCtx); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
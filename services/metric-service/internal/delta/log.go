@@ -0,0 +1,156 @@
+// Package delta writes the transaction log of Delta Lake tables, so that
+// Parquet files exported to object storage read as one table in Spark, Trino
+// and the other lakehouse engines. It covers what an append-only writer
+// needs: the protocol and metadata of a new table and the files each commit
+// adds, without checkpoints.
+package delta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// The reader and writer versions of the protocol the log follows, the
+// earliest ones, which every engine reads
+const (
+	MinReaderVersion = 1
+	MinWriterVersion = 2
+)
+
+// NullPartition is the directory name of a null partition value, as Hive
+// layouts name it
+const NullPartition = "__HIVE_DEFAULT_PARTITION__"
+
+// Action is one line of a commit, with exactly one of its fields set
+type Action struct {
+	Protocol   *Protocol   `json:"protocol,omitempty"`
+	MetaData   *Metadata   `json:"metaData,omitempty"`
+	Add        *Add        `json:"add,omitempty"`
+	CommitInfo *CommitInfo `json:"commitInfo,omitempty"`
+}
+
+type Protocol struct {
+	MinReaderVersion int `json:"minReaderVersion"`
+	MinWriterVersion int `json:"minWriterVersion"`
+}
+
+// Metadata describes a table: its schema, as a JSON schemaString, and the
+// columns its files are partitioned by
+type Metadata struct {
+	ID               string            `json:"id"`
+	Format           Format            `json:"format"`
+	SchemaString     string            `json:"schemaString"`
+	PartitionColumns []string          `json:"partitionColumns"`
+	Configuration    map[string]string `json:"configuration"`
+	CreatedTime      int64             `json:"createdTime"`
+}
+
+type Format struct {
+	Provider string            `json:"provider"`
+	Options  map[string]string `json:"options"`
+}
+
+// Add adds a data file to the table. Path is relative to the table root and
+// URL encoded; partition values are strings, nil for null.
+type Add struct {
+	Path             string             `json:"path"`
+	PartitionValues  map[string]*string `json:"partitionValues"`
+	Size             int64              `json:"size"`
+	ModificationTime int64              `json:"modificationTime"`
+	DataChange       bool               `json:"dataChange"`
+	Stats            string             `json:"stats,omitempty"`
+}
+
+// CommitInfo records the operation of a commit. Engines show it in the
+// table's history and otherwise ignore it.
+type CommitInfo struct {
+	Timestamp           int64             `json:"timestamp"`
+	Operation           string            `json:"operation"`
+	OperationParameters map[string]string `json:"operationParameters"`
+	EngineInfo          string            `json:"engineInfo,omitempty"`
+}
+
+// Field is a column of a table schema. Type is a primitive type name of the
+// Delta protocol, such as "string", "long", "double" or "timestamp".
+type Field struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+// SchemaString encodes the fields of a table as Metadata.SchemaString
+func SchemaString(fields []Field) string {
+	type structField struct {
+		Name     string            `json:"name"`
+		Type     string            `json:"type"`
+		Nullable bool              `json:"nullable"`
+		Metadata map[string]string `json:"metadata"`
+	}
+	schema := struct {
+		Type   string        `json:"type"`
+		Fields []structField `json:"fields"`
+	}{Type: "struct", Fields: make([]structField, len(fields))}
+	for i, f := range fields {
+		schema.Fields[i] = structField{Name: f.Name, Type: f.Type, Nullable: f.Nullable, Metadata: map[string]string{}}
+	}
+	data, _ := json.Marshal(schema)
+	return string(data)
+}
+
+// LogKey is the key of the commit of a version, relative to the table root
+func LogKey(version int64) string {
+	return fmt.Sprintf("_delta_log/%020d.json", version)
+}
+
+// EncodeCommit encodes the actions of a commit as JSON lines
+func EncodeCommit(actions []Action) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, a := range actions {
+		if err := enc.Encode(a); err != nil {
+			return nil, fmt.Errorf("failed to encode commit: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeCommit reads the actions of a commit
+func DecodeCommit(data []byte) ([]Action, error) {
+	var actions []Action
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var a Action
+		if err := dec.Decode(&a); err != nil {
+			return nil, fmt.Errorf("failed to decode commit: %w", err)
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+// PartitionDir is the directory of a partition's files, such as
+// "project_id=x/date=2026-01-02/", in the order of columns. Values must not
+// hold slashes.
+func PartitionDir(columns []string, values map[string]*string) string {
+	var b strings.Builder
+	for _, c := range columns {
+		value := NullPartition
+		if v := values[c]; v != nil {
+			value = *v
+		}
+		b.WriteString(c + "=" + value + "/")
+	}
+	return b.String()
+}
+
+// EscapePath URL encodes a path relative to the table root, as Add.Path holds it
+func EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
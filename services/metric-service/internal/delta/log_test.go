@@ -0,0 +1,78 @@
+package delta
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSchemaString(t *testing.T) {
+	got := SchemaString([]Field{{Name: "time", Type: "timestamp"}, {Name: "step", Type: "long", Nullable: true}})
+	want := `{"type":"struct","fields":[{"name":"time","type":"timestamp","nullable":false,"metadata":{}},` +
+		`{"name":"step","type":"long","nullable":true,"metadata":{}}]}`
+	if got != want {
+		t.Errorf("SchemaString() = %s, want %s", got, want)
+	}
+}
+
+func TestLogKey(t *testing.T) {
+	if got := LogKey(12); got != "_delta_log/00000000000000000012.json" {
+		t.Errorf("LogKey(12) = %s", got)
+	}
+}
+
+func TestCommitRoundTrip(t *testing.T) {
+	date := "2026-01-02"
+	actions := []Action{
+		{Protocol: &Protocol{MinReaderVersion: MinReaderVersion, MinWriterVersion: MinWriterVersion}},
+		{Add: &Add{Path: "date=2026-01-02/part-0.parquet", PartitionValues: map[string]*string{"date": &date, "project_id": nil}, Size: 10, DataChange: true}},
+		{CommitInfo: &CommitInfo{Timestamp: 1, Operation: "WRITE", OperationParameters: map[string]string{"mode": "Append"}}},
+	}
+	data, err := EncodeCommit(actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("commit holds %d lines, want 3", len(lines))
+	}
+	// Each line holds one action, and a null partition value stays null
+	var add map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &add); err != nil {
+		t.Fatal(err)
+	}
+	if len(add) != 1 || add["add"]["partitionValues"].(map[string]interface{})["project_id"] != nil {
+		t.Errorf("add line = %s", lines[1])
+	}
+
+	decoded, err := DecodeCommit(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 3 || decoded[0].Protocol == nil || *decoded[1].Add.PartitionValues["date"] != date ||
+		decoded[2].CommitInfo.Operation != "WRITE" {
+		t.Errorf("DecodeCommit() = %+v", decoded)
+	}
+}
+
+func TestPartitionDir(t *testing.T) {
+	project, date := "p 1", "2026-01-02"
+	tests := []struct {
+		values map[string]*string
+		want   string
+	}{
+		{map[string]*string{"project_id": &project, "date": &date}, "project_id=p 1/date=2026-01-02/"},
+		{map[string]*string{"date": &date}, "project_id=__HIVE_DEFAULT_PARTITION__/date=2026-01-02/"},
+	}
+	for _, tt := range tests {
+		if got := PartitionDir([]string{"project_id", "date"}, tt.values); got != tt.want {
+			t.Errorf("PartitionDir(%v) = %s, want %s", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	if got := EscapePath("project_id=p 1/date=2026-01-02/part-0.parquet"); got != "project_id=p%201/date=2026-01-02/part-0.parquet" {
+		t.Errorf("EscapePath() = %s", got)
+	}
+}
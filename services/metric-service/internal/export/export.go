@@ -0,0 +1,252 @@
+// Package export snapshots the metrics of ended runs to object storage, as
+// Parquet or gzipped JSON lines, for archives kept off the database. The
+// Manager exports runs on demand and, as a scheduled job, every run that
+// ended since the last pass; the outcome of each run's latest export is kept
+// in the run_exports table.
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/parquet"
+	"github.com/wanllmdb/metric-service/internal/repository"
+	"github.com/wanllmdb/metric-service/internal/service"
+	"github.com/wanllmdb/metric-service/internal/storage"
+)
+
+// Formats of exported runs
+const (
+	FormatParquet = "parquet"
+	FormatJSONL   = "jsonl"
+)
+
+// ErrRunNotEnded is returned when exporting a run whose latest state is not
+// one of model.RunEndStates
+var ErrRunNotEnded = errors.New("run has not ended")
+
+// Options configure the exports of a Manager
+type Options struct {
+	// Prefix is the key under which each run's export is stored
+	Prefix string
+	// Format is FormatParquet or FormatJSONL
+	Format string
+	// Settle holds back the export of a run until it has ended this long,
+	// so that buffered writes make it into the export
+	Settle time.Duration
+	// MaxRuns bounds the runs one pass of the job exports
+	MaxRuns int
+}
+
+// Manager exports the metrics of ended runs to object storage
+type Manager struct {
+	exports *repository.ExportRepository
+	metrics *repository.MaintenanceRepository
+	store   storage.ObjectStore
+	opts    Options
+	logger  *zap.Logger
+}
+
+func NewManager(exports *repository.ExportRepository, metrics *repository.MaintenanceRepository, store storage.ObjectStore, opts Options, logger *zap.Logger) (*Manager, error) {
+	if _, err := contentType(opts.Format); err != nil {
+		return nil, err
+	}
+	return &Manager{
+		exports: exports,
+		metrics: metrics,
+		store:   store,
+		opts:    opts,
+		logger:  logger,
+	}, nil
+}
+
+// Key is where the export of a run is stored
+func Key(prefix string, runID uuid.UUID, format string) string {
+	name := "metrics.parquet"
+	if format == FormatJSONL {
+		name = "metrics.jsonl.gz"
+	}
+	return path.Join(prefix, runID.String(), name)
+}
+
+// contentType is the content type of exports in format
+func contentType(format string) (string, error) {
+	switch format {
+	case FormatParquet:
+		return "application/vnd.apache.parquet", nil
+	case FormatJSONL:
+		return "application/gzip", nil
+	}
+	return "", fmt.Errorf("unknown export format %q, must be parquet or jsonl", format)
+}
+
+// ExportRun exports the metrics of a run that has ended, replacing any
+// earlier export of it, and records the outcome
+func (m *Manager) ExportRun(ctx context.Context, runID uuid.UUID) (*model.RunExport, error) {
+	run, err := m.exports.GetEndedRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, ErrRunNotEnded
+	}
+	return m.export(ctx, *run)
+}
+
+// ExportDue exports the runs that ended at least Settle ago and have no
+// export of their latest end, at most MaxRuns of them, those never exported
+// first. Failed exports are retried on each pass. It returns the exports it
+// made, failed ones included, and the failures joined as its error.
+func (m *Manager) ExportDue(ctx context.Context) ([]model.RunExport, error) {
+	runs, err := m.exports.ListDueRuns(ctx, time.Now().Add(-m.opts.Settle), m.opts.MaxRuns)
+	if err != nil {
+		return nil, err
+	}
+
+	var exports []model.RunExport
+	var errs []error
+	for _, run := range runs {
+		export, err := m.export(ctx, run)
+		if export != nil {
+			exports = append(exports, *export)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return exports, err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return exports, errors.Join(errs...)
+}
+
+// List returns the latest exports of runs, most recent first
+func (m *Manager) List(ctx context.Context, params model.RunExportQueryParams) ([]model.RunExport, error) {
+	return m.exports.ListRunExports(ctx, params)
+}
+
+// Job exports the runs due as a scheduled job
+func (m *Manager) Job(interval time.Duration) service.Job {
+	return service.Job{
+		Name:     "run-export",
+		Interval: interval,
+		Run: func(ctx context.Context) (string, error) {
+			exports, err := m.ExportDue(ctx)
+			return jobResult(exports), err
+		},
+	}
+}
+
+// jobResult describes a pass of the job
+func jobResult(exports []model.RunExport) string {
+	var exported, failed int
+	var rows, size int64
+	for _, e := range exports {
+		if e.Status == model.RunExportFailed {
+			failed++
+			continue
+		}
+		exported++
+		rows += e.Rows
+		size += e.Size
+	}
+	return fmt.Sprintf("exported %d runs (%d rows, %d bytes), %d failed", exported, rows, size, failed)
+}
+
+// export writes the metrics of an ended run to its key and records the
+// outcome. A failure to write is recorded, and returned with the failed
+// export.
+func (m *Manager) export(ctx context.Context, run model.EndedRun) (*model.RunExport, error) {
+	export := &model.RunExport{
+		RunID:   run.RunID,
+		State:   run.State,
+		EndedAt: run.EndedAt,
+		Status:  model.RunExportExported,
+		Format:  m.opts.Format,
+		Key:     Key(m.opts.Prefix, run.RunID, m.opts.Format),
+	}
+	exportErr := m.write(ctx, export)
+	if exportErr != nil {
+		if ctx.Err() != nil {
+			return nil, exportErr
+		}
+		m.logger.Warn("Failed to export run", zap.String("run_id", run.RunID.String()), zap.Error(exportErr))
+		export.Status = model.RunExportFailed
+		export.Key, export.Rows, export.Size = "", 0, 0
+		export.Error = exportErr.Error()
+	}
+
+	if err := m.exports.RecordRunExport(ctx, export); err != nil {
+		return nil, errors.Join(exportErr, err)
+	}
+	return export, exportErr
+}
+
+// write streams the metrics of a run to the object store as they are read
+func (m *Manager) write(ctx context.Context, export *model.RunExport) error {
+	contentType, err := contentType(export.Format)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := bufio.NewWriter(countingWriter{w: pw, n: &export.Size})
+		encode, finish := encoder(export.Format, buf)
+		rows, err := m.metrics.StreamRunMetrics(ctx, export.RunID, encode)
+		if err == nil {
+			err = finish()
+		}
+		if err == nil {
+			err = buf.Flush()
+		}
+		export.Rows = rows
+		pw.CloseWithError(err)
+	}()
+
+	// The size of the export is not known up front
+	err = m.store.Put(ctx, export.Key, pr, -1, contentType)
+	pr.CloseWithError(err)
+	<-done
+	if err != nil {
+		return fmt.Errorf("failed to export run %s: %w", export.RunID, err)
+	}
+	return nil
+}
+
+// encoder returns the functions writing metrics in format to w and
+// finishing the export
+func encoder(format string, w io.Writer) (func(model.Metric) error, func() error) {
+	if format == FormatJSONL {
+		gz := gzip.NewWriter(w)
+		enc := json.NewEncoder(gz)
+		return func(metric model.Metric) error { return enc.Encode(metric) }, gz.Close
+	}
+	pw := parquet.NewWriter(w)
+	return pw.Write, pw.Close
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,98 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestKey(t *testing.T) {
+	runID := uuid.MustParse("6f1c2a7e-7d3b-4a59-9d0e-2b7f7f1c0a11")
+	tests := []struct {
+		prefix string
+		format string
+		want   string
+	}{
+		{"exports/runs", FormatParquet, "exports/runs/6f1c2a7e-7d3b-4a59-9d0e-2b7f7f1c0a11/metrics.parquet"},
+		{"exports/runs/", FormatJSONL, "exports/runs/6f1c2a7e-7d3b-4a59-9d0e-2b7f7f1c0a11/metrics.jsonl.gz"},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.prefix, runID, tt.format); got != tt.want {
+			t.Errorf("Key(%q, %q) = %q, want %q", tt.prefix, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestNewManagerFormat(t *testing.T) {
+	for _, format := range []string{FormatParquet, FormatJSONL} {
+		if _, err := NewManager(nil, nil, nil, Options{Format: format}, zap.NewNop()); err != nil {
+			t.Errorf("NewManager with format %q: %v", format, err)
+		}
+	}
+	for _, format := range []string{"", "csv"} {
+		if _, err := NewManager(nil, nil, nil, Options{Format: format}, zap.NewNop()); err == nil {
+			t.Errorf("NewManager with format %q: want an error", format)
+		}
+	}
+}
+
+func TestEncoderJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	encode, finish := encoder(FormatJSONL, &buf)
+	step := int64(3)
+	metric := model.Metric{
+		RunID:      uuid.New(),
+		MetricName: "loss",
+		Step:       &step,
+		Value:      0.5,
+		Time:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	for i := 0; i < 2; i++ {
+		if err := encode(metric); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var got model.Metric
+	if err := json.Unmarshal(lines[0], &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.MetricName != "loss" || got.Value != 0.5 || got.Step == nil || *got.Step != 3 {
+		t.Errorf("decoded %+v, want %+v", got, metric)
+	}
+}
+
+func TestJobResult(t *testing.T) {
+	exports := []model.RunExport{
+		{Status: model.RunExportExported, Rows: 10, Size: 100},
+		{Status: model.RunExportFailed, Error: "store unavailable"},
+		{Status: model.RunExportExported, Rows: 5, Size: 50},
+	}
+	want := "exported 2 runs (15 rows, 150 bytes), 1 failed"
+	if got := jobResult(exports); got != want {
+		t.Errorf("jobResult = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,197 @@
+package grpcapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/wanllmdb/metric-service/internal/arrowipc"
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// flightBatchRows is the most rows of a record batch sent by DoGet
+const flightBatchRows = 8192
+
+// Field numbers of arrow.flight.protocol.Ticket and FlightData
+const (
+	ticketTicket     = 1
+	flightDataHeader = 2
+	flightDataBody   = 1000
+)
+
+// flightTicket is the ticket of a DoGet, JSON naming a metric of a run and
+// the time and step bounds of its history
+type flightTicket struct {
+	RunID      string     `json:"run_id"`
+	MetricName string     `json:"metric_name"`
+	StartTime  *time.Time `json:"start_time"`
+	EndTime    *time.Time `json:"end_time"`
+	MinStep    *int64     `json:"min_step"`
+	MaxStep    *int64     `json:"max_step"`
+}
+
+// FlightServer serves metric histories over Arrow Flight, as record batches
+// notebooks read without decoding JSON. Of the FlightService it implements
+// DoGet; its messages are encoded by hand, so it runs on a gRPC server of its
+// own, with FlightCodec.
+type FlightServer struct {
+	metrics *service.MetricService
+	logger  *zap.Logger
+}
+
+func NewFlightServer(metrics *service.MetricService, logger *zap.Logger) *FlightServer {
+	return &FlightServer{
+		metrics: metrics,
+		logger:  logger,
+	}
+}
+
+// flightService is implemented by FlightServer, for the service description
+type flightService interface {
+	doGet(stream grpc.ServerStream) error
+}
+
+var flightServiceDesc = grpc.ServiceDesc{
+	ServiceName: "arrow.flight.protocol.FlightService",
+	HandlerType: (*flightService)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "DoGet",
+		Handler:       func(srv interface{}, stream grpc.ServerStream) error { return srv.(flightService).doGet(stream) },
+		ServerStreams: true,
+	}},
+	Metadata: "Flight.proto",
+}
+
+// RegisterFlightServer registers the Flight service of s on a server
+// created with FlightCodec
+func RegisterFlightServer(g *grpc.Server, s *FlightServer) {
+	g.RegisterService(&flightServiceDesc, s)
+}
+
+// FlightCodec is the server option of Flight servers: messages are passed as
+// their encoded bytes
+func FlightCodec() grpc.ServerOption {
+	return grpc.ForceServerCodec(flightCodec{})
+}
+
+type flightCodec struct{}
+
+func (flightCodec) Marshal(v interface{}) ([]byte, error) {
+	data, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("flight codec cannot marshal %T", v)
+	}
+	return data, nil
+}
+
+func (flightCodec) Unmarshal(data []byte, v interface{}) error {
+	p, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("flight codec cannot unmarshal into %T", v)
+	}
+	*p = append((*p)[:0], data...)
+	return nil
+}
+
+// Name is that of the codec Flight clients expect
+func (flightCodec) Name() string {
+	return "proto"
+}
+
+// doGet streams the history of the metric a ticket names, oldest first: the
+// schema of arrowipc.MetricSchema, then record batches of its rows as they
+// are read
+func (s *FlightServer) doGet(stream grpc.ServerStream) error {
+	var msg []byte
+	if err := stream.RecvMsg(&msg); err != nil {
+		return err
+	}
+	ticket, err := parseFlightTicket(msg)
+	if err != nil {
+		return err
+	}
+	runID, err := parseRunID(ticket.RunID)
+	if err != nil {
+		return err
+	}
+	if ticket.MetricName == "" {
+		return status.Error(codes.InvalidArgument, "metric_name is required")
+	}
+	params := model.MetricQueryParams{
+		MetricName: ticket.MetricName,
+		StartTime:  ticket.StartTime,
+		EndTime:    ticket.EndTime,
+		MinStep:    ticket.MinStep,
+		MaxStep:    ticket.MaxStep,
+		Direction:  model.DirectionAsc,
+	}
+
+	w := arrowipc.NewMessageWriter(func(metadata, body []byte) error {
+		return stream.SendMsg(flightData(metadata, body))
+	}, arrowipc.MetricSchema(runID, ticket.MetricName))
+	batch := w.NewBatch()
+	err = s.metrics.StreamRunMetrics(stream.Context(), runID, params, func(m model.Metric) error {
+		arrowipc.AppendMetric(batch, m)
+		if batch.Rows() < flightBatchRows {
+			return nil
+		}
+		err := w.Write(batch)
+		batch = w.NewBatch()
+		return err
+	})
+	if err == nil && batch.Rows() > 0 {
+		err = w.Write(batch)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return queryError(s.logger, "Failed to stream metrics", err)
+	}
+	return nil
+}
+
+// parseFlightTicket reads the JSON ticket of a Ticket message
+func parseFlightTicket(msg []byte) (flightTicket, error) {
+	var raw []byte
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return flightTicket{}, status.Error(codes.InvalidArgument, "invalid ticket message")
+		}
+		msg = msg[n:]
+		if num == ticketTicket && typ == protowire.BytesType {
+			raw, n = protowire.ConsumeBytes(msg)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if n < 0 {
+			return flightTicket{}, status.Error(codes.InvalidArgument, "invalid ticket message")
+		}
+		msg = msg[n:]
+	}
+
+	var ticket flightTicket
+	if err := json.Unmarshal(raw, &ticket); err != nil {
+		return ticket, status.Error(codes.InvalidArgument, "ticket must be a JSON object: "+err.Error())
+	}
+	return ticket, nil
+}
+
+// flightData encodes a FlightData message carrying an Arrow IPC message
+func flightData(metadata, body []byte) []byte {
+	b := protowire.AppendTag(nil, flightDataHeader, protowire.BytesType)
+	b = protowire.AppendBytes(b, metadata)
+	if len(body) > 0 {
+		b = protowire.AppendTag(b, flightDataBody, protowire.BytesType)
+		b = protowire.AppendBytes(b, body)
+	}
+	return b
+}
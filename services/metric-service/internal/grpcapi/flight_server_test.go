@@ -0,0 +1,96 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ticketMessage encodes a Ticket holding ticket
+func ticketMessage(ticket string) []byte {
+	b := protowire.AppendTag(nil, ticketTicket, protowire.BytesType)
+	return protowire.AppendString(b, ticket)
+}
+
+func TestParseFlightTicket(t *testing.T) {
+	runID := uuid.NewString()
+	ticket, err := parseFlightTicket(ticketMessage(`{"run_id":"` + runID + `","metric_name":"loss","min_step":100}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.RunID != runID || ticket.MetricName != "loss" || ticket.MinStep == nil || *ticket.MinStep != 100 || ticket.MaxStep != nil {
+		t.Errorf("parseFlightTicket() = %+v", ticket)
+	}
+
+	for _, msg := range [][]byte{ticketMessage("loss"), nil, {0x0a, 0x05}} {
+		if _, err := parseFlightTicket(msg); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("parseFlightTicket(%x) = %v, want InvalidArgument", msg, err)
+		}
+	}
+}
+
+func TestFlightServerValidation(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(FlightCodec())
+	RegisterFlightServer(server, NewFlightServer(nil, zap.NewNop()))
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(flightCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	call := func(method string, msg []byte) error {
+		stream, err := conn.NewStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, "/arrow.flight.protocol.FlightService/"+method)
+		if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(msg); err != nil {
+			return err
+		}
+		if err := stream.CloseSend(); err != nil {
+			return err
+		}
+		var reply []byte
+		return stream.RecvMsg(&reply)
+	}
+
+	if err := call("DoGet", ticketMessage(`{"run_id":"`+uuid.NewString()+`"}`)); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("DoGet() without a metric name = %v, want InvalidArgument", err)
+	}
+	if err := call("DoGet", ticketMessage(`{"run_id":"not-a-run","metric_name":"loss"}`)); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("DoGet() of an invalid run ID = %v, want InvalidArgument", err)
+	}
+	if err := call("ListFlights", nil); status.Code(err) != codes.Unimplemented {
+		t.Errorf("ListFlights() = %v, want Unimplemented", err)
+	}
+}
+
+func TestFlightData(t *testing.T) {
+	msg := flightData([]byte("header"), []byte("body"))
+	want := protowire.AppendTag(nil, flightDataHeader, protowire.BytesType)
+	want = protowire.AppendString(want, "header")
+	want = protowire.AppendTag(want, flightDataBody, protowire.BytesType)
+	want = protowire.AppendString(want, "body")
+	if string(msg) != string(want) {
+		t.Errorf("flightData() = %x, want %x", msg, want)
+	}
+	// Schema messages have no body
+	if got := flightData([]byte("header"), nil); len(got) != 8 {
+		t.Errorf("flightData() without body = %x", got)
+	}
+}
@@ -0,0 +1,216 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	metricsv1 "github.com/wanllmdb/metric-service/api/metrics/v1"
+	"github.com/wanllmdb/metric-service/internal/metricpb"
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/runservice"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// IngestServer writes and streams metrics for MetricIngestService through
+// the same service as the REST handlers
+type IngestServer struct {
+	metricsv1.UnimplementedMetricIngestServiceServer
+
+	metrics *service.MetricService
+	logger  *zap.Logger
+
+	// runs validates the runs written to when set; failOpen accepts writes
+	// while the run service is unavailable
+	runs     *runservice.Client
+	failOpen bool
+	// limiter sheds writes when set
+	limiter *service.IngestLimiter
+}
+
+func NewIngestServer(metrics *service.MetricService, logger *zap.Logger) *IngestServer {
+	return &IngestServer{
+		metrics: metrics,
+		logger:  logger,
+	}
+}
+
+// UseRunValidation rejects writes to runs the run service does not know or
+// does not let the caller write to, authenticated by the authorization
+// metadata of the call
+func (s *IngestServer) UseRunValidation(runs *runservice.Client, failOpen bool) {
+	s.runs = runs
+	s.failOpen = failOpen
+}
+
+// UseIngestLimiter sheds writes that limiter does not admit with
+// ResourceExhausted, saying when to retry
+func (s *IngestServer) UseIngestLimiter(limiter *service.IngestLimiter) {
+	s.limiter = limiter
+}
+
+// BatchWrite writes a batch of metrics
+func (s *IngestServer) BatchWrite(ctx context.Context, req *metricsv1.BatchWriteRequest) (*metricsv1.BatchWriteResponse, error) {
+	if n := len(req.GetMetrics()); n == 0 || n > metricpb.MaxBatch {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("metrics must hold 1 to %d metrics", metricpb.MaxBatch))
+	}
+	opts := model.IngestOptions{RankReduce: req.GetRankReduce(), KeepRanks: req.KeepRanks}
+	switch opts.RankReduce {
+	case "", "mean", "sum", "max", "min":
+	default:
+		return nil, status.Error(codes.InvalidArgument, "rank_reduce must be mean, sum, max or min")
+	}
+	metrics, items := metricpb.Metrics(req.GetMetrics())
+	if len(items) > 0 {
+		return nil, validationStatus(service.NewBatchValidationError("metric", items).(*service.ValidationError))
+	}
+	if err := s.checkRuns(ctx, metrics); err != nil {
+		return nil, err
+	}
+	if err := s.admit(metrics); err != nil {
+		return nil, err
+	}
+
+	if err := s.metrics.BatchWriteWithOptions(ctx, metrics, opts); err != nil {
+		var validationErr *service.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			return nil, validationStatus(validationErr)
+		case errors.Is(err, service.ErrIngestPoolStopped):
+			return nil, status.Error(codes.Unavailable, "metric ingest is shutting down")
+		case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+			return nil, status.FromContextError(err).Err()
+		}
+		s.logger.Error("Failed to write metrics", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to write metrics")
+	}
+	return &metricsv1.BatchWriteResponse{Count: int32(len(metrics))}, nil
+}
+
+// checkRuns validates the runs of a batch when run validation is enabled
+func (s *IngestServer) checkRuns(ctx context.Context, metrics []model.Metric) error {
+	if s.runs == nil {
+		return nil
+	}
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+	seen := make(map[uuid.UUID]bool, 1)
+	for _, m := range metrics {
+		if seen[m.RunID] {
+			continue
+		}
+		seen[m.RunID] = true
+
+		err := s.runs.CheckRun(ctx, m.RunID, authorization)
+		switch {
+		case err == nil:
+		case errors.Is(err, runservice.ErrRunNotFound):
+			return status.Error(codes.NotFound, "unknown run "+m.RunID.String())
+		case errors.Is(err, runservice.ErrUnauthenticated):
+			return status.Error(codes.Unauthenticated, "missing credentials")
+		case errors.Is(err, runservice.ErrForbidden):
+			return status.Error(codes.PermissionDenied, "not allowed to write to run "+m.RunID.String())
+		case errors.Is(err, runservice.ErrUnavailable) && s.failOpen:
+			s.logger.Warn("Accepting write without run validation", zap.String("run_id", m.RunID.String()), zap.Error(err))
+		default:
+			s.logger.Error("Failed to validate run", zap.String("run_id", m.RunID.String()), zap.Error(err))
+			return status.Error(codes.Unavailable, "run validation unavailable")
+		}
+	}
+	return nil
+}
+
+// admit admits a batch when ingest is limited, reporting when to retry a
+// shed one in a RetryInfo
+func (s *IngestServer) admit(metrics []model.Metric) error {
+	if s.limiter == nil {
+		return nil
+	}
+	runIDs := make([]uuid.UUID, len(metrics))
+	for i, m := range metrics {
+		runIDs[i] = m.RunID
+	}
+	var backpressureErr *service.BackpressureError
+	if !errors.As(s.limiter.Admit(runIDs), &backpressureErr) {
+		return nil
+	}
+	st := status.New(codes.ResourceExhausted, backpressureErr.Error())
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(backpressureErr.RetryAfter)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// Subscribe streams the metrics of a run as they are written
+func (s *IngestServer) Subscribe(req *metricsv1.SubscribeRequest, stream metricsv1.MetricIngestService_SubscribeServer) error {
+	runID, err := parseRunID(req.GetRunId())
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(req.GetMetricNames()))
+	for _, name := range req.GetMetricNames() {
+		names[name] = true
+	}
+
+	ctx := stream.Context()
+	sub := s.metrics.SubscribeToMetrics(ctx, "metrics:"+runID.String())
+	defer sub.Close()
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return status.Error(codes.Unavailable, "subscription closed")
+			}
+			var payload model.MetricPayload
+			if err := json.Unmarshal([]byte(msg.Payload), &payload); err != nil {
+				s.logger.Error("Failed to parse metric payload", zap.Error(err))
+				continue
+			}
+			batch := &metricsv1.MetricBatch{}
+			for _, m := range payload.Metrics {
+				if len(names) == 0 || names[m.MetricName] {
+					batch.Metrics = append(batch.Metrics, metricProto(m))
+				}
+			}
+			if len(batch.Metrics) == 0 {
+				continue
+			}
+			if err := stream.Send(batch); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// validationStatus reports the invalid metrics of a batch as a BadRequest
+func validationStatus(err *service.ValidationError) error {
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(err.Items))
+	for i, item := range err.Items {
+		field := fmt.Sprintf("metrics[%d]", item.Index)
+		if item.Field != "" {
+			field += "." + item.Field
+		}
+		violations[i] = &errdetails.BadRequest_FieldViolation{Field: field, Description: item.Reason}
+	}
+	st := status.New(codes.InvalidArgument, err.Error())
+	if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,73 @@
+package grpcapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	metricsv1 "github.com/wanllmdb/metric-service/api/metrics/v1"
+	"github.com/wanllmdb/metric-service/internal/metricpb"
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+func TestBatchWriteReportsInvalidMetrics(t *testing.T) {
+	s := NewIngestServer(nil, zap.NewNop())
+	_, err := s.BatchWrite(context.Background(), &metricsv1.BatchWriteRequest{Metrics: []*metricsv1.Metric{
+		{RunId: "run", MetricName: "loss"},
+		{RunId: uuid.NewString(), MetricName: "done", ValueType: model.ValueTypeBool, Text: proto.String("yes")},
+	}})
+	want := []string{"metrics[0].run_id", "metrics[1].text"}
+	if got := violationFields(t, err); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("violations of %v, want %v", got, want)
+	}
+}
+
+func TestValidationStatus(t *testing.T) {
+	err := validationStatus(&service.ValidationError{
+		Message: "metric 1: metric_name is required",
+		Items:   []service.ItemError{{Index: 1, Field: "metric_name", Reason: "metric_name is required"}},
+	})
+	if got := violationFields(t, err); len(got) != 1 || got[0] != "metrics[1].metric_name" {
+		t.Errorf("violations of %v, want metrics[1].metric_name", got)
+	}
+}
+
+func TestBatchWriteRejectsRequests(t *testing.T) {
+	s := NewIngestServer(nil, zap.NewNop())
+	requests := []*metricsv1.BatchWriteRequest{
+		{},
+		{Metrics: make([]*metricsv1.Metric, metricpb.MaxBatch+1)},
+		{Metrics: []*metricsv1.Metric{{RunId: uuid.NewString(), MetricName: "loss"}}, RankReduce: "median"},
+	}
+	for _, req := range requests {
+		if _, err := s.BatchWrite(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("BatchWrite() of %d metrics, rank_reduce %q: err = %v, want InvalidArgument", len(req.Metrics), req.RankReduce, err)
+		}
+	}
+}
+
+// violationFields lists the fields of the BadRequest detail of an
+// INVALID_ARGUMENT status
+func violationFields(t *testing.T, err error) []string {
+	t.Helper()
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+	var fields []string
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				fields = append(fields, v.GetField())
+			}
+		}
+	}
+	return fields
+}
@@ -0,0 +1,323 @@
+// Package grpcapi serves the gRPC API of the metric service, a typed
+// alternative to the REST API for backend consumers
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	metricsv1 "github.com/wanllmdb/metric-service/api/metrics/v1"
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// streamBatchSize is the most metrics sent in one message of a stream
+const streamBatchSize = 500
+
+// QueryServer answers the metric queries of MetricQueryService from the same
+// services as the REST handlers
+type QueryServer struct {
+	metricsv1.UnimplementedMetricQueryServiceServer
+
+	metrics   *service.MetricService
+	summaries *service.SummaryService
+	logger    *zap.Logger
+}
+
+func NewQueryServer(metrics *service.MetricService, summaries *service.SummaryService, logger *zap.Logger) *QueryServer {
+	return &QueryServer{
+		metrics:   metrics,
+		summaries: summaries,
+		logger:    logger,
+	}
+}
+
+// GetRunMetrics streams the metrics of a run
+func (s *QueryServer) GetRunMetrics(req *metricsv1.GetRunMetricsRequest, stream metricsv1.MetricQueryService_GetRunMetricsServer) error {
+	runID, err := parseRunID(req.GetRunId())
+	if err != nil {
+		return err
+	}
+	params, err := queryParams(req.GetQuery())
+	if err != nil {
+		return err
+	}
+	params.MetricName = req.GetMetricName()
+	return s.streamMetrics(stream, runID, params)
+}
+
+// GetMetricHistory streams the values of one metric of a run
+func (s *QueryServer) GetMetricHistory(req *metricsv1.GetMetricHistoryRequest, stream metricsv1.MetricQueryService_GetMetricHistoryServer) error {
+	runID, err := parseRunID(req.GetRunId())
+	if err != nil {
+		return err
+	}
+	if req.GetMetricName() == "" {
+		return status.Error(codes.InvalidArgument, "metric_name is required")
+	}
+	params, err := queryParams(req.GetQuery())
+	if err != nil {
+		return err
+	}
+	params.MetricName = req.GetMetricName()
+	return s.streamMetrics(stream, runID, params)
+}
+
+// metricStream is the server side of either metric stream
+type metricStream interface {
+	Context() context.Context
+	Send(*metricsv1.MetricBatch) error
+}
+
+// streamMetrics sends the metrics of a query as they are read, in batches.
+// When the limit is reached, the last batch carries the next page's cursor.
+func (s *QueryServer) streamMetrics(stream metricStream, runID uuid.UUID, params model.MetricQueryParams) error {
+	batch := &metricsv1.MetricBatch{}
+	var last model.Metric
+	count := 0
+	err := s.metrics.StreamRunMetrics(stream.Context(), runID, params, func(m model.Metric) error {
+		last = m
+		count++
+		batch.Metrics = append(batch.Metrics, metricProto(m))
+		if len(batch.Metrics) < streamBatchSize {
+			return nil
+		}
+		err := stream.Send(batch)
+		batch = &metricsv1.MetricBatch{}
+		return err
+	})
+	if err != nil {
+		return queryError(s.logger, "Failed to stream metrics", err)
+	}
+	if params.Limit > 0 && count >= params.Limit {
+		batch.NextCursor = model.EncodeCursor(params.CursorOf(last))
+	}
+	if len(batch.Metrics) > 0 || batch.NextCursor != "" {
+		return stream.Send(batch)
+	}
+	return nil
+}
+
+// GetMetricStats returns the statistics of a metric of a run
+func (s *QueryServer) GetMetricStats(ctx context.Context, req *metricsv1.GetMetricStatsRequest) (*metricsv1.MetricStats, error) {
+	runID, err := parseRunID(req.GetRunId())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetMetricName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "metric_name is required")
+	}
+	stats, err := s.metrics.GetMetricStats(ctx, runID, req.GetMetricName())
+	if err != nil {
+		return nil, queryError(s.logger, "Failed to get metric stats", err)
+	}
+	if stats == nil {
+		return nil, status.Error(codes.NotFound, "metric not found")
+	}
+	return statsProto(stats), nil
+}
+
+// Compare returns the summaries of a metric across runs or run groups
+func (s *QueryServer) Compare(ctx context.Context, req *metricsv1.CompareRequest) (*metricsv1.CompareResponse, error) {
+	params, runIDs, err := summaryParams(req)
+	if err != nil {
+		return nil, err
+	}
+	resp := &metricsv1.CompareResponse{MetricName: params.MetricName, Sort: params.Sort}
+
+	if params.GroupBy == "group" {
+		groups, err := s.summaries.ListGroupSummaries(ctx, params, runIDs)
+		if err != nil {
+			return nil, queryError(s.logger, "Failed to list group summaries", err)
+		}
+		for _, g := range groups {
+			resp.Groups = append(resp.Groups, &metricsv1.GroupMetricSummary{
+				Group:      g.Group,
+				MetricName: g.MetricName,
+				Goal:       g.Goal,
+				RunCount:   g.RunCount,
+				Mean:       g.Mean,
+				StdDev:     g.StdDev,
+				Min:        g.Min,
+				Max:        g.Max,
+				BestRunId:  g.BestRunID.String(),
+			})
+		}
+		return resp, nil
+	}
+
+	summaries, err := s.summaries.ListSummaries(ctx, params, runIDs)
+	if err != nil {
+		return nil, queryError(s.logger, "Failed to list summaries", err)
+	}
+	for _, sum := range summaries {
+		resp.Runs = append(resp.Runs, &metricsv1.RunMetricSummary{
+			RunId:      sum.RunID.String(),
+			MetricName: sum.MetricName,
+			Goal:       sum.Goal,
+			FinalValue: sum.FinalValue,
+			FinalStep:  sum.FinalStep,
+			FinalTime:  timestamppb.New(sum.FinalTime),
+			BestValue:  sum.BestValue,
+			BestStep:   sum.BestStep,
+			BestTime:   timestamppb.New(sum.BestTime),
+			Count:      sum.Count,
+			UpdatedAt:  timestamppb.New(sum.UpdatedAt),
+		})
+	}
+	return resp, nil
+}
+
+// queryError maps a service error to its status, logging unexpected ones
+func queryError(logger *zap.Logger, msg string, err error) error {
+	var limitErr *service.RowLimitError
+	switch {
+	case errors.As(err, &limitErr):
+		return status.Error(codes.ResourceExhausted, limitErr.Error())
+	case errors.Is(err, model.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, "invalid cursor")
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	logger.Error(msg, zap.Error(err))
+	return status.Error(codes.Internal, msg)
+}
+
+func parseRunID(value string) (uuid.UUID, error) {
+	runID, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "invalid run ID")
+	}
+	return runID, nil
+}
+
+// queryParams reads the bounds of a metric query. Queries without a limit
+// stream every matching value.
+func queryParams(q *metricsv1.MetricQuery) (model.MetricQueryParams, error) {
+	if q == nil {
+		q = &metricsv1.MetricQuery{}
+	}
+	params := model.MetricQueryParams{
+		Stream:  true,
+		MinStep: q.MinStep,
+		MaxStep: q.MaxStep,
+		Limit:   int(q.GetLimit()),
+		Cursor:  q.GetCursor(),
+	}
+	switch {
+	case params.Limit < 0 || params.Limit > model.MaxStreamedMetricQueryLimit:
+		return params, status.Error(codes.InvalidArgument, fmt.Sprintf("limit must be between 0 and %d", model.MaxStreamedMetricQueryLimit))
+	case params.Limit == 0:
+		params.All = true
+	}
+	if q.GetStartTime() != nil {
+		t := q.GetStartTime().AsTime()
+		params.StartTime = &t
+	}
+	if q.GetEndTime() != nil {
+		t := q.GetEndTime().AsTime()
+		params.EndTime = &t
+	}
+	return params, nil
+}
+
+// summaryParams reads a comparison as ListSummaries takes it, with the
+// defaults of GET /summaries
+func summaryParams(req *metricsv1.CompareRequest) (model.SummaryQueryParams, []uuid.UUID, error) {
+	params := model.SummaryQueryParams{
+		MetricName: req.GetMetricName(),
+		Sort:       req.GetSort(),
+		Group:      req.GetGroup(),
+		JobType:    req.GetJobType(),
+		GroupBy:    req.GetGroupBy(),
+		Limit:      int(req.GetLimit()),
+	}
+	if params.MetricName == "" {
+		return params, nil, status.Error(codes.InvalidArgument, "metric_name is required")
+	}
+	switch params.Sort {
+	case "":
+		params.Sort = "best"
+	case "best", "final":
+	default:
+		return params, nil, status.Error(codes.InvalidArgument, "sort must be best or final")
+	}
+	if params.GroupBy != "" && params.GroupBy != "group" {
+		return params, nil, status.Error(codes.InvalidArgument, "group_by must be empty or group")
+	}
+	switch {
+	case params.Limit < 0 || params.Limit > 1000:
+		return params, nil, status.Error(codes.InvalidArgument, "limit must be between 0 and 1000")
+	case params.Limit == 0:
+		params.Limit = 100
+	}
+	if projectID := req.GetProjectId(); projectID != "" {
+		id, err := uuid.Parse(projectID)
+		if err != nil {
+			return params, nil, status.Error(codes.InvalidArgument, "invalid project ID")
+		}
+		params.ProjectID = &id
+	}
+	runIDs := make([]uuid.UUID, 0, len(req.GetRunIds()))
+	for _, value := range req.GetRunIds() {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return params, nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid run ID %q", value))
+		}
+		runIDs = append(runIDs, id)
+	}
+	return params, runIDs, nil
+}
+
+func metricProto(m model.Metric) *metricsv1.Metric {
+	pb := &metricsv1.Metric{
+		Time:       timestamppb.New(m.Time),
+		RunId:      m.RunID.String(),
+		MetricName: m.MetricName,
+		Step:       m.Step,
+		Value:      m.Value,
+		ValueType:  m.ValueType,
+		Text:       m.Text,
+		NodeId:     m.NodeID,
+	}
+	if m.Rank != nil {
+		rank := int32(*m.Rank)
+		pb.Rank = &rank
+	}
+	if len(m.Metadata) > 0 {
+		// Metadata holds decoded JSON, which a Struct always represents
+		pb.Metadata, _ = structpb.NewStruct(m.Metadata)
+	}
+	return pb
+}
+
+func statsProto(stats *model.MetricStats) *metricsv1.MetricStats {
+	return &metricsv1.MetricStats{
+		MetricName:         stats.MetricName,
+		Count:              stats.Count,
+		MinValue:           stats.MinValue,
+		MaxValue:           stats.MaxValue,
+		AvgValue:           stats.AvgValue,
+		StdDev:             stats.StdDev,
+		NonFiniteCount:     stats.NonFiniteCount,
+		FirstNonFiniteTime: optionalTimestamp(stats.FirstNonFiniteTime),
+		FirstTime:          timestamppb.New(stats.FirstTime),
+		LastTime:           timestamppb.New(stats.LastTime),
+	}
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
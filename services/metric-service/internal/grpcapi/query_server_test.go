@@ -0,0 +1,126 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	metricsv1 "github.com/wanllmdb/metric-service/api/metrics/v1"
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestQueryParams(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	params, err := queryParams(nil)
+	if err != nil || !params.All || !params.Stream || params.Limit != 0 {
+		t.Errorf("queryParams(nil) = %+v, %v; want every value streamed", params, err)
+	}
+
+	params, err = queryParams(&metricsv1.MetricQuery{
+		StartTime: timestamppb.New(start),
+		MinStep:   proto.Int64(10),
+		Limit:     50,
+		Cursor:    "abc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.All || params.Limit != 50 || params.Cursor != "abc" || *params.MinStep != 10 || params.MaxStep != nil ||
+		!params.StartTime.Equal(start) || params.EndTime != nil {
+		t.Errorf("queryParams() = %+v", params)
+	}
+
+	for _, limit := range []int32{-1, model.MaxStreamedMetricQueryLimit + 1} {
+		if _, err := queryParams(&metricsv1.MetricQuery{Limit: limit}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("limit %d: err = %v, want InvalidArgument", limit, err)
+		}
+	}
+}
+
+func TestSummaryParams(t *testing.T) {
+	runID := uuid.New()
+	params, runIDs, err := summaryParams(&metricsv1.CompareRequest{MetricName: "val/loss", RunIds: []string{runID.String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Sort != "best" || params.Limit != 100 || len(runIDs) != 1 || runIDs[0] != runID {
+		t.Errorf("summaryParams() = %+v, %v; want the defaults of GET /summaries", params, runIDs)
+	}
+
+	invalid := []*metricsv1.CompareRequest{
+		{},
+		{MetricName: "loss", Sort: "worst"},
+		{MetricName: "loss", GroupBy: "job_type"},
+		{MetricName: "loss", Limit: 1001},
+		{MetricName: "loss", ProjectId: "x"},
+		{MetricName: "loss", RunIds: []string{"x"}},
+	}
+	for _, req := range invalid {
+		if _, _, err := summaryParams(req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("summaryParams(%v): err = %v, want InvalidArgument", req, err)
+		}
+	}
+}
+
+func TestMetricProto(t *testing.T) {
+	text := "warmup"
+	rank := 2
+	m := model.Metric{
+		Time:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		RunID:      uuid.New(),
+		MetricName: "phase",
+		ValueType:  model.ValueTypeString,
+		Text:       &text,
+		Rank:       &rank,
+		Metadata:   map[string]interface{}{"epoch": 3.0},
+	}
+	pb := metricProto(m)
+	if pb.GetRunId() != m.RunID.String() || pb.GetText() != "warmup" || pb.GetValueType() != "string" ||
+		pb.GetRank() != 2 || pb.Step != nil || !pb.GetTime().AsTime().Equal(m.Time) ||
+		pb.GetMetadata().GetFields()["epoch"].GetNumberValue() != 3 {
+		t.Errorf("metricProto() = %v", pb)
+	}
+}
+
+func TestQueryServerValidation(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	metricsv1.RegisterMetricQueryServiceServer(server, NewQueryServer(nil, nil, zap.NewNop()))
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := metricsv1.NewMetricQueryServiceClient(conn)
+	ctx := context.Background()
+
+	_, err = client.GetMetricStats(ctx, &metricsv1.GetMetricStatsRequest{RunId: "not-a-run", MetricName: "loss"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetMetricStats() = %v, want InvalidArgument", err)
+	}
+
+	stream, err := client.GetMetricHistory(ctx, &metricsv1.GetMetricHistoryRequest{RunId: uuid.NewString()})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetMetricHistory() without a metric name = %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type AnnotationHandler struct {
+	service *service.AnnotationService
+	logger  *zap.Logger
+}
+
+func NewAnnotationHandler(service *service.AnnotationService, logger *zap.Logger) *AnnotationHandler {
+	return &AnnotationHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// CreateAnnotation adds an event marker to a run's timeline
+func (h *AnnotationHandler) CreateAnnotation(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.CreateAnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	annotation, err := h.service.CreateAnnotation(c.Request.Context(), runID, req)
+	if err != nil {
+		h.logger.Error("Failed to create annotation", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to create annotation"})
+		return
+	}
+
+	respond(c, http.StatusCreated, annotation)
+}
+
+// GetRunAnnotations lists the annotations of a run
+func (h *AnnotationHandler) GetRunAnnotations(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.AnnotationQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	annotations, err := h.service.GetRunAnnotations(c.Request.Context(), runID, params)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to get annotations", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get annotations"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("annotations", annotations, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// DeleteAnnotation removes an annotation from a run
+func (h *AnnotationHandler) DeleteAnnotation(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	annotationID, err := uuid.Parse(c.Param("annotation_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid annotation ID"})
+		return
+	}
+
+	deleted, err := h.service.DeleteAnnotation(c.Request.Context(), runID, annotationID)
+	if err != nil {
+		h.logger.Error("Failed to delete annotation", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete annotation"})
+		return
+	}
+	if !deleted {
+		respond(c, http.StatusNotFound, gin.H{"error": "Annotation not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
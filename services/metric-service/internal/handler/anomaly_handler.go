@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type AnomalyHandler struct {
+	detector *service.AnomalyDetector
+	logger   *zap.Logger
+}
+
+func NewAnomalyHandler(detector *service.AnomalyDetector, logger *zap.Logger) *AnomalyHandler {
+	return &AnomalyHandler{
+		detector: detector,
+		logger:   logger,
+	}
+}
+
+// GetRunAnomalies retrieves anomaly events detected for a run
+func (h *AnomalyHandler) GetRunAnomalies(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.AnomalyQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 100
+	}
+
+	anomalies, err := h.detector.GetRunAnomalies(c.Request.Context(), runID, params)
+	if err != nil {
+		h.logger.Error("Failed to get anomalies", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get anomalies"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("anomalies", anomalies, gin.H{
+		"run_id": runID,
+	}))
+}
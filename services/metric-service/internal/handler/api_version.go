@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// V2Response is the envelope of every JSON response of /api/v2. List
+// endpoints, those answering with a listResponse, put their items in Data
+// and their paging in Pagination, with the rest of the response in Meta;
+// other endpoints put their whole response in Data.
+type V2Response struct {
+	Data       interface{}            `json:"data,omitempty"`
+	Pagination *V2Pagination          `json:"pagination,omitempty"`
+	Meta       map[string]interface{} `json:"meta,omitempty"`
+	Error      *V2Error               `json:"error,omitempty"`
+}
+
+// V2Pagination describes the page of a list response. NextCursor is set by
+// endpoints paged by cursor while rows follow the page; Total, Offset and
+// Limit are only set by endpoints that know them. HasMore is left out when
+// it cannot be told.
+type V2Pagination struct {
+	Count      int     `json:"count"`
+	NextCursor *string `json:"next_cursor,omitempty"`
+	Total      *int64  `json:"total,omitempty"`
+	Offset     *int64  `json:"offset,omitempty"`
+	Limit      *int64  `json:"limit,omitempty"`
+	HasMore    *bool   `json:"has_more,omitempty"`
+}
+
+// V2Error is the error model of /api/v2: a stable machine readable code,
+// the HTTP status and a message for humans
+type V2Error struct {
+	Code    string                 `json:"code"`
+	Status  int                    `json:"status"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// v2ErrorCodes maps HTTP statuses to V2Error codes
+var v2ErrorCodes = map[int]string{
+	http.StatusBadRequest:            "invalid_argument",
+	http.StatusUnauthorized:          "unauthenticated",
+	http.StatusForbidden:             "permission_denied",
+	http.StatusNotFound:              "not_found",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnprocessableEntity:   "invalid_argument",
+	http.StatusTooManyRequests:       "rate_limited",
+	StatusClientClosedRequest:        "cancelled",
+	http.StatusServiceUnavailable:    "unavailable",
+	http.StatusGatewayTimeout:        "deadline_exceeded",
+}
+
+func v2ErrorCode(status int) string {
+	if code, ok := v2ErrorCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return "internal"
+	}
+	return "failed_precondition"
+}
+
+// apiVersionKey holds the API version of a request in the gin context
+const apiVersionKey = "api_version"
+
+// DeprecatedVersion marks the responses of a superseded API version with the
+// Deprecation and Sunset headers (RFC 9745, RFC 8594) and links each route to
+// its successor. A zero deprecatedAt sends "Deprecation: true"; a zero sunset
+// leaves out the Sunset header.
+func DeprecatedVersion(prefix, successorPrefix string, deprecatedAt, sunset time.Time) gin.HandlerFunc {
+	deprecation := "true"
+	if !deprecatedAt.IsZero() {
+		deprecation = "@" + strconv.FormatInt(deprecatedAt.Unix(), 10)
+	}
+	sunsetHeader := ""
+	if !sunset.IsZero() {
+		sunsetHeader = sunset.UTC().Format(http.TimeFormat)
+	}
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("Deprecation", deprecation)
+		if sunsetHeader != "" {
+			header.Set("Sunset", sunsetHeader)
+		}
+		successor := successorPrefix + strings.TrimPrefix(c.Request.URL.Path, prefix)
+		header.Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		c.Next()
+	}
+}
+
+// V2Envelope marks the requests of /api/v2, whose JSON responses respond
+// sends as V2Response. Responses in other content types, such as media
+// downloads, are sent as the handler wrote them.
+func V2Envelope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiVersionKey, 2)
+		c.Next()
+	}
+}
+
+// isV2 tells whether the request came through /api/v2
+func isV2(c *gin.Context) bool {
+	version, _ := c.Get(apiVersionKey)
+	return version == 2
+}
+
+// v2Response is the V2Response of a handler's response and its status.
+// Errors become V2Error, with the message of their "error" field and their
+// other fields as details; internal errors of a request whose deadline
+// passed or whose client went away report 504 or 499, as QueryTimeout
+// answers. List responses put their items in Data, their paging in
+// Pagination and their fields in Meta; any other response is the Data.
+func v2Response(c *gin.Context, status int, body interface{}) (int, V2Response) {
+	if status >= http.StatusBadRequest {
+		if w, ok := c.Writer.(*timeoutWriter); ok {
+			status = contextStatus(w.ctx, status)
+		}
+		return status, V2Response{Error: v2Error(status, body)}
+	}
+
+	list, ok := body.(*listResponse)
+	if !ok {
+		return status, V2Response{Data: body}
+	}
+	page := &V2Pagination{
+		Count:      list.count,
+		NextCursor: list.nextCursor,
+		Total:      list.total,
+		Offset:     list.offset,
+		Limit:      list.limit,
+	}
+	if page.Limit == nil {
+		// the page size the client asked for
+		if limit, err := strconv.ParseInt(c.Query("limit"), 10, 64); err == nil {
+			page.Limit = &limit
+		}
+	}
+	page.HasMore = hasMore(page, list.cursored)
+	data := list.items
+	if list.v2Data != nil {
+		data = list.v2Data(data)
+	}
+	var meta map[string]interface{}
+	if len(list.fields) > 0 {
+		meta = list.fields
+	}
+	return status, V2Response{Data: data, Pagination: page, Meta: meta}
+}
+
+// v2Error is the V2Error of an error response body
+func v2Error(status int, body interface{}) *V2Error {
+	apiErr := &V2Error{Code: v2ErrorCode(status), Status: status, Message: http.StatusText(status)}
+	fields, ok := body.(gin.H)
+	if !ok {
+		return apiErr
+	}
+	for key, value := range fields {
+		if key == "error" {
+			if msg, ok := value.(string); ok {
+				apiErr.Message = msg
+			}
+			continue
+		}
+		if apiErr.Details == nil {
+			apiErr.Details = make(map[string]interface{})
+		}
+		apiErr.Details[key] = value
+	}
+	return apiErr
+}
+
+// hasMore tells whether rows follow the page: from its next cursor for
+// endpoints paged by cursor, else from the total when known or else from
+// whether the page came back full
+func hasMore(page *V2Pagination, cursored bool) *bool {
+	var more bool
+	switch {
+	case cursored:
+		more = page.NextCursor != nil
+	case page.Total != nil:
+		var offset int64
+		if page.Offset != nil {
+			offset = *page.Offset
+		}
+		more = offset+int64(page.Count) < *page.Total
+	case page.Limit != nil && *page.Limit > 0:
+		more = int64(page.Count) >= *page.Limit
+	default:
+		return nil
+	}
+	return &more
+}
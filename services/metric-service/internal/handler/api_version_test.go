@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondTo answers a GET of query through respond, as v2 or v1, with the
+// field mask of fields if any
+func respondTo(t *testing.T, v2 bool, query, fields string, status int, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/"+query, nil)
+	if v2 {
+		c.Set(apiVersionKey, 2)
+	}
+	if fields != "" {
+		mask, err := parseFieldMask(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Set(fieldMaskKey, mask)
+	}
+	respond(c, status, body)
+	return w
+}
+
+func TestRespondV2(t *testing.T) {
+	cursor := "abc"
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   interface{}
+		want   string
+	}{
+		{
+			name:   "error",
+			status: http.StatusNotFound,
+			body:   gin.H{"error": "Run not found", "run_id": "r1"},
+			want:   `{"error":{"code":"not_found","status":404,"message":"Run not found","details":{"run_id":"r1"}}}`,
+		},
+		{
+			name:   "error without message",
+			status: http.StatusGatewayTimeout,
+			body:   nil,
+			want:   `{"error":{"code":"deadline_exceeded","status":504,"message":"Gateway Timeout"}}`,
+		},
+		{
+			name:   "array",
+			status: http.StatusOK,
+			body:   []int{1, 2},
+			want:   `{"data":[1,2]}`,
+		},
+		{
+			name:   "object",
+			status: http.StatusOK,
+			body:   gin.H{"run_id": "r1", "value": 0.5},
+			want:   `{"data":{"run_id":"r1","value":0.5}}`,
+		},
+		{
+			name:   "list with total",
+			status: http.StatusOK,
+			body:   newList("metrics", []int{1, 2}, gin.H{"run_id": "r1"}).withWindow(5, 0, 2),
+			want:   `{"data":[1,2],"pagination":{"count":2,"total":5,"offset":0,"limit":2,"has_more":true},"meta":{"run_id":"r1"}}`,
+		},
+		{
+			name:   "last page by total",
+			status: http.StatusOK,
+			body:   newList("entries", []int{1, 2}, nil).withWindow(4, 2, 2),
+			want:   `{"data":[1,2],"pagination":{"count":2,"total":4,"offset":2,"limit":2,"has_more":false}}`,
+		},
+		{
+			name:   "full page by request limit",
+			query:  "?limit=2",
+			status: http.StatusOK,
+			body:   newList("runs", []int{1, 2}, nil),
+			want:   `{"data":[1,2],"pagination":{"count":2,"limit":2,"has_more":true}}`,
+		},
+		{
+			name:   "unknown paging",
+			status: http.StatusOK,
+			body:   newList("runs", []int{1}, nil),
+			want:   `{"data":[1],"pagination":{"count":1}}`,
+		},
+		{
+			name:   "more rows by cursor",
+			query:  "?limit=2",
+			status: http.StatusOK,
+			body:   newList("metrics", []int{1, 2}, nil).withCursor(&cursor),
+			want:   `{"data":[1,2],"pagination":{"count":2,"limit":2,"next_cursor":"abc","has_more":true}}`,
+		},
+		{
+			name:   "last page by cursor",
+			query:  "?limit=2",
+			status: http.StatusOK,
+			body:   newList("metrics", []int{1, 2}, nil).withCursor(nil),
+			want:   `{"data":[1,2],"pagination":{"count":2,"limit":2,"has_more":false}}`,
+		},
+		{
+			name:   "other lists in meta",
+			status: http.StatusOK,
+			body:   newList("metrics", []int{1}, gin.H{"annotations": []int{2}}),
+			want:   `{"data":[1],"pagination":{"count":1},"meta":{"annotations":[2]}}`,
+		},
+		{
+			name:   "series by name",
+			status: http.StatusOK,
+			body: &listResponse{
+				key:    "series",
+				items:  map[string][]int{"loss": {1}, "acc": {}},
+				count:  1,
+				fields: gin.H{},
+				v2Data: seriesData([]string{"loss", "acc", "lr"}),
+			},
+			want: `{"data":[{"metric_name":"loss","metrics":[1]},{"metric_name":"acc","metrics":[]}],"pagination":{"count":1}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := respondTo(t, true, tt.query, "", tt.status, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			assertJSONEqual(t, w.Body.Bytes(), tt.want)
+		})
+	}
+}
+
+func TestRespondV1(t *testing.T) {
+	cursor := "abc"
+	tests := []struct {
+		name string
+		body interface{}
+		want string
+	}{
+		{
+			name: "object",
+			body: gin.H{"run_id": "r1"},
+			want: `{"run_id":"r1"}`,
+		},
+		{
+			name: "list",
+			body: newList("metrics", []int{1, 2}, gin.H{"run_id": "r1"}).withCursor(&cursor),
+			want: `{"run_id":"r1","metrics":[1,2],"count":2,"next_cursor":"abc"}`,
+		},
+		{
+			name: "list with total",
+			body: newList("entries", []int{1}, nil).withWindow(4, 2, 1),
+			want: `{"entries":[1],"count":1,"total":4,"offset":2,"limit":1}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := respondTo(t, false, "", "", http.StatusOK, tt.body)
+			assertJSONEqual(t, w.Body.Bytes(), tt.want)
+		})
+	}
+}
+
+func TestRespondFieldMask(t *testing.T) {
+	type metric struct {
+		Step  int     `json:"step"`
+		Value float64 `json:"value"`
+	}
+	list := func() *listResponse {
+		return newList("metrics", []metric{{1, 0.5}}, gin.H{"run_id": "r1"}).withCursor(nil)
+	}
+	tests := []struct {
+		name   string
+		v2     bool
+		fields string
+		want   string
+	}{
+		{"v1", false, "metrics.value", `{"metrics":[{"value":0.5}],"count":1,"next_cursor":null}`},
+		{"v2", true, "metrics.value", `{"data":[{"value":0.5}],"pagination":{"count":1,"has_more":false}}`},
+		{"v1 list left out", false, "run_id", `{"run_id":"r1","metrics":[],"count":1,"next_cursor":null}`},
+		{"v2 list left out", true, "run_id", `{"data":[],"pagination":{"count":1,"has_more":false},"meta":{"run_id":"r1"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := respondTo(t, tt.v2, "", tt.fields, http.StatusOK, list())
+			assertJSONEqual(t, w.Body.Bytes(), tt.want)
+		})
+	}
+}
+
+func TestRespondV2TimedOut(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	c.Writer = &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+	c.Set(apiVersionKey, 2)
+
+	respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metrics"})
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+	assertJSONEqual(t, w.Body.Bytes(), `{"error":{"code":"deadline_exceeded","status":504,"message":"Failed to get metrics"}}`)
+}
+
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/arrowipc"
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// arrowBatchRows is the most rows of a streamed Arrow record batch
+const arrowBatchRows = 8192
+
+// nextCursorHeader carries the next_cursor of responses without a JSON envelope
+const nextCursorHeader = "X-Next-Cursor"
+
+// acceptsArrow reports whether the request asks for an Arrow IPC stream
+func acceptsArrow(c *gin.Context) bool {
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(part); err == nil && mediaType == arrowipc.ContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// arrowMetrics answers a metric history as an Arrow IPC stream: a page in one
+// record batch with its next_cursor in X-Next-Cursor, or, with stream=true,
+// the rows in batches as they are read. Headers are sent before the first
+// batch, so streamed responses carry no cursor.
+func (h *MetricHandler) arrowMetrics(c *gin.Context, runID uuid.UUID, params model.MetricQueryParams) {
+	schema := arrowipc.MetricSchema(runID, params.MetricName)
+	if !params.Stream {
+		metrics, err := h.service.GetMetricHistory(c.Request.Context(), runID, params.MetricName, params)
+		if err != nil {
+			if rowLimitExceeded(c, err) || invalidCursor(c, err) {
+				return
+			}
+			h.logger.Error("Failed to get metric history", zap.Error(err))
+			respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metric history"})
+			return
+		}
+		if next := nextCursor(metrics, params.Limit, metricPosition(params)); next != nil {
+			c.Header(nextCursorHeader, *next)
+		}
+		w := arrowipc.NewWriter(c.Writer, schema)
+		batch := w.NewBatch()
+		for _, m := range metrics {
+			arrowipc.AppendMetric(batch, m)
+		}
+		writeArrowBatch(c, w, batch)
+		return
+	}
+
+	var w *arrowipc.Writer
+	var batch *arrowipc.Batch
+	err := h.service.StreamRunMetrics(c.Request.Context(), runID, params, func(m model.Metric) error {
+		if w == nil {
+			c.Header("Content-Type", arrowipc.ContentType)
+			c.Status(http.StatusOK)
+			w = arrowipc.NewWriter(c.Writer, schema)
+			batch = w.NewBatch()
+		}
+		arrowipc.AppendMetric(batch, m)
+		if batch.Rows() < arrowBatchRows {
+			return nil
+		}
+		if err := w.Write(batch); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		batch = w.NewBatch()
+		return nil
+	})
+	if err != nil {
+		if w == nil && invalidCursor(c, err) {
+			return
+		}
+		h.logger.Error("Failed to stream metrics", zap.Error(err))
+		if w == nil {
+			respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metrics"})
+		}
+		// A stream cut short lacks its end marker, which readers report
+		return
+	}
+	if w == nil {
+		c.Header("Content-Type", arrowipc.ContentType)
+		c.Status(http.StatusOK)
+		w = arrowipc.NewWriter(c.Writer, schema)
+		batch = w.NewBatch()
+	}
+	if batch.Rows() > 0 && w.Write(batch) != nil {
+		return
+	}
+	w.Close()
+}
+
+// writeArrowSummaries answers a comparison of runs as one Arrow record batch
+func writeArrowSummaries(c *gin.Context, params model.SummaryQueryParams, summaries []model.RunMetricSummary) {
+	w := arrowipc.NewWriter(c.Writer, arrowipc.Schema{
+		Fields: []arrowipc.Field{
+			{Name: "run_id", Type: arrowipc.String},
+			{Name: "goal", Type: arrowipc.String},
+			{Name: "final_value", Type: arrowipc.Float64},
+			{Name: "final_step", Type: arrowipc.Int64},
+			{Name: "final_time", Type: arrowipc.Timestamp},
+			{Name: "best_value", Type: arrowipc.Float64},
+			{Name: "best_step", Type: arrowipc.Int64},
+			{Name: "best_time", Type: arrowipc.Timestamp},
+			{Name: "count", Type: arrowipc.Int64},
+			{Name: "updated_at", Type: arrowipc.Timestamp},
+		},
+		Metadata: map[string]string{"metric_name": params.MetricName, "sort": params.Sort},
+	})
+	b := w.NewBatch()
+	for _, s := range summaries {
+		b.Column(0).AppendString(s.RunID.String())
+		b.Column(1).AppendString(s.Goal)
+		b.Column(2).AppendFloat64(s.FinalValue)
+		appendOptionalInt64(b.Column(3), s.FinalStep)
+		b.Column(4).AppendTime(s.FinalTime)
+		b.Column(5).AppendFloat64(s.BestValue)
+		appendOptionalInt64(b.Column(6), s.BestStep)
+		b.Column(7).AppendTime(s.BestTime)
+		b.Column(8).AppendInt64(s.Count)
+		b.Column(9).AppendTime(s.UpdatedAt)
+	}
+	writeArrowBatch(c, w, b)
+}
+
+// writeArrowGroupSummaries answers a comparison of run groups as one Arrow
+// record batch
+func writeArrowGroupSummaries(c *gin.Context, params model.SummaryQueryParams, groups []model.GroupMetricSummary) {
+	w := arrowipc.NewWriter(c.Writer, arrowipc.Schema{
+		Fields: []arrowipc.Field{
+			{Name: "group", Type: arrowipc.String},
+			{Name: "goal", Type: arrowipc.String},
+			{Name: "run_count", Type: arrowipc.Int64},
+			{Name: "mean", Type: arrowipc.Float64},
+			{Name: "std_dev", Type: arrowipc.Float64},
+			{Name: "min", Type: arrowipc.Float64},
+			{Name: "max", Type: arrowipc.Float64},
+			{Name: "best_run_id", Type: arrowipc.String},
+		},
+		Metadata: map[string]string{"metric_name": params.MetricName, "sort": params.Sort, "group_by": params.GroupBy},
+	})
+	b := w.NewBatch()
+	for _, g := range groups {
+		b.Column(0).AppendString(g.Group)
+		b.Column(1).AppendString(g.Goal)
+		b.Column(2).AppendInt64(g.RunCount)
+		b.Column(3).AppendFloat64(g.Mean)
+		if g.StdDev != nil {
+			b.Column(4).AppendFloat64(*g.StdDev)
+		} else {
+			b.Column(4).AppendNull()
+		}
+		b.Column(5).AppendFloat64(g.Min)
+		b.Column(6).AppendFloat64(g.Max)
+		b.Column(7).AppendString(g.BestRunID.String())
+	}
+	writeArrowBatch(c, w, b)
+}
+
+func appendOptionalInt64(col *arrowipc.Column, v *int64) {
+	if v != nil {
+		col.AppendInt64(*v)
+	} else {
+		col.AppendNull()
+	}
+}
+
+// writeArrowBatch answers 200 with a stream of one batch
+func writeArrowBatch(c *gin.Context, w *arrowipc.Writer, b *arrowipc.Batch) {
+	c.Header("Content-Type", arrowipc.ContentType)
+	c.Status(http.StatusOK)
+	if err := w.Write(b); err == nil {
+		w.Close()
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/wanllmdb/metric-service/internal/arrowipc"
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestAcceptsArrow(t *testing.T) {
+	tests := map[string]bool{
+		"":                                    false,
+		"application/json":                    false,
+		"application/vnd.apache.arrow.stream": true,
+		"application/json;q=0.5, application/vnd.apache.arrow.stream": true,
+		"application/vnd.apache.arrow.file":                           false,
+		"*/*":                                                         false,
+	}
+	for header, want := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.Header.Set("Accept", header)
+		if got := acceptsArrow(c); got != want {
+			t.Errorf("acceptsArrow(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestWriteArrowSummaries(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	step := int64(100)
+	now := time.Now()
+	writeArrowSummaries(c, model.SummaryQueryParams{MetricName: "val/loss", Sort: "best"}, []model.RunMetricSummary{
+		{RunID: uuid.New(), Goal: "minimize", FinalValue: 0.3, FinalStep: &step, FinalTime: now, BestValue: 0.2, BestTime: now, Count: 10, UpdatedAt: now},
+		{RunID: uuid.New(), Goal: "minimize", FinalValue: 0.5, FinalTime: now, BestValue: 0.4, BestTime: now, Count: 3, UpdatedAt: now},
+	})
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != arrowipc.ContentType {
+		t.Fatalf("status %d with Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.Bytes()
+	marker := []byte{0xff, 0xff, 0xff, 0xff}
+	if !bytes.HasPrefix(body, marker) || !bytes.HasSuffix(body, append(marker, 0, 0, 0, 0)) {
+		t.Errorf("body is not an Arrow stream ended by its end marker")
+	}
+	for _, name := range []string{"val/loss", "run_id", "final_value", "updated_at"} {
+		if !bytes.Contains(body, []byte(name)) {
+			t.Errorf("schema lacks %q", name)
+		}
+	}
+}
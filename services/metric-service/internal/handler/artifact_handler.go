@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type ArtifactHandler struct {
+	service *service.ArtifactService
+	logger  *zap.Logger
+}
+
+func NewArtifactHandler(service *service.ArtifactService, logger *zap.Logger) *ArtifactHandler {
+	return &ArtifactHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// CreateArtifact registers a checkpoint or dataset reference on a run
+func (h *ArtifactHandler) CreateArtifact(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.CreateArtifactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	artifact, err := h.service.CreateArtifact(c.Request.Context(), runID, req)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to create artifact", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to create artifact"})
+		return
+	}
+
+	respond(c, http.StatusCreated, artifact)
+}
+
+// GetRunArtifacts lists the artifacts of a run
+func (h *ArtifactHandler) GetRunArtifacts(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.ArtifactQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 1000
+	}
+
+	artifacts, err := h.service.GetRunArtifacts(c.Request.Context(), runID, params)
+	if err != nil {
+		h.logger.Error("Failed to get artifacts", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get artifacts"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("artifacts", artifacts, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// LookupArtifact answers "which checkpoint corresponds to the best val/accuracy"
+func (h *ArtifactHandler) LookupArtifact(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.ArtifactLookupParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if params.Step == nil && params.MetricName == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Either step or metric_name is required"})
+		return
+	}
+
+	artifact, step, err := h.service.LookupArtifact(c.Request.Context(), runID, params)
+	if err != nil {
+		h.logger.Error("Failed to look up artifact", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to look up artifact"})
+		return
+	}
+	if artifact == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "No matching artifact found"})
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"run_id":      runID,
+		"metric_name": params.MetricName,
+		"target_step": step,
+		"artifact":    artifact,
+	})
+}
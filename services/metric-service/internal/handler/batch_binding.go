@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// bindBatchJSON binds a batch request body as ShouldBindJSON does. When
+// binding fails on the items of the batch, the error is a
+// *service.ValidationError listing each invalid item.
+func bindBatchJSON(c *gin.Context, obj interface{}) error {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	return bindBatchBody(body, obj)
+}
+
+func bindBatchBody(body []byte, obj interface{}) error {
+	err := binding.JSON.BindBody(body, obj)
+	if err == nil {
+		return nil
+	}
+	noun, items := batchItemErrors(body, obj)
+	if len(items) == 0 {
+		return err
+	}
+	return service.NewBatchValidationError(noun, items)
+}
+
+// badRequest answers 400 for an invalid request body, listing the invalid
+// items of batch requests under "errors", and 413 for one cut off at its
+// size limit
+func badRequest(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respond(c, http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large", "max_bytes": maxBytesErr.Limit})
+		return
+	}
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) && len(validationErr.Items) > 0 {
+		respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error(), "errors": validationErr.Items})
+		return
+	}
+	respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// batchItemErrors decodes and validates the items of the batch field of obj,
+// its first field holding a list of structs, one by one, so that errors can
+// be told apart by item. It returns the batch's noun, taken from its JSON
+// name, with the errors.
+func batchItemErrors(body []byte, obj interface{}) (string, []service.ItemError) {
+	t := reflect.TypeOf(obj)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return "", nil
+	}
+	var list reflect.StructField
+	found := false
+	for _, f := range reflect.VisibleFields(t.Elem()) {
+		if f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Struct && jsonName(f) != "" {
+			list, found = f, true
+			break
+		}
+	}
+	if !found {
+		return "", nil
+	}
+
+	var fields map[string]json.RawMessage
+	var raws []json.RawMessage
+	if json.Unmarshal(body, &fields) != nil || json.Unmarshal(fields[jsonName(list)], &raws) != nil {
+		return "", nil
+	}
+
+	itemType := list.Type.Elem()
+	var items []service.ItemError
+	for i, raw := range raws {
+		item := reflect.New(itemType)
+		if err := json.Unmarshal(raw, item.Interface()); err != nil {
+			items = append(items, decodeItemError(i, err))
+			continue
+		}
+		var verrs validator.ValidationErrors
+		if err := binding.Validator.ValidateStruct(item.Interface()); errors.As(err, &verrs) {
+			for _, fe := range verrs {
+				items = append(items, validationItemError(i, itemType, fe))
+			}
+		}
+	}
+	return singular(jsonName(list)), items
+}
+
+// decodeItemError describes an item that is not valid JSON for its type
+func decodeItemError(index int, err error) service.ItemError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return service.ItemError{
+			Index:  index,
+			Field:  typeErr.Field,
+			Reason: fmt.Sprintf("%s must be a %s, not a %s", typeErr.Field, jsonTypeName(typeErr.Type), typeErr.Value),
+		}
+	}
+	return service.ItemError{Index: index, Reason: err.Error()}
+}
+
+// validationItemError describes a field of an item failing a binding rule
+func validationItemError(index int, itemType reflect.Type, fe validator.FieldError) service.ItemError {
+	field := fe.Field()
+	if f, ok := itemType.FieldByName(fe.StructField()); ok && jsonName(f) != "" {
+		field = jsonName(f)
+	}
+
+	var reason string
+	switch fe.Tag() {
+	case "required":
+		reason = "is required"
+	case "min", "gte":
+		reason = "must be at least " + fe.Param()
+	case "max", "lte":
+		reason = "must be at most " + fe.Param()
+	case "oneof":
+		reason = "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	default:
+		reason = fmt.Sprintf("fails the %s rule", fe.Tag())
+	}
+	return service.ItemError{Index: index, Field: field, Reason: field + " " + reason}
+}
+
+// jsonName returns the name of a field in JSON, or "" for fields left out
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// jsonTypeName names the JSON type a Go type is decoded from
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return "string"
+}
+
+// singular turns the JSON name of a batch into the noun of its items, e.g.
+// metrics into metric
+func singular(name string) string {
+	return strings.TrimSuffix(name, "s")
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type CheckpointHandler struct {
+	service *service.CheckpointService
+	logger  *zap.Logger
+}
+
+func NewCheckpointHandler(service *service.CheckpointService, logger *zap.Logger) *CheckpointHandler {
+	return &CheckpointHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// RegisterCheckpoint records the checkpoint saved at a step of a run
+func (h *CheckpointHandler) RegisterCheckpoint(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.RegisterCheckpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.service.RegisterCheckpoint(c.Request.Context(), runID, req)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to register checkpoint", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to register checkpoint"})
+		return
+	}
+
+	respond(c, http.StatusCreated, resp)
+}
+
+// GetObjectives lists the objectives a run's checkpoints are ranked by
+func (h *CheckpointHandler) GetObjectives(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	objectives, err := h.service.GetObjectives(c.Request.Context(), runID)
+	if err != nil {
+		h.logger.Error("Failed to get checkpoint objectives", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get checkpoint objectives"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("objectives", objectives, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// SetObjectives adds or updates the objectives a run's checkpoints are ranked by
+func (h *CheckpointHandler) SetObjectives(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.SetCheckpointObjectivesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.SetObjectives(c.Request.Context(), runID, req.Objectives); err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to set checkpoint objectives", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to set checkpoint objectives"})
+		return
+	}
+
+	h.GetObjectives(c)
+}
+
+// GetBestCheckpoints retrieves the best checkpoint so far of each objective
+func (h *CheckpointHandler) GetBestCheckpoints(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	metricName := c.Query("metric_name")
+	best, err := h.service.GetBestCheckpoints(c.Request.Context(), runID, metricName)
+	if err != nil {
+		h.logger.Error("Failed to get best checkpoints", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get best checkpoints"})
+		return
+	}
+	if metricName != "" && len(best) == 0 {
+		respond(c, http.StatusNotFound, gin.H{"error": "No best checkpoint for metric"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("best", best, gin.H{
+		"run_id": runID,
+	}))
+}
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressibleTypes are the content type prefixes worth compressing
+var compressibleTypes = []string{
+	"application/json",
+	"application/x-ndjson",
+	"application/problem+json",
+	"application/xml",
+	"application/vnd.apache.arrow.stream", // written without IPC compression
+	"text/",
+}
+
+// encoder is a pooled compressor, a gzip or zstd writer
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encodings are the content encodings offered, in order of preference when
+// the client rates them alike
+var encodings = []string{"zstd", "gzip"}
+
+// Compression compresses response bodies with zstd or gzip, whichever the
+// client's Accept-Encoding rates higher, preferring zstd on a tie. level is a
+// gzip level; zstd uses the nearest of its own. Bodies shorter than minSize,
+// bodies in other content types (such as media), bodies the handler already
+// encoded or sized, and WebSocket and server-sent event requests are sent as
+// they are. Streamed bodies stay streamed: Flush flushes the compressor.
+func Compression(level, minSize int) gin.HandlerFunc {
+	pools := map[string]*sync.Pool{
+		"gzip": {
+			New: func() interface{} {
+				// The level is validated by the configuration
+				gz, _ := gzip.NewWriterLevel(io.Discard, level)
+				return gz
+			},
+		},
+		"zstd": {
+			New: func() interface{} {
+				// One goroutine per response; the 8 MB window stays within what
+				// browsers decode
+				zw, _ := zstd.NewWriter(nil,
+					zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)),
+					zstd.WithEncoderConcurrency(1),
+					zstd.WithWindowSize(8<<20))
+				return zw
+			},
+		},
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead ||
+			c.GetHeader("Upgrade") != "" ||
+			strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+			c.Next()
+			return
+		}
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		// Caches must keep the encodings apart even when this one goes uncompressed
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, pool: pools[encoding], minSize: minSize}
+		c.Writer = w
+		defer func() {
+			w.finish()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// negotiateEncoding picks the offered encoding an Accept-Encoding header
+// rates highest, or "" when it allows none
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		if q := encodingQuality(header, encoding); q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// encodingQuality is the quality an Accept-Encoding header gives encoding,
+// named or through "*", zero when it does not allow it
+func encodingQuality(header, encoding string) float64 {
+	wildcard := 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != encoding && name != "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if name == encoding {
+			return q
+		}
+		wildcard = q
+	}
+	return wildcard
+}
+
+// compressWriter holds back the start of the body until it knows whether to
+// compress it: once minSize bytes are written, on Flush, or when the handler
+// is done
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	pool     *sync.Pool
+	minSize  int
+
+	decided bool
+	enc     encoder
+	buf     []byte
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	if !w.compressible() {
+		w.decided = true
+		return w.ResponseWriter.Write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends what was written so far, compressing it if it may be
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(w.compressible())
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// WriteHeaderNow sends the headers before any body, which leaves the body
+// uncompressed
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.Hijack()
+}
+
+// compressible tells from the headers the handler set whether the body may
+// be compressed
+func (w *compressWriter) compressible() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Length") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// decide starts the response compressed or not and sends the held back bytes
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if compress {
+		header := w.Header()
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.enc = w.pool.Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// finish sends the rest of the body once the handler is done
+func (w *compressWriter) finish() {
+	if !w.decided {
+		// A body held back in full is shorter than minSize
+		w.decide(false)
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(io.Discard)
+		w.pool.Put(w.enc)
+		w.enc = nil
+	}
+}
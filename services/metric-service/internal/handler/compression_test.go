@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"identity":                  "",
+		"gzip":                      "gzip",
+		"zstd":                      "zstd",
+		"gzip, deflate, br, zstd":   "zstd",
+		"gzip;q=1.0, zstd;q=0.5":    "gzip",
+		"zstd;q=0, gzip":            "gzip",
+		"gzip;q=0":                  "",
+		"*":                         "zstd",
+		"*;q=0.5, gzip":             "gzip",
+		"GZIP":                      "gzip",
+		"zstd;q=0, gzip;q=0, *":     "",
+		"br;q=1.0, gzip;q=0.8, *;q": "zstd",
+	}
+	for header, want := range tests {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat(`{"metric_name":"loss","value":0.5}`, 100)
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		wantEncoding   string
+	}{
+		{"gzip", "gzip", "application/json", large, "gzip"},
+		{"zstd", "gzip, zstd", "application/json", large, "zstd"},
+		{"not accepted", "", "application/json", large, ""},
+		{"short body", "zstd", "application/json", "{}", ""},
+		{"media", "zstd", "image/png", large, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(Compression(5, 1024))
+			router.GET("/", func(c *gin.Context) {
+				c.Data(http.StatusOK, tt.contentType, []byte(tt.body))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if got := decodeBody(t, tt.wantEncoding, w.Body.Bytes()); got != tt.body {
+				t.Fatalf("decoded body has %d bytes, want %d", len(got), len(tt.body))
+			}
+		})
+	}
+}
+
+func decodeBody(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader = bytes.NewReader(body)
+	switch encoding {
+	case "gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	case "zstd":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(decoded)
+}
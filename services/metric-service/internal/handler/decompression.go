@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// decoder is a pooled decompressor, a gzip or zstd reader
+type decoder interface {
+	io.Reader
+	Reset(r io.Reader) error
+}
+
+// zstdDecoder adapts a zstd decoder to decoder
+type zstdDecoder struct {
+	*zstd.Decoder
+}
+
+func (d zstdDecoder) Reset(r io.Reader) error {
+	return d.Decoder.Reset(r)
+}
+
+// Decompression decodes request bodies sent with a gzip or zstd
+// Content-Encoding, so that clients may compress large batches. The decoded
+// body is capped at maxSize bytes, against bodies that expand without bound;
+// reading past it fails as http.MaxBytesReader does. Other encodings are
+// refused with 415.
+func Decompression(maxSize int64) gin.HandlerFunc {
+	pools := map[string]*sync.Pool{
+		"gzip": {
+			New: func() interface{} {
+				return new(gzip.Reader)
+			},
+		},
+		"zstd": {
+			New: func() interface{} {
+				// One goroutine per request, and no larger window than the
+				// encoders of the SDKs use
+				zr, _ := zstd.NewReader(nil,
+					zstd.WithDecoderConcurrency(1),
+					zstd.WithDecoderLowmem(true),
+					zstd.WithDecoderMaxWindow(8<<20))
+				return zstdDecoder{zr}
+			},
+		},
+	}
+
+	return func(c *gin.Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+		if encoding == "" || encoding == "identity" {
+			c.Next()
+			return
+		}
+		pool, ok := pools[encoding]
+		if !ok {
+			abortWithJSON(c, http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported Content-Encoding, want gzip or zstd"})
+			return
+		}
+
+		dec := pool.Get().(decoder)
+		if err := dec.Reset(c.Request.Body); err != nil {
+			// gzip reads its header on Reset
+			pool.Put(dec)
+			abortWithJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid " + encoding + " body"})
+			return
+		}
+		defer pool.Put(dec)
+
+		body := c.Request.Body
+		c.Request.Body = http.MaxBytesReader(c.Writer, io.NopCloser(dec), maxSize)
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Del("Content-Length")
+		c.Request.ContentLength = -1
+		defer body.Close()
+		c.Next()
+	}
+}
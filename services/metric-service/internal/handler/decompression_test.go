@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestDecompression(t *testing.T) {
+	body := strings.Repeat(`{"metric_name":"loss","value":0.5}`, 100)
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(body))
+	gw.Close()
+	zw, _ := zstd.NewWriter(nil)
+	zs := zw.EncodeAll([]byte(body), nil)
+
+	tests := []struct {
+		name     string
+		encoding string
+		data     []byte
+		maxSize  int64
+		want     int
+	}{
+		{"identity", "", []byte(body), 1 << 20, http.StatusOK},
+		{"gzip", "gzip", gz.Bytes(), 1 << 20, http.StatusOK},
+		{"zstd", "zstd", zs, 1 << 20, http.StatusOK},
+		{"unsupported", "br", gz.Bytes(), 1 << 20, http.StatusUnsupportedMediaType},
+		{"corrupt", "gzip", []byte("not gzip"), 1 << 20, http.StatusBadRequest},
+		{"too large", "zstd", zs, int64(len(body) - 1), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/", Decompression(tt.maxSize), func(c *gin.Context) {
+				data, err := io.ReadAll(c.Request.Body)
+				if err != nil {
+					badRequest(c, err)
+					return
+				}
+				if string(data) != body {
+					t.Errorf("handler read %d bytes, want the %d decoded", len(data), len(body))
+				}
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.data))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
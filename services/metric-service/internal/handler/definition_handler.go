@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type DefinitionHandler struct {
+	service *service.DefinitionService
+	logger  *zap.Logger
+}
+
+func NewDefinitionHandler(service *service.DefinitionService, logger *zap.Logger) *DefinitionHandler {
+	return &DefinitionHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// ListDefinitions lists the metric definitions of a project
+func (h *DefinitionHandler) ListDefinitions(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("project_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	defs, err := h.service.ListDefinitions(c.Request.Context(), projectID)
+	if err != nil {
+		h.logger.Error("Failed to list metric definitions", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list metric definitions"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("definitions", defs, gin.H{
+		"project_id": projectID,
+	}))
+}
+
+// SaveDefinition creates or replaces a metric definition
+func (h *DefinitionHandler) SaveDefinition(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("project_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var req model.MetricDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	def, err := h.service.SaveDefinition(c.Request.Context(), projectID, req)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to save metric definition", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to save metric definition"})
+		return
+	}
+
+	respond(c, http.StatusOK, def)
+}
+
+// GetDefinition retrieves one metric definition. The name is a catch-all
+// parameter so hierarchical names such as val/loss need no escaping.
+func (h *DefinitionHandler) GetDefinition(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("project_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	name := strings.TrimPrefix(c.Param("name"), "/")
+	def, err := h.service.GetDefinition(c.Request.Context(), projectID, name)
+	if err != nil {
+		h.logger.Error("Failed to get metric definition", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metric definition"})
+		return
+	}
+	if def == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Metric definition not found"})
+		return
+	}
+
+	respond(c, http.StatusOK, def)
+}
+
+// DeleteDefinition removes a metric definition
+func (h *DefinitionHandler) DeleteDefinition(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("project_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	name := strings.TrimPrefix(c.Param("name"), "/")
+	deleted, err := h.service.DeleteDefinition(c.Request.Context(), projectID, name)
+	if err != nil {
+		h.logger.Error("Failed to delete metric definition", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete metric definition"})
+		return
+	}
+	if !deleted {
+		respond(c, http.StatusNotFound, gin.H{"error": "Metric definition not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type EarlyStoppingHandler struct {
+	service *service.EarlyStoppingService
+	logger  *zap.Logger
+}
+
+func NewEarlyStoppingHandler(service *service.EarlyStoppingService, logger *zap.Logger) *EarlyStoppingHandler {
+	return &EarlyStoppingHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// ShouldStop tells a training loop whether it should halt
+func (h *EarlyStoppingHandler) ShouldStop(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.ShouldStopParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Patience > 0 && params.MetricName == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "metric_name is required when patience is set"})
+		return
+	}
+
+	resp, err := h.service.ShouldStop(c.Request.Context(), runID, params)
+	if err != nil {
+		h.logger.Error("Failed to evaluate stop criteria", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to evaluate stop criteria"})
+		return
+	}
+
+	respond(c, http.StatusOK, resp)
+}
+
+// RequestStop sets the manual stop flag for a run
+func (h *EarlyStoppingHandler) RequestStop(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.StopRequest
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.service.SetStopFlag(c.Request.Context(), runID, req.Reason); err != nil {
+		h.logger.Error("Failed to set stop flag", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to set stop flag"})
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"run_id": runID, "manual_stop": true})
+}
+
+// ClearStop removes the manual stop flag for a run
+func (h *EarlyStoppingHandler) ClearStop(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	if err := h.service.ClearStopFlag(c.Request.Context(), runID); err != nil {
+		h.logger.Error("Failed to clear stop flag", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to clear stop flag"})
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"run_id": runID, "manual_stop": false})
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type EmbeddingHandler struct {
+	service *service.EmbeddingService
+	logger  *zap.Logger
+}
+
+func NewEmbeddingHandler(service *service.EmbeddingService, logger *zap.Logger) *EmbeddingHandler {
+	return &EmbeddingHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// BatchWrite handles batch embedding writing
+func (h *EmbeddingHandler) BatchWrite(c *gin.Context) {
+	var req model.EmbeddingBatchRequest
+	if err := bindBatchJSON(c, &req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	runIDs := make([]uuid.UUID, len(req.Embeddings))
+	for i, e := range req.Embeddings {
+		runIDs[i] = e.RunID
+	}
+	if !checkRuns(c, runIDs) {
+		return
+	}
+
+	if err := h.service.BatchWrite(c.Request.Context(), req.Embeddings); err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to write embeddings", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to write embeddings"})
+		return
+	}
+
+	respond(c, http.StatusCreated, gin.H{
+		"message": "Embeddings written successfully",
+		"count":   len(req.Embeddings),
+	})
+}
+
+// ListEmbeddings lists the embedding metrics logged in a run
+func (h *EmbeddingHandler) ListEmbeddings(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	series, err := h.service.ListEmbeddingSeries(c.Request.Context(), runID)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to list embeddings", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list embeddings"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("embeddings", series, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// GetEmbeddings retrieves the embeddings of a metric
+func (h *EmbeddingHandler) GetEmbeddings(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.EmbeddingQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 500
+	}
+
+	metricName := c.Param("metric_name")
+	embeddings, err := h.service.GetEmbeddings(c.Request.Context(), runID, metricName, params)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to get embeddings", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get embeddings"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("embeddings", embeddings, gin.H{
+		"run_id":      runID,
+		"metric_name": metricName,
+	}))
+}
+
+// Nearest finds the embeddings of a metric closest to a vector or logged key
+func (h *EmbeddingHandler) Nearest(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.NearestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.Nearest(c.Request.Context(), runID, c.Param("metric_name"), req)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to search embeddings", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to search embeddings"})
+		return
+	}
+	if result == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Embedding not found"})
+		return
+	}
+
+	respond(c, http.StatusOK, result)
+}
+
+// GetDrift follows the centroid of a metric's embeddings across steps
+func (h *EmbeddingHandler) GetDrift(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.EmbeddingDriftParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 1000
+	}
+
+	metricName := c.Param("metric_name")
+	points, err := h.service.Drift(c.Request.Context(), runID, metricName, params)
+	if err != nil {
+		h.logger.Error("Failed to compute embedding drift", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to compute embedding drift"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("drift", points, gin.H{
+		"run_id":      runID,
+		"metric_name": metricName,
+	}))
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/export"
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type ExportHandler struct {
+	manager *export.Manager
+	logger  *zap.Logger
+}
+
+func NewExportHandler(manager *export.Manager, logger *zap.Logger) *ExportHandler {
+	return &ExportHandler{
+		manager: manager,
+		logger:  logger,
+	}
+}
+
+// ListExports reports the latest export of each exported run
+func (h *ExportHandler) ListExports(c *gin.Context) {
+	var params model.RunExportQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 100
+	}
+
+	exports, err := h.manager.List(c.Request.Context(), params)
+	if err != nil {
+		h.logger.Error("Failed to list run exports", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list run exports"})
+		return
+	}
+
+	respond(c, http.StatusOK, exports)
+}
+
+// ExportRun exports the metrics of an ended run now
+func (h *ExportHandler) ExportRun(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	result, err := h.manager.ExportRun(c.Request.Context(), runID)
+	if errors.Is(err, export.ErrRunNotEnded) {
+		respond(c, http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to export run", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to export run"})
+		return
+	}
+
+	respond(c, http.StatusOK, result)
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fieldMaskKey holds the fieldMask of a masked request in the gin context
+const fieldMaskKey = "field_mask"
+
+// fieldMask is a parsed ?fields= mask: the fields kept at each level of a
+// JSON document, by name or by "*" for any name. A nil mask keeps the whole
+// value.
+type fieldMask map[string]fieldMask
+
+// parseFieldMask parses a comma-separated list of dotted field paths, such
+// as "run_id,metrics.step,metrics.value". A path keeps the field it names
+// whole, so "metrics,metrics.value" keeps every field of metrics.
+func parseFieldMask(value string) (fieldMask, error) {
+	mask := fieldMask{}
+	for _, path := range strings.Split(value, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		segments := strings.Split(path, ".")
+		node := mask
+		for i, segment := range segments {
+			if segment == "" {
+				return nil, fmt.Errorf("invalid field path %q", path)
+			}
+			child, seen := node[segment]
+			if i == len(segments)-1 {
+				node[segment] = nil
+				break
+			}
+			if seen && child == nil {
+				// already kept whole
+				break
+			}
+			if child == nil {
+				child = fieldMask{}
+				node[segment] = child
+			}
+			node = child
+		}
+	}
+	if len(mask) == 0 {
+		return nil, fmt.Errorf("fields names no field")
+	}
+	return mask, nil
+}
+
+// sub returns the mask of a field and whether the field is kept at all
+func (m fieldMask) sub(name string) (fieldMask, bool) {
+	if child, ok := m[name]; ok {
+		return child, true
+	}
+	child, ok := m["*"]
+	return child, ok
+}
+
+// apply returns the fields of a decoded JSON value the mask keeps. Masks
+// apply to each element of arrays; scalars are kept as they are.
+func (m fieldMask) apply(value interface{}) interface{} {
+	if m == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		kept := make(map[string]interface{}, len(m))
+		for key, field := range v {
+			if child, ok := m.sub(key); ok {
+				kept[key] = child.apply(field)
+			}
+		}
+		return kept
+	case []interface{}:
+		for i, element := range v {
+			v[i] = m.apply(element)
+		}
+		return v
+	}
+	return value
+}
+
+// applyJSON masks an encoded JSON value
+func (m fieldMask) applyJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m.apply(decoded))
+}
+
+// applyValue masks a value as it encodes to JSON
+func (m fieldMask) applyValue(value interface{}) (interface{}, error) {
+	if m == nil {
+		return value, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return m.apply(decoded), nil
+}
+
+// shape masks a response body. A list response keeps its paging, so that
+// trimmed lists can still be paged, and keeps the list itself empty when the
+// mask leaves it out, as its count still tells the rows.
+func (m fieldMask) shape(body interface{}) (interface{}, error) {
+	list, ok := body.(*listResponse)
+	if !ok {
+		return m.applyValue(body)
+	}
+	fields, err := m.applyValue(list.fields)
+	if err != nil {
+		return nil, err
+	}
+	shaped := *list
+	shaped.fields, _ = fields.(map[string]interface{})
+	if itemMask, kept := m.sub(list.key); kept {
+		if shaped.items, err = itemMask.applyValue(list.items); err != nil {
+			return nil, err
+		}
+	} else {
+		shaped.items = []interface{}{}
+	}
+	return &shaped, nil
+}
+
+// requestFieldMask returns the field mask of the request, if it has one
+func requestFieldMask(c *gin.Context) (fieldMask, bool) {
+	mask, ok := c.Get(fieldMaskKey)
+	if !ok {
+		return nil, false
+	}
+	return mask.(fieldMask), true
+}
+
+// FieldMask trims the JSON responses of GET requests to the fields named by
+// ?fields=, a comma-separated list of dotted paths into the response such as
+// "run_id,metrics.step,metrics.value"; "*" matches any field name. Paths
+// name fields of the v1 response shape, so a mask means the same in every
+// API version. The paging fields of list responses (count, next_cursor,
+// total, offset, limit) are always kept. Error responses and responses in
+// other content types pass untouched. The mask is applied by respond and
+// jsonStream, as the response is written.
+func FieldMask() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fields := c.Query("fields")
+		if fields == "" || c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		mask, err := parseFieldMask(fields)
+		if err != nil {
+			abortWithJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(fieldMaskKey, mask)
+		c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type ForecastHandler struct {
+	service *service.ForecastService
+	logger  *zap.Logger
+}
+
+func NewForecastHandler(service *service.ForecastService, logger *zap.Logger) *ForecastHandler {
+	return &ForecastHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetForecast projects when a metric will cross ?target, e.g. the ETA to loss < 2.0
+func (h *ForecastHandler) GetForecast(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+	metricName := c.Param("metric_name")
+
+	var params model.ForecastParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	forecast, err := h.service.Forecast(c.Request.Context(), runID, metricName, params)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to forecast metric", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to forecast metric"})
+		return
+	}
+	if forecast == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Not enough history to forecast"})
+		return
+	}
+
+	respond(c, http.StatusOK, forecast)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type GPUHandler struct {
+	service *service.GPUService
+	logger  *zap.Logger
+}
+
+func NewGPUHandler(service *service.GPUService, logger *zap.Logger) *GPUHandler {
+	return &GPUHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// BatchWrite handles batch GPU metric writing
+func (h *GPUHandler) BatchWrite(c *gin.Context) {
+	var req model.GPUMetricBatchRequest
+	if err := bindBatchJSON(c, &req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	runIDs := make([]uuid.UUID, len(req.Metrics))
+	for i, m := range req.Metrics {
+		runIDs[i] = m.RunID
+	}
+	if !checkRuns(c, runIDs) {
+		return
+	}
+
+	if err := h.service.BatchWrite(c.Request.Context(), req.Metrics); err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to write GPU metrics", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to write GPU metrics"})
+		return
+	}
+
+	respond(c, http.StatusCreated, gin.H{
+		"message": "GPU metrics written successfully",
+		"count":   len(req.Metrics),
+	})
+}
+
+// GetGPUMetrics retrieves GPU samples of a run grouped per device
+func (h *GPUHandler) GetGPUMetrics(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.GPUMetricQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 1000
+	}
+
+	devices, err := h.service.GetDeviceSeries(c.Request.Context(), runID, params)
+	if err != nil {
+		h.logger.Error("Failed to get GPU metrics", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get GPU metrics"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("devices", devices, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// GetGPUSummary aggregates utilization, memory, temperature and power per device
+func (h *GPUHandler) GetGPUSummary(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	devices, err := h.service.GetGPUSummary(c.Request.Context(), runID)
+	if err != nil {
+		h.logger.Error("Failed to get GPU summary", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get GPU summary"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("devices", devices, gin.H{
+		"run_id": runID,
+	}))
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// GrafanaHandler serves the Grafana JSON datasource contract: the
+// datasource URL is /api/v1/grafana, under which Grafana calls /search,
+// /query and /annotations
+type GrafanaHandler struct {
+	service *service.GrafanaService
+	logger  *zap.Logger
+}
+
+func NewGrafanaHandler(service *service.GrafanaService, logger *zap.Logger) *GrafanaHandler {
+	return &GrafanaHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// TestConnection answers the datasource test Grafana runs when it is saved
+func (h *GrafanaHandler) TestConnection(c *gin.Context) {
+	respond(c, http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Search lists the targets matching a <run_id>[:<metric name prefix>] query
+func (h *GrafanaHandler) Search(c *gin.Context) {
+	var req model.GrafanaSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	targets, err := h.service.Search(c.Request.Context(), req.Target)
+	if err != nil {
+		h.logger.Error("Failed to search Grafana targets", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to search targets"})
+		return
+	}
+
+	respond(c, http.StatusOK, targets)
+}
+
+// Query answers the targets of a panel with time series or tables
+func (h *GrafanaHandler) Query(c *gin.Context) {
+	var req model.GrafanaQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := h.service.Query(c.Request.Context(), req)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to query Grafana targets", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to query targets"})
+		return
+	}
+
+	respond(c, http.StatusOK, results)
+}
+
+// Annotations lists the annotations of the run named by the annotation query
+func (h *GrafanaHandler) Annotations(c *gin.Context) {
+	var req model.GrafanaAnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	annotations, err := h.service.Annotations(c.Request.Context(), req)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to get Grafana annotations", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get annotations"})
+		return
+	}
+
+	respond(c, http.StatusOK, annotations)
+}
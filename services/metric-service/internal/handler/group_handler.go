@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type GroupHandler struct {
+	service *service.GroupService
+	logger  *zap.Logger
+}
+
+func NewGroupHandler(service *service.GroupService, logger *zap.Logger) *GroupHandler {
+	return &GroupHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// SetRunGroup places a run in a group
+func (h *GroupHandler) SetRunGroup(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.SetRunGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := h.service.SetRunGroup(c.Request.Context(), runID, req)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to set run group", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to set run group"})
+		return
+	}
+
+	respond(c, http.StatusOK, group)
+}
+
+// GetRunGroup retrieves the group of a run
+func (h *GroupHandler) GetRunGroup(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	group, err := h.service.GetRunGroup(c.Request.Context(), runID)
+	if err != nil {
+		h.logger.Error("Failed to get run group", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get run group"})
+		return
+	}
+	if group == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Run is not in a group"})
+		return
+	}
+
+	respond(c, http.StatusOK, group)
+}
+
+// DeleteRunGroup removes a run from its group
+func (h *GroupHandler) DeleteRunGroup(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	deleted, err := h.service.DeleteRunGroup(c.Request.Context(), runID)
+	if err != nil {
+		h.logger.Error("Failed to delete run group", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete run group"})
+		return
+	}
+	if !deleted {
+		respond(c, http.StatusNotFound, gin.H{"error": "Run is not in a group"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListGroups lists run groups, optionally only those with runs of a job type
+func (h *GroupHandler) ListGroups(c *gin.Context) {
+	var params model.GroupQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 100
+	}
+
+	groups, err := h.service.ListGroups(c.Request.Context(), params)
+	if err != nil {
+		h.logger.Error("Failed to list groups", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list groups"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("groups", groups, nil))
+}
+
+// GetGroupMetric combines a metric per step across the runs of a group,
+// e.g. the mean validation accuracy over five seeds
+func (h *GroupHandler) GetGroupMetric(c *gin.Context) {
+	group := c.Param("group")
+	metricName := strings.TrimPrefix(c.Param("metric_name"), "/")
+	if metricName == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Metric name is required"})
+		return
+	}
+
+	var params model.GroupMetricQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 1000
+	}
+	if params.Reduce == "" {
+		params.Reduce = model.ReduceMean
+	}
+
+	points, err := h.service.GetGroupMetric(c.Request.Context(), group, metricName, params)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to get group metric", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get group metric"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("points", points, gin.H{
+		"group":       group,
+		"metric_name": metricName,
+		"reduce":      params.Reduce,
+	}))
+}
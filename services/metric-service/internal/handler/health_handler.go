@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type HealthHandler struct {
+	service *service.HealthService
+	logger  *zap.Logger
+}
+
+func NewHealthHandler(service *service.HealthService, logger *zap.Logger) *HealthHandler {
+	return &HealthHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetHealth reports the state of each component and of the service as a
+// whole. A degraded service still answers 200, so that liveness probes do
+// not restart it; monitoring reads the status.
+func (h *HealthHandler) GetHealth(c *gin.Context) {
+	respond(c, http.StatusOK, h.service.Check(c.Request.Context()))
+}
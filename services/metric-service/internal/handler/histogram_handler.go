@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type HistogramHandler struct {
+	service *service.HistogramService
+	logger  *zap.Logger
+}
+
+func NewHistogramHandler(service *service.HistogramService, logger *zap.Logger) *HistogramHandler {
+	return &HistogramHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// BatchWrite handles batch histogram writing
+func (h *HistogramHandler) BatchWrite(c *gin.Context) {
+	var req model.HistogramBatchRequest
+	if err := bindBatchJSON(c, &req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	runIDs := make([]uuid.UUID, len(req.Histograms))
+	for i, item := range req.Histograms {
+		runIDs[i] = item.RunID
+	}
+	if !checkRuns(c, runIDs) {
+		return
+	}
+
+	if err := h.service.BatchWrite(c.Request.Context(), req.Histograms); err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to write histograms", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to write histograms"})
+		return
+	}
+
+	respond(c, http.StatusCreated, gin.H{
+		"message": "Histograms written successfully",
+		"count":   len(req.Histograms),
+	})
+}
+
+// ListHistograms lists the histogram metrics logged in a run
+func (h *HistogramHandler) ListHistograms(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	series, err := h.service.ListHistogramSeries(c.Request.Context(), runID)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to list histograms", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list histograms"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("histograms", series, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// GetHistogramHistory retrieves per-step distributions for a histogram metric
+func (h *HistogramHandler) GetHistogramHistory(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	metricName := c.Param("metric_name")
+	if metricName == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Metric name is required"})
+		return
+	}
+
+	var params model.HistogramQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 500
+	}
+
+	histograms, err := h.service.GetHistogramHistory(c.Request.Context(), runID, metricName, params)
+	if err != nil {
+		h.logger.Error("Failed to get histogram history", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get histogram history"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("histograms", histograms, gin.H{
+		"run_id":      runID,
+		"metric_name": metricName,
+	}))
+}
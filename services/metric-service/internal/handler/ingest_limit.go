@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+const ingestLimiterKey = "ingest_limiter"
+
+// IngestLimit makes limiter available to batch and stream handlers, which
+// admit their batches, or the chunks of a stream, once the runs in them are
+// known
+func IngestLimit(limiter *service.IngestLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(ingestLimiterKey, limiter)
+		c.Next()
+	}
+}
+
+// admitIngest admits a batch written to runIDs, one for each metric, when
+// ingest is limited, answering 429 and returning false when it is shed
+func admitIngest(c *gin.Context, runIDs []uuid.UUID) bool {
+	return admitIngestWith(c, runIDs, nil)
+}
+
+// admitIngestWith is admitIngest adding fields to the body of a 429
+func admitIngestWith(c *gin.Context, runIDs []uuid.UUID, fields gin.H) bool {
+	v, ok := c.Get(ingestLimiterKey)
+	if !ok {
+		return true
+	}
+	err := v.(*service.IngestLimiter).Admit(runIDs)
+	var backpressureErr *service.BackpressureError
+	if !errors.As(err, &backpressureErr) {
+		return true
+	}
+
+	// Retry-After counts whole seconds, so waits are rounded up
+	retryAfter := int(math.Ceil(backpressureErr.RetryAfter.Seconds()))
+	retryAfter = max(retryAfter, 1)
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	body := gin.H{"error": backpressureErr.Error(), "retry_after": retryAfter}
+	for k, v := range fields {
+		body[k] = v
+	}
+	if backpressureErr.RunID != uuid.Nil {
+		body["run_id"] = backpressureErr.RunID
+	}
+	respond(c, http.StatusTooManyRequests, body)
+	return false
+}
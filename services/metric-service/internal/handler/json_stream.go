@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const jsonStreamBufferSize = 32 << 10
+
+// jsonStream writes a list response row by row, in the shape respond gives
+// the listResponse of fields and the rows under listKey in the request's API
+// version, trimmed to the request's field mask. Nothing is sent before the first row, so a
+// failure until then can still be answered with an error response. A
+// failure after it can only cut the response short, which leaves the JSON
+// incomplete so clients cannot take it for the whole result.
+type jsonStream struct {
+	c          *gin.Context
+	listKey    string
+	fields     gin.H
+	limit      int
+	v2         bool
+	w          *bufio.Writer
+	count      int
+	cursored   bool
+	nextCursor *string
+	// rowMask trims each row; skipRows only counts rows when the mask leaves
+	// out the list
+	rowMask  fieldMask
+	skipRows bool
+}
+
+func newJSONStream(c *gin.Context, listKey string, fields gin.H, limit int) *jsonStream {
+	return &jsonStream{
+		c:       c,
+		listKey: listKey,
+		fields:  fields,
+		limit:   limit,
+	}
+}
+
+// Started tells whether the response has been sent in part
+func (s *jsonStream) Started() bool {
+	return s.w != nil
+}
+
+// Write adds a row to the list
+func (s *jsonStream) Write(row interface{}) error {
+	if s.w == nil {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+	if s.skipRows {
+		s.count++
+		return nil
+	}
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	if data, err = s.rowMask.applyJSON(data); err != nil {
+		return err
+	}
+	if s.count > 0 {
+		if err := s.w.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	s.count++
+	_, err = s.w.Write(data)
+	return err
+}
+
+// SetNextCursor ends the response with the next_cursor of a list paged by
+// cursor, nil on its last page
+func (s *jsonStream) SetNextCursor(cursor *string) {
+	s.cursored = true
+	s.nextCursor = cursor
+}
+
+// Close ends the list and the response
+func (s *jsonStream) Close() error {
+	if s.w == nil {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+
+	var tail []byte
+	if s.v2 {
+		page := &V2Pagination{Count: s.count, NextCursor: s.nextCursor}
+		if s.limit > 0 {
+			limit := int64(s.limit)
+			page.Limit = &limit
+		}
+		page.HasMore = hasMore(page, s.cursored)
+		data, err := json.Marshal(page)
+		if err != nil {
+			return err
+		}
+		tail = append([]byte(`],"pagination":`), data...)
+		tail = append(tail, '}')
+	} else {
+		tail = []byte(`],"count":` + strconv.Itoa(s.count))
+		if s.cursored {
+			data, err := json.Marshal(s.nextCursor)
+			if err != nil {
+				return err
+			}
+			tail = append(append(tail, `,"next_cursor":`...), data...)
+		}
+		tail = append(tail, '}')
+	}
+
+	if _, err := s.w.Write(tail); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// start sends the headers and everything before the first row
+func (s *jsonStream) start() error {
+	s.v2 = isV2(s.c)
+	fields := s.fields
+	if mask, ok := requestFieldMask(s.c); ok {
+		fields = mask.apply(map[string]interface{}(fields)).(map[string]interface{})
+		var kept bool
+		s.rowMask, kept = mask.sub(s.listKey)
+		s.skipRows = !kept
+	}
+
+	// v1 keeps the fields at the top level, v2 moves them to meta
+	head := []byte{'{'}
+	if len(fields) > 0 {
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		if s.v2 {
+			head = append(append(head, `"meta":`...), data...)
+		} else {
+			head = append(head, data[1:len(data)-1]...)
+		}
+		head = append(head, ',')
+	}
+	listKey := s.listKey
+	if s.v2 {
+		listKey = "data"
+	}
+	key, err := json.Marshal(listKey)
+	if err != nil {
+		return err
+	}
+	head = append(append(head, key...), `:[`...)
+
+	s.c.Header("Content-Type", "application/json; charset=utf-8")
+	s.c.Status(http.StatusOK)
+	s.w = bufio.NewWriterSize(s.c.Writer, jsonStreamBufferSize)
+	_, err = s.w.Write(head)
+	return err
+}
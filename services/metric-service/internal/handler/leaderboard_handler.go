@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type LeaderboardHandler struct {
+	service *service.LeaderboardService
+	logger  *zap.Logger
+}
+
+func NewLeaderboardHandler(service *service.LeaderboardService, logger *zap.Logger) *LeaderboardHandler {
+	return &LeaderboardHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// SetLeaderboard configures the objective a project's runs are ranked by and
+// ranks the runs already in the project
+func (h *LeaderboardHandler) SetLeaderboard(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("project_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var req model.SetLeaderboardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg, ranked, err := h.service.SetLeaderboard(c.Request.Context(), projectID, req)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to set leaderboard", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to set leaderboard"})
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"leaderboard": cfg,
+		"ranked_runs": ranked,
+	})
+}
+
+// GetLeaderboard retrieves the objective of a project's leaderboard
+func (h *LeaderboardHandler) GetLeaderboard(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("project_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	cfg, err := h.service.GetLeaderboard(c.Request.Context(), projectID)
+	if err != nil {
+		h.logger.Error("Failed to get leaderboard", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get leaderboard"})
+		return
+	}
+	if cfg == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Project has no leaderboard"})
+		return
+	}
+
+	respond(c, http.StatusOK, cfg)
+}
+
+// DeleteLeaderboard removes a project's leaderboard
+func (h *LeaderboardHandler) DeleteLeaderboard(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("project_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	deleted, err := h.service.DeleteLeaderboard(c.Request.Context(), projectID)
+	if err != nil {
+		h.logger.Error("Failed to delete leaderboard", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete leaderboard"})
+		return
+	}
+	if !deleted {
+		respond(c, http.StatusNotFound, gin.H{"error": "Project has no leaderboard"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListEntries returns one page of a project's leaderboard, best run first
+func (h *LeaderboardHandler) ListEntries(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("project_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var params model.LeaderboardQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 100
+	}
+
+	cfg, entries, total, err := h.service.ListEntries(c.Request.Context(), projectID, params)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to list leaderboard", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list leaderboard"})
+		return
+	}
+	if cfg == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Project has no leaderboard"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("entries", entries, gin.H{
+		"project_id":  projectID,
+		"metric_name": cfg.MetricName,
+		"goal":        cfg.Goal,
+		"mode":        cfg.Mode,
+	}).withWindow(total, params.Offset, params.Limit))
+}
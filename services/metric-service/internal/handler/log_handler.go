@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type LogHandler struct {
+	service           *service.LogService
+	strictQueryParams bool
+	logger            *zap.Logger
+}
+
+func NewLogHandler(service *service.LogService, strictQueryParams bool, logger *zap.Logger) *LogHandler {
+	return &LogHandler{
+		service:           service,
+		strictQueryParams: strictQueryParams,
+		logger:            logger,
+	}
+}
+
+// WriteLogs appends console lines to a run
+func (h *LogHandler) WriteLogs(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.LogBatchRequest
+	if err := bindBatchJSON(c, &req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	if err := h.service.WriteLogs(c.Request.Context(), runID, req.Lines); err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to write logs", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to write logs"})
+		return
+	}
+
+	respond(c, http.StatusCreated, gin.H{
+		"message": "Logs written successfully",
+		"count":   len(req.Lines),
+	})
+}
+
+// GetRunLogs retrieves console lines of a run within a time range
+func (h *LogHandler) GetRunLogs(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.LogQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 1000
+	}
+
+	lines, err := h.service.GetRunLogs(c.Request.Context(), runID, params)
+	if err != nil {
+		h.logger.Error("Failed to get logs", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get logs"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("lines", lines, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// TailLogs streams a run's console output over a WebSocket, starting with
+// the last ?tail lines
+func (h *LogHandler) TailLogs(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	tail, ok := queryInt(c, h.strictQueryParams, "tail", 100, 0, 10000)
+	if !ok {
+		return
+	}
+	minLevel := c.Query("min_level")
+	levels := service.LogLevelsFrom(minLevel)
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		h.logger.Error("Failed to upgrade connection", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Subscribe before reading the backlog so no line falls in between
+	pubsub := h.service.SubscribeToLogs(ctx, runID)
+	defer pubsub.Close()
+
+	// The read loop only handles control frames and notices the client leaving
+	go func() {
+		defer cancel()
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		conn.SetPongHandler(func(string) error {
+			conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+			return nil
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if tail > 0 {
+		lines, err := h.service.GetLatestLogs(ctx, runID, tail, minLevel)
+		if err != nil {
+			h.logger.Error("Failed to get latest logs", zap.Error(err))
+			return
+		}
+		if len(lines) > 0 && !h.writeLogLines(conn, lines) {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(54 * time.Second)
+	defer ticker.Stop()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var payload model.LogPayload
+			if err := json.Unmarshal([]byte(msg.Payload), &payload); err != nil {
+				h.logger.Error("Failed to parse log payload", zap.Error(err))
+				continue
+			}
+			lines := filterLogLines(payload.Lines, levels)
+			if len(lines) > 0 && !h.writeLogLines(conn, lines) {
+				return
+			}
+
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *LogHandler) writeLogLines(conn *websocket.Conn, lines []model.LogLine) bool {
+	data, err := json.Marshal(model.WebSocketMessage{
+		Type:    "log",
+		Payload: model.LogPayload{Lines: lines},
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal message", zap.Error(err))
+		return true
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return conn.WriteMessage(websocket.TextMessage, data) == nil
+}
+
+// filterLogLines keeps the lines whose level is in levels; nil keeps everything
+func filterLogLines(lines []model.LogLine, levels []string) []model.LogLine {
+	if levels == nil {
+		return lines
+	}
+
+	var filtered []model.LogLine
+	for _, l := range lines {
+		for _, level := range levels {
+			if l.Level == level {
+				filtered = append(filtered, l)
+				break
+			}
+		}
+	}
+	return filtered
+}
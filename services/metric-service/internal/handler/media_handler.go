@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// multipartOverheadBytes leaves room for form fields and boundaries on top of the file
+const multipartOverheadBytes = 1 << 20
+
+type MediaHandler struct {
+	service        *service.MediaService
+	maxUploadBytes int64
+	logger         *zap.Logger
+}
+
+func NewMediaHandler(service *service.MediaService, maxUploadBytes int64, logger *zap.Logger) *MediaHandler {
+	return &MediaHandler{
+		service:        service,
+		maxUploadBytes: maxUploadBytes,
+		logger:         logger,
+	}
+}
+
+// UploadMedia handles a multipart upload of one media file
+func (h *MediaHandler) UploadMedia(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadBytes+multipartOverheadBytes)
+
+	var req model.MediaUploadRequest
+	if err := c.ShouldBind(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respond(c, http.StatusRequestEntityTooLarge, gin.H{"error": "Media file is too large"})
+			return
+		}
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "A file is required"})
+		return
+	}
+	if fileHeader.Size > h.maxUploadBytes {
+		respond(c, http.StatusRequestEntityTooLarge, gin.H{"error": "Media file is too large"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	contentType := fileHeader.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		sniff := make([]byte, 512)
+		n, _ := file.Read(sniff)
+		contentType = http.DetectContentType(sniff[:n])
+		if _, err := file.Seek(0, 0); err != nil {
+			respond(c, http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+	}
+
+	item, err := h.service.UploadMedia(c.Request.Context(), runID, req, contentType, file, fileHeader.Size)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to upload media", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to upload media"})
+		return
+	}
+
+	respond(c, http.StatusCreated, item)
+}
+
+// GetRunMedia lists media items of a run, optionally within a step range
+func (h *MediaHandler) GetRunMedia(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.MediaQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 1000
+	}
+
+	items, err := h.service.GetRunMedia(c.Request.Context(), runID, params)
+	if err != nil {
+		h.logger.Error("Failed to get media", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get media"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("media", items, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// GetMediaContent streams the bytes of a media item, or redirects to a signed
+// URL of the object store
+func (h *MediaHandler) GetMediaContent(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	mediaID, err := uuid.Parse(c.Param("media_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid media ID"})
+		return
+	}
+
+	// Stores that sign URLs serve the content themselves
+	signed, url, err := h.service.MediaURL(c.Request.Context(), runID, mediaID)
+	if err != nil {
+		h.logger.Error("Failed to sign media URL", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get media"})
+		return
+	}
+	if signed != nil {
+		c.Redirect(http.StatusTemporaryRedirect, url)
+		return
+	}
+
+	item, content, err := h.service.OpenMedia(c.Request.Context(), runID, mediaID)
+	if err != nil {
+		h.logger.Error("Failed to open media", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get media"})
+		return
+	}
+	if item == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Media not found"})
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, item.SizeBytes, item.ContentType, content, map[string]string{
+		"ETag":          `"` + item.Digest + `"`,
+		"Cache-Control": "private, max-age=31536000, immutable",
+	})
+}
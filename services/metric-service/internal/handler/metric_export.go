@@ -0,0 +1,306 @@
+package handler
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+	"github.com/wanllmdb/metric-service/internal/tfevents"
+)
+
+// Formats of metric exports
+const (
+	exportFormatCSV      = "csv"
+	exportFormatJSONL    = "jsonl"
+	exportFormatTFEvents = "tfevents"
+)
+
+// tfeventsHost is the host part of the names of exported event files
+const tfeventsHost = "wanllmdb"
+
+// exportFlushRows is how many rows an export writes between flushes, so
+// that the response goes out in chunks as it is read
+const exportFlushRows = 1000
+
+// exportOffsetHeader echoes the offset an export resumes from
+const exportOffsetHeader = "X-Export-Offset"
+
+// exportErrorTrailer is the trailer an export cut short by a failure ends
+// with, as its rows may already be out
+const exportErrorTrailer = "X-Export-Error"
+
+// metricCSVHeader are the columns of CSV exports. Strings and bools are in
+// text, with an empty value.
+var metricCSVHeader = []string{"time", "metric_name", "step", "value", "text", "node_id", "rank"}
+
+// ExportMetrics streams the whole history of a run's metrics in a file
+// format, oldest first unless direction says otherwise. Rows are written as
+// they are read, never held in memory. CSV and JSONL exports skip the first
+// offset rows, so that one cut short resumes after the rows it delivered.
+func (h *MetricHandler) ExportMetrics(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.MetricQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !metricNames(c, &params) {
+		return
+	}
+	if params.Cursor != "" || params.Limit != 0 {
+		respond(c, http.StatusBadRequest, gin.H{"error": "exports cannot be combined with limit or cursor"})
+		return
+	}
+	if params.Direction == "" {
+		params.Direction = model.DirectionAsc
+	}
+	var ok bool
+	if params.Offset, ok = queryInt(c, true, "offset", 0, 0, math.MaxInt); !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", exportFormatCSV)
+	if params.Offset > 0 && format == exportFormatTFEvents {
+		respond(c, http.StatusBadRequest, gin.H{"error": "tfevents exports cannot be resumed from an offset"})
+		return
+	}
+	switch format {
+	case exportFormatCSV:
+		h.exportCSV(c, runID, params)
+	case exportFormatJSONL:
+		h.exportJSONL(c, runID, params)
+	case exportFormatTFEvents:
+		h.exportTFEvents(c, runID, params)
+	default:
+		respond(c, http.StatusBadRequest, gin.H{"error": "format must be csv, jsonl or tfevents"})
+	}
+}
+
+// exportCSV writes the metrics of a query as CSV with a header row
+func (h *MetricHandler) exportCSV(c *gin.Context, runID uuid.UUID, params model.MetricQueryParams) {
+	var w *csv.Writer
+	rows := 0
+	record := make([]string, len(metricCSVHeader))
+	err := h.service.StreamRunMetrics(c.Request.Context(), runID, params, func(m model.Metric) error {
+		if w == nil {
+			startExport(c, runID, params.Offset, "text/csv; charset=utf-8", exportFormatCSV)
+			w = csv.NewWriter(c.Writer)
+			if err := w.Write(metricCSVHeader); err != nil {
+				return err
+			}
+		}
+		metricCSVRecord(m, record)
+		if err := w.Write(record); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			w.Flush()
+			c.Writer.Flush()
+		}
+		return w.Error()
+	})
+	if err == nil && w == nil {
+		startExport(c, runID, params.Offset, "text/csv; charset=utf-8", exportFormatCSV)
+		w = csv.NewWriter(c.Writer)
+		err = w.Write(metricCSVHeader)
+	}
+	if err == nil {
+		w.Flush()
+		err = w.Error()
+	}
+	if err != nil {
+		h.exportFailed(c, w != nil, err)
+	}
+}
+
+// exportJSONL writes the metrics of a query as JSON lines, one metric per
+// line in the shape of the metric queries
+func (h *MetricHandler) exportJSONL(c *gin.Context, runID uuid.UUID, params model.MetricQueryParams) {
+	var w *bufio.Writer
+	start := func() {
+		startExport(c, runID, params.Offset, "application/x-ndjson", exportFormatJSONL)
+		w = bufio.NewWriterSize(c.Writer, jsonStreamBufferSize)
+	}
+
+	rows := 0
+	err := h.service.StreamRunMetrics(c.Request.Context(), runID, params, func(m model.Metric) error {
+		if w == nil {
+			start()
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil && w == nil {
+		start()
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		h.exportFailed(c, w != nil, err)
+	}
+}
+
+// exportTFEvents writes the metrics of a query as a zip archive holding a
+// TensorBoard event file under a directory named after the run, so that
+// unpacking it into a log directory adds the run. Numbers and bools, as 1
+// and 0, are scalar summaries tagged with the metric name; strings and
+// per-rank values are left out. A metric without a step is written at the
+// number of values of its tag before it.
+func (h *MetricHandler) exportTFEvents(c *gin.Context, runID uuid.UUID, params model.MetricQueryParams) {
+	var archive *zip.Writer
+	var events *tfevents.Writer
+	start := func(wallTime time.Time) error {
+		startExport(c, runID, 0, "application/zip", "zip")
+		archive = zip.NewWriter(c.Writer)
+		f, err := archive.Create(runID.String() + "/" + tfevents.FileName(wallTime, tfeventsHost))
+		if err != nil {
+			return err
+		}
+		events, err = tfevents.NewWriter(f, wallTime)
+		return err
+	}
+
+	rows := 0
+	counts := make(map[string]int64)
+	err := h.service.StreamRunMetrics(c.Request.Context(), runID, params, func(m model.Metric) error {
+		if archive == nil {
+			if err := start(m.Time); err != nil {
+				return err
+			}
+		}
+		value, ok := tensorBoardScalar(m)
+		if !ok {
+			return nil
+		}
+		step := counts[m.MetricName]
+		counts[m.MetricName]++
+		if m.Step != nil {
+			step = *m.Step
+		}
+		if err := events.WriteScalar(m.MetricName, step, value, m.Time); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			if err := archive.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil && archive == nil {
+		err = start(time.Now())
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		h.exportFailed(c, archive != nil, err)
+	}
+}
+
+// tensorBoardScalar is the scalar value of m, if it has one
+func tensorBoardScalar(m model.Metric) (float64, bool) {
+	switch {
+	case m.Rank != nil:
+		return 0, false
+	case m.IsNumeric():
+		return m.Value, true
+	case m.ValueType == model.ValueTypeBool && m.Text != nil:
+		if *m.Text == "true" {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// startExport sends the headers of an export, declaring the trailer a
+// failure is reported in and echoing the offset it resumes from
+func startExport(c *gin.Context, runID uuid.UUID, offset int, contentType, extension string) {
+	c.Header(exportOffsetHeader, strconv.Itoa(offset))
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-metrics.%s"`, runID, extension))
+	c.Header("Trailer", exportErrorTrailer)
+	c.Status(http.StatusOK)
+}
+
+// exportFailed answers an export that failed before it started with an error
+// response, or ends one cut short with the error trailer
+func (h *MetricHandler) exportFailed(c *gin.Context, started bool, err error) {
+	if !started {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to export metrics", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to export metrics"})
+		return
+	}
+	h.logger.Error("Failed to export metrics", zap.Error(err))
+	reason := "Failed to export metrics"
+	var limitErr *service.RowLimitError
+	if errors.As(err, &limitErr) {
+		reason = limitErr.Error()
+	}
+	c.Writer.Header().Set(exportErrorTrailer, reason)
+}
+
+// metricCSVRecord fills record with the columns of metricCSVHeader for m.
+// NaN and the infinities are written as NaN, Infinity and -Infinity, which
+// pandas reads as floats.
+func metricCSVRecord(m model.Metric, record []string) {
+	record[0] = m.Time.UTC().Format(time.RFC3339Nano)
+	record[1] = m.MetricName
+	record[2] = ""
+	if m.Step != nil {
+		record[2] = strconv.FormatInt(*m.Step, 10)
+	}
+	record[3], record[4] = "", ""
+	switch {
+	case !m.IsNumeric():
+		if m.Text != nil {
+			record[4] = *m.Text
+		}
+	case model.NonFiniteName(m.Value) != "":
+		record[3] = model.NonFiniteName(m.Value)
+	default:
+		record[3] = strconv.FormatFloat(m.Value, 'g', -1, 64)
+	}
+	record[5] = m.NodeID
+	record[6] = ""
+	if m.Rank != nil {
+		record[6] = strconv.Itoa(*m.Rank)
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/csv"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestMetricCSVRecord(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.FixedZone("CET", 3600))
+	step, rank := int64(42), 3
+	text := `warm, "up"`
+	tests := []struct {
+		name   string
+		metric model.Metric
+		want   string
+	}{
+		{"number", model.Metric{Time: at, MetricName: "loss", Step: &step, Value: 0.25, NodeID: "n1", Rank: &rank},
+			"2024-01-02T02:04:05.6Z,loss,42,0.25,,n1,3"},
+		{"without step", model.Metric{Time: at, MetricName: "lr", Value: 1e-4},
+			"2024-01-02T02:04:05.6Z,lr,,0.0001,,,"},
+		{"non-finite", model.Metric{Time: at, MetricName: "loss", Value: math.Inf(-1)},
+			"2024-01-02T02:04:05.6Z,loss,,-Infinity,,,"},
+		{"string", model.Metric{Time: at, MetricName: "phase", ValueType: model.ValueTypeString, Text: &text},
+			`2024-01-02T02:04:05.6Z,phase,,,"warm, ""up""",,`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			w := csv.NewWriter(&b)
+			record := make([]string, len(metricCSVHeader))
+			metricCSVRecord(tt.metric, record)
+			if err := w.Write(record); err != nil {
+				t.Fatal(err)
+			}
+			w.Flush()
+			if got := strings.TrimSuffix(b.String(), "\n"); got != tt.want {
+				t.Errorf("CSV row = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTensorBoardScalar(t *testing.T) {
+	rank := 0
+	yes, phase := "true", "warmup"
+	tests := []struct {
+		name   string
+		metric model.Metric
+		want   float64
+		ok     bool
+	}{
+		{"number", model.Metric{Value: 0.5}, 0.5, true},
+		{"bool", model.Metric{ValueType: model.ValueTypeBool, Text: &yes}, 1, true},
+		{"string", model.Metric{ValueType: model.ValueTypeString, Text: &phase}, 0, false},
+		{"per rank", model.Metric{Value: 0.5, Rank: &rank}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := tensorBoardScalar(tt.metric); got != tt.want || ok != tt.ok {
+				t.Errorf("tensorBoardScalar = %v, %v; want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
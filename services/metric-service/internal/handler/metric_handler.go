@@ -1,7 +1,12 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"reflect"
+	"regexp"
 	"strconv"
 	"time"
 
@@ -14,88 +19,233 @@ import (
 )
 
 type MetricHandler struct {
-	service *service.MetricService
-	logger  *zap.Logger
+	service           *service.MetricService
+	artifacts         *service.ArtifactService
+	annotations       *service.AnnotationService
+	strictQueryParams bool
+	logger            *zap.Logger
 }
 
-func NewMetricHandler(service *service.MetricService, logger *zap.Logger) *MetricHandler {
+func NewMetricHandler(service *service.MetricService, artifacts *service.ArtifactService, annotations *service.AnnotationService, strictQueryParams bool, logger *zap.Logger) *MetricHandler {
 	return &MetricHandler{
-		service: service,
-		logger:  logger,
+		service:           service,
+		artifacts:         artifacts,
+		annotations:       annotations,
+		strictQueryParams: strictQueryParams,
+		logger:            logger,
 	}
 }
 
-// BatchWrite handles batch metric writing
+// BatchWrite handles batch metric writing, from JSON or protobuf bodies.
+// With a write buffer the batch is answered 202 once queued, unless sync=true
+// asks to wait until it is written. With partial=true the valid metrics are
+// written even when others are not, answering 207 with those not written.
 func (h *MetricHandler) BatchWrite(c *gin.Context) {
+	syncWrite, ok := queryBool(c, h.strictQueryParams, "sync")
+	if !ok {
+		return
+	}
+	partial, ok := queryBool(c, h.strictQueryParams, "partial")
+	if !ok {
+		return
+	}
 	var req model.MetricBatchRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var err error
+	if protobufRequest(c) {
+		err = bindMetricsProto(c, &req)
+	} else {
+		err = bindMetricsJSON(c, &req)
+	}
+	if err != nil {
+		badRequest(c, err)
 		return
 	}
 
-	if err := h.service.BatchWrite(c.Request.Context(), req.Metrics); err != nil {
+	runIDs := make([]uuid.UUID, len(req.Metrics))
+	for i, m := range req.Metrics {
+		runIDs[i] = m.RunID
+	}
+	if !checkRuns(c, runIDs) || !admitIngest(c, runIDs) {
+		return
+	}
+
+	var queued bool
+	// Metrics not written by a partial write
+	var failed []service.ItemError
+	switch {
+	case partial:
+		// Values the database rejects are only known once written, so
+		// partial writes are never queued
+		failed, err = h.service.BatchWritePartial(c.Request.Context(), req.Metrics, req.IngestOptions)
+	case syncWrite:
+		err = h.service.BatchWriteWithOptions(c.Request.Context(), req.Metrics, req.IngestOptions)
+	default:
+		queued, err = h.service.EnqueueWithOptions(c.Request.Context(), req.Metrics, req.IngestOptions)
+	}
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			badRequest(c, validationErr)
+			return
+		}
+		if errors.Is(err, service.ErrIngestPoolStopped) || errors.Is(err, service.ErrWriteBufferStopped) {
+			respond(c, http.StatusServiceUnavailable, gin.H{"error": "Metric ingest is shutting down"})
+			return
+		}
 		h.logger.Error("Failed to write metrics", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write metrics"})
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to write metrics"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	switch {
+	case len(failed) > 0 && len(failed) == len(req.Metrics):
+		badRequest(c, service.NewBatchValidationError("metric", failed))
+		return
+	case len(failed) > 0:
+		respond(c, http.StatusMultiStatus, gin.H{
+			"message": "Some metrics were not written",
+			"count":   len(req.Metrics) - len(failed),
+			"errors":  failed,
+		})
+		return
+	case queued:
+		respond(c, http.StatusAccepted, gin.H{
+			"message": "Metrics queued for writing",
+			"count":   len(req.Metrics),
+		})
+		return
+	}
+	respond(c, http.StatusCreated, gin.H{
 		"message": "Metrics written successfully",
 		"count":   len(req.Metrics),
 	})
 }
 
-// BatchWriteSystemMetrics handles batch system metric writing
+// BatchWriteSystemMetrics handles batch system metric writing, from JSON or
+// protobuf bodies
 func (h *MetricHandler) BatchWriteSystemMetrics(c *gin.Context) {
 	var req model.SystemMetricBatchRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var err error
+	if protobufRequest(c) {
+		err = bindSystemMetricsProto(c, &req)
+	} else {
+		err = bindBatchJSON(c, &req)
+	}
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	runIDs := make([]uuid.UUID, len(req.Metrics))
+	for i, m := range req.Metrics {
+		runIDs[i] = m.RunID
+	}
+	if !checkRuns(c, runIDs) || !admitIngest(c, runIDs) {
 		return
 	}
 
 	if err := h.service.BatchWriteSystemMetrics(c.Request.Context(), req.Metrics); err != nil {
 		h.logger.Error("Failed to write system metrics", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write system metrics"})
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to write system metrics"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	respond(c, http.StatusCreated, gin.H{
 		"message": "System metrics written successfully",
 		"count":   len(req.Metrics),
 	})
 }
 
-// GetRunMetrics retrieves all metrics for a run
+// GetRunMetrics retrieves all metrics for a run. With metric_names the
+// metrics of those names are returned grouped by name under series, unless
+// streamed.
 func (h *MetricHandler) GetRunMetrics(c *gin.Context) {
 	runIDStr := c.Param("run_id")
 	runID, err := uuid.Parse(runIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
 		return
 	}
 
 	var params model.MetricQueryParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Set default limit
-	if params.Limit == 0 {
-		params.Limit = 1000
+	if !metricNames(c, &params) || !metricLimit(c, &params) {
+		return
+	}
+	if params.Stream {
+		h.streamMetrics(c, runID, params, gin.H{"run_id": runID})
+		return
 	}
 
 	metrics, err := h.service.GetRunMetrics(c.Request.Context(), runID, params)
 	if err != nil {
+		if rowLimitExceeded(c, err) || invalidCursor(c, err) {
+			return
+		}
 		h.logger.Error("Failed to get run metrics", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get metrics"})
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metrics"})
+		return
+	}
+
+	response := newList("metrics", metrics, gin.H{
+		"run_id": runID,
+	}).withCursor(nextCursor(metrics, params.Limit, metricPosition(params)))
+	if len(params.MetricNames) > 0 {
+		response.key = "series"
+		response.items = groupByName(metrics, params.MetricNames)
+		response.v2Data = seriesData(params.MetricNames)
+	}
+
+	if !h.addAnnotations(c, runID, params, response.fields) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"run_id":  runID,
-		"metrics": metrics,
-		"count":   len(metrics),
+	respond(c, http.StatusOK, response)
+}
+
+// CountRunMetrics counts the metrics of a run matching the filters of
+// GetRunMetrics, or of one metric under /metrics/:metric_name. HEAD requests
+// of the metric lists are answered here too, with the count in X-Total-Count
+// only.
+func (h *MetricHandler) CountRunMetrics(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.MetricQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if metricName := c.Param("metric_name"); metricName != "" {
+		params.MetricName = metricName
+	}
+	if !metricNames(c, &params) {
+		return
+	}
+
+	total, err := h.service.CountRunMetrics(c.Request.Context(), runID, params)
+	if err != nil {
+		h.logger.Error("Failed to count run metrics", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to count metrics"})
+		return
+	}
+
+	c.Header(totalCountHeader, strconv.FormatInt(total, 10))
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{
+		"run_id": runID,
+		"total":  total,
 	})
 }
 
@@ -104,39 +254,287 @@ func (h *MetricHandler) GetMetricHistory(c *gin.Context) {
 	runIDStr := c.Param("run_id")
 	runID, err := uuid.Parse(runIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
 		return
 	}
 
 	metricName := c.Param("metric_name")
 	if metricName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Metric name is required"})
+		respond(c, http.StatusBadRequest, gin.H{"error": "Metric name is required"})
 		return
 	}
 
 	var params model.MetricQueryParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if params.Limit == 0 {
-		params.Limit = 1000
+	if !metricNames(c, &params) {
+		return
+	}
+
+	// Downsampling reduces the whole range rather than a page of it
+	downsample, ok := queryInt(c, h.strictQueryParams, "downsample", 0, 3, model.MaxMetricQueryLimit)
+	if !ok {
+		return
+	}
+	method := c.DefaultQuery("downsample_method", service.DownsampleLTTB)
+	if downsample > 0 {
+		switch {
+		case method != service.DownsampleLTTB && method != service.DownsampleNth:
+			respond(c, http.StatusBadRequest, gin.H{"error": "downsample_method must be lttb or nth"})
+			return
+		case params.Limit != 0 || params.Cursor != "" || params.Stream:
+			respond(c, http.StatusBadRequest, gin.H{"error": "downsample cannot be combined with limit, cursor or stream"})
+			return
+		case acceptsArrow(c):
+			respond(c, http.StatusBadRequest, gin.H{"error": "downsample cannot be combined with Arrow responses"})
+			return
+		}
 	}
 
-	metrics, err := h.service.GetMetricHistory(c.Request.Context(), runID, metricName, params)
+	// Smoothing applies to the values returned, from the oldest
+	smoothing := c.Query("smoothing")
+	alpha := service.DefaultSmoothingAlpha
+	if smoothing != "" {
+		if smoothing != service.SmoothingEMA {
+			respond(c, http.StatusBadRequest, gin.H{"error": "smoothing must be ema"})
+			return
+		}
+		if value := c.Query("alpha"); value != "" {
+			var err error
+			alpha, err = strconv.ParseFloat(value, 64)
+			if err != nil || !(alpha > 0 && alpha <= 1) {
+				invalidQueryParam(c, "alpha", value, "must be a number greater than 0 and at most 1")
+				return
+			}
+		}
+		if params.Stream || acceptsArrow(c) {
+			respond(c, http.StatusBadRequest, gin.H{"error": "smoothing cannot be combined with stream or Arrow responses"})
+			return
+		}
+	}
+
+	if !metricLimit(c, &params) {
+		return
+	}
+	includeArtifacts, ok := queryBool(c, h.strictQueryParams, "include_artifacts")
+	if !ok {
+		return
+	}
+	if acceptsArrow(c) {
+		includeAnnotations, ok := queryBool(c, h.strictQueryParams, "include_annotations")
+		if !ok {
+			return
+		}
+		if includeArtifacts || includeAnnotations {
+			respond(c, http.StatusBadRequest, gin.H{"error": "include_artifacts and include_annotations cannot be combined with Arrow responses"})
+			return
+		}
+		params.MetricName = metricName
+		h.arrowMetrics(c, runID, params)
+		return
+	}
+	if params.Stream {
+		if includeArtifacts {
+			respond(c, http.StatusBadRequest, gin.H{"error": "include_artifacts cannot be combined with stream"})
+			return
+		}
+		params.MetricName = metricName
+		h.streamMetrics(c, runID, params, gin.H{"run_id": runID, "metric_name": metricName})
+		return
+	}
+
+	var metrics []model.Metric
+	var total int64
+	if downsample > 0 {
+		metrics, total, err = h.service.DownsampleMetricHistory(c.Request.Context(), runID, metricName, params, downsample, method)
+	} else {
+		metrics, err = h.service.GetMetricHistory(c.Request.Context(), runID, metricName, params)
+	}
 	if err != nil {
+		if rowLimitExceeded(c, err) || invalidCursor(c, err) {
+			return
+		}
 		h.logger.Error("Failed to get metric history", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get metric history"})
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metric history"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := newList("metrics", metrics, gin.H{
 		"run_id":      runID,
 		"metric_name": metricName,
-		"metrics":     metrics,
-		"count":       len(metrics),
+	}).withCursor(nextCursor(metrics, params.Limit, metricPosition(params)))
+	if downsample > 0 {
+		// The whole range was read, so there is no next page
+		response.nextCursor = nil
+		response.fields["downsampled_from"] = total
+		response.fields["downsample_method"] = method
+	}
+	if smoothing != "" {
+		response.fields["smoothed"] = service.SmoothEMA(metrics, alpha, params.Direction == model.DirectionAsc)
+		response.fields["smoothing"] = smoothing
+		response.fields["alpha"] = alpha
+	}
+
+	if includeArtifacts {
+		artifacts, err := h.artifacts.GetArtifactsForMetrics(c.Request.Context(), runID, metrics)
+		if err != nil {
+			h.logger.Error("Failed to get artifacts for metric history", zap.Error(err))
+			respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metric history"})
+			return
+		}
+		response.fields["artifacts"] = artifacts
+	}
+
+	if !h.addAnnotations(c, runID, params, response.fields) {
+		return
+	}
+
+	respond(c, http.StatusOK, response)
+}
+
+// maxMetricNames is the most metrics one query may name in metric_names
+const maxMetricNames = 100
+
+// metricNames splits the metric_names of a query into names and checks its
+// metric_name_pattern, answering 400 and returning false for too many names,
+// names with metric_name or an invalid regular expression
+func metricNames(c *gin.Context, params *model.MetricQueryParams) bool {
+	if params.PatternType == model.PatternRegex {
+		if _, err := regexp.Compile(params.MetricNamePattern); err != nil {
+			invalidQueryParam(c, "metric_name_pattern", params.MetricNamePattern, "must be a regular expression")
+			return false
+		}
+	}
+	params.MetricNames = parseNameList(params.MetricNames)
+	switch {
+	case len(params.MetricNames) == 0:
+		return true
+	case params.MetricName != "":
+		respond(c, http.StatusBadRequest, gin.H{"error": "metric_names cannot be combined with metric_name"})
+		return false
+	case len(params.MetricNames) > maxMetricNames:
+		respond(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("metric_names must name at most %d metrics", maxMetricNames)})
+		return false
+	}
+	return true
+}
+
+// seriesData lists the series of groupByName as the v2 data of a metric
+// query, one {"metric_name", "metrics"} object per name in the order of
+// names. A field mask leaving out the series leaves the list empty.
+func seriesData(names []string) func(items interface{}) interface{} {
+	return func(items interface{}) interface{} {
+		data := make([]gin.H, 0, len(names))
+		series := reflect.ValueOf(items)
+		if series.Kind() != reflect.Map {
+			return data
+		}
+		for _, name := range names {
+			if metrics := series.MapIndex(reflect.ValueOf(name)); metrics.IsValid() {
+				data = append(data, gin.H{"metric_name": name, "metrics": metrics.Interface()})
+			}
+		}
+		return data
+	}
+}
+
+// groupByName groups metrics by name, with a series, possibly empty, for
+// each of names
+func groupByName(metrics []model.Metric, names []string) map[string][]model.Metric {
+	series := make(map[string][]model.Metric, len(names))
+	for _, name := range names {
+		series[name] = []model.Metric{}
+	}
+	for _, m := range metrics {
+		series[m.MetricName] = append(series[m.MetricName], m)
+	}
+	return series
+}
+
+// metricLimit applies the default limit of a metric query and the cap of its
+// response mode, leaving no limit for all=true; it reports false after
+// writing an error
+func metricLimit(c *gin.Context, params *model.MetricQueryParams) bool {
+	if params.All {
+		switch {
+		case !params.Stream:
+			respond(c, http.StatusBadRequest, gin.H{"error": "all requires stream=true"})
+			return false
+		case params.Limit != 0:
+			respond(c, http.StatusBadRequest, gin.H{"error": "all cannot be combined with limit"})
+			return false
+		}
+		return true
+	}
+	if params.Limit == 0 {
+		params.Limit = model.DefaultMetricQueryLimit
+	}
+	max := model.MaxMetricQueryLimit
+	if params.Stream {
+		max = model.MaxStreamedMetricQueryLimit
+	}
+	if params.Limit > max {
+		respond(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be at most %d", max)})
+		return false
+	}
+	return true
+}
+
+// streamMetrics writes the metrics of a query as they are read, next to
+// fields and, when requested, the annotations in range
+func (h *MetricHandler) streamMetrics(c *gin.Context, runID uuid.UUID, params model.MetricQueryParams, fields gin.H) {
+	if !h.addAnnotations(c, runID, params, fields) {
+		return
+	}
+
+	stream := newJSONStream(c, "metrics", fields, params.Limit)
+	var last model.Metric
+	err := h.service.StreamRunMetrics(c.Request.Context(), runID, params, func(m model.Metric) error {
+		last = m
+		return stream.Write(m)
 	})
+	if err == nil {
+		var next *string
+		if params.Limit > 0 && stream.count >= params.Limit {
+			next = nextCursor([]model.Metric{last}, 1, metricPosition(params))
+		}
+		stream.SetNextCursor(next)
+		err = stream.Close()
+	}
+	if err != nil {
+		if !stream.Started() && invalidCursor(c, err) {
+			return
+		}
+		h.logger.Error("Failed to stream metrics", zap.Error(err))
+		if !stream.Started() {
+			respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metrics"})
+		}
+	}
+}
+
+// addAnnotations adds the run's annotations within the query range to the
+// response when ?include_annotations=true; it reports false after writing an error
+func (h *MetricHandler) addAnnotations(c *gin.Context, runID uuid.UUID, params model.MetricQueryParams, response gin.H) bool {
+	include, ok := queryBool(c, h.strictQueryParams, "include_annotations")
+	if !ok || !include {
+		return ok
+	}
+
+	annotations, err := h.annotations.GetAnnotationsForQuery(c.Request.Context(), runID, params)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return false
+		}
+		h.logger.Error("Failed to get annotations for metric query", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metrics"})
+		return false
+	}
+
+	response["annotations"] = annotations
+	return true
 }
 
 // GetLatestMetric retrieves the latest value for a metric
@@ -144,29 +542,29 @@ func (h *MetricHandler) GetLatestMetric(c *gin.Context) {
 	runIDStr := c.Param("run_id")
 	runID, err := uuid.Parse(runIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
 		return
 	}
 
 	metricName := c.Param("metric_name")
 	if metricName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Metric name is required"})
+		respond(c, http.StatusBadRequest, gin.H{"error": "Metric name is required"})
 		return
 	}
 
 	metric, err := h.service.GetLatestMetric(c.Request.Context(), runID, metricName)
 	if err != nil {
 		h.logger.Error("Failed to get latest metric", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get latest metric"})
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get latest metric"})
 		return
 	}
 
 	if metric == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Metric not found"})
+		respond(c, http.StatusNotFound, gin.H{"error": "Metric not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, metric)
+	respond(c, http.StatusOK, metric)
 }
 
 // GetMetricStats retrieves statistics for a metric
@@ -174,71 +572,296 @@ func (h *MetricHandler) GetMetricStats(c *gin.Context) {
 	runIDStr := c.Param("run_id")
 	runID, err := uuid.Parse(runIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
 		return
 	}
 
 	metricName := c.Param("metric_name")
 	if metricName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Metric name is required"})
+		respond(c, http.StatusBadRequest, gin.H{"error": "Metric name is required"})
 		return
 	}
 
 	stats, err := h.service.GetMetricStats(c.Request.Context(), runID, metricName)
 	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
 		h.logger.Error("Failed to get metric stats", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get metric stats"})
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metric stats"})
 		return
 	}
 
 	if stats == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Metric not found"})
+		respond(c, http.StatusNotFound, gin.H{"error": "Metric not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, stats)
+	respond(c, http.StatusOK, stats)
 }
 
-// GetSystemMetrics retrieves system metrics for a run
-func (h *MetricHandler) GetSystemMetrics(c *gin.Context) {
+// GetMetricAggregate aggregates a metric per time bucket, reporting fn (avg
+// by default) of the values of each with their minimum, maximum and count
+func (h *MetricHandler) GetMetricAggregate(c *gin.Context) {
 	runIDStr := c.Param("run_id")
 	runID, err := uuid.Parse(runIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
 		return
 	}
 
-	var startTime, endTime *time.Time
-	if st := c.Query("start_time"); st != "" {
-		t, err := time.Parse(time.RFC3339, st)
-		if err == nil {
-			startTime = &t
+	metricName := c.Param("metric_name")
+	if metricName == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Metric name is required"})
+		return
+	}
+
+	var params model.MetricBucketParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	bucket, err := parseBucketWidth(params.Bucket)
+	if err != nil {
+		invalidQueryParam(c, "bucket", params.Bucket, err.Error())
+		return
+	}
+	if params.Fn == "" {
+		params.Fn = model.BucketAvg
+	}
+
+	points, err := h.service.GetMetricBuckets(c.Request.Context(), runID, metricName, bucket, params)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
 		}
+		h.logger.Error("Failed to aggregate metric", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to aggregate metric"})
+		return
+	}
+	if points == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Metric not found"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("points", points, gin.H{
+		"run_id":         runID,
+		"metric_name":    metricName,
+		"bucket_seconds": int(bucket / time.Second),
+		"fn":             params.Fn,
+	}))
+}
+
+// GetMetricStatsBatch retrieves statistics for several metrics of a run at once
+func (h *MetricHandler) GetMetricStatsBatch(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.MetricStatsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	if et := c.Query("end_time"); et != "" {
-		t, err := time.Parse(time.RFC3339, et)
-		if err == nil {
-			endTime = &t
+
+	stats, err := h.service.GetMetricStatsBatch(c.Request.Context(), runID, req.MetricNames)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
 		}
+		h.logger.Error("Failed to get metric stats", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metric stats"})
+		return
 	}
 
-	limit := 1000
-	if l := c.Query("limit"); l != "" {
-		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
+	respond(c, http.StatusOK, newList("stats", stats, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// CompareMetrics aligns one metric of several runs by step
+func (h *MetricHandler) CompareMetrics(c *gin.Context) {
+	var req model.MetricCompareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comparison, err := h.service.CompareMetric(c.Request.Context(), req)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
 		}
+		h.logger.Error("Failed to compare metric", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to compare metric"})
+		return
+	}
+
+	respond(c, http.StatusOK, comparison)
+}
+
+// DeleteMetricRange deletes the values of a metric of a run in a step range,
+// or all of them without min_step and max_step
+func (h *MetricHandler) DeleteMetricRange(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	metricName := c.Param("metric_name")
+	if metricName == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Metric name is required"})
+		return
+	}
+
+	var params model.DeleteMetricParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	metrics, err := h.service.GetSystemMetrics(c.Request.Context(), runID, startTime, endTime, limit)
+	deletion, err := h.service.DeleteMetricRange(c.Request.Context(), runID, metricName, params)
 	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to delete metric values", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete metric values"})
+		return
+	}
+	if deletion.DeletedRows == 0 {
+		respond(c, http.StatusNotFound, gin.H{"error": "No values of the metric in range"})
+		return
+	}
+
+	respond(c, http.StatusOK, deletion)
+}
+
+// GetMetricDeletions lists the audit records of the metric deletions of a run
+func (h *MetricHandler) GetMetricDeletions(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	deletions, err := h.service.GetMetricDeletions(c.Request.Context(), runID)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to get metric deletions", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metric deletions"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("deletions", deletions, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// GetMetricTree lists the metrics of a run nested by namespace
+func (h *MetricHandler) GetMetricTree(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	tree, err := h.service.GetMetricTree(c.Request.Context(), runID)
+	if err != nil {
+		h.logger.Error("Failed to get metric tree", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metric tree"})
+		return
+	}
+
+	metrics := 0
+	for _, node := range tree {
+		metrics += node.MetricCount
+	}
+	respond(c, http.StatusOK, newList("tree", tree, gin.H{
+		"run_id":       runID,
+		"metric_count": metrics,
+	}))
+}
+
+// GetMetricNames lists the metrics of a run with their number of values,
+// step range and latest value
+func (h *MetricHandler) GetMetricNames(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	names, err := h.service.ListMetricNameSummaries(c.Request.Context(), runID)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to list metric names", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list metric names"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("metrics", names, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// GetSystemMetrics retrieves system metrics for a run
+func (h *MetricHandler) GetSystemMetrics(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	// Malformed times and limits are ignored unless strict, as they always were
+	params := model.SystemMetricQueryParams{Cursor: c.Query("cursor")}
+	var ok bool
+	if params.StartTime, ok = queryTime(c, h.strictQueryParams, "start_time"); !ok {
+		return
+	}
+	if params.EndTime, ok = queryTime(c, h.strictQueryParams, "end_time"); !ok {
+		return
+	}
+	if params.Limit, ok = queryInt(c, h.strictQueryParams, "limit", model.DefaultMetricQueryLimit, 1, math.MaxInt); !ok {
+		return
+	}
+
+	metrics, err := h.service.GetSystemMetrics(c.Request.Context(), runID, params)
+	if err != nil {
+		if invalidCursor(c, err) {
+			return
+		}
 		h.logger.Error("Failed to get system metrics", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get system metrics"})
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get system metrics"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"run_id":  runID,
-		"metrics": metrics,
-		"count":   len(metrics),
-	})
+	respond(c, http.StatusOK, newList("metrics", metrics, gin.H{
+		"run_id": runID,
+	}).withCursor(nextCursor(metrics, params.Limit, systemMetricPosition)))
 }
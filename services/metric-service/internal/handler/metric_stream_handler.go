@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// maxStreamLine is the longest line of a metric stream, one metric
+const maxStreamLine = 1 << 20
+
+// metricWriter writes batches of metrics, as MetricService does
+type metricWriter interface {
+	BatchWriteWithOptions(ctx context.Context, metrics []model.Metric, opts model.IngestOptions) error
+}
+
+// MetricStreamHandler ingests metrics sent as newline-delimited JSON on one
+// long-lived request, so that agents stream values as they log them rather
+// than batching them. Metrics are written in chunks of chunkSize, and at
+// least every flushInterval while the stream is slower.
+type MetricStreamHandler struct {
+	service       metricWriter
+	chunkSize     int
+	flushInterval time.Duration
+	logger        *zap.Logger
+}
+
+func NewMetricStreamHandler(service *service.MetricService, chunkSize int, flushInterval time.Duration, logger *zap.Logger) *MetricStreamHandler {
+	return &MetricStreamHandler{
+		service:       service,
+		chunkSize:     chunkSize,
+		flushInterval: flushInterval,
+		logger:        logger,
+	}
+}
+
+// streamLine is a metric read from a stream, or the error ending it
+type streamLine struct {
+	line   int
+	metric model.Metric
+	err    error
+}
+
+// Ingest reads the metrics of a stream until its body ends. Whether it ends
+// well or not, the first "written" metrics of the stream were written and
+// none after them, so that a client resends from the next; errors name the
+// offending "line".
+func (h *MetricStreamHandler) Ingest(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	lines := make(chan streamLine, h.chunkSize)
+	go readMetricLines(ctx, c.Request.Body, lines)
+
+	ticker := time.NewTicker(h.flushInterval)
+	defer ticker.Stop()
+
+	// chunk holds the metrics not yet written, read from chunkLines
+	var chunk []model.Metric
+	var chunkLines []int
+	written, chunks := 0, 0
+	checked := make(map[uuid.UUID]bool)
+	// flush writes the chunk, answering and returning false when it fails
+	// or is shed
+	flush := func() bool {
+		if len(chunk) == 0 {
+			return true
+		}
+		runIDs := make([]uuid.UUID, len(chunk))
+		for i, m := range chunk {
+			runIDs[i] = m.RunID
+		}
+		if !admitIngestWith(c, runIDs, gin.H{"written": written}) {
+			return false
+		}
+		if err := h.service.BatchWriteWithOptions(c.Request.Context(), chunk, model.IngestOptions{}); err != nil {
+			h.writeError(c, err, chunkLines, written)
+			return false
+		}
+		written += len(chunk)
+		chunks++
+		// Observers may hold on to the metrics written
+		chunk, chunkLines = nil, nil
+		return true
+	}
+
+	for {
+		select {
+		case l, ok := <-lines:
+			if !ok {
+				if flush() {
+					respond(c, http.StatusOK, gin.H{"written": written, "chunks": chunks})
+				}
+				return
+			}
+			if l.err != nil {
+				if flush() {
+					respond(c, http.StatusBadRequest, gin.H{"error": l.err.Error(), "line": l.line, "written": written})
+				}
+				return
+			}
+			// A run is checked once, after what came before it is written,
+			// so that a rejection leaves nothing unwritten ahead of it
+			if !checked[l.metric.RunID] {
+				if !flush() || !checkRuns(c, []uuid.UUID{l.metric.RunID}) {
+					return
+				}
+				checked[l.metric.RunID] = true
+			}
+			chunk = append(chunk, l.metric)
+			chunkLines = append(chunkLines, l.line)
+			if len(chunk) >= h.chunkSize && !flush() {
+				return
+			}
+		case <-ticker.C:
+			if !flush() {
+				return
+			}
+		}
+	}
+}
+
+// writeError answers for a chunk, read from lines, that failed to be written
+func (h *MetricStreamHandler) writeError(c *gin.Context, err error, lines []int, written int) {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		// The chunk is rejected as a whole; items index into it
+		line := lines[0]
+		if len(validationErr.Items) > 0 {
+			line = lines[validationErr.Items[0].Index]
+		}
+		respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error(), "line": line, "written": written})
+	case errors.Is(err, service.ErrIngestPoolStopped):
+		respond(c, http.StatusServiceUnavailable, gin.H{"error": "Metric ingest is shutting down", "written": written})
+	default:
+		h.logger.Error("Failed to write streamed metrics", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to write metrics", "written": written})
+	}
+}
+
+// readMetricLines decodes the lines of body into lines, skipping blank ones,
+// until the body or a line fails or ctx is done. Lines are numbered from 1.
+func readMetricLines(ctx context.Context, body io.Reader, lines chan<- streamLine) {
+	defer close(lines)
+	send := func(l streamLine) bool {
+		select {
+		case lines <- l:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxStreamLine)
+	n := 0
+	for scanner.Scan() {
+		n++
+		data := scanner.Bytes()
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		var m model.Metric
+		if err := json.Unmarshal(model.QuoteNonFiniteJSON(data), &m); err != nil {
+			send(streamLine{line: n, err: fmt.Errorf("invalid metric: %w", err)})
+			return
+		}
+		if err := binding.Validator.ValidateStruct(&m); err != nil {
+			send(streamLine{line: n, err: fmt.Errorf("invalid metric: %w", err)})
+			return
+		}
+		if !send(streamLine{line: n, metric: m}) {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = fmt.Errorf("line is longer than %d bytes", maxStreamLine)
+		}
+		send(streamLine{line: n + 1, err: err})
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// chunkRecorder records the chunks written, rejecting metrics named "bad"
+// as the service rejects invalid ones
+type chunkRecorder struct {
+	chunks [][]model.Metric
+}
+
+func (r *chunkRecorder) BatchWriteWithOptions(ctx context.Context, metrics []model.Metric, opts model.IngestOptions) error {
+	var items []service.ItemError
+	for i, m := range metrics {
+		if m.MetricName == "bad" {
+			items = append(items, service.ItemError{Index: i, Field: "metric_name", Reason: "metric_name is bad"})
+		}
+	}
+	if err := service.NewBatchValidationError("metric", items); err != nil {
+		return err
+	}
+	r.chunks = append(r.chunks, metrics)
+	return nil
+}
+
+func TestMetricStreamIngest(t *testing.T) {
+	runID := uuid.New()
+	line := func(name string, step int) string {
+		return `{"run_id":"` + runID.String() + `","metric_name":"` + name + `","step":` + strconv.Itoa(step) + `,"value":NaN}` + "\n"
+	}
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantWritten int
+		wantLine    int
+		wantChunks  []int
+	}{
+		{"chunks", line("loss", 1) + "\n" + line("loss", 2) + line("loss", 3), http.StatusOK, 3, 0, []int{2, 1}},
+		{"malformed line", line("loss", 1) + line("loss", 2) + line("loss", 3) + "{\n", http.StatusBadRequest, 3, 4, []int{2, 1}},
+		{"invalid metric", line("loss", 1) + line("loss", 2) + "\n" + line("acc", 3) + line("bad", 4), http.StatusBadRequest, 2, 5, []int{2}},
+		{"invalid value type", `{"run_id":"` + runID.String() + `","metric_name":"phase","value_type":"date","value":1}`, http.StatusBadRequest, 0, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &chunkRecorder{}
+			h := &MetricStreamHandler{service: recorder, chunkSize: 2, flushInterval: time.Hour, logger: zap.NewNop()}
+			router := gin.New()
+			router.POST("/metrics/stream", h.Ingest)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics/stream", strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			var resp struct {
+				Written int `json:"written"`
+				Line    int `json:"line"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Written != tt.wantWritten || resp.Line != tt.wantLine {
+				t.Errorf("written %d, line %d; want %d, %d: %s", resp.Written, resp.Line, tt.wantWritten, tt.wantLine, w.Body)
+			}
+			if len(recorder.chunks) != len(tt.wantChunks) {
+				t.Fatalf("wrote %d chunks, want %v", len(recorder.chunks), tt.wantChunks)
+			}
+			for i, n := range tt.wantChunks {
+				if len(recorder.chunks[i]) != n {
+					t.Errorf("chunk %d holds %d metrics, want %d", i, len(recorder.chunks[i]), n)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricStreamFlushInterval(t *testing.T) {
+	runID := uuid.New()
+	recorder := &chunkRecorder{}
+	h := &MetricStreamHandler{service: recorder, chunkSize: 100, flushInterval: 10 * time.Millisecond, logger: zap.NewNop()}
+	router := gin.New()
+	router.POST("/metrics/stream", h.Ingest)
+
+	body, pw := io.Pipe()
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics/stream", body))
+		done <- w
+	}()
+	pw.Write([]byte(`{"run_id":"` + runID.String() + `","metric_name":"loss","value":1}` + "\n"))
+	time.Sleep(100 * time.Millisecond)
+	pw.Close()
+	w := <-done
+	if w.Code != http.StatusOK || len(recorder.chunks) != 1 {
+		t.Errorf("status %d, %d chunks; want the metric written before the stream ended", w.Code, len(recorder.chunks))
+	}
+}
+
+func TestMetricStreamIngestShed(t *testing.T) {
+	runID := uuid.New()
+	recorder := &chunkRecorder{}
+	h := &MetricStreamHandler{service: recorder, chunkSize: 2, flushInterval: time.Hour, logger: zap.NewNop()}
+	router := gin.New()
+	// A burst of 2 admits the first chunk only
+	router.POST("/metrics/stream", IngestLimit(service.NewIngestLimiter(service.IngestLimits{Rate: 1, Burst: 2})), h.Ingest)
+
+	var body strings.Builder
+	for step := 1; step <= 4; step++ {
+		body.WriteString(`{"run_id":"` + runID.String() + `","metric_name":"loss","step":` + strconv.Itoa(step) + `,"value":1}` + "\n")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics/stream", strings.NewReader(body.String())))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("status %d, Retry-After %q; want 429 with Retry-After: %s", w.Code, w.Header().Get("Retry-After"), w.Body)
+	}
+	var resp struct {
+		Written int `json:"written"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Written != 2 || len(recorder.chunks) != 1 {
+		t.Errorf("written %d in %d chunks, want the first chunk of 2 only: %s", resp.Written, len(recorder.chunks), w.Body)
+	}
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type NodeHandler struct {
+	service *service.NodeService
+	logger  *zap.Logger
+}
+
+func NewNodeHandler(service *service.NodeService, logger *zap.Logger) *NodeHandler {
+	return &NodeHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetMetricByRank aggregates a training metric across ranks, or per rank with reduce=none
+func (h *NodeHandler) GetMetricByRank(c *gin.Context) {
+	runID, params, ok := h.bindNodeQuery(c)
+	if !ok {
+		return
+	}
+	metricName := c.Param("metric_name")
+
+	result, err := h.service.GetMetricByRank(c.Request.Context(), runID, metricName, params)
+	if h.handleError(c, err, "Failed to aggregate metric across ranks") {
+		return
+	}
+
+	fields := gin.H{"run_id": runID, "metric_name": metricName}
+	h.writeAggregate(c, result, fields)
+}
+
+// GetSystemMetricsByNode aggregates a system metric type across nodes, or per node with reduce=none
+func (h *NodeHandler) GetSystemMetricsByNode(c *gin.Context) {
+	runID, params, ok := h.bindNodeQuery(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.GetSystemMetricByNode(c.Request.Context(), runID, params)
+	if h.handleError(c, err, "Failed to aggregate system metrics across nodes") {
+		return
+	}
+
+	fields := gin.H{"run_id": runID, "metric_type": params.MetricType, "bucket_seconds": result.Params.Bucket}
+	h.writeAggregate(c, result, fields)
+}
+
+// GetGPUMetricsByNode aggregates a GPU reading across all GPUs, or per node with reduce=none
+func (h *NodeHandler) GetGPUMetricsByNode(c *gin.Context) {
+	runID, params, ok := h.bindNodeQuery(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.GetGPUFieldByNode(c.Request.Context(), runID, params)
+	if h.handleError(c, err, "Failed to aggregate GPU metrics across nodes") {
+		return
+	}
+
+	fields := gin.H{"run_id": runID, "field": result.Params.Field, "bucket_seconds": result.Params.Bucket}
+	h.writeAggregate(c, result, fields)
+}
+
+func (h *NodeHandler) bindNodeQuery(c *gin.Context) (uuid.UUID, model.NodeQueryParams, bool) {
+	var params model.NodeQueryParams
+
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return runID, params, false
+	}
+
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return runID, params, false
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 1000
+	}
+	return runID, params, true
+}
+
+// handleError writes the response for a failed query and reports whether there was one
+func (h *NodeHandler) handleError(c *gin.Context, err error, message string) bool {
+	if err == nil {
+		return false
+	}
+
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return true
+	}
+	h.logger.Error(message, zap.Error(err))
+	respond(c, http.StatusInternalServerError, gin.H{"error": message})
+	return true
+}
+
+func (h *NodeHandler) writeAggregate(c *gin.Context, result *service.NodeAggregate, fields gin.H) {
+	fields["reduce"] = result.Params.Reduce
+
+	if result.Params.Reduce == model.ReduceNone {
+		respond(c, http.StatusOK, newList("series", result.Series, fields))
+		return
+	}
+	respond(c, http.StatusOK, newList("points", result.Points, fields))
+}
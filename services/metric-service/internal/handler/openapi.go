@@ -0,0 +1,717 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/openapi"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// Example values giving the types of the fields of gin.H responses
+var (
+	anyID     uuid.UUID
+	anyCount  int
+	anyName   string
+	anyCursor *string
+	anyNumber float64
+)
+
+// limitOffset are the paging parameters of handlers reading them by hand
+type limitOffset struct {
+	Limit  int `form:"limit"`
+	Offset int `form:"offset"`
+}
+
+// tagFilters are the key:value tag filters of the run search endpoints
+type tagFilters struct {
+	Tag   []string `form:"tag"`
+	Limit int      `form:"limit"`
+}
+
+// APIRoutes describes the models of the API routes, keyed by method and path
+// without the version prefix, for the OpenAPI document. Routes left out are
+// documented with their path parameters only.
+var APIRoutes = map[string]openapi.Route{
+	// Metrics
+	"POST /metrics/batch": {
+		Summary: "Write a batch of metrics",
+		Query: struct {
+			Sync    bool `form:"sync"`
+			Partial bool `form:"partial"`
+		}{},
+		Body:     model.MetricBatchRequest{},
+		Status:   http.StatusCreated,
+		Response: openapi.Fields{"message": anyName, "count": anyCount, "errors": []service.ItemError{}},
+	},
+	"GET /runs/:run_id/metrics": {
+		Summary: "Query the metrics of a run",
+		Query: struct {
+			model.MetricQueryParams
+			IncludeAnnotations bool `form:"include_annotations"`
+		}{},
+		Response: openapi.Fields{
+			"run_id": anyID, "metrics": []model.Metric{}, "series": map[string][]model.Metric{}, "count": anyCount,
+			"next_cursor": anyCursor, "annotations": []model.Annotation{},
+		},
+	},
+	"GET /runs/:run_id/metrics/:metric_name": {
+		Summary: "Query the history of a metric",
+		Query: struct {
+			model.MetricQueryParams
+			IncludeArtifacts   bool    `form:"include_artifacts"`
+			IncludeAnnotations bool    `form:"include_annotations"`
+			Downsample         int     `form:"downsample"`
+			DownsampleMethod   string  `form:"downsample_method"`
+			Smoothing          string  `form:"smoothing"`
+			Alpha              float64 `form:"alpha"`
+		}{},
+		Response: openapi.Fields{
+			"run_id": anyID, "metric_name": anyName, "metrics": []model.Metric{}, "count": anyCount,
+			"next_cursor": anyCursor, "artifacts": []model.ArtifactRef{}, "annotations": []model.Annotation{},
+			"downsampled_from": anyCount, "downsample_method": anyName,
+			"smoothed": []*float64{}, "smoothing": anyName, "alpha": anyNumber,
+		},
+	},
+	"GET /runs/:run_id/metrics/:metric_name/latest": {
+		Summary:  "Get the latest value of a metric",
+		Response: model.Metric{},
+	},
+	"GET /runs/:run_id/metrics/:metric_name/stats": {
+		Summary:  "Get statistics of a metric",
+		Response: model.MetricStats{},
+	},
+	"GET /runs/:run_id/metrics/:metric_name/aggregate": {
+		Summary: "Aggregate a metric per time bucket",
+		Query:   model.MetricBucketParams{},
+		Response: openapi.Fields{
+			"run_id": anyID, "metric_name": anyName, "bucket_seconds": anyCount, "fn": anyName,
+			"points": []model.AggregatePoint{}, "count": anyCount,
+		},
+	},
+	"POST /runs/:run_id/metrics/stats": {
+		Summary:  "Get statistics of several metrics",
+		Body:     model.MetricStatsRequest{},
+		Response: openapi.Fields{"run_id": anyID, "stats": []model.MetricStats{}, "count": anyCount},
+	},
+	"POST /metrics/compare": {
+		Summary:  "Compare a metric of several runs by step",
+		Body:     model.MetricCompareRequest{},
+		Response: model.MetricComparison{},
+	},
+	"DELETE /runs/:run_id/metrics/:metric_name": {
+		Summary:  "Delete the values of a metric in a step range",
+		Query:    model.DeleteMetricParams{},
+		Response: model.MetricDeletion{},
+	},
+	"GET /runs/:run_id/metric-deletions": {
+		Summary:  "List the metric deletions of a run",
+		Response: openapi.Fields{"run_id": anyID, "deletions": []model.MetricDeletion{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/metrics/count": {
+		Summary:  "Count the metrics of a run",
+		Query:    model.MetricQueryParams{},
+		Response: openapi.Fields{"run_id": anyID, "total": anyCount},
+	},
+	"GET /runs/:run_id/metrics/export": {
+		Summary: "Export the metrics of a run as a file",
+		Query: struct {
+			model.MetricQueryParams
+			Format string `form:"format"`
+			Offset int    `form:"offset"`
+		}{},
+	},
+	"GET /runs/:run_id/metrics/openmetrics": {
+		Summary: "Scrape the latest value of each metric of a run in the OpenMetrics format",
+	},
+	"GET /runs/:run_id/metric-names": {
+		Summary:  "List the metrics of a run",
+		Response: openapi.Fields{"run_id": anyID, "metrics": []model.MetricNameSummary{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/metrics/tree": {
+		Summary:  "List the metrics of a run by namespace",
+		Response: openapi.Fields{"run_id": anyID, "tree": []*model.MetricTreeNode{}, "count": anyCount, "metric_count": anyCount},
+	},
+	"GET /runs/:run_id/metrics/:metric_name/forecast": {
+		Summary:  "Forecast a metric",
+		Query:    model.ForecastParams{},
+		Response: model.Forecast{},
+	},
+	"POST /metrics/system/batch": {
+		Summary:  "Write a batch of system metrics",
+		Body:     model.SystemMetricBatchRequest{},
+		Status:   http.StatusCreated,
+		Response: openapi.Fields{"message": anyName, "count": anyCount},
+	},
+	"GET /runs/:run_id/system-metrics": {
+		Summary:  "Query the system metrics of a run",
+		Query:    model.SystemMetricQueryParams{},
+		Response: openapi.Fields{"run_id": anyID, "metrics": []model.SystemMetric{}, "count": anyCount, "next_cursor": anyCursor},
+	},
+
+	// GPU metrics
+	"POST /metrics/gpu/batch": {
+		Summary:  "Write a batch of GPU metrics",
+		Body:     model.GPUMetricBatchRequest{},
+		Status:   http.StatusCreated,
+		Response: openapi.Fields{"message": anyName, "count": anyCount},
+	},
+	"GET /runs/:run_id/gpu-metrics": {
+		Summary:  "Query the GPU metrics of a run by device",
+		Query:    model.GPUMetricQueryParams{},
+		Response: openapi.Fields{"run_id": anyID, "devices": []model.GPUDeviceSeries{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/gpu-metrics/summary": {
+		Summary:  "Summarize the GPU metrics of a run by device",
+		Response: openapi.Fields{"run_id": anyID, "devices": []model.GPUDeviceSummary{}, "count": anyCount},
+	},
+
+	// Multi-node aggregation
+	"GET /runs/:run_id/metrics/:metric_name/ranks": {
+		Summary: "Aggregate a metric across ranks",
+		Query:   model.NodeQueryParams{},
+		Response: openapi.Fields{
+			"run_id": anyID, "metric_name": anyName, "reduce": anyName,
+			"points": []model.AggregatePoint{}, "series": []model.NodeSeries{}, "count": anyCount,
+		},
+	},
+	"GET /runs/:run_id/system-metrics/nodes": {
+		Summary: "Aggregate a system metric across nodes",
+		Query:   model.NodeQueryParams{},
+		Response: openapi.Fields{
+			"run_id": anyID, "metric_type": anyName, "bucket_seconds": anyCount, "reduce": anyName,
+			"points": []model.AggregatePoint{}, "series": []model.NodeSeries{}, "count": anyCount,
+		},
+	},
+	"GET /runs/:run_id/gpu-metrics/nodes": {
+		Summary: "Aggregate a GPU metric across nodes",
+		Query:   model.NodeQueryParams{},
+		Response: openapi.Fields{
+			"run_id": anyID, "field": anyName, "bucket_seconds": anyCount, "reduce": anyName,
+			"points": []model.AggregatePoint{}, "series": []model.NodeSeries{}, "count": anyCount,
+		},
+	},
+
+	// Anomalies and early stopping
+	"GET /runs/:run_id/anomalies": {
+		Summary:  "List the anomalies detected in a run",
+		Query:    model.AnomalyQueryParams{},
+		Response: openapi.Fields{"run_id": anyID, "anomalies": []model.Anomaly{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/should-stop": {
+		Summary:  "Tell whether a run should stop",
+		Query:    model.ShouldStopParams{},
+		Response: model.ShouldStopResponse{},
+	},
+	"POST /runs/:run_id/stop": {
+		Summary:      "Ask a run to stop",
+		Body:         model.StopRequest{},
+		OptionalBody: true,
+		Response:     openapi.Fields{"run_id": anyID, "manual_stop": true},
+	},
+	"DELETE /runs/:run_id/stop": {
+		Summary:  "Withdraw the request to stop a run",
+		Response: openapi.Fields{"run_id": anyID, "manual_stop": false},
+	},
+
+	// Sweeps
+	"POST /sweeps": {
+		Summary:  "Create a sweep",
+		Body:     model.CreateSweepRequest{},
+		Status:   http.StatusCreated,
+		Response: model.Sweep{},
+	},
+	"GET /sweeps/:sweep_id": {
+		Summary:  "Get a sweep and its trials",
+		Response: openapi.Fields{"sweep": model.Sweep{}, "trials": []model.SweepTrial{}},
+	},
+	"POST /sweeps/:sweep_id/next": {
+		Summary:      "Suggest the next trial of a sweep",
+		Body:         model.NextTrialRequest{},
+		OptionalBody: true,
+		Status:       http.StatusCreated,
+		Response:     model.SweepTrial{},
+	},
+	"POST /sweeps/:sweep_id/trials/:trial_id/report": {
+		Summary:  "Report the result of a trial",
+		Body:     model.ReportTrialRequest{},
+		Response: model.SweepTrial{},
+	},
+	"GET /sweeps/:sweep_id/leaderboard": {
+		Summary: "Rank the trials of a sweep",
+		Query: struct {
+			Limit int `form:"limit"`
+		}{},
+		Response: openapi.Fields{
+			"sweep_id": anyID, "objective_metric": anyName, "goal": anyName,
+			"leaderboard": []model.SweepTrial{}, "count": anyCount,
+		},
+	},
+
+	// Tags
+	"GET /runs": {
+		Summary:  "Find runs by tags",
+		Query:    tagFilters{},
+		Response: openapi.Fields{"runs": []model.TaggedRun{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/tags": {
+		Summary:  "List the tags of a run",
+		Response: openapi.Fields{"run_id": anyID, "tags": []model.RunTag{}, "count": anyCount},
+	},
+	"PUT /runs/:run_id/tags": {
+		Summary:  "Set tags of a run",
+		Body:     model.SetTagsRequest{},
+		Response: openapi.Fields{"run_id": anyID, "tags": []model.RunTag{}, "count": anyCount},
+	},
+	"DELETE /runs/:run_id/tags/:key": {
+		Summary: "Remove a tag from a run",
+		Status:  http.StatusNoContent,
+	},
+	"GET /metrics/stats": {
+		Summary: "Get statistics of a metric across tagged runs",
+		Query: struct {
+			MetricName string   `form:"metric_name" binding:"required"`
+			Tag        []string `form:"tag" binding:"required"`
+		}{},
+		Response: openapi.Fields{"metric_name": anyName, "runs": []model.TaggedRunStats{}, "count": anyCount},
+	},
+
+	// Reports
+	"POST /reports": {
+		Summary:  "Create a report",
+		Body:     model.CreateReportRequest{},
+		Status:   http.StatusCreated,
+		Response: model.Report{},
+	},
+	"GET /reports": {
+		Summary:  "List reports",
+		Query:    limitOffset{},
+		Response: openapi.Fields{"reports": []model.Report{}, "count": anyCount},
+	},
+	"GET /reports/:report_id": {
+		Summary:  "Get a report",
+		Response: model.Report{},
+	},
+	"GET /reports/:report_id/data": {
+		Summary: "Render the data of a report",
+		Query: struct {
+			Live bool `form:"live"`
+		}{},
+		Response: model.Report{},
+	},
+	"PUT /reports/:report_id": {
+		Summary:  "Update a report",
+		Body:     model.UpdateReportRequest{},
+		Response: model.Report{},
+	},
+	"DELETE /reports/:report_id": {
+		Summary: "Delete a report",
+		Status:  http.StatusNoContent,
+	},
+
+	// Summaries
+	"GET /summaries": {
+		Summary: "Compare the summaries of a metric across runs",
+		Query: struct {
+			model.SummaryQueryParams
+			ProjectID string `form:"project_id"`
+		}{},
+		Response: openapi.Fields{
+			"metric_name": anyName, "sort": anyName, "group_by": anyName,
+			"runs": []model.RunMetricSummary{}, "groups": []model.GroupMetricSummary{}, "count": anyCount,
+		},
+	},
+	"GET /runs/:run_id/summary": {
+		Summary:  "Get the metric summaries of a run",
+		Response: openapi.Fields{"run_id": anyID, "metrics": []model.RunMetricSummary{}, "count": anyCount},
+	},
+	"POST /runs/:run_id/summary/recompute": {
+		Summary:  "Recompute the metric summaries of a run",
+		Response: openapi.Fields{"run_id": anyID, "metrics": anyCount},
+	},
+
+	// Groups
+	"GET /groups": {
+		Summary:  "List run groups",
+		Query:    model.GroupQueryParams{},
+		Response: openapi.Fields{"groups": []model.GroupInfo{}, "count": anyCount},
+	},
+	"GET /groups/:group/metrics/*metric_name": {
+		Summary: "Aggregate a metric across the runs of a group",
+		Query:   model.GroupMetricQueryParams{},
+		Response: openapi.Fields{
+			"group": anyName, "metric_name": anyName, "reduce": anyName,
+			"points": []model.AggregatePoint{}, "count": anyCount,
+		},
+	},
+	"GET /runs/:run_id/group": {
+		Summary:  "Get the group of a run",
+		Response: model.RunGroup{},
+	},
+	"PUT /runs/:run_id/group": {
+		Summary:  "Set the group of a run",
+		Body:     model.SetRunGroupRequest{},
+		Response: model.RunGroup{},
+	},
+	"DELETE /runs/:run_id/group": {
+		Summary: "Remove a run from its group",
+		Status:  http.StatusNoContent,
+	},
+
+	// Projects
+	"GET /projects/:project_id/runs": {
+		Summary:  "List the runs of a project",
+		Query:    model.ProjectRunQueryParams{},
+		Response: openapi.Fields{"project_id": anyID, "runs": []model.ProjectRun{}, "count": anyCount},
+	},
+	"GET /projects/:project_id/runs/latest-metrics": {
+		Summary: "Get the latest value of a metric for every active run of a project",
+		Query:   model.ProjectLatestMetricsParams{},
+		Response: openapi.Fields{
+			"project_id": anyID, "metric_name": anyName, "runs": []model.ProjectRunMetric{}, "count": anyCount,
+		},
+	},
+	"GET /projects/:project_id/experiments": {
+		Summary:  "List the experiments of a project",
+		Response: openapi.Fields{"project_id": anyID, "experiments": []model.ExperimentInfo{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/project": {
+		Summary:  "Get the project of a run",
+		Response: model.RunProject{},
+	},
+	"PUT /runs/:run_id/project": {
+		Summary:  "Set the project of a run",
+		Body:     model.SetRunProjectRequest{},
+		Response: model.RunProject{},
+	},
+
+	// Run configs
+	"GET /runs/:run_id/config": {
+		Summary:  "Get the config of a run",
+		Response: model.RunConfig{},
+	},
+	"PUT /runs/:run_id/config": {
+		Summary:  "Set the config of a run",
+		Body:     model.SetRunConfigRequest{},
+		Response: model.RunConfig{},
+	},
+	"POST /runs/diff": {
+		Summary:  "Diff the configs and summaries of runs",
+		Body:     model.RunDiffRequest{},
+		Response: model.RunDiff{},
+	},
+
+	// Project leaderboards
+	"GET /projects/:project_id/leaderboard": {
+		Summary: "Rank the runs of a project",
+		Query:   model.LeaderboardQueryParams{},
+		Response: openapi.Fields{
+			"project_id": anyID, "metric_name": anyName, "goal": anyName, "mode": anyName,
+			"entries": []model.LeaderboardEntry{}, "count": anyCount, "total": int64(0),
+			"offset": anyCount, "limit": anyCount,
+		},
+	},
+	"GET /projects/:project_id/leaderboard/config": {
+		Summary:  "Get the leaderboard config of a project",
+		Response: model.LeaderboardConfig{},
+	},
+	"PUT /projects/:project_id/leaderboard/config": {
+		Summary:  "Set the leaderboard config of a project",
+		Body:     model.SetLeaderboardRequest{},
+		Response: openapi.Fields{"leaderboard": model.LeaderboardConfig{}, "ranked_runs": int64(0)},
+	},
+	"DELETE /projects/:project_id/leaderboard/config": {
+		Summary: "Delete the leaderboard of a project",
+		Status:  http.StatusNoContent,
+	},
+
+	// Metric definitions
+	"GET /projects/:project_id/metric-definitions": {
+		Summary:  "List the metric definitions of a project",
+		Response: openapi.Fields{"project_id": anyID, "definitions": []model.MetricDefinition{}, "count": anyCount},
+	},
+	"POST /projects/:project_id/metric-definitions": {
+		Summary:  "Save a metric definition",
+		Body:     model.MetricDefinitionRequest{},
+		Response: model.MetricDefinition{},
+	},
+	"GET /projects/:project_id/metric-definitions/*name": {
+		Summary:  "Get a metric definition",
+		Response: model.MetricDefinition{},
+	},
+	"DELETE /projects/:project_id/metric-definitions/*name": {
+		Summary: "Delete a metric definition",
+		Status:  http.StatusNoContent,
+	},
+
+	// Artifacts and checkpoints
+	"POST /runs/:run_id/artifacts": {
+		Summary:  "Link an artifact to a run",
+		Body:     model.CreateArtifactRequest{},
+		Status:   http.StatusCreated,
+		Response: model.ArtifactRef{},
+	},
+	"GET /runs/:run_id/artifacts": {
+		Summary:  "List the artifacts of a run",
+		Query:    model.ArtifactQueryParams{},
+		Response: openapi.Fields{"run_id": anyID, "artifacts": []model.ArtifactRef{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/artifacts/lookup": {
+		Summary: "Find the artifact logged nearest a metric value",
+		Query:   model.ArtifactLookupParams{},
+		Response: openapi.Fields{
+			"run_id": anyID, "metric_name": anyName, "target_step": &anyCount, "artifact": model.ArtifactRef{},
+		},
+	},
+	"POST /runs/:run_id/checkpoints": {
+		Summary:  "Register a checkpoint",
+		Body:     model.RegisterCheckpointRequest{},
+		Status:   http.StatusCreated,
+		Response: model.RegisterCheckpointResponse{},
+	},
+	"GET /runs/:run_id/checkpoints/best": {
+		Summary: "Get the best checkpoints of a run",
+		Query: struct {
+			MetricName string `form:"metric_name"`
+		}{},
+		Response: openapi.Fields{"run_id": anyID, "best": []model.BestCheckpoint{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/checkpoints/objectives": {
+		Summary:  "Get the checkpoint objectives of a run",
+		Response: openapi.Fields{"run_id": anyID, "objectives": []model.CheckpointObjective{}, "count": anyCount},
+	},
+	"PUT /runs/:run_id/checkpoints/objectives": {
+		Summary:  "Set the checkpoint objectives of a run",
+		Body:     model.SetCheckpointObjectivesRequest{},
+		Response: openapi.Fields{"run_id": anyID, "objectives": []model.CheckpointObjective{}, "count": anyCount},
+	},
+
+	// Media
+	"POST /runs/:run_id/media": {
+		Summary:  "Upload a media file",
+		Form:     model.MediaUploadRequest{},
+		File:     "file",
+		Status:   http.StatusCreated,
+		Response: model.MediaItem{},
+	},
+	"GET /runs/:run_id/media": {
+		Summary:  "List the media of a run",
+		Query:    model.MediaQueryParams{},
+		Response: openapi.Fields{"run_id": anyID, "media": []model.MediaItem{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/media/:media_id/content": {
+		Summary: "Download a media file",
+	},
+
+	// Histograms
+	"POST /metrics/histograms/batch": {
+		Summary:  "Write a batch of histograms",
+		Body:     model.HistogramBatchRequest{},
+		Status:   http.StatusCreated,
+		Response: openapi.Fields{"message": anyName, "count": anyCount},
+	},
+	"GET /runs/:run_id/histograms": {
+		Summary:  "List the histogram series of a run",
+		Response: openapi.Fields{"run_id": anyID, "histograms": []model.HistogramSeries{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/histograms/:metric_name": {
+		Summary:  "Query the history of a histogram",
+		Query:    model.HistogramQueryParams{},
+		Response: openapi.Fields{"run_id": anyID, "metric_name": anyName, "histograms": []model.HistogramMetric{}, "count": anyCount},
+	},
+
+	// Embeddings
+	"POST /metrics/embeddings/batch": {
+		Summary:  "Write a batch of embeddings",
+		Body:     model.EmbeddingBatchRequest{},
+		Status:   http.StatusCreated,
+		Response: openapi.Fields{"message": anyName, "count": anyCount},
+	},
+	"GET /runs/:run_id/embeddings": {
+		Summary:  "List the embedding series of a run",
+		Response: openapi.Fields{"run_id": anyID, "embeddings": []model.EmbeddingSeries{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/embeddings/:metric_name": {
+		Summary:  "Query the embeddings of a series",
+		Query:    model.EmbeddingQueryParams{},
+		Response: openapi.Fields{"run_id": anyID, "metric_name": anyName, "embeddings": []model.Embedding{}, "count": anyCount},
+	},
+	"POST /runs/:run_id/embeddings/:metric_name/nearest": {
+		Summary:  "Find the nearest embeddings to a vector",
+		Body:     model.NearestRequest{},
+		Response: model.NearestResult{},
+	},
+	"GET /runs/:run_id/embeddings/:metric_name/drift": {
+		Summary:  "Measure the drift of an embedding series",
+		Query:    model.EmbeddingDriftParams{},
+		Response: openapi.Fields{"run_id": anyID, "metric_name": anyName, "drift": []model.EmbeddingDriftPoint{}, "count": anyCount},
+	},
+
+	// Tables
+	"POST /metrics/tables/batch": {
+		Summary:  "Write a batch of tables",
+		Body:     model.TableBatchRequest{},
+		Status:   http.StatusCreated,
+		Response: openapi.Fields{"message": anyName, "count": anyCount},
+	},
+	"GET /runs/:run_id/tables": {
+		Summary:  "List the table series of a run",
+		Response: openapi.Fields{"run_id": anyID, "tables": []model.TableSeries{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/tables/:metric_name": {
+		Summary:  "Query the history of a table",
+		Query:    model.TableQueryParams{},
+		Response: openapi.Fields{"run_id": anyID, "metric_name": anyName, "tables": []model.TableMetric{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/tables/:metric_name/latest": {
+		Summary:  "Get the latest version of a table",
+		Response: model.TableMetric{},
+	},
+
+	// Logs
+	"POST /runs/:run_id/logs": {
+		Summary:  "Write log lines",
+		Body:     model.LogBatchRequest{},
+		Status:   http.StatusCreated,
+		Response: openapi.Fields{"message": anyName, "count": anyCount},
+	},
+	"GET /runs/:run_id/logs": {
+		Summary:  "Query the logs of a run",
+		Query:    model.LogQueryParams{},
+		Response: openapi.Fields{"run_id": anyID, "lines": []model.LogLine{}, "count": anyCount},
+	},
+
+	// Annotations
+	"POST /runs/:run_id/annotations": {
+		Summary:  "Annotate a run",
+		Body:     model.CreateAnnotationRequest{},
+		Status:   http.StatusCreated,
+		Response: model.Annotation{},
+	},
+	"GET /runs/:run_id/annotations": {
+		Summary:  "List the annotations of a run",
+		Query:    model.AnnotationQueryParams{},
+		Response: openapi.Fields{"run_id": anyID, "annotations": []model.Annotation{}, "count": anyCount},
+	},
+	"DELETE /runs/:run_id/annotations/:annotation_id": {
+		Summary: "Delete an annotation",
+		Status:  http.StatusNoContent,
+	},
+
+	// Run lifecycle
+	"POST /runs/:run_id/events": {
+		Summary:  "Record a lifecycle event of a run",
+		Body:     model.CreateRunEventRequest{},
+		Status:   http.StatusCreated,
+		Response: model.RunEvent{},
+	},
+	"GET /runs/:run_id/events": {
+		Summary:  "List the lifecycle events of a run",
+		Query:    model.RunEventQueryParams{},
+		Response: openapi.Fields{"run_id": anyID, "events": []model.RunEvent{}, "count": anyCount},
+	},
+	"GET /runs/:run_id/state": {
+		Summary:  "Get the state of a run",
+		Response: model.RunState{},
+	},
+
+	// Scheduled jobs
+	"GET /admin/jobs": {
+		Summary:  "List the scheduled jobs",
+		Response: model.SchedulerStatus{},
+	},
+	"POST /admin/jobs/:name/run": {
+		Summary:  "Run a scheduled job now",
+		Status:   http.StatusAccepted,
+		Response: model.JobStatus{},
+	},
+
+	// Run exports
+	"GET /admin/exports": {
+		Summary:  "List the latest export of each exported run",
+		Query:    model.RunExportQueryParams{},
+		Response: []model.RunExport{},
+	},
+	"POST /admin/exports/:run_id": {
+		Summary:  "Export the metrics of an ended run to object storage now",
+		Response: model.RunExport{},
+	},
+
+	// Grafana JSON datasource
+	"GET /grafana": {
+		Summary:  "Test the Grafana datasource connection",
+		Response: openapi.Fields{"status": anyName},
+	},
+	"POST /grafana/search": {
+		Summary:  "Search Grafana targets",
+		Body:     model.GrafanaSearchRequest{},
+		Response: []string{},
+	},
+	"POST /grafana/query": {
+		Summary:  "Query Grafana targets as time series or tables",
+		Body:     model.GrafanaQueryRequest{},
+		Response: []interface{}{},
+	},
+	"POST /grafana/annotations": {
+		Summary:  "List run annotations for Grafana",
+		Body:     model.GrafanaAnnotationRequest{},
+		Response: []model.GrafanaAnnotation{},
+	},
+
+	// Prometheus
+	"GET /prometheus/metrics": {
+		Summary: "Scrape the latest values of the configured metrics of active runs",
+	},
+	"POST /prom/read": {
+		Summary: "Answer a snappy-compressed Prometheus remote-read request with run metrics",
+	},
+	"GET /rollups/openmetrics": {
+		Summary: "Export the hourly rollups of run metrics in the OpenMetrics format",
+		Query:   model.RollupQueryParams{},
+	},
+}
+
+// ServeOpenAPI serves the OpenAPI document of the API, marshalled once
+func ServeOpenAPI(doc *openapi.Document) gin.HandlerFunc {
+	body, err := json.Marshal(doc)
+	return func(c *gin.Context) {
+		if err != nil {
+			respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to render the OpenAPI document"})
+			return
+		}
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
+
+// ValidateRequests rejects requests not matching the OpenAPI document with
+// 400 before they reach their handler. The document must already describe
+// the routes, which are looked up by their pattern without the version
+// prefix, so the middleware serves every API version.
+func ValidateRequests(doc *openapi.Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// JSON bodies may carry bare NaN tokens, which handlers accept
+		if c.Request.Body != nil && c.Request.ContentLength != 0 && c.ContentType() == binding.MIMEJSON {
+			body, err := io.ReadAll(c.Request.Body)
+			c.Request.Body.Close()
+			if err != nil {
+				abortWithJSON(c, http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+				return
+			}
+			body = model.QuoteNonFiniteJSON(body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Request.ContentLength = int64(len(body))
+		}
+		if err := doc.Validate(c.Request.Method, RouteKey(c.FullPath()), c.Request, c.Params); err != nil {
+			abortWithJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
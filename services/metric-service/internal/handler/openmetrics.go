@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// openMetricsContentType is the content type of the OpenMetrics text format
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// ExportRollups writes the hourly rollups of runs' metrics within a time
+// range in the OpenMetrics text format
+func (h *MetricHandler) ExportRollups(c *gin.Context) {
+	var params model.RollupQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	runIDs, err := parseUUIDList(params.RunIDs)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	w := &rollupWriter{c: c}
+	err = h.service.StreamRollups(c.Request.Context(), runIDs, params, w.Add)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		var validationErr *service.ValidationError
+		if !w.Started() && errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to export rollups", zap.Error(err))
+		if !w.Started() {
+			respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to export rollups"})
+		}
+	}
+}
+
+// ScrapeRunMetrics exposes the latest value of each metric of a run in the
+// OpenMetrics text format, for a Prometheus scrape job per live run. The
+// families are those of the Prometheus exposition: values, steps and the
+// times they were logged, without sample timestamps.
+func (h *MetricHandler) ScrapeRunMetrics(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	names, err := h.service.ListMetricNameSummaries(c.Request.Context(), runID)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to get latest metrics for OpenMetrics", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metrics"})
+		return
+	}
+
+	metrics := make([]model.Metric, len(names))
+	for i, n := range names {
+		metrics[i] = n.Last
+	}
+	c.Data(http.StatusOK, openMetricsContentType, append(prometheusExposition(metrics), "# EOF\n"...))
+}
+
+// rollupStats are the statistics of a rollup exposed as the stat label, in
+// the order they are written
+var rollupStats = []struct {
+	name  string
+	value func(model.MetricRollup) *float64
+}{
+	{"avg", func(r model.MetricRollup) *float64 { return r.Avg }},
+	{"min", func(r model.MetricRollup) *float64 { return r.Min }},
+	{"max", func(r model.MetricRollup) *float64 { return r.Max }},
+	{"stddev", func(r model.MetricRollup) *float64 { return r.StdDev }},
+	{"count", func(r model.MetricRollup) *float64 {
+		count := float64(r.Count)
+		return &count
+	}},
+}
+
+// rollupWriter writes rollups, ordered by run, metric and bucket, as the
+// samples of the gauge family wanllmdb_metric_rollup. OpenMetrics wants the
+// samples of each label set together and in time order, so the buckets of a
+// run's metric are held until the next metric starts and then written one
+// stat at a time. As with jsonStream, nothing is sent before the first
+// series, and a failure after it leaves out the closing # EOF.
+type rollupWriter struct {
+	c      *gin.Context
+	w      *bufio.Writer
+	series []model.MetricRollup
+}
+
+// Started tells whether the response has been sent in part
+func (rw *rollupWriter) Started() bool {
+	return rw.w != nil
+}
+
+// Add adds the next rollup, writing the previous series when it starts a new one
+func (rw *rollupWriter) Add(r model.MetricRollup) error {
+	if len(rw.series) > 0 {
+		last := rw.series[len(rw.series)-1]
+		if last.RunID != r.RunID || last.MetricName != r.MetricName {
+			if err := rw.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	rw.series = append(rw.series, r)
+	return nil
+}
+
+// Close writes the last series and ends the exposition
+func (rw *rollupWriter) Close() error {
+	if err := rw.flush(); err != nil {
+		return err
+	}
+	if rw.w == nil {
+		rw.start()
+	}
+	if _, err := rw.w.WriteString("# EOF\n"); err != nil {
+		return err
+	}
+	return rw.w.Flush()
+}
+
+func (rw *rollupWriter) start() {
+	rw.c.Header("Content-Type", openMetricsContentType)
+	rw.c.Status(http.StatusOK)
+	rw.w = bufio.NewWriterSize(rw.c.Writer, jsonStreamBufferSize)
+	rw.w.WriteString("# TYPE wanllmdb_metric_rollup gauge\n")
+	rw.w.WriteString("# HELP wanllmdb_metric_rollup Hourly statistics of a run metric.\n")
+}
+
+// flush writes the held series
+func (rw *rollupWriter) flush() error {
+	if len(rw.series) == 0 {
+		return nil
+	}
+	if rw.w == nil {
+		rw.start()
+	}
+	first := rw.series[0]
+	for _, stat := range rollupStats {
+		labels := fmt.Sprintf(`{run_id="%s",metric="%s",stat="%s"}`,
+			first.RunID, prometheusLabelValue(first.MetricName), stat.name)
+		for _, r := range rw.series {
+			value := stat.value(r)
+			if value == nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(rw.w, "wanllmdb_metric_rollup%s %s %d\n",
+				labels, strconv.FormatFloat(*value, 'g', -1, 64), r.Bucket.Unix()); err != nil {
+				return err
+			}
+		}
+	}
+	rw.series = rw.series[:0]
+	return nil
+}
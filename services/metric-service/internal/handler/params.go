@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// totalCountHeader carries the number of rows a list query matches in all
+const totalCountHeader = "X-Total-Count"
+
+// parseUUIDList parses a comma-separated list of UUIDs, ignoring empty entries
+func parseUUIDList(value string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := uuid.Parse(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid UUID %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseNameList splits comma-separated lists of names, as query parameters
+// given once or repeated, dropping empty entries and repeats
+func parseNameList(values []string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// bindMetricsJSON binds a batch of metric values as bindBatchJSON does, also
+// accepting the bare NaN, Infinity and -Infinity tokens that encoders such as
+// Python's json module write
+func bindMetricsJSON(c *gin.Context, obj interface{}) error {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	return bindBatchBody(model.QuoteNonFiniteJSON(body), obj)
+}
+
+// rowLimitExceeded answers 422 when err is a query cut off at a row cap,
+// reporting whether it did
+func rowLimitExceeded(c *gin.Context, err error) bool {
+	var limitErr *service.RowLimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+	respond(c, http.StatusUnprocessableEntity, gin.H{"error": limitErr.Error(), "row_limit": limitErr.Limit})
+	return true
+}
+
+// invalidCursor answers 400 when err is a cursor the endpoint did not issue,
+// reporting whether it did
+func invalidCursor(c *gin.Context, err error) bool {
+	if !errors.Is(err, model.ErrInvalidCursor) {
+		return false
+	}
+	respond(c, http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+	return true
+}
+
+// nextCursor returns the next_cursor of a page of rows: the position of its
+// last row when the page came back full, or nil on the last page. A full
+// last page is followed by an empty one.
+func nextCursor[T any](rows []T, limit int, position func(T) interface{}) *string {
+	if limit <= 0 || len(rows) < limit {
+		return nil
+	}
+	cursor := model.EncodeCursor(position(rows[len(rows)-1]))
+	return &cursor
+}
+
+// metricPosition returns the cursor positions of rows of a metric query in
+// the order of params, and systemMetricPosition those of system metrics, for
+// nextCursor
+func metricPosition(params model.MetricQueryParams) func(model.Metric) interface{} {
+	return func(m model.Metric) interface{} { return params.CursorOf(m) }
+}
+
+func systemMetricPosition(m model.SystemMetric) interface{} { return model.SystemMetricCursorOf(m) }
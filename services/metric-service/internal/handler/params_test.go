@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestParseNameList(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []string
+	}{
+		{nil, nil},
+		{[]string{"loss,accuracy,lr"}, []string{"loss", "accuracy", "lr"}},
+		{[]string{"loss", "val/loss"}, []string{"loss", "val/loss"}},
+		{[]string{" loss , ,accuracy", "loss"}, []string{"loss", "accuracy"}},
+		{[]string{","}, nil},
+	}
+	for _, tt := range tests {
+		if got := parseNameList(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseNameList(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestGroupByName(t *testing.T) {
+	metrics := []model.Metric{{MetricName: "loss", Value: 2}, {MetricName: "lr", Value: 0.1}, {MetricName: "loss", Value: 1}}
+	series := groupByName(metrics, []string{"loss", "lr", "accuracy"})
+	if len(series) != 3 {
+		t.Fatalf("groupByName() = %v, want a series for each name", series)
+	}
+	if loss := series["loss"]; len(loss) != 2 || loss[0].Value != 2 || loss[1].Value != 1 {
+		t.Errorf("loss series = %v, want its metrics in order", loss)
+	}
+	if accuracy := series["accuracy"]; accuracy == nil || len(accuracy) != 0 {
+		t.Errorf("accuracy series = %#v, want empty", accuracy)
+	}
+}
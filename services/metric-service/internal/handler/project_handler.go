@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type ProjectHandler struct {
+	service *service.ProjectService
+	logger  *zap.Logger
+}
+
+func NewProjectHandler(service *service.ProjectService, logger *zap.Logger) *ProjectHandler {
+	return &ProjectHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// SetRunProject moves a run to a project and experiment
+func (h *ProjectHandler) SetRunProject(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.SetRunProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	run, err := h.service.SetRunProject(c.Request.Context(), runID, req)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to set run project", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to set run project"})
+		return
+	}
+
+	respond(c, http.StatusOK, run)
+}
+
+// GetRunProject retrieves the project and experiment of a run
+func (h *ProjectHandler) GetRunProject(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	run, err := h.service.GetRunProject(c.Request.Context(), runID)
+	if err != nil {
+		h.logger.Error("Failed to get run project", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get run project"})
+		return
+	}
+	if run == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Run is not registered in a project"})
+		return
+	}
+
+	respond(c, http.StatusOK, run)
+}
+
+// ListProjectRuns lists the runs of a project, most recently active first,
+// with the latest value of ?metric_name (default loss)
+func (h *ProjectHandler) ListProjectRuns(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("project_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var params model.ProjectRunQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 100
+	}
+
+	runs, err := h.service.ListProjectRuns(c.Request.Context(), projectID, params)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to list project runs", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list project runs"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("runs", runs, gin.H{
+		"project_id": projectID,
+	}))
+}
+
+// ListLatestMetrics returns the latest value of ?metric_name for every run
+// of a project active within ?active_within (default 15m)
+func (h *ProjectHandler) ListLatestMetrics(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("project_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var params model.ProjectLatestMetricsParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 1000
+	}
+
+	runs, err := h.service.ListLatestMetrics(c.Request.Context(), projectID, params)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to get latest project metrics", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get latest metrics"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("runs", runs, gin.H{
+		"project_id":  projectID,
+		"metric_name": params.MetricName,
+	}))
+}
+
+// ListExperiments summarizes the experiments of a project
+func (h *ProjectHandler) ListExperiments(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("project_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	experiments, err := h.service.ListExperiments(c.Request.Context(), projectID)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to list experiments", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list experiments"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("experiments", experiments, gin.H{
+		"project_id": projectID,
+	}))
+}
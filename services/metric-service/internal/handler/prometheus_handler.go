@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/snappy"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/promremote"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// prometheusContentType is the content type of the Prometheus text format
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// maxPromReadBytes caps remote-read requests, compressed and decoded alike;
+// they hold matchers, not data
+const maxPromReadBytes = 1 << 20
+
+// PrometheusHandler exposes the latest values of configured metrics of
+// active runs for Prometheus to scrape
+type PrometheusHandler struct {
+	service      *service.MetricService
+	metricNames  []string
+	activeWithin time.Duration
+	logger       *zap.Logger
+}
+
+func NewPrometheusHandler(service *service.MetricService, metricNames []string, activeWithin time.Duration, logger *zap.Logger) *PrometheusHandler {
+	return &PrometheusHandler{
+		service:      service,
+		metricNames:  metricNames,
+		activeWithin: activeWithin,
+		logger:       logger,
+	}
+}
+
+// Scrape writes the latest value, step and time of each configured metric
+// of every run that logged it within the active window, labelled with the
+// run ID and metric name. Bools are exposed as 1 and 0; strings are left out.
+// Without configured metrics there is nothing to expose, which answers 404.
+func (h *PrometheusHandler) Scrape(c *gin.Context) {
+	if len(h.metricNames) == 0 {
+		respond(c, http.StatusNotFound, gin.H{"error": "No metrics are configured for Prometheus"})
+		return
+	}
+
+	metrics, err := h.service.GetActiveLatestMetrics(c.Request.Context(), h.metricNames, h.activeWithin)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to get metrics for Prometheus", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metrics"})
+		return
+	}
+
+	c.Data(http.StatusOK, prometheusContentType, prometheusExposition(metrics))
+}
+
+// Read answers a Prometheus remote-read request, a snappy-compressed
+// ReadRequest, with a snappy-compressed ReadResponse of sampled series. Each
+// metric of a run is a series named after it and labelled with run_id, and
+// with node_id and rank when it has them; every query must match run_id or
+// __name__ with =.
+func (h *PrometheusHandler) Read(c *gin.Context) {
+	compressed, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPromReadBytes))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	if n, err := snappy.DecodedLen(compressed); err != nil || n > maxPromReadBytes {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Request body must be snappy-compressed and at most 1MiB decoded"})
+		return
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Request body must be snappy-compressed"})
+		return
+	}
+	queries, err := promremote.DecodeReadRequest(data)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results := make([][]model.PromTimeSeries, len(queries))
+	for i, query := range queries {
+		if results[i], err = h.service.PrometheusRead(c.Request.Context(), query); err != nil {
+			var validationErr *service.ValidationError
+			if errors.As(err, &validationErr) {
+				respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			if rowLimitExceeded(c, err) {
+				return
+			}
+			h.logger.Error("Failed to read metrics for Prometheus", zap.Error(err))
+			respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to read metrics"})
+			return
+		}
+	}
+
+	c.Header("Content-Encoding", "snappy")
+	c.Data(http.StatusOK, "application/x-protobuf", snappy.Encode(nil, promremote.AppendReadResponse(nil, results)))
+}
+
+// prometheusExposition renders metrics as the gauges of the Prometheus text
+// format. Samples carry no timestamp, as Prometheus rejects old ones; the
+// time a value was logged is a gauge of its own.
+func prometheusExposition(metrics []model.Metric) []byte {
+	var values, steps, times bytes.Buffer
+	for _, m := range metrics {
+		var value float64
+		switch {
+		case m.IsNumeric():
+			value = m.Value
+		case m.ValueType == model.ValueTypeBool && m.Text != nil:
+			if *m.Text == "true" {
+				value = 1
+			}
+		default:
+			continue
+		}
+		labels := fmt.Sprintf(`{run_id="%s",metric="%s"}`, m.RunID, prometheusLabelValue(m.MetricName))
+		fmt.Fprintf(&values, "wanllmdb_run_metric_value%s %s\n", labels, strconv.FormatFloat(value, 'g', -1, 64))
+		if m.Step != nil {
+			fmt.Fprintf(&steps, "wanllmdb_run_metric_step%s %d\n", labels, *m.Step)
+		}
+		fmt.Fprintf(&times, "wanllmdb_run_metric_timestamp_seconds%s %s\n", labels,
+			strconv.FormatFloat(float64(m.Time.UnixMilli())/1000, 'f', -1, 64))
+	}
+
+	var out bytes.Buffer
+	out.WriteString("# HELP wanllmdb_run_metric_value Latest value of a run metric.\n")
+	out.WriteString("# TYPE wanllmdb_run_metric_value gauge\n")
+	out.Write(values.Bytes())
+	out.WriteString("# HELP wanllmdb_run_metric_step Step of the latest value of a run metric.\n")
+	out.WriteString("# TYPE wanllmdb_run_metric_step gauge\n")
+	out.Write(steps.Bytes())
+	out.WriteString("# HELP wanllmdb_run_metric_timestamp_seconds Time the latest value of a run metric was logged.\n")
+	out.WriteString("# TYPE wanllmdb_run_metric_timestamp_seconds gauge\n")
+	out.Write(times.Bytes())
+	return out.Bytes()
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusLabelValue escapes a label value for the text format
+func prometheusLabelValue(value string) string {
+	return prometheusLabelEscaper.Replace(value)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/proto"
+
+	metricsv1 "github.com/wanllmdb/metric-service/api/metrics/v1"
+	"github.com/wanllmdb/metric-service/internal/metricpb"
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// protobufRequest tells whether a request body is a protobuf message, which
+// batch endpoints decode instead of JSON
+func protobufRequest(c *gin.Context) bool {
+	switch c.ContentType() {
+	case "application/x-protobuf", "application/protobuf":
+		return true
+	}
+	return false
+}
+
+// bindMetricsProto reads a metricsv1.BatchWriteRequest body into req, with
+// the checks the JSON binding makes
+func bindMetricsProto(c *gin.Context, req *model.MetricBatchRequest) error {
+	var pb metricsv1.BatchWriteRequest
+	if err := readProto(c, &pb); err != nil {
+		return err
+	}
+	if n := len(pb.GetMetrics()); n == 0 || n > metricpb.MaxBatch {
+		return fmt.Errorf("metrics must hold 1 to %d metrics", metricpb.MaxBatch)
+	}
+	switch pb.GetRankReduce() {
+	case "", "mean", "sum", "max", "min":
+	default:
+		return errors.New("rank_reduce must be one of mean, sum, max, min")
+	}
+	metrics, items := metricpb.Metrics(pb.GetMetrics())
+	if err := service.NewBatchValidationError("metric", items); err != nil {
+		return err
+	}
+	req.Metrics = metrics
+	req.IngestOptions = model.IngestOptions{RankReduce: pb.GetRankReduce(), KeepRanks: pb.KeepRanks}
+	return nil
+}
+
+// bindSystemMetricsProto reads a metricsv1.SystemMetricBatch body into req
+func bindSystemMetricsProto(c *gin.Context, req *model.SystemMetricBatchRequest) error {
+	var pb metricsv1.SystemMetricBatch
+	if err := readProto(c, &pb); err != nil {
+		return err
+	}
+	if n := len(pb.GetMetrics()); n == 0 || n > metricpb.MaxBatch {
+		return fmt.Errorf("metrics must hold 1 to %d metrics", metricpb.MaxBatch)
+	}
+	metrics, items := metricpb.SystemMetrics(pb.GetMetrics())
+	if err := service.NewBatchValidationError("metric", items); err != nil {
+		return err
+	}
+	req.Metrics = metrics
+	return nil
+}
+
+func readProto(c *gin.Context, m proto.Message) error {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(body, m); err != nil {
+		return fmt.Errorf("invalid protobuf body: %w", err)
+	}
+	return nil
+}
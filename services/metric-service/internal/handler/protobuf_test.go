@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+
+	metricsv1 "github.com/wanllmdb/metric-service/api/metrics/v1"
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// protoContext is a request context with a protobuf body
+func protoContext(t *testing.T, m proto.Message) *gin.Context {
+	t.Helper()
+	body, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/metrics/batch", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/x-protobuf")
+	return c
+}
+
+func TestBindMetricsProto(t *testing.T) {
+	runID := uuid.New()
+	c := protoContext(t, &metricsv1.BatchWriteRequest{
+		Metrics:    []*metricsv1.Metric{{RunId: runID.String(), MetricName: "loss", Step: proto.Int64(3), Value: 0.5}},
+		RankReduce: "mean",
+	})
+	if !protobufRequest(c) {
+		t.Fatal("protobuf body not recognized")
+	}
+	var req model.MetricBatchRequest
+	if err := bindMetricsProto(c, &req); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Metrics) != 1 || req.Metrics[0].RunID != runID || req.Metrics[0].MetricName != "loss" ||
+		*req.Metrics[0].Step != 3 || req.RankReduce != "mean" {
+		t.Errorf("bound %+v", req)
+	}
+
+	invalid := []*metricsv1.BatchWriteRequest{
+		{},
+		{Metrics: []*metricsv1.Metric{{RunId: runID.String(), MetricName: "loss"}}, RankReduce: "median"},
+	}
+	for _, pb := range invalid {
+		if err := bindMetricsProto(protoContext(t, pb), &model.MetricBatchRequest{}); err == nil {
+			t.Errorf("bound %v", pb)
+		}
+	}
+
+	err := bindMetricsProto(protoContext(t, &metricsv1.BatchWriteRequest{
+		Metrics: []*metricsv1.Metric{{RunId: runID.String(), MetricName: "loss"}, {RunId: "run", MetricName: "acc"}},
+	}), &model.MetricBatchRequest{})
+	var validationErr *service.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Items) != 1 || validationErr.Items[0].Index != 1 {
+		t.Errorf("err = %v, want the run ID of metric 1", err)
+	}
+}
+
+func TestBindSystemMetricsProto(t *testing.T) {
+	runID := uuid.New()
+	c := protoContext(t, &metricsv1.SystemMetricBatch{
+		Metrics: []*metricsv1.SystemMetric{{RunId: runID.String(), MetricType: "cpu", Value: 87.5}},
+	})
+	var req model.SystemMetricBatchRequest
+	if err := bindSystemMetricsProto(c, &req); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Metrics) != 1 || req.Metrics[0].RunID != runID || req.Metrics[0].Value != 87.5 {
+		t.Errorf("bound %+v", req)
+	}
+
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/metrics/system/batch", bytes.NewReader([]byte{0xff, 0xff}))
+	if err := bindSystemMetricsProto(c, &req); err == nil {
+		t.Error("bound a malformed body")
+	}
+}
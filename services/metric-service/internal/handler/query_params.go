@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The query parameters handlers parse themselves fall back to their defaults
+// when malformed, as they always have. Handlers built with strict query
+// parameters pass strict to the functions below, which then answer 400.
+
+// invalidQueryParam answers 400 naming the parameter, its value and what is
+// wrong with it
+func invalidQueryParam(c *gin.Context, name, value, reason string) {
+	abortWithJSON(c, http.StatusBadRequest, gin.H{
+		"error":     fmt.Sprintf("invalid query parameter %s: %s", name, reason),
+		"parameter": name,
+		"value":     value,
+	})
+}
+
+// queryInt parses the integer query parameter name, returning def when it is
+// absent. A value that is not an integer between min and max also gives def,
+// unless strict, where it answers 400 and reports false.
+func queryInt(c *gin.Context, strict bool, name string, def, min, max int) (int, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(value)
+	if err == nil && n >= min && n <= max {
+		return n, true
+	}
+	if !strict {
+		return def, true
+	}
+	if err != nil {
+		invalidQueryParam(c, name, value, "must be an integer")
+	} else {
+		invalidQueryParam(c, name, value, fmt.Sprintf("must be between %d and %d", min, max))
+	}
+	return 0, false
+}
+
+// queryBool reports whether the boolean query parameter name is true. Only
+// "true" counts, and any other value is false, unless strict, where a value
+// strconv.ParseBool rejects answers 400 and ok is false.
+func queryBool(c *gin.Context, strict bool, name string) (value, ok bool) {
+	raw := c.Query(name)
+	if !strict {
+		return raw == "true", true
+	}
+	if raw == "" {
+		return false, true
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		invalidQueryParam(c, name, raw, "must be a boolean")
+		return false, false
+	}
+	return b, true
+}
+
+// queryTime parses the timestamp query parameter name, already normalized to
+// RFC 3339 by NormalizeTimestamps, returning nil when it is absent. A value
+// that is not a timestamp is ignored too, unless strict, where it answers
+// 400 and reports false.
+func queryTime(c *gin.Context, strict bool, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return &t, true
+	}
+	if !strict {
+		return nil, true
+	}
+	invalidQueryParam(c, name, value, "must be a timestamp")
+	return nil, false
+}
+
+// maxBucketWidth is the widest time bucket of the aggregate endpoints
+const maxBucketWidth = 24 * time.Hour
+
+// parseBucketWidth parses a time bucket width, a duration such as 30s or 1m
+// or a number of seconds, of whole seconds up to maxBucketWidth. The error
+// says what is wrong with it.
+func parseBucketWidth(value string) (time.Duration, error) {
+	width, err := time.ParseDuration(value)
+	if n, atoiErr := strconv.Atoi(value); atoiErr == nil {
+		width, err = time.Duration(n)*time.Second, nil
+	}
+	if err != nil {
+		return 0, errors.New("must be a duration such as 1m")
+	}
+	if width < time.Second || width > maxBucketWidth || width%time.Second != 0 {
+		return 0, fmt.Errorf("must be whole seconds between 1s and %s", maxBucketWidth)
+	}
+	return width, nil
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func queryContext(rawQuery string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?"+rawQuery, nil)
+	return c, w
+}
+
+func TestQueryTime(t *testing.T) {
+	tests := []struct {
+		query   string
+		strict  bool
+		wantSet bool
+		wantOK  bool
+	}{
+		{"", false, false, true},
+		{"start_time=2024-01-01T00:00:00Z", false, true, true},
+		{"start_time=2024-01-01T00:00:00.5Z", true, true, true},
+		{"start_time=yesterday", false, false, true},
+		{"start_time=yesterday", true, false, false},
+	}
+	for _, tt := range tests {
+		c, w := queryContext(tt.query)
+		got, ok := queryTime(c, tt.strict, "start_time")
+		if ok != tt.wantOK || (got != nil) != tt.wantSet {
+			t.Errorf("queryTime(%q, strict=%v) = %v, %v; want set %v, ok %v", tt.query, tt.strict, got, ok, tt.wantSet, tt.wantOK)
+		}
+		if !ok && w.Code != http.StatusBadRequest {
+			t.Errorf("queryTime(%q, strict=%v) answered %d, want 400", tt.query, tt.strict, w.Code)
+		}
+	}
+}
+
+func TestQueryInt(t *testing.T) {
+	tests := []struct {
+		query  string
+		strict bool
+		want   int
+		wantOK bool
+	}{
+		{"", true, 1000, true},
+		{"limit=50", true, 50, true},
+		{"limit=0", false, 1000, true},
+		{"limit=0", true, 0, false},
+		{"limit=ten", false, 1000, true},
+		{"limit=ten", true, 0, false},
+		{"limit=5000000", false, 5000000, true},
+	}
+	for _, tt := range tests {
+		c, _ := queryContext(tt.query)
+		got, ok := queryInt(c, tt.strict, "limit", 1000, 1, 1<<31)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("queryInt(%q, strict=%v) = %d, %v; want %d, %v", tt.query, tt.strict, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseBucketWidth(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"1m", time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"30", 30 * time.Second, false},
+		{"24h", 24 * time.Hour, false},
+		{"500ms", 0, true},
+		{"1.5s", 0, true},
+		{"0", 0, true},
+		{"-1m", 0, true},
+		{"25h", 0, true},
+		{"minute", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseBucketWidth(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseBucketWidth(%q) = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatusClientClosedRequest is recorded for requests whose client went away
+// before the response was written
+const StatusClientClosedRequest = 499
+
+// QueryTimeout bounds the database time of read requests. Each GET or HEAD
+// request runs under a deadline of budget, or of its route's entry in
+// overrides, keyed by the route pattern without the /api/vN prefix (such as
+// /runs/:run_id/metrics); a zero budget leaves the route unbounded. Queries
+// run under the request context, so they are also cancelled when the client
+// disconnects. A handler failing with 500 once the deadline passed answers
+// 504 instead, and 499 once the client is gone.
+//
+// Exports (all=true, or a route of exportRoutes) run under exportBudget
+// instead, zero leaving them unbounded: their response is streamed, so a
+// deadline passing after the first row could only cut it short.
+func QueryTimeout(budget time.Duration, overrides map[string]time.Duration, exportBudget time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		timeout := budget
+		if d, ok := overrides[RouteKey(c.FullPath())]; ok {
+			timeout = d
+		}
+		if all, err := strconv.ParseBool(c.Query("all")); (err == nil && all) || exportRoutes[RouteKey(c.FullPath())] {
+			timeout = exportBudget
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		w := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+	}
+}
+
+// exportRoutes are the routes whose every response is an export, keyed as
+// in QueryTimeout overrides
+var exportRoutes = map[string]bool{
+	"/runs/:run_id/metrics/export": true,
+}
+
+// RouteKey strips the version prefix from a route pattern, giving the key of
+// the route in QueryTimeout overrides
+func RouteKey(fullPath string) string {
+	rest, ok := strings.CutPrefix(fullPath, "/api/")
+	if !ok {
+		return fullPath
+	}
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		return rest[i:]
+	}
+	return fullPath
+}
+
+// timeoutWriter reports the internal errors caused by the request context
+// ending with the status that says why
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx context.Context
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.ResponseWriter.WriteHeader(contextStatus(w.ctx, code))
+}
+
+// contextStatus is the status of a response with code once ctx has ended:
+// internal errors report 504 past its deadline and 499 once it was cancelled
+func contextStatus(ctx context.Context, code int) int {
+	if code != http.StatusInternalServerError {
+		return code
+	}
+	switch err := ctx.Err(); {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	}
+	return code
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestQueryTimeoutExportBudget(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		exportBudget time.Duration
+		wantDeadline bool
+	}{
+		{"query", "", 0, true},
+		{"streamed page", "?stream=true&limit=10", 0, true},
+		{"unbounded export", "?stream=true&all=true", 0, false},
+		{"export budget", "?stream=true&all=1", time.Hour, true},
+		{"all=false", "?all=false", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(QueryTimeout(time.Minute, nil, tt.exportBudget))
+			var hasDeadline bool
+			router.GET("/api/v1/runs/:run_id/metrics", func(c *gin.Context) {
+				_, hasDeadline = c.Request.Context().Deadline()
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/1/metrics"+tt.query, nil)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			if hasDeadline != tt.wantDeadline {
+				t.Fatalf("request has deadline %v, want %v", hasDeadline, tt.wantDeadline)
+			}
+		})
+	}
+}
+
+func TestQueryTimeoutExportRoute(t *testing.T) {
+	router := gin.New()
+	router.Use(QueryTimeout(10*time.Millisecond, nil, 0))
+	router.GET("/api/v1/runs/:run_id/metrics/export", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		c.Writer.WriteHeaderNow()
+		// A slow export keeps streaming past the query budget
+		for i := 0; i < 3; i++ {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			c.Writer.WriteString("row\n")
+		}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/runs/1/metrics/export?format=csv", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "row\nrow\nrow\n" {
+		t.Fatalf("export = %d %q, want 200 with 3 rows", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutWriterStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel func(c *gin.Context)
+		status int
+		want   int
+	}{
+		{"ok", nil, http.StatusOK, http.StatusOK},
+		{"internal error in time", nil, http.StatusInternalServerError, http.StatusInternalServerError},
+		{"deadline passed", func(c *gin.Context) { <-c.Request.Context().Done() }, http.StatusInternalServerError, http.StatusGatewayTimeout},
+		{"not found after deadline", func(c *gin.Context) { <-c.Request.Context().Done() }, http.StatusNotFound, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(QueryTimeout(10*time.Millisecond, nil, 0))
+			router.GET("/api/v1/runs/:run_id", func(c *gin.Context) {
+				if tt.cancel != nil {
+					tt.cancel(c)
+				}
+				c.Status(tt.status)
+				c.Writer.WriteHeaderNow()
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/runs/1", nil))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeoutWriterClientGone(t *testing.T) {
+	router := gin.New()
+	router.Use(QueryTimeout(time.Minute, nil, 0))
+	router.GET("/api/v1/runs/:run_id", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+		c.Writer.WriteHeaderNow()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/runs/1", nil).WithContext(ctx))
+	if w.Code != StatusClientClosedRequest {
+		t.Fatalf("status = %d, want %d", w.Code, StatusClientClosedRequest)
+	}
+}
+
+func TestQueryTimeoutSkipsWrites(t *testing.T) {
+	router := gin.New()
+	router.Use(QueryTimeout(time.Minute, nil, 0))
+	var hasDeadline bool
+	router.POST("/api/v1/metrics/batch", func(c *gin.Context) {
+		_, hasDeadline = c.Request.Context().Deadline()
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/metrics/batch", nil))
+	if hasDeadline {
+		t.Fatal("write request has a deadline")
+	}
+}
+
+func TestRouteKey(t *testing.T) {
+	tests := map[string]string{
+		"/api/v1/runs/:run_id/metrics": "/runs/:run_id/metrics",
+		"/api/v2/runs/:run_id/metrics": "/runs/:run_id/metrics",
+		"/health":                      "/health",
+		"/api/v1":                      "/api/v1",
+	}
+	for path, want := range tests {
+		if got := RouteKey(path); got != want {
+			t.Errorf("RouteKey(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
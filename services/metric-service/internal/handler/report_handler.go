@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type ReportHandler struct {
+	service           *service.ReportService
+	strictQueryParams bool
+	logger            *zap.Logger
+}
+
+func NewReportHandler(service *service.ReportService, strictQueryParams bool, logger *zap.Logger) *ReportHandler {
+	return &ReportHandler{
+		service:           service,
+		strictQueryParams: strictQueryParams,
+		logger:            logger,
+	}
+}
+
+// CreateReport saves a comparison report
+func (h *ReportHandler) CreateReport(c *gin.Context) {
+	var req model.CreateReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.service.CreateReport(c.Request.Context(), req)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to create report", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to create report"})
+		return
+	}
+
+	// The snapshot can be large; clients fetch it through /data
+	report.Snapshot = nil
+	respond(c, http.StatusCreated, report)
+}
+
+// ListReports lists saved reports
+func (h *ReportHandler) ListReports(c *gin.Context) {
+	limit, ok := queryInt(c, h.strictQueryParams, "limit", 50, 1, 500)
+	if !ok {
+		return
+	}
+	offset, ok := queryInt(c, h.strictQueryParams, "offset", 0, 0, math.MaxInt)
+	if !ok {
+		return
+	}
+
+	reports, err := h.service.ListReports(c.Request.Context(), limit, offset)
+	if err != nil {
+		h.logger.Error("Failed to list reports", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list reports"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("reports", reports, nil))
+}
+
+// GetReport retrieves a report definition
+func (h *ReportHandler) GetReport(c *gin.Context) {
+	reportID, err := uuid.Parse(c.Param("report_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	report, err := h.service.GetReport(c.Request.Context(), reportID, false)
+	if err != nil {
+		h.logger.Error("Failed to get report", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get report"})
+		return
+	}
+	if report == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// GetReportData returns the report with the data needed to render it
+func (h *ReportHandler) GetReportData(c *gin.Context) {
+	reportID, err := uuid.Parse(c.Param("report_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	live, ok := queryBool(c, h.strictQueryParams, "live")
+	if !ok {
+		return
+	}
+	report, err := h.service.RenderReport(c.Request.Context(), reportID, live)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to render report", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to render report"})
+		return
+	}
+	if report == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// UpdateReport changes the name, description or chart config of a report
+func (h *ReportHandler) UpdateReport(c *gin.Context) {
+	reportID, err := uuid.Parse(c.Param("report_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	var req model.UpdateReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := h.service.UpdateReport(c.Request.Context(), reportID, req)
+	if err != nil {
+		h.logger.Error("Failed to update report", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+		return
+	}
+	if !updated {
+		respond(c, http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	h.GetReport(c)
+}
+
+// DeleteReport removes a report
+func (h *ReportHandler) DeleteReport(c *gin.Context) {
+	reportID, err := uuid.Parse(c.Param("report_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	deleted, err := h.service.DeleteReport(c.Request.Context(), reportID)
+	if err != nil {
+		h.logger.Error("Failed to delete report", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete report"})
+		return
+	}
+	if !deleted {
+		respond(c, http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respond writes body as the JSON response of the request: as it is in v1,
+// and as its V2Response in v2, so that each version has its own shape,
+// encoded once. Responses other than errors are trimmed to the request's
+// field mask first. Handlers answer through respond rather than c.JSON.
+func respond(c *gin.Context, status int, body interface{}) {
+	if mask, ok := requestFieldMask(c); ok && status < http.StatusBadRequest {
+		if shaped, err := mask.shape(body); err == nil {
+			body = shaped
+		}
+	}
+	if isV2(c) {
+		status, body = v2Response(c, status, body)
+	} else if list, ok := body.(*listResponse); ok {
+		body = list.v1()
+	}
+	c.JSON(status, body)
+}
+
+// abortWithJSON answers like respond and stops the handler chain, for
+// middlewares
+func abortWithJSON(c *gin.Context, status int, body interface{}) {
+	c.Abort()
+	respond(c, status, body)
+}
+
+// listResponse is the response of a list endpoint: its items under key with
+// their count and paging, and the fields describing the list as a whole. v1
+// sends it as one object; v2 sends the items as data, the paging as
+// pagination and the fields as meta.
+type listResponse struct {
+	key    string
+	items  interface{}
+	count  int
+	fields gin.H
+	// cursored lists are paged by nextCursor, nil on their last page
+	cursored   bool
+	nextCursor *string
+	// total, offset and limit are set by lists paged by offset
+	total  *int64
+	offset *int64
+	limit  *int64
+	// v2Data gives the data of v2 from the items, for lists whose v1 items
+	// are not a JSON array
+	v2Data func(items interface{}) interface{}
+}
+
+// newList returns the list response of items under key, counting them, with
+// fields
+func newList[T any](key string, items []T, fields gin.H) *listResponse {
+	if fields == nil {
+		fields = gin.H{}
+	}
+	return &listResponse{key: key, items: items, count: len(items), fields: fields}
+}
+
+// withCursor pages the list by cursor, cursor being nil on the last page
+func (l *listResponse) withCursor(cursor *string) *listResponse {
+	l.cursored = true
+	l.nextCursor = cursor
+	return l
+}
+
+// withWindow pages the list by offset and limit within total rows
+func (l *listResponse) withWindow(total int64, offset, limit int) *listResponse {
+	o, n := int64(offset), int64(limit)
+	l.total, l.offset, l.limit = &total, &o, &n
+	return l
+}
+
+// v1 is the list response as one object: its fields, the items under key,
+// their count and the paging fields of the list
+func (l *listResponse) v1() gin.H {
+	response := make(gin.H, len(l.fields)+5)
+	for key, value := range l.fields {
+		response[key] = value
+	}
+	response[l.key] = l.items
+	response["count"] = l.count
+	if l.cursored {
+		response["next_cursor"] = l.nextCursor
+	}
+	if l.total != nil {
+		response["total"] = *l.total
+	}
+	if l.offset != nil {
+		response["offset"] = *l.offset
+	}
+	if l.limit != nil {
+		response["limit"] = *l.limit
+	}
+	return response
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type RunConfigHandler struct {
+	service *service.RunConfigService
+	logger  *zap.Logger
+}
+
+func NewRunConfigHandler(service *service.RunConfigService, logger *zap.Logger) *RunConfigHandler {
+	return &RunConfigHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// SetRunConfig replaces the hyperparameters and configuration of a run
+func (h *RunConfigHandler) SetRunConfig(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.SetRunConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg, err := h.service.SetRunConfig(c.Request.Context(), runID, req)
+	if err != nil {
+		h.logger.Error("Failed to set run config", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to set run config"})
+		return
+	}
+
+	respond(c, http.StatusOK, cfg)
+}
+
+// GetRunConfig retrieves the configuration of a run
+func (h *RunConfigHandler) GetRunConfig(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	cfg, err := h.service.GetRunConfig(c.Request.Context(), runID)
+	if err != nil {
+		h.logger.Error("Failed to get run config", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get run config"})
+		return
+	}
+	if cfg == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Run has no config"})
+		return
+	}
+
+	respond(c, http.StatusOK, cfg)
+}
+
+// DiffRuns reports what changed between two runs: configuration, tags,
+// placement and final/best metric values
+func (h *RunConfigHandler) DiffRuns(c *gin.Context) {
+	var req model.RunDiffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	diff, err := h.service.DiffRuns(c.Request.Context(), req)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to diff runs", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to diff runs"})
+		return
+	}
+
+	respond(c, http.StatusOK, diff)
+}
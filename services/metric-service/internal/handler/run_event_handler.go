@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type RunEventHandler struct {
+	service *service.RunEventService
+	logger  *zap.Logger
+}
+
+func NewRunEventHandler(service *service.RunEventService, logger *zap.Logger) *RunEventHandler {
+	return &RunEventHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// AppendEvent records a run state change
+func (h *RunEventHandler) AppendEvent(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.CreateRunEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := h.service.AppendEvent(c.Request.Context(), runID, req)
+	if errors.Is(err, service.ErrInvalidTransition) {
+		respond(c, http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to append run event", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to append run event"})
+		return
+	}
+
+	respond(c, http.StatusCreated, event)
+}
+
+// GetRunEvents retrieves the state changes of a run
+func (h *RunEventHandler) GetRunEvents(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.RunEventQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 1000
+	}
+
+	events, err := h.service.GetRunEvents(c.Request.Context(), runID, params)
+	if err != nil {
+		h.logger.Error("Failed to get run events", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get run events"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("events", events, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// GetRunState retrieves the current state of a run and its state intervals
+func (h *RunEventHandler) GetRunState(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	state, err := h.service.GetRunState(c.Request.Context(), runID)
+	if err != nil {
+		h.logger.Error("Failed to get run state", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get run state"})
+		return
+	}
+	if state == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Run has no state events"})
+		return
+	}
+
+	respond(c, http.StatusOK, state)
+}
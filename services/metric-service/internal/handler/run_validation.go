@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/runservice"
+)
+
+const runValidatorKey = "run_validator"
+
+// RunValidator rejects writes for runs the run service does not know or does
+// not let the caller access, so metrics of mistyped or deleted runs are not
+// silently stored
+type RunValidator struct {
+	client *runservice.Client
+	// failOpen accepts writes while the run service is unavailable
+	failOpen bool
+	logger   *zap.Logger
+}
+
+func NewRunValidator(client *runservice.Client, failOpen bool, logger *zap.Logger) *RunValidator {
+	return &RunValidator{
+		client:   client,
+		failOpen: failOpen,
+		logger:   logger,
+	}
+}
+
+// Middleware checks the run of write requests to /runs/:run_id routes and
+// makes the validator available to batch handlers, whose runs are in the body
+func (v *RunValidator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(runValidatorKey, v)
+
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		// Malformed IDs are left for the handler to reject
+		runID, err := uuid.Parse(c.Param("run_id"))
+		if err != nil {
+			c.Next()
+			return
+		}
+		if !v.check(c, []uuid.UUID{runID}) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// check validates runIDs, writing the error response and returning false when
+// one is rejected
+func (v *RunValidator) check(c *gin.Context, runIDs []uuid.UUID) bool {
+	authorization := c.GetHeader("Authorization")
+	seen := make(map[uuid.UUID]bool, 1)
+	for _, runID := range runIDs {
+		if seen[runID] {
+			continue
+		}
+		seen[runID] = true
+
+		err := v.client.CheckRun(c.Request.Context(), runID, authorization)
+		switch {
+		case err == nil:
+		case errors.Is(err, runservice.ErrRunNotFound):
+			respond(c, http.StatusNotFound, gin.H{"error": "Unknown run", "run_id": runID})
+			return false
+		case errors.Is(err, runservice.ErrUnauthenticated):
+			respond(c, http.StatusUnauthorized, gin.H{"error": "Missing credentials", "run_id": runID})
+			return false
+		case errors.Is(err, runservice.ErrForbidden):
+			respond(c, http.StatusForbidden, gin.H{"error": "Not allowed to write to run", "run_id": runID})
+			return false
+		case errors.Is(err, runservice.ErrUnavailable) && v.failOpen:
+			v.logger.Warn("Accepting write without run validation", zap.String("run_id", runID.String()), zap.Error(err))
+		default:
+			v.logger.Error("Failed to validate run", zap.String("run_id", runID.String()), zap.Error(err))
+			respond(c, http.StatusServiceUnavailable, gin.H{"error": "Run validation unavailable"})
+			return false
+		}
+	}
+	return true
+}
+
+// checkRuns validates the runs of a batch when run validation is enabled,
+// writing the error response and returning false when one is rejected
+func checkRuns(c *gin.Context, runIDs []uuid.UUID) bool {
+	v, ok := c.Get(runValidatorKey)
+	if !ok {
+		return true
+	}
+	return v.(*RunValidator).check(c, runIDs)
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type SchedulerHandler struct {
+	scheduler *service.Scheduler
+	logger    *zap.Logger
+}
+
+func NewSchedulerHandler(scheduler *service.Scheduler, logger *zap.Logger) *SchedulerHandler {
+	return &SchedulerHandler{
+		scheduler: scheduler,
+		logger:    logger,
+	}
+}
+
+// ListJobs reports the scheduler leader and the status of every job
+func (h *SchedulerHandler) ListJobs(c *gin.Context) {
+	status, err := h.scheduler.Status(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to get scheduler status", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get scheduler status"})
+		return
+	}
+
+	respond(c, http.StatusOK, status)
+}
+
+// RunJob starts a run of a job immediately
+func (h *SchedulerHandler) RunJob(c *gin.Context) {
+	status, err := h.scheduler.Trigger(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrJobNotFound):
+			respond(c, http.StatusNotFound, gin.H{"error": "Job not found"})
+		case errors.Is(err, service.ErrJobRunning):
+			respond(c, http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("Failed to start job", zap.Error(err))
+			respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to start job"})
+		}
+		return
+	}
+
+	respond(c, http.StatusAccepted, status)
+}
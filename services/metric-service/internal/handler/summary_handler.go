@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type SummaryHandler struct {
+	service *service.SummaryService
+	logger  *zap.Logger
+}
+
+func NewSummaryHandler(service *service.SummaryService, logger *zap.Logger) *SummaryHandler {
+	return &SummaryHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetRunSummary retrieves the final/best value of every metric in a run
+func (h *SummaryHandler) GetRunSummary(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	summaries, err := h.service.GetRunSummary(c.Request.Context(), runID)
+	if err != nil {
+		h.logger.Error("Failed to get run summary", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get run summary"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("metrics", summaries, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// RecomputeRunSummary rebuilds the summary of a run from its history
+func (h *SummaryHandler) RecomputeRunSummary(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	count, err := h.service.RecomputeRunSummary(c.Request.Context(), runID)
+	if err != nil {
+		h.logger.Error("Failed to recompute run summary", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to recompute run summary"})
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"run_id":  runID,
+		"metrics": count,
+	})
+}
+
+// ListSummaries ranks runs, or with group_by=group run groups, by the best or
+// final value of a metric
+func (h *SummaryHandler) ListSummaries(c *gin.Context) {
+	var params model.SummaryQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	runIDs, err := parseUUIDList(params.RunIDs)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if projectID := c.Query("project_id"); projectID != "" {
+		id, err := uuid.Parse(projectID)
+		if err != nil {
+			respond(c, http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+		params.ProjectID = &id
+	}
+
+	if params.Sort == "" {
+		params.Sort = "best"
+	}
+	if params.Limit == 0 {
+		params.Limit = 100
+	}
+
+	if params.GroupBy == "group" {
+		groups, err := h.service.ListGroupSummaries(c.Request.Context(), params, runIDs)
+		if err != nil {
+			h.logger.Error("Failed to list group summaries", zap.Error(err))
+			respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list summaries"})
+			return
+		}
+
+		if acceptsArrow(c) {
+			writeArrowGroupSummaries(c, params, groups)
+			return
+		}
+		respond(c, http.StatusOK, newList("groups", groups, gin.H{
+			"metric_name": params.MetricName,
+			"sort":        params.Sort,
+			"group_by":    params.GroupBy,
+		}))
+		return
+	}
+
+	summaries, err := h.service.ListSummaries(c.Request.Context(), params, runIDs)
+	if err != nil {
+		h.logger.Error("Failed to list summaries", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list summaries"})
+		return
+	}
+
+	if acceptsArrow(c) {
+		writeArrowSummaries(c, params, summaries)
+		return
+	}
+	respond(c, http.StatusOK, newList("runs", summaries, gin.H{
+		"metric_name": params.MetricName,
+		"sort":        params.Sort,
+	}))
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type SweepHandler struct {
+	service           *service.SweepService
+	strictQueryParams bool
+	logger            *zap.Logger
+}
+
+func NewSweepHandler(service *service.SweepService, strictQueryParams bool, logger *zap.Logger) *SweepHandler {
+	return &SweepHandler{
+		service:           service,
+		strictQueryParams: strictQueryParams,
+		logger:            logger,
+	}
+}
+
+// CreateSweep handles sweep definition
+func (h *SweepHandler) CreateSweep(c *gin.Context) {
+	var req model.CreateSweepRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sweep, err := h.service.CreateSweep(c.Request.Context(), req)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to create sweep", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to create sweep"})
+		return
+	}
+
+	respond(c, http.StatusCreated, sweep)
+}
+
+// GetSweep retrieves a sweep with its trials
+func (h *SweepHandler) GetSweep(c *gin.Context) {
+	sweepID, err := uuid.Parse(c.Param("sweep_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid sweep ID"})
+		return
+	}
+
+	sweep, err := h.service.GetSweep(c.Request.Context(), sweepID)
+	if err != nil {
+		h.logger.Error("Failed to get sweep", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get sweep"})
+		return
+	}
+	if sweep == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Sweep not found"})
+		return
+	}
+
+	trials, err := h.service.ListTrials(c.Request.Context(), sweepID)
+	if err != nil {
+		h.logger.Error("Failed to list sweep trials", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get sweep"})
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"sweep":  sweep,
+		"trials": trials,
+	})
+}
+
+// NextTrial hands the next suggested config to a sweep agent
+func (h *SweepHandler) NextTrial(c *gin.Context) {
+	sweepID, err := uuid.Parse(c.Param("sweep_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid sweep ID"})
+		return
+	}
+
+	var req model.NextTrialRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	trial, err := h.service.NextTrial(c.Request.Context(), sweepID, req.RunID)
+	if errors.Is(err, service.ErrSweepFinished) {
+		respond(c, http.StatusConflict, gin.H{"error": "Sweep is finished"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to allocate trial", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to allocate trial"})
+		return
+	}
+	if trial == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Sweep not found"})
+		return
+	}
+
+	respond(c, http.StatusCreated, trial)
+}
+
+// ReportTrial records the outcome of a trial
+func (h *SweepHandler) ReportTrial(c *gin.Context) {
+	sweepID, err := uuid.Parse(c.Param("sweep_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid sweep ID"})
+		return
+	}
+
+	trialID, err := uuid.Parse(c.Param("trial_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid trial ID"})
+		return
+	}
+
+	var req model.ReportTrialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trial, err := h.service.ReportTrial(c.Request.Context(), sweepID, trialID, req)
+	if err != nil {
+		h.logger.Error("Failed to report trial", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to report trial"})
+		return
+	}
+	if trial == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Trial not found"})
+		return
+	}
+
+	respond(c, http.StatusOK, trial)
+}
+
+// GetLeaderboard ranks a sweep's trials by its objective metric
+func (h *SweepHandler) GetLeaderboard(c *gin.Context) {
+	sweepID, err := uuid.Parse(c.Param("sweep_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid sweep ID"})
+		return
+	}
+
+	limit, ok := queryInt(c, h.strictQueryParams, "limit", 50, 1, 1000)
+	if !ok {
+		return
+	}
+
+	sweep, err := h.service.GetSweep(c.Request.Context(), sweepID)
+	if err != nil {
+		h.logger.Error("Failed to get sweep", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get leaderboard"})
+		return
+	}
+	if sweep == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Sweep not found"})
+		return
+	}
+
+	trials, err := h.service.GetLeaderboard(c.Request.Context(), sweep, limit)
+	if err != nil {
+		h.logger.Error("Failed to get leaderboard", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get leaderboard"})
+		return
+	}
+
+	entries := make([]gin.H, 0, len(trials))
+	for i, t := range trials {
+		entries = append(entries, gin.H{
+			"rank":            i + 1,
+			"trial_id":        t.ID,
+			"trial_number":    t.TrialNumber,
+			"run_id":          t.RunID,
+			"status":          t.Status,
+			"config":          t.Config,
+			"objective_value": t.ObjectiveValue,
+		})
+	}
+
+	respond(c, http.StatusOK, newList("leaderboard", entries, gin.H{
+		"sweep_id":         sweep.ID,
+		"objective_metric": sweep.ObjectiveMetric,
+		"goal":             sweep.Goal,
+	}))
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type TableHandler struct {
+	service *service.TableService
+	logger  *zap.Logger
+}
+
+func NewTableHandler(service *service.TableService, logger *zap.Logger) *TableHandler {
+	return &TableHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// BatchWrite handles batch table writing
+func (h *TableHandler) BatchWrite(c *gin.Context) {
+	var req model.TableBatchRequest
+	if err := bindBatchJSON(c, &req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	runIDs := make([]uuid.UUID, len(req.Tables))
+	for i, t := range req.Tables {
+		runIDs[i] = t.RunID
+	}
+	if !checkRuns(c, runIDs) {
+		return
+	}
+
+	if err := h.service.BatchWrite(c.Request.Context(), req.Tables); err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to write tables", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to write tables"})
+		return
+	}
+
+	respond(c, http.StatusCreated, gin.H{
+		"message": "Tables written successfully",
+		"count":   len(req.Tables),
+	})
+}
+
+// ListTables lists the table metrics logged in a run
+func (h *TableHandler) ListTables(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	series, err := h.service.ListTableSeries(c.Request.Context(), runID)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to list tables", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list tables"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("tables", series, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// GetTableHistory retrieves the per-step versions of a table metric
+func (h *TableHandler) GetTableHistory(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var params model.TableQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 100
+	}
+
+	metricName := c.Param("metric_name")
+	tables, err := h.service.GetTableHistory(c.Request.Context(), runID, metricName, params)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to get table history", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get table history"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("tables", tables, gin.H{
+		"run_id":      runID,
+		"metric_name": metricName,
+	}))
+}
+
+// GetLatestTable retrieves the newest version of a table metric
+func (h *TableHandler) GetLatestTable(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	table, err := h.service.GetLatestTable(c.Request.Context(), runID, c.Param("metric_name"))
+	if err != nil {
+		h.logger.Error("Failed to get latest table", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get latest table"})
+		return
+	}
+	if table == nil {
+		respond(c, http.StatusNotFound, gin.H{"error": "Table not found"})
+		return
+	}
+
+	respond(c, http.StatusOK, table)
+}
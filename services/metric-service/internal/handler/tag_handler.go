@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+type TagHandler struct {
+	service           *service.TagService
+	strictQueryParams bool
+	logger            *zap.Logger
+}
+
+func NewTagHandler(service *service.TagService, strictQueryParams bool, logger *zap.Logger) *TagHandler {
+	return &TagHandler{
+		service:           service,
+		strictQueryParams: strictQueryParams,
+		logger:            logger,
+	}
+}
+
+// GetRunTags retrieves the tags of a run
+func (h *TagHandler) GetRunTags(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	tags, err := h.service.GetRunTags(c.Request.Context(), runID)
+	if err != nil {
+		h.logger.Error("Failed to get run tags", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get tags"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("tags", tags, gin.H{
+		"run_id": runID,
+	}))
+}
+
+// SetRunTags adds or overwrites tags and labels on a run
+func (h *TagHandler) SetRunTags(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var req model.SetTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.SetTags(c.Request.Context(), runID, req); err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			respond(c, http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.logger.Error("Failed to set run tags", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to set tags"})
+		return
+	}
+
+	h.GetRunTags(c)
+}
+
+// DeleteRunTag removes a tag or label from a run
+func (h *TagHandler) DeleteRunTag(c *gin.Context) {
+	runIDStr := c.Param("run_id")
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	deleted, err := h.service.DeleteTag(c.Request.Context(), runID, c.Param("key"))
+	if err != nil {
+		h.logger.Error("Failed to delete run tag", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+	if !deleted {
+		respond(c, http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListRuns lists runs matching every ?tag=key:value or ?tag=label filter
+func (h *TagHandler) ListRuns(c *gin.Context) {
+	filters, err := service.ParseTagFilters(c.QueryArray("tag"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, ok := queryInt(c, h.strictQueryParams, "limit", 100, 1, 1000)
+	if !ok {
+		return
+	}
+
+	runs, err := h.service.FindRuns(c.Request.Context(), filters, limit)
+	if err != nil {
+		h.logger.Error("Failed to find tagged runs", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to list runs"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("runs", runs, nil))
+}
+
+// GetMetricStatsByTags compares one metric across all runs matching the tag filters
+func (h *TagHandler) GetMetricStatsByTags(c *gin.Context) {
+	metricName := c.Query("metric_name")
+	if metricName == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "Metric name is required"})
+		return
+	}
+
+	tagValues := c.QueryArray("tag")
+	if len(tagValues) == 0 {
+		respond(c, http.StatusBadRequest, gin.H{"error": "At least one tag filter is required"})
+		return
+	}
+
+	filters, err := service.ParseTagFilters(tagValues)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	runs, err := h.service.GetMetricStatsByTags(c.Request.Context(), filters, metricName)
+	if err != nil {
+		if rowLimitExceeded(c, err) {
+			return
+		}
+		h.logger.Error("Failed to get metric stats by tags", zap.Error(err))
+		respond(c, http.StatusInternalServerError, gin.H{"error": "Failed to get metric stats"})
+		return
+	}
+
+	respond(c, http.StatusOK, newList("runs", runs, gin.H{
+		"metric_name": metricName,
+	}))
+}
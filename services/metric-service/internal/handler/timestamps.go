@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// NormalizeTimestamps rewrites the named query parameters from any form
+// model.ParseTimestamp accepts, such as epoch seconds, into the RFC 3339
+// times the handlers bind. Values that do not parse are left for binding
+// to reject, or answered with 400 naming the parameter when strict. It
+// must run before anything reads the query, as gin caches it.
+func NormalizeTimestamps(strict bool, params ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		changed := false
+		for _, name := range params {
+			values := query[name]
+			for i, value := range values {
+				t, err := model.ParseTimestamp(value)
+				if err != nil {
+					if strict {
+						invalidQueryParam(c, name, value, "must be a timestamp")
+						return
+					}
+					continue
+				}
+				if normalized := t.Format(time.RFC3339Nano); normalized != value {
+					values[i] = normalized
+					changed = true
+				}
+			}
+		}
+		if changed {
+			c.Request.URL.RawQuery = query.Encode()
+		}
+		c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
@@ -10,9 +11,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
+	"github.com/wanllmdb/metric-service/internal/bufpool"
 	"github.com/wanllmdb/metric-service/internal/model"
 	"github.com/wanllmdb/metric-service/internal/service"
 )
@@ -37,9 +38,15 @@ func NewWebSocketHandler(service *service.MetricService, logger *zap.Logger) *We
 	}
 }
 
+// metricPayloads holds the payloads metric messages from Redis are decoded
+// into, reused along with their metric slices
+var metricPayloads = sync.Pool{
+	New: func() interface{} { return new(model.MetricPayload) },
+}
+
 type Client struct {
 	conn        *websocket.Conn
-	send        chan []byte
+	send        chan *bytes.Buffer
 	runID       uuid.UUID
 	metricNames map[string]bool
 	mu          sync.RWMutex
@@ -50,7 +57,7 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 	runIDStr := c.Param("run_id")
 	runID, err := uuid.Parse(runIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		respond(c, http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
 		return
 	}
 
@@ -62,7 +69,7 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 
 	client := &Client{
 		conn:        conn,
-		send:        make(chan []byte, 256),
+		send:        make(chan *bytes.Buffer, 256),
 		runID:       runID,
 		metricNames: make(map[string]bool),
 	}
@@ -123,7 +130,9 @@ func (h *WebSocketHandler) writePump(client *Client) {
 				return
 			}
 
-			if err := client.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			err := client.conn.WriteMessage(websocket.TextMessage, message.Bytes())
+			bufpool.Put(message)
+			if err != nil {
 				return
 			}
 
@@ -140,43 +149,129 @@ func (h *WebSocketHandler) writePump(client *Client) {
 func (h *WebSocketHandler) subscribePump(client *Client) {
 	ctx := context.Background()
 	channel := "metrics:" + client.runID.String()
+	annotationChannel := "annotations:" + client.runID.String()
+	anomalyChannel := "anomalies:" + client.runID.String()
+	alertChannel := "alerts:" + client.runID.String()
 
 	// Get Redis client from service (we'll need to expose this)
-	pubsub := h.service.SubscribeToMetrics(ctx, channel)
+	pubsub := h.service.SubscribeToMetrics(ctx, channel, annotationChannel, anomalyChannel, alertChannel)
 	defer pubsub.Close()
 
 	ch := pubsub.Channel()
 
 	for msg := range ch {
-		// Parse the metric payload
-		var payload model.MetricPayload
-		if err := json.Unmarshal([]byte(msg.Payload), &payload); err != nil {
-			h.logger.Error("Failed to parse metric payload", zap.Error(err))
+		switch msg.Channel {
+		case annotationChannel:
+			// Annotations are forwarded regardless of the metric filter
+			h.forwardAnnotations(client, msg.Payload)
 			continue
-		}
-
-		// Filter metrics based on subscription
-		filteredMetrics := h.filterMetrics(client, payload.Metrics)
-		if len(filteredMetrics) == 0 {
+		case anomalyChannel:
+			h.forwardAnomalies(client, msg.Payload)
 			continue
-		}
-
-		// Send to client
-		filteredPayload := model.MetricPayload{Metrics: filteredMetrics}
-		data, err := json.Marshal(model.WebSocketMessage{
-			Type:    "metric",
-			Payload: filteredPayload,
-		})
-		if err != nil {
-			h.logger.Error("Failed to marshal message", zap.Error(err))
+		case alertChannel:
+			// So are alerts, which concern the whole run
+			h.forwardAlerts(client, msg.Payload)
 			continue
 		}
 
-		select {
-		case client.send <- data:
-		default:
-			h.logger.Warn("Client send buffer full, dropping message")
+		h.forwardMetrics(client, msg.Payload)
+	}
+}
+
+// forwardMetrics relays the subscribed metrics of a published batch
+func (h *WebSocketHandler) forwardMetrics(client *Client, data string) {
+	// Decoding reuses the elements of the pooled slice, so they are zeroed
+	// first lest the maps of earlier metadata be merged into
+	payload := metricPayloads.Get().(*model.MetricPayload)
+	clear(payload.Metrics[:cap(payload.Metrics)])
+	payload.Metrics = payload.Metrics[:0]
+	defer metricPayloads.Put(payload)
+
+	// Parse the metric payload
+	if err := json.Unmarshal([]byte(data), payload); err != nil {
+		h.logger.Error("Failed to parse metric payload", zap.Error(err))
+		return
+	}
+
+	// Filter metrics based on subscription
+	payload.Metrics = h.filterMetrics(client, payload.Metrics)
+	if len(payload.Metrics) == 0 {
+		return
+	}
+
+	// Send to client
+	h.sendMessage(client, "metric", payload)
+}
+
+// forwardAnnotations relays newly created annotations to the client
+func (h *WebSocketHandler) forwardAnnotations(client *Client, payload string) {
+	var annotations model.AnnotationPayload
+	if err := json.Unmarshal([]byte(payload), &annotations); err != nil {
+		h.logger.Error("Failed to parse annotation payload", zap.Error(err))
+		return
+	}
+
+	h.sendMessage(client, "annotation", annotations)
+}
+
+// forwardAnomalies relays detected anomalies of the subscribed metrics
+func (h *WebSocketHandler) forwardAnomalies(client *Client, payload string) {
+	var anomalies model.AnomalyPayload
+	if err := json.Unmarshal([]byte(payload), &anomalies); err != nil {
+		h.logger.Error("Failed to parse anomaly payload", zap.Error(err))
+		return
+	}
+
+	client.mu.RLock()
+	if len(client.metricNames) > 0 {
+		var filtered []model.Anomaly
+		for _, a := range anomalies.Anomalies {
+			if client.metricNames[a.MetricName] {
+				filtered = append(filtered, a)
+			}
 		}
+		anomalies.Anomalies = filtered
+	}
+	client.mu.RUnlock()
+
+	if len(anomalies.Anomalies) == 0 {
+		return
+	}
+	h.sendMessage(client, "anomaly", anomalies)
+}
+
+// forwardAlerts relays alerts raised for the run
+func (h *WebSocketHandler) forwardAlerts(client *Client, payload string) {
+	var alerts model.AlertPayload
+	if err := json.Unmarshal([]byte(payload), &alerts); err != nil {
+		h.logger.Error("Failed to parse alert payload", zap.Error(err))
+		return
+	}
+
+	h.sendMessage(client, "alert", alerts)
+}
+
+func (h *WebSocketHandler) sendMessage(client *Client, messageType string, payload interface{}) {
+	// Outgoing messages are encoded into pooled buffers. A buffer queued on
+	// a client's send channel belongs to its writePump, which returns it once
+	// written.
+	buf := bufpool.Get()
+	if err := json.NewEncoder(buf).Encode(model.WebSocketMessage{
+		Type:    messageType,
+		Payload: payload,
+	}); err != nil {
+		bufpool.Put(buf)
+		h.logger.Error("Failed to marshal message", zap.Error(err))
+		return
+	}
+	// Drop the encoder's trailing newline
+	buf.Truncate(buf.Len() - 1)
+
+	select {
+	case client.send <- buf:
+	default:
+		bufpool.Put(buf)
+		h.logger.Warn("Client send buffer full, dropping message")
 	}
 }
 
@@ -211,7 +306,7 @@ func (h *WebSocketHandler) handleMessage(client *Client, msg *model.WebSocketMes
 	}
 }
 
-// filterMetrics filters metrics based on client subscription
+// filterMetrics filters metrics based on client subscription, in place
 func (h *WebSocketHandler) filterMetrics(client *Client, metrics []model.Metric) []model.Metric {
 	client.mu.RLock()
 	defer client.mu.RUnlock()
@@ -221,7 +316,7 @@ func (h *WebSocketHandler) filterMetrics(client *Client, metrics []model.Metric)
 		return metrics
 	}
 
-	var filtered []model.Metric
+	filtered := metrics[:0]
 	for _, m := range metrics {
 		if client.metricNames[m.MetricName] {
 			filtered = append(filtered, m)
@@ -0,0 +1,459 @@
+// Package kafka is a minimal Kafka producer: it finds partition leaders from
+// cluster metadata and appends uncompressed record batches to them, which is
+// all the metric egress needs of a client
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Required acknowledgements of produce requests
+const (
+	AcksAll    int16 = -1 // every in-sync replica has the records
+	AcksLeader int16 = 1  // the partition leader has the records
+)
+
+const (
+	produceAttempts = 3
+	retryBackoff    = 250 * time.Millisecond
+	// maxResponseSize bounds the responses read, which for the requests sent
+	// are small
+	maxResponseSize = 16 << 20
+)
+
+// Options configure a Producer. Brokers are host:port addresses to bootstrap
+// from; the rest of the cluster is learnt from its metadata.
+type Options struct {
+	Brokers      []string
+	ClientID     string
+	RequiredAcks int16
+	// Timeout bounds each request, and is how long the broker waits for
+	// replicas to acknowledge a produce request
+	Timeout time.Duration
+	// TLS, when set, encrypts connections to the brokers
+	TLS *tls.Config
+}
+
+// Message is a record to append to a topic. Messages with the same key go to
+// the same partition, which keeps them in order.
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers []Header
+	Time    time.Time
+}
+
+// Header is a record header, metadata consumers may read without the value
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Producer appends messages to Kafka topics. Calls to Produce are serialized.
+type Producer struct {
+	opts   Options
+	dialer net.Dialer
+
+	mu            sync.Mutex
+	brokers       map[int32]string   // addresses by node ID
+	leaders       map[string][]int32 // node ID of the leader of each partition, by topic
+	conns         map[string]*conn   // by address
+	correlationID int32
+}
+
+func NewProducer(opts Options) *Producer {
+	if opts.RequiredAcks == 0 {
+		opts.RequiredAcks = AcksAll
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	return &Producer{
+		opts:    opts,
+		dialer:  net.Dialer{Timeout: opts.Timeout},
+		brokers: make(map[int32]string),
+		leaders: make(map[string][]int32),
+		conns:   make(map[string]*conn),
+	}
+}
+
+// Produce appends msgs to their topics, retrying partitions whose leader
+// moved or was not yet elected. It returns once every message is
+// acknowledged, or with the error of a message that was not; messages of
+// other partitions may have been appended even so.
+func (p *Producer) Produce(ctx context.Context, msgs []Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	pending := make([]Message, len(msgs))
+	for i, m := range msgs {
+		if m.Time.IsZero() {
+			m.Time = now
+		}
+		pending[i] = m
+	}
+
+	for attempt := 1; ; attempt++ {
+		failed, err := p.produce(ctx, pending)
+		if err == nil {
+			return nil
+		}
+		var kerr Error
+		if (errors.As(err, &kerr) && !kerr.Retriable()) || attempt == produceAttempts || ctx.Err() != nil {
+			return err
+		}
+		// Leaders are looked up again for the retry
+		for _, m := range failed {
+			delete(p.leaders, m.Topic)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(retryBackoff * time.Duration(attempt)):
+		}
+		pending = failed
+	}
+}
+
+// Close closes the connections to the brokers
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, c := range p.conns {
+		c.Close()
+		delete(p.conns, addr)
+	}
+	return nil
+}
+
+// partitionMessages are the messages of a produce request for one partition
+type partitionMessages struct {
+	topic     string
+	partition int32
+	msgs      []Message
+}
+
+// produce sends msgs to the leaders of their partitions, one request per
+// leader, and returns the messages that failed with the first error
+func (p *Producer) produce(ctx context.Context, msgs []Message) ([]Message, error) {
+	var unknown []string
+	for _, m := range msgs {
+		if _, ok := p.leaders[m.Topic]; !ok && !slices.Contains(unknown, m.Topic) {
+			unknown = append(unknown, m.Topic)
+		}
+	}
+	if len(unknown) > 0 {
+		if err := p.refreshMetadata(ctx, unknown); err != nil {
+			return msgs, err
+		}
+	}
+
+	var failed []Message
+	var firstErr error
+	fail := func(msgs []Message, err error) {
+		failed = append(failed, msgs...)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// Group by leader, then partition, keeping the order of each partition
+	byLeader := make(map[int32][]*partitionMessages)
+	index := make(map[string]*partitionMessages)
+	var leaderOrder []int32
+	for _, m := range msgs {
+		leaders, ok := p.leaders[m.Topic]
+		if !ok || len(leaders) == 0 {
+			fail([]Message{m}, fmt.Errorf("topic %s: %w", m.Topic, ErrUnknownTopicOrPartition))
+			continue
+		}
+		partition := int32(partitionFor(m.Key, len(leaders)))
+		leader := leaders[partition]
+		if leader < 0 {
+			fail([]Message{m}, fmt.Errorf("topic %s partition %d: %w", m.Topic, partition, ErrLeaderNotAvailable))
+			continue
+		}
+		key := m.Topic + "/" + strconv.Itoa(int(partition))
+		pm, ok := index[key]
+		if !ok {
+			pm = &partitionMessages{topic: m.Topic, partition: partition}
+			index[key] = pm
+			if _, ok := byLeader[leader]; !ok {
+				leaderOrder = append(leaderOrder, leader)
+			}
+			byLeader[leader] = append(byLeader[leader], pm)
+		}
+		pm.msgs = append(pm.msgs, m)
+	}
+
+	for _, leader := range leaderOrder {
+		partitions := byLeader[leader]
+		addr, ok := p.brokers[leader]
+		if !ok {
+			for _, pm := range partitions {
+				fail(pm.msgs, fmt.Errorf("broker %d: %w", leader, ErrLeaderNotAvailable))
+			}
+			continue
+		}
+		resp, err := p.roundTrip(ctx, addr, apiProduce, produceVersion, p.encodeProduce(partitions))
+		if err != nil {
+			for _, pm := range partitions {
+				fail(pm.msgs, err)
+			}
+			continue
+		}
+		codes, err := decodeProduceResponse(resp)
+		if err != nil {
+			for _, pm := range partitions {
+				fail(pm.msgs, err)
+			}
+			continue
+		}
+		for _, pm := range partitions {
+			code, ok := codes[pm.topic+"/"+strconv.Itoa(int(pm.partition))]
+			if !ok {
+				fail(pm.msgs, fmt.Errorf("topic %s partition %d: missing from the produce response", pm.topic, pm.partition))
+			} else if code != 0 {
+				fail(pm.msgs, fmt.Errorf("topic %s partition %d: %w", pm.topic, pm.partition, code))
+			}
+		}
+	}
+	return failed, firstErr
+}
+
+func (p *Producer) encodeProduce(partitions []*partitionMessages) []byte {
+	var e encoder
+	e.nullString() // transactional ID
+	e.int16(p.opts.RequiredAcks)
+	e.int32(int32(p.opts.Timeout / time.Millisecond))
+
+	var topics []string
+	byTopic := make(map[string][]*partitionMessages)
+	for _, pm := range partitions {
+		if _, ok := byTopic[pm.topic]; !ok {
+			topics = append(topics, pm.topic)
+		}
+		byTopic[pm.topic] = append(byTopic[pm.topic], pm)
+	}
+	e.arrayLen(len(topics))
+	for _, topic := range topics {
+		e.string(topic)
+		e.arrayLen(len(byTopic[topic]))
+		for _, pm := range byTopic[topic] {
+			e.int32(pm.partition)
+			e.bytes(appendRecordBatch(nil, pm.msgs))
+		}
+	}
+	return e.buf
+}
+
+// decodeProduceResponse returns the error code of each partition, keyed by
+// topic/partition
+func decodeProduceResponse(resp []byte) (map[string]Error, error) {
+	d := decoder{buf: resp}
+	codes := make(map[string]Error)
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		topic := d.string()
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			partition := d.int32()
+			code := Error(d.int16())
+			d.int64() // base offset
+			d.int64() // log append time
+			codes[topic+"/"+strconv.Itoa(int(partition))] = code
+		}
+	}
+	d.int32() // throttle time
+	if d.err != nil {
+		return nil, fmt.Errorf("failed to decode produce response: %w", d.err)
+	}
+	return codes, nil
+}
+
+// refreshMetadata looks up the brokers and the partition leaders of topics,
+// asking the known brokers and then the bootstrap ones until one answers
+func (p *Producer) refreshMetadata(ctx context.Context, topics []string) error {
+	var e encoder
+	e.arrayLen(len(topics))
+	for _, topic := range topics {
+		e.string(topic)
+	}
+
+	addrs := make([]string, 0, len(p.brokers)+len(p.opts.Brokers))
+	for _, addr := range p.brokers {
+		addrs = append(addrs, addr)
+	}
+	addrs = append(addrs, p.opts.Brokers...)
+
+	var lastErr error
+	for _, addr := range addrs {
+		resp, err := p.roundTrip(ctx, addr, apiMetadata, metadataVersion, e.buf)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return p.applyMetadata(resp)
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no brokers configured")
+	}
+	return fmt.Errorf("failed to fetch metadata: %w", lastErr)
+}
+
+func (p *Producer) applyMetadata(resp []byte) error {
+	d := decoder{buf: resp}
+	brokers := make(map[int32]string)
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		nodeID := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		brokers[nodeID] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller ID
+
+	leaders := make(map[string][]int32)
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		code := Error(d.int16())
+		topic := d.string()
+		d.bool() // internal
+		var partitions []int32
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			d.int16() // partition error, reflected by the leader
+			partition := d.int32()
+			leader := d.int32()
+			for k, r := 0, d.arrayLen(); k < r; k++ {
+				d.int32() // replicas
+			}
+			for k, r := 0, d.arrayLen(); k < r; k++ {
+				d.int32() // in-sync replicas
+			}
+			if partition < 0 || d.err != nil {
+				continue
+			}
+			for int(partition) >= len(partitions) {
+				partitions = append(partitions, -1)
+			}
+			partitions[partition] = leader
+		}
+		// Topics being created have no partitions yet and are asked about again
+		if code == 0 && len(partitions) > 0 {
+			leaders[topic] = partitions
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("failed to decode metadata: %w", d.err)
+	}
+
+	for nodeID, addr := range brokers {
+		p.brokers[nodeID] = addr
+	}
+	for topic, partitions := range leaders {
+		p.leaders[topic] = partitions
+	}
+	return nil
+}
+
+// conn is a connection to a broker, which answers its requests in order
+type conn struct {
+	net.Conn
+	rd *bufio.Reader
+}
+
+// roundTrip sends a request to the broker at addr and returns the body of
+// its response. A connection that fails is closed and dialled again by the
+// next request.
+func (p *Producer) roundTrip(ctx context.Context, addr string, apiKey, version int16, body []byte) ([]byte, error) {
+	c, err := p.connect(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.exchange(ctx, c, apiKey, version, body)
+	if err != nil {
+		c.Close()
+		delete(p.conns, addr)
+		return nil, fmt.Errorf("broker %s: %w", addr, err)
+	}
+	return resp, nil
+}
+
+func (p *Producer) connect(ctx context.Context, addr string) (*conn, error) {
+	if c, ok := p.conns[addr]; ok {
+		return c, nil
+	}
+	nc, err := p.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to broker %s: %w", addr, err)
+	}
+	if p.opts.TLS != nil {
+		cfg := p.opts.TLS.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tc := tls.Client(nc, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("failed to connect to broker %s: %w", addr, err)
+		}
+		nc = tc
+	}
+	c := &conn{Conn: nc, rd: bufio.NewReader(nc)}
+	p.conns[addr] = c
+	return c, nil
+}
+
+func (p *Producer) exchange(ctx context.Context, c *conn, apiKey, version int16, body []byte) ([]byte, error) {
+	deadline := time.Now().Add(p.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	p.correlationID++
+	correlationID := p.correlationID
+	var e encoder
+	e.int32(0) // size, set below
+	e.int16(apiKey)
+	e.int16(version)
+	e.int32(correlationID)
+	e.string(p.opts.ClientID)
+	e.buf = append(e.buf, body...)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+	if _, err := c.Write(e.buf); err != nil {
+		return nil, err
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.rd, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < 4 || n > maxResponseSize {
+		return nil, fmt.Errorf("invalid response size %d", n)
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(c.rd, resp); err != nil {
+		return nil, err
+	}
+	if got := int32(binary.BigEndian.Uint32(resp)); got != correlationID {
+		return nil, fmt.Errorf("response to request %d, want %d", got, correlationID)
+	}
+	return resp[4:], nil
+}
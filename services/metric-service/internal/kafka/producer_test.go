@@ -0,0 +1,296 @@
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMurmur2(t *testing.T) {
+	// Vectors of the Java client's Utils.murmur2
+	tests := []struct {
+		key  string
+		want int32
+	}{
+		{"21", -973932308},
+		{"foobar", -790332482},
+		{"a-little-bit-long-string", -985981536},
+		{"a-little-bit-longer-string", -1486304829},
+		{"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", -58897971},
+		{"abc", 479470107},
+	}
+	for _, tt := range tests {
+		if got := murmur2([]byte(tt.key)); got != tt.want {
+			t.Errorf("murmur2(%q) = %d, want %d", tt.key, got, tt.want)
+		}
+	}
+}
+
+// record is a message as the fake broker received it
+type record struct {
+	topic     string
+	partition int32
+	key       string
+	value     string
+	headers   map[string]string
+}
+
+// fakeBroker answers metadata and produce requests as a one-node cluster
+// whose topics have two partitions. The first produce request to a topic in
+// fail fails with its error.
+type fakeBroker struct {
+	t    *testing.T
+	ln   net.Listener
+	fail map[string]Error
+
+	mu       sync.Mutex
+	records  []record
+	produces int
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{t: t, ln: ln, fail: make(map[string]Error)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(c)
+		}
+	}()
+	return b
+}
+
+func (b *fakeBroker) serve(c net.Conn) {
+	defer c.Close()
+	rd := bufio.NewReader(c)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(rd, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(rd, req); err != nil {
+			return
+		}
+		d := decoder{buf: req}
+		apiKey, version, correlationID := d.int16(), d.int16(), d.int32()
+		d.string() // client ID
+
+		e := encoder{}
+		e.int32(0)
+		e.int32(correlationID)
+		switch {
+		case apiKey == apiMetadata && version == metadataVersion:
+			b.metadata(&d, &e)
+		case apiKey == apiProduce && version == produceVersion:
+			b.produce(&d, &e)
+		default:
+			b.t.Errorf("unexpected request %d v%d", apiKey, version)
+			return
+		}
+		if d.err != nil {
+			b.t.Errorf("failed to decode request %d: %v", apiKey, d.err)
+			return
+		}
+		binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+		if _, err := c.Write(e.buf); err != nil {
+			return
+		}
+	}
+}
+
+func (b *fakeBroker) metadata(d *decoder, e *encoder) {
+	host, port, _ := net.SplitHostPort(b.ln.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	e.arrayLen(1)
+	e.int32(1)
+	e.string(host)
+	e.int32(int32(portNum))
+	e.nullString()
+	e.int32(1) // controller
+
+	n := d.arrayLen()
+	e.arrayLen(n)
+	for i := 0; i < n; i++ {
+		e.int16(0)
+		e.string(d.string())
+		e.int8(0)
+		e.arrayLen(2)
+		for partition := int32(0); partition < 2; partition++ {
+			e.int16(0)
+			e.int32(partition)
+			e.int32(1) // leader
+			e.arrayLen(1)
+			e.int32(1)
+			e.arrayLen(1)
+			e.int32(1)
+		}
+	}
+}
+
+func (b *fakeBroker) produce(d *decoder, e *encoder) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.produces++
+
+	d.string() // transactional ID
+	if acks := d.int16(); acks != AcksAll {
+		b.t.Errorf("acks = %d, want %d", acks, AcksAll)
+	}
+	d.int32() // timeout
+	n := d.arrayLen()
+	e.arrayLen(n)
+	for i := 0; i < n; i++ {
+		topic := d.string()
+		e.string(topic)
+		m := d.arrayLen()
+		e.arrayLen(m)
+		code, fail := b.fail[topic]
+		delete(b.fail, topic)
+		for j := 0; j < m; j++ {
+			partition := d.int32()
+			batch := d.bytes()
+			e.int32(partition)
+			if fail {
+				e.int16(int16(code))
+			} else {
+				for _, r := range decodeRecordBatch(b.t, batch) {
+					r.topic, r.partition = topic, partition
+					b.records = append(b.records, r)
+				}
+				e.int16(0)
+			}
+			e.int64(0)  // base offset
+			e.int64(-1) // log append time
+		}
+	}
+	e.int32(0) // throttle time
+}
+
+func decodeRecordBatch(t *testing.T, batch []byte) []record {
+	t.Helper()
+	d := decoder{buf: batch}
+	d.int64() // base offset
+	if length := d.int32(); int(length) != len(batch)-12 {
+		t.Errorf("batch length = %d, want %d", length, len(batch)-12)
+	}
+	d.int32() // partition leader epoch
+	if magic := d.int8(); magic != recordBatchMagic {
+		t.Errorf("magic = %d, want %d", magic, recordBatchMagic)
+	}
+	if crc := uint32(d.int32()); crc != crc32.Checksum(d.buf, castagnoli) {
+		t.Errorf("CRC mismatch")
+	}
+	d.int16() // attributes
+	lastOffsetDelta := d.int32()
+	d.int64() // first timestamp
+	d.int64() // max timestamp
+	d.int64() // producer ID
+	d.int16() // producer epoch
+	d.int32() // base sequence
+	n := d.arrayLen()
+	if int(lastOffsetDelta) != n-1 {
+		t.Errorf("last offset delta = %d for %d records", lastOffsetDelta, n)
+	}
+	var records []record
+	for i := 0; i < n; i++ {
+		length := d.varint()
+		rd := decoder{buf: d.next(int(length))}
+		rd.int8()   // attributes
+		rd.varint() // timestamp delta
+		if delta := rd.varint(); delta != int64(i) {
+			t.Errorf("offset delta = %d, want %d", delta, i)
+		}
+		r := record{key: string(rd.varbytes()), value: string(rd.varbytes()), headers: make(map[string]string)}
+		for headers := rd.varint(); headers > 0; headers-- {
+			key := rd.varbytes()
+			r.headers[string(key)] = string(rd.varbytes())
+		}
+		if len(rd.buf) != 0 || rd.err != nil {
+			t.Errorf("malformed record %d: %v", i, rd.err)
+		}
+		records = append(records, r)
+	}
+	if d.err != nil || len(d.buf) != 0 {
+		t.Errorf("malformed record batch: %v", d.err)
+	}
+	return records
+}
+
+func TestProduce(t *testing.T) {
+	b := newFakeBroker(t)
+	b.fail["b"] = ErrNotLeaderForPartition
+	p := NewProducer(Options{Brokers: []string{b.ln.Addr().String()}, ClientID: "test", Timeout: 5 * time.Second})
+	defer p.Close()
+
+	msgs := []Message{
+		{Topic: "a", Key: []byte("run-1"), Value: []byte("1")},
+		{Topic: "a", Key: []byte("run-2"), Value: []byte("2")},
+		{Topic: "b", Key: []byte("run-1"), Value: []byte("3")},
+		{Topic: "a", Key: []byte("run-1"), Value: []byte("4"), Headers: []Header{{Key: "content-type", Value: []byte("text/plain")}}},
+	}
+	if err := p.Produce(context.Background(), msgs); err != nil {
+		t.Fatalf("Produce() = %v", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.produces != 2 {
+		t.Errorf("%d produce requests, want 2: one rejected by a moved leader", b.produces)
+	}
+	got := make(map[string][]record)
+	for _, r := range b.records {
+		got[r.topic+"/"+r.key] = append(got[r.topic+"/"+r.key], r)
+	}
+	for _, m := range msgs {
+		if len(got[m.Topic+"/"+string(m.Key)]) == 0 {
+			t.Errorf("message %s of %s not received", m.Value, m.Topic)
+		}
+	}
+	if len(b.records) != len(msgs) {
+		t.Errorf("received %d records, want %d", len(b.records), len(msgs))
+	}
+	// A key stays on one partition, in order
+	if rs := got["a/run-1"]; len(rs) != 2 || rs[0].value != "1" || rs[1].value != "4" || rs[0].partition != rs[1].partition {
+		t.Errorf("records of a/run-1 = %+v, want 1 then 4 on one partition", rs)
+	} else if rs[1].headers["content-type"] != "text/plain" || len(rs[0].headers) != 0 {
+		t.Errorf("headers of a/run-1 = %v and %v, want none and content-type", rs[0].headers, rs[1].headers)
+	}
+	for _, r := range b.records {
+		if want := int32(partitionFor([]byte(r.key), 2)); r.partition != want {
+			t.Errorf("%s/%s on partition %d, want %d", r.topic, r.key, r.partition, want)
+		}
+	}
+}
+
+func TestProduceNotRetriable(t *testing.T) {
+	b := newFakeBroker(t)
+	b.fail["a"] = ErrMessageTooLarge
+	p := NewProducer(Options{Brokers: []string{b.ln.Addr().String()}, Timeout: 5 * time.Second})
+	defer p.Close()
+
+	err := p.Produce(context.Background(), []Message{{Topic: "a", Key: []byte("run-1"), Value: []byte("1")}})
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("Produce() = %v, want %v", err, ErrMessageTooLarge)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.produces != 1 {
+		t.Errorf("%d produce requests, want 1", b.produces)
+	}
+}
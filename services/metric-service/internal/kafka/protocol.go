@@ -0,0 +1,313 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// API keys and versions of the requests the producer sends
+const (
+	apiProduce  int16 = 0
+	apiMetadata int16 = 3
+
+	// produceVersion is the first to carry v2 record batches, which every
+	// broker since 0.11 accepts
+	produceVersion  int16 = 3
+	metadataVersion int16 = 1
+)
+
+// Error is an error code returned by a broker
+type Error int16
+
+// Error codes the producer acts on
+const (
+	ErrUnknownTopicOrPartition    Error = 3
+	ErrLeaderNotAvailable         Error = 5
+	ErrNotLeaderForPartition      Error = 6
+	ErrRequestTimedOut            Error = 7
+	ErrMessageTooLarge            Error = 10
+	ErrNetworkException           Error = 13
+	ErrNotEnoughReplicas          Error = 19
+	ErrNotEnoughReplicasAppend    Error = 20
+	ErrTopicAuthorizationFailed   Error = 29
+	ErrClusterAuthorizationFailed Error = 31
+)
+
+var errorNames = map[Error]string{
+	ErrUnknownTopicOrPartition:    "unknown topic or partition",
+	ErrLeaderNotAvailable:         "leader not available",
+	ErrNotLeaderForPartition:      "not leader for partition",
+	ErrRequestTimedOut:            "request timed out",
+	ErrMessageTooLarge:            "message too large",
+	ErrNetworkException:           "network exception",
+	ErrNotEnoughReplicas:          "not enough replicas",
+	ErrNotEnoughReplicasAppend:    "not enough replicas after append",
+	ErrTopicAuthorizationFailed:   "topic authorization failed",
+	ErrClusterAuthorizationFailed: "cluster authorization failed",
+}
+
+func (e Error) Error() string {
+	if name, ok := errorNames[e]; ok {
+		return fmt.Sprintf("kafka: %s (%d)", name, int16(e))
+	}
+	return fmt.Sprintf("kafka: error code %d", int16(e))
+}
+
+// Retriable tells whether a request failing with e may pass once metadata is
+// refreshed or the cluster settles, such as after a leader election or while
+// a topic is being created
+func (e Error) Retriable() bool {
+	switch e {
+	case ErrUnknownTopicOrPartition, ErrLeaderNotAvailable, ErrNotLeaderForPartition,
+		ErrRequestTimedOut, ErrNetworkException, ErrNotEnoughReplicas, ErrNotEnoughReplicasAppend:
+		return true
+	}
+	return false
+}
+
+var errShortBuffer = errors.New("kafka: truncated response")
+
+// encoder appends the big-endian primitives of the Kafka protocol
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) int8(v int8)   { e.buf = append(e.buf, byte(v)) }
+func (e *encoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *encoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *encoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+// varint appends a zigzag varint, as records encode their fields
+func (e *encoder) varint(v int64) { e.buf = binary.AppendVarint(e.buf, v) }
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) nullString() { e.int16(-1) }
+
+func (e *encoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// varbytes appends a record key or value, nil as length -1
+func (e *encoder) varbytes(b []byte) {
+	if b == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) arrayLen(n int) { e.int32(int32(n)) }
+
+// decoder reads the big-endian primitives of the Kafka protocol. The first
+// short read sets err and zeroes every later read.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = errShortBuffer
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = errShortBuffer
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) bool() bool { return d.int8() != 0 }
+
+// string reads a string, a null one as ""
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+func (d *decoder) varbytes() []byte {
+	n := d.varint()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// arrayLen reads the length of an array, a null one as 0
+func (d *decoder) arrayLen() int {
+	n := d.int32()
+	if n < 0 || d.err != nil {
+		return 0
+	}
+	// Every element takes at least a byte, which bounds bogus lengths
+	if int(n) > len(d.buf) {
+		d.err = errShortBuffer
+		return 0
+	}
+	return int(n)
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Fixed fields of a v2 record batch
+const (
+	recordBatchMagic = 2
+	// crcOffset is where the CRC starts: after the base offset, batch
+	// length, partition leader epoch and magic
+	crcOffset = 8 + 4 + 4 + 1
+	// batchLengthOffset is where the length of the rest of the batch starts
+	batchLengthOffset = 8
+)
+
+// appendRecordBatch appends msgs as one uncompressed v2 record batch. Offsets
+// are assigned by the broker, and the producer is neither idempotent nor
+// transactional.
+func appendRecordBatch(buf []byte, msgs []Message) []byte {
+	first := msgs[0].Time
+	max := first
+	for _, m := range msgs {
+		if m.Time.After(max) {
+			max = m.Time
+		}
+	}
+
+	start := len(buf)
+	e := encoder{buf: buf}
+	e.int64(0)  // base offset
+	e.int32(0)  // batch length, set below
+	e.int32(-1) // partition leader epoch
+	e.int8(recordBatchMagic)
+	e.int32(0) // CRC, set below
+	e.int16(0) // attributes: no compression, create time
+	e.int32(int32(len(msgs) - 1))
+	e.int64(first.UnixMilli())
+	e.int64(max.UnixMilli())
+	e.int64(-1) // producer ID
+	e.int16(-1) // producer epoch
+	e.int32(-1) // base sequence
+	e.arrayLen(len(msgs))
+
+	var record encoder
+	for i, m := range msgs {
+		record.buf = record.buf[:0]
+		record.int8(0) // attributes
+		record.varint(m.Time.UnixMilli() - first.UnixMilli())
+		record.varint(int64(i))
+		record.varbytes(m.Key)
+		record.varbytes(m.Value)
+		record.varint(int64(len(m.Headers)))
+		for _, h := range m.Headers {
+			record.varbytes([]byte(h.Key))
+			record.varbytes(h.Value)
+		}
+		e.varint(int64(len(record.buf)))
+		e.buf = append(e.buf, record.buf...)
+	}
+
+	batch := e.buf[start:]
+	binary.BigEndian.PutUint32(batch[batchLengthOffset:], uint32(len(batch)-batchLengthOffset-4))
+	binary.BigEndian.PutUint32(batch[crcOffset:], crc32.Checksum(batch[crcOffset+4:], castagnoli))
+	return e.buf
+}
+
+// murmur2 is the hash the Java client's default partitioner applies to keys,
+// so that producers in either language place a key on the same partition
+func murmur2(data []byte) int32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+	length := len(data)
+	h := uint32(seed) ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// partitionFor picks the partition of a key out of n, as the Java client does
+func partitionFor(key []byte, n int) int {
+	return int(murmur2(key)&0x7fffffff) % n
+}
@@ -0,0 +1,112 @@
+// Package metricpb reads metric batches from the protobuf messages of
+// api/metrics/v1, for the gRPC API and the protobuf bodies of the REST batch
+// endpoints alike
+package metricpb
+
+import (
+	"github.com/google/uuid"
+
+	metricsv1 "github.com/wanllmdb/metric-service/api/metrics/v1"
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+// MaxBatch is the most metrics one batch holds, as in JSON
+const MaxBatch = 1000
+
+// Metrics reads the metrics of a batch, reporting the fields that cannot be
+// read. Fields the service validates, such as the metric name, are left to
+// it. A metric without a time keeps the zero time, which the service stamps
+// on arrival.
+func Metrics(pbs []*metricsv1.Metric) ([]model.Metric, []service.ItemError) {
+	metrics := make([]model.Metric, len(pbs))
+	var items []service.ItemError
+	for i, pb := range pbs {
+		m := model.Metric{
+			MetricName: pb.GetMetricName(),
+			Step:       pb.Step,
+			Value:      pb.GetValue(),
+			NodeID:     pb.GetNodeId(),
+			Rank:       rank(pb.Rank),
+			ValueType:  pb.GetValueType(),
+			Text:       pb.Text,
+		}
+		if pb.GetTime() != nil {
+			m.Time = pb.GetTime().AsTime()
+		}
+		if pb.GetMetadata() != nil {
+			m.Metadata = pb.GetMetadata().AsMap()
+		}
+		var ok bool
+		if m.RunID, ok = runID(pb.GetRunId()); !ok {
+			items = append(items, service.ItemError{Index: i, Field: "run_id", Reason: "run_id must be a UUID"})
+		}
+		if m.ProjectID, ok = optionalID(pb.GetProjectId()); !ok {
+			items = append(items, service.ItemError{Index: i, Field: "project_id", Reason: "project_id must be a UUID"})
+		}
+		if m.ExperimentID, ok = optionalID(pb.GetExperimentId()); !ok {
+			items = append(items, service.ItemError{Index: i, Field: "experiment_id", Reason: "experiment_id must be a UUID"})
+		}
+		if m.ValueType == model.ValueTypeBool && m.Text != nil && *m.Text != "true" && *m.Text != "false" {
+			items = append(items, service.ItemError{Index: i, Field: "text", Reason: "text of a bool must be true or false"})
+		}
+		metrics[i] = m
+	}
+	return metrics, items
+}
+
+// SystemMetrics reads the system metrics of a batch, reporting the fields
+// that cannot be read
+func SystemMetrics(pbs []*metricsv1.SystemMetric) ([]model.SystemMetric, []service.ItemError) {
+	metrics := make([]model.SystemMetric, len(pbs))
+	var items []service.ItemError
+	for i, pb := range pbs {
+		m := model.SystemMetric{
+			MetricType: pb.GetMetricType(),
+			Value:      pb.GetValue(),
+			NodeID:     pb.GetNodeId(),
+			Rank:       rank(pb.Rank),
+		}
+		if pb.GetTime() != nil {
+			m.Time = pb.GetTime().AsTime()
+		}
+		if pb.GetMetadata() != nil {
+			m.Metadata = pb.GetMetadata().AsMap()
+		}
+		var ok bool
+		if m.RunID, ok = runID(pb.GetRunId()); !ok {
+			items = append(items, service.ItemError{Index: i, Field: "run_id", Reason: "run_id must be a UUID"})
+		}
+		metrics[i] = m
+	}
+	return metrics, items
+}
+
+// runID parses a run ID, leaving it nil when empty for the service to
+// report as missing
+func runID(value string) (uuid.UUID, bool) {
+	if value == "" {
+		return uuid.Nil, true
+	}
+	id, err := uuid.Parse(value)
+	return id, err == nil
+}
+
+func optionalID(value string) (*uuid.UUID, bool) {
+	if value == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, false
+	}
+	return &id, true
+}
+
+func rank(pb *int32) *int {
+	if pb == nil {
+		return nil
+	}
+	r := int(*pb)
+	return &r
+}
@@ -0,0 +1,76 @@
+package metricpb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	metricsv1 "github.com/wanllmdb/metric-service/api/metrics/v1"
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestMetrics(t *testing.T) {
+	runID, projectID := uuid.New(), uuid.New()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	metadata, _ := structpb.NewStruct(map[string]interface{}{"split": "val"})
+
+	metrics, items := Metrics([]*metricsv1.Metric{
+		{
+			Time:       timestamppb.New(at),
+			RunId:      runID.String(),
+			MetricName: "loss",
+			Step:       proto.Int64(7),
+			Value:      0.25,
+			Rank:       proto.Int32(3),
+			Metadata:   metadata,
+			ProjectId:  projectID.String(),
+		},
+		{RunId: runID.String(), MetricName: "phase", ValueType: model.ValueTypeString, Text: proto.String("eval")},
+	})
+	if len(items) > 0 {
+		t.Fatal(items)
+	}
+	m := metrics[0]
+	if !m.Time.Equal(at) || m.RunID != runID || *m.Step != 7 || m.Value != 0.25 || *m.Rank != 3 ||
+		m.Metadata["split"] != "val" || *m.ProjectID != projectID || m.ExperimentID != nil {
+		t.Errorf("Metrics()[0] = %+v", m)
+	}
+	if !metrics[1].Time.IsZero() || metrics[1].ValueType != model.ValueTypeString || *metrics[1].Text != "eval" {
+		t.Errorf("Metrics()[1] = %+v", metrics[1])
+	}
+
+	_, items = Metrics([]*metricsv1.Metric{
+		{RunId: "run", MetricName: "loss", ExperimentId: "exp"},
+		{MetricName: "done", ValueType: model.ValueTypeBool, Text: proto.String("yes")},
+	})
+	want := []struct {
+		index int
+		field string
+	}{{0, "run_id"}, {0, "experiment_id"}, {1, "text"}}
+	if len(items) != len(want) {
+		t.Fatalf("items = %+v, want %v", items, want)
+	}
+	for i, w := range want {
+		if items[i].Index != w.index || items[i].Field != w.field {
+			t.Errorf("items[%d] = %+v, want %v", i, items[i], w)
+		}
+	}
+}
+
+func TestSystemMetrics(t *testing.T) {
+	runID := uuid.New()
+	metrics, items := SystemMetrics([]*metricsv1.SystemMetric{
+		{RunId: runID.String(), MetricType: "cpu", Value: 42, NodeId: "n1"},
+		{RunId: "run", MetricType: "memory"},
+	})
+	if metrics[0].RunID != runID || metrics[0].MetricType != "cpu" || metrics[0].Value != 42 || metrics[0].NodeID != "n1" {
+		t.Errorf("SystemMetrics()[0] = %+v", metrics[0])
+	}
+	if len(items) != 1 || items[0].Index != 1 || items[0].Field != "run_id" {
+		t.Errorf("items = %+v, want run_id of item 1", items)
+	}
+}
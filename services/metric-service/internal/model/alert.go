@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Alert severities
+const (
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// Alert is a notice raised by a detection subsystem that needs someone's
+// attention now, such as a loss going NaN. Alerts are pushed to live clients
+// and not stored; the events they are raised from are.
+type Alert struct {
+	Time       time.Time `json:"time"`
+	RunID      uuid.UUID `json:"run_id"`
+	Severity   string    `json:"severity"`
+	Source     string    `json:"source"` // subsystem that raised it, e.g. anomaly_detector
+	Kind       string    `json:"kind"`
+	MetricName string    `json:"metric_name,omitempty"`
+	Step       *int64    `json:"step,omitempty"`
+	Message    string    `json:"message"`
+}
+
+type AlertPayload struct {
+	Alerts []Alert `json:"alerts"`
+}
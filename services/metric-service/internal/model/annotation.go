@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Annotation marks an event on a run's timeline, such as a learning rate drop
+// or a resume from checkpoint, so charts can draw it next to the metrics
+type Annotation struct {
+	ID        uuid.UUID              `json:"id"`
+	RunID     uuid.UUID              `json:"run_id"`
+	Time      time.Time              `json:"time"`
+	Step      *int64                 `json:"step"`
+	Text      string                 `json:"text"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+type CreateAnnotationRequest struct {
+	Time     *time.Time             `json:"time"`
+	Step     *int64                 `json:"step" binding:"omitempty,min=0"`
+	Text     string                 `json:"text" binding:"required,max=1024"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+type AnnotationQueryParams struct {
+	StartTime *time.Time `form:"start_time"`
+	EndTime   *time.Time `form:"end_time"`
+	MinStep   *int64     `form:"min_step"`
+	MaxStep   *int64     `form:"max_step"`
+}
+
+type AnnotationPayload struct {
+	Annotations []Annotation `json:"annotations"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Anomaly kinds
+const (
+	AnomalyKindSpike     = "spike"      // value broke above the EWMA band
+	AnomalyKindDrop      = "drop"       // value broke below the EWMA band
+	AnomalyKindNaNStreak = "nan_streak" // consecutive NaN/Inf values
+)
+
+type Anomaly struct {
+	Time           time.Time `json:"time"`
+	RunID          uuid.UUID `json:"run_id"`
+	MetricName     string    `json:"metric_name"`
+	Step           *int64    `json:"step"`
+	Value          *float64  `json:"value"` // nil when the offending value was NaN/Inf
+	Kind           string    `json:"kind"`
+	Score          float64   `json:"score"`
+	ExpectedMean   float64   `json:"expected_mean"`
+	ExpectedStdDev float64   `json:"expected_stddev"`
+	Message        string    `json:"message"`
+}
+
+type AnomalyQueryParams struct {
+	StartTime  *time.Time `form:"start_time"`
+	EndTime    *time.Time `form:"end_time"`
+	MetricName string     `form:"metric_name"`
+	Kind       string     `form:"kind"`
+	Limit      int        `form:"limit" binding:"min=0,max=1000"`
+}
+
+type AnomalyPayload struct {
+	Anomalies []Anomaly `json:"anomalies"`
+}
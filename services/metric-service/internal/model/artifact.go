@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Artifact kinds
+const (
+	ArtifactKindCheckpoint = "checkpoint"
+	ArtifactKindDataset    = "dataset"
+	ArtifactKindOther      = "other"
+)
+
+// ArtifactRef links an artifact stored elsewhere (checkpoint, dataset version)
+// to a run and the step it was produced at
+type ArtifactRef struct {
+	ID        uuid.UUID              `json:"id"`
+	RunID     uuid.UUID              `json:"run_id"`
+	Step      *int64                 `json:"step"`
+	Kind      string                 `json:"kind"`
+	Name      string                 `json:"name"`
+	URI       string                 `json:"uri"`
+	Digest    string                 `json:"digest"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+type CreateArtifactRequest struct {
+	Step     *int64                 `json:"step"`
+	Kind     string                 `json:"kind" binding:"required,oneof=checkpoint dataset other"`
+	Name     string                 `json:"name" binding:"required,max=255"`
+	URI      string                 `json:"uri" binding:"required"`
+	Digest   string                 `json:"digest" binding:"max=255"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+type ArtifactQueryParams struct {
+	Kind    string `form:"kind"`
+	MinStep *int64 `form:"min_step"`
+	MaxStep *int64 `form:"max_step"`
+	Limit   int    `form:"limit" binding:"min=0,max=10000"`
+}
+
+type ArtifactLookupParams struct {
+	MetricName string `form:"metric_name"`
+	At         string `form:"at" binding:"omitempty,oneof=best final"`
+	Step       *int64 `form:"step"`
+	Kind       string `form:"kind"`
+}
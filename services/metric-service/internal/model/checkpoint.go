@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CheckpointObjective is a metric a run's checkpoints are ranked by. An empty
+// goal is resolved from the metric's definition or name.
+type CheckpointObjective struct {
+	MetricName string `json:"metric_name" binding:"required,max=255"`
+	Goal       string `json:"goal" binding:"omitempty,oneof=min max"`
+}
+
+// RegisterCheckpointRequest registers the checkpoint saved at a step.
+// Objectives, when given, are added to the run's checkpoint objectives.
+type RegisterCheckpointRequest struct {
+	Step       *int64                 `json:"step" binding:"required,min=0"`
+	Name       string                 `json:"name" binding:"required,max=255"`
+	URI        string                 `json:"uri" binding:"required"`
+	Digest     string                 `json:"digest" binding:"max=255"`
+	Metadata   map[string]interface{} `json:"metadata"`
+	Objectives []CheckpointObjective  `json:"objectives" binding:"omitempty,max=20,dive"`
+}
+
+type SetCheckpointObjectivesRequest struct {
+	Objectives []CheckpointObjective `json:"objectives" binding:"required,min=1,max=20,dive"`
+}
+
+// BestCheckpoint is the checkpoint of a run whose step has the best value of
+// an objective so far, among the steps where both a checkpoint was saved and
+// the objective was logged
+type BestCheckpoint struct {
+	RunID      uuid.UUID   `json:"run_id"`
+	MetricName string      `json:"metric_name"`
+	Goal       string      `json:"goal"`
+	Value      float64     `json:"value"`
+	Step       int64       `json:"step"`
+	MetricTime time.Time   `json:"metric_time"`
+	Checkpoint ArtifactRef `json:"checkpoint"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+type RegisterCheckpointResponse struct {
+	Checkpoint ArtifactRef      `json:"checkpoint"`
+	Best       []BestCheckpoint `json:"best"`
+	// IsBest lists the objectives this checkpoint is now the best one for
+	IsBest []string `json:"is_best"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+// CloudEvents types of the events published
+const (
+	CloudEventRunStateChanged = "io.wanllmdb.run.state_changed" // data is the RunEvent
+	CloudEventAlertRaised     = "io.wanllmdb.alert.raised"      // data is the Alert
+	CloudEventMetricsDeleted  = "io.wanllmdb.metrics.deleted"   // data is the MetricDeletion
+)
+
+// CloudEventSpecVersion is the CloudEvents version events conform to
+const CloudEventSpecVersion = "1.0"
+
+// CloudEvent is an event in the structured JSON format of CloudEvents. The
+// subject is the run the event is about, also carried in the runid
+// extension so that brokers can filter on it.
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	RunID           string      `json:"runid,omitempty"`
+	Data            interface{} `json:"data"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import "github.com/google/uuid"
+
+// MetricCompareRequest asks for one metric of several runs, aligned by step.
+// Limit is the number of steps, from the first at or after MinStep.
+type MetricCompareRequest struct {
+	RunIDs     []uuid.UUID `json:"run_ids" binding:"required,min=1,max=50"`
+	MetricName string      `json:"metric_name" binding:"required"`
+	MinStep    *int64      `json:"min_step"`
+	MaxStep    *int64      `json:"max_step"`
+	Limit      int         `json:"limit" binding:"min=0,max=10000"`
+}
+
+// RunStepValue is the mean of the finite values a run logged at a step, nil
+// when it logged none
+type RunStepValue struct {
+	RunID uuid.UUID
+	Step  int64
+	Value *float64
+}
+
+// MetricComparison holds one metric of several runs on a shared step axis.
+// The values of each series line up with Steps, null where its run has no
+// value at the step. NextMinStep is the min_step of the steps after Steps,
+// nil when there are none.
+type MetricComparison struct {
+	MetricName  string             `json:"metric_name"`
+	Steps       []int64            `json:"steps"`
+	Series      []ComparisonSeries `json:"series"`
+	NextMinStep *int64             `json:"next_min_step"`
+}
+
+// ComparisonSeries holds the values of one run of a MetricComparison
+type ComparisonSeries struct {
+	RunID  uuid.UUID  `json:"run_id"`
+	Values []*float64 `json:"values"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Probable causes of a crash, in the order the detector checks them
+const (
+	CrashCauseGPUECC  = "gpu_ecc_error" // a GPU reported ECC errors
+	CrashCauseHostOOM = "host_oom"      // host memory was nearly full
+	CrashCauseGPUOOM  = "gpu_oom"       // a GPU's memory was nearly full
+	CrashCauseUnknown = "unknown"       // ingest stopped without other evidence
+)
+
+// SilentRun is a run that stopped logging metrics while not known to have ended
+type SilentRun struct {
+	RunID        uuid.UUID
+	LastMetricAt time.Time
+	LastStep     *int64
+	State        string // empty when the run has no state events
+	// Interval is the run's mean gap between logged timestamps, in seconds
+	Interval *float64
+}
+
+// CrashEvidence is what the last system samples before a run went silent
+// say about why it stopped
+type CrashEvidence struct {
+	HostMemoryPercent *float64   `json:"host_memory_percent,omitempty"`
+	GPUMemoryPercent  *float64   `json:"gpu_memory_percent,omitempty"`
+	GPUDevice         *int       `json:"gpu_device,omitempty"`
+	GPUECCErrors      float64    `json:"gpu_ecc_errors,omitempty"`
+	ECCDevice         *int       `json:"ecc_device,omitempty"`
+	LastSystemSample  *time.Time `json:"last_system_sample,omitempty"`
+}
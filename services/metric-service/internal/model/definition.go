@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MetricDefinition describes how a metric is interpreted within a project.
+// Definitions under the nil project ID apply to every project as defaults.
+type MetricDefinition struct {
+	ProjectID    uuid.UUID `json:"project_id"`
+	Name         string    `json:"name"`
+	DisplayName  string    `json:"display_name"`
+	Unit         string    `json:"unit"`
+	Goal         string    `json:"goal"`
+	ExpectedMin  *float64  `json:"expected_min"`
+	ExpectedMax  *float64  `json:"expected_max"`
+	EnforceRange bool      `json:"enforce_range"`
+	Description  string    `json:"description"`
+	// RankReduce combines the values distributed ranks log for the same step
+	// into the canonical series; empty stores every rank's value as sent
+	RankReduce     string `json:"rank_reduce,omitempty"`
+	KeepRankValues bool   `json:"keep_rank_values"`
+	// Cumulative marks a running counter (tokens, samples) whose per-second
+	// rate is derived on ingest as RateMetricName
+	Cumulative bool      `json:"cumulative"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type MetricDefinitionRequest struct {
+	Name           string   `json:"name" binding:"required,max=255"`
+	DisplayName    string   `json:"display_name" binding:"max=255"`
+	Unit           string   `json:"unit" binding:"max=64"`
+	Goal           string   `json:"goal" binding:"required,oneof=min max"`
+	ExpectedMin    *float64 `json:"expected_min"`
+	ExpectedMax    *float64 `json:"expected_max"`
+	EnforceRange   bool     `json:"enforce_range"`
+	Description    string   `json:"description"`
+	RankReduce     string   `json:"rank_reduce" binding:"omitempty,oneof=mean sum max min"`
+	KeepRankValues bool     `json:"keep_rank_values"`
+	Cumulative     bool     `json:"cumulative"`
+}
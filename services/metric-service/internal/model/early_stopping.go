@@ -0,0 +1,40 @@
+package model
+
+import "github.com/google/uuid"
+
+// Metric optimization goals
+const (
+	GoalMin = "min"
+	GoalMax = "max"
+)
+
+type ShouldStopParams struct {
+	MetricName    string  `form:"metric_name"`
+	Goal          string  `form:"goal" binding:"omitempty,oneof=min max"`
+	Patience      int64   `form:"patience" binding:"min=0"`
+	MinDelta      float64 `form:"min_delta" binding:"min=0"`
+	StopOnAnomaly string  `form:"stop_on_anomaly"` // comma-separated anomaly kinds, "none" to disable
+}
+
+type BestStep struct {
+	BestValue  float64 `json:"best_value"`
+	BestStep   *int64  `json:"best_step"`
+	LatestStep *int64  `json:"latest_step"`
+}
+
+type ShouldStopResponse struct {
+	RunID                 uuid.UUID `json:"run_id"`
+	ShouldStop            bool      `json:"should_stop"`
+	Reasons               []string  `json:"reasons"`
+	ManualStop            bool      `json:"manual_stop"`
+	AnomalyCount          int64     `json:"anomaly_count"`
+	MetricName            string    `json:"metric_name,omitempty"`
+	BestValue             *float64  `json:"best_value,omitempty"`
+	BestStep              *int64    `json:"best_step,omitempty"`
+	LatestStep            *int64    `json:"latest_step,omitempty"`
+	StepsSinceImprovement *int64    `json:"steps_since_improvement,omitempty"`
+}
+
+type StopRequest struct {
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Distance functions of nearest-neighbor queries
+const (
+	DistanceCosine    = "cosine"
+	DistanceEuclidean = "euclidean"
+	DistanceDot       = "dot"
+)
+
+// Embedding is a vector logged for one item at one step, such as the
+// representation of an evaluation sample. Key identifies the item across
+// steps, so the same sample can be followed as training moves it.
+type Embedding struct {
+	Time       time.Time              `json:"time"`
+	RunID      uuid.UUID              `json:"run_id"`
+	MetricName string                 `json:"metric_name"`
+	Step       *int64                 `json:"step"`
+	Key        string                 `json:"key"`
+	Vector     []float32              `json:"vector,omitempty"`
+	Dim        int                    `json:"dim"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type EmbeddingBatchRequest struct {
+	Embeddings []Embedding `json:"embeddings" binding:"required,min=1,max=1000"`
+}
+
+type EmbeddingQueryParams struct {
+	Step           *int64 `form:"step"`
+	MinStep        *int64 `form:"min_step"`
+	MaxStep        *int64 `form:"max_step"`
+	Key            string `form:"key"`
+	IncludeVectors bool   `form:"include_vectors"`
+	Limit          int    `form:"limit" binding:"min=0,max=10000"`
+}
+
+// EmbeddingSeries describes one embedding metric logged in a run
+type EmbeddingSeries struct {
+	MetricName string    `json:"metric_name"`
+	Dim        int       `json:"dim"`
+	Count      int64     `json:"count"`
+	FirstStep  *int64    `json:"first_step"`
+	LastStep   *int64    `json:"last_step"`
+	LastTime   time.Time `json:"last_time"`
+}
+
+// NearestRequest searches the embeddings of a metric closest to a query
+// vector, given either directly or as the key of a logged embedding (at Step,
+// or its latest step). The searched embeddings are those of the run, or of
+// RunIDs, optionally restricted to a step range.
+type NearestRequest struct {
+	Vector   []float32   `json:"vector"`
+	Key      string      `json:"key"`
+	Step     *int64      `json:"step"`
+	K        int         `json:"k" binding:"omitempty,min=1,max=1000"`
+	Distance string      `json:"distance" binding:"omitempty,oneof=cosine euclidean dot"`
+	RunIDs   []uuid.UUID `json:"run_ids" binding:"omitempty,max=100"`
+	MinStep  *int64      `json:"min_step"`
+	MaxStep  *int64      `json:"max_step"`
+}
+
+// Neighbor is an embedding and its distance to the query. For the dot
+// distance it is the negated dot product, so smaller is always closer.
+type Neighbor struct {
+	Embedding
+	Distance float64 `json:"distance"`
+}
+
+// NearestResult lists the neighbors of a query, closest first
+type NearestResult struct {
+	MetricName string     `json:"metric_name"`
+	Distance   string     `json:"distance"`
+	Scanned    int        `json:"scanned"`
+	Truncated  bool       `json:"truncated"`
+	Neighbors  []Neighbor `json:"neighbors"`
+}
+
+type EmbeddingDriftParams struct {
+	MinStep *int64 `form:"min_step"`
+	MaxStep *int64 `form:"max_step"`
+	Limit   int    `form:"limit" binding:"min=0,max=10000"`
+}
+
+// EmbeddingCentroid is the mean of the embeddings of one step
+type EmbeddingCentroid struct {
+	Step     int64
+	Dim      int
+	Count    int64
+	Centroid []float64
+}
+
+// EmbeddingDriftPoint measures, as cosine distances, how far the centroid of a
+// step's embeddings has moved from the first step's centroid and from the
+// previous step's
+type EmbeddingDriftPoint struct {
+	Step         int64   `json:"step"`
+	Count        int64   `json:"count"`
+	FromFirst    float64 `json:"from_first"`
+	FromPrevious float64 `json:"from_previous"`
+	CentroidNorm float64 `json:"centroid_norm"`
+}
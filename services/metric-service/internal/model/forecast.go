@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Trend models fitted by forecasts. ForecastModelAuto picks whichever of the
+// others fits the recent history best.
+const (
+	ForecastModelAuto        = "auto"
+	ForecastModelLinear      = "linear"
+	ForecastModelExponential = "exponential"
+)
+
+type ForecastParams struct {
+	Target *float64 `form:"target" binding:"required"`
+	Model  string   `form:"model" binding:"omitempty,oneof=auto linear exponential"`
+	Window int      `form:"window" binding:"min=0,max=10000"` // most recent steps fitted, default 200
+	Points int      `form:"points" binding:"min=0,max=1000"`  // projected points returned, default 20
+}
+
+// ForecastPoint is one step of the fitted projection
+type ForecastPoint struct {
+	Step  int64     `json:"step"`
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Forecast projects when a metric will cross a target value from a trend
+// fitted to its recent history. The ETA fields are nil when the trend does
+// not head toward the target, or when it has already been reached.
+type Forecast struct {
+	RunID          uuid.UUID       `json:"run_id"`
+	MetricName     string          `json:"metric_name"`
+	Model          string          `json:"model"`
+	Target         float64         `json:"target"`
+	SampleCount    int             `json:"sample_count"`
+	LatestValue    float64         `json:"latest_value"`
+	LatestStep     int64           `json:"latest_step"`
+	LatestTime     time.Time       `json:"latest_time"`
+	RSquared       float64         `json:"r_squared"`
+	SecondsPerStep float64         `json:"seconds_per_step"`
+	Reached        bool            `json:"reached"`
+	Converging     bool            `json:"converging"`
+	ETAStep        *int64          `json:"eta_step"`
+	ETASeconds     *float64        `json:"eta_seconds"`
+	ETA            *time.Time      `json:"eta"`
+	Projection     []ForecastPoint `json:"projection"`
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GPUMetric is one sample of a single GPU. Readings a device doesn't report
+// are left nil.
+type GPUMetric struct {
+	Time              time.Time              `json:"time"`
+	RunID             uuid.UUID              `json:"run_id"`
+	NodeID            string                 `json:"node_id,omitempty"`
+	DeviceIndex       int                    `json:"device_index"`
+	DeviceUUID        string                 `json:"device_uuid"`
+	DeviceName        string                 `json:"device_name,omitempty"`
+	Utilization       *float64               `json:"utilization"`
+	MemoryUtilization *float64               `json:"memory_utilization"`
+	MemoryUsedMB      *float64               `json:"memory_used_mb"`
+	MemoryTotalMB     *float64               `json:"memory_total_mb"`
+	TemperatureC      *float64               `json:"temperature_c"`
+	PowerW            *float64               `json:"power_w"`
+	Metadata          map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type GPUMetricBatchRequest struct {
+	Metrics []GPUMetric `json:"metrics" binding:"required,min=1,max=1000"`
+}
+
+type GPUMetricQueryParams struct {
+	StartTime   *time.Time `form:"start_time"`
+	EndTime     *time.Time `form:"end_time"`
+	NodeID      string     `form:"node_id"`
+	DeviceIndex *int       `form:"device_index"`
+	Limit       int        `form:"limit" binding:"min=0,max=10000"` // per device
+}
+
+// GPUDeviceSeries holds the samples of one device in time order
+type GPUDeviceSeries struct {
+	NodeID      string      `json:"node_id,omitempty"`
+	DeviceIndex int         `json:"device_index"`
+	DeviceUUID  string      `json:"device_uuid"`
+	DeviceName  string      `json:"device_name,omitempty"`
+	Metrics     []GPUMetric `json:"metrics"`
+}
+
+// GPUDeviceSummary aggregates the samples of one device over a run
+type GPUDeviceSummary struct {
+	NodeID          string    `json:"node_id,omitempty"`
+	DeviceIndex     int       `json:"device_index"`
+	DeviceUUID      string    `json:"device_uuid"`
+	DeviceName      string    `json:"device_name,omitempty"`
+	Count           int64     `json:"count"`
+	AvgUtilization  *float64  `json:"avg_utilization"`
+	MaxUtilization  *float64  `json:"max_utilization"`
+	MaxMemoryUsedMB *float64  `json:"max_memory_used_mb"`
+	MemoryTotalMB   *float64  `json:"memory_total_mb"`
+	MaxTemperatureC *float64  `json:"max_temperature_c"`
+	AvgPowerW       *float64  `json:"avg_power_w"`
+	MaxPowerW       *float64  `json:"max_power_w"`
+	FirstTime       time.Time `json:"first_time"`
+	LastTime        time.Time `json:"last_time"`
+}
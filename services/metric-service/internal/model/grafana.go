@@ -0,0 +1,83 @@
+package model
+
+import "time"
+
+// Requests and responses of the Grafana JSON datasource contract, served
+// under /grafana. Targets name a metric of a run as <run_id>:<metric_name>.
+
+// GrafanaTargetSeparator splits a Grafana target into its run ID and metric
+// name; metric names may contain it, run IDs cannot
+const GrafanaTargetSeparator = ":"
+
+// Types of query targets: a time series of values, or a table of rows with
+// their step
+const (
+	GrafanaTimeSeries = "timeserie"
+	GrafanaTable      = "table"
+)
+
+type GrafanaRange struct {
+	From time.Time `json:"from" binding:"required"`
+	To   time.Time `json:"to" binding:"required"`
+}
+
+type GrafanaSearchRequest struct {
+	Target string `json:"target"`
+}
+
+type GrafanaTarget struct {
+	Target string `json:"target" binding:"required"`
+	RefID  string `json:"refId"`
+	Type   string `json:"type" binding:"omitempty,oneof=timeserie table"`
+}
+
+type GrafanaQueryRequest struct {
+	Range         GrafanaRange    `json:"range" binding:"required"`
+	IntervalMs    int64           `json:"intervalMs"`
+	MaxDataPoints int             `json:"maxDataPoints" binding:"min=0"`
+	Targets       []GrafanaTarget `json:"targets" binding:"required,min=1,max=50,dive"`
+}
+
+// GrafanaSeries is a time series answering a timeserie target. Datapoints
+// are [value, epoch milliseconds] pairs, oldest first; non-finite values
+// are null.
+type GrafanaSeries struct {
+	Target     string          `json:"target"`
+	RefID      string          `json:"refId,omitempty"`
+	Datapoints [][]interface{} `json:"datapoints"`
+}
+
+type GrafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// GrafanaTableResult is a table answering a table target, one row per value
+// with its time in epoch milliseconds, step and value
+type GrafanaTableResult struct {
+	Type    string          `json:"type"`
+	RefID   string          `json:"refId,omitempty"`
+	Columns []GrafanaColumn `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// GrafanaAnnotationRequest asks for the annotations of the run named by the
+// query of the annotation, which is echoed back in each result
+type GrafanaAnnotationRequest struct {
+	Range      GrafanaRange `json:"range" binding:"required"`
+	Annotation struct {
+		Name       string      `json:"name"`
+		Datasource interface{} `json:"datasource,omitempty"`
+		Enable     bool        `json:"enable"`
+		IconColor  string      `json:"iconColor,omitempty"`
+		Query      string      `json:"query"`
+	} `json:"annotation"`
+}
+
+type GrafanaAnnotation struct {
+	Annotation interface{} `json:"annotation"`
+	Time       int64       `json:"time"`
+	Title      string      `json:"title"`
+	Text       string      `json:"text"`
+	Tags       []string    `json:"tags"`
+}
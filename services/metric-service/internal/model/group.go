@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RunGroup places a run in a group of related runs, such as the seeds of one
+// experiment, and records the kind of job it is (train, eval, ...)
+type RunGroup struct {
+	RunID     uuid.UUID `json:"run_id"`
+	Group     string    `json:"group"`
+	JobType   string    `json:"job_type,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SetRunGroupRequest struct {
+	Group   string `json:"group" binding:"required,max=255"`
+	JobType string `json:"job_type" binding:"max=64"`
+}
+
+// GroupInfo describes one group and the runs in it
+type GroupInfo struct {
+	Group    string   `json:"group"`
+	JobTypes []string `json:"job_types"`
+	RunCount int64    `json:"run_count"`
+}
+
+type GroupQueryParams struct {
+	JobType string `form:"job_type"`
+	Limit   int    `form:"limit" binding:"min=0,max=1000"`
+}
+
+type GroupMetricQueryParams struct {
+	JobType string `form:"job_type"`
+	MinStep *int64 `form:"min_step"`
+	MaxStep *int64 `form:"max_step"`
+	Reduce  string `form:"reduce"` // mean, max, min, sum
+	Limit   int    `form:"limit" binding:"min=0,max=10000"`
+}
+
+// GroupMetricSummary aggregates the best or final value of one metric over
+// the runs of a group
+type GroupMetricSummary struct {
+	Group      string    `json:"group"`
+	MetricName string    `json:"metric_name"`
+	Goal       string    `json:"goal"`
+	RunCount   int64     `json:"run_count"`
+	Mean       float64   `json:"mean"`
+	StdDev     *float64  `json:"std_dev"`
+	Min        float64   `json:"min"`
+	Max        float64   `json:"max"`
+	BestRunID  uuid.UUID `json:"best_run_id"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Health states of the service and of each of its components. The service is
+// degraded when any component is not healthy.
+const (
+	HealthHealthy   = "healthy"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+)
+
+// ComponentHealth is the result of checking one dependency or worker of the
+// service
+type ComponentHealth struct {
+	Name      string                 `json:"name"`
+	Status    string                 `json:"status"`
+	Error     string                 `json:"error,omitempty"`
+	LatencyMs float64                `json:"latency_ms"`
+	CheckedAt time.Time              `json:"checked_at"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Health reports the overall state of the service with its components
+type Health struct {
+	Status     string            `json:"status"`
+	Timestamp  time.Time         `json:"timestamp"`
+	StartedAt  time.Time         `json:"started_at"`
+	Components []ComponentHealth `json:"components"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HistogramMetric is a distribution logged at one step, such as the gradient
+// or weight histogram of a layer. BinEdges has one more entry than Counts.
+type HistogramMetric struct {
+	Time       time.Time              `json:"time"`
+	RunID      uuid.UUID              `json:"run_id"`
+	MetricName string                 `json:"metric_name"`
+	Step       *int64                 `json:"step"`
+	BinEdges   []float64              `json:"bin_edges"`
+	Counts     []float64              `json:"counts"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type HistogramBatchRequest struct {
+	Histograms []HistogramMetric `json:"histograms" binding:"required,min=1,max=1000"`
+}
+
+type HistogramQueryParams struct {
+	MinStep *int64 `form:"min_step"`
+	MaxStep *int64 `form:"max_step"`
+	Limit   int    `form:"limit" binding:"min=0,max=10000"`
+}
+
+// HistogramSeries describes one histogram metric logged in a run
+type HistogramSeries struct {
+	MetricName string    `json:"metric_name"`
+	Count      int64     `json:"count"`
+	FirstStep  *int64    `json:"first_step"`
+	LastStep   *int64    `json:"last_step"`
+	LastTime   time.Time `json:"last_time"`
+}
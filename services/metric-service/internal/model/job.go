@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// JobStatus is the state of a scheduled background job, shared by every
+// instance of the service through Redis
+type JobStatus struct {
+	Name         string     `json:"name"`
+	Interval     string     `json:"interval"`
+	Running      bool       `json:"running"`
+	LastStarted  *time.Time `json:"last_started"`
+	LastFinished *time.Time `json:"last_finished"`
+	LastDuration float64    `json:"last_duration_seconds"`
+	LastTrigger  string     `json:"last_trigger,omitempty"` // schedule or manual
+	LastResult   string     `json:"last_result,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	Runs         int64      `json:"runs"`
+	Failures     int64      `json:"failures"`
+	NextRun      *time.Time `json:"next_run"`
+	RunningOn    string     `json:"running_on,omitempty"`
+}
+
+// SchedulerStatus reports the scheduler's leader and its jobs
+type SchedulerStatus struct {
+	Instance string      `json:"instance"`
+	Leader   string      `json:"leader"`
+	IsLeader bool        `json:"is_leader"`
+	Jobs     []JobStatus `json:"jobs"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Leaderboard modes: rank runs by the best value of the objective under its
+// goal, or by the value it was last logged with
+const (
+	LeaderboardModeBest  = "best"
+	LeaderboardModeFinal = "final"
+)
+
+// LeaderboardConfig sets the objective a project's runs are ranked by
+type LeaderboardConfig struct {
+	ProjectID  uuid.UUID `json:"project_id"`
+	MetricName string    `json:"metric_name"`
+	Goal       string    `json:"goal"`
+	Mode       string    `json:"mode"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type SetLeaderboardRequest struct {
+	MetricName string `json:"metric_name" binding:"required,max=255"`
+	Goal       string `json:"goal" binding:"required,oneof=min max"`
+	Mode       string `json:"mode" binding:"omitempty,oneof=best final"` // default best
+}
+
+type LeaderboardQueryParams struct {
+	ExperimentID string `form:"experiment_id"`
+	Group        string `form:"group"`
+	JobType      string `form:"job_type"`
+	Offset       int    `form:"offset" binding:"min=0"`
+	Limit        int    `form:"limit" binding:"min=0,max=1000"`
+}
+
+// LeaderboardEntry is the standing of one run on its project's leaderboard.
+// Rank counts from 1 within the filtered listing.
+type LeaderboardEntry struct {
+	Rank         int        `json:"rank"`
+	RunID        uuid.UUID  `json:"run_id"`
+	ExperimentID *uuid.UUID `json:"experiment_id,omitempty"`
+	Group        string     `json:"group,omitempty"`
+	JobType      string     `json:"job_type,omitempty"`
+	Value        float64    `json:"value"`
+	Step         *int64     `json:"step"`
+	Time         time.Time  `json:"time"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Log levels, from least to most severe
+const (
+	LogLevelDebug    = "debug"
+	LogLevelInfo     = "info"
+	LogLevelWarning  = "warning"
+	LogLevelError    = "error"
+	LogLevelCritical = "critical"
+)
+
+// LogLevels lists the log levels in increasing severity
+var LogLevels = []string{LogLevelDebug, LogLevelInfo, LogLevelWarning, LogLevelError, LogLevelCritical}
+
+// LogLine is one line of console output captured from a run
+type LogLine struct {
+	Time  time.Time `json:"time"`
+	RunID uuid.UUID `json:"run_id"`
+	Level string    `json:"level"`
+	Line  string    `json:"line"`
+}
+
+type LogBatchRequest struct {
+	Lines []LogLine `json:"lines" binding:"required,min=1,max=5000"`
+}
+
+type LogQueryParams struct {
+	StartTime *time.Time `form:"start_time"`
+	EndTime   *time.Time `form:"end_time"`
+	MinLevel  string     `form:"min_level" binding:"omitempty,oneof=debug info warning error critical"`
+	Contains  string     `form:"contains"`
+	Limit     int        `form:"limit" binding:"min=0,max=10000"`
+}
+
+type LogPayload struct {
+	Lines []LogLine `json:"lines"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RetentionResult lists the chunks of one hypertable dropped by retention,
+// or that would be with DryRun
+type RetentionResult struct {
+	Table     string        `json:"table"`
+	OlderThan time.Duration `json:"older_than"`
+	Chunks    []string      `json:"chunks"`
+	DryRun    bool          `json:"dry_run"`
+}
+
+// RunArchive describes the metrics of a run exported to object storage
+type RunArchive struct {
+	RunID       uuid.UUID `json:"run_id"`
+	Key         string    `json:"key"`
+	MetricCount int64     `json:"metric_count"`
+	DeletedRows int64     `json:"deleted_rows"`
+}
+
+// LakehouseExport describes a run of the export of metrics to a Delta Lake
+// table: the days it committed, up to ExportedUntil, and the table's version
+type LakehouseExport struct {
+	Table         string    `json:"table"`
+	Version       int64     `json:"version"`
+	Days          int       `json:"days"`
+	Files         int       `json:"files"`
+	Rows          int64     `json:"rows"`
+	ExportedUntil time.Time `json:"exported_until"`
+}
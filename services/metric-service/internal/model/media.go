@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Media kinds
+const (
+	MediaKindImage = "image"
+	MediaKindAudio = "audio"
+	MediaKindPlot  = "plot"
+	MediaKindOther = "other"
+)
+
+// MediaItem describes a logged media sample; the bytes live in object storage
+type MediaItem struct {
+	ID          uuid.UUID              `json:"id"`
+	RunID       uuid.UUID              `json:"run_id"`
+	Step        *int64                 `json:"step"`
+	Key         string                 `json:"key"`
+	Kind        string                 `json:"kind"`
+	ContentType string                 `json:"content_type"`
+	SizeBytes   int64                  `json:"size_bytes"`
+	Digest      string                 `json:"digest"`
+	ObjectKey   string                 `json:"-"`
+	Caption     string                 `json:"caption,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// MediaUploadRequest holds the multipart form fields sent with a media file
+type MediaUploadRequest struct {
+	Key      string `form:"key" binding:"required,max=255"`
+	Kind     string `form:"kind" binding:"required,oneof=image audio plot other"`
+	Step     *int64 `form:"step" binding:"omitempty,min=0"`
+	Caption  string `form:"caption" binding:"max=1024"`
+	Metadata string `form:"metadata"` // JSON object
+}
+
+type MediaQueryParams struct {
+	Key     string `form:"key"`
+	Kind    string `form:"kind"`
+	MinStep *int64 `form:"min_step"`
+	MaxStep *int64 `form:"max_step"`
+	Limit   int    `form:"limit" binding:"min=0,max=10000"`
+}
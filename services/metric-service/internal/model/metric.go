@@ -1,6 +1,9 @@
 package model
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,9 +13,155 @@ type Metric struct {
 	Time       time.Time              `json:"time"`
 	RunID      uuid.UUID              `json:"run_id"`
 	MetricName string                 `json:"metric_name"`
-	Step       *int                   `json:"step"`
-	Value      float64                `json:"value"`
+	Step       *int64                 `json:"step"`
+	Value      float64                `json:"value" openapi:"any"`
+	NodeID     string                 `json:"node_id,omitempty"`
+	Rank       *int                   `json:"rank,omitempty"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	// ValueType is empty for numbers. String and bool values are held in
+	// Text, bools as "true" or "false", and leave Value zero.
+	ValueType string  `json:"value_type,omitempty" binding:"omitempty,oneof=number string bool"`
+	Text      *string `json:"-"`
+	// ProjectID and ExperimentID register the run in its project; they are
+	// recorded per run rather than per value
+	ProjectID    *uuid.UUID `json:"project_id,omitempty"`
+	ExperimentID *uuid.UUID `json:"experiment_id,omitempty"`
+}
+
+// Value types of metrics. Only numbers go into statistics, aggregations and
+// the other numeric reads; strings and bools, such as a training phase or an
+// early stopping flag, are kept in the history and live streams.
+const (
+	ValueTypeNumber = "number"
+	ValueTypeString = "string"
+	ValueTypeBool   = "bool"
+)
+
+// IsNumeric tells whether the metric holds a number
+func (m *Metric) IsNumeric() bool {
+	return m.ValueType == "" || m.ValueType == ValueTypeNumber
+}
+
+// MarshalJSON writes string and bool values as such, and NaN and infinite
+// values, which JSON cannot carry, as null with non_finite naming them
+func (m Metric) MarshalJSON() ([]byte, error) {
+	type metric Metric
+	if !m.IsNumeric() {
+		var value interface{}
+		if m.Text != nil {
+			value = *m.Text
+			if m.ValueType == ValueTypeBool {
+				value = *m.Text == "true"
+			}
+		}
+		return json.Marshal(struct {
+			metric
+			Value interface{} `json:"value"`
+		}{metric: metric(m), Value: value})
+	}
+	name := NonFiniteName(m.Value)
+	if name == "" {
+		return json.Marshal(metric(m))
+	}
+	return json.Marshal(struct {
+		metric
+		Value     *float64 `json:"value"`
+		NonFinite string   `json:"non_finite"`
+	}{metric: metric(m), NonFinite: name})
+}
+
+// UnmarshalJSON accepts the time of a metric in any form ParseTimestamp
+// does, or as an epoch number, and its value as a number, as "NaN",
+// "Infinity" or "-Infinity" (also "inf" and "-inf"), or as null together
+// with non_finite naming one of those, as reads serve them. A JSON true or
+// false is a bool value; strings are only read as text with value_type
+// "string".
+func (m *Metric) UnmarshalJSON(data []byte) error {
+	type metric Metric
+	aux := struct {
+		*metric
+		Time      json.RawMessage `json:"time"`
+		Value     json.RawMessage `json:"value"`
+		NonFinite string          `json:"non_finite"`
+	}{metric: (*metric)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	t, err := unmarshalTimestamp(aux.Time)
+	if err != nil {
+		return err
+	}
+	m.Time = t
+	if m.Text, err = unmarshalText(aux.Value, m.ValueType); err != nil {
+		return fmt.Errorf("metric %s: %w", m.MetricName, err)
+	}
+	if m.Text != nil {
+		if m.ValueType == "" {
+			m.ValueType = ValueTypeBool
+		}
+		return nil
+	}
+	if m.Value, err = unmarshalValue(aux.Value, aux.NonFinite); err != nil {
+		return fmt.Errorf("metric %s: %w", m.MetricName, err)
+	}
+	return nil
+}
+
+// unmarshalText decodes a string or bool value into its text, or returns nil
+// for numeric values. Value types are checked when metrics are validated.
+func unmarshalText(data json.RawMessage, valueType string) (*string, error) {
+	data = bytes.TrimSpace(data)
+	isBool := bytes.Equal(data, []byte("true")) || bytes.Equal(data, []byte("false"))
+	switch valueType {
+	case "":
+		if !isBool {
+			return nil, nil
+		}
+	case ValueTypeString:
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("invalid value %s: value_type string needs a string", data)
+		}
+		return &s, nil
+	case ValueTypeBool:
+		if !isBool {
+			return nil, fmt.Errorf("invalid value %s: value_type bool needs true or false", data)
+		}
+	default:
+		return nil, nil
+	}
+	s := string(data)
+	return &s, nil
+}
+
+// unmarshalValue decodes a metric value, 0 when missing
+func unmarshalValue(data json.RawMessage, nonFinite string) (float64, error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case len(data) == 0:
+		return 0, nil
+	case bytes.Equal(data, []byte("null")):
+		// A bare null may be a client bug rather than a NaN, so it is only
+		// accepted with the encoding reads use
+		if v, ok := parseNonFinite(nonFinite); ok {
+			return v, nil
+		}
+		return 0, fmt.Errorf("invalid value null: send non_finite naming NaN, Infinity or -Infinity with it")
+	case data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return 0, err
+		}
+		if v, ok := parseNonFinite(s); ok {
+			return v, nil
+		}
+		return 0, fmt.Errorf("invalid value %s: must be a number, NaN, Infinity or -Infinity", data)
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return 0, fmt.Errorf("invalid value %s: must be a number, NaN, Infinity or -Infinity", data)
+	}
+	return v, nil
 }
 
 type SystemMetric struct {
@@ -20,35 +169,230 @@ type SystemMetric struct {
 	RunID      uuid.UUID              `json:"run_id"`
 	MetricType string                 `json:"metric_type"` // cpu, gpu, memory, disk, network
 	Value      float64                `json:"value"`
+	NodeID     string                 `json:"node_id,omitempty"`
+	Rank       *int                   `json:"rank,omitempty"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// UnmarshalJSON accepts the time of a system metric as Metric does
+func (m *SystemMetric) UnmarshalJSON(data []byte) error {
+	type systemMetric SystemMetric
+	aux := struct {
+		*systemMetric
+		Time json.RawMessage `json:"time"`
+	}{systemMetric: (*systemMetric)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	t, err := unmarshalTimestamp(aux.Time)
+	if err != nil {
+		return err
+	}
+	m.Time = t
+	return nil
+}
+
 type MetricBatchRequest struct {
 	Metrics []Metric `json:"metrics" binding:"required,min=1,max=1000"`
+	IngestOptions
+}
+
+// IngestOptions override the metric definitions for one batch. Metrics with
+// a rank and step whose rank_reduce resolves to a reduction are combined with
+// the other ranks' values for that step into the canonical (rank-less)
+// series; KeepRanks also stores each rank's value as RankMetricName.
+type IngestOptions struct {
+	RankReduce string `json:"rank_reduce,omitempty" binding:"omitempty,oneof=mean sum max min"`
+	KeepRanks  *bool  `json:"keep_ranks,omitempty"`
+}
+
+// RankMetricName is the name under which a rank's raw values of a reduced metric are kept
+func RankMetricName(metricName string, rank int) string {
+	return fmt.Sprintf("%s/rank_%d", metricName, rank)
+}
+
+// RateSuffix is appended to a cumulative counter's name for its derived per-second rate
+const RateSuffix = "_per_sec"
+
+// RateMetricName is the name of the per-second rate derived from a cumulative
+// counter, e.g. tokens_per_sec from tokens
+func RateMetricName(metricName string) string {
+	return metricName + RateSuffix
+}
+
+// RankReduction says how the per-rank values of one metric are combined
+type RankReduction struct {
+	Reduce    string
+	KeepRanks bool
 }
 
 type SystemMetricBatchRequest struct {
 	Metrics []SystemMetric `json:"metrics" binding:"required,min=1,max=1000"`
 }
 
+// Limits of metric queries: the default, and the largest for responses that
+// are built in memory and for streamed ones
+const (
+	DefaultMetricQueryLimit     = 1000
+	MaxMetricQueryLimit         = 10000
+	MaxStreamedMetricQueryLimit = 10000000
+)
+
 type MetricQueryParams struct {
 	StartTime  *time.Time `form:"start_time"`
 	EndTime    *time.Time `form:"end_time"`
-	MinStep    *int       `form:"min_step"`
-	MaxStep    *int       `form:"max_step"`
-	Limit      int        `form:"limit" binding:"min=1,max=10000"`
+	MinStep    *int64     `form:"min_step"`
+	MaxStep    *int64     `form:"max_step"`
+	Limit      int        `form:"limit" binding:"omitempty,min=1"`
 	MetricName string     `form:"metric_name"`
+	// MetricNames restricts the query to several metrics, given as a
+	// comma-separated list or repeated
+	MetricNames []string `form:"metric_names"`
+	// MetricNamePattern restricts the query to the metrics whose names match
+	// it, a glob such as train/* or, with PatternType regex, a regular
+	// expression
+	MetricNamePattern string `form:"metric_name_pattern" binding:"max=256"`
+	PatternType       string `form:"pattern_type" binding:"omitempty,oneof=glob regex"`
+	// Cursor resumes after the last row of a page, from its next_cursor
+	Cursor string `form:"cursor"`
+	// Stream writes rows as they are read instead of building the response
+	Stream bool `form:"stream"`
+	// All returns every matching row rather than a page; only with Stream
+	All bool `form:"all"`
+	// OrderBy is time (default) or step, and Direction desc (default) or asc
+	OrderBy   string `form:"order_by" binding:"omitempty,oneof=time step"`
+	Direction string `form:"direction" binding:"omitempty,oneof=asc desc"`
+	// Offset skips the first rows, to resume an export; it is not bound
+	// from queries, which page by cursor
+	Offset int `form:"-"`
+}
+
+// Pattern types of MetricQueryParams.MetricNamePattern
+const (
+	PatternGlob  = "glob"
+	PatternRegex = "regex"
+)
+
+// Orders of metric queries, by OrderBy and Direction
+const (
+	OrderByTime   = "time"
+	OrderByStep   = "step"
+	DirectionAsc  = "asc"
+	DirectionDesc = "desc"
+)
+
+// Order names the order of the query as its cursors record it: empty for
+// the default, newest first, or the column and direction
+func (p MetricQueryParams) Order() string {
+	orderBy, direction := p.OrderBy, p.Direction
+	if orderBy == "" {
+		orderBy = OrderByTime
+	}
+	if direction == "" {
+		direction = DirectionDesc
+	}
+	if orderBy == OrderByTime && direction == DirectionDesc {
+		return ""
+	}
+	return orderBy + " " + direction
+}
+
+// CursorOf returns the position of m in the order of the query
+func (p MetricQueryParams) CursorOf(m Metric) MetricCursor {
+	cursor := MetricCursorOf(m)
+	cursor.Order = p.Order()
+	return cursor
+}
+
+// After returns the position Cursor resumes after, or nil without a cursor.
+// A cursor of a query in another order is invalid.
+func (p MetricQueryParams) After() (*MetricCursor, error) {
+	if p.Cursor == "" {
+		return nil, nil
+	}
+	var after MetricCursor
+	if err := DecodeCursor(p.Cursor, &after); err != nil {
+		return nil, err
+	}
+	if after.Order != p.Order() {
+		return nil, ErrInvalidCursor
+	}
+	return &after, nil
 }
 
+type SystemMetricQueryParams struct {
+	StartTime *time.Time `form:"start_time"`
+	EndTime   *time.Time `form:"end_time"`
+	Limit     int        `form:"limit" binding:"omitempty,min=1"`
+	Cursor    string     `form:"cursor"`
+}
+
+// After returns the position Cursor resumes after, or nil without a cursor
+func (p SystemMetricQueryParams) After() (*SystemMetricCursor, error) {
+	if p.Cursor == "" {
+		return nil, nil
+	}
+	var after SystemMetricCursor
+	if err := DecodeCursor(p.Cursor, &after); err != nil {
+		return nil, err
+	}
+	return &after, nil
+}
+
+// MetricStats describes the values of a metric. Count covers every value;
+// the minimum, maximum, mean and standard deviation only the finite ones,
+// and are null without any. NaN and infinite values are counted apart, with
+// the time of the first, which usually marks where a run diverged.
 type MetricStats struct {
+	MetricName         string     `json:"metric_name"`
+	Count              int64      `json:"count"`
+	MinValue           *float64   `json:"min_value"`
+	MaxValue           *float64   `json:"max_value"`
+	AvgValue           *float64   `json:"avg_value"`
+	StdDev             *float64   `json:"std_dev"`
+	NonFiniteCount     int64      `json:"non_finite_count"`
+	FirstNonFiniteTime *time.Time `json:"first_non_finite_time"`
+	FirstTime          time.Time  `json:"first_time"`
+	LastTime           time.Time  `json:"last_time"`
+}
+
+// MetricStatsRequest asks for the statistics of several metrics of a run
+type MetricStatsRequest struct {
+	MetricNames []string `json:"metric_names" binding:"required,min=1,max=100,dive,required"`
+}
+
+// MetricNameSummary describes a metric logged in a run, for metric pickers.
+// FirstStep and LastStep are null for metrics logged without steps, and Last
+// is the latest value, by time.
+type MetricNameSummary struct {
 	MetricName string    `json:"metric_name"`
 	Count      int64     `json:"count"`
-	MinValue   float64   `json:"min_value"`
-	MaxValue   float64   `json:"max_value"`
-	AvgValue   float64   `json:"avg_value"`
-	StdDev     *float64  `json:"std_dev"`
-	FirstTime  time.Time `json:"first_time"`
-	LastTime   time.Time `json:"last_time"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	FirstStep  *int64    `json:"first_step"`
+	LastStep   *int64    `json:"last_step"`
+	Last       Metric    `json:"last"`
+}
+
+// Functions of MetricBucketParams, the value reported for each bucket
+const (
+	BucketAvg   = "avg"
+	BucketMin   = "min"
+	BucketMax   = "max"
+	BucketSum   = "sum"
+	BucketCount = "count"
+)
+
+// MetricBucketParams select the time buckets of a metric. Bucket is the
+// width of each, a duration such as 30s or 1m or a number of seconds.
+type MetricBucketParams struct {
+	StartTime *time.Time `form:"start_time"`
+	EndTime   *time.Time `form:"end_time"`
+	MinStep   *int64     `form:"min_step"`
+	MaxStep   *int64     `form:"max_step"`
+	Bucket    string     `form:"bucket" binding:"required"`
+	Fn        string     `form:"fn" binding:"omitempty,oneof=avg min max sum count"`
+	Limit     int        `form:"limit" binding:"min=0,max=10000"` // latest buckets
 }
 
 type RunMetricsSummary struct {
@@ -57,7 +401,7 @@ type RunMetricsSummary struct {
 }
 
 type WebSocketMessage struct {
-	Type    string      `json:"type"` // "subscribe", "unsubscribe", "metric"
+	Type    string      `json:"type"` // "subscribe", "unsubscribe", "metric", "annotation", "anomaly", "alert"
 	Payload interface{} `json:"payload"`
 }
 
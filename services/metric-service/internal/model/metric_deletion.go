@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MetricDeletion is the audit record of values of a metric deleted from a
+// run. MinStep and MaxStep bound the steps deleted; without either bound the
+// whole metric was deleted, including values logged without a step.
+type MetricDeletion struct {
+	ID          uuid.UUID `json:"id"`
+	RunID       uuid.UUID `json:"run_id"`
+	MetricName  string    `json:"metric_name"`
+	MinStep     *int64    `json:"min_step"`
+	MaxStep     *int64    `json:"max_step"`
+	DeletedRows int64     `json:"deleted_rows"`
+	Reason      string    `json:"reason,omitempty"`
+	DeletedAt   time.Time `json:"deleted_at"`
+}
+
+type DeleteMetricParams struct {
+	MinStep *int64 `form:"min_step"`
+	MaxStep *int64 `form:"max_step"`
+	Reason  string `form:"reason" binding:"max=1024"`
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// MetricNamespaceSeparator splits metric names into namespaces, e.g.
+// train/loss into the namespace train and the metric loss
+const MetricNamespaceSeparator = "/"
+
+// MetricNameCount is a metric logged in a run with the number of its values
+type MetricNameCount struct {
+	MetricName string
+	Count      int64
+	LastSeen   time.Time
+}
+
+// MetricTreeNode is a namespace or metric of a run's metric tree. A name can
+// be both when metrics are logged under it and below it, as with lr and lr/group_0.
+type MetricTreeNode struct {
+	Name string `json:"name"`
+	// Path is the full metric name or namespace, without a trailing separator
+	Path string `json:"path"`
+	// IsMetric is set when a metric is logged under Path itself
+	IsMetric bool `json:"is_metric"`
+	// MetricCount and PointCount cover the node's own metric and every metric below it
+	MetricCount int               `json:"metric_count"`
+	PointCount  int64             `json:"point_count"`
+	LastSeen    time.Time         `json:"last_seen"`
+	Children    []*MetricTreeNode `json:"children,omitempty"`
+}
+
+// BuildMetricTree nests metric names by namespace. Nodes are sorted by name
+// at every level.
+func BuildMetricTree(metrics []MetricNameCount) []*MetricTreeNode {
+	root := &MetricTreeNode{}
+	index := make(map[string]*MetricTreeNode)
+	for _, m := range metrics {
+		parent := root
+		segments := strings.Split(m.MetricName, MetricNamespaceSeparator)
+		for i, segment := range segments {
+			path := strings.Join(segments[:i+1], MetricNamespaceSeparator)
+			node, ok := index[path]
+			if !ok {
+				node = &MetricTreeNode{Name: segment, Path: path}
+				index[path] = node
+				parent.Children = append(parent.Children, node)
+			}
+			node.MetricCount++
+			node.PointCount += m.Count
+			if m.LastSeen.After(node.LastSeen) {
+				node.LastSeen = m.LastSeen
+			}
+			parent = node
+		}
+		parent.IsMetric = true
+	}
+	sortMetricTree(root.Children)
+	return root.Children
+}
+
+func sortMetricTree(nodes []*MetricTreeNode) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	for _, node := range nodes {
+		sortMetricTree(node.Children)
+	}
+}
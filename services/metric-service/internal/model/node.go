@@ -0,0 +1,67 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Reductions applied across the nodes or ranks of a distributed run.
+// ReduceNone breaks the series out per node or rank instead.
+const (
+	ReduceNone = "none"
+	ReduceMean = "mean"
+	ReduceMax  = "max"
+	ReduceMin  = "min"
+	ReduceSum  = "sum"
+)
+
+// GPU fields that can be aggregated across nodes
+var GPUAggregateFields = []string{
+	"utilization", "memory_utilization", "memory_used_mb", "temperature_c", "power_w",
+}
+
+type NodeQueryParams struct {
+	StartTime  *time.Time `form:"start_time"`
+	EndTime    *time.Time `form:"end_time"`
+	MinStep    *int64     `form:"min_step"`
+	MaxStep    *int64     `form:"max_step"`
+	NodeID     string     `form:"node_id"`
+	Reduce     string     `form:"reduce"`                           // none, mean, max, min, sum
+	MetricType string     `form:"metric_type"`                      // system metrics only
+	Field      string     `form:"field"`                            // GPU metrics only
+	Bucket     int        `form:"bucket" binding:"min=0,max=86400"` // seconds, system and GPU metrics
+	Limit      int        `form:"limit" binding:"min=0,max=10000"`  // points per series
+}
+
+// AggregatePoint is one step or time bucket of a series. Value is the
+// requested reduction; Min, Max and Count describe the values it covers, so
+// a low Min with a high Value points at a straggler.
+type AggregatePoint struct {
+	Time  time.Time `json:"time"`
+	Step  *int64    `json:"step,omitempty"`
+	Value float64   `json:"value"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Count int64     `json:"count"`
+}
+
+// MarshalJSON writes reductions over NaN or infinite values as null
+func (p AggregatePoint) MarshalJSON() ([]byte, error) {
+	type aggregatePoint AggregatePoint
+	if NonFiniteName(p.Value) == "" && NonFiniteName(p.Min) == "" && NonFiniteName(p.Max) == "" {
+		return json.Marshal(aggregatePoint(p))
+	}
+	return json.Marshal(struct {
+		aggregatePoint
+		Value *float64 `json:"value"`
+		Min   *float64 `json:"min"`
+		Max   *float64 `json:"max"`
+	}{aggregatePoint: aggregatePoint(p), Value: finiteOrNil(p.Value), Min: finiteOrNil(p.Min), Max: finiteOrNil(p.Max)})
+}
+
+// NodeSeries holds the points of one node, or of one rank on a node
+type NodeSeries struct {
+	NodeID string           `json:"node_id"`
+	Rank   *int             `json:"rank,omitempty"`
+	Points []AggregatePoint `json:"points"`
+}
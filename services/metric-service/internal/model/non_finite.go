@@ -0,0 +1,120 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// NonFinitePolicy says what ingest does with NaN and infinite metric values
+type NonFinitePolicy string
+
+const (
+	// NonFiniteReject fails the batch
+	NonFiniteReject NonFinitePolicy = "reject"
+	// NonFiniteNull keeps the value; reads serve it as null with non_finite
+	// naming it, and statistics count it apart from the finite values
+	NonFiniteNull NonFinitePolicy = "null"
+	// NonFiniteClamp replaces infinities with the largest finite values of
+	// their sign; NaN, which has no nearest value, is kept as under null
+	NonFiniteClamp NonFinitePolicy = "clamp"
+)
+
+// ParseNonFinitePolicy parses a policy name
+func ParseNonFinitePolicy(name string) (NonFinitePolicy, error) {
+	switch policy := NonFinitePolicy(strings.ToLower(name)); policy {
+	case NonFiniteReject, NonFiniteNull, NonFiniteClamp:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown policy %q: must be reject, null or clamp", name)
+}
+
+// NonFiniteName returns the name of a NaN or infinite value, as Postgres
+// and JavaScript spell it, or "" for finite values
+func NonFiniteName(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	}
+	return ""
+}
+
+// parseNonFinite reads the string forms of NaN and the infinities clients
+// send in place of numbers, in any case
+func parseNonFinite(s string) (float64, bool) {
+	switch strings.ToLower(s) {
+	case "nan":
+		return math.NaN(), true
+	case "inf", "+inf", "infinity", "+infinity":
+		return math.Inf(1), true
+	case "-inf", "-infinity":
+		return math.Inf(-1), true
+	}
+	return 0, false
+}
+
+// finiteOrNil returns v, or nil when it is NaN or infinite, for fields that
+// JSON cannot carry otherwise
+func finiteOrNil(v float64) *float64 {
+	if NonFiniteName(v) != "" {
+		return nil
+	}
+	return &v
+}
+
+// nonFiniteTokens are the bare tokens that encoders such as Python's json
+// module write for NaN and the infinities
+var nonFiniteTokens = [][]byte{[]byte("NaN"), []byte("Infinity"), []byte("-Infinity")}
+
+// QuoteNonFiniteJSON quotes the bare NaN, Infinity and -Infinity tokens of a
+// JSON document, which encoding/json rejects, into strings that metric
+// values accept. Strings in the document are left as they are.
+func QuoteNonFiniteJSON(data []byte) []byte {
+	if !bytes.Contains(data, nonFiniteTokens[0]) && !bytes.Contains(data, nonFiniteTokens[1]) {
+		return data
+	}
+
+	var out []byte
+	copied := 0
+	inString, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		if c == '"' {
+			inString = true
+			continue
+		}
+		if c != 'N' && c != 'I' && c != '-' {
+			continue
+		}
+		// Outside strings these letters start no other token
+		for _, token := range nonFiniteTokens {
+			if bytes.HasPrefix(data[i:], token) {
+				out = append(out, data[copied:i]...)
+				out = append(append(append(out, '"'), token...), '"')
+				i += len(token) - 1
+				copied = i + 1
+				break
+			}
+		}
+	}
+	if out == nil {
+		return data
+	}
+	return append(out, data[copied:]...)
+}
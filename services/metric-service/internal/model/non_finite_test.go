@@ -0,0 +1,79 @@
+package model
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestQuoteNonFiniteJSON(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"finite", `{"value": 1.5}`, `{"value": 1.5}`},
+		{"NaN", `{"value": NaN}`, `{"value": "NaN"}`},
+		{"infinities", `[Infinity, -Infinity, -1]`, `["Infinity", "-Infinity", -1]`},
+		{"inside string", `{"metric_name": "NaN Infinity", "value": NaN}`, `{"metric_name": "NaN Infinity", "value": "NaN"}`},
+		{"escaped quote", `{"metric_name": "a\"NaN", "value": Infinity}`, `{"metric_name": "a\"NaN", "value": "Infinity"}`},
+		{"already quoted", `{"value": "NaN"}`, `{"value": "NaN"}`},
+		{"empty", ``, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(QuoteNonFiniteJSON([]byte(tt.in))); got != tt.want {
+				t.Fatalf("QuoteNonFiniteJSON(%s) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalValue(t *testing.T) {
+	tests := []struct {
+		data      string
+		nonFinite string
+		want      float64
+		wantErr   bool
+	}{
+		{``, "", 0, false},
+		{`0.25`, "", 0.25, false},
+		{` -3 `, "", -3, false},
+		{`"NaN"`, "", math.NaN(), false},
+		{`"inf"`, "", math.Inf(1), false},
+		{`"-Infinity"`, "", math.Inf(-1), false},
+		{`null`, "NaN", math.NaN(), false},
+		{`null`, "-Infinity", math.Inf(-1), false},
+		{`null`, "", 0, true},
+		{`null`, "huge", 0, true},
+		{`"1.5"`, "", 0, true},
+		{`{}`, "", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := unmarshalValue(json.RawMessage(tt.data), tt.nonFinite)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("unmarshalValue(%s, %q) error = %v, want error %v", tt.data, tt.nonFinite, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got != tt.want && !(math.IsNaN(got) && math.IsNaN(tt.want)) {
+			t.Errorf("unmarshalValue(%s, %q) = %v, want %v", tt.data, tt.nonFinite, got, tt.want)
+		}
+	}
+}
+
+func TestMetricJSONRoundTrip(t *testing.T) {
+	for _, v := range []float64{1.5, math.NaN(), math.Inf(1), math.Inf(-1)} {
+		data, err := json.Marshal(Metric{MetricName: "loss", Value: v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m Metric
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", data, err)
+		}
+		if NonFiniteName(m.Value) != NonFiniteName(v) || (NonFiniteName(v) == "" && m.Value != v) {
+			t.Fatalf("%v came back as %v from %s", v, m.Value, data)
+		}
+	}
+}
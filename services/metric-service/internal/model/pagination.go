@@ -0,0 +1,85 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor is returned for cursors not issued by the endpoint
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor turns the position of the last row of a page into the opaque
+// next_cursor token of list responses
+func EncodeCursor(position interface{}) string {
+	data, err := json.Marshal(position)
+	if err != nil {
+		// positions are plain structs, which always marshal
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reads a cursor token into position
+func DecodeCursor(token string, position interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, position); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// MetricCursor is the position of a metric in the order metric queries
+// return them: newest first, ties broken by name, node, rank and step, all
+// descending, unless Order says otherwise (see MetricQueryParams.Order).
+// Ranks and steps left unset order as -1.
+type MetricCursor struct {
+	Time       time.Time `json:"t"`
+	MetricName string    `json:"n"`
+	NodeID     string    `json:"i,omitempty"`
+	Rank       int       `json:"r"`
+	Step       int64     `json:"s"`
+	Order      string    `json:"o,omitempty"`
+}
+
+// MetricCursorOf returns the position of m
+func MetricCursorOf(m Metric) MetricCursor {
+	return MetricCursor{
+		Time:       m.Time,
+		MetricName: m.MetricName,
+		NodeID:     m.NodeID,
+		Rank:       intOr(m.Rank, -1),
+		Step:       intOr(m.Step, -1),
+	}
+}
+
+// SystemMetricCursor is the position of a system metric in the order system
+// metric queries return them: newest first, ties broken by type, node and
+// rank, all descending
+type SystemMetricCursor struct {
+	Time       time.Time `json:"t"`
+	MetricType string    `json:"m"`
+	NodeID     string    `json:"i,omitempty"`
+	Rank       int       `json:"r"`
+}
+
+// SystemMetricCursorOf returns the position of m
+func SystemMetricCursorOf(m SystemMetric) SystemMetricCursor {
+	return SystemMetricCursor{
+		Time:       m.Time,
+		MetricType: m.MetricType,
+		NodeID:     m.NodeID,
+		Rank:       intOr(m.Rank, -1),
+	}
+}
+
+func intOr[T int | int64](v *T, fallback T) T {
+	if v == nil {
+		return fallback
+	}
+	return *v
+}
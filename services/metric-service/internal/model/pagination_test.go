@@ -0,0 +1,59 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMetricCursorRoundTrip(t *testing.T) {
+	rank, step := 3, int64(42)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+	tests := []struct {
+		name   string
+		metric Metric
+		want   MetricCursor
+	}{
+		{"set", Metric{Time: now, MetricName: "loss", NodeID: "n1", Rank: &rank, Step: &step}, MetricCursor{now, "loss", "n1", 3, 42, ""}},
+		{"unset rank and step", Metric{Time: now, MetricName: "loss"}, MetricCursor{now, "loss", "", -1, -1, ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got MetricCursor
+			if err := DecodeCursor(EncodeCursor(MetricCursorOf(tt.metric)), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !got.Time.Equal(tt.want.Time) || got.MetricName != tt.want.MetricName || got.NodeID != tt.want.NodeID ||
+				got.Rank != tt.want.Rank || got.Step != tt.want.Step {
+				t.Fatalf("cursor = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, token := range []string{"%%%", "bm90IGpzb24", "W10"} {
+		var after MetricCursor
+		if err := DecodeCursor(token, &after); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q) = %v, want ErrInvalidCursor", token, err)
+		}
+	}
+}
+
+func TestMetricQueryCursorOrder(t *testing.T) {
+	m := Metric{Time: time.Unix(100, 0), MetricName: "loss"}
+	byStep := MetricQueryParams{OrderBy: OrderByStep, Direction: DirectionAsc}
+	byTime := MetricQueryParams{OrderBy: OrderByTime, Direction: DirectionDesc}
+
+	if _, err := (MetricQueryParams{Cursor: EncodeCursor(byTime.CursorOf(m))}).After(); err != nil {
+		t.Errorf("cursor of the default order = %v, want it accepted without order_by", err)
+	}
+	byStep.Cursor = EncodeCursor(byStep.CursorOf(m))
+	if _, err := byStep.After(); err != nil {
+		t.Errorf("cursor of a step order = %v, want it accepted", err)
+	}
+	byTime.Cursor = byStep.Cursor
+	if _, err := byTime.After(); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor of a step order read in time order = %v, want ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RunProject places a run in a project and optionally in an experiment within
+// it. Runs are registered when a batch names their project and stay active
+// while batches keep arriving.
+type RunProject struct {
+	RunID        uuid.UUID  `json:"run_id"`
+	ProjectID    uuid.UUID  `json:"project_id"`
+	ExperimentID *uuid.UUID `json:"experiment_id,omitempty"`
+	FirstSeenAt  time.Time  `json:"first_seen_at"`
+	LastSeenAt   time.Time  `json:"last_seen_at"`
+}
+
+type SetRunProjectRequest struct {
+	ProjectID    uuid.UUID  `json:"project_id" binding:"required"`
+	ExperimentID *uuid.UUID `json:"experiment_id"`
+}
+
+type ProjectRunQueryParams struct {
+	ExperimentID string        `form:"experiment_id"`
+	ActiveWithin time.Duration `form:"active_within"` // e.g. 15m; 0 lists every run
+	MetricName   string        `form:"metric_name"`   // metric reported as latest, default loss
+	Limit        int           `form:"limit" binding:"min=0,max=1000"`
+}
+
+// ProjectRun is a run of a project with the latest value of one metric
+type ProjectRun struct {
+	RunProject
+	MetricName  string     `json:"metric_name"`
+	LatestValue *float64   `json:"latest_value"`
+	LatestStep  *int64     `json:"latest_step"`
+	LatestTime  *time.Time `json:"latest_time"`
+}
+
+type ProjectLatestMetricsParams struct {
+	MetricName   string        `form:"metric_name" binding:"required"`
+	ActiveWithin time.Duration `form:"active_within"` // default 15m
+	ExperimentID string        `form:"experiment_id"`
+	Limit        int           `form:"limit" binding:"min=0,max=1000"`
+}
+
+// ProjectRunMetric is an active run of a project with the latest value it
+// logged of one metric
+type ProjectRunMetric struct {
+	RunProject
+	Metric Metric `json:"metric"`
+}
+
+// ExperimentInfo summarizes the runs of one experiment in a project. Runs
+// outside any experiment are reported under a nil ExperimentID.
+type ExperimentInfo struct {
+	ExperimentID *uuid.UUID `json:"experiment_id"`
+	RunCount     int64      `json:"run_count"`
+	FirstSeenAt  time.Time  `json:"first_seen_at"`
+	LastSeenAt   time.Time  `json:"last_seen_at"`
+}
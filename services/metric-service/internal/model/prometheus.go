@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// Queries and results of the Prometheus remote-read protocol, served under
+// /prom/read. Each metric of a run is a series labelled with its name as
+// __name__ and its run as run_id, and with node_id and rank when it has them.
+
+// Labels of the series of remote reads
+const (
+	PromLabelName   = "__name__"
+	PromLabelRunID  = "run_id"
+	PromLabelNodeID = "node_id"
+	PromLabelRank   = "rank"
+)
+
+// Types of label matchers, numbered as in the protocol
+const (
+	PromMatchEqual = iota
+	PromMatchNotEqual
+	PromMatchRegexp
+	PromMatchNotRegexp
+)
+
+// PromLabelMatcher selects series by the value of one label. A series
+// without the label matches as if its value were empty.
+type PromLabelMatcher struct {
+	Type  int
+	Name  string
+	Value string
+}
+
+// PromReadQuery selects the samples of the series its matchers all match
+// within [Start, End]
+type PromReadQuery struct {
+	Start    time.Time
+	End      time.Time
+	Matchers []PromLabelMatcher
+}
+
+type PromLabel struct {
+	Name  string
+	Value string
+}
+
+type PromSample struct {
+	Value float64
+	Time  time.Time
+}
+
+// PromTimeSeries is a series of a remote read, its labels sorted by name and
+// its samples oldest first
+type PromTimeSeries struct {
+	Labels  []PromLabel
+	Samples []PromSample
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Report is a saved comparison: a set of runs and metrics plus the chart
+// configuration used to render them
+type Report struct {
+	ID          uuid.UUID              `json:"id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	RunIDs      []uuid.UUID            `json:"run_ids"`
+	MetricNames []string               `json:"metric_names"`
+	Config      map[string]interface{} `json:"config"`
+	Snapshot    *ReportSnapshot        `json:"snapshot,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+// ReportSnapshot freezes the underlying data of a report at save time
+type ReportSnapshot struct {
+	TakenAt time.Time      `json:"taken_at"`
+	Series  []ReportSeries `json:"series"`
+}
+
+type ReportSeries struct {
+	RunID      uuid.UUID    `json:"run_id"`
+	MetricName string       `json:"metric_name"`
+	Stats      *MetricStats `json:"stats"`
+	Points     []Metric     `json:"points"`
+	Truncated  bool         `json:"truncated"`
+}
+
+type CreateReportRequest struct {
+	Name        string                 `json:"name" binding:"required,max=255"`
+	Description string                 `json:"description"`
+	RunIDs      []uuid.UUID            `json:"run_ids" binding:"required,min=1,max=50"`
+	MetricNames []string               `json:"metric_names" binding:"required,min=1,max=50"`
+	Config      map[string]interface{} `json:"config"`
+	Snapshot    *bool                  `json:"snapshot"`
+}
+
+type UpdateReportRequest struct {
+	Name        *string                `json:"name" binding:"omitempty,max=255"`
+	Description *string                `json:"description"`
+	Config      map[string]interface{} `json:"config"`
+}
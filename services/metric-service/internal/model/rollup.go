@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxRollupRuns is the most runs one rollup export may cover
+const MaxRollupRuns = 100
+
+// MetricRollup is an hourly bucket of a run's metric from the metrics_hourly
+// continuous aggregate. Count, like the statistics, covers the numeric values
+// of the bucket only; the statistics are nil when it holds none, and StdDev
+// also when it holds a single one.
+type MetricRollup struct {
+	RunID      uuid.UUID `json:"run_id"`
+	MetricName string    `json:"metric_name"`
+	Bucket     time.Time `json:"bucket"`
+	Avg        *float64  `json:"avg"`
+	Min        *float64  `json:"min"`
+	Max        *float64  `json:"max"`
+	StdDev     *float64  `json:"stddev"`
+	Count      int64     `json:"count"`
+}
+
+type RollupQueryParams struct {
+	RunIDs     string     `form:"run_ids" binding:"required"` // comma-separated
+	MetricName string     `form:"metric_name"`
+	StartTime  *time.Time `form:"start_time" binding:"required"`
+	EndTime    *time.Time `form:"end_time" binding:"required"`
+}
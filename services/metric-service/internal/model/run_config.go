@@ -0,0 +1,72 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Config sources: runs either report their hyperparameters directly or
+// inherit the configuration their sweep trial suggested
+const (
+	ConfigSourceRun   = "run"
+	ConfigSourceSweep = "sweep"
+)
+
+// RunConfig holds the hyperparameters and other configuration of a run
+type RunConfig struct {
+	RunID     uuid.UUID              `json:"run_id"`
+	Config    map[string]interface{} `json:"config"`
+	Source    string                 `json:"source"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+type SetRunConfigRequest struct {
+	Config map[string]interface{} `json:"config" binding:"required"`
+}
+
+type RunDiffRequest struct {
+	RunA             uuid.UUID `json:"run_a" binding:"required"`
+	RunB             uuid.UUID `json:"run_b" binding:"required"`
+	MetricNames      []string  `json:"metric_names" binding:"max=100"` // empty compares every summarized metric
+	IncludeUnchanged bool      `json:"include_unchanged"`
+}
+
+// Value diff changes, from run A to run B
+const (
+	DiffAdded     = "added"   // only run B has the key
+	DiffRemoved   = "removed" // only run A has the key
+	DiffChanged   = "changed"
+	DiffUnchanged = "unchanged"
+)
+
+// ValueDiff compares one key between two runs. Nested config keys are
+// flattened with dots, e.g. optimizer.lr.
+type ValueDiff struct {
+	Key    string      `json:"key"`
+	A      interface{} `json:"a"`
+	B      interface{} `json:"b"`
+	Change string      `json:"change"`
+}
+
+// MetricDiff sets the final and best values of one metric side by side.
+// Deltas are B minus A; Better names the run with the better best value.
+type MetricDiff struct {
+	MetricName string            `json:"metric_name"`
+	Goal       string            `json:"goal"`
+	A          *RunMetricSummary `json:"a"`
+	B          *RunMetricSummary `json:"b"`
+	FinalDelta *float64          `json:"final_delta"`
+	BestDelta  *float64          `json:"best_delta"`
+	Better     string            `json:"better,omitempty"` // a, b, or empty when tied or unknown
+}
+
+// RunDiff answers what changed between two runs
+type RunDiff struct {
+	RunA     uuid.UUID    `json:"run_a"`
+	RunB     uuid.UUID    `json:"run_b"`
+	Config   []ValueDiff  `json:"config"`
+	Tags     []ValueDiff  `json:"tags"`
+	Metadata []ValueDiff  `json:"metadata"` // group, job type, project and experiment
+	Metrics  []MetricDiff `json:"metrics"`
+}
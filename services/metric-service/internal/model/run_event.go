@@ -0,0 +1,72 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Run lifecycle states
+const (
+	RunStateCreated  = "created"
+	RunStateRunning  = "running"
+	RunStatePaused   = "paused"
+	RunStateFinished = "finished"
+	RunStateCrashed  = "crashed"
+	RunStateKilled   = "killed"
+)
+
+// RunEvent records a run changing state. PreviousState is the state the run
+// left, empty for its first event. Source tells who reported the change,
+// e.g. the SDK or a detector of the service.
+type RunEvent struct {
+	ID            uuid.UUID              `json:"id"`
+	RunID         uuid.UUID              `json:"run_id"`
+	Time          time.Time              `json:"time"`
+	State         string                 `json:"state"`
+	PreviousState string                 `json:"previous_state,omitempty"`
+	Step          *int64                 `json:"step"`
+	Reason        string                 `json:"reason,omitempty"`
+	Source        string                 `json:"source,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+}
+
+type CreateRunEventRequest struct {
+	State    string                 `json:"state" binding:"required,oneof=created running paused finished crashed killed"`
+	Time     *time.Time             `json:"time"`
+	Step     *int64                 `json:"step"`
+	Reason   string                 `json:"reason" binding:"max=1024"`
+	Source   string                 `json:"source" binding:"max=64"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+type RunEventQueryParams struct {
+	StartTime *time.Time `form:"start_time"`
+	EndTime   *time.Time `form:"end_time"`
+	Limit     int        `form:"limit" binding:"min=0,max=10000"`
+}
+
+// RunEventPayload is published on a run's events channel
+type RunEventPayload struct {
+	Events []RunEvent `json:"events"`
+}
+
+// StateInterval is a span of time a run spent in one state. EndTime is nil
+// for the current state.
+type StateInterval struct {
+	State     string     `json:"state"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// RunState is the current state of a run with the intervals of its history,
+// for shading charts, and the seconds spent in each state up to now
+type RunState struct {
+	RunID     uuid.UUID          `json:"run_id"`
+	State     string             `json:"state"`
+	Since     time.Time          `json:"since"`
+	Intervals []StateInterval    `json:"intervals"`
+	Durations map[string]float64 `json:"durations"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outcomes of the export of a run
+const (
+	RunExportExported = "exported"
+	RunExportFailed   = "failed"
+)
+
+// RunEndStates are the states a run ends in, after which its metrics are
+// exported
+var RunEndStates = []string{RunStateFinished, RunStateCrashed, RunStateKilled}
+
+// EndedRun is a run whose latest state is one of RunEndStates, entered at
+// EndedAt
+type EndedRun struct {
+	RunID   uuid.UUID `json:"run_id"`
+	State   string    `json:"state"`
+	EndedAt time.Time `json:"ended_at"`
+}
+
+// RunExport is the latest export of an ended run's metrics to object
+// storage. Key, Rows and Size describe the object written; Error says why a
+// failed export failed.
+type RunExport struct {
+	RunID     uuid.UUID `json:"run_id"`
+	State     string    `json:"state"`
+	EndedAt   time.Time `json:"ended_at"`
+	Status    string    `json:"status"`
+	Format    string    `json:"format"`
+	Key       string    `json:"key,omitempty"`
+	Rows      int64     `json:"rows"`
+	Size      int64     `json:"size"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type RunExportQueryParams struct {
+	Status string `form:"status" binding:"omitempty,oneof=exported failed"`
+	Limit  int    `form:"limit" binding:"min=0,max=1000"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RunMetricSummary holds the final and best value of one metric in a run,
+// maintained on write so leaderboards never scan history
+type RunMetricSummary struct {
+	RunID      uuid.UUID `json:"run_id"`
+	MetricName string    `json:"metric_name"`
+	Goal       string    `json:"goal"`
+	FinalValue float64   `json:"final_value"`
+	FinalStep  *int64    `json:"final_step"`
+	FinalTime  time.Time `json:"final_time"`
+	BestValue  float64   `json:"best_value"`
+	BestStep   *int64    `json:"best_step"`
+	BestTime   time.Time `json:"best_time"`
+	Count      int64     `json:"count"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type SummaryQueryParams struct {
+	MetricName string `form:"metric_name" binding:"required"`
+	RunIDs     string `form:"run_ids"` // comma-separated
+	Sort       string `form:"sort" binding:"omitempty,oneof=best final"`
+	Group      string `form:"group"`
+	JobType    string `form:"job_type"`
+	GroupBy    string `form:"group_by" binding:"omitempty,oneof=group"`
+	Limit      int    `form:"limit" binding:"min=0,max=1000"`
+
+	// ProjectID is parsed from ?project_id by the handler
+	ProjectID *uuid.UUID `form:"-"`
+}
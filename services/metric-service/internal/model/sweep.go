@@ -0,0 +1,88 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sweep search strategies
+const (
+	SweepStrategyGrid   = "grid"
+	SweepStrategyRandom = "random"
+	SweepStrategyBayes  = "bayes"
+)
+
+// Sweep statuses
+const (
+	SweepStatusRunning  = "running"
+	SweepStatusFinished = "finished"
+)
+
+// Trial statuses
+const (
+	TrialStatusRunning   = "running"
+	TrialStatusCompleted = "completed"
+	TrialStatusFailed    = "failed"
+)
+
+// Parameter distributions
+const (
+	DistributionUniform    = "uniform"
+	DistributionLogUniform = "log_uniform"
+	DistributionIntUniform = "int_uniform"
+)
+
+// ParameterSpec describes the search range of one hyperparameter: either a
+// discrete list of values or a distribution between min and max
+type ParameterSpec struct {
+	Values       []interface{} `json:"values,omitempty"`
+	Distribution string        `json:"distribution,omitempty"`
+	Min          *float64      `json:"min,omitempty"`
+	Max          *float64      `json:"max,omitempty"`
+}
+
+type Sweep struct {
+	ID              uuid.UUID                `json:"id"`
+	Name            string                   `json:"name"`
+	ObjectiveMetric string                   `json:"objective_metric"`
+	Goal            string                   `json:"goal"`
+	Strategy        string                   `json:"strategy"`
+	SearchSpace     map[string]ParameterSpec `json:"search_space"`
+	MaxTrials       *int                     `json:"max_trials"`
+	Status          string                   `json:"status"`
+	TrialCount      int                      `json:"trial_count"`
+	CreatedAt       time.Time                `json:"created_at"`
+	UpdatedAt       time.Time                `json:"updated_at"`
+}
+
+type SweepTrial struct {
+	ID             uuid.UUID              `json:"id"`
+	SweepID        uuid.UUID              `json:"sweep_id"`
+	TrialNumber    int                    `json:"trial_number"`
+	RunID          *uuid.UUID             `json:"run_id"`
+	Config         map[string]interface{} `json:"config"`
+	Status         string                 `json:"status"`
+	ObjectiveValue *float64               `json:"objective_value"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+type CreateSweepRequest struct {
+	Name            string                   `json:"name" binding:"required,max=255"`
+	ObjectiveMetric string                   `json:"objective_metric" binding:"required,max=255"`
+	Goal            string                   `json:"goal" binding:"required,oneof=min max"`
+	Strategy        string                   `json:"strategy" binding:"required,oneof=grid random bayes"`
+	SearchSpace     map[string]ParameterSpec `json:"search_space" binding:"required,min=1"`
+	MaxTrials       *int                     `json:"max_trials" binding:"omitempty,min=1"`
+}
+
+type NextTrialRequest struct {
+	RunID *uuid.UUID `json:"run_id"`
+}
+
+type ReportTrialRequest struct {
+	RunID          *uuid.UUID `json:"run_id"`
+	Status         string     `json:"status" binding:"required,oneof=completed failed"`
+	ObjectiveValue *float64   `json:"objective_value"`
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of table metrics
+const (
+	TableKindTable           = "table"
+	TableKindConfusionMatrix = "confusion_matrix"
+)
+
+// TableMetric is a small structured object logged at one step, for values
+// scalars cannot express. A table has named Columns and Rows of string,
+// number, boolean or null cells. A confusion matrix has class Labels and a
+// square Matrix of counts indexed [actual][predicted]; reads add its Summary.
+type TableMetric struct {
+	Time       time.Time              `json:"time"`
+	RunID      uuid.UUID              `json:"run_id"`
+	MetricName string                 `json:"metric_name"`
+	Step       *int64                 `json:"step"`
+	Kind       string                 `json:"kind"`
+	Columns    []string               `json:"columns,omitempty"`
+	Rows       [][]interface{}        `json:"rows,omitempty"`
+	Labels     []string               `json:"labels,omitempty"`
+	Matrix     [][]float64            `json:"matrix,omitempty"`
+	Summary    *ConfusionSummary      `json:"summary,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type TableBatchRequest struct {
+	Tables []TableMetric `json:"tables" binding:"required,min=1,max=100"`
+}
+
+type TableQueryParams struct {
+	MinStep *int64 `form:"min_step"`
+	MaxStep *int64 `form:"max_step"`
+	Limit   int    `form:"limit" binding:"min=0,max=1000"`
+}
+
+// TableSeries describes one table metric logged in a run
+type TableSeries struct {
+	MetricName string    `json:"metric_name"`
+	Kind       string    `json:"kind"`
+	Count      int64     `json:"count"`
+	FirstStep  *int64    `json:"first_step"`
+	LastStep   *int64    `json:"last_step"`
+	LastTime   time.Time `json:"last_time"`
+}
+
+// ConfusionSummary holds the metrics derived from a confusion matrix
+type ConfusionSummary struct {
+	Total    float64        `json:"total"`
+	Accuracy *float64       `json:"accuracy"`
+	MacroF1  *float64       `json:"macro_f1"`
+	Classes  []ClassMetrics `json:"classes"`
+}
+
+// ClassMetrics are the one-vs-rest metrics of one class. Precision, recall
+// and F1 are null when undefined, e.g. precision of a never predicted class.
+type ClassMetrics struct {
+	Label     string   `json:"label"`
+	Support   float64  `json:"support"`
+	Predicted float64  `json:"predicted"`
+	Precision *float64 `json:"precision"`
+	Recall    *float64 `json:"recall"`
+	F1        *float64 `json:"f1"`
+}
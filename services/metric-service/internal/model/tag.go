@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RunTag is a key/value tag on a run. Plain labels are stored with an empty value.
+type RunTag struct {
+	RunID     uuid.UUID `json:"run_id"`
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagFilter matches runs carrying Key, and Value too when HasValue is set
+type TagFilter struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+type SetTagsRequest struct {
+	Tags   map[string]string `json:"tags"`
+	Labels []string          `json:"labels"`
+}
+
+type TaggedRun struct {
+	RunID uuid.UUID         `json:"run_id"`
+	Tags  map[string]string `json:"tags"`
+}
+
+type RunMetricStats struct {
+	RunID uuid.UUID `json:"run_id"`
+	MetricStats
+}
+
+type TaggedRunStats struct {
+	RunID uuid.UUID         `json:"run_id"`
+	Tags  map[string]string `json:"tags"`
+	Stats *MetricStats      `json:"stats"`
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// defaultLocation is the time zone of timestamps given without one
+var defaultLocation = time.UTC
+
+// SetDefaultTimezone sets the time zone of timestamps given without one. It
+// must be called before requests are served.
+func SetDefaultTimezone(loc *time.Location) {
+	defaultLocation = loc
+}
+
+// localLayouts are the layouts accepted besides RFC 3339 for times given
+// without a zone
+var localLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// epochMillisFrom is the smallest epoch timestamp taken as milliseconds
+// rather than seconds; as seconds it would fall past the year 5000
+const epochMillisFrom = 1e11
+
+// ParseTimestamp parses an RFC 3339 time, a time without a zone in the
+// default time zone, or Unix epoch seconds or milliseconds, possibly
+// fractional. Which unit an epoch is in is told from its magnitude.
+func ParseTimestamp(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, value, defaultLocation); err == nil {
+			return t, nil
+		}
+	}
+	if epoch, err := strconv.ParseFloat(value, 64); err == nil {
+		return epochTime(epoch)
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q: must be an RFC 3339 time or Unix epoch seconds or milliseconds", value)
+}
+
+func epochTime(epoch float64) (time.Time, error) {
+	if math.IsNaN(epoch) || math.IsInf(epoch, 0) {
+		return time.Time{}, fmt.Errorf("invalid timestamp %v", epoch)
+	}
+	perSecond := 1.0
+	if math.Abs(epoch) >= epochMillisFrom {
+		perSecond = 1000
+	}
+	// whole seconds first, so that milliseconds keep their precision
+	sec := math.Floor(epoch / perSecond)
+	nsec := math.Round((epoch - sec*perSecond) * (1e9 / perSecond))
+	return time.Unix(int64(sec), int64(nsec)).UTC(), nil
+}
+
+// unmarshalTimestamp decodes a JSON timestamp given as a string or as an
+// epoch number. A missing or null timestamp leaves the zero time.
+func unmarshalTimestamp(data json.RawMessage) (time.Time, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return time.Time{}, nil
+	}
+	if data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return time.Time{}, err
+		}
+		return ParseTimestamp(value)
+	}
+	epoch, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %s", data)
+	}
+	return epochTime(epoch)
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event types
+const (
+	WebhookRunFirstMetric   = "run.first_metric"         // a run's first metric was written
+	WebhookRunPoints        = "run.points"               // a run reached another multiple of N points
+	WebhookThresholdCrossed = "metric.threshold_crossed" // a metric first crossed a configured threshold
+)
+
+// WebhookEvent is the body of a webhook delivery. Data holds the fields of
+// the event type: metric_name and step for run.first_metric, points for
+// run.points, and metric_name, step, value and threshold for
+// metric.threshold_crossed.
+type WebhookEvent struct {
+	ID        uuid.UUID              `json:"id"`
+	Type      string                 `json:"type"`
+	Time      time.Time              `json:"time"`
+	RunID     uuid.UUID              `json:"run_id"`
+	ProjectID *uuid.UUID             `json:"project_id,omitempty"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// WebhookThreshold fires metric.threshold_crossed the first time a run logs
+// MetricName above (or below) Value
+type WebhookThreshold struct {
+	MetricName string
+	Above      bool
+	Value      float64
+}
+
+// String returns the threshold as it is configured, e.g. val/loss<0.5
+func (t WebhookThreshold) String() string {
+	op := "<"
+	if t.Above {
+		op = ">"
+	}
+	return t.MetricName + op + strconv.FormatFloat(t.Value, 'g', -1, 64)
+}
+
+// Crossed tells whether v is past the threshold
+func (t WebhookThreshold) Crossed(v float64) bool {
+	if t.Above {
+		return v > t.Value
+	}
+	return v < t.Value
+}
+
+// ParseWebhookThresholds parses thresholds written as metric>value or
+// metric<value
+func ParseWebhookThresholds(specs []string) ([]WebhookThreshold, error) {
+	thresholds := make([]WebhookThreshold, 0, len(specs))
+	for _, spec := range specs {
+		i := strings.LastIndexAny(spec, "<>")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid threshold %q: want metric>value or metric<value", spec)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(spec[i+1:]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q: %w", spec, err)
+		}
+		thresholds = append(thresholds, WebhookThreshold{
+			MetricName: strings.TrimSpace(spec[:i]),
+			Above:      spec[i] == '>',
+			Value:      value,
+		})
+	}
+	return thresholds, nil
+}
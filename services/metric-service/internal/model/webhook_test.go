@@ -0,0 +1,35 @@
+package model
+
+import "testing"
+
+func TestParseWebhookThresholds(t *testing.T) {
+	thresholds, err := ParseWebhookThresholds([]string{"val/loss<0.5", "accuracy > 0.9", "a<b>1e3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WebhookThreshold{
+		{MetricName: "val/loss", Above: false, Value: 0.5},
+		{MetricName: "accuracy", Above: true, Value: 0.9},
+		{MetricName: "a<b", Above: true, Value: 1000},
+	}
+	if len(thresholds) != len(want) {
+		t.Fatalf("ParseWebhookThresholds() = %v, want %v", thresholds, want)
+	}
+	for i := range want {
+		if thresholds[i] != want[i] {
+			t.Errorf("threshold %d = %+v, want %+v", i, thresholds[i], want[i])
+		}
+	}
+	if got := thresholds[0].String(); got != "val/loss<0.5" {
+		t.Errorf("String() = %q, want val/loss<0.5", got)
+	}
+	if !thresholds[0].Crossed(0.4) || thresholds[0].Crossed(0.5) || !thresholds[1].Crossed(0.95) {
+		t.Error("Crossed() disagrees with the thresholds")
+	}
+
+	for _, spec := range []string{"loss", "<0.5", "loss<", "loss>abc"} {
+		if _, err := ParseWebhookThresholds([]string{spec}); err == nil {
+			t.Errorf("ParseWebhookThresholds(%q) returned no error", spec)
+		}
+	}
+}
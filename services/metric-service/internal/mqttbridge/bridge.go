@@ -0,0 +1,261 @@
+// Package mqttbridge ingests metrics that edge devices and embedded trainers
+// publish to an MQTT broker, for environments where HTTP batch posts are too
+// heavy
+package mqttbridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+const (
+	maxRetryDelay = 30 * time.Second
+	// disconnectQuiesce is how long, in milliseconds, pending work may take
+	// on disconnect
+	disconnectQuiesce uint = 250
+)
+
+// MetricWriter persists ingested batches; the metric service is one
+type MetricWriter interface {
+	BatchWrite(ctx context.Context, metrics []model.Metric) error
+}
+
+// Options configure a Bridge. Metrics are read from topics under
+// TopicPrefix, <prefix>/<run_id>/<metric_name>, where the metric name may
+// span levels, e.g. edge/metrics/<run_id>/train/loss. SharedGroup, when set,
+// subscribes through the shared subscription $share/<group>/, so that the
+// replicas of the service split the messages rather than each ingesting all.
+type Options struct {
+	BrokerURL   string
+	ClientID    string
+	Username    string
+	Password    string
+	TopicPrefix string
+	SharedGroup string
+	QoS         byte
+	// Messages are written in batches of up to BatchSize, at least every
+	// FlushInterval
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// Bridge subscribes to metric topics and writes what devices publish.
+// Messages are acknowledged once their batch is written, and the session
+// outlives disconnects, so the broker redelivers what a crash left unwritten.
+type Bridge struct {
+	writer MetricWriter
+	opts   Options
+	logger *zap.Logger
+	in     chan delivery
+	done   chan struct{}
+}
+
+// delivery is a parsed message awaiting its write
+type delivery struct {
+	metrics []model.Metric
+	msg     mqtt.Message
+}
+
+func New(writer MetricWriter, opts Options, logger *zap.Logger) *Bridge {
+	opts.TopicPrefix = strings.TrimSuffix(opts.TopicPrefix, "/")
+	return &Bridge{
+		writer: writer,
+		opts:   opts,
+		logger: logger,
+		in:     make(chan delivery, opts.BatchSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Filter is the topic filter the bridge subscribes to
+func (b *Bridge) Filter() string {
+	filter := b.opts.TopicPrefix + "/+/#"
+	if b.opts.SharedGroup != "" {
+		filter = "$share/" + b.opts.SharedGroup + "/" + filter
+	}
+	return filter
+}
+
+// Run connects to the broker and ingests messages until the context is
+// cancelled. Connections are retried in the background; the subscription is
+// renewed on every connect.
+func (b *Bridge) Run(ctx context.Context) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(b.opts.BrokerURL).
+		SetClientID(b.opts.ClientID).
+		SetUsername(b.opts.Username).
+		SetPassword(b.opts.Password).
+		SetCleanSession(false).
+		SetAutoAckDisabled(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(func(client mqtt.Client) {
+			token := client.Subscribe(b.Filter(), b.opts.QoS, b.handle)
+			go func() {
+				if token.Wait() && token.Error() != nil {
+					b.logger.Error("Failed to subscribe to MQTT metric topics", zap.String("filter", b.Filter()), zap.Error(token.Error()))
+					return
+				}
+				b.logger.Info("Subscribed to MQTT metric topics", zap.String("filter", b.Filter()))
+			}()
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			b.logger.Warn("Lost connection to MQTT broker", zap.Error(err))
+		})
+	client := mqtt.NewClient(opts)
+	client.Connect()
+
+	b.batch(ctx)
+	close(b.done)
+	client.Disconnect(disconnectQuiesce)
+}
+
+// handle parses a message and hands it to the batching loop, which blocks
+// the client while batches are written. Invalid messages are handed on
+// without metrics, to be acknowledged in order with the others.
+func (b *Bridge) handle(_ mqtt.Client, msg mqtt.Message) {
+	metrics, err := parseMessage(b.opts.TopicPrefix, msg.Topic(), msg.Payload())
+	if err != nil {
+		b.logger.Warn("Dropping invalid MQTT metric message", zap.String("topic", msg.Topic()), zap.Error(err))
+	}
+	select {
+	case b.in <- delivery{metrics: metrics, msg: msg}:
+	case <-b.done:
+	}
+}
+
+func (b *Bridge) batch(ctx context.Context) {
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+	var pending []delivery
+	count := 0
+	flush := func() {
+		if len(pending) > 0 {
+			b.flush(ctx, pending)
+			pending, count = nil, 0
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			// Unwritten messages stay unacknowledged and are redelivered
+			return
+		case d := <-b.in:
+			pending = append(pending, d)
+			if count += len(d.metrics); count >= b.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// flush writes the metrics of deliveries and acknowledges them. Invalid
+// metrics are dropped, and failed writes retried until they pass or the
+// context is cancelled.
+func (b *Bridge) flush(ctx context.Context, deliveries []delivery) {
+	var metrics []model.Metric
+	for _, d := range deliveries {
+		metrics = append(metrics, d.metrics...)
+	}
+
+	delay := time.Second
+	for len(metrics) > 0 {
+		err := b.writer.BatchWrite(ctx, metrics)
+		if err == nil {
+			break
+		}
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) && len(validationErr.Items) > 0 {
+			b.logger.Warn("Dropping invalid MQTT metrics", zap.Int("count", len(validationErr.Items)), zap.Error(err))
+			n := len(metrics)
+			if metrics = withoutItems(metrics, validationErr.Items); len(metrics) == n {
+				break
+			}
+			continue
+		}
+		b.logger.Error("Failed to write MQTT metrics, retrying", zap.Int("count", len(metrics)), zap.Duration("delay", delay), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+
+	for _, d := range deliveries {
+		d.msg.Ack()
+	}
+}
+
+// withoutItems returns metrics without the invalid items of a batch
+func withoutItems(metrics []model.Metric, items []service.ItemError) []model.Metric {
+	invalid := make(map[int]bool, len(items))
+	for _, item := range items {
+		invalid[item.Index] = true
+	}
+	valid := metrics[:0]
+	for i, m := range metrics {
+		if !invalid[i] {
+			valid = append(valid, m)
+		}
+	}
+	return valid
+}
+
+// parseMessage reads the metrics of a message on topic. A message on
+// <prefix>/<run_id>/<metric_name> holds one value: a bare number, or a
+// metric object as the HTTP API takes it, whose run and name the topic sets.
+// A message on <prefix>/<run_id> holds a JSON array of metric objects, each
+// naming its metric.
+func parseMessage(prefix, topic string, payload []byte) ([]model.Metric, error) {
+	rest, ok := strings.CutPrefix(topic, prefix+"/")
+	if !ok {
+		return nil, fmt.Errorf("topic is not under %s", prefix)
+	}
+	runPart, metricName, _ := strings.Cut(rest, "/")
+	runID, err := uuid.Parse(runPart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid run ID %q in topic", runPart)
+	}
+	payload = bytes.TrimSpace(payload)
+
+	if metricName == "" {
+		var metrics []model.Metric
+		if err := json.Unmarshal(payload, &metrics); err != nil {
+			return nil, fmt.Errorf("run topics take a JSON array of metrics: %w", err)
+		}
+		for i := range metrics {
+			metrics[i].RunID = runID
+		}
+		return metrics, nil
+	}
+
+	m := model.Metric{RunID: runID, MetricName: metricName}
+	if len(payload) > 0 && payload[0] == '{' {
+		if err := json.Unmarshal(payload, &m); err != nil {
+			return nil, fmt.Errorf("invalid metric: %w", err)
+		}
+		m.RunID, m.MetricName = runID, metricName
+		return []model.Metric{m}, nil
+	}
+	value, err := strconv.ParseFloat(string(payload), 64)
+	if err != nil {
+		return nil, fmt.Errorf("payload is neither a number nor a JSON metric")
+	}
+	m.Value = value
+	return []model.Metric{m}, nil
+}
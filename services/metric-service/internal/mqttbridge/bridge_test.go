@@ -0,0 +1,140 @@
+package mqttbridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/service"
+)
+
+const runID = "123e4567-e89b-12d3-a456-426614174000"
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		topic     string
+		payload   string
+		wantNames []string
+		wantValue float64
+		wantStep  int64
+		wantErr   bool
+	}{
+		{name: "bare number", topic: "edge/" + runID + "/loss", payload: " 0.25\n", wantNames: []string{"loss"}, wantValue: 0.25},
+		{name: "nested name", topic: "edge/" + runID + "/train/loss", payload: "1e-3", wantNames: []string{"train/loss"}, wantValue: 0.001},
+		{
+			name:      "metric object",
+			topic:     "edge/" + runID + "/loss",
+			payload:   `{"value": 2, "step": 7, "metric_name": "ignored", "run_id": "00000000-0000-0000-0000-000000000001"}`,
+			wantNames: []string{"loss"}, wantValue: 2, wantStep: 7,
+		},
+		{
+			name:      "run batch",
+			topic:     "edge/" + runID,
+			payload:   `[{"metric_name": "loss", "value": 1, "step": 3}, {"metric_name": "acc", "value": 0.5}]`,
+			wantNames: []string{"loss", "acc"}, wantValue: 1, wantStep: 3,
+		},
+		{name: "invalid run", topic: "edge/run-1/loss", payload: "1", wantErr: true},
+		{name: "not a number", topic: "edge/" + runID + "/loss", payload: "high", wantErr: true},
+		{name: "run batch not an array", topic: "edge/" + runID, payload: `{"value": 1}`, wantErr: true},
+		{name: "other prefix", topic: "other/" + runID + "/loss", payload: "1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics, err := parseMessage("edge", tt.topic, []byte(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMessage() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(metrics) != len(tt.wantNames) {
+				t.Fatalf("%d metrics, want %d", len(metrics), len(tt.wantNames))
+			}
+			for i, m := range metrics {
+				if m.RunID.String() != runID || m.MetricName != tt.wantNames[i] {
+					t.Errorf("metric %d is %s of %s, want %s of %s", i, m.MetricName, m.RunID, tt.wantNames[i], runID)
+				}
+			}
+			if metrics[0].Value != tt.wantValue {
+				t.Errorf("value = %v, want %v", metrics[0].Value, tt.wantValue)
+			}
+			if tt.wantStep != 0 && (metrics[0].Step == nil || *metrics[0].Step != tt.wantStep) {
+				t.Errorf("step = %v, want %d", metrics[0].Step, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	if got, want := New(nil, Options{TopicPrefix: "edge/"}, zap.NewNop()).Filter(), "edge/+/#"; got != want {
+		t.Errorf("Filter() = %q, want %q", got, want)
+	}
+	if got, want := New(nil, Options{TopicPrefix: "edge", SharedGroup: "svc"}, zap.NewNop()).Filter(), "$share/svc/edge/+/#"; got != want {
+		t.Errorf("Filter() = %q, want %q", got, want)
+	}
+}
+
+// fakeMessage records its acknowledgement
+type fakeMessage struct {
+	acked bool
+}
+
+func (m *fakeMessage) Duplicate() bool   { return false }
+func (m *fakeMessage) Qos() byte         { return 1 }
+func (m *fakeMessage) Retained() bool    { return false }
+func (m *fakeMessage) Topic() string     { return "" }
+func (m *fakeMessage) MessageID() uint16 { return 0 }
+func (m *fakeMessage) Payload() []byte   { return nil }
+func (m *fakeMessage) Ack()              { m.acked = true }
+
+// rejectingWriter rejects the named metrics of a batch, as the metric
+// service's validation does, and records the batches it accepts
+type rejectingWriter struct {
+	invalid string
+	written [][]model.Metric
+}
+
+func (w *rejectingWriter) BatchWrite(_ context.Context, metrics []model.Metric) error {
+	var items []service.ItemError
+	for i, m := range metrics {
+		if m.MetricName == w.invalid {
+			items = append(items, service.ItemError{Index: i, Reason: "invalid"})
+		}
+	}
+	if err := service.NewBatchValidationError("metric", items); err != nil {
+		return err
+	}
+	w.written = append(w.written, metrics)
+	return nil
+}
+
+func TestFlushDropsInvalidMetrics(t *testing.T) {
+	writer := &rejectingWriter{invalid: "bad"}
+	b := New(writer, Options{TopicPrefix: "edge", BatchSize: 10}, zap.NewNop())
+	run := uuid.MustParse(runID)
+	msgs := []*fakeMessage{{}, {}, {}}
+	b.flush(context.Background(), []delivery{
+		{metrics: []model.Metric{{RunID: run, MetricName: "loss"}, {RunID: run, MetricName: "bad"}}, msg: msgs[0]},
+		// An unparseable message carries no metrics but is acknowledged in turn
+		{msg: msgs[1]},
+		{metrics: []model.Metric{{RunID: run, MetricName: "acc"}}, msg: msgs[2]},
+	})
+
+	if len(writer.written) != 1 || len(writer.written[0]) != 2 {
+		t.Fatalf("written %v, want one batch of loss and acc", writer.written)
+	}
+	for i, m := range writer.written[0] {
+		if want := []string{"loss", "acc"}[i]; m.MetricName != want {
+			t.Errorf("metric %d = %s, want %s", i, m.MetricName, want)
+		}
+	}
+	for i, m := range msgs {
+		if !m.acked {
+			t.Errorf("message %d not acknowledged", i)
+		}
+	}
+}
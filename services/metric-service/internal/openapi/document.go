@@ -0,0 +1,261 @@
+// Package openapi generates the OpenAPI 3 document of the HTTP API from the
+// registered gin routes and the model types their handlers bind and return,
+// and validates requests against it.
+package openapi
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	prefix     string
+	mu         sync.RWMutex
+	operations map[string]*Operation // by method and gin path without prefix
+	schemas    *schemaGenerator
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem holds the operations of a path by lower-case method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Route describes the models of one route. Query and Form are structs bound
+// with form tags, Body one bound from JSON and Response the value answered,
+// any of which may be left out. Responses built as maps are described with
+// Fields.
+type Route struct {
+	Summary      string
+	Query        interface{}
+	Body         interface{}
+	OptionalBody bool // the body may be left empty
+	Form         interface{}
+	File         string // multipart field of an uploaded file
+	Status       int    // of a successful response; 200 when unset
+	Response     interface{}
+}
+
+// Fields describes a JSON object by example: each field's schema is that of
+// the type of its value
+type Fields map[string]interface{}
+
+// errorResponse is the body of every error response of the v1 API
+var errorResponse = &Response{
+	Description: "Error",
+	Content: map[string]MediaType{"application/json": {Schema: &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"error": {Type: "string"}},
+	}}},
+}
+
+// New creates an empty document for the routes registered under prefix
+func New(title, version, prefix string) *Document {
+	return &Document{
+		OpenAPI:    "3.0.3",
+		Info:       Info{Title: title, Version: version},
+		Servers:    []Server{{URL: prefix}},
+		Paths:      make(map[string]PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+		prefix:     prefix,
+		operations: make(map[string]*Operation),
+		schemas:    newSchemaGenerator(),
+	}
+}
+
+// AddRoutes documents the routes registered under the document's prefix,
+// describing them from described, keyed by method and path without the
+// prefix, e.g. "GET /runs/:run_id/metrics". Routes without a description get
+// their path parameters only. It returns the described routes not found, so
+// that stale descriptions can be reported.
+func (d *Document) AddRoutes(routes gin.RoutesInfo, described map[string]Route) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	found := make(map[string]bool)
+	for _, r := range routes {
+		path, ok := strings.CutPrefix(r.Path, d.prefix)
+		if !ok || !strings.HasPrefix(path, "/") {
+			continue
+		}
+		key := r.Method + " " + path
+		desc, ok := described[key]
+		found[key] = ok
+
+		op := d.operation(r, path, desc)
+		d.operations[key] = op
+		openPath := openAPIPath(path)
+		if d.Paths[openPath] == nil {
+			d.Paths[openPath] = make(PathItem)
+		}
+		d.Paths[openPath][strings.ToLower(r.Method)] = op
+	}
+	d.Components.Schemas = d.schemas.components
+
+	var missing []string
+	for key := range described {
+		if !found[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func (d *Document) operation(r gin.RouteInfo, path string, desc Route) *Operation {
+	handlerType, method := handlerName(r.Handler)
+	op := &Operation{
+		OperationID: operationID(handlerType, method),
+		Summary:     desc.Summary,
+		Responses:   map[string]*Response{"default": errorResponse},
+	}
+	if handlerType != "" {
+		op.Tags = []string{strings.ToLower(handlerType[:1]) + handlerType[1:]}
+	}
+
+	for _, segment := range strings.Split(path, "/") {
+		if len(segment) > 1 && (segment[0] == ':' || segment[0] == '*') {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:     segment[1:],
+				In:       "path",
+				Required: true,
+				Schema:   pathParamSchema(segment[1:]),
+			})
+		}
+	}
+	if desc.Query != nil {
+		op.Parameters = append(op.Parameters, d.schemas.queryParameters(reflect.TypeOf(desc.Query))...)
+	}
+
+	switch {
+	case desc.Body != nil:
+		op.RequestBody = &RequestBody{Required: !desc.OptionalBody, Content: map[string]MediaType{
+			"application/json": {Schema: d.schemas.describe(desc.Body)},
+		}}
+	case desc.Form != nil:
+		form := d.schemas.formSchema(reflect.TypeOf(desc.Form))
+		if desc.File != "" {
+			form.Properties[desc.File] = &Schema{Type: "string", Format: "binary"}
+			form.Required = append(form.Required, desc.File)
+		}
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"multipart/form-data": {Schema: form},
+		}}
+	}
+
+	status := desc.Status
+	if status == 0 {
+		status = 200
+	}
+	response := &Response{Description: "Success"}
+	if desc.Response != nil {
+		response.Content = map[string]MediaType{
+			"application/json": {Schema: d.schemas.describe(desc.Response)},
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = response
+	return op
+}
+
+// lookup returns the operation of a route, by method and gin path without
+// the version prefix
+func (d *Document) lookup(method, path string) *Operation {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.operations[method+" "+path]
+}
+
+// openAPIPath turns the parameters of a gin path into OpenAPI templates
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if len(segment) > 1 && (segment[0] == ':' || segment[0] == '*') {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathParamSchema gives IDs the UUID format; other parameters are names
+func pathParamSchema(name string) *Schema {
+	if strings.HasSuffix(name, "_id") {
+		return &Schema{Type: "string", Format: "uuid"}
+	}
+	return &Schema{Type: "string"}
+}
+
+// handlerName splits the name gin reports for a method value handler, such
+// as ".../handler.(*MetricHandler).GetRunMetrics-fm", into the handler type
+// without its Handler suffix and the method
+func handlerName(name string) (handlerType, method string) {
+	name = strings.TrimSuffix(name, "-fm")
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return "", name
+	}
+	method = name[i+1:]
+	receiver := name[:i]
+	if j := strings.LastIndex(receiver, "(*"); j >= 0 {
+		handlerType = strings.TrimSuffix(strings.TrimSuffix(receiver[j+2:], ")"), "Handler")
+	}
+	return handlerType, method
+}
+
+func operationID(handlerType, method string) string {
+	if handlerType == "" {
+		return method
+	}
+	return strings.ToLower(handlerType[:1]) + handlerType[1:] + method
+}
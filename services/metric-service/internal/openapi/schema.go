@@ -0,0 +1,324 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is the subset of the OpenAPI schema object the models need
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+
+	// omitEmpty skips the constraints for zero values, as omitempty does
+	omitEmpty bool
+}
+
+const refPrefix = "#/components/schemas/"
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	uuidType     = reflect.TypeOf(uuid.UUID{})
+	numberType   = reflect.TypeOf(json.Number(""))
+)
+
+// schemaGenerator derives schemas from Go types, the way encoding/json
+// marshals them and gin binds them. Named structs become components
+// referenced by name.
+type schemaGenerator struct {
+	components map[string]*Schema
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{components: make(map[string]*Schema)}
+}
+
+// describe returns the schema of the values of the type of v, describing
+// Fields field by field rather than as maps
+func (g *schemaGenerator) describe(v interface{}) *Schema {
+	switch v := v.(type) {
+	case nil:
+		return &Schema{}
+	case Fields:
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema, len(v))}
+		for name, value := range v {
+			s.Properties[name] = g.describe(value)
+		}
+		return s
+	case []Fields:
+		item := &Schema{}
+		if len(v) > 0 {
+			item = g.describe(v[0])
+		}
+		return &Schema{Type: "array", Items: item}
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case numberType:
+		return &Schema{Type: "number"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.schema(t.Elem())
+		if s.Ref != "" {
+			// siblings of $ref are ignored in OpenAPI 3.0
+			return s
+		}
+		s.Nullable = true
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		// nil slices and maps marshal to null
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte", Nullable: true}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem()), Nullable: true}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := g.components[name]; !ok {
+			// registered before generating the fields, so that recursive
+			// types refer to themselves
+			g.components[name] = &Schema{}
+			*g.components[name] = *g.structSchema(t)
+		}
+		return &Schema{Ref: refPrefix + name}
+	}
+	// interface{} holds any value
+	return &Schema{}
+}
+
+// structSchema returns the schema of the JSON object of a struct, with the
+// fields of embedded structs inlined
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t, "json")
+	return s
+}
+
+func (g *schemaGenerator) addFields(s *Schema, t reflect.Type, tagKey string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get(tagKey) == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(s, embedded, tagKey)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name := fieldName(f, tagKey)
+		if name == "" {
+			continue
+		}
+		var fs *Schema
+		switch {
+		case f.Tag.Get("openapi") == "any":
+			// read by a custom unmarshaler from more than one JSON type
+			fs = &Schema{}
+		case tagKey == "form":
+			fs = g.paramSchema(f.Type)
+		default:
+			fs = g.schema(f.Type)
+		}
+		if applyBinding(fs, f.Type, f.Tag.Get("binding")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// fieldName returns the name of a field under a json or form tag, or ""
+// for fields left out
+func fieldName(f reflect.StructField, tagKey string) string {
+	tag := f.Tag.Get(tagKey)
+	if tag == "-" {
+		return ""
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		if tagKey == "form" {
+			return ""
+		}
+		return f.Name
+	}
+	return name
+}
+
+// paramSchema returns the schema of a query or form value bound into t;
+// durations are given as strings such as 15m
+func (g *schemaGenerator) paramSchema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		return g.paramSchema(t.Elem())
+	}
+	if t == durationType {
+		return &Schema{Type: "string", Format: "duration"}
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		return &Schema{Type: "array", Items: g.paramSchema(t.Elem())}
+	}
+	return g.schema(t)
+}
+
+// queryParameters returns the query parameters bound into the struct t
+func (g *schemaGenerator) queryParameters(t reflect.Type) []*Parameter {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s := &Schema{Properties: make(map[string]*Schema)}
+	g.addFields(s, t, "form")
+
+	required := make(map[string]bool, len(s.Required))
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	var params []*Parameter
+	g.eachField(t, "form", func(name string) {
+		params = append(params, &Parameter{
+			Name:     name,
+			In:       "query",
+			Required: required[name],
+			Schema:   s.Properties[name],
+		})
+	})
+	return params
+}
+
+// formSchema returns the schema of the multipart form bound into the struct t
+func (g *schemaGenerator) formSchema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t, "form")
+	return s
+}
+
+// eachField calls fn with the names of the fields of t in declaration order,
+// so that parameters are listed the way the model declares them
+func (g *schemaGenerator) eachField(t reflect.Type, tagKey string, fn func(name string)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get(tagKey) == "" && f.Type.Kind() == reflect.Struct {
+			g.eachField(f.Type, tagKey, fn)
+			continue
+		}
+		if name := fieldName(f, tagKey); f.IsExported() && name != "" {
+			fn(name)
+		}
+	}
+}
+
+// applyBinding adds the constraints of a gin binding tag to s, returning
+// whether the field is required. Constraints after dive apply to elements
+// and are left out.
+func applyBinding(s *Schema, t reflect.Type, tag string) (required bool) {
+	if tag == "" {
+		return false
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "omitempty":
+			s.omitEmpty = true
+		case "oneof":
+			s.Enum = strings.Fields(value)
+		case "min", "max", "gte", "lte":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			lower := key == "min" || key == "gte"
+			setBound(s, t, n, lower)
+		}
+	}
+	return required
+}
+
+// setBound sets the lower or upper bound n of s, which bounds lengths for
+// strings, slices and maps and values otherwise, as the validator does
+func setBound(s *Schema, t reflect.Type, n float64, lower bool) {
+	count := int(n)
+	switch t.Kind() {
+	case reflect.String:
+		if lower {
+			s.MinLength = &count
+		} else {
+			s.MaxLength = &count
+		}
+	case reflect.Slice, reflect.Array:
+		if lower {
+			s.MinItems = &count
+		} else {
+			s.MaxItems = &count
+		}
+	case reflect.Map:
+		if lower {
+			s.MinProperties = &count
+		} else {
+			s.MaxProperties = &count
+		}
+	default:
+		if lower {
+			s.Minimum = &n
+		} else {
+			s.Maximum = &n
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Validate checks a request to a route, given by method and gin path without
+// the version prefix, against the document: its path parameters, its query
+// parameters and its JSON body. Requests to routes not in the document and
+// bodies of other media types pass. The body is read and put back for the
+// handler.
+func (d *Document) Validate(method, path string, r *http.Request, params gin.Params) error {
+	op := d.lookup(method, path)
+	if op == nil {
+		return nil
+	}
+
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			value, _ := params.Get(p.Name)
+			if err := checkParam(value, p.Schema); err != nil {
+				return fmt.Errorf("path parameter %q: %w", p.Name, err)
+			}
+		case "query":
+			if err := checkQuery(query[p.Name], p); err != nil {
+				return fmt.Errorf("query parameter %q: %w", p.Name, err)
+			}
+		}
+	}
+
+	if op.RequestBody == nil {
+		return nil
+	}
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return nil
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "application/json" {
+			return nil
+		}
+	}
+	if r.Body == nil || r.ContentLength == 0 {
+		if op.RequestBody.Required {
+			return fmt.Errorf("request body is required")
+		}
+		return nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 && !op.RequestBody.Required {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return d.checkValue("body", value, media.Schema)
+}
+
+// checkQuery checks the values of a query parameter. Empty values bind to
+// zero values and pass, like missing ones.
+func checkQuery(values []string, p *Parameter) error {
+	if len(values) == 0 || (len(values) == 1 && values[0] == "") {
+		if p.Required {
+			return fmt.Errorf("is required")
+		}
+		return nil
+	}
+	if p.Schema.Type == "array" {
+		for _, value := range values {
+			if err := checkParam(value, p.Schema.Items); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// gin binds the first value
+	return checkParam(values[0], p.Schema)
+}
+
+// checkParam checks a path or query value, parsed as gin binds it
+func checkParam(value string, s *Schema) error {
+	if value == "" && s.omitEmpty {
+		return nil
+	}
+	switch s.Type {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		return checkBounds(float64(n), s)
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		return checkBounds(n, s)
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("must be a boolean")
+		}
+		return nil
+	case "string":
+		return checkString(value, s)
+	}
+	return nil
+}
+
+// checkValue checks a decoded JSON value at path against s
+func (d *Document) checkValue(path string, value interface{}, s *Schema) error {
+	if s.Ref != "" {
+		resolved, ok := d.Components.Schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+		if !ok {
+			return nil
+		}
+		s = resolved
+	}
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s: must not be null", path)
+	}
+	if s.omitEmpty && isEmpty(value) {
+		return nil
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: must be an object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s.%s: is required", path, name)
+			}
+		}
+		if s.MinProperties != nil && len(object) < *s.MinProperties {
+			return fmt.Errorf("%s: must have at least %d entries", path, *s.MinProperties)
+		}
+		if s.MaxProperties != nil && len(object) > *s.MaxProperties {
+			return fmt.Errorf("%s: must have at most %d entries", path, *s.MaxProperties)
+		}
+		for name, v := range object {
+			fs, ok := s.Properties[name]
+			if !ok {
+				// unknown fields are ignored when binding
+				if fs = s.AdditionalProperties; fs == nil {
+					continue
+				}
+			}
+			if err := d.checkValue(path+"."+name, v, fs); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: must be an array", path)
+		}
+		if s.MinItems != nil && len(array) < *s.MinItems {
+			return fmt.Errorf("%s: must have at least %d items", path, *s.MinItems)
+		}
+		if s.MaxItems != nil && len(array) > *s.MaxItems {
+			return fmt.Errorf("%s: must have at most %d items", path, *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, v := range array {
+				if err := d.checkValue(fmt.Sprintf("%s[%d]", path, i), v, s.Items); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string", path)
+		}
+		if err := checkString(str, s); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: must be an integer", path)
+		}
+		i, err := strconv.ParseInt(n.String(), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: must be an integer", path)
+		}
+		if err := checkBounds(float64(i), s); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case "number":
+		n, ok := value.(json.Number)
+		if s, isString := value.(string); isString && nonFinite[strings.ToLower(s)] {
+			// the service reads NaN and the infinities from strings
+			return nil
+		}
+		if !ok {
+			return fmt.Errorf("%s: must be a number", path)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fmt.Errorf("%s: must be a number", path)
+		}
+		if err := checkBounds(f, s); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: must be a boolean", path)
+		}
+	}
+	return nil
+}
+
+// nonFinite are the strings taken for NaN and the infinities in place of numbers
+var nonFinite = map[string]bool{
+	"nan": true, "inf": true, "+inf": true, "-inf": true, "infinity": true, "+infinity": true, "-infinity": true,
+}
+
+// checkString checks a string against the enum, lengths and format of s
+func checkString(value string, s *Schema) error {
+	if value == "" && s.omitEmpty {
+		return nil
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if value == allowed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("must be one of %s", strings.Join(s.Enum, ", "))
+		}
+	}
+	length := utf8.RuneCountInString(value)
+	if s.MinLength != nil && length < *s.MinLength {
+		return fmt.Errorf("must be at least %d characters", *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		return fmt.Errorf("must be at most %d characters", *s.MaxLength)
+	}
+
+	switch s.Format {
+	case "uuid":
+		if _, err := uuid.Parse(value); err != nil {
+			return fmt.Errorf("must be a UUID")
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Errorf("must be an RFC 3339 time")
+		}
+	case "duration":
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("must be a duration such as 15m")
+		}
+	case "byte":
+		if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+			return fmt.Errorf("must be base64 encoded")
+		}
+	}
+	return nil
+}
+
+func checkBounds(n float64, s *Schema) error {
+	if n == 0 && s.omitEmpty {
+		return nil
+	}
+	if s.Minimum != nil && n < *s.Minimum {
+		return fmt.Errorf("must be at least %v", *s.Minimum)
+	}
+	if s.Maximum != nil && n > *s.Maximum {
+		return fmt.Errorf("must be at most %v", *s.Maximum)
+	}
+	return nil
+}
+
+// isEmpty reports whether a decoded JSON value binds to a zero value
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return v == ""
+	case json.Number:
+		f, err := v.Float64()
+		return err == nil && f == 0
+	case bool:
+		return !v
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
@@ -0,0 +1,404 @@
+// Package parquet holds a minimal Parquet writer for metric exports: a fixed
+// flat schema, PLAIN encoded values, RLE definition levels and no
+// compression, which every Parquet reader accepts.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// Parquet physical types, repetitions, converted types and encodings
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMicros = 10
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// parquetRowGroupSize bounds how many rows are buffered before a row group is written
+const parquetRowGroupSize = 100000
+
+var parquetMagic = []byte("PAR1")
+
+type parquetColumn struct {
+	name       string
+	physical   int32
+	converted  int32 // -1 for none
+	repetition int32
+
+	values bytes.Buffer // PLAIN encoded, nulls omitted
+	levels []byte       // definition levels, optional columns only
+	count  int
+}
+
+func (c *parquetColumn) appendNull() {
+	c.levels = append(c.levels, 0)
+	c.count++
+}
+
+func (c *parquetColumn) present() {
+	if c.repetition == parquetOptional {
+		c.levels = append(c.levels, 1)
+	}
+	c.count++
+}
+
+func (c *parquetColumn) appendInt64(v int64) {
+	c.present()
+	binary.Write(&c.values, binary.LittleEndian, v)
+}
+
+func (c *parquetColumn) appendInt32(v int32) {
+	c.present()
+	binary.Write(&c.values, binary.LittleEndian, v)
+}
+
+func (c *parquetColumn) appendDouble(v float64) {
+	c.present()
+	binary.Write(&c.values, binary.LittleEndian, math.Float64bits(v))
+}
+
+func (c *parquetColumn) appendString(v string) {
+	c.present()
+	binary.Write(&c.values, binary.LittleEndian, uint32(len(v)))
+	c.values.WriteString(v)
+}
+
+func (c *parquetColumn) reset() {
+	c.values.Reset()
+	c.levels = c.levels[:0]
+	c.count = 0
+}
+
+// columnChunk records where a written column chunk lives for the footer
+type columnChunk struct {
+	column *parquetColumn
+	offset int64
+	size   int64
+	values int64
+}
+
+type rowGroup struct {
+	chunks []columnChunk
+	size   int64
+	rows   int64
+}
+
+// Writer writes metrics with the columns time, run_id, metric_name, step,
+// value, node_id, rank and text_value. String and bool values are in
+// text_value, with a null value.
+type Writer struct {
+	w      io.Writer
+	offset int64
+	err    error
+
+	columns []*parquetColumn
+	rows    int64
+	groups  []rowGroup
+}
+
+func NewWriter(w io.Writer) *Writer {
+	pw := &Writer{
+		w: w,
+		columns: []*parquetColumn{
+			{name: "time", physical: parquetInt64, converted: parquetTimestampMicros, repetition: parquetRequired},
+			{name: "run_id", physical: parquetByteArray, converted: parquetUTF8, repetition: parquetRequired},
+			{name: "metric_name", physical: parquetByteArray, converted: parquetUTF8, repetition: parquetRequired},
+			{name: "step", physical: parquetInt64, converted: -1, repetition: parquetOptional},
+			{name: "value", physical: parquetDouble, converted: -1, repetition: parquetOptional},
+			{name: "node_id", physical: parquetByteArray, converted: parquetUTF8, repetition: parquetRequired},
+			{name: "rank", physical: parquetInt32, converted: -1, repetition: parquetOptional},
+			{name: "text_value", physical: parquetByteArray, converted: parquetUTF8, repetition: parquetOptional},
+		},
+	}
+	pw.write(parquetMagic)
+	return pw
+}
+
+func (pw *Writer) write(p []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(p)
+	pw.offset += int64(n)
+	pw.err = err
+}
+
+// Write buffers one metric, writing a row group once enough rows are buffered
+func (pw *Writer) Write(m model.Metric) error {
+	c := pw.columns
+	c[0].appendInt64(m.Time.UnixNano() / int64(time.Microsecond))
+	c[1].appendString(m.RunID.String())
+	c[2].appendString(m.MetricName)
+	if m.Step != nil {
+		c[3].appendInt64(int64(*m.Step))
+	} else {
+		c[3].appendNull()
+	}
+	if m.IsNumeric() {
+		c[4].appendDouble(m.Value)
+	} else {
+		c[4].appendNull()
+	}
+	c[5].appendString(m.NodeID)
+	if m.Rank != nil {
+		c[6].appendInt32(int32(*m.Rank))
+	} else {
+		c[6].appendNull()
+	}
+	if m.Text != nil {
+		c[7].appendString(*m.Text)
+	} else {
+		c[7].appendNull()
+	}
+
+	pw.rows++
+	if pw.rows >= parquetRowGroupSize {
+		pw.flushRowGroup()
+	}
+	return pw.err
+}
+
+// flushRowGroup writes each column of the buffered rows as one data page
+func (pw *Writer) flushRowGroup() {
+	if pw.rows == 0 {
+		return
+	}
+
+	group := rowGroup{rows: pw.rows}
+	for _, c := range pw.columns {
+		var page bytes.Buffer
+		if c.repetition == parquetOptional {
+			levels := encodeLevels(c.levels)
+			binary.Write(&page, binary.LittleEndian, uint32(len(levels)))
+			page.Write(levels)
+		}
+		page.Write(c.values.Bytes())
+
+		var header thriftWriter
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(page.Len()))
+		header.i32(3, int32(page.Len()))
+		header.structBegin(5)
+		header.i32(1, int32(c.count))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.structEnd()
+		header.stop()
+
+		chunk := columnChunk{column: c, offset: pw.offset, values: int64(c.count)}
+		pw.write(header.buf.Bytes())
+		pw.write(page.Bytes())
+		chunk.size = pw.offset - chunk.offset
+		group.size += chunk.size
+		group.chunks = append(group.chunks, chunk)
+
+		// The footer only needs the column's schema from here on
+		snapshot := *c
+		snapshot.values = bytes.Buffer{}
+		snapshot.levels = nil
+		group.chunks[len(group.chunks)-1].column = &snapshot
+		c.reset()
+	}
+
+	pw.groups = append(pw.groups, group)
+	pw.rows = 0
+}
+
+// Close writes the remaining rows and the file footer
+func (pw *Writer) Close() error {
+	pw.flushRowGroup()
+
+	var meta thriftWriter
+	meta.i32(1, 1) // version
+
+	meta.listBegin(2, thriftStruct, len(pw.columns)+1)
+	meta.elemBegin()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(pw.columns)))
+	meta.elemEnd()
+	for _, c := range pw.columns {
+		meta.elemBegin()
+		meta.i32(1, c.physical)
+		meta.i32(3, c.repetition)
+		meta.binary(4, c.name)
+		if c.converted >= 0 {
+			meta.i32(6, c.converted)
+		}
+		meta.elemEnd()
+	}
+
+	var total int64
+	for _, g := range pw.groups {
+		total += g.rows
+	}
+	meta.i64(3, total)
+
+	meta.listBegin(4, thriftStruct, len(pw.groups))
+	for _, g := range pw.groups {
+		meta.elemBegin()
+		meta.listBegin(1, thriftStruct, len(g.chunks))
+		for _, chunk := range g.chunks {
+			meta.elemBegin()
+			meta.i64(2, chunk.offset)
+			meta.structBegin(3)
+			meta.i32(1, chunk.column.physical)
+			meta.listBegin(2, thriftI32, 2)
+			meta.varint(zigzag(parquetPlain))
+			meta.varint(zigzag(parquetRLE))
+			meta.listBegin(3, thriftBinary, 1)
+			meta.varint(uint64(len(chunk.column.name)))
+			meta.buf.WriteString(chunk.column.name)
+			meta.i32(4, 0) // UNCOMPRESSED
+			meta.i64(5, chunk.values)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.structEnd()
+			meta.elemEnd()
+		}
+		meta.i64(2, g.size)
+		meta.i64(3, g.rows)
+		meta.elemEnd()
+	}
+
+	meta.binary(6, "wanllmdb")
+	meta.stop()
+
+	pw.write(meta.buf.Bytes())
+	footerLen := make([]byte, 4)
+	binary.LittleEndian.PutUint32(footerLen, uint32(meta.buf.Len()))
+	pw.write(footerLen)
+	pw.write(parquetMagic)
+
+	if pw.err != nil {
+		return fmt.Errorf("failed to write parquet: %w", pw.err)
+	}
+	return nil
+}
+
+// encodeLevels encodes definition levels of bit width 1 as RLE runs
+func encodeLevels(levels []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		writeVarint(&out, uint64(j-i)<<1)
+		out.WriteByte(levels[i])
+		i = j
+	}
+	return out.Bytes()
+}
+
+// Thrift compact protocol types used by the Parquet footer and page headers
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes Thrift structs with the compact protocol. Field IDs
+// are delta encoded against the previous field of the enclosing struct.
+type thriftWriter struct {
+	buf   bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	writeVarint(&t.buf, v)
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+func (t *thriftWriter) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.elemBegin()
+}
+
+func (t *thriftWriter) structEnd() {
+	t.elemEnd()
+}
+
+// elemBegin starts a struct without a field header, as list elements are
+func (t *thriftWriter) elemBegin() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) elemEnd() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
+
+func (t *thriftWriter) listBegin(id int16, elemType byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xF0 | elemType)
+		t.varint(uint64(size))
+	}
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func writeVarint(buf *bytes.Buffer, v uint64) {
+	for v >= 0x80 {
+		buf.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	buf.WriteByte(byte(v))
+}
@@ -0,0 +1,177 @@
+// Package promremote reads the requests and writes the responses of the
+// Prometheus remote-read protocol, the ReadRequest and ReadResponse messages
+// of prometheus/prompb, field by field. Compression is left to the caller.
+package promremote
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// Field numbers of the messages read and written
+const (
+	readRequestQueries = 1
+
+	queryStart    = 1
+	queryEnd      = 2
+	queryMatchers = 3
+
+	matcherType  = 1
+	matcherName  = 2
+	matcherValue = 3
+
+	readResponseResults = 1
+	queryResultSeries   = 1
+	seriesLabels        = 1
+	seriesSamples       = 2
+	labelName           = 1
+	labelValue          = 2
+	sampleValue         = 1
+	sampleTimestamp     = 2
+)
+
+// DecodeReadRequest reads the queries of a ReadRequest. Accepted response
+// types and hints are skipped: results are always sampled.
+func DecodeReadRequest(data []byte) ([]model.PromReadQuery, error) {
+	var queries []model.PromReadQuery
+	err := decodeMessage(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+		if num != readRequestQueries || typ != protowire.BytesType {
+			return skipField(num, typ, data)
+		}
+		msg, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return n, nil
+		}
+		query, err := decodeQuery(msg)
+		if err != nil {
+			return 0, err
+		}
+		queries = append(queries, query)
+		return n, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid read request: %w", err)
+	}
+	return queries, nil
+}
+
+func decodeQuery(data []byte) (model.PromReadQuery, error) {
+	var start, end int64
+	var query model.PromReadQuery
+	err := decodeMessage(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+		switch {
+		case (num == queryStart || num == queryEnd) && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if num == queryStart {
+				start = int64(v)
+			} else {
+				end = int64(v)
+			}
+			return n, nil
+		case num == queryMatchers && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			matcher, err := decodeMatcher(msg)
+			if err != nil {
+				return 0, err
+			}
+			query.Matchers = append(query.Matchers, matcher)
+			return n, nil
+		}
+		return skipField(num, typ, data)
+	})
+	query.Start = time.UnixMilli(start).UTC()
+	query.End = time.UnixMilli(end).UTC()
+	return query, err
+}
+
+func decodeMatcher(data []byte) (model.PromLabelMatcher, error) {
+	var matcher model.PromLabelMatcher
+	err := decodeMessage(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+		switch {
+		case num == matcherType && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			matcher.Type = int(v)
+			return n, nil
+		case (num == matcherName || num == matcherValue) && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(data)
+			if num == matcherName {
+				matcher.Name = v
+			} else {
+				matcher.Value = v
+			}
+			return n, nil
+		}
+		return skipField(num, typ, data)
+	})
+	return matcher, err
+}
+
+// decodeMessage passes each field of a message to field, which consumes its
+// value and returns its length, negative when it is malformed
+func decodeMessage(data []byte, field func(num protowire.Number, typ protowire.Type, data []byte) (int, error)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		n, err := field(num, typ, data)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func skipField(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+	if typ == protowire.StartGroupType || typ == protowire.EndGroupType {
+		return 0, errors.New("groups are not supported")
+	}
+	return protowire.ConsumeFieldValue(num, typ, data), nil
+}
+
+// AppendReadResponse appends a ReadResponse holding one result per query,
+// in order, to b
+func AppendReadResponse(b []byte, results [][]model.PromTimeSeries) []byte {
+	var result, series, msg []byte
+	for _, r := range results {
+		result = result[:0]
+		for _, s := range r {
+			series = series[:0]
+			for _, l := range s.Labels {
+				msg = protowire.AppendTag(msg[:0], labelName, protowire.BytesType)
+				msg = protowire.AppendString(msg, l.Name)
+				msg = protowire.AppendTag(msg, labelValue, protowire.BytesType)
+				msg = protowire.AppendString(msg, l.Value)
+				series = protowire.AppendTag(series, seriesLabels, protowire.BytesType)
+				series = protowire.AppendBytes(series, msg)
+			}
+			for _, sample := range s.Samples {
+				msg = protowire.AppendTag(msg[:0], sampleValue, protowire.Fixed64Type)
+				msg = protowire.AppendFixed64(msg, math.Float64bits(sample.Value))
+				msg = protowire.AppendTag(msg, sampleTimestamp, protowire.VarintType)
+				msg = protowire.AppendVarint(msg, uint64(sample.Time.UnixMilli()))
+				series = protowire.AppendTag(series, seriesSamples, protowire.BytesType)
+				series = protowire.AppendBytes(series, msg)
+			}
+			result = protowire.AppendTag(result, queryResultSeries, protowire.BytesType)
+			result = protowire.AppendBytes(result, series)
+		}
+		b = protowire.AppendTag(b, readResponseResults, protowire.BytesType)
+		b = protowire.AppendBytes(b, result)
+	}
+	return b
+}
@@ -0,0 +1,86 @@
+package promremote
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// message appends field number num holding msg to b
+func message(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func TestDecodeReadRequest(t *testing.T) {
+	var matcher []byte
+	matcher = protowire.AppendTag(matcher, matcherType, protowire.VarintType)
+	matcher = protowire.AppendVarint(matcher, model.PromMatchRegexp)
+	matcher = protowire.AppendTag(matcher, matcherName, protowire.BytesType)
+	matcher = protowire.AppendString(matcher, "__name__")
+	matcher = protowire.AppendTag(matcher, matcherValue, protowire.BytesType)
+	matcher = protowire.AppendString(matcher, "train/.*")
+
+	var query []byte
+	query = protowire.AppendTag(query, queryStart, protowire.VarintType)
+	query = protowire.AppendVarint(query, 1700000000000)
+	query = protowire.AppendTag(query, queryEnd, protowire.VarintType)
+	query = protowire.AppendVarint(query, 1700000060000)
+	query = message(query, queryMatchers, matcher)
+	// Hints are skipped
+	query = message(query, 4, []byte{0x08, 0x01})
+
+	req := message(nil, readRequestQueries, query)
+	// As are accepted response types
+	req = protowire.AppendTag(req, 2, protowire.BytesType)
+	req = protowire.AppendBytes(req, []byte{0x00, 0x01})
+
+	queries, err := DecodeReadRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 1 {
+		t.Fatalf("got %d queries, want 1", len(queries))
+	}
+	q := queries[0]
+	if !q.Start.Equal(time.UnixMilli(1700000000000)) || !q.End.Equal(time.UnixMilli(1700000060000)) {
+		t.Errorf("range = %v to %v", q.Start, q.End)
+	}
+	want := model.PromLabelMatcher{Type: model.PromMatchRegexp, Name: "__name__", Value: "train/.*"}
+	if len(q.Matchers) != 1 || q.Matchers[0] != want {
+		t.Errorf("matchers = %v, want [%v]", q.Matchers, want)
+	}
+
+	if _, err := DecodeReadRequest(req[:len(req)-1]); err == nil {
+		t.Error("DecodeReadRequest accepted a truncated request")
+	}
+}
+
+func TestAppendReadResponse(t *testing.T) {
+	results := [][]model.PromTimeSeries{{{
+		Labels:  []model.PromLabel{{Name: "__name__", Value: "loss"}},
+		Samples: []model.PromSample{{Value: 0.5, Time: time.UnixMilli(1000)}},
+	}}, nil}
+
+	var label, sample []byte
+	label = protowire.AppendTag(label, labelName, protowire.BytesType)
+	label = protowire.AppendString(label, "__name__")
+	label = protowire.AppendTag(label, labelValue, protowire.BytesType)
+	label = protowire.AppendString(label, "loss")
+	sample = protowire.AppendTag(sample, sampleValue, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(0.5))
+	sample = protowire.AppendTag(sample, sampleTimestamp, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, 1000)
+	series := message(message(nil, seriesLabels, label), seriesSamples, sample)
+	// An empty result stays in place, so that results line up with queries
+	want := message(message(nil, readResponseResults, message(nil, queryResultSeries, series)), readResponseResults, nil)
+
+	if got := AppendReadResponse(nil, results); !bytes.Equal(got, want) {
+		t.Errorf("AppendReadResponse = %x, want %x", got, want)
+	}
+}
@@ -0,0 +1,265 @@
+// Package pubsub publishes messages to Google Cloud Pub/Sub topics through
+// the REST API. It authenticates with a service account key, as a
+// self-signed JWT, or else with the metadata server of the GCE, GKE or Cloud
+// Run instance it runs on; against the emulator it sends no credentials.
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultEndpoint is the endpoint of the Pub/Sub API
+const DefaultEndpoint = "https://pubsub.googleapis.com"
+
+// metadataTokenURL serves access tokens of the instance's service account
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// Options configure a Client. Topic is the full topic name,
+// "projects/<project>/topics/<topic>". EmulatorHost, the host:port of a
+// Pub/Sub emulator, overrides Endpoint and disables authentication.
+type Options struct {
+	Topic           string
+	Endpoint        string
+	CredentialsFile string
+	EmulatorHost    string
+	Timeout         time.Duration
+}
+
+// Message is a message published to a topic. Messages with the same
+// OrderingKey are delivered in order to subscriptions that enable ordering.
+type Message struct {
+	Data        []byte
+	Attributes  map[string]string
+	OrderingKey string
+}
+
+// Client publishes to one topic
+type Client struct {
+	opts     Options
+	endpoint string
+	client   *http.Client
+	now      func() time.Time
+
+	// key signs self-signed JWTs when a service account key is configured
+	key   *rsa.PrivateKey
+	keyID string
+	email string
+
+	// tokenURL serves access tokens otherwise, unless on the emulator
+	tokenURL string
+	mu       sync.Mutex
+	token    string
+	expiry   time.Time
+}
+
+func NewClient(opts Options) (*Client, error) {
+	parts := strings.Split(opts.Topic, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[1] == "" || parts[2] != "topics" || parts[3] == "" {
+		return nil, fmt.Errorf("invalid Pub/Sub topic %q, want projects/<project>/topics/<topic>", opts.Topic)
+	}
+	c := &Client{
+		opts:     opts,
+		endpoint: strings.TrimSuffix(opts.Endpoint, "/"),
+		client:   &http.Client{Timeout: opts.Timeout},
+		now:      time.Now,
+	}
+	if c.endpoint == "" {
+		c.endpoint = DefaultEndpoint
+	}
+	switch {
+	case opts.EmulatorHost != "":
+		c.endpoint = "http://" + opts.EmulatorHost
+	case opts.CredentialsFile != "":
+		if err := c.loadKey(opts.CredentialsFile); err != nil {
+			return nil, err
+		}
+	default:
+		c.tokenURL = metadataTokenURL
+	}
+	return c, nil
+}
+
+// loadKey reads a service account key file, as the IAM console downloads it
+func (c *Client) loadKey(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read credentials: %w", err)
+	}
+	var creds struct {
+		Type         string `json:"type"`
+		ClientEmail  string `json:"client_email"`
+		PrivateKeyID string `json:"private_key_id"`
+		PrivateKey   string `json:"private_key"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return fmt.Errorf("failed to parse credentials: %w", err)
+	}
+	if creds.Type != "service_account" {
+		return fmt.Errorf("credentials of type %q are not supported, want a service account key", creds.Type)
+	}
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return fmt.Errorf("credentials hold no PEM private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return fmt.Errorf("failed to parse private key: %w", err)
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("private key is not an RSA key")
+	}
+	c.key, c.keyID, c.email = key, creds.PrivateKeyID, creds.ClientEmail
+	return nil
+}
+
+// Publish publishes a message and returns the ID Pub/Sub gave it
+func (c *Client) Publish(ctx context.Context, msg Message) (string, error) {
+	type pubsubMessage struct {
+		Data        string            `json:"data"`
+		Attributes  map[string]string `json:"attributes,omitempty"`
+		OrderingKey string            `json:"orderingKey,omitempty"`
+	}
+	body, err := json.Marshal(struct {
+		Messages []pubsubMessage `json:"messages"`
+	}{Messages: []pubsubMessage{{
+		Data:        base64.StdEncoding.EncodeToString(msg.Data),
+		Attributes:  msg.Attributes,
+		OrderingKey: msg.OrderingKey,
+	}}})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/v1/"+c.opts.Topic+":publish", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	auth, err := c.authorization(ctx)
+	if err != nil {
+		return "", err
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Status != "" {
+			return "", fmt.Errorf("Pub/Sub responded %d: %s: %s", resp.StatusCode, apiErr.Error.Status, apiErr.Error.Message)
+		}
+		return "", fmt.Errorf("Pub/Sub responded %d", resp.StatusCode)
+	}
+	var result struct {
+		MessageIDs []string `json:"messageIds"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil || len(result.MessageIDs) != 1 {
+		return "", fmt.Errorf("failed to decode response: %s", data)
+	}
+	return result.MessageIDs[0], nil
+}
+
+// authorization is the Authorization header of a request, empty against the
+// emulator
+func (c *Client) authorization(ctx context.Context) (string, error) {
+	switch {
+	case c.key != nil:
+		token, err := c.signJWT()
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	case c.tokenURL != "":
+		token, err := c.accessToken(ctx)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+	return "", nil
+}
+
+// signJWT makes a self-signed JWT, which Google APIs accept in place of an
+// OAuth access token when its audience is the API
+func (c *Client) signJWT() (string, error) {
+	now := c.now().Unix()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": c.keyID})
+	claims, _ := json.Marshal(map[string]any{
+		"iss": c.email,
+		"sub": c.email,
+		"aud": "https://pubsub.googleapis.com/",
+		"iat": now,
+		"exp": now + 3600,
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// accessToken fetches an access token from the metadata server, reusing it
+// until a minute before it expires
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && c.now().Before(c.expiry) {
+		return c.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.tokenURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server responded %d", resp.StatusCode)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode access token: %w", err)
+	}
+	c.token = token.AccessToken
+	c.expiry = c.now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
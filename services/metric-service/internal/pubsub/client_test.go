@@ -0,0 +1,166 @@
+package pubsub
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewClient(t *testing.T) {
+	invalid := []string{"", "runs", "projects/p/topics/", "projects//topics/t", "projects/p/subscriptions/s"}
+	for _, topic := range invalid {
+		if _, err := NewClient(Options{Topic: topic}); err == nil {
+			t.Errorf("NewClient(%q) succeeded", topic)
+		}
+	}
+
+	c, err := NewClient(Options{Topic: "projects/p/topics/t", EmulatorHost: "localhost:8085"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.endpoint != "http://localhost:8085" || c.key != nil || c.tokenURL != "" {
+		t.Errorf("emulator client at %s, key %v, token URL %q", c.endpoint, c.key != nil, c.tokenURL)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	var path, auth string
+	var received struct {
+		Messages []struct {
+			Data        string            `json:"data"`
+			Attributes  map[string]string `json:"attributes"`
+			OrderingKey string            `json:"orderingKey"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Error(err)
+		}
+		if received.Messages[0].OrderingKey == "fail" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error":{"code":404,"message":"Resource not found","status":"NOT_FOUND"}}`)
+			return
+		}
+		io.WriteString(w, `{"messageIds":["42"]}`)
+	}))
+	defer server.Close()
+
+	c, err := NewClient(Options{Topic: "projects/p/topics/t", EmulatorHost: strings.TrimPrefix(server.URL, "http://")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := c.Publish(context.Background(), Message{
+		Data:        []byte(`{"id":"1"}`),
+		Attributes:  map[string]string{"ce-type": "run.finished"},
+		OrderingKey: "run-1",
+	})
+	if err != nil || id != "42" {
+		t.Fatalf("Publish() = %q, %v", id, err)
+	}
+	if path != "/v1/projects/p/topics/t:publish" || auth != "" {
+		t.Errorf("published to %s with Authorization %q", path, auth)
+	}
+	msg := received.Messages[0]
+	data, _ := base64.StdEncoding.DecodeString(msg.Data)
+	if string(data) != `{"id":"1"}` || msg.Attributes["ce-type"] != "run.finished" || msg.OrderingKey != "run-1" {
+		t.Errorf("published %s, %v, %q", data, msg.Attributes, msg.OrderingKey)
+	}
+
+	if _, err := c.Publish(context.Background(), Message{OrderingKey: "fail"}); err == nil || !strings.Contains(err.Error(), "NOT_FOUND") {
+		t.Errorf("Publish() to a missing topic = %v", err)
+	}
+}
+
+func TestSignJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	creds, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "metrics@p.iam.gserviceaccount.com",
+		"private_key_id": "k1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+	file := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(file, creds, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClient(Options{Topic: "projects/p/topics/t", CredentialsFile: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.now = func() time.Time { return time.Unix(1700000000, 0) }
+	token, err := c.signJWT()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts", len(parts))
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], sig); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+	data, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]any
+	json.Unmarshal(data, &claims)
+	if claims["iss"] != "metrics@p.iam.gserviceaccount.com" || claims["aud"] != "https://pubsub.googleapis.com/" || claims["exp"] != float64(1700003600) {
+		t.Errorf("claims = %v", claims)
+	}
+}
+
+func TestAccessToken(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fetches++
+		io.WriteString(w, `{"access_token":"token","expires_in":3600,"token_type":"Bearer"}`)
+	}))
+	defer server.Close()
+
+	c, err := NewClient(Options{Topic: "projects/p/topics/t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.tokenURL = server.URL
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if auth, err := c.authorization(context.Background()); err != nil || auth != "Bearer token" {
+			t.Fatalf("authorization() = %q, %v", auth, err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("fetched %d tokens, want the first reused", fetches)
+	}
+	now = now.Add(time.Hour)
+	c.authorization(context.Background())
+	if fetches != 2 {
+		t.Errorf("fetched %d tokens, want an expiring one refreshed", fetches)
+	}
+}
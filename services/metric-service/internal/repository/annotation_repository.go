@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type AnnotationRepository struct {
+	db     *pgxpool.Pool
+	limits RowLimits
+	logger *zap.Logger
+}
+
+func NewAnnotationRepository(db *pgxpool.Pool, limits RowLimits, logger *zap.Logger) *AnnotationRepository {
+	return &AnnotationRepository{
+		db:     db,
+		limits: limits,
+		logger: logger,
+	}
+}
+
+// CreateAnnotation adds an annotation to a run
+func (r *AnnotationRepository) CreateAnnotation(ctx context.Context, a *model.Annotation) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO run_annotations (id, run_id, time, step, text, metadata)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING created_at`,
+		a.ID, a.RunID, a.Time, a.Step, a.Text, a.Metadata,
+	).Scan(&a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert annotation: %w", err)
+	}
+	return nil
+}
+
+// GetRunAnnotations retrieves the annotations of a run in time order
+func (r *AnnotationRepository) GetRunAnnotations(ctx context.Context, runID uuid.UUID, params model.AnnotationQueryParams) ([]model.Annotation, error) {
+	q := newQuery(`SELECT id, run_id, time, step, text, metadata, created_at
+	               FROM run_annotations
+	               WHERE run_id = ?`, runID)
+	addIfSet(q, " AND time >= ?", params.StartTime)
+	addIfSet(q, " AND time <= ?", params.EndTime)
+	addIfSet(q, " AND step >= ?", params.MinStep)
+	addIfSet(q, " AND step <= ?", params.MaxStep)
+	q.Add(" ORDER BY time ASC LIMIT ?", r.limits.resultLimit(0))
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query annotations: %w", err)
+	}
+	annotations, err := pgx.CollectRows(rows, scanner(func(a *model.Annotation) []interface{} {
+		return []interface{}{&a.ID, &a.RunID, &a.Time, &a.Step, &a.Text, &a.Metadata, &a.CreatedAt}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read annotations: %w", err)
+	}
+
+	return annotations, r.limits.checkResultRows("annotations", len(annotations))
+}
+
+// DeleteAnnotation removes an annotation, reporting whether it existed
+func (r *AnnotationRepository) DeleteAnnotation(ctx context.Context, runID, annotationID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx,
+		`DELETE FROM run_annotations WHERE run_id = $1 AND id = $2`,
+		runID, annotationID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete annotation: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
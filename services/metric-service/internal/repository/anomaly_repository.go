@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type AnomalyRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewAnomalyRepository(db *pgxpool.Pool, logger *zap.Logger) *AnomalyRepository {
+	return &AnomalyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BatchWrite inserts anomaly events in a single round trip
+func (r *AnomalyRepository) BatchWrite(ctx context.Context, anomalies []model.Anomaly) error {
+	if len(anomalies) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, a := range anomalies {
+		batch.Queue(
+			`INSERT INTO metric_anomalies (time, run_id, metric_name, step, value, kind, score, expected_mean, expected_stddev, message)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			a.Time, a.RunID, a.MetricName, a.Step, a.Value, a.Kind, a.Score, a.ExpectedMean, a.ExpectedStdDev, a.Message,
+		)
+	}
+
+	br := r.db.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for i := 0; i < len(anomalies); i++ {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("failed to insert anomaly %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// GetRunAnomalies retrieves anomaly events for a specific run
+func (r *AnomalyRepository) GetRunAnomalies(ctx context.Context, runID uuid.UUID, params model.AnomalyQueryParams) ([]model.Anomaly, error) {
+	q := newQuery(`SELECT time, run_id, metric_name, step, value, kind, score, expected_mean, expected_stddev, message
+	               FROM metric_anomalies
+	               WHERE run_id = ?`, runID)
+	addIfSet(q, " AND time >= ?", params.StartTime)
+	addIfSet(q, " AND time <= ?", params.EndTime)
+	addIfNotZero(q, " AND metric_name = ?", params.MetricName)
+	addIfNotZero(q, " AND kind = ?", params.Kind)
+	q.Add(" ORDER BY time DESC")
+	if params.Limit > 0 {
+		q.Add(" LIMIT ?", params.Limit)
+	}
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query anomalies: %w", err)
+	}
+	anomalies, err := pgx.CollectRows(rows, scanner(func(a *model.Anomaly) []interface{} {
+		return []interface{}{&a.Time, &a.RunID, &a.MetricName, &a.Step, &a.Value, &a.Kind, &a.Score, &a.ExpectedMean, &a.ExpectedStdDev, &a.Message}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read anomalies: %w", err)
+	}
+	return anomalies, nil
+}
+
+// CountRunAnomalies counts anomaly events of the given kinds recorded for a run
+func (r *AnomalyRepository) CountRunAnomalies(ctx context.Context, runID uuid.UUID, kinds []string) (int64, error) {
+	var count int64
+	err := r.db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM metric_anomalies WHERE run_id = $1 AND kind = ANY($2)`,
+		runID, kinds,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count anomalies: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type ArtifactRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewArtifactRepository(db *pgxpool.Pool, logger *zap.Logger) *ArtifactRepository {
+	return &ArtifactRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CreateArtifact registers an artifact reference on a run
+func (r *ArtifactRepository) CreateArtifact(ctx context.Context, a *model.ArtifactRef) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO run_artifacts (id, run_id, step, kind, name, uri, digest, metadata)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING created_at`,
+		a.ID, a.RunID, a.Step, a.Kind, a.Name, a.URI, a.Digest, a.Metadata,
+	).Scan(&a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert artifact: %w", err)
+	}
+	return nil
+}
+
+// GetRunArtifacts retrieves the artifacts of a run ordered by step
+func (r *ArtifactRepository) GetRunArtifacts(ctx context.Context, runID uuid.UUID, params model.ArtifactQueryParams) ([]model.ArtifactRef, error) {
+	q := newQuery(`SELECT `+artifactColumns+`
+	               FROM run_artifacts
+	               WHERE run_id = ?`, runID)
+	addIfNotZero(q, " AND kind = ?", params.Kind)
+	addIfSet(q, " AND step >= ?", params.MinStep)
+	addIfSet(q, " AND step <= ?", params.MaxStep)
+	q.Add(" ORDER BY step NULLS FIRST, created_at")
+	if params.Limit > 0 {
+		q.Add(" LIMIT ?", params.Limit)
+	}
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query artifacts: %w", err)
+	}
+	artifacts, err := pgx.CollectRows(rows, scanArtifact)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifacts: %w", err)
+	}
+	return artifacts, nil
+}
+
+// GetNearestArtifact retrieves the artifact logged closest to a step,
+// preferring the earlier one on ties
+func (r *ArtifactRepository) GetNearestArtifact(ctx context.Context, runID uuid.UUID, step int64, kind string) (*model.ArtifactRef, error) {
+	q := newQuery(`SELECT `+artifactColumns+`
+	               FROM run_artifacts
+	               WHERE run_id = ? AND step IS NOT NULL`, runID)
+	addIfNotZero(q, " AND kind = ?", kind)
+	q.Add(" ORDER BY ABS(step - ?), step, created_at DESC LIMIT 1", step)
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nearest artifact: %w", err)
+	}
+	a, err := pgx.CollectOneRow(rows, scanArtifact)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nearest artifact: %w", err)
+	}
+	return &a, nil
+}
+
+// artifactColumns are the columns read into a model.ArtifactRef by scanArtifact
+const artifactColumns = `id, run_id, step, kind, name, uri, digest, metadata, created_at`
+
+var scanArtifact = scanner(func(a *model.ArtifactRef) []interface{} {
+	return []interface{}{&a.ID, &a.RunID, &a.Step, &a.Kind, &a.Name, &a.URI, &a.Digest, &a.Metadata, &a.CreatedAt}
+})
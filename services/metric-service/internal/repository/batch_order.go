@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// timeOrder returns the indexes of n batch rows ordered by run and then by
+// time, so that an insert fills each run's chunks in order. Clients flushing
+// buffers after a reconnect send rows out of order, which would otherwise
+// spread one insert over many chunks. Rows of the same run and time keep
+// their order in the batch.
+func timeOrder(n int, key func(i int) (uuid.UUID, time.Time)) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	less := func(a, b int) bool {
+		runA, timeA := key(a)
+		runB, timeB := key(b)
+		if c := bytes.Compare(runA[:], runB[:]); c != 0 {
+			return c < 0
+		}
+		return timeA.Before(timeB)
+	}
+
+	sorted := true
+	for i := 1; i < n && sorted; i++ {
+		sorted = !less(i, i-1)
+	}
+	if !sorted {
+		sort.SliceStable(order, func(i, j int) bool { return less(order[i], order[j]) })
+	}
+	return order
+}
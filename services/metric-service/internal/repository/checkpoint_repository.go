@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// upsertBestCheckpoint ends the statements that propose best checkpoints: a
+// candidate only replaces the current best when its value is strictly better
+const upsertBestCheckpoint = `
+	 ON CONFLICT (run_id, metric_name) DO UPDATE SET
+	   goal = EXCLUDED.goal,
+	   artifact_id = EXCLUDED.artifact_id,
+	   step = EXCLUDED.step,
+	   value = EXCLUDED.value,
+	   time = EXCLUDED.time,
+	   updated_at = NOW()
+	 WHERE CASE
+	   WHEN EXCLUDED.goal = 'max' THEN EXCLUDED.value > run_best_checkpoints.value
+	   ELSE EXCLUDED.value < run_best_checkpoints.value
+	 END`
+
+// bestCandidateOrder picks, per objective, the best candidate and, among equal
+// values, the earliest checkpoint
+const bestCandidateOrder = `CASE WHEN o.goal = 'max' THEN -m.value ELSE m.value END, a.step, a.created_at`
+
+type CheckpointRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewCheckpointRepository(db *pgxpool.Pool, logger *zap.Logger) *CheckpointRepository {
+	return &CheckpointRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SetObjectives adds or updates checkpoint objectives of a run and rebuilds
+// their best checkpoints from the run's history
+func (r *CheckpointRepository) SetObjectives(ctx context.Context, runID uuid.UUID, objectives []model.CheckpointObjective) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	names := make([]string, len(objectives))
+	for i, o := range objectives {
+		names[i] = o.MetricName
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO run_checkpoint_objectives (run_id, metric_name, goal)
+			 VALUES ($1, $2, $3)
+			 ON CONFLICT (run_id, metric_name) DO UPDATE SET goal = EXCLUDED.goal`,
+			runID, o.MetricName, o.Goal,
+		); err != nil {
+			return fmt.Errorf("failed to save checkpoint objective: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM run_best_checkpoints WHERE run_id = $1 AND metric_name = ANY($2)`,
+		runID, names,
+	); err != nil {
+		return fmt.Errorf("failed to clear best checkpoints: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO run_best_checkpoints (run_id, metric_name, goal, artifact_id, step, value, time)
+		 SELECT DISTINCT ON (o.metric_name) o.run_id, o.metric_name, o.goal, a.id, a.step, m.value, m.time
+		 FROM run_checkpoint_objectives o
+		 JOIN run_artifacts a ON a.run_id = o.run_id AND a.kind = 'checkpoint' AND a.step IS NOT NULL
+		 JOIN LATERAL (
+		     SELECT value, time FROM metrics
+		     WHERE run_id = o.run_id AND metric_name = o.metric_name AND step = a.step
+		       AND value NOT IN ('NaN'::float8, 'Infinity'::float8, '-Infinity'::float8)
+		     ORDER BY time DESC LIMIT 1
+		 ) m ON true
+		 WHERE o.run_id = $1 AND o.metric_name = ANY($2)
+		 ORDER BY o.metric_name, `+bestCandidateOrder,
+		runID, names,
+	); err != nil {
+		return fmt.Errorf("failed to rebuild best checkpoints: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetObjectives retrieves the checkpoint objectives of a run
+func (r *CheckpointRepository) GetObjectives(ctx context.Context, runID uuid.UUID) ([]model.CheckpointObjective, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT metric_name, goal FROM run_checkpoint_objectives WHERE run_id = $1 ORDER BY metric_name`,
+		runID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query checkpoint objectives: %w", err)
+	}
+	objectives, err := pgx.CollectRows(rows, scanner(func(o *model.CheckpointObjective) []interface{} {
+		return []interface{}{&o.MetricName, &o.Goal}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint objectives: %w", err)
+	}
+	return objectives, nil
+}
+
+// ProposeCheckpoint offers a newly registered checkpoint as the best one of
+// each objective of its run, using the objectives' values logged at its step
+func (r *CheckpointRepository) ProposeCheckpoint(ctx context.Context, artifact *model.ArtifactRef) error {
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO run_best_checkpoints (run_id, metric_name, goal, artifact_id, step, value, time)
+		 SELECT o.run_id, o.metric_name, o.goal, $2::uuid, $3::bigint, m.value, m.time
+		 FROM run_checkpoint_objectives o
+		 JOIN LATERAL (
+		     SELECT value, time FROM metrics
+		     WHERE run_id = o.run_id AND metric_name = o.metric_name AND step = $3
+		       AND value NOT IN ('NaN'::float8, 'Infinity'::float8, '-Infinity'::float8)
+		     ORDER BY time DESC LIMIT 1
+		 ) m ON true
+		 WHERE o.run_id = $1`+upsertBestCheckpoint,
+		artifact.RunID, artifact.ID, artifact.Step,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update best checkpoints: %w", err)
+	}
+	return nil
+}
+
+// ProposeMetrics offers the checkpoints saved at the steps of newly logged
+// objective values as the best ones of their objectives
+func (r *CheckpointRepository) ProposeMetrics(ctx context.Context, metrics []model.Metric) error {
+	var (
+		runIDs []uuid.UUID
+		names  []string
+		steps  []int64
+		values []float64
+		times  []time.Time
+	)
+	for _, m := range metrics {
+		if m.Step == nil || !m.IsNumeric() || math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+			continue
+		}
+		runIDs = append(runIDs, m.RunID)
+		names = append(names, m.MetricName)
+		steps = append(steps, *m.Step)
+		values = append(values, m.Value)
+		times = append(times, m.Time)
+	}
+	if len(runIDs) == 0 {
+		return nil
+	}
+
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO run_best_checkpoints (run_id, metric_name, goal, artifact_id, step, value, time)
+		 SELECT DISTINCT ON (o.run_id, o.metric_name) o.run_id, o.metric_name, o.goal, a.id, a.step, m.value, m.time
+		 FROM unnest($1::uuid[], $2::text[], $3::bigint[], $4::float8[], $5::timestamptz[])
+		      AS m(run_id, metric_name, step, value, time)
+		 JOIN run_checkpoint_objectives o ON o.run_id = m.run_id AND o.metric_name = m.metric_name
+		 JOIN run_artifacts a ON a.run_id = m.run_id AND a.kind = 'checkpoint' AND a.step = m.step
+		 ORDER BY o.run_id, o.metric_name, `+bestCandidateOrder+upsertBestCheckpoint,
+		runIDs, names, steps, values, times,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update best checkpoints: %w", err)
+	}
+	return nil
+}
+
+// GetBestCheckpoints retrieves the best checkpoint of each objective of a
+// run, or of one objective when metricName is set
+func (r *CheckpointRepository) GetBestCheckpoints(ctx context.Context, runID uuid.UUID, metricName string) ([]model.BestCheckpoint, error) {
+	q := newQuery(`SELECT b.run_id, b.metric_name, b.goal, b.value, b.step, b.time, b.updated_at,
+	                      a.id, a.run_id, a.step, a.kind, a.name, a.uri, a.digest, a.metadata, a.created_at
+	               FROM run_best_checkpoints b
+	               JOIN run_artifacts a ON a.id = b.artifact_id
+	               WHERE b.run_id = ?`, runID)
+	addIfNotZero(q, " AND b.metric_name = ?", metricName)
+	q.Add(" ORDER BY b.metric_name")
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query best checkpoints: %w", err)
+	}
+	best, err := pgx.CollectRows(rows, scanner(func(b *model.BestCheckpoint) []interface{} {
+		a := &b.Checkpoint
+		return []interface{}{
+			&b.RunID, &b.MetricName, &b.Goal, &b.Value, &b.Step, &b.MetricTime, &b.UpdatedAt,
+			&a.ID, &a.RunID, &a.Step, &a.Kind, &a.Name, &a.URI, &a.Digest, &a.Metadata, &a.CreatedAt,
+		}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read best checkpoints: %w", err)
+	}
+	return best, nil
+}
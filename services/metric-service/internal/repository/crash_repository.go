@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// eccErrors reads the ECC error count a GPU sample reports in its metadata
+const eccErrors = `GREATEST(
+	CASE WHEN jsonb_typeof(metadata->'ecc_errors') = 'number' THEN (metadata->>'ecc_errors')::float8 END,
+	CASE WHEN jsonb_typeof(metadata->'ecc_uncorrected_errors') = 'number' THEN (metadata->>'ecc_uncorrected_errors')::float8 END)`
+
+type CrashRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewCrashRepository(db *pgxpool.Pool, logger *zap.Logger) *CrashRepository {
+	return &CrashRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// FindSilentRuns lists the runs that logged metrics after since but none
+// after until, and whose latest state, if any, is created or running. The
+// most recently silent runs come first.
+func (r *CrashRepository) FindSilentRuns(ctx context.Context, since, until time.Time, limit int) ([]model.SilentRun, error) {
+	rows, err := r.db.Query(ctx,
+		`WITH recent AS (
+		     SELECT run_id, MAX(time) AS last_time
+		     FROM metrics
+		     WHERE time > $1
+		     GROUP BY run_id
+		     HAVING MAX(time) < $2
+		 )
+		 SELECT r.run_id, r.last_time, s.step, COALESCE(e.state, ''), c.interval
+		 FROM recent r
+		 LEFT JOIN LATERAL (
+		     SELECT state FROM run_events
+		     WHERE run_id = r.run_id
+		     ORDER BY time DESC, created_at DESC
+		     LIMIT 1
+		 ) e ON true
+		 LEFT JOIN LATERAL (
+		     SELECT step FROM metrics
+		     WHERE run_id = r.run_id AND time = r.last_time AND step IS NOT NULL
+		     ORDER BY step DESC
+		     LIMIT 1
+		 ) s ON true
+		 LEFT JOIN LATERAL (
+		     SELECT EXTRACT(EPOCH FROM MAX(t) - MIN(t))::float8 / NULLIF(COUNT(*) - 1, 0) AS interval
+		     FROM (
+		         SELECT DISTINCT time AS t FROM metrics
+		         WHERE run_id = r.run_id AND time > $1
+		         ORDER BY t DESC
+		         LIMIT 100
+		     ) recent_times
+		 ) c ON true
+		 WHERE e.state IS NULL OR e.state IN ('created', 'running')
+		 ORDER BY r.last_time DESC
+		 LIMIT $3`,
+		since, until, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query silent runs: %w", err)
+	}
+	runs, err := pgx.CollectRows(rows, scanner(func(run *model.SilentRun) []interface{} {
+		return []interface{}{&run.RunID, &run.LastMetricAt, &run.LastStep, &run.State, &run.Interval}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read silent runs: %w", err)
+	}
+	return runs, nil
+}
+
+// GetEvidence summarizes the system and GPU samples of a run between from and to
+func (r *CrashRepository) GetEvidence(ctx context.Context, runID uuid.UUID, from, to time.Time) (*model.CrashEvidence, error) {
+	evidence := &model.CrashEvidence{}
+
+	err := r.db.QueryRow(ctx,
+		`SELECT
+		     (SELECT MAX(value) FROM system_metrics
+		      WHERE run_id = $1 AND metric_type = 'memory' AND time BETWEEN $2 AND $3),
+		     (SELECT MAX(time) FROM system_metrics
+		      WHERE run_id = $1 AND time BETWEEN $2 AND $3)`,
+		runID, from, to,
+	).Scan(&evidence.HostMemoryPercent, &evidence.LastSystemSample)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system metrics: %w", err)
+	}
+
+	// The fullest device, and the device with the most ECC errors. ECC
+	// counters are cumulative, so errors are counted as their growth over the
+	// window unless it holds a single sample.
+	rows, err := r.db.Query(ctx,
+		`SELECT device_index, MAX(memory_percent),
+		        CASE WHEN COUNT(ecc) > 1 THEN MAX(ecc) - MIN(ecc) ELSE COALESCE(MAX(ecc), 0) END
+		 FROM (
+		     SELECT device_index, memory_used_mb / NULLIF(memory_total_mb, 0) * 100 AS memory_percent,
+		            `+eccErrors+` AS ecc
+		     FROM gpu_metrics
+		     WHERE run_id = $1 AND time BETWEEN $2 AND $3
+		 ) samples
+		 GROUP BY device_index`,
+		runID, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query GPU metrics: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var device int
+		var memory *float64
+		var ecc float64
+		if err := rows.Scan(&device, &memory, &ecc); err != nil {
+			return nil, fmt.Errorf("failed to scan GPU evidence: %w", err)
+		}
+		if memory != nil && (evidence.GPUMemoryPercent == nil || *memory > *evidence.GPUMemoryPercent) {
+			d := device
+			evidence.GPUMemoryPercent, evidence.GPUDevice = memory, &d
+		}
+		if ecc > evidence.GPUECCErrors {
+			d := device
+			evidence.GPUECCErrors, evidence.ECCDevice = ecc, &d
+		}
+	}
+
+	return evidence, rows.Err()
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// cursorFetchSize is how many rows a streamed query fetches from its cursor
+// at a time, which bounds what is held in memory whatever the query returns
+const cursorFetchSize = 5000
+
+// streamCursor runs query through a server-side cursor in a read-only
+// transaction, fetching cursorFetchSize rows at a time and passing each to
+// fn as it is scanned. It stops at the first error fn returns and reports
+// the number of rows passed.
+func streamCursor[T any](ctx context.Context, db *pgxpool.Pool, query string, args []interface{}, scan pgx.RowToFunc[T], fn func(T) error) (int64, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DECLARE stream_cursor NO SCROLL CURSOR FOR `+query, args...); err != nil {
+		return 0, fmt.Errorf("failed to declare cursor: %w", err)
+	}
+
+	var count int64
+	for {
+		rows, err := tx.Query(ctx, fmt.Sprintf(`FETCH FORWARD %d FROM stream_cursor`, cursorFetchSize))
+		if err != nil {
+			return count, fmt.Errorf("failed to fetch rows: %w", err)
+		}
+		fetched := 0
+		for rows.Next() {
+			fetched++
+			value, err := scan(rows)
+			if err != nil {
+				rows.Close()
+				return count, fmt.Errorf("failed to scan row: %w", err)
+			}
+			if err := fn(value); err != nil {
+				rows.Close()
+				return count, err
+			}
+			count++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return count, fmt.Errorf("failed to fetch rows: %w", err)
+		}
+		if fetched < cursorFetchSize {
+			return count, nil
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type DefinitionRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewDefinitionRepository(db *pgxpool.Pool, logger *zap.Logger) *DefinitionRepository {
+	return &DefinitionRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// UpsertDefinition creates or replaces a metric definition
+func (r *DefinitionRepository) UpsertDefinition(ctx context.Context, def *model.MetricDefinition) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO metric_definitions (project_id, name, display_name, unit, goal, expected_min, expected_max, enforce_range, description,
+		                                 rank_reduce, keep_rank_values, cumulative)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		 ON CONFLICT (project_id, name) DO UPDATE SET
+		   display_name = EXCLUDED.display_name,
+		   unit = EXCLUDED.unit,
+		   goal = EXCLUDED.goal,
+		   expected_min = EXCLUDED.expected_min,
+		   expected_max = EXCLUDED.expected_max,
+		   enforce_range = EXCLUDED.enforce_range,
+		   description = EXCLUDED.description,
+		   rank_reduce = EXCLUDED.rank_reduce,
+		   keep_rank_values = EXCLUDED.keep_rank_values,
+		   cumulative = EXCLUDED.cumulative,
+		   updated_at = NOW()
+		 RETURNING created_at, updated_at`,
+		def.ProjectID, def.Name, def.DisplayName, def.Unit, def.Goal, def.ExpectedMin, def.ExpectedMax, def.EnforceRange, def.Description,
+		def.RankReduce, def.KeepRankValues, def.Cumulative,
+	).Scan(&def.CreatedAt, &def.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert metric definition: %w", err)
+	}
+	return nil
+}
+
+// GetDefinition retrieves one metric definition
+func (r *DefinitionRepository) GetDefinition(ctx context.Context, projectID uuid.UUID, name string) (*model.MetricDefinition, error) {
+	var d model.MetricDefinition
+	err := r.db.QueryRow(ctx,
+		`SELECT project_id, name, display_name, unit, goal, expected_min, expected_max, enforce_range, description, rank_reduce, keep_rank_values, cumulative, created_at, updated_at
+		 FROM metric_definitions
+		 WHERE project_id = $1 AND name = $2`,
+		projectID, name,
+	).Scan(&d.ProjectID, &d.Name, &d.DisplayName, &d.Unit, &d.Goal, &d.ExpectedMin, &d.ExpectedMax, &d.EnforceRange, &d.Description, &d.RankReduce, &d.KeepRankValues, &d.Cumulative, &d.CreatedAt, &d.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric definition: %w", err)
+	}
+	return &d, nil
+}
+
+// ListDefinitions retrieves the definitions of a project, or of all projects when projectID is nil
+func (r *DefinitionRepository) ListDefinitions(ctx context.Context, projectID *uuid.UUID) ([]model.MetricDefinition, error) {
+	q := newQuery(`SELECT project_id, name, display_name, unit, goal, expected_min, expected_max, enforce_range, description, rank_reduce, keep_rank_values, cumulative, created_at, updated_at
+	               FROM metric_definitions`)
+	addIfSet(q, " WHERE project_id = ?", projectID)
+	q.Add(" ORDER BY project_id, name")
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric definitions: %w", err)
+	}
+	defs, err := pgx.CollectRows(rows, scanner(func(d *model.MetricDefinition) []interface{} {
+		return []interface{}{&d.ProjectID, &d.Name, &d.DisplayName, &d.Unit, &d.Goal, &d.ExpectedMin, &d.ExpectedMax, &d.EnforceRange, &d.Description, &d.RankReduce, &d.KeepRankValues, &d.Cumulative, &d.CreatedAt, &d.UpdatedAt}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric definitions: %w", err)
+	}
+	return defs, nil
+}
+
+// DeleteDefinition removes a metric definition
+func (r *DefinitionRepository) DeleteDefinition(ctx context.Context, projectID uuid.UUID, name string) (bool, error) {
+	tag, err := r.db.Exec(ctx,
+		`DELETE FROM metric_definitions WHERE project_id = $1 AND name = $2`,
+		projectID, name,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete metric definition: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type EmbeddingRepository struct {
+	db     *pgxpool.Pool
+	limits RowLimits
+	logger *zap.Logger
+}
+
+func NewEmbeddingRepository(db *pgxpool.Pool, limits RowLimits, logger *zap.Logger) *EmbeddingRepository {
+	return &EmbeddingRepository{
+		db:     db,
+		limits: limits,
+		logger: logger,
+	}
+}
+
+// BatchWrite inserts multiple embeddings in a single transaction
+func (r *EmbeddingRepository) BatchWrite(ctx context.Context, embeddings []model.Embedding) error {
+	if len(embeddings) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, e := range embeddings {
+		batch.Queue(
+			`INSERT INTO run_embeddings (time, run_id, metric_name, step, key, dim, vector, metadata)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			e.Time, e.RunID, e.MetricName, e.Step, e.Key, e.Dim, e.Vector, e.Metadata,
+		)
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for i := 0; i < len(embeddings); i++ {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("failed to insert embedding %d: %w", i, err)
+		}
+	}
+
+	// The batch holds the connection until closed, so close it before committing
+	if err := br.Close(); err != nil {
+		return fmt.Errorf("failed to close batch: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Info("Embedding batch write completed", zap.Int("count", len(embeddings)))
+	return nil
+}
+
+// GetEmbeddings retrieves the embeddings of one metric ordered by step and key.
+// Vectors are only read when includeVectors is set.
+func (r *EmbeddingRepository) GetEmbeddings(ctx context.Context, runID uuid.UUID, metricName string, params model.EmbeddingQueryParams) ([]model.Embedding, error) {
+	vector := "NULL::real[]"
+	if params.IncludeVectors {
+		vector = "vector"
+	}
+	q := newQuery(fmt.Sprintf(`SELECT time, run_id, metric_name, step, key, dim, %s, metadata
+	                           FROM run_embeddings
+	                           WHERE run_id = ? AND metric_name = ?`, vector), runID, metricName)
+	addIfSet(q, " AND step = ?", params.Step)
+	addIfSet(q, " AND step >= ?", params.MinStep)
+	addIfSet(q, " AND step <= ?", params.MaxStep)
+	addIfNotZero(q, " AND key = ?", params.Key)
+	q.Add(" ORDER BY step ASC, key ASC, time ASC LIMIT ?", r.limits.resultLimit(params.Limit))
+
+	return r.queryEmbeddings(ctx, q)
+}
+
+// GetEmbedding retrieves the embedding logged for key at step, or at its
+// latest step when step is nil. It returns nil when there is none.
+func (r *EmbeddingRepository) GetEmbedding(ctx context.Context, runID uuid.UUID, metricName, key string, step *int64) (*model.Embedding, error) {
+	q := newQuery(`SELECT time, run_id, metric_name, step, key, dim, vector, metadata
+	               FROM run_embeddings
+	               WHERE run_id = ? AND metric_name = ? AND key = ?`, runID, metricName, key)
+	addIfSet(q, " AND step = ?", step)
+	q.Add(" ORDER BY step DESC NULLS LAST, time DESC LIMIT 1")
+
+	embeddings, err := r.queryEmbeddings(ctx, q)
+	if err != nil || len(embeddings) == 0 {
+		return nil, err
+	}
+	return &embeddings[0], nil
+}
+
+// ScanEmbeddings reads up to limit embeddings of dimension dim with their
+// vectors, across runIDs, as candidates of a nearest-neighbor search
+func (r *EmbeddingRepository) ScanEmbeddings(ctx context.Context, runIDs []uuid.UUID, metricName string, dim int, minStep, maxStep *int64, limit int) ([]model.Embedding, error) {
+	q := newQuery(`SELECT time, run_id, metric_name, step, key, dim, vector, metadata
+	               FROM run_embeddings
+	               WHERE run_id = ANY(?) AND metric_name = ? AND dim = ?`, runIDs, metricName, dim)
+	addIfSet(q, " AND step >= ?", minStep)
+	addIfSet(q, " AND step <= ?", maxStep)
+	// The newest embeddings are searched first when the scan is cut off
+	q.Add(" ORDER BY time DESC LIMIT ?", r.limits.resultLimit(limit))
+
+	return r.queryEmbeddings(ctx, q)
+}
+
+func (r *EmbeddingRepository) queryEmbeddings(ctx context.Context, q *queryBuilder) ([]model.Embedding, error) {
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query embeddings: %w", err)
+	}
+	embeddings, err := pgx.CollectRows(rows, scanner(func(e *model.Embedding) []interface{} {
+		return []interface{}{&e.Time, &e.RunID, &e.MetricName, &e.Step, &e.Key, &e.Dim, &e.Vector, &e.Metadata}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embeddings: %w", err)
+	}
+
+	return embeddings, r.limits.checkResultRows("embeddings", len(embeddings))
+}
+
+// ListEmbeddingSeries lists the embedding metrics logged in a run
+func (r *EmbeddingRepository) ListEmbeddingSeries(ctx context.Context, runID uuid.UUID) ([]model.EmbeddingSeries, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT metric_name, MAX(dim), COUNT(*), MIN(step), MAX(step), MAX(time)
+		 FROM run_embeddings
+		 WHERE run_id = $1
+		 GROUP BY metric_name
+		 ORDER BY metric_name
+		 LIMIT $2`,
+		runID, r.limits.resultLimit(0),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query embedding series: %w", err)
+	}
+	series, err := pgx.CollectRows(rows, scanner(func(s *model.EmbeddingSeries) []interface{} {
+		return []interface{}{&s.MetricName, &s.Dim, &s.Count, &s.FirstStep, &s.LastStep, &s.LastTime}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding series: %w", err)
+	}
+
+	return series, r.limits.checkResultRows("embedding series", len(series))
+}
+
+// GetStepCentroids averages the embeddings of each step of a metric, per
+// dimension, for the first limit steps in the range
+func (r *EmbeddingRepository) GetStepCentroids(ctx context.Context, runID uuid.UUID, metricName string, params model.EmbeddingDriftParams) ([]model.EmbeddingCentroid, error) {
+	// The filter applies to both the steps and their components
+	q := new(queryBuilder)
+	filter := fmt.Sprintf("WHERE run_id = %s AND metric_name = %s AND step IS NOT NULL", q.Arg(runID), q.Arg(metricName))
+	if params.MinStep != nil {
+		filter += " AND step >= " + q.Arg(*params.MinStep)
+	}
+	if params.MaxStep != nil {
+		filter += " AND step <= " + q.Arg(*params.MaxStep)
+	}
+	q.Add(fmt.Sprintf(`WITH steps AS (
+	     SELECT DISTINCT step FROM run_embeddings %[1]s ORDER BY step LIMIT ?
+	 ), components AS (
+	     SELECT e.step, e.dim, u.idx, AVG(u.v)::float8 AS mean, COUNT(*) AS n
+	     FROM run_embeddings e
+	     CROSS JOIN LATERAL unnest(e.vector) WITH ORDINALITY AS u(v, idx)
+	     %[1]s AND e.step IN (SELECT step FROM steps)
+	     GROUP BY e.step, e.dim, u.idx
+	 )
+	 SELECT step, dim, MAX(n), array_agg(mean ORDER BY idx)
+	 FROM components
+	 GROUP BY step, dim
+	 ORDER BY step, dim`, filter), params.Limit)
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query embedding centroids: %w", err)
+	}
+	centroids, err := pgx.CollectRows(rows, scanner(func(c *model.EmbeddingCentroid) []interface{} {
+		return []interface{}{&c.Step, &c.Dim, &c.Count, &c.Centroid}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding centroids: %w", err)
+	}
+	return centroids, nil
+}
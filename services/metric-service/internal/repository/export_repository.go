@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// latestRunEvents selects the latest event of each run
+const latestRunEvents = `SELECT DISTINCT ON (run_id) run_id, state, time
+	FROM run_events
+	ORDER BY run_id, time DESC, created_at DESC`
+
+// ExportRepository tracks the export of ended runs to object storage
+type ExportRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewExportRepository(db *pgxpool.Pool, logger *zap.Logger) *ExportRepository {
+	return &ExportRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// ListDueRuns returns up to limit runs that ended before endedBefore and
+// have no export of their latest end: never exported, ended again since, or
+// failed. Runs never tried come first, then those tried longest ago.
+func (r *ExportRepository) ListDueRuns(ctx context.Context, endedBefore time.Time, limit int) ([]model.EndedRun, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT e.run_id, e.state, e.time
+		 FROM (`+latestRunEvents+`) e
+		 LEFT JOIN run_exports x USING (run_id)
+		 WHERE e.state = ANY($1) AND e.time < $2
+		   AND (x.run_id IS NULL OR x.ended_at < e.time OR x.status = $3)
+		 ORDER BY x.updated_at NULLS FIRST, e.time
+		 LIMIT $4`,
+		model.RunEndStates, endedBefore, model.RunExportFailed, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runs due for export: %w", err)
+	}
+	runs, err := pgx.CollectRows(rows, scanner(endedRunTargets))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runs due for export: %w", err)
+	}
+	return runs, nil
+}
+
+// GetEndedRun returns how a run ended, or nil when its latest state is not
+// one of model.RunEndStates
+func (r *ExportRepository) GetEndedRun(ctx context.Context, runID uuid.UUID) (*model.EndedRun, error) {
+	var run model.EndedRun
+	err := r.db.QueryRow(ctx,
+		`SELECT run_id, state, time
+		 FROM run_events
+		 WHERE run_id = $1
+		 ORDER BY time DESC, created_at DESC
+		 LIMIT 1`,
+		runID,
+	).Scan(endedRunTargets(&run)...)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query run state: %w", err)
+	}
+	if !slices.Contains(model.RunEndStates, run.State) {
+		return nil, nil
+	}
+	return &run, nil
+}
+
+// RecordRunExport saves the outcome of an export as the run's latest
+func (r *ExportRepository) RecordRunExport(ctx context.Context, e *model.RunExport) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO run_exports (run_id, state, ended_at, status, format, key, rows, size, error, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		 ON CONFLICT (run_id) DO UPDATE SET
+		     state = EXCLUDED.state,
+		     ended_at = EXCLUDED.ended_at,
+		     status = EXCLUDED.status,
+		     format = EXCLUDED.format,
+		     key = EXCLUDED.key,
+		     rows = EXCLUDED.rows,
+		     size = EXCLUDED.size,
+		     error = EXCLUDED.error,
+		     updated_at = EXCLUDED.updated_at
+		 RETURNING updated_at`,
+		e.RunID, e.State, e.EndedAt, e.Status, e.Format, e.Key, e.Rows, e.Size, e.Error,
+	).Scan(&e.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record export of run %s: %w", e.RunID, err)
+	}
+	return nil
+}
+
+// ListRunExports returns the latest exports of runs, most recent first
+func (r *ExportRepository) ListRunExports(ctx context.Context, params model.RunExportQueryParams) ([]model.RunExport, error) {
+	q := newQuery(`SELECT run_id, state, ended_at, status, format, key, rows, size, error, updated_at
+	               FROM run_exports`)
+	addIfNotZero(q, " WHERE status = ?", params.Status)
+	q.Add(" ORDER BY updated_at DESC")
+	addIfNotZero(q, " LIMIT ?", params.Limit)
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query run exports: %w", err)
+	}
+	exports, err := pgx.CollectRows(rows, scanner(func(e *model.RunExport) []interface{} {
+		return []interface{}{&e.RunID, &e.State, &e.EndedAt, &e.Status, &e.Format, &e.Key, &e.Rows, &e.Size, &e.Error, &e.UpdatedAt}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run exports: %w", err)
+	}
+	return exports, nil
+}
+
+func endedRunTargets(run *model.EndedRun) []interface{} {
+	return []interface{}{&run.RunID, &run.State, &run.EndedAt}
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type GPURepository struct {
+	db     *pgxpool.Pool
+	limits RowLimits
+	logger *zap.Logger
+}
+
+func NewGPURepository(db *pgxpool.Pool, limits RowLimits, logger *zap.Logger) *GPURepository {
+	return &GPURepository{
+		db:     db,
+		limits: limits,
+		logger: logger,
+	}
+}
+
+// BatchWrite inserts multiple GPU samples
+func (r *GPURepository) BatchWrite(ctx context.Context, metrics []model.GPUMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	order := timeOrder(len(metrics), func(i int) (uuid.UUID, time.Time) { return metrics[i].RunID, metrics[i].Time })
+	batch := &pgx.Batch{}
+	for _, i := range order {
+		m := metrics[i]
+		batch.Queue(
+			`INSERT INTO gpu_metrics (time, run_id, node_id, device_index, device_uuid, device_name, utilization,
+			                          memory_utilization, memory_used_mb, memory_total_mb, temperature_c, power_w, metadata)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+			m.Time, m.RunID, m.NodeID, m.DeviceIndex, m.DeviceUUID, m.DeviceName, m.Utilization,
+			m.MemoryUtilization, m.MemoryUsedMB, m.MemoryTotalMB, m.TemperatureC, m.PowerW, m.Metadata,
+		)
+	}
+
+	br := r.db.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for _, i := range order {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("failed to insert GPU metric %d: %w", i, err)
+		}
+	}
+
+	r.logger.Info("GPU metrics batch write completed", zap.Int("count", len(metrics)))
+	return nil
+}
+
+// GetGPUMetrics retrieves the latest samples of each device of a run, ordered
+// by node, device and then time. limit applies per device.
+func (r *GPURepository) GetGPUMetrics(ctx context.Context, runID uuid.UUID, params model.GPUMetricQueryParams) ([]model.GPUMetric, error) {
+	q := newQuery(`SELECT time, run_id, node_id, device_index, device_uuid, device_name, utilization,
+	                      memory_utilization, memory_used_mb, memory_total_mb, temperature_c, power_w, metadata
+	               FROM (
+	                 SELECT *, ROW_NUMBER() OVER (PARTITION BY node_id, device_uuid, device_index ORDER BY time DESC) AS rn
+	                 FROM gpu_metrics
+	                 WHERE run_id = ?`, runID)
+	addIfSet(q, " AND time >= ?", params.StartTime)
+	addIfSet(q, " AND time <= ?", params.EndTime)
+	addIfNotZero(q, " AND node_id = ?", params.NodeID)
+	addIfSet(q, " AND device_index = ?", params.DeviceIndex)
+	q.Add(`
+	               ) ranked
+	               WHERE rn <= ?
+	               ORDER BY node_id, device_index, device_uuid, time ASC
+	               LIMIT ?`, params.Limit, r.limits.resultLimit(0))
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query GPU metrics: %w", err)
+	}
+	metrics, err := pgx.CollectRows(rows, scanner(func(m *model.GPUMetric) []interface{} {
+		return []interface{}{&m.Time, &m.RunID, &m.NodeID, &m.DeviceIndex, &m.DeviceUUID, &m.DeviceName, &m.Utilization,
+			&m.MemoryUtilization, &m.MemoryUsedMB, &m.MemoryTotalMB, &m.TemperatureC, &m.PowerW, &m.Metadata}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GPU metrics: %w", err)
+	}
+	return metrics, r.limits.checkResultRows("GPU metrics", len(metrics))
+}
+
+// GetGPUSummary aggregates each device of a run over its lifetime, reading
+// at most the scan cap of readings
+func (r *GPURepository) GetGPUSummary(ctx context.Context, runID uuid.UUID) ([]model.GPUDeviceSummary, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT node_id, device_index, device_uuid, MAX(device_name), COUNT(*),
+		        AVG(utilization), MAX(utilization), MAX(memory_used_mb), MAX(memory_total_mb),
+		        MAX(temperature_c), AVG(power_w), MAX(power_w), MIN(time), MAX(time)
+		 FROM (
+		   SELECT * FROM gpu_metrics
+		   WHERE run_id = $1
+		   LIMIT $2
+		 ) g
+		 GROUP BY node_id, device_index, device_uuid
+		 ORDER BY node_id, device_index, device_uuid`,
+		runID, r.limits.scanLimit(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query GPU summary: %w", err)
+	}
+	summaries, err := pgx.CollectRows(rows, scanner(func(s *model.GPUDeviceSummary) []interface{} {
+		return []interface{}{&s.NodeID, &s.DeviceIndex, &s.DeviceUUID, &s.DeviceName, &s.Count,
+			&s.AvgUtilization, &s.MaxUtilization, &s.MaxMemoryUsedMB, &s.MemoryTotalMB,
+			&s.MaxTemperatureC, &s.AvgPowerW, &s.MaxPowerW, &s.FirstTime, &s.LastTime}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GPU summary: %w", err)
+	}
+
+	var scanned int64
+	for _, s := range summaries {
+		scanned += s.Count
+	}
+	return summaries, r.limits.checkScannedRows("GPU readings", scanned)
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type GroupRepository struct {
+	db     *pgxpool.Pool
+	limits RowLimits
+	logger *zap.Logger
+}
+
+func NewGroupRepository(db *pgxpool.Pool, limits RowLimits, logger *zap.Logger) *GroupRepository {
+	return &GroupRepository{
+		db:     db,
+		limits: limits,
+		logger: logger,
+	}
+}
+
+// SetRunGroup creates or replaces the group of a run
+func (r *GroupRepository) SetRunGroup(ctx context.Context, g *model.RunGroup) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO run_groups (run_id, group_name, job_type)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (run_id) DO UPDATE SET
+		   group_name = EXCLUDED.group_name,
+		   job_type = EXCLUDED.job_type,
+		   updated_at = NOW()
+		 RETURNING updated_at`,
+		g.RunID, g.Group, g.JobType,
+	).Scan(&g.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert run group: %w", err)
+	}
+	return nil
+}
+
+// GetRunGroup retrieves the group of a run
+func (r *GroupRepository) GetRunGroup(ctx context.Context, runID uuid.UUID) (*model.RunGroup, error) {
+	var g model.RunGroup
+	err := r.db.QueryRow(ctx,
+		`SELECT run_id, group_name, job_type, updated_at FROM run_groups WHERE run_id = $1`,
+		runID,
+	).Scan(&g.RunID, &g.Group, &g.JobType, &g.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query run group: %w", err)
+	}
+	return &g, nil
+}
+
+// DeleteRunGroup removes a run from its group, reporting whether it had one
+func (r *GroupRepository) DeleteRunGroup(ctx context.Context, runID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM run_groups WHERE run_id = $1`, runID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete run group: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListGroups lists groups by name with their job types and run counts
+func (r *GroupRepository) ListGroups(ctx context.Context, params model.GroupQueryParams) ([]model.GroupInfo, error) {
+	q := newQuery(`SELECT group_name,
+	                      ARRAY_REMOVE(ARRAY_AGG(DISTINCT job_type ORDER BY job_type), ''),
+	                      COUNT(*)
+	               FROM run_groups`)
+	addIfNotZero(q, " WHERE job_type = ?", params.JobType)
+	q.Add(" GROUP BY group_name ORDER BY group_name")
+	if params.Limit > 0 {
+		q.Add(" LIMIT ?", params.Limit)
+	}
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups: %w", err)
+	}
+	groups, err := pgx.CollectRows(rows, scanner(func(g *model.GroupInfo) []interface{} {
+		return []interface{}{&g.Group, &g.JobTypes, &g.RunCount}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read groups: %w", err)
+	}
+	return groups, nil
+}
+
+// GetGroupMetric combines a metric per step across the runs of a group,
+// averaging each run's values at a step first
+func (r *GroupRepository) GetGroupMetric(ctx context.Context, group, metricName string, params model.GroupMetricQueryParams) ([]model.AggregatePoint, error) {
+	samples := newQuery(`SELECT MAX(m.time) AS time, m.step, AVG(m.value) AS value, COUNT(*) AS scanned
+	                     FROM (
+	                       SELECT m.time, m.run_id, m.step, m.value
+	                       FROM metrics m
+	                       JOIN run_groups g ON g.run_id = m.run_id
+	                       WHERE g.group_name = ? AND m.metric_name = ? AND m.step IS NOT NULL
+	                         AND m.value IS NOT NULL`, group, metricName)
+	addIfNotZero(samples, " AND g.job_type = ?", params.JobType)
+	addIfSet(samples, " AND m.step >= ?", params.MinStep)
+	addIfSet(samples, " AND m.step <= ?", params.MaxStep)
+	samples.Add(" LIMIT ?) m GROUP BY m.run_id, m.step", r.limits.scanLimit())
+
+	return queryReduced(ctx, r.db, r.limits, samples, "step", params.Reduce, params.Limit)
+}
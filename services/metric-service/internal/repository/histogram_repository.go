@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type HistogramRepository struct {
+	db     *pgxpool.Pool
+	limits RowLimits
+	logger *zap.Logger
+}
+
+func NewHistogramRepository(db *pgxpool.Pool, limits RowLimits, logger *zap.Logger) *HistogramRepository {
+	return &HistogramRepository{
+		db:     db,
+		limits: limits,
+		logger: logger,
+	}
+}
+
+// BatchWrite inserts multiple histograms in a single transaction
+func (r *HistogramRepository) BatchWrite(ctx context.Context, histograms []model.HistogramMetric) error {
+	if len(histograms) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, h := range histograms {
+		batch.Queue(
+			`INSERT INTO metric_histograms (time, run_id, metric_name, step, bin_edges, counts, metadata)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			h.Time, h.RunID, h.MetricName, h.Step, h.BinEdges, h.Counts, h.Metadata,
+		)
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for i := 0; i < len(histograms); i++ {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("failed to insert histogram %d: %w", i, err)
+		}
+	}
+
+	// The batch holds the connection until closed, so close it before committing
+	if err := br.Close(); err != nil {
+		return fmt.Errorf("failed to close batch: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Info("Histogram batch write completed", zap.Int("count", len(histograms)))
+	return nil
+}
+
+// GetHistogramHistory retrieves the per-step distributions of one histogram metric
+func (r *HistogramRepository) GetHistogramHistory(ctx context.Context, runID uuid.UUID, metricName string, params model.HistogramQueryParams) ([]model.HistogramMetric, error) {
+	q := newQuery(`SELECT time, run_id, metric_name, step, bin_edges, counts, metadata
+	               FROM metric_histograms
+	               WHERE run_id = ? AND metric_name = ?`, runID, metricName)
+	addIfSet(q, " AND step >= ?", params.MinStep)
+	addIfSet(q, " AND step <= ?", params.MaxStep)
+	q.Add(" ORDER BY step ASC, time ASC")
+	if params.Limit > 0 {
+		q.Add(" LIMIT ?", params.Limit)
+	}
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query histograms: %w", err)
+	}
+	histograms, err := pgx.CollectRows(rows, scanner(func(h *model.HistogramMetric) []interface{} {
+		return []interface{}{&h.Time, &h.RunID, &h.MetricName, &h.Step, &h.BinEdges, &h.Counts, &h.Metadata}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read histograms: %w", err)
+	}
+	return histograms, nil
+}
+
+// ListHistogramSeries lists the histogram metrics logged in a run
+func (r *HistogramRepository) ListHistogramSeries(ctx context.Context, runID uuid.UUID) ([]model.HistogramSeries, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT metric_name, COUNT(*), MIN(step), MAX(step), MAX(time)
+		 FROM metric_histograms
+		 WHERE run_id = $1
+		 GROUP BY metric_name
+		 ORDER BY metric_name
+		 LIMIT $2`,
+		runID, r.limits.resultLimit(0),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query histogram series: %w", err)
+	}
+	series, err := pgx.CollectRows(rows, scanner(func(s *model.HistogramSeries) []interface{} {
+		return []interface{}{&s.MetricName, &s.Count, &s.FirstStep, &s.LastStep, &s.LastTime}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read histogram series: %w", err)
+	}
+
+	return series, r.limits.checkResultRows("histogram series", len(series))
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// LeaderboardRepository stores project leaderboard objectives and the
+// standing of each run. Entries carry the goal and mode of their leaderboard
+// so that ingest can merge new values without reading the configuration.
+type LeaderboardRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewLeaderboardRepository(db *pgxpool.Pool, logger *zap.Logger) *LeaderboardRepository {
+	return &LeaderboardRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SetLeaderboard creates or replaces the objective of a project and rebuilds
+// its entries once from the metric history of the project's runs. It returns
+// the number of runs ranked.
+func (r *LeaderboardRepository) SetLeaderboard(ctx context.Context, cfg *model.LeaderboardConfig) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO project_leaderboards (project_id, metric_name, goal, mode)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (project_id) DO UPDATE SET
+		   metric_name = EXCLUDED.metric_name,
+		   goal = EXCLUDED.goal,
+		   mode = EXCLUDED.mode,
+		   updated_at = NOW()
+		 RETURNING created_at, updated_at`,
+		cfg.ProjectID, cfg.MetricName, cfg.Goal, cfg.Mode,
+	).Scan(&cfg.CreatedAt, &cfg.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to upsert leaderboard: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM leaderboard_entries WHERE project_id = $1`, cfg.ProjectID); err != nil {
+		return 0, fmt.Errorf("failed to clear leaderboard entries: %w", err)
+	}
+
+	order := "m.time DESC"
+	if cfg.Mode != model.LeaderboardModeFinal {
+		order = "m.value ASC, m.time ASC"
+		if cfg.Goal == model.GoalMax {
+			order = "m.value DESC, m.time ASC"
+		}
+	}
+
+	tag, err := tx.Exec(ctx, fmt.Sprintf(
+		`INSERT INTO leaderboard_entries (project_id, run_id, goal, mode, value, step, time, updated_at)
+		 SELECT DISTINCT ON (m.run_id) $1, m.run_id, $3, $4, m.value, m.step, m.time, NOW()
+		 FROM metrics m
+		 JOIN run_projects p ON p.run_id = m.run_id
+		 WHERE p.project_id = $1 AND m.metric_name = $2 AND m.value <> 'NaN'::float8
+		   AND m.value <> 'Infinity'::float8 AND m.value <> '-Infinity'::float8
+		 ORDER BY m.run_id, %s`, order),
+		cfg.ProjectID, cfg.MetricName, cfg.Goal, cfg.Mode,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to backfill leaderboard: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// GetLeaderboard retrieves the objective of a project
+func (r *LeaderboardRepository) GetLeaderboard(ctx context.Context, projectID uuid.UUID) (*model.LeaderboardConfig, error) {
+	var cfg model.LeaderboardConfig
+	err := r.db.QueryRow(ctx,
+		`SELECT project_id, metric_name, goal, mode, created_at, updated_at
+		 FROM project_leaderboards WHERE project_id = $1`,
+		projectID,
+	).Scan(&cfg.ProjectID, &cfg.MetricName, &cfg.Goal, &cfg.Mode, &cfg.CreatedAt, &cfg.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leaderboard: %w", err)
+	}
+	return &cfg, nil
+}
+
+// DeleteLeaderboard removes the objective and entries of a project, reporting
+// whether it had a leaderboard
+func (r *LeaderboardRepository) DeleteLeaderboard(ctx context.Context, projectID uuid.UUID) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM leaderboard_entries WHERE project_id = $1`, projectID); err != nil {
+		return false, fmt.Errorf("failed to delete leaderboard entries: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM project_leaderboards WHERE project_id = $1`, projectID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete leaderboard: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListObjectiveNames lists the metric names any leaderboard ranks by
+func (r *LeaderboardRepository) ListObjectiveNames(ctx context.Context) ([]string, error) {
+	rows, err := r.db.Query(ctx, `SELECT DISTINCT metric_name FROM project_leaderboards`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leaderboard objectives: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leaderboard objectives: %w", err)
+	}
+	return names, nil
+}
+
+// UpdateEntries merges per-batch summaries into the leaderboards of the runs'
+// projects. A summary only applies to leaderboards ranking by its metric under
+// its goal; final entries only move forward in time and best entries only
+// improve.
+func (r *LeaderboardRepository) UpdateEntries(ctx context.Context, summaries []model.RunMetricSummary) error {
+	if len(summaries) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, s := range summaries {
+		batch.Queue(
+			`INSERT INTO leaderboard_entries (project_id, run_id, goal, mode, value, step, time, updated_at)
+			 SELECT l.project_id, p.run_id, l.goal, l.mode,
+			        CASE WHEN l.mode = 'final' THEN $4::float8 ELSE $7::float8 END,
+			        CASE WHEN l.mode = 'final' THEN $5::bigint ELSE $8::bigint END,
+			        CASE WHEN l.mode = 'final' THEN $6::timestamptz ELSE $9::timestamptz END,
+			        NOW()
+			 FROM run_projects p
+			 JOIN project_leaderboards l ON l.project_id = p.project_id AND l.metric_name = $2 AND l.goal = $3
+			 WHERE p.run_id = $1
+			 ON CONFLICT (project_id, run_id) DO UPDATE SET
+			   value = EXCLUDED.value,
+			   step = EXCLUDED.step,
+			   time = EXCLUDED.time,
+			   updated_at = NOW()
+			 WHERE CASE
+			   WHEN EXCLUDED.mode = 'final' THEN EXCLUDED.time >= leaderboard_entries.time
+			   WHEN EXCLUDED.goal = 'max' THEN EXCLUDED.value > leaderboard_entries.value
+			   ELSE EXCLUDED.value < leaderboard_entries.value
+			 END`,
+			s.RunID, s.MetricName, s.Goal, s.FinalValue, s.FinalStep, s.FinalTime, s.BestValue, s.BestStep, s.BestTime,
+		)
+	}
+
+	br := r.db.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for i := 0; i < len(summaries); i++ {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("failed to update leaderboard entry %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// ListEntries ranks the runs of a project by its objective, returning one
+// page of entries and the number of entries matching the filters. Runs that
+// have since moved to another project are left out.
+func (r *LeaderboardRepository) ListEntries(ctx context.Context, cfg *model.LeaderboardConfig, experimentID *uuid.UUID, params model.LeaderboardQueryParams) ([]model.LeaderboardEntry, int64, error) {
+	q := newQuery(`FROM leaderboard_entries e
+	               JOIN run_projects p ON p.run_id = e.run_id AND p.project_id = e.project_id
+	               LEFT JOIN run_groups g ON g.run_id = e.run_id
+	               WHERE e.project_id = ?`, cfg.ProjectID)
+	addIfSet(q, " AND p.experiment_id = ?", experimentID)
+	addIfNotZero(q, " AND g.group_name = ?", params.Group)
+	addIfNotZero(q, " AND g.job_type = ?", params.JobType)
+
+	if err := q.Err(); err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := r.db.QueryRow(ctx, "SELECT COUNT(*) "+q.String(), q.Args()...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count leaderboard entries: %w", err)
+	}
+
+	direction := "ASC"
+	if cfg.Goal == model.GoalMax {
+		direction = "DESC"
+	}
+
+	q.Wrap(`SELECT e.run_id, p.experiment_id, COALESCE(g.group_name, ''), COALESCE(g.job_type, ''),
+	               e.value, e.step, e.time, e.updated_at
+	        `)
+	q.Add(fmt.Sprintf(`
+	        ORDER BY e.value %s, e.time ASC, e.run_id
+	        OFFSET ? LIMIT ?`, direction), params.Offset, params.Limit)
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query leaderboard entries: %w", err)
+	}
+	entries, err := pgx.CollectRows(rows, scanner(func(e *model.LeaderboardEntry) []interface{} {
+		return []interface{}{&e.RunID, &e.ExperimentID, &e.Group, &e.JobType, &e.Value, &e.Step, &e.Time, &e.UpdatedAt}
+	}))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read leaderboard entries: %w", err)
+	}
+	for i := range entries {
+		entries[i].Rank = params.Offset + i + 1
+	}
+
+	return entries, total, nil
+}
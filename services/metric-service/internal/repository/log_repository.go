@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type LogRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewLogRepository(db *pgxpool.Pool, logger *zap.Logger) *LogRepository {
+	return &LogRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// BatchWrite appends log lines to a run
+func (r *LogRepository) BatchWrite(ctx context.Context, lines []model.LogLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	order := timeOrder(len(lines), func(i int) (uuid.UUID, time.Time) { return lines[i].RunID, lines[i].Time })
+	batch := &pgx.Batch{}
+	for _, i := range order {
+		l := lines[i]
+		batch.Queue(
+			`INSERT INTO run_logs (time, run_id, level, line) VALUES ($1, $2, $3, $4)`,
+			l.Time, l.RunID, l.Level, l.Line,
+		)
+	}
+
+	br := r.db.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for _, i := range order {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("failed to insert log line %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// GetRunLogs retrieves log lines of a run in time order. levels restricts the
+// result to the given levels when non-empty.
+func (r *LogRepository) GetRunLogs(ctx context.Context, runID uuid.UUID, params model.LogQueryParams, levels []string) ([]model.LogLine, error) {
+	q := newQuery(`SELECT time, run_id, level, line
+	               FROM run_logs
+	               WHERE run_id = ?`, runID)
+	addIfSet(q, " AND time >= ?", params.StartTime)
+	addIfSet(q, " AND time <= ?", params.EndTime)
+	if len(levels) > 0 {
+		q.Add(" AND level = ANY(?)", levels)
+	}
+	addIfNotZero(q, " AND strpos(line, ?) > 0", params.Contains)
+	q.Add(" ORDER BY time ASC")
+	if params.Limit > 0 {
+		q.Add(" LIMIT ?", params.Limit)
+	}
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logs: %w", err)
+	}
+	lines, err := pgx.CollectRows(rows, scanLogLine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logs: %w", err)
+	}
+	return lines, nil
+}
+
+// GetLatestLogs retrieves the last lines of a run in time order; nil levels means all levels
+func (r *LogRepository) GetLatestLogs(ctx context.Context, runID uuid.UUID, limit int, levels []string) ([]model.LogLine, error) {
+	query := `SELECT time, run_id, level, line FROM (
+	            SELECT time, run_id, level, line
+	            FROM run_logs
+	            WHERE run_id = $1 AND ($2::text[] IS NULL OR level = ANY($2))
+	            ORDER BY time DESC
+	            LIMIT $3
+	          ) latest
+	          ORDER BY time ASC`
+
+	rows, err := r.db.Query(ctx, query, runID, levels, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest logs: %w", err)
+	}
+	lines, err := pgx.CollectRows(rows, scanLogLine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read latest logs: %w", err)
+	}
+	return lines, nil
+}
+
+var scanLogLine = scanner(func(l *model.LogLine) []interface{} {
+	return []interface{}{&l.Time, &l.RunID, &l.Level, &l.Line}
+})
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// RetentionTables are the hypertables whose old chunks retention can drop
+var RetentionTables = []string{
+	"metrics", "system_metrics", "gpu_metrics", "metric_histograms", "run_logs", "metric_anomalies", "run_embeddings", "metric_tables",
+}
+
+// MaintenanceRepository runs the operator tasks of the admin CLI
+type MaintenanceRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewMaintenanceRepository(db *pgxpool.Pool, logger *zap.Logger) *MaintenanceRepository {
+	return &MaintenanceRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// DropChunks drops the chunks of a hypertable holding only data older than
+// olderThan and returns their names. With dryRun the chunks are only listed.
+// The table must be one of RetentionTables.
+func (r *MaintenanceRepository) DropChunks(ctx context.Context, table string, olderThan time.Duration, dryRun bool) ([]string, error) {
+	fn := "drop_chunks"
+	if dryRun {
+		fn = "show_chunks"
+	}
+
+	rows, err := r.db.Query(ctx,
+		fmt.Sprintf(`SELECT chunk::text FROM %s($1::regclass, older_than => $2::interval) AS chunk`, fn),
+		table, olderThan,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s for %s: %w", fn, table, err)
+	}
+	chunks, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunks: %w", err)
+	}
+	return chunks, nil
+}
+
+// ListSummarizedRuns lists every run with a metric summary
+func (r *MaintenanceRepository) ListSummarizedRuns(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `SELECT DISTINCT run_id FROM run_summary ORDER BY run_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query summarized runs: %w", err)
+	}
+	runIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read summarized runs: %w", err)
+	}
+	return runIDs, nil
+}
+
+// StreamRunMetrics passes every metric of a run to fn in time order, a
+// batch at a time through a cursor, stopping at the first error fn returns
+func (r *MaintenanceRepository) StreamRunMetrics(ctx context.Context, runID uuid.UUID, fn func(model.Metric) error) (int64, error) {
+	return streamCursor(ctx, r.db,
+		`SELECT `+metricColumns+`
+		 FROM metrics
+		 WHERE run_id = $1
+		 ORDER BY time, metric_name`,
+		[]interface{}{runID}, scanMetric, fn,
+	)
+}
+
+// StreamMetricsBetween passes every metric with a time in [from, to) to fn,
+// with the project of its run, ordered by project and time. Runs without a
+// project come first.
+func (r *MaintenanceRepository) StreamMetricsBetween(ctx context.Context, from, to time.Time, fn func(model.Metric) error) (int64, error) {
+	return streamCursor(ctx, r.db,
+		`SELECT `+metricColumns+`, project_id
+		 FROM metrics LEFT JOIN run_projects USING (run_id)
+		 WHERE time >= $1 AND time < $2
+		 ORDER BY project_id NULLS FIRST, time, run_id, metric_name`,
+		[]interface{}{from, to}, scanProjectMetric, fn,
+	)
+}
+
+// scanProjectMetric reads a row of metricColumns followed by the run's project
+var scanProjectMetric = scanner(func(m *model.Metric) []interface{} {
+	return append(metricFields(m), &m.ProjectID)
+})
+
+// FirstMetricTime returns the time of the oldest metric, or nil without metrics
+func (r *MaintenanceRepository) FirstMetricTime(ctx context.Context) (*time.Time, error) {
+	var first *time.Time
+	if err := r.db.QueryRow(ctx, `SELECT MIN(time) FROM metrics`).Scan(&first); err != nil {
+		return nil, fmt.Errorf("failed to query first metric time: %w", err)
+	}
+	return first, nil
+}
+
+// DeleteRunMetrics removes the raw training metrics of a run, including
+// staged rank values, and returns the number of metric rows removed.
+// Summaries, leaderboard entries and other run records are kept.
+func (r *MaintenanceRepository) DeleteRunMetrics(ctx context.Context, runID uuid.UUID) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM metric_rank_values WHERE run_id = $1`, runID); err != nil {
+		return 0, fmt.Errorf("failed to delete rank values: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM metrics WHERE run_id = $1`, runID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete metrics: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM run_metric_names WHERE run_id = $1`, runID); err != nil {
+		return 0, fmt.Errorf("failed to delete metric names: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// ApplySchema executes a multi-statement SQL script, such as the idempotent
+// init-timescaledb.sql schema
+func (r *MaintenanceRepository) ApplySchema(ctx context.Context, script string) error {
+	// Without arguments pgx uses the simple protocol, which allows several statements
+	if _, err := r.db.Exec(ctx, script); err != nil {
+		return fmt.Errorf("failed to apply schema: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type MediaRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewMediaRepository(db *pgxpool.Pool, logger *zap.Logger) *MediaRepository {
+	return &MediaRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CreateMedia records the metadata of a stored media item
+func (r *MediaRepository) CreateMedia(ctx context.Context, m *model.MediaItem) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO run_media (id, run_id, step, key, kind, content_type, size_bytes, digest, object_key, caption, metadata)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING created_at`,
+		m.ID, m.RunID, m.Step, m.Key, m.Kind, m.ContentType, m.SizeBytes, m.Digest, m.ObjectKey, m.Caption, m.Metadata,
+	).Scan(&m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert media: %w", err)
+	}
+	return nil
+}
+
+// GetMedia retrieves one media item of a run
+func (r *MediaRepository) GetMedia(ctx context.Context, runID, mediaID uuid.UUID) (*model.MediaItem, error) {
+	var m model.MediaItem
+	err := r.db.QueryRow(ctx,
+		`SELECT id, run_id, step, key, kind, content_type, size_bytes, digest, object_key, caption, metadata, created_at
+		 FROM run_media
+		 WHERE run_id = $1 AND id = $2`,
+		runID, mediaID,
+	).Scan(&m.ID, &m.RunID, &m.Step, &m.Key, &m.Kind, &m.ContentType, &m.SizeBytes, &m.Digest, &m.ObjectKey, &m.Caption, &m.Metadata, &m.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query media: %w", err)
+	}
+	return &m, nil
+}
+
+// GetRunMedia lists the media items of a run ordered by step
+func (r *MediaRepository) GetRunMedia(ctx context.Context, runID uuid.UUID, params model.MediaQueryParams) ([]model.MediaItem, error) {
+	q := newQuery(`SELECT id, run_id, step, key, kind, content_type, size_bytes, digest, object_key, caption, metadata, created_at
+	               FROM run_media
+	               WHERE run_id = ?`, runID)
+	addIfNotZero(q, " AND key = ?", params.Key)
+	addIfNotZero(q, " AND kind = ?", params.Kind)
+	addIfSet(q, " AND step >= ?", params.MinStep)
+	addIfSet(q, " AND step <= ?", params.MaxStep)
+	q.Add(" ORDER BY step NULLS FIRST, key, created_at")
+	addIfNotZero(q, " LIMIT ?", params.Limit)
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query media: %w", err)
+	}
+	items, err := pgx.CollectRows(rows, scanner(func(m *model.MediaItem) []interface{} {
+		return []interface{}{&m.ID, &m.RunID, &m.Step, &m.Key, &m.Kind, &m.ContentType, &m.SizeBytes, &m.Digest, &m.ObjectKey, &m.Caption, &m.Metadata, &m.CreatedAt}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// metricNameRows accumulates the run_metric_names rows of a written batch:
+// when each of its series was first and last written, and how many points
+// were added to it
+type metricNameRows map[runMetric]*metricNameRow
+
+type runMetric struct {
+	runID uuid.UUID
+	name  string
+}
+
+type metricNameRow struct {
+	first, last time.Time
+	count       int64
+}
+
+// add records a value of a series written at t, adding points to its count
+func (rows metricNameRows) add(runID uuid.UUID, name string, t time.Time, points int64) {
+	key := runMetric{runID: runID, name: name}
+	row, ok := rows[key]
+	if !ok {
+		rows[key] = &metricNameRow{first: t, last: t, count: points}
+		return
+	}
+	if t.Before(row.first) {
+		row.first = t
+	}
+	if t.After(row.last) {
+		row.last = t
+	}
+	row.count += points
+}
+
+// upsert merges the rows into run_metric_names as part of tx. Rows are
+// written in key order, so concurrent batches lock them in the same order.
+func (rows metricNameRows) upsert(ctx context.Context, tx pgx.Tx) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	keys := make([]runMetric, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if c := bytes.Compare(keys[i].runID[:], keys[j].runID[:]); c != 0 {
+			return c < 0
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	runIDs := make([]uuid.UUID, len(keys))
+	names := make([]string, len(keys))
+	firsts := make([]time.Time, len(keys))
+	lasts := make([]time.Time, len(keys))
+	counts := make([]int64, len(keys))
+	for i, key := range keys {
+		row := rows[key]
+		runIDs[i], names[i], firsts[i], lasts[i], counts[i] = key.runID, key.name, row.first, row.last, row.count
+	}
+
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO run_metric_names (run_id, metric_name, first_seen, last_seen, count)
+		 SELECT * FROM unnest($1::uuid[], $2::text[], $3::timestamptz[], $4::timestamptz[], $5::bigint[])
+		 ON CONFLICT (run_id, metric_name) DO UPDATE SET
+		   first_seen = LEAST(run_metric_names.first_seen, EXCLUDED.first_seen),
+		   last_seen  = GREATEST(run_metric_names.last_seen, EXCLUDED.last_seen),
+		   count      = run_metric_names.count + EXCLUDED.count`,
+		runIDs, names, firsts, lasts, counts,
+	); err != nil {
+		return fmt.Errorf("failed to record metric names: %w", err)
+	}
+	return nil
+}
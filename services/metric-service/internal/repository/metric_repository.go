@@ -2,29 +2,127 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 
 	"github.com/wanllmdb/metric-service/internal/model"
 )
 
+// metricColumns are the columns read into a model.Metric by scanMetric.
+// String and bool values have no numeric value.
+const metricColumns = `time, run_id, metric_name, step, COALESCE(value, 0) AS value, node_id, rank, metadata,
+	COALESCE(value_type, '') AS value_type, text_value`
+
+// finiteValue holds for metric values other than NaN and the infinities
+const finiteValue = `value NOT IN ('NaN'::float8, 'Infinity'::float8, '-Infinity'::float8)`
+
+// metricStatsColumns aggregate the values of a metric into MetricStats; NaN
+// and infinities are left out of the value statistics and counted apart, and
+// string and bool values, which have no numeric value, are left out altogether
+const metricStatsColumns = `COUNT(value) as count,
+	            MIN(value) FILTER (WHERE ` + finiteValue + `) as min_value,
+	            MAX(value) FILTER (WHERE ` + finiteValue + `) as max_value,
+	            AVG(value) FILTER (WHERE ` + finiteValue + `) as avg_value,
+	            STDDEV(value) FILTER (WHERE ` + finiteValue + `) as std_dev,
+	            COUNT(*) FILTER (WHERE NOT ` + finiteValue + `) as non_finite_count,
+	            MIN(time) FILTER (WHERE NOT ` + finiteValue + `) as first_non_finite_time,
+	            MIN(time) as first_time,
+	            MAX(time) as last_time`
+
+// Hot statements, prepared on every connection at startup; see PreparedStatements
+const (
+	insertMetricQuery = `INSERT INTO metrics (time, run_id, metric_name, step, value, node_id, rank, metadata, value_type, text_value)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	latestMetricQuery = `SELECT ` + metricColumns + `
+	          FROM metrics
+	          WHERE run_id = $1 AND metric_name = $2
+	          ORDER BY time DESC
+	          LIMIT 1`
+)
+
+// metricCopyColumns are the columns of insertMetricQuery, in the order of
+// insertMetricArgs, for COPY
+var metricCopyColumns = []string{"time", "run_id", "metric_name", "step", "value", "node_id", "rank", "metadata", "value_type", "text_value"}
+
+// PreparedStatements lists the statements worth preparing on each connection:
+// metric inserts, the latest value, and the history query without time or
+// step bounds
+func PreparedStatements() []string {
+	history := runMetricsQuery(uuid.Nil, model.MetricQueryParams{MetricName: "-", Limit: 1}, nil)
+	return []string{insertMetricQuery, latestMetricQuery, history.String()}
+}
+
+// scanMetric reads a row of metricColumns, for pgx.CollectRows
+var scanMetric = scanner(metricFields)
+
+// metricFields are the destinations of a row of metricColumns
+func metricFields(m *model.Metric) []interface{} {
+	return []interface{}{&m.Time, &m.RunID, &m.MetricName, &m.Step, &m.Value, &m.NodeID, &m.Rank, &m.Metadata, &m.ValueType, &m.Text}
+}
+
+// insertMetricArgs are the arguments of insertMetricQuery for a metric
+// stored under name. String and bool values are stored in text_value with a
+// NULL value, which keeps them out of numeric queries.
+func insertMetricArgs(m model.Metric, name string) []interface{} {
+	var value interface{} = m.Value
+	var valueType *string
+	if !m.IsNumeric() {
+		value, valueType = nil, &m.ValueType
+	}
+	return []interface{}{m.Time, m.RunID, name, m.Step, value, m.NodeID, m.Rank, m.Metadata, valueType, m.Text}
+}
+
 type MetricRepository struct {
 	db     *pgxpool.Pool
+	limits RowLimits
 	logger *zap.Logger
+
+	// copyThreshold is the size from which BatchWrite copies rather than
+	// inserts; zero always inserts
+	copyThreshold int
 }
 
-func NewMetricRepository(db *pgxpool.Pool, logger *zap.Logger) *MetricRepository {
+func NewMetricRepository(db *pgxpool.Pool, limits RowLimits, logger *zap.Logger) *MetricRepository {
 	return &MetricRepository{
 		db:     db,
+		limits: limits,
 		logger: logger,
 	}
 }
 
+// UseCopy makes BatchWrite load batches of at least threshold metrics with
+// COPY, in one round trip, rather than one INSERT each. Smaller batches keep
+// the prepared insert, which is cheaper below a few hundred rows.
+func (r *MetricRepository) UseCopy(threshold int) {
+	r.copyThreshold = threshold
+}
+
+// CheckWritable reports whether the database accepts writes, which a
+// standby in recovery does not
+func (r *MetricRepository) CheckWritable(ctx context.Context) (bool, error) {
+	var writable bool
+	if err := r.db.QueryRow(ctx, `SELECT NOT pg_is_in_recovery()`).Scan(&writable); err != nil {
+		return false, fmt.Errorf("failed to query recovery state: %w", err)
+	}
+	return writable, nil
+}
+
+// PoolStats reports the connections of the pool in use, open and allowed
+func (r *MetricRepository) PoolStats() (acquired, total, max int32) {
+	stat := r.db.Stat()
+	return stat.AcquiredConns(), stat.TotalConns(), stat.MaxConns()
+}
+
 // BatchWrite inserts multiple metrics in a single transaction
 func (r *MetricRepository) BatchWrite(ctx context.Context, metrics []model.Metric) error {
 	if len(metrics) == 0 {
@@ -37,33 +135,324 @@ func (r *MetricRepository) BatchWrite(ctx context.Context, metrics []model.Metri
 	}
 	defer tx.Rollback(ctx)
 
+	order := timeOrder(len(metrics), func(i int) (uuid.UUID, time.Time) { return metrics[i].RunID, metrics[i].Time })
+	if r.copyThreshold > 0 && len(metrics) >= r.copyThreshold {
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"metrics"}, metricCopyColumns, metricCopySource(metrics, order)); err != nil {
+			return fmt.Errorf("failed to copy metrics: %w", err)
+		}
+	} else if err := insertMetrics(ctx, tx, metrics, order); err != nil {
+		return err
+	}
+
+	names := make(metricNameRows)
+	for _, m := range metrics {
+		names.add(m.RunID, m.MetricName, m.Time, 1)
+	}
+	if err := names.upsert(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Info("Batch write completed", zap.Int("count", len(metrics)))
+	return nil
+}
+
+// RejectedValue reports whether a failed write was the database rejecting a
+// value it was given, such as a number out of range or one breaking a
+// constraint, rather than failing as a whole, and why. Writing the other
+// values of the batch without it may then succeed.
+func RejectedValue(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return "", false
+	}
+	// Class 22 is data exceptions, class 23 integrity constraint violations
+	if !strings.HasPrefix(pgErr.Code, "22") && !strings.HasPrefix(pgErr.Code, "23") {
+		return "", false
+	}
+	return pgErr.Message, true
+}
+
+// metricCopySource streams metrics to COPY in the given order
+func metricCopySource(metrics []model.Metric, order []int) pgx.CopyFromSource {
+	return pgx.CopyFromSlice(len(order), func(i int) ([]interface{}, error) {
+		m := metrics[order[i]]
+		return insertMetricArgs(m, m.MetricName), nil
+	})
+}
+
+// insertMetrics inserts metrics in the given order, one INSERT each in a
+// single batch
+func insertMetrics(ctx context.Context, tx pgx.Tx, metrics []model.Metric, order []int) error {
 	batch := &pgx.Batch{}
-	for _, metric := range metrics {
-		batch.Queue(
-			`INSERT INTO metrics (time, run_id, metric_name, step, value, metadata)
-			 VALUES ($1, $2, $3, $4, $5, $6)`,
-			metric.Time, metric.RunID, metric.MetricName, metric.Step, metric.Value, metric.Metadata,
-		)
+	for _, i := range order {
+		batch.Queue(insertMetricQuery, insertMetricArgs(metrics[i], metrics[i].MetricName)...)
 	}
 
 	br := tx.SendBatch(ctx, batch)
 	defer br.Close()
 
-	// Execute all batched queries
-	for i := 0; i < len(metrics); i++ {
+	for _, i := range order {
 		if _, err := br.Exec(); err != nil {
 			return fmt.Errorf("failed to insert metric %d: %w", i, err)
 		}
 	}
 
+	// The batch holds the connection until closed, so close it before the
+	// transaction goes on
+	if err := br.Close(); err != nil {
+		return fmt.Errorf("failed to close batch: %w", err)
+	}
+	return nil
+}
+
+// BatchWriteRanked writes a batch in which some metrics are reduced across
+// ranks. Metrics without an entry in reductions (keyed by metric name),
+// without a step and rank, or not numeric, are inserted as they are. Every other value is
+// staged in metric_rank_values and optionally kept as its rank's own series,
+// then the canonical value of each step touched is recomputed from all ranks
+// reported so far. It returns the canonical metrics as written.
+func (r *MetricRepository) BatchWriteRanked(ctx context.Context, metrics []model.Metric, reductions map[string]model.RankReduction) ([]model.Metric, error) {
+	if len(metrics) == 0 {
+		return nil, nil
+	}
+
+	type stepKey struct {
+		runID uuid.UUID
+		name  string
+		step  int64
+	}
+	var steps []stepKey
+	seenSteps := make(map[stepKey]bool)
+	var runIDs []uuid.UUID
+	seenRuns := make(map[uuid.UUID]bool)
+	names := make(metricNameRows)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, i := range timeOrder(len(metrics), func(i int) (uuid.UUID, time.Time) { return metrics[i].RunID, metrics[i].Time }) {
+		m := metrics[i]
+		reduction, ok := reductions[m.MetricName]
+		if !ok || m.Step == nil || m.Rank == nil || !m.IsNumeric() {
+			batch.Queue(insertMetricQuery, insertMetricArgs(m, m.MetricName)...)
+			names.add(m.RunID, m.MetricName, m.Time, 1)
+			continue
+		}
+
+		// A rank resending a step replaces its earlier value
+		batch.Queue(
+			`INSERT INTO metric_rank_values (run_id, metric_name, step, rank, node_id, time, value)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)
+			 ON CONFLICT (run_id, metric_name, step, rank) DO UPDATE SET
+			   node_id = EXCLUDED.node_id,
+			   time = EXCLUDED.time,
+			   value = EXCLUDED.value`,
+			m.RunID, m.MetricName, *m.Step, *m.Rank, m.NodeID, m.Time, m.Value,
+		)
+		if reduction.KeepRanks {
+			rankName := model.RankMetricName(m.MetricName, *m.Rank)
+			batch.Queue(insertMetricQuery, insertMetricArgs(m, rankName)...)
+			names.add(m.RunID, rankName, m.Time, 1)
+		}
+
+		key := stepKey{runID: m.RunID, name: m.MetricName, step: *m.Step}
+		if !seenSteps[key] {
+			seenSteps[key] = true
+			steps = append(steps, key)
+		}
+		if !seenRuns[m.RunID] {
+			seenRuns[m.RunID] = true
+			runIDs = append(runIDs, m.RunID)
+		}
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := br.Exec(); err != nil {
+			br.Close()
+			return nil, fmt.Errorf("failed to insert metric %d: %w", i, err)
+		}
+	}
+	if err := br.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close batch: %w", err)
+	}
+
+	// Ranks of the same run report concurrently. Reducing under a per-run lock
+	// makes each writer see the values committed by the ones before it; runs
+	// are locked in a fixed order so two batches cannot deadlock.
+	sort.Slice(runIDs, func(i, j int) bool { return runIDs[i].String() < runIDs[j].String() })
+	for _, runID := range runIDs {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1::text, 0))`, runID); err != nil {
+			return nil, fmt.Errorf("failed to lock run %s: %w", runID, err)
+		}
+	}
+
+	canonical := make([]model.Metric, 0, len(steps))
+	for _, key := range steps {
+		reduction := reductions[key.name]
+		aggFunc, ok := reduceFuncs[reduction.Reduce]
+		if !ok {
+			return nil, fmt.Errorf("unsupported reduction %q for metric %s", reduction.Reduce, key.name)
+		}
+
+		replaced, err := tx.Exec(ctx,
+			`DELETE FROM metrics WHERE run_id = $1 AND metric_name = $2 AND step = $3 AND rank IS NULL`,
+			key.runID, key.name, key.step,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replace canonical metric: %w", err)
+		}
+
+		step := key.step
+		m := model.Metric{RunID: key.runID, MetricName: key.name, Step: &step}
+		err = tx.QueryRow(ctx, fmt.Sprintf(
+			`INSERT INTO metrics (time, run_id, metric_name, step, value, node_id, rank, metadata)
+			 SELECT MAX(time), run_id, metric_name, step, %s(value), '', NULL,
+			        jsonb_build_object('rank_reduce', $4::text, 'ranks', COUNT(*))
+			 FROM metric_rank_values
+			 WHERE run_id = $1 AND metric_name = $2 AND step = $3
+			 GROUP BY run_id, metric_name, step
+			 RETURNING time, value, metadata`, aggFunc),
+			key.runID, key.name, key.step, reduction.Reduce,
+		).Scan(&m.Time, &m.Value, &m.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write canonical metric: %w", err)
+		}
+		canonical = append(canonical, m)
+
+		// A step reduced again replaces its point rather than adding one
+		var points int64
+		if replaced.RowsAffected() == 0 {
+			points = 1
+		}
+		names.add(m.RunID, m.MetricName, m.Time, points)
+	}
+
+	if err := names.upsert(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Info("Ranked batch write completed",
+		zap.Int("count", len(metrics)),
+		zap.Int("reduced_steps", len(canonical)))
+	return canonical, nil
+}
+
+// DeleteMetricRange deletes the values of a metric logged at the steps of
+// d.MinStep through d.MaxStep, or every value of it without bounds, along
+// with the rank values staged for them. It updates the run's metric name
+// index and records d as the audit record, setting its DeletedRows and
+// DeletedAt. When no value is in range nothing is changed or recorded.
+func (r *MetricRepository) DeleteMetricRange(ctx context.Context, d *model.MetricDeletion) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	steps := func(table string) *queryBuilder {
+		q := newQuery("DELETE FROM "+table+" WHERE run_id = ? AND metric_name = ?", d.RunID, d.MetricName)
+		addIfSet(q, " AND step >= ?", d.MinStep)
+		addIfSet(q, " AND step <= ?", d.MaxStep)
+		return q
+	}
+
+	q := steps("metric_rank_values")
+	if err := q.Err(); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, q.String(), q.Args()...); err != nil {
+		return fmt.Errorf("failed to delete rank values: %w", err)
+	}
+	q = steps("metrics")
+	tag, err := tx.Exec(ctx, q.String(), q.Args()...)
+	if err != nil {
+		return fmt.Errorf("failed to delete metrics: %w", err)
+	}
+	d.DeletedRows = tag.RowsAffected()
+	if d.DeletedRows == 0 {
+		// Nothing to delete, and so nothing to record
+		return nil
+	}
+
+	// The index is rebuilt from what is left, dropping metrics left empty
+	if _, err := tx.Exec(ctx,
+		`WITH left_over AS (
+		   SELECT MIN(time) AS first_seen, MAX(time) AS last_seen, COUNT(*) AS count
+		   FROM metrics WHERE run_id = $1 AND metric_name = $2
+		 )
+		 UPDATE run_metric_names n
+		 SET first_seen = l.first_seen, last_seen = l.last_seen, count = l.count
+		 FROM left_over l
+		 WHERE n.run_id = $1 AND n.metric_name = $2 AND l.count > 0`,
+		d.RunID, d.MetricName,
+	); err != nil {
+		return fmt.Errorf("failed to update metric names: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM run_metric_names n
+		 WHERE n.run_id = $1 AND n.metric_name = $2
+		   AND NOT EXISTS (SELECT 1 FROM metrics WHERE run_id = $1 AND metric_name = $2)`,
+		d.RunID, d.MetricName,
+	); err != nil {
+		return fmt.Errorf("failed to update metric names: %w", err)
+	}
+
+	if err := tx.QueryRow(ctx,
+		`INSERT INTO metric_deletions (id, run_id, metric_name, min_step, max_step, deleted_rows, reason)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING deleted_at`,
+		d.ID, d.RunID, d.MetricName, d.MinStep, d.MaxStep, d.DeletedRows, d.Reason,
+	).Scan(&d.DeletedAt); err != nil {
+		return fmt.Errorf("failed to record metric deletion: %w", err)
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	r.logger.Info("Batch write completed", zap.Int("count", len(metrics)))
+	r.logger.Info("Metric range deleted",
+		zap.String("run_id", d.RunID.String()),
+		zap.String("metric_name", d.MetricName),
+		zap.Int64("count", d.DeletedRows))
 	return nil
 }
 
+// GetMetricDeletions retrieves the audit records of the metric deletions of
+// a run, latest first
+func (r *MetricRepository) GetMetricDeletions(ctx context.Context, runID uuid.UUID) ([]model.MetricDeletion, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT id, run_id, metric_name, min_step, max_step, deleted_rows, reason, deleted_at
+		 FROM metric_deletions
+		 WHERE run_id = $1
+		 ORDER BY deleted_at DESC
+		 LIMIT $2`,
+		runID, r.limits.resultLimit(0),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric deletions: %w", err)
+	}
+	deletions, err := pgx.CollectRows(rows, scanner(func(d *model.MetricDeletion) []interface{} {
+		return []interface{}{&d.ID, &d.RunID, &d.MetricName, &d.MinStep, &d.MaxStep, &d.DeletedRows, &d.Reason, &d.DeletedAt}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric deletions: %w", err)
+	}
+	return deletions, r.limits.checkResultRows("metric deletions", len(deletions))
+}
+
 // BatchWriteSystemMetrics inserts multiple system metrics
 func (r *MetricRepository) BatchWriteSystemMetrics(ctx context.Context, metrics []model.SystemMetric) error {
 	if len(metrics) == 0 {
@@ -76,24 +465,31 @@ func (r *MetricRepository) BatchWriteSystemMetrics(ctx context.Context, metrics
 	}
 	defer tx.Rollback(ctx)
 
+	order := timeOrder(len(metrics), func(i int) (uuid.UUID, time.Time) { return metrics[i].RunID, metrics[i].Time })
 	batch := &pgx.Batch{}
-	for _, metric := range metrics {
+	for _, i := range order {
+		metric := metrics[i]
 		batch.Queue(
-			`INSERT INTO system_metrics (time, run_id, metric_type, value, metadata)
-			 VALUES ($1, $2, $3, $4, $5)`,
-			metric.Time, metric.RunID, metric.MetricType, metric.Value, metric.Metadata,
+			`INSERT INTO system_metrics (time, run_id, metric_type, value, node_id, rank, metadata)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			metric.Time, metric.RunID, metric.MetricType, metric.Value, metric.NodeID, metric.Rank, metric.Metadata,
 		)
 	}
 
 	br := tx.SendBatch(ctx, batch)
 	defer br.Close()
 
-	for i := 0; i < len(metrics); i++ {
+	for _, i := range order {
 		if _, err := br.Exec(); err != nil {
 			return fmt.Errorf("failed to insert system metric %d: %w", i, err)
 		}
 	}
 
+	// The batch holds the connection until closed, so close it before committing
+	if err := br.Close(); err != nil {
+		return fmt.Errorf("failed to close batch: %w", err)
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -102,67 +498,297 @@ func (r *MetricRepository) BatchWriteSystemMetrics(ctx context.Context, metrics
 	return nil
 }
 
+// GetLatestBefore retrieves, for each given series, the latest value logged
+// without a rank strictly before the series' Time. Results align with the
+// input and are nil where nothing was logged earlier.
+func (r *MetricRepository) GetLatestBefore(ctx context.Context, series []model.Metric) ([]*model.Metric, error) {
+	if len(series) == 0 {
+		return nil, nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, m := range series {
+		batch.Queue(
+			`SELECT time, step, value FROM metrics
+			 WHERE run_id = $1 AND metric_name = $2 AND rank IS NULL AND time < $3
+			   AND value <> 'NaN'::float8
+			 ORDER BY time DESC
+			 LIMIT 1`,
+			m.RunID, m.MetricName, m.Time,
+		)
+	}
+
+	br := r.db.SendBatch(ctx, batch)
+	defer br.Close()
+
+	latest := make([]*model.Metric, len(series))
+	for i, m := range series {
+		prev := model.Metric{RunID: m.RunID, MetricName: m.MetricName}
+		err := br.QueryRow().Scan(&prev.Time, &prev.Step, &prev.Value)
+		if err == pgx.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query previous value of %s: %w", m.MetricName, err)
+		}
+		latest[i] = &prev
+	}
+
+	return latest, nil
+}
+
 // GetRunMetrics retrieves all metrics for a specific run
 func (r *MetricRepository) GetRunMetrics(ctx context.Context, runID uuid.UUID, params model.MetricQueryParams) ([]model.Metric, error) {
-	query := `SELECT time, run_id, metric_name, step, value, metadata
-	          FROM metrics
-	          WHERE run_id = $1`
-	args := []interface{}{runID}
-	argIdx := 2
+	params.Limit = r.limits.resultLimit(params.Limit)
+	after, err := params.After()
+	if err != nil {
+		return nil, err
+	}
+	q := runMetricsQuery(runID, params, after)
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics: %w", err)
+	}
+	metrics, err := pgx.CollectRows(rows, scanMetric)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %w", err)
+	}
 
-	if params.StartTime != nil {
-		query += fmt.Sprintf(" AND time >= $%d", argIdx)
-		args = append(args, *params.StartTime)
-		argIdx++
+	return metrics, r.limits.checkResultRows("metrics", len(metrics))
+}
+
+// StreamRunMetrics passes the metrics GetRunMetrics would return to fn as
+// they are read, a batch at a time through a cursor, stopping at the first
+// error fn returns. A zero limit streams every matching metric, up to the
+// stream cap, past which it fails with a RowLimitError.
+func (r *MetricRepository) StreamRunMetrics(ctx context.Context, runID uuid.UUID, params model.MetricQueryParams, fn func(model.Metric) error) error {
+	after, err := params.After()
+	if err != nil {
+		return err
 	}
+	params.Limit = r.limits.streamLimit(params.Limit)
+	q := runMetricsQuery(runID, params, after)
+	if err := q.Err(); err != nil {
+		return err
+	}
+	streamed := 0
+	_, err = streamCursor(ctx, r.db, q.String(), q.Args(), scanMetric, func(m model.Metric) error {
+		streamed++
+		if err := r.limits.checkStreamedRows("metrics", streamed); err != nil {
+			return err
+		}
+		return fn(m)
+	})
+	return err
+}
 
-	if params.EndTime != nil {
-		query += fmt.Sprintf(" AND time <= $%d", argIdx)
-		args = append(args, *params.EndTime)
-		argIdx++
+// ScanRunMetrics counts the metrics GetRunMetrics would match without a
+// limit or cursor, telling start how many there are, then passes them to fn
+// as they are read, in the same order. Like aggregates, it fails with a
+// RowLimitError past the scan cap, before reading any.
+func (r *MetricRepository) ScanRunMetrics(ctx context.Context, runID uuid.UUID, params model.MetricQueryParams, start func(total int64), fn func(model.Metric) error) error {
+	params.Limit, params.Cursor = 0, ""
+	total, err := r.CountRunMetrics(ctx, runID, params)
+	if err != nil {
+		return err
+	}
+	if err := r.limits.checkScannedRows("metrics", total); err != nil {
+		return err
+	}
+	start(total)
+	if total == 0 {
+		return nil
 	}
 
-	if params.MinStep != nil {
-		query += fmt.Sprintf(" AND step >= $%d", argIdx)
-		args = append(args, *params.MinStep)
-		argIdx++
+	// Metrics written meanwhile do not make the scan read more than counted
+	params.Limit = int(total)
+	q := runMetricsQuery(runID, params, nil)
+	if err := q.Err(); err != nil {
+		return err
 	}
+	_, err = streamCursor(ctx, r.db, q.String(), q.Args(), scanMetric, fn)
+	return err
+}
+
+// runMetricsQuery builds the query of GetRunMetrics, resuming after the
+// position after when set. Its text depends only on which params are set, so
+// the common forms can be prepared ahead.
+func runMetricsQuery(runID uuid.UUID, params model.MetricQueryParams, after *model.MetricCursor) *queryBuilder {
+	q := newQuery(`SELECT `+metricColumns+`
+	               FROM metrics
+	               WHERE run_id = ?`, runID)
+	metricFilters(q, params)
 
-	if params.MaxStep != nil {
-		query += fmt.Sprintf(" AND step <= $%d", argIdx)
-		args = append(args, *params.MaxStep)
-		argIdx++
+	// Ties on time, or on step, are broken down to the row, so that cursors
+	// resume exactly
+	columns := "time, metric_name, node_id, COALESCE(rank, -1), COALESCE(step, -1)"
+	order := "time %[1]s, metric_name %[1]s, node_id %[1]s, COALESCE(rank, -1) %[1]s, COALESCE(step, -1) %[1]s"
+	var position []interface{}
+	if after != nil {
+		position = []interface{}{after.Time, after.MetricName, after.NodeID, after.Rank, after.Step}
+	}
+	if params.OrderBy == model.OrderByStep {
+		columns = "COALESCE(step, -1), time, metric_name, node_id, COALESCE(rank, -1)"
+		order = "COALESCE(step, -1) %[1]s, time %[1]s, metric_name %[1]s, node_id %[1]s, COALESCE(rank, -1) %[1]s"
+		if after != nil {
+			position = []interface{}{after.Step, after.Time, after.MetricName, after.NodeID, after.Rank}
+		}
+	}
+	direction, compare := "DESC", "<"
+	if params.Direction == model.DirectionAsc {
+		direction, compare = "ASC", ">"
+	}
+	if after != nil {
+		q.Add(fmt.Sprintf(" AND (%s) %s (?, ?, ?, ?, ?)", columns, compare), position...)
+	}
+	q.Add(" ORDER BY " + fmt.Sprintf(order, direction))
+	if params.Limit > 0 {
+		q.Add(" LIMIT ?", params.Limit)
 	}
+	if params.Offset > 0 {
+		q.Add(" OFFSET ?", params.Offset)
+	}
+	return q
+}
 
-	if params.MetricName != "" {
-		query += fmt.Sprintf(" AND metric_name = $%d", argIdx)
-		args = append(args, params.MetricName)
-		argIdx++
+// metricFilters adds the time, step and name conditions of a metric query
+func metricFilters(q *queryBuilder, params model.MetricQueryParams) {
+	addIfSet(q, " AND time >= ?", params.StartTime)
+	addIfSet(q, " AND time <= ?", params.EndTime)
+	addIfSet(q, " AND step >= ?", params.MinStep)
+	addIfSet(q, " AND step <= ?", params.MaxStep)
+	addIfNotZero(q, " AND metric_name = ?", params.MetricName)
+	if len(params.MetricNames) > 0 {
+		q.Add(" AND metric_name = ANY(?)", params.MetricNames)
+	}
+	switch {
+	case params.MetricNamePattern == "":
+	case params.PatternType == model.PatternRegex:
+		q.Add(" AND metric_name ~ ?", params.MetricNamePattern)
+	default:
+		q.Add(" AND metric_name LIKE ?", globToLike(params.MetricNamePattern))
 	}
+}
 
-	query += " ORDER BY time DESC"
+// globToLike translates a glob to a LIKE pattern: * matches any run of
+// characters, slashes included, and ? any one. The LIKE wildcards % and _
+// match only themselves, as does any character escaped with a backslash.
+func globToLike(glob string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range glob {
+		switch {
+		case escaped:
+			escaped = false
+			if r == '%' || r == '_' || r == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		case r == '\\':
+			escaped = true
+		case r == '*':
+			b.WriteByte('%')
+		case r == '?':
+			b.WriteByte('_')
+		case r == '%' || r == '_':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if escaped {
+		// A trailing backslash stands for itself
+		b.WriteString(`\\`)
+	}
+	return b.String()
+}
 
-	if params.Limit > 0 {
-		query += fmt.Sprintf(" LIMIT $%d", argIdx)
-		args = append(args, params.Limit)
+// CountRunMetrics counts the metrics GetRunMetrics would return for params
+// without a limit or cursor
+func (r *MetricRepository) CountRunMetrics(ctx context.Context, runID uuid.UUID, params model.MetricQueryParams) (int64, error) {
+	q := newQuery(`SELECT COUNT(*) FROM metrics WHERE run_id = ?`, runID)
+	metricFilters(q, params)
+
+	if err := q.Err(); err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := r.db.QueryRow(ctx, q.String(), q.Args()...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count metrics: %w", err)
 	}
+	return count, nil
+}
 
-	rows, err := r.db.Query(ctx, query, args...)
+// ListMetricNames lists the names of the metrics logged in a run
+func (r *MetricRepository) ListMetricNames(ctx context.Context, runID uuid.UUID) ([]string, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT metric_name FROM run_metric_names WHERE run_id = $1 ORDER BY metric_name`,
+		runID,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query metrics: %w", err)
+		return nil, fmt.Errorf("failed to query metric names: %w", err)
 	}
-	defer rows.Close()
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric names: %w", err)
+	}
+	return names, nil
+}
 
-	var metrics []model.Metric
-	for rows.Next() {
-		var m model.Metric
-		if err := rows.Scan(&m.Time, &m.RunID, &m.MetricName, &m.Step, &m.Value, &m.Metadata); err != nil {
-			return nil, fmt.Errorf("failed to scan metric: %w", err)
-		}
-		metrics = append(metrics, m)
+// ListMetricNameCounts lists the metrics logged in a run with their number
+// of values, ordered by name
+func (r *MetricRepository) ListMetricNameCounts(ctx context.Context, runID uuid.UUID) ([]model.MetricNameCount, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT metric_name, count, last_seen FROM run_metric_names WHERE run_id = $1 ORDER BY metric_name`,
+		runID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric names: %w", err)
 	}
+	names, err := pgx.CollectRows(rows, scanner(func(n *model.MetricNameCount) []interface{} {
+		return []interface{}{&n.MetricName, &n.Count, &n.LastSeen}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric names: %w", err)
+	}
+	return names, nil
+}
 
-	return metrics, nil
+// ListMetricNameSummaries lists the metrics logged in a run, ordered by
+// name, with their number of values, step range and latest value. Metrics
+// whose values were all deleted are left out.
+func (r *MetricRepository) ListMetricNameSummaries(ctx context.Context, runID uuid.UUID) ([]model.MetricNameSummary, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT n.metric_name, n.count, n.first_seen, n.last_seen, s.first_step, s.last_step, l.*
+		 FROM run_metric_names n
+		 CROSS JOIN LATERAL (
+		   SELECT MIN(step) AS first_step, MAX(step) AS last_step
+		   FROM metrics
+		   WHERE run_id = n.run_id AND metric_name = n.metric_name
+		 ) s
+		 JOIN LATERAL (
+		   SELECT `+metricColumns+`
+		   FROM metrics
+		   WHERE run_id = n.run_id AND metric_name = n.metric_name
+		   ORDER BY time DESC
+		   LIMIT 1
+		 ) l ON true
+		 WHERE n.run_id = $1
+		 ORDER BY n.metric_name
+		 LIMIT $2`,
+		runID, r.limits.resultLimit(0),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric names: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, scanner(func(n *model.MetricNameSummary) []interface{} {
+		return append([]interface{}{&n.MetricName, &n.Count, &n.FirstSeen, &n.LastSeen, &n.FirstStep, &n.LastStep}, metricFields(&n.Last)...)
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric names: %w", err)
+	}
+	return names, r.limits.checkResultRows("metric names", len(names))
 }
 
 // GetMetricHistory retrieves history for a specific metric
@@ -173,16 +799,8 @@ func (r *MetricRepository) GetMetricHistory(ctx context.Context, runID uuid.UUID
 
 // GetLatestMetric retrieves the most recent value for a specific metric
 func (r *MetricRepository) GetLatestMetric(ctx context.Context, runID uuid.UUID, metricName string) (*model.Metric, error) {
-	query := `SELECT time, run_id, metric_name, step, value, metadata
-	          FROM metrics
-	          WHERE run_id = $1 AND metric_name = $2
-	          ORDER BY time DESC
-	          LIMIT 1`
-
 	var m model.Metric
-	err := r.db.QueryRow(ctx, query, runID, metricName).Scan(
-		&m.Time, &m.RunID, &m.MetricName, &m.Step, &m.Value, &m.Metadata,
-	)
+	err := r.db.QueryRow(ctx, latestMetricQuery, runID, metricName).Scan(metricFields(&m)...)
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
@@ -193,29 +811,116 @@ func (r *MetricRepository) GetLatestMetric(ctx context.Context, runID uuid.UUID,
 	return &m, nil
 }
 
+// ListActiveLatestMetrics returns the latest value, by time, of each of the
+// named metrics in every run that logged it since the given time
+func (r *MetricRepository) ListActiveLatestMetrics(ctx context.Context, metricNames []string, since time.Time) ([]model.Metric, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT m.*
+		 FROM run_metric_names n
+		 JOIN LATERAL (
+		   SELECT `+metricColumns+`
+		   FROM metrics
+		   WHERE run_id = n.run_id AND metric_name = n.metric_name
+		   ORDER BY time DESC
+		   LIMIT 1
+		 ) m ON true
+		 WHERE n.metric_name = ANY($1) AND n.last_seen >= $2
+		 ORDER BY n.run_id, n.metric_name
+		 LIMIT $3`,
+		metricNames, since, r.limits.resultLimit(0),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active latest metrics: %w", err)
+	}
+	metrics, err := pgx.CollectRows(rows, scanMetric)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read active latest metrics: %w", err)
+	}
+
+	return metrics, r.limits.checkResultRows("metrics", len(metrics))
+}
+
+// StreamRollups passes the hourly rollups of the runs' metrics with buckets
+// starting in [start, end) to fn, ordered by run, metric and bucket, a batch
+// at a time through a cursor. Buckets not yet materialized are left out.
+func (r *MetricRepository) StreamRollups(ctx context.Context, runIDs []uuid.UUID, metricName string, start, end time.Time, fn func(model.MetricRollup) error) error {
+	q := newQuery(`SELECT run_id, metric_name, bucket, avg_value, min_value, max_value, stddev_value, count
+	               FROM metrics_hourly
+	               WHERE run_id = ANY(?) AND bucket >= ? AND bucket < ?`, runIDs, start, end)
+	addIfNotZero(q, " AND metric_name = ?", metricName)
+	q.Add(" ORDER BY run_id, metric_name, bucket")
+	if limit := r.limits.streamLimit(0); limit > 0 {
+		q.Add(" LIMIT ?", limit)
+	}
+	if err := q.Err(); err != nil {
+		return err
+	}
+
+	streamed := 0
+	_, err := streamCursor(ctx, r.db, q.String(), q.Args(), scanner(func(m *model.MetricRollup) []interface{} {
+		return []interface{}{&m.RunID, &m.MetricName, &m.Bucket, &m.Avg, &m.Min, &m.Max, &m.StdDev, &m.Count}
+	}), func(m model.MetricRollup) error {
+		streamed++
+		if err := r.limits.checkStreamedRows("rollups", streamed); err != nil {
+			return err
+		}
+		return fn(m)
+	})
+	return err
+}
+
+// StreamSeriesMetrics passes the numeric and bool metrics logged within
+// [start, end] to fn, of the run and under the name given unless they are
+// nil or empty, a batch at a time through a cursor. They are ordered by run,
+// name, node and rank, then oldest first, so that each series is read in one
+// go. Past the stream cap it fails with a RowLimitError.
+func (r *MetricRepository) StreamSeriesMetrics(ctx context.Context, runID *uuid.UUID, metricName string, start, end time.Time, fn func(model.Metric) error) error {
+	q := newQuery(`SELECT `+metricColumns+`
+	               FROM metrics
+	               WHERE time >= ? AND time <= ? AND COALESCE(value_type, '') <> ?`, start, end, model.ValueTypeString)
+	addIfSet(q, " AND run_id = ?", runID)
+	addIfNotZero(q, " AND metric_name = ?", metricName)
+	q.Add(" ORDER BY run_id, metric_name, node_id, COALESCE(rank, -1), time")
+	if limit := r.limits.streamLimit(0); limit > 0 {
+		q.Add(" LIMIT ?", limit)
+	}
+	if err := q.Err(); err != nil {
+		return err
+	}
+
+	streamed := 0
+	_, err := streamCursor(ctx, r.db, q.String(), q.Args(), scanMetric, func(m model.Metric) error {
+		streamed++
+		if err := r.limits.checkStreamedRows("metrics", streamed); err != nil {
+			return err
+		}
+		return fn(m)
+	})
+	return err
+}
+
 // GetMetricStats retrieves statistics for a specific metric
 func (r *MetricRepository) GetMetricStats(ctx context.Context, runID uuid.UUID, metricName string) (*model.MetricStats, error) {
 	query := `SELECT
 	            metric_name,
-	            COUNT(*) as count,
-	            MIN(value) as min_value,
-	            MAX(value) as max_value,
-	            AVG(value) as avg_value,
-	            STDDEV(value) as std_dev,
-	            MIN(time) as first_time,
-	            MAX(time) as last_time
-	          FROM metrics
-	          WHERE run_id = $1 AND metric_name = $2
+	            ` + metricStatsColumns + `
+	          FROM (
+	            SELECT metric_name, value, time FROM metrics
+	            WHERE run_id = $1 AND metric_name = $2
+	            LIMIT $3
+	          ) m
 	          GROUP BY metric_name`
 
 	var stats model.MetricStats
-	err := r.db.QueryRow(ctx, query, runID, metricName).Scan(
+	err := r.db.QueryRow(ctx, query, runID, metricName, r.limits.scanLimit()).Scan(
 		&stats.MetricName,
 		&stats.Count,
 		&stats.MinValue,
 		&stats.MaxValue,
 		&stats.AvgValue,
 		&stats.StdDev,
+		&stats.NonFiniteCount,
+		&stats.FirstNonFiniteTime,
 		&stats.FirstTime,
 		&stats.LastTime,
 	)
@@ -225,51 +930,249 @@ func (r *MetricRepository) GetMetricStats(ctx context.Context, runID uuid.UUID,
 	if err != nil {
 		return nil, fmt.Errorf("failed to query metric stats: %w", err)
 	}
+	if err := r.limits.checkScannedRows("metric values", stats.Count); err != nil {
+		return nil, err
+	}
 
 	return &stats, nil
 }
 
-// GetSystemMetrics retrieves system metrics for a specific run
-func (r *MetricRepository) GetSystemMetrics(ctx context.Context, runID uuid.UUID, startTime, endTime *time.Time, limit int) ([]model.SystemMetric, error) {
-	query := `SELECT time, run_id, metric_type, value, metadata
-	          FROM system_metrics
-	          WHERE run_id = $1`
-	args := []interface{}{runID}
-	argIdx := 2
+// GetMetricStatsForNames retrieves statistics for several metrics of a run
+// in one query; metrics without values are left out
+func (r *MetricRepository) GetMetricStatsForNames(ctx context.Context, runID uuid.UUID, metricNames []string) ([]model.MetricStats, error) {
+	query := `SELECT
+	            metric_name,
+	            ` + metricStatsColumns + `
+	          FROM (
+	            SELECT metric_name, value, time FROM metrics
+	            WHERE run_id = $1 AND metric_name = ANY($2)
+	            LIMIT $3
+	          ) m
+	          GROUP BY metric_name`
 
-	if startTime != nil {
-		query += fmt.Sprintf(" AND time >= $%d", argIdx)
-		args = append(args, *startTime)
-		argIdx++
+	rows, err := r.db.Query(ctx, query, runID, metricNames, r.limits.scanLimit())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric stats: %w", err)
+	}
+	stats, err := pgx.CollectRows(rows, scanner(func(s *model.MetricStats) []interface{} {
+		return []interface{}{&s.MetricName, &s.Count, &s.MinValue, &s.MaxValue, &s.AvgValue, &s.StdDev, &s.NonFiniteCount, &s.FirstNonFiniteTime, &s.FirstTime, &s.LastTime}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric stats: %w", err)
 	}
 
-	if endTime != nil {
-		query += fmt.Sprintf(" AND time <= $%d", argIdx)
-		args = append(args, *endTime)
-		argIdx++
+	var scanned int64
+	for _, s := range stats {
+		scanned += s.Count
+	}
+
+	return stats, r.limits.checkScannedRows("metric values", scanned)
+}
+
+// bucketFuncs maps the functions of MetricBucketParams to SQL aggregates
+var bucketFuncs = map[string]string{
+	model.BucketAvg:   "AVG(value)",
+	model.BucketMin:   "MIN(value)",
+	model.BucketMax:   "MAX(value)",
+	model.BucketSum:   "SUM(value)",
+	model.BucketCount: "COUNT(*)::float8",
+}
+
+// GetMetricBuckets groups the numeric values of a metric into time buckets
+// of the given width with time_bucket, keeping the latest limit buckets in
+// time order. Each point holds the requested function of its values as
+// Value, with their minimum, maximum and count, and its last step.
+func (r *MetricRepository) GetMetricBuckets(ctx context.Context, runID uuid.UUID, metricName string, bucket time.Duration, params model.MetricBucketParams) ([]model.AggregatePoint, error) {
+	fn := params.Fn
+	if fn == "" {
+		fn = model.BucketAvg
+	}
+	aggFunc, ok := bucketFuncs[fn]
+	if !ok {
+		return nil, fmt.Errorf("unsupported bucket function %q", fn)
 	}
 
-	query += " ORDER BY time DESC"
+	q := newQuery(fmt.Sprintf(
+		`SELECT time, step, value, min_value, max_value, count, SUM(count) OVER ()::bigint AS scanned
+		 FROM (
+		   SELECT time_bucket(make_interval(secs => ?), time) AS time, MAX(step) AS step, %s AS value,
+		          MIN(value) AS min_value, MAX(value) AS max_value, COUNT(*) AS count
+		   FROM (
+		     SELECT time, step, value
+		     FROM metrics
+		     WHERE run_id = ? AND metric_name = ? AND value IS NOT NULL`, aggFunc),
+		bucket.Seconds(), runID, metricName)
+	addIfSet(q, " AND time >= ?", params.StartTime)
+	addIfSet(q, " AND time <= ?", params.EndTime)
+	addIfSet(q, " AND step >= ?", params.MinStep)
+	addIfSet(q, " AND step <= ?", params.MaxStep)
+	// The total scanned is taken over every bucket before the latest are kept
+	q.Add(` LIMIT ?) m
+		   GROUP BY 1
+		 ) b
+		 ORDER BY time DESC
+		 LIMIT ?`, r.limits.scanLimit(), r.limits.resultLimit(params.Limit))
+	q.Wrap("SELECT * FROM (").Add(") latest ORDER BY time")
 
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT $%d", argIdx)
-		args = append(args, limit)
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric buckets: %w", err)
+	}
+	var scanned int64
+	points, err := pgx.CollectRows(rows, scanner(func(p *model.AggregatePoint) []interface{} {
+		return []interface{}{&p.Time, &p.Step, &p.Value, &p.Min, &p.Max, &p.Count, &scanned}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric buckets: %w", err)
 	}
+	if err := r.limits.checkScannedRows("metric values", scanned); err != nil {
+		return nil, err
+	}
+	return points, r.limits.checkResultRows("metric buckets", len(points))
+}
 
-	rows, err := r.db.Query(ctx, query, args...)
+// GetSystemMetrics retrieves system metrics for a specific run
+func (r *MetricRepository) GetSystemMetrics(ctx context.Context, runID uuid.UUID, params model.SystemMetricQueryParams) ([]model.SystemMetric, error) {
+	after, err := params.After()
+	if err != nil {
+		return nil, err
+	}
+
+	q := newQuery(`SELECT time, run_id, metric_type, value, node_id, rank, metadata
+	               FROM system_metrics
+	               WHERE run_id = ?`, runID)
+	addIfSet(q, " AND time >= ?", params.StartTime)
+	addIfSet(q, " AND time <= ?", params.EndTime)
+	if after != nil {
+		q.Add(" AND (time, metric_type, node_id, COALESCE(rank, -1)) < (?, ?, ?, ?)",
+			after.Time, after.MetricType, after.NodeID, after.Rank)
+	}
+	q.Add(" ORDER BY time DESC, metric_type DESC, node_id DESC, COALESCE(rank, -1) DESC LIMIT ?", r.limits.resultLimit(params.Limit))
+
+	rows, err := q.Query(ctx, r.db)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query system metrics: %w", err)
 	}
-	defer rows.Close()
+	metrics, err := pgx.CollectRows(rows, scanner(func(m *model.SystemMetric) []interface{} {
+		return []interface{}{&m.Time, &m.RunID, &m.MetricType, &m.Value, &m.NodeID, &m.Rank, &m.Metadata}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read system metrics: %w", err)
+	}
 
-	var metrics []model.SystemMetric
-	for rows.Next() {
-		var m model.SystemMetric
-		if err := rows.Scan(&m.Time, &m.RunID, &m.MetricType, &m.Value, &m.Metadata); err != nil {
-			return nil, fmt.Errorf("failed to scan system metric: %w", err)
-		}
-		metrics = append(metrics, m)
+	return metrics, r.limits.checkResultRows("system metrics", len(metrics))
+}
+
+// GetBestStep returns the best value for a metric under the given goal, the
+// first step at which a value within minDelta of it was logged, and the latest step
+func (r *MetricRepository) GetBestStep(ctx context.Context, runID uuid.UUID, metricName string, goal string, minDelta float64) (*model.BestStep, error) {
+	bestAgg, withinBest := "MIN(value)", "s.value <= b.best + $3"
+	if goal == model.GoalMax {
+		bestAgg, withinBest = "MAX(value)", "s.value >= b.best - $3"
+	}
+
+	query := fmt.Sprintf(`WITH s AS (
+	            SELECT step, value FROM metrics
+	            WHERE run_id = $1 AND metric_name = $2 AND step IS NOT NULL AND value IS NOT NULL
+	          ), b AS (
+	            SELECT %s AS best FROM s
+	          )
+	          SELECT b.best,
+	                 (SELECT MIN(s.step) FROM s WHERE %s),
+	                 (SELECT MAX(step) FROM s)
+	          FROM b`, bestAgg, withinBest)
+
+	var best model.BestStep
+	var bestValue *float64
+	err := r.db.QueryRow(ctx, query, runID, metricName, minDelta).Scan(&bestValue, &best.BestStep, &best.LatestStep)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query best step: %w", err)
+	}
+	if bestValue == nil {
+		return nil, nil
+	}
+	best.BestValue = *bestValue
+
+	return &best, nil
+}
+
+// GetRecentSteps retrieves the mean finite value of a metric at each of its
+// latest limit steps, oldest first
+func (r *MetricRepository) GetRecentSteps(ctx context.Context, runID uuid.UUID, metricName string, limit int) ([]model.AggregatePoint, error) {
+	samples := newQuery(`SELECT time, step, value, 1 AS scanned FROM metrics
+	                     WHERE run_id = ? AND metric_name = ? AND step IS NOT NULL AND value <> 'NaN'::float8
+	                       AND value <> 'Infinity'::float8 AND value <> '-Infinity'::float8
+	                     LIMIT ?`, runID, metricName, r.limits.scanLimit())
+	return queryReduced(ctx, r.db, r.limits, samples, "step", model.ReduceMean, limit)
+}
+
+// GetStepValuesForRuns retrieves the mean finite value of a metric of each
+// of runIDs at each of its first steps within the request's step range,
+// ordered by step and run. Runs without a value at a step are left out of
+// it, and runs without a finite one have a nil value.
+func (r *MetricRepository) GetStepValuesForRuns(ctx context.Context, req model.MetricCompareRequest, steps int) ([]model.RunStepValue, error) {
+	q := newQuery(`SELECT step, run_id, value, scanned
+	               FROM (
+	                 SELECT step, run_id, AVG(value) FILTER (WHERE `+finiteValue+`) AS value,
+	                        SUM(COUNT(*)) OVER ()::bigint AS scanned, DENSE_RANK() OVER (ORDER BY step) AS n
+	                 FROM (
+	                   SELECT run_id, step, value
+	                   FROM metrics
+	                   WHERE run_id = ANY(?) AND metric_name = ? AND step IS NOT NULL AND value IS NOT NULL`,
+		req.RunIDs, req.MetricName)
+	addIfSet(q, " AND step >= ?", req.MinStep)
+	addIfSet(q, " AND step <= ?", req.MaxStep)
+	q.Add(` LIMIT ?) m
+	                 GROUP BY step, run_id
+	               ) s
+	               WHERE n <= ?
+	               ORDER BY step, run_id`, r.limits.scanLimit(), steps)
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric steps: %w", err)
+	}
+	var scanned int64
+	values, err := pgx.CollectRows(rows, scanner(func(v *model.RunStepValue) []interface{} {
+		return []interface{}{&v.Step, &v.RunID, &v.Value, &scanned}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric steps: %w", err)
+	}
+	if err := r.limits.checkScannedRows("metric values", scanned); err != nil {
+		return nil, err
+	}
+	return values, r.limits.checkResultRows("metric steps", len(values))
+}
+
+// GetMetricStatsForRuns retrieves statistics for one metric across several runs
+func (r *MetricRepository) GetMetricStatsForRuns(ctx context.Context, runIDs []uuid.UUID, metricName string) ([]model.RunMetricStats, error) {
+	query := `SELECT
+	            run_id,
+	            metric_name,
+	            ` + metricStatsColumns + `
+	          FROM (
+	            SELECT run_id, metric_name, value, time FROM metrics
+	            WHERE run_id = ANY($1) AND metric_name = $2
+	            LIMIT $3
+	          ) m
+	          GROUP BY run_id, metric_name`
+
+	rows, err := r.db.Query(ctx, query, runIDs, metricName, r.limits.scanLimit())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric stats: %w", err)
+	}
+	stats, err := pgx.CollectRows(rows, scanner(func(s *model.RunMetricStats) []interface{} {
+		return []interface{}{&s.RunID, &s.MetricName, &s.Count, &s.MinValue, &s.MaxValue, &s.AvgValue, &s.StdDev, &s.NonFiniteCount, &s.FirstNonFiniteTime, &s.FirstTime, &s.LastTime}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric stats: %w", err)
+	}
+
+	var scanned int64
+	for _, s := range stats {
+		scanned += s.Count
 	}
 
-	return metrics, nil
+	return stats, r.limits.checkScannedRows("metric values", scanned)
 }
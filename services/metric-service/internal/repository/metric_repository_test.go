@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// keysetPattern captures the columns a cursor resumes after and the ORDER BY
+// of a paged query
+var keysetPattern = regexp.MustCompile(`AND \(([^<]+)\) < \(.*ORDER BY (.+?)(?: LIMIT|$)`)
+
+func TestRunMetricsQueryCursor(t *testing.T) {
+	runID := uuid.New()
+	after := &model.MetricCursor{Time: time.Unix(100, 0), MetricName: "loss", NodeID: "n1", Rank: -1, Step: 7}
+	q := runMetricsQuery(runID, model.MetricQueryParams{MetricName: "loss", Limit: 10}, after)
+	if err := q.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := keysetPattern.FindStringSubmatch(q.String())
+	if m == nil {
+		t.Fatalf("no cursor condition and ORDER BY in %q", q.String())
+	}
+	// The cursor must compare the ORDER BY columns in order, all descending,
+	// so that a page resumes right after the previous one's last row
+	columns := splitColumns(m[1])
+	order := splitColumns(m[2])
+	if len(columns) != len(order) {
+		t.Fatalf("cursor compares %v, query orders by %v", columns, order)
+	}
+	for i, column := range columns {
+		if order[i] != column+" DESC" {
+			t.Fatalf("cursor compares %v, query orders by %v", columns, order)
+		}
+	}
+
+	args := q.Args()
+	want := []interface{}{runID, "loss", after.Time, after.MetricName, after.NodeID, after.Rank, after.Step, 10}
+	if len(args) != len(want) {
+		t.Fatalf("Args() = %v, want %v", args, want)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Fatalf("Args() = %v, want %v", args, want)
+		}
+	}
+}
+
+// splitColumns splits a column list at the commas outside parentheses
+func splitColumns(list string) []string {
+	var columns []string
+	depth, start := 0, 0
+	for i, r := range list {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				columns = append(columns, strings.TrimSpace(list[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(columns, strings.TrimSpace(list[start:]))
+}
+
+func TestRunMetricsQueryWithoutCursor(t *testing.T) {
+	q := runMetricsQuery(uuid.New(), model.MetricQueryParams{}, nil)
+	if err := q.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(q.String(), "<") || strings.Contains(q.String(), "LIMIT") {
+		t.Fatalf("query without cursor or limit = %q", q.String())
+	}
+}
+
+func TestRunMetricsQueryOffset(t *testing.T) {
+	q := runMetricsQuery(uuid.New(), model.MetricQueryParams{Limit: 10, Offset: 5000}, nil)
+	if err := q.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(q.String(), " LIMIT $2 OFFSET $3") {
+		t.Fatalf("query with offset = %q", q.String())
+	}
+	if args := q.Args(); args[len(args)-1] != 5000 {
+		t.Fatalf("Args() = %v, want the offset last", args)
+	}
+}
+
+func TestRunMetricsQueryOrder(t *testing.T) {
+	pattern := regexp.MustCompile(`AND \(([^<>]+)\) ([<>]) \(.*ORDER BY (.+?)(?: LIMIT|$)`)
+	after := &model.MetricCursor{Time: time.Unix(100, 0), MetricName: "loss", Rank: -1, Step: 7}
+	tests := []struct {
+		orderBy, direction string
+		wantFirst          string
+		wantCompare        string
+		wantDirection      string
+		wantPosition       interface{}
+	}{
+		{"", model.DirectionAsc, "time", ">", "ASC", after.Time},
+		{model.OrderByStep, "", "COALESCE(step, -1)", "<", "DESC", after.Step},
+		{model.OrderByStep, model.DirectionAsc, "COALESCE(step, -1)", ">", "ASC", after.Step},
+	}
+	for _, tt := range tests {
+		params := model.MetricQueryParams{MetricName: "loss", Limit: 10, OrderBy: tt.orderBy, Direction: tt.direction}
+		q := runMetricsQuery(uuid.New(), params, after)
+		m := pattern.FindStringSubmatch(q.String())
+		if m == nil {
+			t.Fatalf("%s %s: no cursor condition and ORDER BY in %q", tt.orderBy, tt.direction, q.String())
+		}
+		columns, order := splitColumns(m[1]), splitColumns(m[3])
+		if columns[0] != tt.wantFirst || m[2] != tt.wantCompare || len(columns) != len(order) {
+			t.Fatalf("%s %s: cursor compares %v %s, query orders by %v", tt.orderBy, tt.direction, columns, m[2], order)
+		}
+		for i, column := range columns {
+			if order[i] != column+" "+tt.wantDirection {
+				t.Fatalf("%s %s: cursor compares %v, query orders by %v", tt.orderBy, tt.direction, columns, order)
+			}
+		}
+		// The position is bound in the order of the columns
+		if args := q.Args(); args[2] != tt.wantPosition {
+			t.Errorf("%s %s: position starts with %v, want %v", tt.orderBy, tt.direction, args[2], tt.wantPosition)
+		}
+	}
+}
+
+func TestGlobToLike(t *testing.T) {
+	tests := []struct {
+		glob, want string
+	}{
+		{"train/*", "train/%"},
+		{"*/loss", "%/loss"},
+		{"layer_?/grad", `layer\__/grad`},
+		{"100%", `100\%`},
+		{`lr\*`, "lr*"},
+		{`a\?\_\\b`, `a?\_\\b`},
+		{`trailing\`, `trailing\\`},
+	}
+	for _, tt := range tests {
+		if got := globToLike(tt.glob); got != tt.want {
+			t.Errorf("globToLike(%q) = %q, want %q", tt.glob, got, tt.want)
+		}
+	}
+}
+
+func TestMetricCopySource(t *testing.T) {
+	runID := uuid.New()
+	text := "done"
+	metrics := []model.Metric{
+		{RunID: runID, MetricName: "loss", Time: time.Unix(200, 0), Value: 0.5},
+		{RunID: runID, MetricName: "status", Time: time.Unix(100, 0), ValueType: model.ValueTypeString, Text: &text},
+	}
+	order := timeOrder(len(metrics), func(i int) (uuid.UUID, time.Time) { return metrics[i].RunID, metrics[i].Time })
+
+	src := metricCopySource(metrics, order)
+	var names []string
+	for src.Next() {
+		row, err := src.Values()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(row) != len(metricCopyColumns) {
+			t.Fatalf("row has %d values for %d columns", len(row), len(metricCopyColumns))
+		}
+		names = append(names, row[2].(string))
+		if row[2] == "status" && row[4] != nil {
+			t.Errorf("string metric copied with value %v, want NULL", row[4])
+		}
+	}
+	if strings.Join(names, ",") != "status,loss" {
+		t.Errorf("copied %v, want rows in time order", names)
+	}
+}
+
+func TestRejectedValue(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"out of range", &pgconn.PgError{Code: "22003", Message: "value out of range"}, true},
+		{"wrapped not null", fmt.Errorf("failed to write metrics: %w", &pgconn.PgError{Code: "23502", Message: "null value"}), true},
+		{"read only", &pgconn.PgError{Code: "25006", Message: "read-only transaction"}, false},
+		{"connection", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := RejectedValue(tt.err)
+			if ok != tt.want || (ok && reason == "") {
+				t.Errorf("RejectedValue() = %q, %v, want %v", reason, ok, tt.want)
+			}
+		})
+	}
+}
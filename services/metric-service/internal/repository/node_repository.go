@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// reduceFuncs maps the supported reductions to SQL aggregates
+var reduceFuncs = map[string]string{
+	model.ReduceMean: "AVG",
+	model.ReduceMax:  "MAX",
+	model.ReduceMin:  "MIN",
+	model.ReduceSum:  "SUM",
+}
+
+// NodeRepository aggregates training, system and GPU metrics across the
+// nodes and ranks of distributed runs.
+//
+// Each query first builds a samples CTE holding one value per series (a rank,
+// node or GPU) per step or time bucket, with the columns time, step, node_id,
+// rank, value and scanned, the raw rows behind the sample. That is then
+// either reduced across series or broken out per node and rank. Samples read
+// at most one raw row past the scan cap, so that the total of scanned tells
+// an overflow.
+type NodeRepository struct {
+	db     *pgxpool.Pool
+	limits RowLimits
+	logger *zap.Logger
+}
+
+func NewNodeRepository(db *pgxpool.Pool, limits RowLimits, logger *zap.Logger) *NodeRepository {
+	return &NodeRepository{
+		db:     db,
+		limits: limits,
+		logger: logger,
+	}
+}
+
+// GetMetricAcrossRanks aggregates a training metric per step across ranks
+func (r *NodeRepository) GetMetricAcrossRanks(ctx context.Context, runID uuid.UUID, metricName string, params model.NodeQueryParams) ([]model.AggregatePoint, error) {
+	return queryReduced(ctx, r.db, r.limits, rankSamples(runID, metricName, params, r.limits), "step", params.Reduce, params.Limit)
+}
+
+// GetMetricPerRank retrieves a training metric per step for each rank
+func (r *NodeRepository) GetMetricPerRank(ctx context.Context, runID uuid.UUID, metricName string, params model.NodeQueryParams) ([]model.NodeSeries, error) {
+	return r.breakOut(ctx, rankSamples(runID, metricName, params, r.limits), "step", params.Limit)
+}
+
+// GetSystemMetricAcrossNodes aggregates one system metric type per time bucket across nodes
+func (r *NodeRepository) GetSystemMetricAcrossNodes(ctx context.Context, runID uuid.UUID, params model.NodeQueryParams) ([]model.AggregatePoint, error) {
+	return queryReduced(ctx, r.db, r.limits, systemSamples(runID, params, r.limits), "time", params.Reduce, params.Limit)
+}
+
+// GetSystemMetricPerNode retrieves one system metric type per time bucket for each node
+func (r *NodeRepository) GetSystemMetricPerNode(ctx context.Context, runID uuid.UUID, params model.NodeQueryParams) ([]model.NodeSeries, error) {
+	return r.breakOut(ctx, systemSamples(runID, params, r.limits), "time", params.Limit)
+}
+
+// GetGPUFieldAcrossNodes aggregates one GPU reading per time bucket across every GPU of a run
+func (r *NodeRepository) GetGPUFieldAcrossNodes(ctx context.Context, runID uuid.UUID, params model.NodeQueryParams) ([]model.AggregatePoint, error) {
+	return queryReduced(ctx, r.db, r.limits, gpuSamples(runID, params, r.limits), "time", params.Reduce, params.Limit)
+}
+
+// GetGPUFieldPerNode retrieves one GPU reading per time bucket for each node,
+// averaged over the node's GPUs
+func (r *NodeRepository) GetGPUFieldPerNode(ctx context.Context, runID uuid.UUID, params model.NodeQueryParams) ([]model.NodeSeries, error) {
+	return r.breakOut(ctx, gpuSamples(runID, params, r.limits), "time", params.Limit)
+}
+
+// rankSamples averages each rank's values of a metric per step. Values
+// logged without a rank, including the canonical series of metrics reduced on
+// ingest, are not a rank's and are left out.
+func rankSamples(runID uuid.UUID, metricName string, params model.NodeQueryParams, limits RowLimits) *queryBuilder {
+	q := newQuery(`SELECT MAX(time) AS time, step, node_id, rank, AVG(value) AS value, COUNT(*) AS scanned
+	               FROM (
+	                 SELECT time, step, node_id, rank, value
+	                 FROM metrics
+	                 WHERE run_id = ?`, runID)
+	// Ranks of metrics reduced on ingest keep their values under RankMetricName
+	name := q.Arg(metricName)
+	q.Add(fmt.Sprintf(` AND (metric_name = %[1]s OR starts_with(metric_name, %[1]s || '/rank_'))
+	                    AND rank IS NOT NULL AND step IS NOT NULL AND value IS NOT NULL`, name))
+	nodeFilters(q, params)
+	addIfSet(q, " AND step >= ?", params.MinStep)
+	addIfSet(q, " AND step <= ?", params.MaxStep)
+	return q.Add(" LIMIT ?) m GROUP BY step, node_id, rank", limits.scanLimit())
+}
+
+// systemSamples averages each node's (and rank's) values of a metric type per time bucket
+func systemSamples(runID uuid.UUID, params model.NodeQueryParams, limits RowLimits) *queryBuilder {
+	q := newQuery(`SELECT time_bucket(make_interval(secs => ?), time) AS time, NULL::bigint AS step,
+	                      node_id, rank, AVG(value) AS value, COUNT(*) AS scanned
+	               FROM (
+	                 SELECT time, node_id, rank, value
+	                 FROM system_metrics
+	                 WHERE run_id = ? AND metric_type = ?`, float64(params.Bucket), runID, params.MetricType)
+	nodeFilters(q, params)
+	return q.Add(" LIMIT ?) s GROUP BY 1, node_id, rank", limits.scanLimit())
+}
+
+// gpuSamples averages each GPU's readings of one field per time bucket. The
+// field must be one of model.GPUAggregateFields.
+func gpuSamples(runID uuid.UUID, params model.NodeQueryParams, limits RowLimits) *queryBuilder {
+	q := newQuery(fmt.Sprintf(`SELECT time_bucket(make_interval(secs => ?), time) AS time, NULL::bigint AS step,
+	                                  node_id, NULL::integer AS rank, AVG(%[1]s) AS value, COUNT(*) AS scanned
+	                           FROM (
+	                             SELECT time, node_id, device_uuid, device_index, %[1]s
+	                             FROM gpu_metrics
+	                             WHERE run_id = ? AND %[1]s IS NOT NULL`, params.Field), float64(params.Bucket), runID)
+	nodeFilters(q, params)
+	return q.Add(" LIMIT ?) g GROUP BY 1, node_id, device_uuid, device_index", limits.scanLimit())
+}
+
+// nodeFilters adds the time range and node conditions shared by every samples query
+func nodeFilters(q *queryBuilder, params model.NodeQueryParams) {
+	addIfSet(q, " AND time >= ?", params.StartTime)
+	addIfSet(q, " AND time <= ?", params.EndTime)
+	addIfNotZero(q, " AND node_id = ?", params.NodeID)
+}
+
+// queryReduced combines the series of a samples CTE per step or time bucket
+// with the given reduction, keeping the latest limit points. Only the time,
+// step, value and scanned columns of the samples are used.
+func queryReduced(ctx context.Context, db *pgxpool.Pool, limits RowLimits, samples *queryBuilder, key, reduce string, limit int) ([]model.AggregatePoint, error) {
+	aggFunc, ok := reduceFuncs[reduce]
+	if !ok {
+		return nil, fmt.Errorf("unsupported reduction %q", reduce)
+	}
+
+	q := samples.Wrap("WITH samples AS (").Add(fmt.Sprintf(
+		`)
+		 SELECT time, step, value, min_value, max_value, count,
+		        (SELECT SUM(scanned) FROM samples)::bigint AS scanned
+		 FROM (
+		   SELECT MAX(time) AS time, MAX(step) AS step, %s(value) AS value,
+		          MIN(value) AS min_value, MAX(value) AS max_value, COUNT(*) AS count
+		   FROM samples
+		   GROUP BY %s
+		   ORDER BY %s DESC
+		   LIMIT ?
+		 ) reduced
+		 ORDER BY %s`, aggFunc, key, key, key), limits.resultLimit(limit))
+
+	rows, err := q.Query(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query aggregate: %w", err)
+	}
+	var scanned int64
+	points, err := pgx.CollectRows(rows, scanner(func(p *model.AggregatePoint) []interface{} {
+		return []interface{}{&p.Time, &p.Step, &p.Value, &p.Min, &p.Max, &p.Count, &scanned}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read aggregate: %w", err)
+	}
+	if err := limits.checkScannedRows("metric values", scanned); err != nil {
+		return nil, err
+	}
+	return points, limits.checkResultRows("aggregate points", len(points))
+}
+
+// breakOut returns the samples of a samples CTE as one series per node and
+// rank, keeping the latest limit points of each
+func (r *NodeRepository) breakOut(ctx context.Context, samples *queryBuilder, key string, limit int) ([]model.NodeSeries, error) {
+	q := samples.Wrap("WITH samples AS (").Add(fmt.Sprintf(
+		`)
+		 SELECT node_id, rank, time, step, value, min_value, max_value, count,
+		        (SELECT SUM(scanned) FROM samples)::bigint AS scanned
+		 FROM (
+		   SELECT node_id, rank, MAX(time) AS time, MAX(step) AS step, AVG(value) AS value,
+		          MIN(value) AS min_value, MAX(value) AS max_value, COUNT(*) AS count,
+		          ROW_NUMBER() OVER (PARTITION BY node_id, rank ORDER BY %s DESC) AS rn
+		   FROM samples
+		   GROUP BY node_id, rank, %s
+		 ) ranked
+		 WHERE rn <= ?
+		 ORDER BY node_id, rank NULLS FIRST, %s
+		 LIMIT ?`, key, key, key), limit, r.limits.resultLimit(0))
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query node series: %w", err)
+	}
+	type nodePoint struct {
+		nodeID string
+		rank   *int
+		point  model.AggregatePoint
+	}
+	var scanned int64
+	points, err := pgx.CollectRows(rows, scanner(func(p *nodePoint) []interface{} {
+		return []interface{}{&p.nodeID, &p.rank, &p.point.Time, &p.point.Step, &p.point.Value, &p.point.Min, &p.point.Max, &p.point.Count, &scanned}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node series: %w", err)
+	}
+	if err := r.limits.checkScannedRows("metric values", scanned); err != nil {
+		return nil, err
+	}
+	if err := r.limits.checkResultRows("node series points", len(points)); err != nil {
+		return nil, err
+	}
+
+	// Rows arrive ordered by node and rank, so each series is one contiguous run
+	var series []model.NodeSeries
+	for _, p := range points {
+		last := len(series) - 1
+		if last < 0 || series[last].NodeID != p.nodeID || !sameRank(series[last].Rank, p.rank) {
+			series = append(series, model.NodeSeries{NodeID: p.nodeID, Rank: p.rank})
+			last++
+		}
+		series[last].Points = append(series[last].Points, p.point)
+	}
+	return series, nil
+}
+
+func sameRank(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
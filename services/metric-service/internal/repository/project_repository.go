@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type ProjectRepository struct {
+	db     *pgxpool.Pool
+	limits RowLimits
+	logger *zap.Logger
+}
+
+func NewProjectRepository(db *pgxpool.Pool, limits RowLimits, logger *zap.Logger) *ProjectRepository {
+	return &ProjectRepository{
+		db:     db,
+		limits: limits,
+		logger: logger,
+	}
+}
+
+// RegisterRuns records the project of each run and marks it as seen at
+// LastSeenAt. A run keeps its experiment when a later batch omits it.
+func (r *ProjectRepository) RegisterRuns(ctx context.Context, runs []model.RunProject) error {
+	if len(runs) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, run := range runs {
+		batch.Queue(
+			`INSERT INTO run_projects (run_id, project_id, experiment_id, first_seen_at, last_seen_at)
+			 VALUES ($1, $2, $3, $4, $4)
+			 ON CONFLICT (run_id) DO UPDATE SET
+			   project_id = EXCLUDED.project_id,
+			   experiment_id = COALESCE(EXCLUDED.experiment_id, run_projects.experiment_id),
+			   last_seen_at = GREATEST(run_projects.last_seen_at, EXCLUDED.last_seen_at)`,
+			run.RunID, run.ProjectID, run.ExperimentID, run.LastSeenAt,
+		)
+	}
+
+	br := r.db.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for i := 0; i < len(runs); i++ {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("failed to register run %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// SetRunProject moves a run to a project and experiment
+func (r *ProjectRepository) SetRunProject(ctx context.Context, run *model.RunProject) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO run_projects (run_id, project_id, experiment_id)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (run_id) DO UPDATE SET
+		   project_id = EXCLUDED.project_id,
+		   experiment_id = EXCLUDED.experiment_id
+		 RETURNING first_seen_at, last_seen_at`,
+		run.RunID, run.ProjectID, run.ExperimentID,
+	).Scan(&run.FirstSeenAt, &run.LastSeenAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert run project: %w", err)
+	}
+	return nil
+}
+
+// GetRunProject retrieves the project of a run
+func (r *ProjectRepository) GetRunProject(ctx context.Context, runID uuid.UUID) (*model.RunProject, error) {
+	var run model.RunProject
+	err := r.db.QueryRow(ctx,
+		`SELECT run_id, project_id, experiment_id, first_seen_at, last_seen_at
+		 FROM run_projects
+		 WHERE run_id = $1`,
+		runID,
+	).Scan(&run.RunID, &run.ProjectID, &run.ExperimentID, &run.FirstSeenAt, &run.LastSeenAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query run project: %w", err)
+	}
+	return &run, nil
+}
+
+// ListProjectRuns lists the runs of a project, most recently active first,
+// with the latest value of one metric from the run summaries
+func (r *ProjectRepository) ListProjectRuns(ctx context.Context, projectID uuid.UUID, experimentID *uuid.UUID, activeSince *time.Time, metricName string, limit int) ([]model.ProjectRun, error) {
+	q := newQuery(`SELECT p.run_id, p.project_id, p.experiment_id, p.first_seen_at, p.last_seen_at,
+	                      s.final_value, s.final_step, s.final_time
+	               FROM run_projects p
+	               LEFT JOIN run_summary s ON s.run_id = p.run_id AND s.metric_name = ?
+	               WHERE p.project_id = ?`, metricName, projectID)
+	addIfSet(q, " AND p.experiment_id = ?", experimentID)
+	addIfSet(q, " AND p.last_seen_at >= ?", activeSince)
+	q.Add(" ORDER BY p.last_seen_at DESC")
+	addIfNotZero(q, " LIMIT ?", limit)
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query project runs: %w", err)
+	}
+	runs, err := pgx.CollectRows(rows, scanner(func(run *model.ProjectRun) []interface{} {
+		run.MetricName = metricName
+		return []interface{}{&run.RunID, &run.ProjectID, &run.ExperimentID, &run.FirstSeenAt, &run.LastSeenAt,
+			&run.LatestValue, &run.LatestStep, &run.LatestTime}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project runs: %w", err)
+	}
+	return runs, nil
+}
+
+// ListLatestMetrics returns the latest value of a metric, by time, of each
+// run of a project active since activeSince, most recently active first.
+// Runs that have not logged the metric are left out.
+func (r *ProjectRepository) ListLatestMetrics(ctx context.Context, projectID uuid.UUID, experimentID *uuid.UUID, activeSince time.Time, metricName string, limit int) ([]model.ProjectRunMetric, error) {
+	q := newQuery(`SELECT p.run_id, p.project_id, p.experiment_id, p.first_seen_at, p.last_seen_at, m.*
+	               FROM run_projects p
+	               JOIN LATERAL (
+	                 SELECT `+metricColumns+`
+	                 FROM metrics
+	                 WHERE run_id = p.run_id AND metric_name = ?
+	                 ORDER BY time DESC
+	                 LIMIT 1
+	               ) m ON true
+	               WHERE p.project_id = ? AND p.last_seen_at >= ?`, metricName, projectID, activeSince)
+	addIfSet(q, " AND p.experiment_id = ?", experimentID)
+	q.Add(" ORDER BY p.last_seen_at DESC")
+	addIfNotZero(q, " LIMIT ?", limit)
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest metrics: %w", err)
+	}
+	runs, err := pgx.CollectRows(rows, scanner(func(run *model.ProjectRunMetric) []interface{} {
+		return append([]interface{}{&run.RunID, &run.ProjectID, &run.ExperimentID, &run.FirstSeenAt, &run.LastSeenAt},
+			metricFields(&run.Metric)...)
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read latest metrics: %w", err)
+	}
+	return runs, nil
+}
+
+// ListExperiments summarizes the experiments of a project, most recently active first
+func (r *ProjectRepository) ListExperiments(ctx context.Context, projectID uuid.UUID) ([]model.ExperimentInfo, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT experiment_id, COUNT(*), MIN(first_seen_at), MAX(last_seen_at)
+		 FROM run_projects
+		 WHERE project_id = $1
+		 GROUP BY experiment_id
+		 ORDER BY MAX(last_seen_at) DESC
+		 LIMIT $2`,
+		projectID, r.limits.resultLimit(0),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query experiments: %w", err)
+	}
+	experiments, err := pgx.CollectRows(rows, scanner(func(e *model.ExperimentInfo) []interface{} {
+		return []interface{}{&e.ExperimentID, &e.RunCount, &e.FirstSeenAt, &e.LastSeenAt}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read experiments: %w", err)
+	}
+
+	return experiments, r.limits.checkResultRows("experiments", len(experiments))
+}
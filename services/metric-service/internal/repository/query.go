@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// querier runs statements, on a pool or in a transaction
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+// queryBuilder assembles a statement from fragments in which each ? stands
+// for the next of the fragment's arguments. Placeholders are numbered as the
+// arguments are added, so optional filters cannot get them out of step. The
+// statements here use no ? operators.
+type queryBuilder struct {
+	sql  strings.Builder
+	args []interface{}
+	err  error
+}
+
+// newQuery starts a statement with fragment
+func newQuery(fragment string, args ...interface{}) *queryBuilder {
+	return new(queryBuilder).Add(fragment, args...)
+}
+
+// Add appends fragment, binding its placeholders to args. A fragment whose
+// placeholders and arguments do not match is left out, and the mismatch
+// returned by Err.
+func (q *queryBuilder) Add(fragment string, args ...interface{}) *queryBuilder {
+	if n := strings.Count(fragment, "?"); n != len(args) {
+		if q.err == nil {
+			q.err = fmt.Errorf("query fragment %q has %d placeholders for %d arguments", fragment, n, len(args))
+		}
+		return q
+	}
+	for _, arg := range args {
+		i := strings.IndexByte(fragment, '?')
+		q.sql.WriteString(fragment[:i])
+		q.sql.WriteString(q.Arg(arg))
+		fragment = fragment[i+1:]
+	}
+	q.sql.WriteString(fragment)
+	return q
+}
+
+// Wrap puts prefix before the statement so far, as when it becomes a
+// subquery or CTE. prefix binds no arguments, so the placeholders stay valid.
+func (q *queryBuilder) Wrap(prefix string) *queryBuilder {
+	sql := q.sql.String()
+	q.sql.Reset()
+	q.sql.WriteString(prefix)
+	q.sql.WriteString(sql)
+	return q
+}
+
+// Arg binds an argument without adding SQL and returns its placeholder, for
+// fragments such as IN lists or ones reused in several places
+func (q *queryBuilder) Arg(arg interface{}) string {
+	q.args = append(q.args, arg)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// String returns the statement
+func (q *queryBuilder) String() string {
+	return q.sql.String()
+}
+
+// Args returns the arguments of the statement's placeholders, in order
+func (q *queryBuilder) Args() []interface{} {
+	return q.args
+}
+
+// Err returns the first fragment added with mismatched arguments, which
+// leaves the statement unusable
+func (q *queryBuilder) Err() error {
+	return q.err
+}
+
+// Query runs the statement on db, unless building it failed
+func (q *queryBuilder) Query(ctx context.Context, db querier) (pgx.Rows, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return db.Query(ctx, q.String(), q.args...)
+}
+
+// addIfSet appends fragment bound to *value when value is not nil
+func addIfSet[T any](q *queryBuilder, fragment string, value *T) {
+	if value != nil {
+		q.Add(fragment, *value)
+	}
+}
+
+// addIfNotZero appends fragment bound to value unless it is the zero value,
+// such as an unset name or limit
+func addIfNotZero[T comparable](q *queryBuilder, fragment string, value T) {
+	var zero T
+	if value != zero {
+		q.Add(fragment, value)
+	}
+}
+
+// scanner reads one row into a T, for pgx.CollectRows. It takes the scan
+// targets of a T, in the order of the statement's columns.
+func scanner[T any](targets func(*T) []interface{}) pgx.RowToFunc[T] {
+	return func(row pgx.CollectableRow) (T, error) {
+		var value T
+		err := row.Scan(targets(&value)...)
+		return value, err
+	}
+}
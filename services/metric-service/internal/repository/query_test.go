@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	step := int64(10)
+	tests := []struct {
+		name     string
+		build    func() *queryBuilder
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			name: "numbered in order",
+			build: func() *queryBuilder {
+				return newQuery("SELECT * FROM m WHERE a = ? AND b = ?", 1, 2).Add(" LIMIT ?", 3)
+			},
+			wantSQL:  "SELECT * FROM m WHERE a = $1 AND b = $2 LIMIT $3",
+			wantArgs: []interface{}{1, 2, 3},
+		},
+		{
+			name: "optional filters",
+			build: func() *queryBuilder {
+				q := newQuery("SELECT * FROM m WHERE run_id = ?", "run")
+				addIfSet[int64](q, " AND step >= ?", nil)
+				addIfSet(q, " AND step <= ?", &step)
+				addIfNotZero(q, " AND metric_name = ?", "")
+				addIfNotZero(q, " LIMIT ?", 5)
+				return q
+			},
+			wantSQL:  "SELECT * FROM m WHERE run_id = $1 AND step <= $2 LIMIT $3",
+			wantArgs: []interface{}{"run", step, 5},
+		},
+		{
+			name: "wrapped",
+			build: func() *queryBuilder {
+				return newQuery("SELECT value FROM m WHERE a = ?", 1).Wrap("WITH s AS (").Add(") SELECT * FROM s LIMIT ?", 2)
+			},
+			wantSQL:  "WITH s AS (SELECT value FROM m WHERE a = $1) SELECT * FROM s LIMIT $2",
+			wantArgs: []interface{}{1, 2},
+		},
+		{
+			name: "reused argument",
+			build: func() *queryBuilder {
+				q := newQuery("SELECT * FROM m WHERE a = ?", 1)
+				p := q.Arg("x")
+				return q.Add(" AND (b = " + p + " OR c = " + p + ")")
+			},
+			wantSQL:  "SELECT * FROM m WHERE a = $1 AND (b = $2 OR c = $2)",
+			wantArgs: []interface{}{1, "x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.build()
+			if err := q.Err(); err != nil {
+				t.Fatalf("Err() = %v", err)
+			}
+			if got := q.String(); got != tt.wantSQL {
+				t.Errorf("String() = %q, want %q", got, tt.wantSQL)
+			}
+			if got := q.Args(); !reflect.DeepEqual(got, tt.wantArgs) {
+				t.Errorf("Args() = %v, want %v", got, tt.wantArgs)
+			}
+		})
+	}
+}
+
+// failingQuerier fails the test when a query reaches it
+type failingQuerier struct{ t *testing.T }
+
+func (f failingQuerier) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	f.t.Fatal("query ran despite a mismatched fragment")
+	return nil, nil
+}
+
+func TestQueryBuilderMismatch(t *testing.T) {
+	q := newQuery("SELECT * FROM m WHERE a = ?", 1).
+		Add(" AND b = ? AND c = ?", 2).
+		Add(" LIMIT ?")
+	if q.Err() == nil {
+		t.Fatal("Err() = nil, want the mismatched fragment")
+	}
+	if got, want := q.Err().Error(), `query fragment " AND b = ? AND c = ?" has 2 placeholders for 1 arguments`; got != want {
+		t.Errorf("Err() = %q, want the first mismatch %q", got, want)
+	}
+	if got, want := q.String(), "SELECT * FROM m WHERE a = $1"; got != want {
+		t.Errorf("String() = %q, want mismatched fragments left out: %q", got, want)
+	}
+	if _, err := q.Query(context.Background(), failingQuerier{t}); err != q.Err() {
+		t.Errorf("Query() = %v, want Err()", err)
+	}
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type ReportRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewReportRepository(db *pgxpool.Pool, logger *zap.Logger) *ReportRepository {
+	return &ReportRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CreateReport inserts a report together with its snapshot
+func (r *ReportRepository) CreateReport(ctx context.Context, report *model.Report) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO reports (id, name, description, run_ids, metric_names, config, snapshot)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING created_at, updated_at`,
+		report.ID, report.Name, report.Description, report.RunIDs, report.MetricNames, report.Config, report.Snapshot,
+	).Scan(&report.CreatedAt, &report.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert report: %w", err)
+	}
+	return nil
+}
+
+// GetReport retrieves a report, optionally including its snapshot
+func (r *ReportRepository) GetReport(ctx context.Context, reportID uuid.UUID, withSnapshot bool) (*model.Report, error) {
+	snapshotCol := "NULL::jsonb"
+	if withSnapshot {
+		snapshotCol = "snapshot"
+	}
+
+	var rep model.Report
+	err := r.db.QueryRow(ctx,
+		fmt.Sprintf(`SELECT id, name, description, run_ids, metric_names, config, %s, created_at, updated_at
+		             FROM reports
+		             WHERE id = $1`, snapshotCol),
+		reportID,
+	).Scan(&rep.ID, &rep.Name, &rep.Description, &rep.RunIDs, &rep.MetricNames, &rep.Config, &rep.Snapshot, &rep.CreatedAt, &rep.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query report: %w", err)
+	}
+	return &rep, nil
+}
+
+// ListReports retrieves report definitions without their snapshots, newest first
+func (r *ReportRepository) ListReports(ctx context.Context, limit, offset int) ([]model.Report, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT id, name, description, run_ids, metric_names, config, created_at, updated_at
+		 FROM reports
+		 ORDER BY created_at DESC
+		 LIMIT $1 OFFSET $2`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reports: %w", err)
+	}
+	reports, err := pgx.CollectRows(rows, scanner(func(rep *model.Report) []interface{} {
+		return []interface{}{&rep.ID, &rep.Name, &rep.Description, &rep.RunIDs, &rep.MetricNames, &rep.Config, &rep.CreatedAt, &rep.UpdatedAt}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reports: %w", err)
+	}
+	return reports, nil
+}
+
+// UpdateReport changes the name, description or chart config of a report
+func (r *ReportRepository) UpdateReport(ctx context.Context, reportID uuid.UUID, req model.UpdateReportRequest) (bool, error) {
+	// A nil map must reach COALESCE as SQL NULL rather than JSON null
+	var config interface{}
+	if req.Config != nil {
+		config = req.Config
+	}
+
+	tag, err := r.db.Exec(ctx,
+		`UPDATE reports
+		 SET name = COALESCE($2, name),
+		     description = COALESCE($3, description),
+		     config = COALESCE($4, config),
+		     updated_at = NOW()
+		 WHERE id = $1`,
+		reportID, req.Name, req.Description, config,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update report: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteReport removes a report
+func (r *ReportRepository) DeleteReport(ctx context.Context, reportID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM reports WHERE id = $1`, reportID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete report: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
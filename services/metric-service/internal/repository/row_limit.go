@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// RowLimits are hard caps on what one query may read, whatever limit its
+// caller asked for. MaxResultRows bounds the rows a list query returns;
+// MaxScanRows bounds the rows an aggregate over raw metrics reads;
+// MaxStreamRows bounds the rows a streamed query writes out, zero leaving
+// streams unbounded. Repositories reading unbounded data are given the caps
+// when created.
+type RowLimits struct {
+	MaxResultRows int
+	MaxScanRows   int
+	MaxStreamRows int
+}
+
+// DefaultRowLimits are the caps used when none are configured
+var DefaultRowLimits = RowLimits{
+	MaxResultRows: 100000,
+	MaxScanRows:   20000000,
+	MaxStreamRows: 100000000,
+}
+
+// Validate checks that the API's own limits fit under the caps
+func (l RowLimits) Validate() error {
+	if l.MaxResultRows < model.MaxMetricQueryLimit {
+		return fmt.Errorf("max result rows must be at least %d", model.MaxMetricQueryLimit)
+	}
+	if l.MaxScanRows < l.MaxResultRows {
+		return fmt.Errorf("max scan rows must be at least max result rows")
+	}
+	if l.MaxStreamRows != 0 && l.MaxStreamRows < model.MaxStreamedMetricQueryLimit {
+		return fmt.Errorf("max stream rows must be 0 or at least %d", model.MaxStreamedMetricQueryLimit)
+	}
+	return nil
+}
+
+// RowLimitError reports a query cut off at a row cap; the caller should
+// narrow it by time, step or limit
+type RowLimitError struct {
+	What  string
+	Limit int
+}
+
+func (e *RowLimitError) Error() string {
+	return fmt.Sprintf("query would read more than %d %s; narrow it by time, step or limit", e.Limit, e.What)
+}
+
+// resultLimit is the LIMIT for a caller's limit: the limit itself when set
+// and within the cap, else one row past the cap so that checkResultRows can
+// tell an overflow from a result of exactly the cap
+func (l RowLimits) resultLimit(limit int) int {
+	if limit > 0 && limit <= l.MaxResultRows {
+		return limit
+	}
+	return l.MaxResultRows + 1
+}
+
+// checkResultRows fails when a query limited by resultLimit returned more
+// rows than the cap
+func (l RowLimits) checkResultRows(what string, n int) error {
+	if n > l.MaxResultRows {
+		return &RowLimitError{What: what, Limit: l.MaxResultRows}
+	}
+	return nil
+}
+
+// scanLimit is the LIMIT of the raw rows an aggregate reads, one past the
+// cap so that checkScannedRows can tell an overflow
+func (l RowLimits) scanLimit() int {
+	return l.MaxScanRows + 1
+}
+
+// checkScannedRows fails when an aggregate over a subquery limited to
+// scanLimit rows counted more than MaxScanRows
+func (l RowLimits) checkScannedRows(what string, n int64) error {
+	if n > int64(l.MaxScanRows) {
+		return &RowLimitError{What: what, Limit: l.MaxScanRows}
+	}
+	return nil
+}
+
+// streamLimit is the LIMIT of a streamed query: the caller's limit when set
+// and within the cap, else one row past the cap, or no limit (0) when
+// neither bounds the stream
+func (l RowLimits) streamLimit(limit int) int {
+	if l.MaxStreamRows <= 0 || (limit > 0 && limit <= l.MaxStreamRows) {
+		return limit
+	}
+	return l.MaxStreamRows + 1
+}
+
+// checkStreamedRows fails once a stream limited by streamLimit reaches the
+// row past the cap, before that row is written
+func (l RowLimits) checkStreamedRows(what string, n int) error {
+	if l.MaxStreamRows > 0 && n > l.MaxStreamRows {
+		return &RowLimitError{What: what, Limit: l.MaxStreamRows}
+	}
+	return nil
+}
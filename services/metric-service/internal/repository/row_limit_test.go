@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRowLimitsResultLimit(t *testing.T) {
+	limits := RowLimits{MaxResultRows: 100}
+	tests := []struct {
+		limit, want int
+	}{
+		{0, 101},
+		{1, 1},
+		{100, 100},
+		{101, 101},
+		{5000, 101},
+	}
+	for _, tt := range tests {
+		if got := limits.resultLimit(tt.limit); got != tt.want {
+			t.Errorf("resultLimit(%d) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+
+	if err := limits.checkResultRows("rows", 100); err != nil {
+		t.Errorf("checkResultRows(100) = %v, want nil", err)
+	}
+	var limitErr *RowLimitError
+	if err := limits.checkResultRows("rows", 101); !errors.As(err, &limitErr) || limitErr.Limit != 100 {
+		t.Errorf("checkResultRows(101) = %v, want a RowLimitError at 100", err)
+	}
+}
+
+func TestRowLimitsScan(t *testing.T) {
+	limits := RowLimits{MaxScanRows: 1000}
+	if got := limits.scanLimit(); got != 1001 {
+		t.Errorf("scanLimit() = %d, want 1001", got)
+	}
+	if err := limits.checkScannedRows("values", 1000); err != nil {
+		t.Errorf("checkScannedRows(1000) = %v, want nil", err)
+	}
+	if err := limits.checkScannedRows("values", 1001); err == nil {
+		t.Error("checkScannedRows(1001) = nil, want an error")
+	}
+}
+
+func TestRowLimitsStream(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxStream int
+		limit     int
+		want      int
+		rows      int
+		wantError bool
+	}{
+		{"unbounded", 0, 0, 0, 1 << 30, false},
+		{"unbounded with limit", 0, 50, 50, 50, false},
+		{"capped export", 1000, 0, 1001, 1001, true},
+		{"export at the cap", 1000, 0, 1001, 1000, false},
+		{"limit within cap", 1000, 500, 500, 500, false},
+		{"limit past cap", 1000, 5000, 1001, 1001, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := RowLimits{MaxStreamRows: tt.maxStream}
+			if got := limits.streamLimit(tt.limit); got != tt.want {
+				t.Errorf("streamLimit(%d) = %d, want %d", tt.limit, got, tt.want)
+			}
+			if err := limits.checkStreamedRows("rows", tt.rows); (err != nil) != tt.wantError {
+				t.Errorf("checkStreamedRows(%d) = %v, want error %v", tt.rows, err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestRowLimitsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  RowLimits
+		wantErr bool
+	}{
+		{"defaults", DefaultRowLimits, false},
+		{"unbounded streams", RowLimits{MaxResultRows: 100000, MaxScanRows: 100000}, false},
+		{"result cap under API limit", RowLimits{MaxResultRows: 10, MaxScanRows: 100000}, true},
+		{"scan cap under result cap", RowLimits{MaxResultRows: 100000, MaxScanRows: 10}, true},
+		{"stream cap under API limit", RowLimits{MaxResultRows: 100000, MaxScanRows: 100000, MaxStreamRows: 10}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.limits.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
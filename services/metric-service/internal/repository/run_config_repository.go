@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type RunConfigRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewRunConfigRepository(db *pgxpool.Pool, logger *zap.Logger) *RunConfigRepository {
+	return &RunConfigRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SetRunConfig creates or replaces the configuration a run reported
+func (r *RunConfigRepository) SetRunConfig(ctx context.Context, cfg *model.RunConfig) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO run_configs (run_id, config)
+		 VALUES ($1, $2)
+		 ON CONFLICT (run_id) DO UPDATE SET
+		   config = EXCLUDED.config,
+		   updated_at = NOW()
+		 RETURNING updated_at`,
+		cfg.RunID, cfg.Config,
+	).Scan(&cfg.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert run config: %w", err)
+	}
+	return nil
+}
+
+// GetRunConfig retrieves the configuration of a run, falling back to the
+// configuration of the sweep trial it ran when it reported none
+func (r *RunConfigRepository) GetRunConfig(ctx context.Context, runID uuid.UUID) (*model.RunConfig, error) {
+	cfg := model.RunConfig{RunID: runID}
+	err := r.db.QueryRow(ctx,
+		`SELECT config, source, updated_at FROM (
+		   SELECT config, 'run' AS source, updated_at, 0 AS priority FROM run_configs WHERE run_id = $1
+		   UNION ALL
+		   SELECT config, 'sweep', updated_at, 1 FROM sweep_trials WHERE run_id = $1
+		 ) c
+		 ORDER BY priority, updated_at DESC
+		 LIMIT 1`,
+		runID,
+	).Scan(&cfg.Config, &cfg.Source, &cfg.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query run config: %w", err)
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type RunEventRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewRunEventRepository(db *pgxpool.Pool, logger *zap.Logger) *RunEventRepository {
+	return &RunEventRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// AppendEvent adds a state change to a run. check is called with the run's
+// latest event (nil for none) under a per-run lock, so concurrent reports are
+// validated against each other; an error from check aborts the insert.
+func (r *RunEventRepository) AppendEvent(ctx context.Context, e *model.RunEvent, check func(latest *model.RunEvent) error) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended('run_events:' || $1::text, 0))`, e.RunID); err != nil {
+		return fmt.Errorf("failed to lock run %s: %w", e.RunID, err)
+	}
+
+	latest, err := scanRunEvent(tx.QueryRow(ctx,
+		`SELECT id, run_id, time, state, previous_state, step, reason, source, metadata, created_at
+		 FROM run_events
+		 WHERE run_id = $1
+		 ORDER BY time DESC, created_at DESC
+		 LIMIT 1`,
+		e.RunID,
+	))
+	if err == pgx.ErrNoRows {
+		latest = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to query latest run event: %w", err)
+	}
+
+	if err := check(latest); err != nil {
+		return err
+	}
+	if latest != nil {
+		e.PreviousState = latest.State
+	}
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO run_events (id, run_id, time, state, previous_state, step, reason, source, metadata)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING created_at`,
+		e.ID, e.RunID, e.Time, e.State, e.PreviousState, e.Step, e.Reason, e.Source, e.Metadata,
+	).Scan(&e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert run event: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetRunEvents retrieves the state changes of a run in time order
+func (r *RunEventRepository) GetRunEvents(ctx context.Context, runID uuid.UUID, params model.RunEventQueryParams) ([]model.RunEvent, error) {
+	q := newQuery(`SELECT id, run_id, time, state, previous_state, step, reason, source, metadata, created_at
+	               FROM run_events
+	               WHERE run_id = ?`, runID)
+	addIfSet(q, " AND time >= ?", params.StartTime)
+	addIfSet(q, " AND time <= ?", params.EndTime)
+	q.Add(" ORDER BY time ASC, created_at ASC")
+	addIfNotZero(q, " LIMIT ?", params.Limit)
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query run events: %w", err)
+	}
+	events, err := pgx.CollectRows(rows, scanner(runEventTargets))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run events: %w", err)
+	}
+	return events, nil
+}
+
+func scanRunEvent(row pgx.Row) (*model.RunEvent, error) {
+	var e model.RunEvent
+	if err := row.Scan(runEventTargets(&e)...); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func runEventTargets(e *model.RunEvent) []interface{} {
+	return []interface{}{&e.ID, &e.RunID, &e.Time, &e.State, &e.PreviousState, &e.Step, &e.Reason, &e.Source, &e.Metadata, &e.CreatedAt}
+}
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type SummaryRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewSummaryRepository(db *pgxpool.Pool, logger *zap.Logger) *SummaryRepository {
+	return &SummaryRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// UpsertSummaries merges per-batch summaries into run_summary. The final value
+// only moves forward in time and the best value only improves under the stored goal.
+func (r *SummaryRepository) UpsertSummaries(ctx context.Context, summaries []model.RunMetricSummary) error {
+	if len(summaries) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, s := range summaries {
+		batch.Queue(
+			`INSERT INTO run_summary (run_id, metric_name, goal, final_value, final_step, final_time, best_value, best_step, best_time, count, updated_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+			 ON CONFLICT (run_id, metric_name) DO UPDATE SET
+			   final_value = CASE WHEN EXCLUDED.final_time >= run_summary.final_time THEN EXCLUDED.final_value ELSE run_summary.final_value END,
+			   final_step  = CASE WHEN EXCLUDED.final_time >= run_summary.final_time THEN EXCLUDED.final_step ELSE run_summary.final_step END,
+			   final_time  = GREATEST(EXCLUDED.final_time, run_summary.final_time),
+			   best_value  = CASE WHEN `+improves+` THEN EXCLUDED.best_value ELSE run_summary.best_value END,
+			   best_step   = CASE WHEN `+improves+` THEN EXCLUDED.best_step ELSE run_summary.best_step END,
+			   best_time   = CASE WHEN `+improves+` THEN EXCLUDED.best_time ELSE run_summary.best_time END,
+			   count       = run_summary.count + EXCLUDED.count,
+			   updated_at  = NOW()`,
+			s.RunID, s.MetricName, s.Goal, s.FinalValue, s.FinalStep, s.FinalTime, s.BestValue, s.BestStep, s.BestTime, s.Count,
+		)
+	}
+
+	br := r.db.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for i := 0; i < len(summaries); i++ {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("failed to upsert summary %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// improves is true when the incoming best value beats the stored one under the stored goal
+const improves = `((run_summary.goal = 'max' AND EXCLUDED.best_value > run_summary.best_value)
+			     OR (run_summary.goal <> 'max' AND EXCLUDED.best_value < run_summary.best_value))`
+
+// RecomputeRunSummary rebuilds the summary rows of a run from its full history
+func (r *SummaryRepository) RecomputeRunSummary(ctx context.Context, runID uuid.UUID, goalFor func(metricName string) string) (int, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT metric_name FROM run_metric_names WHERE run_id = $1`,
+		runID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query metric names: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, fmt.Errorf("failed to read metric names: %w", err)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM run_summary WHERE run_id = $1`, runID); err != nil {
+		return 0, fmt.Errorf("failed to clear run summary: %w", err)
+	}
+
+	for _, name := range names {
+		goal := goalFor(name)
+		bestOrder := "value ASC"
+		if goal == model.GoalMax {
+			bestOrder = "value DESC"
+		}
+
+		_, err := tx.Exec(ctx, fmt.Sprintf(
+			`WITH s AS (
+			   SELECT time, step, value FROM metrics
+			   WHERE run_id = $1 AND metric_name = $2 AND value <> 'NaN'::float8
+			     AND value <> 'Infinity'::float8 AND value <> '-Infinity'::float8
+			 ), f AS (
+			   SELECT time, step, value FROM s ORDER BY time DESC LIMIT 1
+			 ), b AS (
+			   SELECT time, step, value FROM s ORDER BY %s, time ASC LIMIT 1
+			 )
+			 INSERT INTO run_summary (run_id, metric_name, goal, final_value, final_step, final_time, best_value, best_step, best_time, count, updated_at)
+			 SELECT $1, $2, $3, f.value, f.step, f.time, b.value, b.step, b.time, (SELECT COUNT(*) FROM s), NOW()
+			 FROM f, b`, bestOrder),
+			runID, name, goal,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to recompute summary for %s: %w", name, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(names), nil
+}
+
+// GetRunSummary retrieves all metric summaries of a run
+func (r *SummaryRepository) GetRunSummary(ctx context.Context, runID uuid.UUID) ([]model.RunMetricSummary, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT run_id, metric_name, goal, final_value, final_step, final_time, best_value, best_step, best_time, count, updated_at
+		 FROM run_summary
+		 WHERE run_id = $1
+		 ORDER BY metric_name`,
+		runID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query run summary: %w", err)
+	}
+	summaries, err := pgx.CollectRows(rows, scanSummary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run summary: %w", err)
+	}
+	return summaries, nil
+}
+
+// ListSummaries ranks runs by the best or final value of one metric
+func (r *SummaryRepository) ListSummaries(ctx context.Context, params model.SummaryQueryParams, runIDs []uuid.UUID) ([]model.RunMetricSummary, error) {
+	column := "best_value"
+	if params.Sort == "final" {
+		column = "final_value"
+	}
+
+	q := newQuery(`SELECT s.run_id, s.metric_name, s.goal, s.final_value, s.final_step, s.final_time,
+	                      s.best_value, s.best_step, s.best_time, s.count, s.updated_at
+	               FROM run_summary s`)
+	summaryFilters(q, params, runIDs, params.Group != "" || params.JobType != "")
+
+	// Rank in the direction of each row's goal
+	q.Add(fmt.Sprintf(" ORDER BY CASE WHEN s.goal = 'max' THEN -s.%s ELSE s.%s END ASC", column, column))
+	addIfNotZero(q, " LIMIT ?", params.Limit)
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query summaries: %w", err)
+	}
+	summaries, err := pgx.CollectRows(rows, scanSummary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read summaries: %w", err)
+	}
+	return summaries, nil
+}
+
+// ListGroupSummaries ranks run groups by the mean best or final value of one
+// metric over their runs
+func (r *SummaryRepository) ListGroupSummaries(ctx context.Context, params model.SummaryQueryParams, runIDs []uuid.UUID) ([]model.GroupMetricSummary, error) {
+	column := "s.best_value"
+	if params.Sort == "final" {
+		column = "s.final_value"
+	}
+	// Sorts ascending in the direction of the goal
+	ranked := fmt.Sprintf("CASE WHEN s.goal = 'max' THEN -%s ELSE %s END", column, column)
+
+	q := newQuery(fmt.Sprintf(
+		`SELECT g.group_name, s.metric_name, MAX(s.goal), COUNT(*),
+		        AVG(%[1]s), STDDEV(%[1]s), MIN(%[1]s), MAX(%[1]s),
+		        (ARRAY_AGG(s.run_id ORDER BY %[2]s))[1]
+		 FROM run_summary s`, column, ranked))
+	summaryFilters(q, params, runIDs, true)
+	q.Add(fmt.Sprintf(" GROUP BY g.group_name, s.metric_name ORDER BY AVG(%s) ASC", ranked))
+	addIfNotZero(q, " LIMIT ?", params.Limit)
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group summaries: %w", err)
+	}
+	summaries, err := pgx.CollectRows(rows, scanner(func(g *model.GroupMetricSummary) []interface{} {
+		return []interface{}{&g.Group, &g.MetricName, &g.Goal, &g.RunCount,
+			&g.Mean, &g.StdDev, &g.Min, &g.Max, &g.BestRunID}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read group summaries: %w", err)
+	}
+	return summaries, nil
+}
+
+// summaryFilters adds the joins and conditions shared by the summary
+// listings to q; joinGroups joins each run to its group as g
+func summaryFilters(q *queryBuilder, params model.SummaryQueryParams, runIDs []uuid.UUID, joinGroups bool) {
+	if joinGroups {
+		q.Add(" JOIN run_groups g ON g.run_id = s.run_id")
+	}
+	if params.ProjectID != nil {
+		q.Add(" JOIN run_projects p ON p.run_id = s.run_id")
+	}
+
+	q.Add(" WHERE s.metric_name = ?", params.MetricName)
+	if len(runIDs) > 0 {
+		q.Add(" AND s.run_id = ANY(?)", runIDs)
+	}
+	addIfSet(q, " AND p.project_id = ?", params.ProjectID)
+	addIfNotZero(q, " AND g.group_name = ?", params.Group)
+	addIfNotZero(q, " AND g.job_type = ?", params.JobType)
+}
+
+var scanSummary = scanner(func(s *model.RunMetricSummary) []interface{} {
+	return []interface{}{&s.RunID, &s.MetricName, &s.Goal, &s.FinalValue, &s.FinalStep, &s.FinalTime, &s.BestValue, &s.BestStep, &s.BestTime, &s.Count, &s.UpdatedAt}
+})
+
+// GetMetricSummary retrieves the summary of one metric in a run
+func (r *SummaryRepository) GetMetricSummary(ctx context.Context, runID uuid.UUID, metricName string) (*model.RunMetricSummary, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT run_id, metric_name, goal, final_value, final_step, final_time, best_value, best_step, best_time, count, updated_at
+		 FROM run_summary
+		 WHERE run_id = $1 AND metric_name = $2`,
+		runID, metricName,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric summary: %w", err)
+	}
+	summary, err := pgx.CollectOneRow(rows, scanSummary)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric summary: %w", err)
+	}
+	return &summary, nil
+}
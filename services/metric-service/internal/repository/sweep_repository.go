@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// SuggestFunc proposes the config for the next trial given the sweep and its existing trials
+type SuggestFunc func(sweep *model.Sweep, trials []model.SweepTrial) (map[string]interface{}, error)
+
+type SweepRepository struct {
+	db     *pgxpool.Pool
+	limits RowLimits
+	logger *zap.Logger
+}
+
+func NewSweepRepository(db *pgxpool.Pool, limits RowLimits, logger *zap.Logger) *SweepRepository {
+	return &SweepRepository{
+		db:     db,
+		limits: limits,
+		logger: logger,
+	}
+}
+
+// CreateSweep inserts a new sweep definition
+func (r *SweepRepository) CreateSweep(ctx context.Context, sweep *model.Sweep) error {
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO sweeps (id, name, objective_metric, goal, strategy, search_space, max_trials, status)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING created_at, updated_at`,
+		sweep.ID, sweep.Name, sweep.ObjectiveMetric, sweep.Goal, sweep.Strategy, sweep.SearchSpace, sweep.MaxTrials, sweep.Status,
+	).Scan(&sweep.CreatedAt, &sweep.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert sweep: %w", err)
+	}
+	return nil
+}
+
+// GetSweep retrieves a sweep with its trial count
+func (r *SweepRepository) GetSweep(ctx context.Context, sweepID uuid.UUID) (*model.Sweep, error) {
+	var s model.Sweep
+	err := r.db.QueryRow(ctx,
+		`SELECT id, name, objective_metric, goal, strategy, search_space, max_trials, status, created_at, updated_at,
+		        (SELECT COUNT(*) FROM sweep_trials WHERE sweep_id = sweeps.id)
+		 FROM sweeps
+		 WHERE id = $1`,
+		sweepID,
+	).Scan(&s.ID, &s.Name, &s.ObjectiveMetric, &s.Goal, &s.Strategy, &s.SearchSpace, &s.MaxTrials, &s.Status, &s.CreatedAt, &s.UpdatedAt, &s.TrialCount)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sweep: %w", err)
+	}
+	return &s, nil
+}
+
+// SetSweepStatus updates the status of a sweep
+func (r *SweepRepository) SetSweepStatus(ctx context.Context, sweepID uuid.UUID, status string) error {
+	_, err := r.db.Exec(ctx,
+		`UPDATE sweeps SET status = $2, updated_at = NOW() WHERE id = $1`,
+		sweepID, status,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update sweep status: %w", err)
+	}
+	return nil
+}
+
+// ListTrials retrieves all trials of a sweep ordered by trial number
+func (r *SweepRepository) ListTrials(ctx context.Context, sweepID uuid.UUID) ([]model.SweepTrial, error) {
+	return r.listTrials(ctx, r.db, sweepID)
+}
+
+// CreateTrial allocates the next trial of a sweep. The sweep row is locked
+// while suggest runs so concurrent agents never receive the same trial number.
+func (r *SweepRepository) CreateTrial(ctx context.Context, sweepID uuid.UUID, runID *uuid.UUID, suggest SuggestFunc) (*model.SweepTrial, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var s model.Sweep
+	err = tx.QueryRow(ctx,
+		`SELECT id, name, objective_metric, goal, strategy, search_space, max_trials, status, created_at, updated_at
+		 FROM sweeps
+		 WHERE id = $1
+		 FOR UPDATE`,
+		sweepID,
+	).Scan(&s.ID, &s.Name, &s.ObjectiveMetric, &s.Goal, &s.Strategy, &s.SearchSpace, &s.MaxTrials, &s.Status, &s.CreatedAt, &s.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock sweep: %w", err)
+	}
+
+	trials, err := r.listTrials(ctx, tx, sweepID)
+	if err != nil {
+		return nil, err
+	}
+	s.TrialCount = len(trials)
+
+	config, err := suggest(&s, trials)
+	if err != nil {
+		return nil, err
+	}
+
+	trial := model.SweepTrial{
+		ID:          uuid.New(),
+		SweepID:     sweepID,
+		TrialNumber: len(trials),
+		RunID:       runID,
+		Config:      config,
+		Status:      model.TrialStatusRunning,
+	}
+	err = tx.QueryRow(ctx,
+		`INSERT INTO sweep_trials (id, sweep_id, trial_number, run_id, config, status)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING created_at, updated_at`,
+		trial.ID, trial.SweepID, trial.TrialNumber, trial.RunID, trial.Config, trial.Status,
+	).Scan(&trial.CreatedAt, &trial.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert trial: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &trial, nil
+}
+
+// GetTrial retrieves a single trial of a sweep
+func (r *SweepRepository) GetTrial(ctx context.Context, sweepID, trialID uuid.UUID) (*model.SweepTrial, error) {
+	var t model.SweepTrial
+	err := r.db.QueryRow(ctx,
+		`SELECT id, sweep_id, trial_number, run_id, config, status, objective_value, created_at, updated_at
+		 FROM sweep_trials
+		 WHERE sweep_id = $1 AND id = $2`,
+		sweepID, trialID,
+	).Scan(&t.ID, &t.SweepID, &t.TrialNumber, &t.RunID, &t.Config, &t.Status, &t.ObjectiveValue, &t.CreatedAt, &t.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trial: %w", err)
+	}
+	return &t, nil
+}
+
+// CompleteTrial records the outcome of a trial
+func (r *SweepRepository) CompleteTrial(ctx context.Context, sweepID, trialID uuid.UUID, runID *uuid.UUID, status string, objective *float64) (*model.SweepTrial, error) {
+	var t model.SweepTrial
+	err := r.db.QueryRow(ctx,
+		`UPDATE sweep_trials
+		 SET status = $3, run_id = COALESCE($4, run_id), objective_value = $5, updated_at = NOW()
+		 WHERE sweep_id = $1 AND id = $2
+		 RETURNING id, sweep_id, trial_number, run_id, config, status, objective_value, created_at, updated_at`,
+		sweepID, trialID, status, runID, objective,
+	).Scan(&t.ID, &t.SweepID, &t.TrialNumber, &t.RunID, &t.Config, &t.Status, &t.ObjectiveValue, &t.CreatedAt, &t.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update trial: %w", err)
+	}
+	return &t, nil
+}
+
+// GetLeaderboard ranks the trials of a sweep by objective value. Trials that
+// have not reported yet are ranked by the best objective value logged so far.
+func (r *SweepRepository) GetLeaderboard(ctx context.Context, sweep *model.Sweep, limit int) ([]model.SweepTrial, error) {
+	agg, order := "MIN(m.value)", "ASC"
+	if sweep.Goal == model.GoalMax {
+		agg, order = "MAX(m.value)", "DESC"
+	}
+
+	query := fmt.Sprintf(`SELECT t.id, t.sweep_id, t.trial_number, t.run_id, t.config, t.status,
+	                 COALESCE(t.objective_value, live.value) AS objective,
+	                 t.created_at, t.updated_at
+	          FROM sweep_trials t
+	          LEFT JOIN LATERAL (
+	            SELECT %s AS value FROM metrics m
+	            WHERE m.run_id = t.run_id AND m.metric_name = $2
+	              AND m.value NOT IN ('NaN'::float8, 'Infinity'::float8, '-Infinity'::float8)
+	          ) live ON t.objective_value IS NULL
+	          WHERE t.sweep_id = $1 AND t.status <> $3
+	          ORDER BY objective %s NULLS LAST, t.trial_number
+	          LIMIT $4`, agg, order)
+
+	rows, err := r.db.Query(ctx, query, sweep.ID, sweep.ObjectiveMetric, model.TrialStatusFailed, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leaderboard: %w", err)
+	}
+	trials, err := pgx.CollectRows(rows, scanTrial)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leaderboard: %w", err)
+	}
+	return trials, nil
+}
+
+var scanTrial = scanner(func(t *model.SweepTrial) []interface{} {
+	return []interface{}{&t.ID, &t.SweepID, &t.TrialNumber, &t.RunID, &t.Config, &t.Status, &t.ObjectiveValue, &t.CreatedAt, &t.UpdatedAt}
+})
+
+func (r *SweepRepository) listTrials(ctx context.Context, q querier, sweepID uuid.UUID) ([]model.SweepTrial, error) {
+	rows, err := q.Query(ctx,
+		`SELECT id, sweep_id, trial_number, run_id, config, status, objective_value, created_at, updated_at
+		 FROM sweep_trials
+		 WHERE sweep_id = $1
+		 ORDER BY trial_number
+		 LIMIT $2`,
+		sweepID, r.limits.resultLimit(0),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trials: %w", err)
+	}
+	trials, err := pgx.CollectRows(rows, scanTrial)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trials: %w", err)
+	}
+
+	return trials, r.limits.checkResultRows("trials", len(trials))
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// tableData is the JSONB content of a table metric row
+type tableData struct {
+	Columns []string        `json:"columns,omitempty"`
+	Rows    [][]interface{} `json:"rows,omitempty"`
+	Labels  []string        `json:"labels,omitempty"`
+	Matrix  [][]float64     `json:"matrix,omitempty"`
+}
+
+type TableRepository struct {
+	db     *pgxpool.Pool
+	limits RowLimits
+	logger *zap.Logger
+}
+
+func NewTableRepository(db *pgxpool.Pool, limits RowLimits, logger *zap.Logger) *TableRepository {
+	return &TableRepository{
+		db:     db,
+		limits: limits,
+		logger: logger,
+	}
+}
+
+// BatchWrite inserts multiple table metrics in a single transaction
+func (r *TableRepository) BatchWrite(ctx context.Context, tables []model.TableMetric) error {
+	if len(tables) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, t := range tables {
+		data := tableData{Columns: t.Columns, Rows: t.Rows, Labels: t.Labels, Matrix: t.Matrix}
+		batch.Queue(
+			`INSERT INTO metric_tables (time, run_id, metric_name, step, kind, data, metadata)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			t.Time, t.RunID, t.MetricName, t.Step, t.Kind, data, t.Metadata,
+		)
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for i := 0; i < len(tables); i++ {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("failed to insert table %d: %w", i, err)
+		}
+	}
+
+	// The batch holds the connection until closed, so close it before committing
+	if err := br.Close(); err != nil {
+		return fmt.Errorf("failed to close batch: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Info("Table batch write completed", zap.Int("count", len(tables)))
+	return nil
+}
+
+// GetTableHistory retrieves the per-step versions of one table metric
+func (r *TableRepository) GetTableHistory(ctx context.Context, runID uuid.UUID, metricName string, params model.TableQueryParams) ([]model.TableMetric, error) {
+	q := newQuery(`SELECT time, run_id, metric_name, step, kind, data, metadata
+	               FROM metric_tables
+	               WHERE run_id = ? AND metric_name = ?`, runID, metricName)
+	addIfSet(q, " AND step >= ?", params.MinStep)
+	addIfSet(q, " AND step <= ?", params.MaxStep)
+	q.Add(" ORDER BY step ASC, time ASC LIMIT ?", r.limits.resultLimit(params.Limit))
+
+	return r.queryTables(ctx, q)
+}
+
+// GetLatestTable retrieves the newest version of a table metric, or nil when
+// none was logged
+func (r *TableRepository) GetLatestTable(ctx context.Context, runID uuid.UUID, metricName string) (*model.TableMetric, error) {
+	tables, err := r.queryTables(ctx, newQuery(
+		`SELECT time, run_id, metric_name, step, kind, data, metadata
+		 FROM metric_tables
+		 WHERE run_id = ? AND metric_name = ?
+		 ORDER BY step DESC NULLS LAST, time DESC
+		 LIMIT 1`,
+		runID, metricName,
+	))
+	if err != nil || len(tables) == 0 {
+		return nil, err
+	}
+	return &tables[0], nil
+}
+
+func (r *TableRepository) queryTables(ctx context.Context, q *queryBuilder) ([]model.TableMetric, error) {
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tables: %w", err)
+	}
+	tables, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.TableMetric, error) {
+		var t model.TableMetric
+		var data tableData
+		if err := row.Scan(&t.Time, &t.RunID, &t.MetricName, &t.Step, &t.Kind, &data, &t.Metadata); err != nil {
+			return t, err
+		}
+		t.Columns, t.Rows, t.Labels, t.Matrix = data.Columns, data.Rows, data.Labels, data.Matrix
+		return t, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tables: %w", err)
+	}
+
+	return tables, r.limits.checkResultRows("tables", len(tables))
+}
+
+// ListTableSeries lists the table metrics logged in a run
+func (r *TableRepository) ListTableSeries(ctx context.Context, runID uuid.UUID) ([]model.TableSeries, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT metric_name, (array_agg(kind ORDER BY time DESC))[1], COUNT(*), MIN(step), MAX(step), MAX(time)
+		 FROM metric_tables
+		 WHERE run_id = $1
+		 GROUP BY metric_name
+		 ORDER BY metric_name
+		 LIMIT $2`,
+		runID, r.limits.resultLimit(0),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query table series: %w", err)
+	}
+	series, err := pgx.CollectRows(rows, scanner(func(s *model.TableSeries) []interface{} {
+		return []interface{}{&s.MetricName, &s.Kind, &s.Count, &s.FirstStep, &s.LastStep, &s.LastTime}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table series: %w", err)
+	}
+
+	return series, r.limits.checkResultRows("table series", len(series))
+}
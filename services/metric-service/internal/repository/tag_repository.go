@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+type TagRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewTagRepository(db *pgxpool.Pool, logger *zap.Logger) *TagRepository {
+	return &TagRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SetTags upserts tags on a run, overwriting the value of existing keys
+func (r *TagRepository) SetTags(ctx context.Context, runID uuid.UUID, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for key, value := range tags {
+		batch.Queue(
+			`INSERT INTO run_tags (run_id, tag_key, tag_value)
+			 VALUES ($1, $2, $3)
+			 ON CONFLICT (run_id, tag_key) DO UPDATE SET tag_value = EXCLUDED.tag_value`,
+			runID, key, value,
+		)
+	}
+
+	br := r.db.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for i := 0; i < len(tags); i++ {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("failed to upsert tag %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteTag removes a tag from a run, reporting whether it existed
+func (r *TagRepository) DeleteTag(ctx context.Context, runID uuid.UUID, key string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM run_tags WHERE run_id = $1 AND tag_key = $2`, runID, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete tag: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetRunTags retrieves all tags of a run
+func (r *TagRepository) GetRunTags(ctx context.Context, runID uuid.UUID) ([]model.RunTag, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT run_id, tag_key, tag_value, created_at
+		 FROM run_tags
+		 WHERE run_id = $1
+		 ORDER BY tag_key`,
+		runID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	tags, err := pgx.CollectRows(rows, scanner(func(t *model.RunTag) []interface{} {
+		return []interface{}{&t.RunID, &t.Key, &t.Value, &t.CreatedAt}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	return tags, nil
+}
+
+// FindRuns returns the runs matching every filter together with all their tags
+func (r *TagRepository) FindRuns(ctx context.Context, filters []model.TagFilter, limit int) ([]model.TaggedRun, error) {
+	q := newQuery(`SELECT t.run_id, jsonb_object_agg(t.tag_key, t.tag_value)
+	               FROM run_tags t`)
+
+	for i, f := range filters {
+		if i == 0 {
+			q.Add(" WHERE")
+		} else {
+			q.Add(" AND")
+		}
+		q.Add(" EXISTS (SELECT 1 FROM run_tags f WHERE f.run_id = t.run_id AND f.tag_key = ?", f.Key)
+		if f.HasValue {
+			q.Add(" AND f.tag_value = ?", f.Value)
+		}
+		q.Add(")")
+	}
+
+	q.Add(" GROUP BY t.run_id ORDER BY t.run_id")
+	addIfNotZero(q, " LIMIT ?", limit)
+
+	rows, err := q.Query(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tagged runs: %w", err)
+	}
+	runs, err := pgx.CollectRows(rows, scanner(func(run *model.TaggedRun) []interface{} {
+		return []interface{}{&run.RunID, &run.Tags}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tagged runs: %w", err)
+	}
+	return runs, nil
+}
@@ -0,0 +1,165 @@
+// Package runservice checks runs against the platform's run service, which
+// owns runs and their access control
+package runservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrRunNotFound is returned for runs the run service does not know, or
+	// does not show to the caller
+	ErrRunNotFound = errors.New("run not found")
+	// ErrUnauthenticated is returned for requests that carry no credentials
+	ErrUnauthenticated = errors.New("missing credentials")
+	// ErrForbidden is returned when the run service rejects the caller's credentials
+	ErrForbidden = errors.New("not allowed to access run")
+	// ErrUnavailable is returned when the run service cannot be reached or
+	// the circuit breaker is open
+	ErrUnavailable = errors.New("run service unavailable")
+)
+
+type Options struct {
+	BaseURL          string
+	Timeout          time.Duration
+	CacheTTL         time.Duration // for runs that exist
+	NegativeCacheTTL time.Duration // for unknown runs and rejected credentials
+	FailureThreshold int           // consecutive failures that open the breaker
+	Cooldown         time.Duration // how long the breaker stays open
+}
+
+// Client checks that runs exist and are accessible to the caller. Answers are
+// cached per run and credential, and a circuit breaker stops calling the run
+// service for a while after repeated failures.
+type Client struct {
+	opts    Options
+	http    *http.Client
+	breaker *breaker
+	logger  *zap.Logger
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	err     error
+	expires time.Time
+}
+
+func NewClient(opts Options, logger *zap.Logger) *Client {
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+	return &Client{
+		opts:    opts,
+		http:    &http.Client{Timeout: opts.Timeout},
+		breaker: newBreaker(opts.FailureThreshold, opts.Cooldown),
+		logger:  logger,
+		cache:   make(map[string]cacheEntry),
+	}
+}
+
+// CheckRun returns nil when the run exists and the credentials, the value of
+// an Authorization header, may access it. Only the caller's own credentials
+// are forwarded; requests without any are rejected.
+func (c *Client) CheckRun(ctx context.Context, runID uuid.UUID, authorization string) error {
+	if authorization == "" {
+		return ErrUnauthenticated
+	}
+	key := cacheKey(runID, authorization)
+
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.err
+	}
+
+	if !c.breaker.allow(now) {
+		return ErrUnavailable
+	}
+
+	err := c.fetchRun(ctx, runID, authorization)
+	if errors.Is(err, ErrUnavailable) {
+		if c.breaker.failure(time.Now()) {
+			c.logger.Warn("Run service circuit breaker opened", zap.Duration("cooldown", c.opts.Cooldown), zap.Error(err))
+		}
+		return err
+	}
+	if ctx.Err() != nil {
+		c.breaker.abandon()
+		return err
+	}
+	c.breaker.success()
+
+	ttl := c.opts.CacheTTL
+	if err != nil {
+		ttl = c.opts.NegativeCacheTTL
+	}
+	c.mu.Lock()
+	c.evictExpired(now)
+	c.cache[key] = cacheEntry{err: err, expires: now.Add(ttl)}
+	c.mu.Unlock()
+	return err
+}
+
+func (c *Client) fetchRun(ctx context.Context, runID uuid.UUID, authorization string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/v1/runs/%s", c.opts.BaseURL, runID), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// A caller giving up is not the run service failing
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrRunNotFound
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	default:
+		return fmt.Errorf("%w: unexpected status %d", ErrUnavailable, resp.StatusCode)
+	}
+}
+
+// evictExpired drops stale entries once the cache grows large, keeping its
+// size bounded by the number of runs written within a TTL
+func (c *Client) evictExpired(now time.Time) {
+	if len(c.cache) < 10000 {
+		return
+	}
+	for key, entry := range c.cache {
+		if !now.Before(entry.expires) {
+			delete(c.cache, key)
+		}
+	}
+}
+
+// cacheKey identifies a run as seen with some credentials, without keeping
+// the credentials themselves in memory
+func cacheKey(runID uuid.UUID, authorization string) string {
+	sum := sha256.Sum256([]byte(authorization))
+	return runID.String() + ":" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// publishAlerts pushes alerts to the live stream of their runs and, when
+// events is set, publishes them as CloudEvents. Alerts are best effort, so
+// failures are only logged.
+func publishAlerts(ctx context.Context, rdb *redis.Client, events *CloudEventPublisher, logger *zap.Logger, alerts []model.Alert) {
+	if events != nil {
+		for _, a := range alerts {
+			events.Publish(model.CloudEventAlertRaised, a.RunID, a.Time, a)
+		}
+	}
+
+	byRun := make(map[uuid.UUID][]model.Alert)
+	for _, a := range alerts {
+		byRun[a.RunID] = append(byRun[a.RunID], a)
+	}
+
+	for runID, runAlerts := range byRun {
+		data, err := json.Marshal(model.AlertPayload{Alerts: runAlerts})
+		if err == nil {
+			err = rdb.Publish(ctx, alertChannel(runID), data).Err()
+		}
+		if err != nil {
+			logger.Warn("Failed to publish alerts", zap.String("run_id", runID.String()), zap.Error(err))
+		}
+	}
+}
+
+// alertChannel is the Redis channel alerts of a run are published on
+func alertChannel(runID uuid.UUID) string {
+	return "alerts:" + runID.String()
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// AnnotationService manages run timeline annotations
+type AnnotationService struct {
+	repo   *repository.AnnotationRepository
+	redis  *redis.Client
+	logger *zap.Logger
+}
+
+func NewAnnotationService(repo *repository.AnnotationRepository, redis *redis.Client, logger *zap.Logger) *AnnotationService {
+	return &AnnotationService{
+		repo:   repo,
+		redis:  redis,
+		logger: logger,
+	}
+}
+
+// CreateAnnotation stores an annotation and broadcasts it to live subscribers
+func (s *AnnotationService) CreateAnnotation(ctx context.Context, runID uuid.UUID, req model.CreateAnnotationRequest) (*model.Annotation, error) {
+	annotation := &model.Annotation{
+		ID:       uuid.New(),
+		RunID:    runID,
+		Time:     time.Now(),
+		Step:     req.Step,
+		Text:     req.Text,
+		Metadata: req.Metadata,
+	}
+	if req.Time != nil {
+		annotation.Time = *req.Time
+	}
+
+	if err := s.repo.CreateAnnotation(ctx, annotation); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(model.AnnotationPayload{Annotations: []model.Annotation{*annotation}})
+	if err == nil {
+		channel := fmt.Sprintf("annotations:%s", runID.String())
+		err = s.redis.Publish(ctx, channel, data).Err()
+	}
+	if err != nil {
+		s.logger.Warn("Failed to publish annotation", zap.Error(err))
+	}
+
+	return annotation, nil
+}
+
+// GetRunAnnotations retrieves the annotations of a run
+func (s *AnnotationService) GetRunAnnotations(ctx context.Context, runID uuid.UUID, params model.AnnotationQueryParams) ([]model.Annotation, error) {
+	return s.repo.GetRunAnnotations(ctx, runID, params)
+}
+
+// GetAnnotationsForQuery retrieves the annotations within the range of a metric query
+func (s *AnnotationService) GetAnnotationsForQuery(ctx context.Context, runID uuid.UUID, params model.MetricQueryParams) ([]model.Annotation, error) {
+	return s.repo.GetRunAnnotations(ctx, runID, model.AnnotationQueryParams{
+		StartTime: params.StartTime,
+		EndTime:   params.EndTime,
+		MinStep:   params.MinStep,
+		MaxStep:   params.MaxStep,
+	})
+}
+
+// DeleteAnnotation removes an annotation
+func (s *AnnotationService) DeleteAnnotation(ctx context.Context, runID, annotationID uuid.UUID) (bool, error) {
+	return s.repo.DeleteAnnotation(ctx, runID, annotationID)
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+const (
+	anomalyQueueSize   = 1024
+	anomalySeriesTTL   = 30 * time.Minute
+	anomalySweepPeriod = time.Minute
+)
+
+type AnomalyOptions struct {
+	ZScoreThreshold float64 // band width in EWMA standard deviations
+	EWMAAlpha       float64 // smoothing factor for the rolling mean/variance
+	WarmupSamples   int     // samples required before a series can be flagged
+	NaNStreak       int     // consecutive NaN/Inf values that trigger an event
+}
+
+type seriesKey struct {
+	runID      uuid.UUID
+	metricName string
+}
+
+// seriesState holds the rolling statistics for one (run, metric) series
+type seriesState struct {
+	mean     float64
+	variance float64
+	count    int
+	nanRun   int
+	lastSeen time.Time
+}
+
+// AnomalyDetector maintains rolling EWMA statistics per (run, metric) and
+// records anomaly events when a value breaks out of its band or a NaN streak forms
+type AnomalyDetector struct {
+	repo   *repository.AnomalyRepository
+	redis  *redis.Client
+	logger *zap.Logger
+	opts   AnomalyOptions
+	queue  chan []model.Metric
+	events *CloudEventPublisher
+
+	// series is only touched by the Run goroutine
+	series map[seriesKey]*seriesState
+}
+
+func NewAnomalyDetector(repo *repository.AnomalyRepository, redis *redis.Client, opts AnomalyOptions, logger *zap.Logger) *AnomalyDetector {
+	return &AnomalyDetector{
+		repo:   repo,
+		redis:  redis,
+		logger: logger,
+		opts:   opts,
+		queue:  make(chan []model.Metric, anomalyQueueSize),
+		series: make(map[seriesKey]*seriesState),
+	}
+}
+
+// UseCloudEvents publishes the alerts of detected anomalies to events
+func (d *AnomalyDetector) UseCloudEvents(events *CloudEventPublisher) {
+	d.events = events
+}
+
+// ObserveMetrics queues a persisted batch for analysis without blocking the write path
+func (d *AnomalyDetector) ObserveMetrics(ctx context.Context, metrics []model.Metric) {
+	select {
+	case d.queue <- metrics:
+	default:
+		d.logger.Warn("Anomaly detector queue full, dropping batch", zap.Int("count", len(metrics)))
+	}
+}
+
+// Run consumes queued batches until the context is cancelled
+func (d *AnomalyDetector) Run(ctx context.Context) {
+	ticker := time.NewTicker(anomalySweepPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case metrics := <-d.queue:
+			anomalies := d.analyze(metrics)
+			if len(anomalies) > 0 {
+				d.record(ctx, anomalies)
+			}
+		case now := <-ticker.C:
+			d.evictIdle(now)
+		}
+	}
+}
+
+// GetRunAnomalies retrieves recorded anomaly events for a run
+func (d *AnomalyDetector) GetRunAnomalies(ctx context.Context, runID uuid.UUID, params model.AnomalyQueryParams) ([]model.Anomaly, error) {
+	return d.repo.GetRunAnomalies(ctx, runID, params)
+}
+
+// analyze folds a batch into the rolling statistics and returns any anomalies found
+func (d *AnomalyDetector) analyze(metrics []model.Metric) []model.Anomaly {
+	var anomalies []model.Anomaly
+	now := time.Now()
+
+	for _, m := range metrics {
+		if !m.IsNumeric() {
+			continue
+		}
+		key := seriesKey{runID: m.RunID, metricName: m.MetricName}
+		state, ok := d.series[key]
+		if !ok {
+			state = &seriesState{}
+			d.series[key] = state
+		}
+		state.lastSeen = now
+
+		if math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+			state.nanRun++
+			// Emit once per streak rather than for every subsequent NaN
+			if state.nanRun == d.opts.NaNStreak {
+				anomalies = append(anomalies, model.Anomaly{
+					Time:           m.Time,
+					RunID:          m.RunID,
+					MetricName:     m.MetricName,
+					Step:           m.Step,
+					Kind:           model.AnomalyKindNaNStreak,
+					Score:          float64(state.nanRun),
+					ExpectedMean:   state.mean,
+					ExpectedStdDev: math.Sqrt(state.variance),
+					Message:        fmt.Sprintf("%d consecutive non-finite values", state.nanRun),
+				})
+			}
+			continue
+		}
+		state.nanRun = 0
+
+		if state.count >= d.opts.WarmupSamples && state.variance > 0 {
+			stddev := math.Sqrt(state.variance)
+			z := (m.Value - state.mean) / stddev
+			if math.Abs(z) > d.opts.ZScoreThreshold {
+				kind := model.AnomalyKindSpike
+				if z < 0 {
+					kind = model.AnomalyKindDrop
+				}
+				value := m.Value
+				anomalies = append(anomalies, model.Anomaly{
+					Time:           m.Time,
+					RunID:          m.RunID,
+					MetricName:     m.MetricName,
+					Step:           m.Step,
+					Value:          &value,
+					Kind:           kind,
+					Score:          z,
+					ExpectedMean:   state.mean,
+					ExpectedStdDev: stddev,
+					Message:        fmt.Sprintf("value %.6g is %.1f standard deviations from the rolling mean %.6g", value, z, state.mean),
+				})
+			}
+		}
+
+		state.update(m.Value, d.opts.EWMAAlpha)
+	}
+
+	return anomalies
+}
+
+// update applies the incremental EWMA mean/variance recurrence
+func (s *seriesState) update(value, alpha float64) {
+	if s.count == 0 {
+		s.mean = value
+		s.variance = 0
+		s.count = 1
+		return
+	}
+
+	diff := value - s.mean
+	incr := alpha * diff
+	s.mean += incr
+	s.variance = (1 - alpha) * (s.variance + diff*incr)
+	s.count++
+}
+
+func (d *AnomalyDetector) record(ctx context.Context, anomalies []model.Anomaly) {
+	if err := d.repo.BatchWrite(ctx, anomalies); err != nil {
+		d.logger.Error("Failed to write anomalies", zap.Error(err))
+	}
+
+	byRun := make(map[uuid.UUID][]model.Anomaly)
+	for _, a := range anomalies {
+		byRun[a.RunID] = append(byRun[a.RunID], a)
+	}
+
+	for runID, runAnomalies := range byRun {
+		data, err := json.Marshal(model.AnomalyPayload{Anomalies: runAnomalies})
+		if err != nil {
+			d.logger.Error("Failed to marshal anomalies", zap.Error(err))
+			continue
+		}
+
+		channel := fmt.Sprintf("anomalies:%s", runID.String())
+		if err := d.redis.Publish(ctx, channel, data).Err(); err != nil {
+			d.logger.Error("Failed to publish anomalies to Redis", zap.Error(err))
+		}
+	}
+
+	// A metric going NaN usually means the run is lost, so it is also raised
+	// as an alert for dashboards to put in front of the user
+	var alerts []model.Alert
+	for _, a := range anomalies {
+		if a.Kind == model.AnomalyKindNaNStreak {
+			alerts = append(alerts, model.Alert{
+				Time:       a.Time,
+				RunID:      a.RunID,
+				Severity:   model.AlertSeverityCritical,
+				Source:     "anomaly_detector",
+				Kind:       a.Kind,
+				MetricName: a.MetricName,
+				Step:       a.Step,
+				Message:    a.Message,
+			})
+		}
+	}
+	publishAlerts(ctx, d.redis, d.events, d.logger, alerts)
+
+	d.logger.Info("Anomalies detected", zap.Int("count", len(anomalies)))
+}
+
+func (d *AnomalyDetector) evictIdle(now time.Time) {
+	for key, state := range d.series {
+		if now.Sub(state.lastSeen) > anomalySeriesTTL {
+			delete(d.series, key)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// ArtifactService links checkpoints and dataset versions to run steps
+type ArtifactService struct {
+	repo        *repository.ArtifactRepository
+	summaryRepo *repository.SummaryRepository
+	logger      *zap.Logger
+}
+
+func NewArtifactService(repo *repository.ArtifactRepository, summaryRepo *repository.SummaryRepository, logger *zap.Logger) *ArtifactService {
+	return &ArtifactService{
+		repo:        repo,
+		summaryRepo: summaryRepo,
+		logger:      logger,
+	}
+}
+
+// CreateArtifact registers an artifact reference on a run
+func (s *ArtifactService) CreateArtifact(ctx context.Context, runID uuid.UUID, req model.CreateArtifactRequest) (*model.ArtifactRef, error) {
+	if req.Step != nil && *req.Step < 0 {
+		return nil, &ValidationError{Message: "step must be non-negative"}
+	}
+
+	artifact := &model.ArtifactRef{
+		ID:       uuid.New(),
+		RunID:    runID,
+		Step:     req.Step,
+		Kind:     req.Kind,
+		Name:     req.Name,
+		URI:      req.URI,
+		Digest:   req.Digest,
+		Metadata: req.Metadata,
+	}
+
+	if err := s.repo.CreateArtifact(ctx, artifact); err != nil {
+		return nil, err
+	}
+	return artifact, nil
+}
+
+// GetRunArtifacts retrieves the artifacts of a run
+func (s *ArtifactService) GetRunArtifacts(ctx context.Context, runID uuid.UUID, params model.ArtifactQueryParams) ([]model.ArtifactRef, error) {
+	return s.repo.GetRunArtifacts(ctx, runID, params)
+}
+
+// GetArtifactsForMetrics retrieves the artifacts logged within the step range
+// covered by a page of metric history
+func (s *ArtifactService) GetArtifactsForMetrics(ctx context.Context, runID uuid.UUID, metrics []model.Metric) ([]model.ArtifactRef, error) {
+	var minStep, maxStep *int64
+	for _, m := range metrics {
+		if m.Step == nil {
+			continue
+		}
+		if minStep == nil || *m.Step < *minStep {
+			minStep = m.Step
+		}
+		if maxStep == nil || *m.Step > *maxStep {
+			maxStep = m.Step
+		}
+	}
+	if minStep == nil {
+		return nil, nil
+	}
+
+	return s.repo.GetRunArtifacts(ctx, runID, model.ArtifactQueryParams{
+		MinStep: minStep,
+		MaxStep: maxStep,
+	})
+}
+
+// LookupArtifact finds the artifact closest to a step, given directly or
+// resolved from the best or final step of a metric. The resolved step is nil
+// when the metric has no summary yet.
+func (s *ArtifactService) LookupArtifact(ctx context.Context, runID uuid.UUID, params model.ArtifactLookupParams) (*model.ArtifactRef, *int64, error) {
+	step := params.Step
+	if step == nil {
+		summary, err := s.summaryRepo.GetMetricSummary(ctx, runID, params.MetricName)
+		if err != nil || summary == nil {
+			return nil, nil, err
+		}
+		step = summary.BestStep
+		if params.At == "final" {
+			step = summary.FinalStep
+		}
+		if step == nil {
+			return nil, nil, nil
+		}
+	}
+
+	artifact, err := s.repo.GetNearestArtifact(ctx, runID, *step, params.Kind)
+	if err != nil {
+		return nil, nil, err
+	}
+	return artifact, step, nil
+}
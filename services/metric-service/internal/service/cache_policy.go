@@ -0,0 +1,41 @@
+package service
+
+import "time"
+
+// CachePolicy sets how long query results are cached by how recently their
+// run was written. Results are cached under the time of the run's last
+// write, so a write makes the results cached before it unreachable; the TTLs
+// bound how long superseded results take up memory, which matters for runs
+// being written, and how long results outlive changes made behind the
+// service's back, such as metrics deleted by maintenance.
+type CachePolicy struct {
+	ActiveTTL     time.Duration // for runs written within ActiveWindow
+	ActiveWindow  time.Duration
+	IdleTTL       time.Duration // for runs neither active nor finished
+	FinishedAfter time.Duration // runs not written for this long are finished
+	FinishedTTL   time.Duration // for finished runs; 0 keeps results until evicted
+}
+
+// DefaultCachePolicy keeps results of active runs for seconds and those of
+// finished runs for hours
+var DefaultCachePolicy = CachePolicy{
+	ActiveTTL:     5 * time.Second,
+	ActiveWindow:  2 * time.Minute,
+	IdleTTL:       5 * time.Minute,
+	FinishedAfter: time.Hour,
+	FinishedTTL:   6 * time.Hour,
+}
+
+// TTL returns how long to cache results of a run last written at lastWrite,
+// which is zero for runs without a known last write
+func (p CachePolicy) TTL(lastWrite, now time.Time) time.Duration {
+	age := now.Sub(lastWrite)
+	switch {
+	case lastWrite.IsZero() || age >= p.FinishedAfter:
+		return p.FinishedTTL
+	case age < p.ActiveWindow:
+		return p.ActiveTTL
+	default:
+		return p.IdleTTL
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// CheckpointService registers checkpoints and tracks the best one so far for
+// each objective metric of a run. A checkpoint is ranked by the objective's
+// value logged at its step, whichever of the two arrives first.
+type CheckpointService struct {
+	repo      *repository.CheckpointRepository
+	artifacts *repository.ArtifactRepository
+	summaries *SummaryService
+	logger    *zap.Logger
+}
+
+func NewCheckpointService(repo *repository.CheckpointRepository, artifacts *repository.ArtifactRepository, summaries *SummaryService, logger *zap.Logger) *CheckpointService {
+	return &CheckpointService{
+		repo:      repo,
+		artifacts: artifacts,
+		summaries: summaries,
+		logger:    logger,
+	}
+}
+
+// RegisterCheckpoint records the checkpoint saved at a step, after adding any
+// objectives given with it, and reports the run's best checkpoints
+func (s *CheckpointService) RegisterCheckpoint(ctx context.Context, runID uuid.UUID, req model.RegisterCheckpointRequest) (*model.RegisterCheckpointResponse, error) {
+	if len(req.Objectives) > 0 {
+		if err := s.SetObjectives(ctx, runID, req.Objectives); err != nil {
+			return nil, err
+		}
+	}
+
+	artifact := &model.ArtifactRef{
+		ID:       uuid.New(),
+		RunID:    runID,
+		Step:     req.Step,
+		Kind:     model.ArtifactKindCheckpoint,
+		Name:     req.Name,
+		URI:      req.URI,
+		Digest:   req.Digest,
+		Metadata: req.Metadata,
+	}
+	if err := s.artifacts.CreateArtifact(ctx, artifact); err != nil {
+		return nil, err
+	}
+	if err := s.repo.ProposeCheckpoint(ctx, artifact); err != nil {
+		return nil, err
+	}
+
+	best, err := s.repo.GetBestCheckpoints(ctx, runID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.RegisterCheckpointResponse{
+		Checkpoint: *artifact,
+		Best:       best,
+		IsBest:     []string{},
+	}
+	for _, b := range best {
+		if b.Checkpoint.ID == artifact.ID {
+			resp.IsBest = append(resp.IsBest, b.MetricName)
+		}
+	}
+	return resp, nil
+}
+
+// SetObjectives adds or updates objectives of a run, ranking the checkpoints
+// already registered under them
+func (s *CheckpointService) SetObjectives(ctx context.Context, runID uuid.UUID, objectives []model.CheckpointObjective) error {
+	seen := make(map[string]bool, len(objectives))
+	for i, o := range objectives {
+		if seen[o.MetricName] {
+			return &ValidationError{Message: "duplicate objective " + o.MetricName}
+		}
+		seen[o.MetricName] = true
+
+		if o.Goal == "" {
+			objectives[i].Goal = s.summaries.GoalFor(o.MetricName)
+		}
+	}
+	return s.repo.SetObjectives(ctx, runID, objectives)
+}
+
+// GetObjectives retrieves the checkpoint objectives of a run
+func (s *CheckpointService) GetObjectives(ctx context.Context, runID uuid.UUID) ([]model.CheckpointObjective, error) {
+	return s.repo.GetObjectives(ctx, runID)
+}
+
+// GetBestCheckpoints retrieves the best checkpoint of each objective of a
+// run, or of one objective when metricName is set
+func (s *CheckpointService) GetBestCheckpoints(ctx context.Context, runID uuid.UUID, metricName string) ([]model.BestCheckpoint, error) {
+	return s.repo.GetBestCheckpoints(ctx, runID, metricName)
+}
+
+// ObserveMetrics ranks the checkpoints saved at the steps of newly logged
+// objective values
+func (s *CheckpointService) ObserveMetrics(ctx context.Context, metrics []model.Metric) {
+	if err := s.repo.ProposeMetrics(ctx, metrics); err != nil {
+		s.logger.Error("Failed to update best checkpoints", zap.Error(err))
+	}
+}
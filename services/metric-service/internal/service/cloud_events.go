@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+const (
+	cloudEventQueueSize = 1024
+	// cloudEventContentType is the media type of the structured JSON format
+	cloudEventContentType = "application/cloudevents+json"
+)
+
+// CloudEventPublisher delivers run state changes, alerts and metric
+// deletions as CloudEvents, so that event-driven components consume them
+// with standard SDKs and brokers. Each event goes to every sink; sinks that
+// order messages do so by run, keeping each run's events in order.
+type CloudEventPublisher struct {
+	source string
+	sinks  []EventSink
+	logger *zap.Logger
+	queue  chan model.CloudEvent
+}
+
+// NewCloudEventPublisher creates a publisher of events from source, the
+// source URI of every event
+func NewCloudEventPublisher(source string, sinks []EventSink, logger *zap.Logger) *CloudEventPublisher {
+	return &CloudEventPublisher{
+		source: source,
+		sinks:  sinks,
+		logger: logger,
+		queue:  make(chan model.CloudEvent, cloudEventQueueSize),
+	}
+}
+
+// Publish queues an event about a run without blocking the caller
+func (p *CloudEventPublisher) Publish(eventType string, runID uuid.UUID, t time.Time, data interface{}) {
+	event := model.CloudEvent{
+		SpecVersion:     model.CloudEventSpecVersion,
+		ID:              uuid.New().String(),
+		Source:          p.source,
+		Type:            eventType,
+		Subject:         runID.String(),
+		Time:            t.UTC(),
+		DataContentType: "application/json",
+		RunID:           runID.String(),
+		Data:            data,
+	}
+	select {
+	case p.queue <- event:
+	default:
+		p.logger.Warn("CloudEvents queue full, dropping event", zap.String("type", eventType))
+	}
+}
+
+// Run delivers queued events until the context is cancelled, then closes
+// the sinks that hold connections
+func (p *CloudEventPublisher) Run(ctx context.Context) {
+	defer func() {
+		for _, sink := range p.sinks {
+			if closer, ok := sink.(io.Closer); ok {
+				closer.Close()
+			}
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-p.queue:
+			p.deliver(ctx, event)
+		}
+	}
+}
+
+func (p *CloudEventPublisher) deliver(ctx context.Context, event model.CloudEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		p.logger.Error("Failed to encode CloudEvent", zap.String("type", event.Type), zap.Error(err))
+		return
+	}
+	for _, sink := range p.sinks {
+		if err := sink.Send(ctx, event, body); err != nil {
+			p.logger.Warn("Failed to deliver CloudEvent",
+				zap.Stringer("sink", sink),
+				zap.String("type", event.Type),
+				zap.String("id", event.ID),
+				zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestCloudEventPublisher(t *testing.T) {
+	type delivery struct {
+		contentType string
+		signature   string
+		body        []byte
+	}
+	received := make(chan delivery, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- delivery{r.Header.Get("Content-Type"), r.Header.Get("X-Wanllmdb-Signature"), body}
+	}))
+	defer server.Close()
+
+	sinks := []EventSink{NewWebhookEventSink(server.URL, "secret", time.Second, zap.NewNop())}
+	p := NewCloudEventPublisher("/wanllmdb/test", sinks, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	runID := uuid.New()
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	p.Publish(model.CloudEventAlertRaised, runID, at, model.Alert{RunID: runID, Kind: "nan_streak", Message: "loss is NaN"})
+
+	var got delivery
+	select {
+	case got = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event delivered")
+	}
+	if got.contentType != "application/cloudevents+json" {
+		t.Errorf("Content-Type = %q, want application/cloudevents+json", got.contentType)
+	}
+	if got.signature == "" {
+		t.Error("event not signed")
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(got.body, &event); err != nil {
+		t.Fatalf("invalid event %s: %v", got.body, err)
+	}
+	want := map[string]interface{}{
+		"specversion":     "1.0",
+		"source":          "/wanllmdb/test",
+		"type":            "io.wanllmdb.alert.raised",
+		"subject":         runID.String(),
+		"runid":           runID.String(),
+		"time":            "2026-03-01T11:00:00Z",
+		"datacontenttype": "application/json",
+	}
+	for attr, value := range want {
+		if event[attr] != value {
+			t.Errorf("%s = %v, want %v", attr, event[attr], value)
+		}
+	}
+	if id, _ := event["id"].(string); id == "" {
+		t.Error("event has no id")
+	}
+	if data, _ := event["data"].(map[string]interface{}); data["kind"] != "nan_streak" {
+		t.Errorf("data = %v, want the alert", event["data"])
+	}
+}
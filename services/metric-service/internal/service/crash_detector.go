@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+const (
+	// A run is silent once it has not logged for this many of its usual
+	// intervals, and never before CrashOptions.Silence
+	crashSilenceIntervals = 10
+
+	// crashEvidenceWindow is how far before a run went silent its system
+	// samples are searched for a cause
+	crashEvidenceWindow = 5 * time.Minute
+
+	maxCrashCandidates = 200
+)
+
+type CrashOptions struct {
+	Silence         time.Duration // minimum silence before a run is considered crashed
+	Lookback        time.Duration // runs silent for longer are no longer examined
+	MemoryThreshold float64       // host or GPU memory percent that indicates OOM
+}
+
+// CrashDetector finds runs whose metric ingest stopped abruptly and records a
+// crashed state event with the probable cause read from their last system
+// and GPU samples. Runs that report state events are marked crashed whenever
+// they go silent while running; runs that do not, only when the samples
+// point to a cause.
+type CrashDetector struct {
+	repo   *repository.CrashRepository
+	events *RunEventService
+	opts   CrashOptions
+	logger *zap.Logger
+}
+
+func NewCrashDetector(repo *repository.CrashRepository, events *RunEventService, opts CrashOptions, logger *zap.Logger) *CrashDetector {
+	return &CrashDetector{
+		repo:   repo,
+		events: events,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// Job runs the detector as a scheduled job
+func (d *CrashDetector) Job(interval time.Duration) Job {
+	return Job{
+		Name:     "crash-detection",
+		Interval: interval,
+		Run: func(ctx context.Context) (string, error) {
+			crashed, err := d.Scan(ctx)
+			return fmt.Sprintf("marked %d runs crashed", crashed), err
+		},
+	}
+}
+
+// Scan examines the runs that went silent and returns how many were marked crashed
+func (d *CrashDetector) Scan(ctx context.Context) (int, error) {
+	now := time.Now()
+	runs, err := d.repo.FindSilentRuns(ctx, now.Add(-d.opts.Lookback), now.Add(-d.opts.Silence), maxCrashCandidates)
+	if err != nil {
+		return 0, err
+	}
+
+	crashed := 0
+	for _, run := range runs {
+		// Runs that log rarely are given proportionally longer
+		if run.Interval != nil {
+			silence := time.Duration(*run.Interval * crashSilenceIntervals * float64(time.Second))
+			if now.Sub(run.LastMetricAt) < silence {
+				continue
+			}
+		}
+
+		evidence, err := d.repo.GetEvidence(ctx, run.RunID, run.LastMetricAt.Add(-crashEvidenceWindow), run.LastMetricAt.Add(d.opts.Silence))
+		if err != nil {
+			return crashed, err
+		}
+
+		cause, reason := d.probableCause(evidence)
+		if cause == model.CrashCauseUnknown && run.State == "" {
+			continue
+		}
+
+		_, err = d.events.AppendEvent(ctx, run.RunID, model.CreateRunEventRequest{
+			State:  model.RunStateCrashed,
+			Time:   &run.LastMetricAt,
+			Step:   run.LastStep,
+			Reason: reason,
+			Source: "crash_detector",
+			Metadata: map[string]interface{}{
+				"probable_cause":     cause,
+				"evidence":           evidence,
+				"last_metric_at":     run.LastMetricAt,
+				"silent_for_seconds": now.Sub(run.LastMetricAt).Seconds(),
+			},
+		})
+		if errors.Is(err, ErrInvalidTransition) {
+			// The run changed state since it was listed
+			continue
+		}
+		if err != nil {
+			return crashed, err
+		}
+
+		crashed++
+		d.logger.Info("Run marked crashed",
+			zap.String("run_id", run.RunID.String()),
+			zap.String("cause", cause),
+			zap.Time("last_metric_at", run.LastMetricAt),
+		)
+	}
+	return crashed, nil
+}
+
+// probableCause names the most likely reason a run stopped. ECC errors come
+// first, since they also make memory readings unreliable.
+func (d *CrashDetector) probableCause(e *model.CrashEvidence) (string, string) {
+	switch {
+	case e.GPUECCErrors > 0:
+		return model.CrashCauseGPUECC, fmt.Sprintf("metric ingest stopped; GPU %d reported %.0f ECC errors", *e.ECCDevice, e.GPUECCErrors)
+	case e.HostMemoryPercent != nil && *e.HostMemoryPercent >= d.opts.MemoryThreshold:
+		return model.CrashCauseHostOOM, fmt.Sprintf("metric ingest stopped; host memory reached %.1f%%, likely out of memory", *e.HostMemoryPercent)
+	case e.GPUMemoryPercent != nil && *e.GPUMemoryPercent >= d.opts.MemoryThreshold:
+		return model.CrashCauseGPUOOM, fmt.Sprintf("metric ingest stopped; GPU %d memory reached %.1f%%, likely out of memory", *e.GPUDevice, *e.GPUMemoryPercent)
+	default:
+		return model.CrashCauseUnknown, "metric ingest stopped with no sign of the cause in system metrics"
+	}
+}
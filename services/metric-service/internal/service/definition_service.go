@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// definitionRefreshInterval bounds how stale another replica's definition changes can be
+const definitionRefreshInterval = 30 * time.Second
+
+type definitionKey struct {
+	projectID uuid.UUID
+	name      string
+}
+
+// DefinitionService manages the metric definitions registry and serves
+// lookups for validation and summaries from an in-memory copy
+type DefinitionService struct {
+	repo   *repository.DefinitionRepository
+	logger *zap.Logger
+
+	mu    sync.RWMutex
+	cache map[definitionKey]model.MetricDefinition
+}
+
+func NewDefinitionService(repo *repository.DefinitionRepository, logger *zap.Logger) *DefinitionService {
+	return &DefinitionService{
+		repo:   repo,
+		logger: logger,
+		cache:  make(map[definitionKey]model.MetricDefinition),
+	}
+}
+
+// Run keeps the in-memory registry in sync until the context is cancelled
+func (s *DefinitionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(definitionRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				s.logger.Error("Failed to refresh metric definitions", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Refresh reloads every definition into memory
+func (s *DefinitionService) Refresh(ctx context.Context) error {
+	defs, err := s.repo.ListDefinitions(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	cache := make(map[definitionKey]model.MetricDefinition, len(defs))
+	for _, d := range defs {
+		cache[definitionKey{projectID: d.ProjectID, name: d.Name}] = d
+	}
+
+	s.mu.Lock()
+	s.cache = cache
+	s.mu.Unlock()
+	return nil
+}
+
+// Lookup returns the definition of a metric in a project, falling back to the
+// installation-wide default under the nil project ID
+func (s *DefinitionService) Lookup(projectID uuid.UUID, name string) (model.MetricDefinition, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if d, ok := s.cache[definitionKey{projectID: projectID, name: name}]; ok {
+		return d, true
+	}
+	d, ok := s.cache[definitionKey{projectID: uuid.Nil, name: name}]
+	return d, ok
+}
+
+// CheckRange validates a value against the expected range of an enforcing definition
+func (s *DefinitionService) CheckRange(projectID uuid.UUID, name string, value float64) error {
+	d, ok := s.Lookup(projectID, name)
+	if !ok || !d.EnforceRange {
+		return nil
+	}
+	if d.ExpectedMin != nil && value < *d.ExpectedMin {
+		return fmt.Errorf("value %g is below the expected minimum %g", value, *d.ExpectedMin)
+	}
+	if d.ExpectedMax != nil && value > *d.ExpectedMax {
+		return fmt.Errorf("value %g is above the expected maximum %g", value, *d.ExpectedMax)
+	}
+	return nil
+}
+
+// SaveDefinition creates or replaces a definition
+func (s *DefinitionService) SaveDefinition(ctx context.Context, projectID uuid.UUID, req model.MetricDefinitionRequest) (*model.MetricDefinition, error) {
+	if req.ExpectedMin != nil && req.ExpectedMax != nil && *req.ExpectedMin > *req.ExpectedMax {
+		return nil, &ValidationError{Message: "expected_min must not exceed expected_max"}
+	}
+
+	def := &model.MetricDefinition{
+		ProjectID:      projectID,
+		Name:           req.Name,
+		DisplayName:    req.DisplayName,
+		Unit:           req.Unit,
+		Goal:           req.Goal,
+		ExpectedMin:    req.ExpectedMin,
+		ExpectedMax:    req.ExpectedMax,
+		EnforceRange:   req.EnforceRange,
+		Description:    req.Description,
+		RankReduce:     req.RankReduce,
+		KeepRankValues: req.KeepRankValues,
+		Cumulative:     req.Cumulative,
+	}
+	if def.DisplayName == "" {
+		def.DisplayName = def.Name
+	}
+
+	if err := s.repo.UpsertDefinition(ctx, def); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.cache[definitionKey{projectID: def.ProjectID, name: def.Name}] = *def
+	s.mu.Unlock()
+
+	return def, nil
+}
+
+// GetDefinition retrieves one definition
+func (s *DefinitionService) GetDefinition(ctx context.Context, projectID uuid.UUID, name string) (*model.MetricDefinition, error) {
+	return s.repo.GetDefinition(ctx, projectID, name)
+}
+
+// ListDefinitions retrieves the definitions of a project
+func (s *DefinitionService) ListDefinitions(ctx context.Context, projectID uuid.UUID) ([]model.MetricDefinition, error) {
+	return s.repo.ListDefinitions(ctx, &projectID)
+}
+
+// DeleteDefinition removes a definition
+func (s *DefinitionService) DeleteDefinition(ctx context.Context, projectID uuid.UUID, name string) (bool, error) {
+	deleted, err := s.repo.DeleteDefinition(ctx, projectID, name)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	delete(s.cache, definitionKey{projectID: projectID, name: name})
+	s.mu.Unlock()
+
+	return deleted, nil
+}
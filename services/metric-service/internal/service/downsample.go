@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"math"
+
+	"github.com/google/uuid"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// Downsampling methods of DownsampleMetricHistory
+const (
+	// DownsampleLTTB keeps the points of Largest-Triangle-Three-Buckets,
+	// which preserves the peaks and shape of a series as drawn
+	DownsampleLTTB = "lttb"
+	// DownsampleNth keeps every nth point, which is cheaper but may skip
+	// spikes
+	DownsampleNth = "nth"
+)
+
+// DownsampleMetricHistory reads the history of a metric within the time and
+// step range of params, whatever their limit or cursor, and reduces it to at
+// most points values, at least 3, with method. The first and last values are
+// always kept. It returns the values kept, in the order of the history, and
+// how many were read.
+func (s *MetricService) DownsampleMetricHistory(ctx context.Context, runID uuid.UUID, metricName string, params model.MetricQueryParams, points int, method string) ([]model.Metric, int64, error) {
+	params.MetricName = metricName
+	var d *downsampler
+	var total int64
+	err := s.repo.ScanRunMetrics(ctx, runID, params, func(n int64) {
+		total = n
+		d = newDownsampler(method, points, int(n))
+	}, func(m model.Metric) error {
+		d.add(m)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return d.finish(), total, nil
+}
+
+// downsampler reduces a series of total points, fed in order, to the given
+// number of points, holding no more than two buckets of it at once. LTTB
+// splits the points between the first and last into points-2 buckets, and
+// keeps from each the point forming the largest triangle with the point kept
+// before it and the average of the next bucket. Points are placed by time;
+// string and bool values and non-finite numbers are kept only when a bucket
+// has nothing else.
+//
+// A series turning out longer or shorter than total, as metrics are written
+// meanwhile, is still reduced to no more than points.
+type downsampler struct {
+	method string
+	points int
+	// every is the number of points per bucket for LTTB, or the stride of
+	// every nth point
+	every float64
+
+	read int
+	kept []model.Metric
+	// held is the latest point read, kept back in case it is the last
+	held *model.Metric
+
+	// cur and next are the buckets LTTB has yet to choose from; curBucket is
+	// the index of cur
+	cur, next []model.Metric
+	curBucket int
+}
+
+func newDownsampler(method string, points, total int) *downsampler {
+	d := &downsampler{method: method, points: points, kept: make([]model.Metric, 0, min(points, total))}
+	switch {
+	case total <= points:
+		// Every point is kept
+		d.every = 0
+	case method == DownsampleNth:
+		d.every = math.Ceil(float64(total-1) / float64(points-1))
+	default:
+		d.every = float64(total-2) / float64(points-2)
+	}
+	return d
+}
+
+func (d *downsampler) add(m model.Metric) {
+	i := d.read
+	d.read++
+	if i == 0 {
+		d.kept = append(d.kept, m)
+		return
+	}
+	if d.held != nil {
+		d.place(*d.held, i-1)
+	}
+	d.held = &m
+}
+
+// place takes the point read at index i, which is not the last
+func (d *downsampler) place(m model.Metric, i int) {
+	switch {
+	case d.every == 0:
+		if len(d.kept) < d.points-1 {
+			d.kept = append(d.kept, m)
+		}
+	case d.method == DownsampleNth:
+		if i%int(d.every) == 0 && len(d.kept) < d.points-1 {
+			d.kept = append(d.kept, m)
+		}
+	default:
+		bucket := min(int(float64(i-1)/d.every), d.points-3)
+		for bucket > d.curBucket+1 {
+			d.choose(bucketAverage(d.next))
+			d.cur, d.next = d.next, nil
+			d.curBucket++
+		}
+		if bucket == d.curBucket {
+			d.cur = append(d.cur, m)
+		} else {
+			d.next = append(d.next, m)
+		}
+	}
+}
+
+// finish returns the points kept, with the last
+func (d *downsampler) finish() []model.Metric {
+	if d.held == nil {
+		return d.kept
+	}
+	if len(d.next) > 0 {
+		d.choose(bucketAverage(d.next))
+		d.cur = d.next
+	}
+	d.choose(plotPoint{x: plotX(*d.held), y: d.held.Value, ok: plottable(*d.held)})
+	return append(d.kept, *d.held)
+}
+
+// choose keeps the point of cur forming the largest triangle with the point
+// kept last and c, then empties cur
+func (d *downsampler) choose(c plotPoint) {
+	if len(d.cur) == 0 {
+		return
+	}
+	a := d.kept[len(d.kept)-1]
+	best, bestArea := 0, -1.0
+	for j, b := range d.cur {
+		if !plottable(b) {
+			continue
+		}
+		// Without both ends the first plottable point is kept
+		area := 0.0
+		if c.ok && plottable(a) {
+			ax, bx := plotX(a), plotX(b)
+			area = math.Abs((ax-c.x)*(b.Value-a.Value) - (ax-bx)*(c.y-a.Value))
+		}
+		if area > bestArea {
+			best, bestArea = j, area
+		}
+	}
+	d.kept = append(d.kept, d.cur[best])
+	d.cur = nil
+}
+
+// plotPoint is a plotted position; ok is false when it has no value to plot
+type plotPoint struct {
+	x, y float64
+	ok   bool
+}
+
+// bucketAverage returns the average position of the plottable points of a
+// bucket
+func bucketAverage(bucket []model.Metric) plotPoint {
+	var p plotPoint
+	n := 0
+	for _, m := range bucket {
+		if plottable(m) {
+			p.x += plotX(m)
+			p.y += m.Value
+			n++
+		}
+	}
+	if n == 0 {
+		return plotPoint{}
+	}
+	return plotPoint{x: p.x / float64(n), y: p.y / float64(n), ok: true}
+}
+
+// plotX places a point by time, in seconds
+func plotX(m model.Metric) float64 {
+	return float64(m.Time.UnixNano()) / 1e9
+}
+
+// plottable reports whether a point has a finite numeric value
+func plottable(m model.Metric) bool {
+	return m.IsNumeric() && !math.IsNaN(m.Value) && !math.IsInf(m.Value, 0)
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// series returns n values, one a second, of value(i)
+func series(n int, value func(i int) float64) []model.Metric {
+	start := time.Unix(1700000000, 0)
+	metrics := make([]model.Metric, n)
+	for i := range metrics {
+		step := int64(i)
+		metrics[i] = model.Metric{Time: start.Add(time.Duration(i) * time.Second), Step: &step, Value: value(i)}
+	}
+	return metrics
+}
+
+func downsample(method string, points, total int, metrics []model.Metric) []model.Metric {
+	d := newDownsampler(method, points, total)
+	for _, m := range metrics {
+		d.add(m)
+	}
+	return d.finish()
+}
+
+func keptSteps(metrics []model.Metric) map[int64]bool {
+	steps := make(map[int64]bool, len(metrics))
+	for _, m := range metrics {
+		steps[*m.Step] = true
+	}
+	return steps
+}
+
+func TestDownsampleLTTB(t *testing.T) {
+	spiky := series(1000, func(i int) float64 {
+		switch i {
+		case 123:
+			return 50
+		case 777:
+			return -40
+		}
+		return math.Sin(float64(i) / 100)
+	})
+	spiky[400].Value = math.NaN()
+	text := "warmup"
+	spiky[401].ValueType, spiky[401].Text = model.ValueTypeString, &text
+
+	tests := []struct {
+		name      string
+		metrics   []model.Metric
+		total     int
+		points    int
+		wantLen   int
+		wantSteps []int64
+	}{
+		{"short series kept", series(5, func(i int) float64 { return float64(i) }), 5, 10, 5, []int64{0, 1, 2, 3, 4}},
+		{"spikes kept", spiky, 1000, 50, 50, []int64{0, 123, 777, 999}},
+		{"fewer than counted", spiky[:600], 1000, 50, 31, []int64{0, 123, 599}},
+		{"more than counted", spiky, 600, 50, 50, []int64{0, 123, 999}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept := downsample(DownsampleLTTB, tt.points, tt.total, tt.metrics)
+			if len(kept) != tt.wantLen {
+				t.Fatalf("kept %d points, want %d", len(kept), tt.wantLen)
+			}
+			steps := keptSteps(kept)
+			for _, step := range tt.wantSteps {
+				if !steps[step] {
+					t.Errorf("step %d not kept", step)
+				}
+			}
+			if steps[400] || steps[401] {
+				t.Error("kept a NaN or string value over plottable ones")
+			}
+			for i := 1; i < len(kept); i++ {
+				if !kept[i].Time.After(kept[i-1].Time) {
+					t.Fatalf("points out of order at %d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestDownsampleNth(t *testing.T) {
+	kept := downsample(DownsampleNth, 10, 100, series(100, func(i int) float64 { return float64(i) }))
+	if len(kept) > 10 {
+		t.Fatalf("kept %d points, want at most 10", len(kept))
+	}
+	for i, m := range kept[:len(kept)-1] {
+		if *m.Step != int64(i*11) {
+			t.Errorf("point %d is step %d, want %d", i, *m.Step, i*11)
+		}
+	}
+	if last := kept[len(kept)-1]; *last.Step != 99 {
+		t.Errorf("last point is step %d, want 99", *last.Step)
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// stopFlagTTL bounds how long a manual stop request is kept for a run
+const stopFlagTTL = 7 * 24 * time.Hour
+
+// EarlyStoppingService evaluates stop criteria so training loops can poll a single endpoint
+type EarlyStoppingService struct {
+	metricRepo  *repository.MetricRepository
+	anomalyRepo *repository.AnomalyRepository
+	redis       *redis.Client
+	logger      *zap.Logger
+	events      *CloudEventPublisher
+}
+
+func NewEarlyStoppingService(metricRepo *repository.MetricRepository, anomalyRepo *repository.AnomalyRepository, redis *redis.Client, logger *zap.Logger) *EarlyStoppingService {
+	return &EarlyStoppingService{
+		metricRepo:  metricRepo,
+		anomalyRepo: anomalyRepo,
+		redis:       redis,
+		logger:      logger,
+	}
+}
+
+// UseCloudEvents publishes the alerts of stop requests to events
+func (s *EarlyStoppingService) UseCloudEvents(events *CloudEventPublisher) {
+	s.events = events
+}
+
+// ShouldStop evaluates the manual stop flag, anomaly events and the
+// no-improvement criterion for a run
+func (s *EarlyStoppingService) ShouldStop(ctx context.Context, runID uuid.UUID, params model.ShouldStopParams) (*model.ShouldStopResponse, error) {
+	resp := &model.ShouldStopResponse{
+		RunID:      runID,
+		MetricName: params.MetricName,
+		Reasons:    []string{},
+	}
+
+	// Manual stop flag
+	reason, err := s.redis.Get(ctx, s.stopFlagKey(runID)).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read stop flag: %w", err)
+	}
+	if err == nil {
+		resp.ManualStop = true
+		if reason == "" {
+			reason = "manual stop requested"
+		}
+		resp.Reasons = append(resp.Reasons, reason)
+	}
+
+	// Anomaly events
+	if kinds := parseAnomalyKinds(params.StopOnAnomaly); len(kinds) > 0 {
+		count, err := s.anomalyRepo.CountRunAnomalies(ctx, runID, kinds)
+		if err != nil {
+			return nil, err
+		}
+		resp.AnomalyCount = count
+		if count > 0 {
+			resp.Reasons = append(resp.Reasons, fmt.Sprintf("%d anomaly event(s) of kind %s", count, strings.Join(kinds, ",")))
+		}
+	}
+
+	// No improvement in the objective metric for Patience steps
+	if params.MetricName != "" && params.Patience > 0 {
+		goal := params.Goal
+		if goal == "" {
+			goal = model.GoalMin
+		}
+
+		best, err := s.metricRepo.GetBestStep(ctx, runID, params.MetricName, goal, params.MinDelta)
+		if err != nil {
+			return nil, err
+		}
+		if best != nil && best.BestStep != nil && best.LatestStep != nil {
+			bestValue := best.BestValue
+			stepsSince := *best.LatestStep - *best.BestStep
+			resp.BestValue = &bestValue
+			resp.BestStep = best.BestStep
+			resp.LatestStep = best.LatestStep
+			resp.StepsSinceImprovement = &stepsSince
+
+			if stepsSince >= params.Patience {
+				resp.Reasons = append(resp.Reasons, fmt.Sprintf(
+					"no improvement in %s for %d steps (best %.6g at step %d)",
+					params.MetricName, stepsSince, bestValue, *best.BestStep))
+			}
+		}
+	}
+
+	resp.ShouldStop = len(resp.Reasons) > 0
+	return resp, nil
+}
+
+// SetStopFlag records a manual stop request for a run
+func (s *EarlyStoppingService) SetStopFlag(ctx context.Context, runID uuid.UUID, reason string) error {
+	if err := s.redis.Set(ctx, s.stopFlagKey(runID), reason, stopFlagTTL).Err(); err != nil {
+		return fmt.Errorf("failed to set stop flag: %w", err)
+	}
+	s.logger.Info("Manual stop requested", zap.String("run_id", runID.String()), zap.String("reason", reason))
+
+	message := "stop requested"
+	if reason != "" {
+		message += ": " + reason
+	}
+	publishAlerts(ctx, s.redis, s.events, s.logger, []model.Alert{{
+		Time:     time.Now(),
+		RunID:    runID,
+		Severity: model.AlertSeverityWarning,
+		Source:   "early_stopping",
+		Kind:     "stop_requested",
+		Message:  message,
+	}})
+	return nil
+}
+
+// ClearStopFlag removes a manual stop request for a run
+func (s *EarlyStoppingService) ClearStopFlag(ctx context.Context, runID uuid.UUID) error {
+	if err := s.redis.Del(ctx, s.stopFlagKey(runID)).Err(); err != nil {
+		return fmt.Errorf("failed to clear stop flag: %w", err)
+	}
+	return nil
+}
+
+func (s *EarlyStoppingService) stopFlagKey(runID uuid.UUID) string {
+	return fmt.Sprintf("run:stop:%s", runID.String())
+}
+
+// parseAnomalyKinds parses the stop_on_anomaly parameter, defaulting to NaN streaks
+func parseAnomalyKinds(value string) []string {
+	if value == "" {
+		return []string{model.AnomalyKindNaNStreak}
+	}
+	if value == "none" {
+		return nil
+	}
+
+	var kinds []string
+	for _, kind := range strings.Split(value, ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+const (
+	// maxEmbeddingDim bounds the size of a single embedding row
+	maxEmbeddingDim = 4096
+
+	defaultNearestK = 10
+
+	// maxNearestScan bounds how many embeddings a nearest-neighbor search
+	// compares; the newest are searched first
+	maxNearestScan = 50000
+)
+
+// EmbeddingService logs embedding vectors and answers nearest-neighbor and
+// drift queries over them. Similarity is computed in the service, so no
+// vector extension is needed in the database.
+type EmbeddingService struct {
+	repo   *repository.EmbeddingRepository
+	logger *zap.Logger
+}
+
+func NewEmbeddingService(repo *repository.EmbeddingRepository, logger *zap.Logger) *EmbeddingService {
+	return &EmbeddingService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// BatchWrite validates and writes embeddings
+func (s *EmbeddingService) BatchWrite(ctx context.Context, embeddings []model.Embedding) error {
+	if err := validateEmbeddings(embeddings); err != nil {
+		return err
+	}
+	return s.repo.BatchWrite(ctx, embeddings)
+}
+
+// GetEmbeddings retrieves the embeddings of a metric
+func (s *EmbeddingService) GetEmbeddings(ctx context.Context, runID uuid.UUID, metricName string, params model.EmbeddingQueryParams) ([]model.Embedding, error) {
+	return s.repo.GetEmbeddings(ctx, runID, metricName, params)
+}
+
+// ListEmbeddingSeries lists the embedding metrics of a run
+func (s *EmbeddingService) ListEmbeddingSeries(ctx context.Context, runID uuid.UUID) ([]model.EmbeddingSeries, error) {
+	return s.repo.ListEmbeddingSeries(ctx, runID)
+}
+
+// Nearest finds the k embeddings of a metric closest to the query. It returns
+// nil when the query names a key that was never logged.
+func (s *EmbeddingService) Nearest(ctx context.Context, runID uuid.UUID, metricName string, req model.NearestRequest) (*model.NearestResult, error) {
+	if (len(req.Vector) == 0) == (req.Key == "") {
+		return nil, &ValidationError{Message: "exactly one of vector or key is required"}
+	}
+	if req.K == 0 {
+		req.K = defaultNearestK
+	}
+	if req.Distance == "" {
+		req.Distance = model.DistanceCosine
+	}
+	if len(req.RunIDs) == 0 {
+		req.RunIDs = []uuid.UUID{runID}
+	}
+
+	query := req.Vector
+	var self *model.Embedding
+	if req.Key != "" {
+		e, err := s.repo.GetEmbedding(ctx, runID, metricName, req.Key, req.Step)
+		if err != nil || e == nil {
+			return nil, err
+		}
+		query, self = e.Vector, e
+	} else if err := validateVector(query); err != nil {
+		return nil, err
+	}
+
+	candidates, err := s.repo.ScanEmbeddings(ctx, req.RunIDs, metricName, len(query), req.MinStep, req.MaxStep, maxNearestScan+1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.NearestResult{
+		MetricName: metricName,
+		Distance:   req.Distance,
+		Neighbors:  []model.Neighbor{},
+	}
+	if len(candidates) > maxNearestScan {
+		candidates = candidates[:maxNearestScan]
+		result.Truncated = true
+	}
+	result.Scanned = len(candidates)
+
+	for _, c := range candidates {
+		// The query item is trivially its own nearest neighbor
+		if self != nil && c.RunID == self.RunID && c.Key == self.Key && sameStep(c.Step, self.Step) {
+			continue
+		}
+		d, ok := vectorDistance(req.Distance, query, c.Vector)
+		if !ok {
+			continue
+		}
+		c.Vector = nil
+		result.Neighbors = append(result.Neighbors, model.Neighbor{Embedding: c, Distance: d})
+	}
+
+	sort.SliceStable(result.Neighbors, func(i, j int) bool {
+		return result.Neighbors[i].Distance < result.Neighbors[j].Distance
+	})
+	if len(result.Neighbors) > req.K {
+		result.Neighbors = result.Neighbors[:req.K]
+	}
+	return result, nil
+}
+
+// Drift follows the centroid of a metric's embeddings step by step, measuring
+// its cosine distance from the first step and from the previous one. Steps
+// logged with a different dimension than the first are skipped.
+func (s *EmbeddingService) Drift(ctx context.Context, runID uuid.UUID, metricName string, params model.EmbeddingDriftParams) ([]model.EmbeddingDriftPoint, error) {
+	centroids, err := s.repo.GetStepCentroids(ctx, runID, metricName, params)
+	if err != nil {
+		return nil, err
+	}
+
+	points := []model.EmbeddingDriftPoint{}
+	var first, previous []float64
+	for _, c := range centroids {
+		if first != nil && c.Dim != len(first) {
+			continue
+		}
+		if first == nil {
+			first, previous = c.Centroid, c.Centroid
+		}
+		points = append(points, model.EmbeddingDriftPoint{
+			Step:         c.Step,
+			Count:        c.Count,
+			FromFirst:    cosineDistance(first, c.Centroid),
+			FromPrevious: cosineDistance(previous, c.Centroid),
+			CentroidNorm: math.Sqrt(dot(c.Centroid, c.Centroid)),
+		})
+		previous = c.Centroid
+	}
+	return points, nil
+}
+
+func sameStep(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// vectorDistance measures how far b is from a; smaller is closer for every
+// distance. Cosine distance is undefined for zero vectors.
+func vectorDistance(distance string, a, b []float32) (float64, bool) {
+	var ab, aa, bb float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		ab += x * y
+		aa += x * x
+		bb += y * y
+	}
+
+	switch distance {
+	case model.DistanceEuclidean:
+		return math.Sqrt(math.Max(aa+bb-2*ab, 0)), true
+	case model.DistanceDot:
+		return -ab, true
+	default:
+		if aa == 0 || bb == 0 {
+			return 0, false
+		}
+		return 1 - ab/math.Sqrt(aa*bb), true
+	}
+}
+
+func cosineDistance(a, b []float64) float64 {
+	norms := math.Sqrt(dot(a, a) * dot(b, b))
+	if norms == 0 {
+		return 0
+	}
+	return 1 - dot(a, b)/norms
+}
+
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func validateVector(vector []float32) error {
+	if len(vector) == 0 || len(vector) > maxEmbeddingDim {
+		return &ValidationError{Message: fmt.Sprintf("vector must have between 1 and %d dimensions", maxEmbeddingDim)}
+	}
+	for _, v := range vector {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return &ValidationError{Message: "vector components must be finite"}
+		}
+	}
+	return nil
+}
+
+func validateEmbeddings(embeddings []model.Embedding) error {
+	// Within a batch, every embedding of a metric must have the same dimension
+	dims := make(map[string]int)
+	for i, e := range embeddings {
+		if e.RunID == uuid.Nil {
+			return &ValidationError{Message: fmt.Sprintf("embedding %d: run_id is required", i)}
+		}
+		if e.MetricName == "" {
+			return &ValidationError{Message: fmt.Sprintf("embedding %d: metric_name is required", i)}
+		}
+		if e.Key == "" || len(e.Key) > 255 {
+			return &ValidationError{Message: fmt.Sprintf("embedding %d (%s): key is required and at most 255 characters", i, e.MetricName)}
+		}
+		if err := validateVector(e.Vector); err != nil {
+			return &ValidationError{Message: fmt.Sprintf("embedding %d (%s): %s", i, e.MetricName, err.Error())}
+		}
+		if dim, ok := dims[e.MetricName]; ok && dim != len(e.Vector) {
+			return &ValidationError{Message: fmt.Sprintf("embedding %d (%s): expected %d dimensions like the rest of the batch, got %d", i, e.MetricName, dim, len(e.Vector))}
+		}
+		dims[e.MetricName] = len(e.Vector)
+
+		embeddings[i].Dim = len(e.Vector)
+		if e.Time.IsZero() {
+			embeddings[i].Time = time.Now()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// ValidationError marks errors caused by invalid client input, which handlers
+// report as 400 rather than 500
+type ValidationError struct {
+	Message string
+	// Items lists every invalid item of a batch request, in order
+	Items []ItemError
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// ItemError says what is wrong with one item of a batch request. Field is
+// the JSON name of the offending field, when one is to blame; Reason reads
+// on its own, e.g. "run_id is required".
+type ItemError struct {
+	Index  int    `json:"index"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// NewBatchValidationError reports the invalid items of a batch of noun
+// (e.g. "metric"), or returns nil when there are none. The message names the
+// first item and how many more there are.
+func NewBatchValidationError(noun string, items []ItemError) error {
+	if len(items) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("%s %d: %s", noun, items[0].Index, items[0].Reason)
+	switch len(items) {
+	case 1:
+	case 2:
+		msg += " (and 1 more error)"
+	default:
+		msg += fmt.Sprintf(" (and %d more errors)", len(items)-1)
+	}
+	return &ValidationError{Message: msg, Items: items}
+}
+
+// RowLimitError marks queries that would read more rows than the configured
+// caps, which handlers report as 422 so the client narrows the query
+type RowLimitError = repository.RowLimitError
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/kafka"
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/pubsub"
+	"github.com/wanllmdb/metric-service/internal/sns"
+)
+
+// EventSink is a destination of CloudEvents. Send receives the event along
+// with its structured JSON encoding, body, and is called from one goroutine.
+// Sinks that hold connections implement io.Closer.
+type EventSink interface {
+	Send(ctx context.Context, event model.CloudEvent, body []byte) error
+	String() string
+}
+
+// webhookEventSink posts events to a URL in the structured mode of the HTTP
+// binding
+type webhookEventSink struct {
+	url    string
+	sender *webhookSender
+}
+
+// NewWebhookEventSink posts events to url, signed with secret when set
+func NewWebhookEventSink(url, secret string, timeout time.Duration, logger *zap.Logger) EventSink {
+	return &webhookEventSink{url: url, sender: newWebhookSender(secret, timeout, logger)}
+}
+
+func (s *webhookEventSink) Send(ctx context.Context, event model.CloudEvent, body []byte) error {
+	return s.sender.post(ctx, s.url, map[string]string{"Content-Type": cloudEventContentType}, body)
+}
+
+func (s *webhookEventSink) String() string { return s.url }
+
+// kafkaEventSink produces events to a topic in the structured mode of the
+// Kafka binding, keyed by run
+type kafkaEventSink struct {
+	producer *kafka.Producer
+	topic    string
+}
+
+func NewKafkaEventSink(producer *kafka.Producer, topic string) EventSink {
+	return &kafkaEventSink{producer: producer, topic: topic}
+}
+
+func (s *kafkaEventSink) Send(ctx context.Context, event model.CloudEvent, body []byte) error {
+	return s.producer.Produce(ctx, []kafka.Message{{
+		Topic:   s.topic,
+		Key:     []byte(event.Subject),
+		Value:   body,
+		Headers: []kafka.Header{{Key: "content-type", Value: []byte(cloudEventContentType)}},
+	}})
+}
+
+func (s *kafkaEventSink) String() string { return "kafka:" + s.topic }
+
+func (s *kafkaEventSink) Close() error { return s.producer.Close() }
+
+// eventAttributes are the message attributes of an event on Pub/Sub and
+// SNS: the content type the structured mode requires, and the event type, so
+// that subscriptions filter by it without decoding messages
+func eventAttributes(event model.CloudEvent) map[string]string {
+	return map[string]string{"content-type": cloudEventContentType, "ce-type": event.Type}
+}
+
+// pubSubEventSink publishes events to a Pub/Sub topic in the structured
+// mode, ordered by run
+type pubSubEventSink struct {
+	client *pubsub.Client
+	topic  string
+}
+
+func NewPubSubEventSink(client *pubsub.Client, topic string) EventSink {
+	return &pubSubEventSink{client: client, topic: topic}
+}
+
+func (s *pubSubEventSink) Send(ctx context.Context, event model.CloudEvent, body []byte) error {
+	_, err := s.client.Publish(ctx, pubsub.Message{
+		Data:        body,
+		Attributes:  eventAttributes(event),
+		OrderingKey: event.Subject,
+	})
+	return err
+}
+
+func (s *pubSubEventSink) String() string { return "pubsub:" + s.topic }
+
+// snsEventSink publishes events to an SNS topic. FIFO topics group messages
+// by run and deduplicate them by event ID, so redelivered events are
+// dropped.
+type snsEventSink struct {
+	client   *sns.Client
+	topicARN string
+}
+
+func NewSNSEventSink(client *sns.Client, topicARN string) EventSink {
+	return &snsEventSink{client: client, topicARN: topicARN}
+}
+
+func (s *snsEventSink) Send(ctx context.Context, event model.CloudEvent, body []byte) error {
+	_, err := s.client.Publish(ctx, sns.Message{
+		Body:            string(body),
+		Attributes:      eventAttributes(event),
+		GroupID:         event.Subject,
+		DeduplicationID: event.ID,
+	})
+	return err
+}
+
+func (s *snsEventSink) String() string { return "sns:" + s.topicARN }
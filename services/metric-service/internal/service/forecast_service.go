@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+const (
+	defaultForecastWindow = 200
+	defaultForecastPoints = 20
+
+	// minForecastSamples is the fewest steps a trend is fitted to
+	minForecastSamples = 3
+
+	// maxForecastSteps bounds how far ahead a crossing is still reported
+	maxForecastSteps = 1e9
+)
+
+// ForecastService projects when a metric will reach a target by fitting a
+// trend to the latest steps of its history
+type ForecastService struct {
+	metricRepo *repository.MetricRepository
+	logger     *zap.Logger
+}
+
+func NewForecastService(metricRepo *repository.MetricRepository, logger *zap.Logger) *ForecastService {
+	return &ForecastService{
+		metricRepo: metricRepo,
+		logger:     logger,
+	}
+}
+
+// trend is a fitted model of value against step
+type trend struct {
+	model     string
+	intercept float64
+	slope     float64
+	rSquared  float64
+}
+
+func (t trend) predict(step float64) float64 {
+	if t.model == model.ForecastModelExponential {
+		return math.Exp(t.intercept + t.slope*step)
+	}
+	return t.intercept + t.slope*step
+}
+
+// crossing returns the step at which the trend reaches target
+func (t trend) crossing(target float64) float64 {
+	if t.model == model.ForecastModelExponential {
+		return (math.Log(target) - t.intercept) / t.slope
+	}
+	return (target - t.intercept) / t.slope
+}
+
+// Forecast fits a trend to the recent history of a metric and projects when
+// it crosses params.Target. It returns nil when the metric has too few steps.
+func (s *ForecastService) Forecast(ctx context.Context, runID uuid.UUID, metricName string, params model.ForecastParams) (*model.Forecast, error) {
+	if params.Model == "" {
+		params.Model = model.ForecastModelAuto
+	}
+	if params.Window == 0 {
+		params.Window = defaultForecastWindow
+	}
+	if params.Points == 0 {
+		params.Points = defaultForecastPoints
+	}
+	if params.Window < minForecastSamples {
+		return nil, &ValidationError{Message: "window must cover at least 3 steps"}
+	}
+	target := *params.Target
+
+	points, err := s.metricRepo.GetRecentSteps(ctx, runID, metricName, params.Window)
+	if err != nil {
+		return nil, err
+	}
+	if len(points) < minForecastSamples {
+		return nil, nil
+	}
+
+	steps := make([]float64, len(points))
+	values := make([]float64, len(points))
+	seconds := make([]float64, len(points))
+	positive := target > 0
+	for i, p := range points {
+		steps[i] = float64(*p.Step)
+		values[i] = p.Value
+		seconds[i] = p.Time.Sub(points[0].Time).Seconds()
+		if p.Value <= 0 {
+			positive = false
+		}
+	}
+
+	var fit trend
+	switch params.Model {
+	case model.ForecastModelLinear:
+		fit = fitLinear(steps, values)
+	case model.ForecastModelExponential:
+		if !positive {
+			return nil, &ValidationError{Message: "exponential model needs a positive target and positive values"}
+		}
+		fit = fitExponential(steps, values)
+	default:
+		fit = fitLinear(steps, values)
+		if positive {
+			if exp := fitExponential(steps, values); exp.rSquared > fit.rSquared {
+				fit = exp
+			}
+		}
+	}
+
+	latest := points[len(points)-1]
+	forecast := &model.Forecast{
+		RunID:          runID,
+		MetricName:     metricName,
+		Model:          fit.model,
+		Target:         target,
+		SampleCount:    len(points),
+		LatestValue:    latest.Value,
+		LatestStep:     *latest.Step,
+		LatestTime:     latest.Time,
+		RSquared:       fit.rSquared,
+		SecondsPerStep: math.Max(fitLinear(steps, seconds).slope, 0),
+	}
+
+	// The trend's direction decides which side of the target counts as reached
+	switch {
+	case fit.slope < 0:
+		forecast.Reached = latest.Value <= target
+		forecast.Converging = !forecast.Reached && target < latest.Value
+	case fit.slope > 0:
+		forecast.Reached = latest.Value >= target
+		forecast.Converging = !forecast.Reached && target > latest.Value
+	default:
+		forecast.Reached = latest.Value == target
+	}
+
+	horizon := steps[len(steps)-1] + (steps[len(steps)-1] - steps[0])
+	if forecast.Converging {
+		// The fitted curve can lag the latest value, so the crossing is at least the next step
+		cross := math.Max(math.Ceil(fit.crossing(target)), steps[len(steps)-1]+1)
+		if cross-steps[len(steps)-1] <= maxForecastSteps {
+			etaStep := int64(cross)
+			etaSeconds := (cross - steps[len(steps)-1]) * forecast.SecondsPerStep
+			forecast.ETAStep = &etaStep
+			if forecast.SecondsPerStep > 0 {
+				eta := latest.Time.Add(time.Duration(etaSeconds * float64(time.Second)))
+				forecast.ETASeconds = &etaSeconds
+				forecast.ETA = &eta
+			}
+			horizon = cross
+		} else {
+			forecast.Converging = false
+		}
+	}
+
+	forecast.Projection = project(fit, latest, horizon, forecast.SecondsPerStep, params.Points)
+	return forecast, nil
+}
+
+// project samples the fitted trend at n evenly spaced steps after the latest one up to horizon
+func project(fit trend, latest model.AggregatePoint, horizon, secondsPerStep float64, n int) []model.ForecastPoint {
+	from := float64(*latest.Step)
+	if horizon <= from {
+		return []model.ForecastPoint{}
+	}
+
+	projection := make([]model.ForecastPoint, 0, n)
+	last := int64(-1)
+	for i := 1; i <= n; i++ {
+		step := int64(math.Round(from + (horizon-from)*float64(i)/float64(n)))
+		if step <= last {
+			continue
+		}
+		last = step
+		ahead := float64(step) - from
+		projection = append(projection, model.ForecastPoint{
+			Step:  step,
+			Time:  latest.Time.Add(time.Duration(ahead * secondsPerStep * float64(time.Second))),
+			Value: fit.predict(float64(step)),
+		})
+	}
+	return projection
+}
+
+// fitLinear fits y = intercept + slope*x by least squares
+func fitLinear(xs, ys []float64) trend {
+	intercept, slope := leastSquares(xs, ys)
+	t := trend{model: model.ForecastModelLinear, intercept: intercept, slope: slope}
+	t.rSquared = rSquared(t, xs, ys)
+	return t
+}
+
+// fitExponential fits y = exp(intercept + slope*x) by least squares on log(y).
+// Every y must be positive.
+func fitExponential(xs, ys []float64) trend {
+	logs := make([]float64, len(ys))
+	for i, y := range ys {
+		logs[i] = math.Log(y)
+	}
+	intercept, slope := leastSquares(xs, logs)
+	t := trend{model: model.ForecastModelExponential, intercept: intercept, slope: slope}
+	t.rSquared = rSquared(t, xs, ys)
+	return t
+}
+
+func leastSquares(xs, ys []float64) (intercept, slope float64) {
+	n := float64(len(xs))
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	var cov, varX float64
+	for i := range xs {
+		cov += (xs[i] - meanX) * (ys[i] - meanY)
+		varX += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if varX == 0 {
+		return meanY, 0
+	}
+	slope = cov / varX
+	return meanY - slope*meanX, slope
+}
+
+// rSquared measures how well a trend explains ys in their original scale,
+// so linear and exponential fits can be compared
+func rSquared(t trend, xs, ys []float64) float64 {
+	var mean float64
+	for _, y := range ys {
+		mean += y
+	}
+	mean /= float64(len(ys))
+
+	var ssRes, ssTot float64
+	for i, y := range ys {
+		r := y - t.predict(xs[i])
+		ssRes += r * r
+		ssTot += (y - mean) * (y - mean)
+	}
+	if ssTot == 0 {
+		if ssRes == 0 {
+			return 1
+		}
+		return 0
+	}
+	return 1 - ssRes/ssTot
+}
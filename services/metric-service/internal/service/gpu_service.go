@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// GPUMetricObserver is notified after a GPU batch has been persisted, on
+// the request path
+type GPUMetricObserver interface {
+	ObserveGPUMetrics(ctx context.Context, metrics []model.GPUMetric)
+}
+
+// GPUService handles structured per-device GPU metrics
+type GPUService struct {
+	repo      *repository.GPURepository
+	logger    *zap.Logger
+	observers []GPUMetricObserver
+}
+
+func NewGPUService(repo *repository.GPURepository, logger *zap.Logger) *GPUService {
+	return &GPUService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// BatchWrite validates and writes GPU samples
+func (s *GPUService) BatchWrite(ctx context.Context, metrics []model.GPUMetric) error {
+	for i, m := range metrics {
+		if m.RunID == uuid.Nil {
+			return &ValidationError{Message: fmt.Sprintf("metric %d: run_id is required", i)}
+		}
+		if m.DeviceIndex < 0 {
+			return &ValidationError{Message: fmt.Sprintf("metric %d: device_index must be non-negative", i)}
+		}
+		if m.Time.IsZero() {
+			metrics[i].Time = time.Now()
+		}
+	}
+	if err := s.repo.BatchWrite(ctx, metrics); err != nil {
+		return err
+	}
+	for _, observer := range s.observers {
+		observer.ObserveGPUMetrics(ctx, metrics)
+	}
+	return nil
+}
+
+// RegisterObserver adds an observer that receives every persisted batch
+func (s *GPUService) RegisterObserver(observer GPUMetricObserver) {
+	s.observers = append(s.observers, observer)
+}
+
+// GetDeviceSeries retrieves GPU samples of a run grouped per device
+func (s *GPUService) GetDeviceSeries(ctx context.Context, runID uuid.UUID, params model.GPUMetricQueryParams) ([]model.GPUDeviceSeries, error) {
+	metrics, err := s.repo.GetGPUMetrics(ctx, runID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows arrive ordered by device, so each device is one contiguous run
+	var series []model.GPUDeviceSeries
+	for _, m := range metrics {
+		last := len(series) - 1
+		if last < 0 || series[last].NodeID != m.NodeID || series[last].DeviceIndex != m.DeviceIndex || series[last].DeviceUUID != m.DeviceUUID {
+			series = append(series, model.GPUDeviceSeries{
+				NodeID:      m.NodeID,
+				DeviceIndex: m.DeviceIndex,
+				DeviceUUID:  m.DeviceUUID,
+				DeviceName:  m.DeviceName,
+			})
+			last++
+		}
+		series[last].Metrics = append(series[last].Metrics, m)
+	}
+
+	return series, nil
+}
+
+// GetGPUSummary aggregates each device of a run
+func (s *GPUService) GetGPUSummary(ctx context.Context, runID uuid.UUID) ([]model.GPUDeviceSummary, error) {
+	return s.repo.GetGPUSummary(ctx, runID)
+}
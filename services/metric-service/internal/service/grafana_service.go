@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// GrafanaService answers the Grafana JSON datasource contract from run
+// metrics and annotations
+type GrafanaService struct {
+	metrics     *MetricService
+	annotations *AnnotationService
+	logger      *zap.Logger
+}
+
+func NewGrafanaService(metrics *MetricService, annotations *AnnotationService, logger *zap.Logger) *GrafanaService {
+	return &GrafanaService{
+		metrics:     metrics,
+		annotations: annotations,
+		logger:      logger,
+	}
+}
+
+// Search lists the targets matching a query of the form <run_id> or
+// <run_id>:<metric name prefix>. Anything else matches nothing, as Grafana
+// searches while the query is typed.
+func (s *GrafanaService) Search(ctx context.Context, query string) ([]string, error) {
+	runIDStr, prefix, _ := strings.Cut(strings.TrimSpace(query), model.GrafanaTargetSeparator)
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		return []string{}, nil
+	}
+
+	names, err := s.metrics.ListMetricNames(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	targets := []string{}
+	for _, name := range names {
+		if strings.HasPrefix(name.MetricName, prefix) {
+			targets = append(targets, runID.String()+model.GrafanaTargetSeparator+name.MetricName)
+		}
+	}
+	return targets, nil
+}
+
+// Query answers each target with the values of its metric within the
+// range, a time series or a table as the target asks, oldest first. At most
+// maxDataPoints values, the latest in the range, are returned per target.
+func (s *GrafanaService) Query(ctx context.Context, req model.GrafanaQueryRequest) ([]interface{}, error) {
+	limit := req.MaxDataPoints
+	if limit == 0 {
+		limit = model.DefaultMetricQueryLimit
+	}
+	if limit > model.MaxMetricQueryLimit {
+		limit = model.MaxMetricQueryLimit
+	}
+
+	results := make([]interface{}, 0, len(req.Targets))
+	for _, target := range req.Targets {
+		runID, metricName, err := parseGrafanaTarget(target.Target)
+		if err != nil {
+			return nil, err
+		}
+		metrics, err := s.metrics.GetMetricHistory(ctx, runID, metricName, model.MetricQueryParams{
+			StartTime: &req.Range.From,
+			EndTime:   &req.Range.To,
+			Limit:     limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if target.Type == model.GrafanaTable {
+			results = append(results, grafanaTable(target, metrics))
+		} else {
+			results = append(results, grafanaSeries(target, metrics))
+		}
+	}
+	return results, nil
+}
+
+// Annotations lists the annotations within the range of the run named by
+// the annotation query
+func (s *GrafanaService) Annotations(ctx context.Context, req model.GrafanaAnnotationRequest) ([]model.GrafanaAnnotation, error) {
+	runID, err := uuid.Parse(strings.TrimSpace(req.Annotation.Query))
+	if err != nil {
+		return nil, &ValidationError{Message: "annotation query must be a run ID"}
+	}
+
+	annotations, err := s.annotations.GetRunAnnotations(ctx, runID, model.AnnotationQueryParams{
+		StartTime: &req.Range.From,
+		EndTime:   &req.Range.To,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]model.GrafanaAnnotation, 0, len(annotations))
+	for _, a := range annotations {
+		result := model.GrafanaAnnotation{
+			Annotation: req.Annotation,
+			Time:       a.Time.UnixMilli(),
+			Title:      a.Text,
+			Tags:       []string{},
+		}
+		if a.Step != nil {
+			result.Text = fmt.Sprintf("step %d", *a.Step)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// parseGrafanaTarget splits a target into its run ID and metric name
+func parseGrafanaTarget(target string) (uuid.UUID, string, error) {
+	runIDStr, metricName, found := strings.Cut(target, model.GrafanaTargetSeparator)
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil || !found || metricName == "" {
+		return uuid.Nil, "", &ValidationError{Message: fmt.Sprintf("target %q must be <run_id>:<metric_name>", target)}
+	}
+	return runID, metricName, nil
+}
+
+// grafanaSeries turns metrics, newest first, into a time series of their
+// numeric values. Per-rank values are left out for the reduced ones.
+func grafanaSeries(target model.GrafanaTarget, metrics []model.Metric) model.GrafanaSeries {
+	series := model.GrafanaSeries{Target: target.Target, RefID: target.RefID, Datapoints: [][]interface{}{}}
+	for i := len(metrics) - 1; i >= 0; i-- {
+		m := metrics[i]
+		if m.Rank != nil || !m.IsNumeric() {
+			continue
+		}
+		series.Datapoints = append(series.Datapoints, []interface{}{grafanaValue(m), m.Time.UnixMilli()})
+	}
+	return series
+}
+
+// grafanaTable turns metrics, newest first, into a table of their values,
+// strings and bools included
+func grafanaTable(target model.GrafanaTarget, metrics []model.Metric) model.GrafanaTableResult {
+	table := model.GrafanaTableResult{
+		Type:  model.GrafanaTable,
+		RefID: target.RefID,
+		Columns: []model.GrafanaColumn{
+			{Text: "Time", Type: "time"},
+			{Text: "Step", Type: "number"},
+			{Text: target.Target, Type: "number"},
+		},
+		Rows: [][]interface{}{},
+	}
+	for i := len(metrics) - 1; i >= 0; i-- {
+		m := metrics[i]
+		if m.Rank != nil {
+			continue
+		}
+		if !m.IsNumeric() {
+			table.Columns[2].Type = "string"
+		}
+		table.Rows = append(table.Rows, []interface{}{m.Time.UnixMilli(), m.Step, grafanaValue(m)})
+	}
+	return table
+}
+
+// grafanaValue is the value of a metric as JSON can carry it: null for
+// non-finite numbers, and the text of strings and bools
+func grafanaValue(m model.Metric) interface{} {
+	if !m.IsNumeric() {
+		if m.Text == nil {
+			return nil
+		}
+		return *m.Text
+	}
+	if model.NonFiniteName(m.Value) != "" {
+		return nil
+	}
+	return m.Value
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// GroupService organizes runs into groups, such as the seeds of one
+// experiment, and aggregates metrics across the runs of a group
+type GroupService struct {
+	repo   *repository.GroupRepository
+	logger *zap.Logger
+}
+
+func NewGroupService(repo *repository.GroupRepository, logger *zap.Logger) *GroupService {
+	return &GroupService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// SetRunGroup places a run in a group, replacing any earlier group
+func (s *GroupService) SetRunGroup(ctx context.Context, runID uuid.UUID, req model.SetRunGroupRequest) (*model.RunGroup, error) {
+	group := strings.TrimSpace(req.Group)
+	if group == "" || strings.Contains(group, "/") {
+		return nil, &ValidationError{Message: "group must be non-empty and must not contain '/'"}
+	}
+
+	g := &model.RunGroup{
+		RunID:   runID,
+		Group:   group,
+		JobType: strings.TrimSpace(req.JobType),
+	}
+	if err := s.repo.SetRunGroup(ctx, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// GetRunGroup retrieves the group of a run
+func (s *GroupService) GetRunGroup(ctx context.Context, runID uuid.UUID) (*model.RunGroup, error) {
+	return s.repo.GetRunGroup(ctx, runID)
+}
+
+// DeleteRunGroup removes a run from its group
+func (s *GroupService) DeleteRunGroup(ctx context.Context, runID uuid.UUID) (bool, error) {
+	return s.repo.DeleteRunGroup(ctx, runID)
+}
+
+// ListGroups lists groups with their job types and run counts
+func (s *GroupService) ListGroups(ctx context.Context, params model.GroupQueryParams) ([]model.GroupInfo, error) {
+	return s.repo.ListGroups(ctx, params)
+}
+
+// GetGroupMetric combines a metric per step across the runs of a group
+func (s *GroupService) GetGroupMetric(ctx context.Context, group, metricName string, params model.GroupMetricQueryParams) ([]model.AggregatePoint, error) {
+	switch params.Reduce {
+	case model.ReduceMean, model.ReduceMax, model.ReduceMin, model.ReduceSum:
+	default:
+		return nil, &ValidationError{Message: fmt.Sprintf("unsupported reduce %q (use mean, max, min or sum)", params.Reduce)}
+	}
+
+	return s.repo.GetGroupMetric(ctx, group, metricName, params)
+}
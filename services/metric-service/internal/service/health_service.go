@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// healthCheckTimeout bounds each component check, so that a hung dependency
+// is reported rather than hanging the health request
+const healthCheckTimeout = 2 * time.Second
+
+// HealthService checks the components the service needs to take writes: the
+// database, Redis, the ingest queues and the background jobs
+type HealthService struct {
+	repo      *repository.MetricRepository
+	redis     *redis.Client
+	ingest    *IngestPool
+	scheduler *Scheduler
+	startedAt time.Time
+	logger    *zap.Logger
+}
+
+func NewHealthService(repo *repository.MetricRepository, redis *redis.Client, logger *zap.Logger) *HealthService {
+	return &HealthService{
+		repo:      repo,
+		redis:     redis,
+		startedAt: time.Now(),
+		logger:    logger,
+	}
+}
+
+// UseIngestPool adds the depth of the ingest queues to the checks
+func (s *HealthService) UseIngestPool(pool *IngestPool) {
+	s.ingest = pool
+}
+
+// UseScheduler adds the state of the scheduled jobs to the checks
+func (s *HealthService) UseScheduler(scheduler *Scheduler) {
+	s.scheduler = scheduler
+}
+
+// componentCheck fills in the status of a component, which starts healthy
+type componentCheck struct {
+	name  string
+	check func(ctx context.Context, c *model.ComponentHealth)
+}
+
+// Check runs every component check at once and reports the service healthy
+// only when all of them are
+func (s *HealthService) Check(ctx context.Context) *model.Health {
+	checks := []componentCheck{
+		{"database", s.checkDatabase},
+		{"redis", s.checkRedis},
+	}
+	if s.ingest != nil {
+		checks = append(checks, componentCheck{"ingest_queue", s.checkIngest})
+	}
+	if s.scheduler != nil {
+		checks = append(checks, componentCheck{"background_jobs", s.checkJobs})
+	}
+
+	health := &model.Health{
+		Status:     model.HealthHealthy,
+		StartedAt:  s.startedAt,
+		Components: make([]model.ComponentHealth, len(checks)),
+	}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		i, check := i, check
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			c := &health.Components[i]
+			c.Name = check.name
+			c.Status = model.HealthHealthy
+			start := time.Now()
+			check.check(ctx, c)
+			c.CheckedAt = time.Now()
+			c.LatencyMs = float64(c.CheckedAt.Sub(start).Microseconds()) / 1000
+		}()
+	}
+	wg.Wait()
+
+	for _, c := range health.Components {
+		if c.Status != model.HealthHealthy {
+			health.Status = model.HealthDegraded
+		}
+	}
+	health.Timestamp = time.Now()
+	return health
+}
+
+// checkDatabase reports the database unhealthy when it cannot be reached or
+// does not take writes
+func (s *HealthService) checkDatabase(ctx context.Context, c *model.ComponentHealth) {
+	acquired, total, max := s.repo.PoolStats()
+	c.Details = map[string]interface{}{
+		"acquired_conns": acquired,
+		"total_conns":    total,
+		"max_conns":      max,
+	}
+
+	writable, err := s.repo.CheckWritable(ctx)
+	switch {
+	case err != nil:
+		c.Status = model.HealthUnhealthy
+		c.Error = err.Error()
+	case !writable:
+		c.Status = model.HealthUnhealthy
+		c.Error = "database is read-only"
+	}
+}
+
+// checkRedis reports Redis unhealthy when it does not answer a ping; live
+// streams, caching and the scheduler depend on it
+func (s *HealthService) checkRedis(ctx context.Context, c *model.ComponentHealth) {
+	if err := s.redis.Ping(ctx).Err(); err != nil {
+		c.Status = model.HealthUnhealthy
+		c.Error = err.Error()
+	}
+}
+
+// checkIngest reports the ingest queues degraded when a shard is full, as
+// writes to its runs then wait for room
+func (s *HealthService) checkIngest(_ context.Context, c *model.ComponentHealth) {
+	queued, capacity, full := s.ingest.QueueDepth()
+	c.Details = map[string]interface{}{
+		"queued":      queued,
+		"capacity":    capacity,
+		"full_shards": full,
+	}
+	if full > 0 {
+		c.Status = model.HealthDegraded
+		c.Error = "ingest queue is full"
+	}
+}
+
+// checkJobs reports the background jobs degraded when the last run of any
+// of them failed
+func (s *HealthService) checkJobs(ctx context.Context, c *model.ComponentHealth) {
+	status, err := s.scheduler.Status(ctx)
+	if err != nil {
+		c.Status = model.HealthUnhealthy
+		c.Error = err.Error()
+		return
+	}
+
+	var failed []string
+	jobs := make(map[string]interface{}, len(status.Jobs))
+	for _, job := range status.Jobs {
+		jobs[job.Name] = map[string]interface{}{
+			"running":       job.Running,
+			"last_finished": job.LastFinished,
+			"last_error":    job.LastError,
+		}
+		if job.LastError != "" {
+			failed = append(failed, job.Name)
+		}
+	}
+	c.Details = map[string]interface{}{
+		"leader": status.Leader,
+		"jobs":   jobs,
+	}
+	if len(failed) > 0 {
+		c.Status = model.HealthDegraded
+		c.Error = "last run failed: " + strings.Join(failed, ", ")
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// maxHistogramBins bounds the size of a single histogram row
+const maxHistogramBins = 1024
+
+// HistogramService handles histogram metrics
+type HistogramService struct {
+	repo   *repository.HistogramRepository
+	logger *zap.Logger
+}
+
+func NewHistogramService(repo *repository.HistogramRepository, logger *zap.Logger) *HistogramService {
+	return &HistogramService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// BatchWrite validates and writes histograms
+func (s *HistogramService) BatchWrite(ctx context.Context, histograms []model.HistogramMetric) error {
+	if err := validateHistograms(histograms); err != nil {
+		return err
+	}
+	return s.repo.BatchWrite(ctx, histograms)
+}
+
+// GetHistogramHistory retrieves the per-step distributions of a histogram metric
+func (s *HistogramService) GetHistogramHistory(ctx context.Context, runID uuid.UUID, metricName string, params model.HistogramQueryParams) ([]model.HistogramMetric, error) {
+	return s.repo.GetHistogramHistory(ctx, runID, metricName, params)
+}
+
+// ListHistogramSeries lists the histogram metrics of a run
+func (s *HistogramService) ListHistogramSeries(ctx context.Context, runID uuid.UUID) ([]model.HistogramSeries, error) {
+	return s.repo.ListHistogramSeries(ctx, runID)
+}
+
+func validateHistograms(histograms []model.HistogramMetric) error {
+	for i, h := range histograms {
+		if h.RunID == uuid.Nil {
+			return &ValidationError{Message: fmt.Sprintf("histogram %d: run_id is required", i)}
+		}
+		if h.MetricName == "" {
+			return &ValidationError{Message: fmt.Sprintf("histogram %d: metric_name is required", i)}
+		}
+		if len(h.Counts) == 0 || len(h.Counts) > maxHistogramBins {
+			return &ValidationError{Message: fmt.Sprintf("histogram %d (%s): must have between 1 and %d bins", i, h.MetricName, maxHistogramBins)}
+		}
+		if len(h.BinEdges) != len(h.Counts)+1 {
+			return &ValidationError{Message: fmt.Sprintf("histogram %d (%s): expected %d bin edges for %d counts, got %d", i, h.MetricName, len(h.Counts)+1, len(h.Counts), len(h.BinEdges))}
+		}
+		for j, edge := range h.BinEdges {
+			if math.IsNaN(edge) || math.IsInf(edge, 0) {
+				return &ValidationError{Message: fmt.Sprintf("histogram %d (%s): bin edges must be finite", i, h.MetricName)}
+			}
+			if j > 0 && edge <= h.BinEdges[j-1] {
+				return &ValidationError{Message: fmt.Sprintf("histogram %d (%s): bin edges must be strictly increasing", i, h.MetricName)}
+			}
+		}
+		for _, count := range h.Counts {
+			if math.IsNaN(count) || math.IsInf(count, 0) || count < 0 {
+				return &ValidationError{Message: fmt.Sprintf("histogram %d (%s): counts must be finite and non-negative", i, h.MetricName)}
+			}
+		}
+		if h.Time.IsZero() {
+			histograms[i].Time = time.Now()
+		}
+	}
+	return nil
+}
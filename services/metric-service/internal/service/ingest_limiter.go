@@ -0,0 +1,194 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// runBucketSweep is how often the rate buckets of runs that stopped writing
+// are dropped
+const runBucketSweep = time.Minute
+
+// BackpressureError marks writes shed because ingest is saturated or over a
+// rate limit, which handlers report as 429 with RetryAfter
+type BackpressureError struct {
+	Reason string
+	// RunID is the run over its own rate limit, or uuid.Nil
+	RunID      uuid.UUID
+	RetryAfter time.Duration
+}
+
+func (e *BackpressureError) Error() string {
+	return e.Reason
+}
+
+// IngestLimits configure an IngestLimiter. Rates are metrics per second,
+// allowing bursts of Burst metrics; a zero rate is unlimited, and a zero
+// burst allows a second of the rate.
+type IngestLimits struct {
+	Rate     float64
+	Burst    int
+	RunRate  float64
+	RunBurst int
+	// QueueThreshold is the fill, from 0 to 1, of the write buffer or of the
+	// ingest queue of a run past which its writes are shed; zero never sheds
+	QueueThreshold float64
+	// RetryAfter is when clients shed for a full queue are told to retry
+	RetryAfter time.Duration
+}
+
+// IngestLimiter sheds metric writes before they wait on a saturated database
+// or write buffer, and holds the service and each run to their rates, so that
+// clients are told to back off rather than left to time out
+type IngestLimiter struct {
+	limits IngestLimits
+	ingest *IngestPool
+	buffer *WriteBuffer
+	now    func() time.Time
+
+	mu        sync.Mutex
+	global    tokenBucket
+	runs      map[uuid.UUID]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewIngestLimiter(limits IngestLimits) *IngestLimiter {
+	if limits.Burst <= 0 {
+		limits.Burst = max(int(math.Ceil(limits.Rate)), 1)
+	}
+	if limits.RunBurst <= 0 {
+		limits.RunBurst = max(int(math.Ceil(limits.RunRate)), 1)
+	}
+	now := time.Now()
+	return &IngestLimiter{
+		limits:    limits,
+		now:       time.Now,
+		global:    tokenBucket{tokens: float64(limits.Burst), last: now},
+		runs:      make(map[uuid.UUID]*tokenBucket),
+		lastSweep: now,
+	}
+}
+
+// UseIngestPool sheds the writes of runs whose ingest queue is past the
+// queue threshold, as the database falls behind
+func (l *IngestLimiter) UseIngestPool(pool *IngestPool) {
+	l.ingest = pool
+}
+
+// UseWriteBuffer sheds writes while the write buffer is past the queue
+// threshold
+func (l *IngestLimiter) UseWriteBuffer(buffer *WriteBuffer) {
+	l.buffer = buffer
+}
+
+// Admit admits a batch of metrics written to runIDs, one for each metric,
+// or returns a BackpressureError. A batch is charged to the rates only when
+// admitted. A batch larger than a burst is admitted once the bucket is full,
+// leaving it owing the rest.
+func (l *IngestLimiter) Admit(runIDs []uuid.UUID) error {
+	if err := l.checkQueues(runIDs); err != nil {
+		return err
+	}
+	if l.limits.Rate <= 0 && l.limits.RunRate <= 0 {
+		return nil
+	}
+
+	counts := make(map[uuid.UUID]int, 1)
+	for _, runID := range runIDs {
+		counts[runID]++
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if l.limits.RunRate > 0 && now.Sub(l.lastSweep) >= runBucketSweep {
+		l.sweep(now)
+	}
+	if l.limits.Rate > 0 {
+		if wait := l.global.wait(float64(len(runIDs)), l.limits.Rate, l.limits.Burst, now); wait > 0 {
+			return &BackpressureError{Reason: "metric ingest rate limit exceeded", RetryAfter: wait}
+		}
+	}
+	if l.limits.RunRate > 0 {
+		for runID, n := range counts {
+			bucket, ok := l.runs[runID]
+			if !ok {
+				bucket = &tokenBucket{tokens: float64(l.limits.RunBurst), last: now}
+				l.runs[runID] = bucket
+			}
+			if wait := bucket.wait(float64(n), l.limits.RunRate, l.limits.RunBurst, now); wait > 0 {
+				return &BackpressureError{Reason: "run ingest rate limit exceeded", RunID: runID, RetryAfter: wait}
+			}
+		}
+		for runID, n := range counts {
+			l.runs[runID].tokens -= float64(n)
+		}
+	}
+	if l.limits.Rate > 0 {
+		l.global.tokens -= float64(len(runIDs))
+	}
+	return nil
+}
+
+// checkQueues sheds writes while the write buffer, or the ingest queue of
+// one of runIDs, is past the queue threshold
+func (l *IngestLimiter) checkQueues(runIDs []uuid.UUID) error {
+	threshold := l.limits.QueueThreshold
+	if threshold <= 0 {
+		return nil
+	}
+	if l.buffer != nil {
+		if queued, capacity := l.buffer.QueueDepth(); float64(queued) >= threshold*float64(capacity) {
+			return &BackpressureError{Reason: "metric write buffer is saturated", RetryAfter: l.limits.RetryAfter}
+		}
+	}
+	if l.ingest != nil {
+		for _, shard := range l.ingest.Shards(runIDs) {
+			if queued, capacity := l.ingest.ShardDepth(shard); float64(queued) >= threshold*float64(capacity) {
+				return &BackpressureError{Reason: fmt.Sprintf("ingest queue %d is saturated", shard), RetryAfter: l.limits.RetryAfter}
+			}
+		}
+	}
+	return nil
+}
+
+// sweep drops the buckets of runs idle long enough to have refilled, which
+// a new bucket would start as anyway
+func (l *IngestLimiter) sweep(now time.Time) {
+	for runID, bucket := range l.runs {
+		bucket.refill(l.limits.RunRate, l.limits.RunBurst, now)
+		if bucket.tokens >= float64(l.limits.RunBurst) {
+			delete(l.runs, runID)
+		}
+	}
+	l.lastSweep = now
+}
+
+// tokenBucket holds up to a burst of tokens, refilled at a rate per second.
+// Tokens may go negative when a batch larger than the burst is admitted.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) refill(rate float64, burst int, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.tokens+elapsed*rate, float64(burst))
+	}
+	b.last = now
+}
+
+// wait refills the bucket and returns how long until n tokens can be taken,
+// or zero when they can now. No more than a burst is ever waited for.
+func (b *tokenBucket) wait(n, rate float64, burst int, now time.Time) time.Duration {
+	b.refill(rate, burst, now)
+	n = min(n, float64(burst))
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / rate * float64(time.Second))
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func repeatRun(runID uuid.UUID, n int) []uuid.UUID {
+	runIDs := make([]uuid.UUID, n)
+	for i := range runIDs {
+		runIDs[i] = runID
+	}
+	return runIDs
+}
+
+func TestIngestLimiterRates(t *testing.T) {
+	l := NewIngestLimiter(IngestLimits{Rate: 100, RunRate: 10})
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	l.global.last = now
+	a, b := uuid.New(), uuid.New()
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		runIDs  []uuid.UUID
+		wantRun uuid.UUID
+		// wantWait is the RetryAfter of a shed batch, zero when admitted
+		wantWait time.Duration
+	}{
+		{"within run burst", 0, repeatRun(a, 10), uuid.Nil, 0},
+		{"run over its rate", 0, repeatRun(a, 5), a, 500 * time.Millisecond},
+		{"other run", 0, repeatRun(b, 10), uuid.Nil, 0},
+		{"run refilled", time.Second, repeatRun(a, 10), uuid.Nil, 0},
+		{"larger than burst once full", 2 * time.Second, repeatRun(b, 30), uuid.Nil, 0},
+		{"owing the rest", time.Second, repeatRun(b, 1), b, 1100 * time.Millisecond},
+		{"other runs", 0, append(repeatRun(uuid.New(), 10), repeatRun(uuid.New(), 10)...), uuid.Nil, 0},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		err := l.Admit(step.runIDs)
+		var backpressureErr *BackpressureError
+		if step.wantWait == 0 {
+			if err != nil {
+				t.Fatalf("%s: Admit() = %v, want admitted", step.name, err)
+			}
+			continue
+		}
+		if !errors.As(err, &backpressureErr) || backpressureErr.RunID != step.wantRun || backpressureErr.RetryAfter != step.wantWait {
+			t.Fatalf("%s: Admit() = %#v, want run %s told to wait %s", step.name, err, step.wantRun, step.wantWait)
+		}
+	}
+
+	// 20 of the 100 metrics of the service are taken in the last second
+	for i := 0; i < 8; i++ {
+		if err := l.Admit(repeatRun(uuid.New(), 10)); err != nil {
+			t.Fatalf("Admit() = %v, want the service burst left", err)
+		}
+	}
+	err := l.Admit(repeatRun(uuid.New(), 10))
+	var backpressureErr *BackpressureError
+	if !errors.As(err, &backpressureErr) || backpressureErr.RunID != uuid.Nil || backpressureErr.RetryAfter != 100*time.Millisecond {
+		t.Fatalf("Admit() = %#v, want the service over its rate", err)
+	}
+}
+
+func TestIngestLimiterSweep(t *testing.T) {
+	l := NewIngestLimiter(IngestLimits{RunRate: 10})
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+
+	a, b := uuid.New(), uuid.New()
+	l.Admit(repeatRun(a, 10))
+	now = now.Add(59 * time.Second)
+	l.Admit(repeatRun(b, 10))
+	now = now.Add(time.Second)
+	l.Admit(repeatRun(b, 1))
+	if _, ok := l.runs[a]; ok {
+		t.Error("bucket of an idle run kept")
+	}
+	if _, ok := l.runs[b]; !ok {
+		t.Error("bucket of a writing run dropped")
+	}
+}
+
+func TestIngestLimiterQueues(t *testing.T) {
+	pool := NewIngestPool(2, 4)
+	runs := runsOnShards(t, pool, 2)
+	l := NewIngestLimiter(IngestLimits{QueueThreshold: 0.75, RetryAfter: 3 * time.Second})
+	l.UseIngestPool(pool)
+
+	shard := pool.Shard(runs[0])
+	for i := 0; i < 3; i++ {
+		pool.shards[shard] <- &ingestTask{ctx: context.Background()}
+	}
+	var backpressureErr *BackpressureError
+	if err := l.Admit([]uuid.UUID{runs[1], runs[0]}); !errors.As(err, &backpressureErr) || backpressureErr.RetryAfter != 3*time.Second {
+		t.Errorf("Admit() to a saturated shard = %v", err)
+	}
+	if err := l.Admit([]uuid.UUID{runs[1]}); err != nil {
+		t.Errorf("Admit() to another shard = %v", err)
+	}
+}
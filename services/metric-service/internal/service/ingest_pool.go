@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+// ErrIngestPoolStopped is returned for writes submitted after the pool shut
+// down
+var ErrIngestPoolStopped = errors.New("ingest pool stopped")
+
+// IngestPool runs writes on a fixed set of shards, each drained by one
+// writer. Writes are sharded by run ID, so the writes of a run happen one at
+// a time and in the order they were submitted, while different runs are
+// written in parallel across shards. Work that reads what a run already
+// wrote, such as counter rates, relies on this.
+//
+// A write spanning runs on several shards is still run once, as one write:
+// it is queued on each of its shards and runs when all of them have reached
+// it, holding them until it is done.
+type IngestPool struct {
+	shards []chan *ingestTask
+
+	mu     sync.RWMutex
+	closed bool
+	// spanning orders writes queued on several shards, so that any two of
+	// them are queued in the same order on every shard they share
+	spanning sync.Mutex
+}
+
+type ingestTask struct {
+	ctx    context.Context
+	write  func(ctx context.Context) error
+	result chan error
+	// waiting counts the shards yet to reach a write queued on several; the
+	// last one runs it while the others wait for done
+	waiting atomic.Int32
+	done    chan struct{}
+}
+
+// NewIngestPool creates a pool of shards writers, each queueing up to
+// queueSize writes before submitters wait
+func NewIngestPool(shards, queueSize int) *IngestPool {
+	p := &IngestPool{shards: make([]chan *ingestTask, shards)}
+	for i := range p.shards {
+		p.shards[i] = make(chan *ingestTask, queueSize)
+	}
+	return p
+}
+
+// Run drains the shards until ctx is done. Writes queued by then are still
+// run before it returns; later ones fail with ErrIngestPoolStopped.
+func (p *IngestPool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, shard := range p.shards {
+		shard := shard
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range shard {
+				task.run()
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	// Submitters hold the read lock while queueing, so no send races the close
+	p.mu.Lock()
+	p.closed = true
+	for _, shard := range p.shards {
+		close(shard)
+	}
+	p.mu.Unlock()
+	wg.Wait()
+}
+
+// Shard returns the shard the writes of a run go to
+func (p *IngestPool) Shard(runID uuid.UUID) int {
+	h := fnv.New32a()
+	h.Write(runID[:])
+	return int(h.Sum32() % uint32(len(p.shards)))
+}
+
+// QueueDepth reports the writes waiting in the shards and how many the
+// shards can hold, with the number of shards that are full
+func (p *IngestPool) QueueDepth() (queued, capacity, full int) {
+	for _, shard := range p.shards {
+		queued += len(shard)
+		capacity += cap(shard)
+		if len(shard) == cap(shard) {
+			full++
+		}
+	}
+	return queued, capacity, full
+}
+
+// ShardDepth reports the writes waiting in a shard and how many it can hold
+func (p *IngestPool) ShardDepth(shard int) (queued, capacity int) {
+	return len(p.shards[shard]), cap(p.shards[shard])
+}
+
+// Shards returns the distinct shards the writes of runIDs go to, in order
+func (p *IngestPool) Shards(runIDs []uuid.UUID) []int {
+	seen := make(map[int]bool, 1)
+	var shards []int
+	for _, runID := range runIDs {
+		shard := p.Shard(runID)
+		if !seen[shard] {
+			seen[shard] = true
+			shards = append(shards, shard)
+		}
+	}
+	sort.Ints(shards)
+	return shards
+}
+
+// submit queues write on shards, returning the channel its result is sent on
+func (p *IngestPool) submit(ctx context.Context, shards []int, write func(ctx context.Context) error) (<-chan error, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return nil, ErrIngestPoolStopped
+	}
+
+	task := &ingestTask{ctx: ctx, write: write, result: make(chan error, 1)}
+	if len(shards) == 1 {
+		select {
+		case p.shards[shards[0]] <- task:
+			return task.result, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	task.waiting.Store(int32(len(shards)))
+	task.done = make(chan struct{})
+	p.spanning.Lock()
+	defer p.spanning.Unlock()
+	select {
+	case p.shards[shards[0]] <- task:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	// Once queued on one shard the write must reach all of them, or that
+	// shard would wait for it forever; if ctx is done by then it is skipped
+	for _, shard := range shards[1:] {
+		p.shards[shard] <- task
+	}
+	return task.result, nil
+}
+
+// wait returns the result of a submitted write. A caller giving up does not
+// withdraw the write: it is skipped if it has not started, as its context
+// is done.
+func wait(ctx context.Context, result <-chan error) error {
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *ingestTask) run() {
+	if t.done != nil {
+		if t.waiting.Add(-1) > 0 {
+			<-t.done
+			return
+		}
+		defer close(t.done)
+	}
+	if err := t.ctx.Err(); err != nil {
+		t.result <- err
+		return
+	}
+	t.result <- t.write(t.ctx)
+}
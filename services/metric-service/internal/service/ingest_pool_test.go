@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func runsOnShards(t *testing.T, p *IngestPool, n int) []uuid.UUID {
+	t.Helper()
+	seen := make(map[int]bool)
+	var runs []uuid.UUID
+	for len(runs) < n {
+		runID := uuid.New()
+		if shard := p.Shard(runID); !seen[shard] {
+			seen[shard] = true
+			runs = append(runs, runID)
+		}
+	}
+	return runs
+}
+
+func TestIngestPoolShard(t *testing.T) {
+	p := NewIngestPool(8, 1)
+	for i := 0; i < 100; i++ {
+		runID := uuid.New()
+		shard := p.Shard(runID)
+		if shard < 0 || shard >= 8 {
+			t.Fatalf("Shard(%s) = %d, want within [0, 8)", runID, shard)
+		}
+		if again := p.Shard(runID); again != shard {
+			t.Fatalf("Shard(%s) = %d then %d, want stable", runID, shard, again)
+		}
+	}
+}
+
+func TestIngestPoolShards(t *testing.T) {
+	p := NewIngestPool(4, 1)
+	runs := runsOnShards(t, p, 3)
+	a, b, c := runs[0], runs[1], runs[2]
+
+	tests := []struct {
+		name   string
+		runIDs []uuid.UUID
+		want   int
+	}{
+		{"one run", []uuid.UUID{a}, 1},
+		{"repeated run", []uuid.UUID{a, a, a}, 1},
+		{"two shards", []uuid.UUID{a, b, a}, 2},
+		{"three shards", []uuid.UUID{c, b, a}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shards := p.Shards(tt.runIDs)
+			if len(shards) != tt.want {
+				t.Fatalf("Shards() = %v, want %d shards", shards, tt.want)
+			}
+			for i := 1; i < len(shards); i++ {
+				if shards[i-1] >= shards[i] {
+					t.Fatalf("Shards() = %v, want ascending and distinct", shards)
+				}
+			}
+		})
+	}
+}
+
+func TestIngestPoolSpanningWriteRunsOnce(t *testing.T) {
+	p := NewIngestPool(4, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	runs := runsOnShards(t, p, 3)
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	// Hold the first shard so the spanning write cannot start before the
+	// write queued ahead of it there
+	release := make(chan struct{})
+	first, err := p.submit(ctx, p.Shards(runs[:1]), func(context.Context) error {
+		<-release
+		return record("first")(ctx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spanning, err := p.submit(ctx, p.Shards(runs), record("spanning"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	for _, result := range []<-chan error{first, spanning} {
+		if err := wait(ctx, result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A write queued after the spanning one on another of its shards runs
+	// after it
+	after, err := p.submit(ctx, p.Shards(runs[2:]), record("after"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx, after); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"first", "spanning", "after"}
+	if len(order) != len(want) {
+		t.Fatalf("writes ran %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("writes ran %v, want %v", order, want)
+		}
+	}
+}
+
+func TestIngestPoolSpanningWritesDoNotDeadlock(t *testing.T) {
+	p := NewIngestPool(4, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	runs := runsOnShards(t, p, 4)
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+	for i := 0; i < 200; i++ {
+		// Alternate the order runs are listed in, and mix in single-shard writes
+		runIDs := []uuid.UUID{runs[i%4], runs[(i+1)%4]}
+		if i%3 == 0 {
+			runIDs = runIDs[:1]
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := p.submit(ctx, p.Shards(runIDs), func(context.Context) error { return nil })
+			if err == nil {
+				err = wait(ctx, result)
+			}
+			errs <- err
+		}()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writes did not finish")
+	}
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIngestPoolStopped(t *testing.T) {
+	p := NewIngestPool(2, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Run(ctx)
+
+	if _, err := p.submit(context.Background(), []int{0}, func(context.Context) error { return nil }); err != ErrIngestPoolStopped {
+		t.Fatalf("submit() after Run returned %v, want ErrIngestPoolStopped", err)
+	}
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/kafka"
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+const (
+	kafkaQueueSize = 1024
+	// kafkaProjectTTL is how long the project of a run is remembered, so
+	// that batches not naming it are routed without a lookup each
+	kafkaProjectTTL = 5 * time.Minute
+	// kafkaMaxProjects bounds the remembered projects
+	kafkaMaxProjects = 100000
+)
+
+// KafkaEgress mirrors persisted batches to Kafka, as they are published to
+// Redis for live streams: one message per run of a batch, holding the same
+// payload, on the topic of the run's project and keyed by run ID, so that
+// consumers read each run in order and can replay it. Batches that do not
+// name the project of a run are routed by the project it is registered in.
+type KafkaEgress struct {
+	producer    *kafka.Producer
+	projects    *ProjectService
+	topicPrefix string
+	logger      *zap.Logger
+	queue       chan []model.Metric
+	// runProjects is only used by Run
+	runProjects map[uuid.UUID]runProject
+}
+
+// runProject is a remembered project of a run, nil for runs in none
+type runProject struct {
+	projectID *uuid.UUID
+	expires   time.Time
+}
+
+func NewKafkaEgress(producer *kafka.Producer, projects *ProjectService, topicPrefix string, logger *zap.Logger) *KafkaEgress {
+	return &KafkaEgress{
+		producer:    producer,
+		projects:    projects,
+		topicPrefix: topicPrefix,
+		logger:      logger,
+		queue:       make(chan []model.Metric, kafkaQueueSize),
+		runProjects: make(map[uuid.UUID]runProject),
+	}
+}
+
+// ObserveMetrics queues a persisted batch without blocking the write path
+func (k *KafkaEgress) ObserveMetrics(ctx context.Context, metrics []model.Metric) {
+	select {
+	case k.queue <- metrics:
+	default:
+		k.logger.Warn("Kafka egress queue full, dropping batch", zap.Int("count", len(metrics)))
+	}
+}
+
+// Run produces queued batches until the context is cancelled
+func (k *KafkaEgress) Run(ctx context.Context) {
+	defer k.producer.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case metrics := <-k.queue:
+			msgs, err := k.messages(ctx, metrics)
+			if err != nil {
+				k.logger.Error("Failed to encode metrics for Kafka", zap.Error(err))
+				continue
+			}
+			if err := k.producer.Produce(ctx, msgs); err != nil {
+				k.logger.Error("Failed to produce metrics to Kafka", zap.Int("count", len(metrics)), zap.Error(err))
+			}
+		}
+	}
+}
+
+// messages groups a batch by run into messages on the topics of the runs'
+// projects. Runs without a project go to the "unassigned" topic.
+func (k *KafkaEgress) messages(ctx context.Context, metrics []model.Metric) ([]kafka.Message, error) {
+	metricsByRun := make(map[uuid.UUID][]model.Metric)
+	var order []uuid.UUID
+	projects := make(map[uuid.UUID]*uuid.UUID)
+	for _, m := range metrics {
+		if _, ok := metricsByRun[m.RunID]; !ok {
+			order = append(order, m.RunID)
+		}
+		metricsByRun[m.RunID] = append(metricsByRun[m.RunID], m)
+		if m.ProjectID != nil {
+			projects[m.RunID] = m.ProjectID
+		}
+	}
+
+	msgs := make([]kafka.Message, 0, len(order))
+	for _, runID := range order {
+		projectID, ok := projects[runID]
+		if ok {
+			k.remember(runID, projectID)
+		} else {
+			projectID = k.projectOf(ctx, runID)
+		}
+		value, err := json.Marshal(model.MetricPayload{Metrics: metricsByRun[runID]})
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, kafka.Message{
+			Topic: k.topic(projectID),
+			Key:   []byte(runID.String()),
+			Value: value,
+		})
+	}
+	return msgs, nil
+}
+
+func (k *KafkaEgress) topic(projectID *uuid.UUID) string {
+	if projectID == nil {
+		return k.topicPrefix + "unassigned"
+	}
+	return k.topicPrefix + projectID.String()
+}
+
+// projectOf returns the project a run is registered in, nil for none. When
+// it cannot be looked up, the last one known is kept.
+func (k *KafkaEgress) projectOf(ctx context.Context, runID uuid.UUID) *uuid.UUID {
+	known, ok := k.runProjects[runID]
+	if ok && time.Now().Before(known.expires) {
+		return known.projectID
+	}
+	run, err := k.projects.GetRunProject(ctx, runID)
+	if err != nil {
+		k.logger.Warn("Failed to look up the project of a run", zap.String("run_id", runID.String()), zap.Error(err))
+		return known.projectID
+	}
+	var projectID *uuid.UUID
+	if run != nil {
+		projectID = &run.ProjectID
+	}
+	k.remember(runID, projectID)
+	return projectID
+}
+
+func (k *KafkaEgress) remember(runID uuid.UUID, projectID *uuid.UUID) {
+	if len(k.runProjects) >= kafkaMaxProjects {
+		now := time.Now()
+		for id, p := range k.runProjects {
+			if now.After(p.expires) {
+				delete(k.runProjects, id)
+			}
+		}
+		if len(k.runProjects) >= kafkaMaxProjects {
+			clear(k.runProjects)
+		}
+	}
+	k.runProjects[runID] = runProject{projectID: projectID, expires: time.Now().Add(kafkaProjectTTL)}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestKafkaEgressMessages(t *testing.T) {
+	projectID := uuid.New()
+	known := uuid.New()
+	runA, runB, runC := uuid.New(), uuid.New(), uuid.New()
+	k := NewKafkaEgress(nil, nil, "m.", zap.NewNop())
+	// Runs whose batches do not name a project are routed by the one known
+	k.remember(runB, &known)
+	k.remember(runC, nil)
+
+	msgs, err := k.messages(context.Background(), []model.Metric{
+		{RunID: runA, MetricName: "loss"},
+		{RunID: runB, MetricName: "loss"},
+		{RunID: runA, MetricName: "acc", ProjectID: &projectID},
+		{RunID: runC, MetricName: "loss"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		topic   string
+		key     uuid.UUID
+		metrics int
+	}{
+		{"m." + projectID.String(), runA, 2},
+		{"m." + known.String(), runB, 1},
+		{"m.unassigned", runC, 1},
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("%d messages, want %d", len(msgs), len(want))
+	}
+	for i, w := range want {
+		if msgs[i].Topic != w.topic || string(msgs[i].Key) != w.key.String() {
+			t.Errorf("message %d to %s keyed %s, want %s keyed %s", i, msgs[i].Topic, msgs[i].Key, w.topic, w.key)
+		}
+		var payload model.MetricPayload
+		if err := json.Unmarshal(msgs[i].Value, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if len(payload.Metrics) != w.metrics {
+			t.Errorf("message %d holds %d metrics, want %d", i, len(payload.Metrics), w.metrics)
+		}
+	}
+	if p := k.runProjects[runA].projectID; p == nil || *p != projectID {
+		t.Errorf("project of a run named by its batch not remembered")
+	}
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/delta"
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/parquet"
+	"github.com/wanllmdb/metric-service/internal/storage"
+)
+
+// LakehouseExportOptions configure the export of metrics to a Delta Lake
+// table in object storage
+type LakehouseExportOptions struct {
+	// Prefix is the key of the table root
+	Prefix string
+	// Delay holds back the export of a day until it has been over this
+	// long, so that late writes make it into the table
+	Delay time.Duration
+	// MaxDays bounds the days one run exports; a table catching up on
+	// history grows over several runs
+	MaxDays int
+}
+
+// lakehousePartitions are the partition columns of the exported table
+var lakehousePartitions = []string{"project_id", "date"}
+
+// lakehouseFields are the columns of the exported table: those of the
+// Parquet files, then the partition columns
+var lakehouseFields = []delta.Field{
+	{Name: "time", Type: "timestamp"},
+	{Name: "run_id", Type: "string"},
+	{Name: "metric_name", Type: "string"},
+	{Name: "step", Type: "long", Nullable: true},
+	{Name: "value", Type: "double", Nullable: true},
+	{Name: "node_id", Type: "string"},
+	{Name: "rank", Type: "integer", Nullable: true},
+	{Name: "text_value", Type: "string", Nullable: true},
+	{Name: "project_id", Type: "string", Nullable: true},
+	{Name: "date", Type: "date"},
+}
+
+// exportedUntilParam is the commit parameter recording the end of the days
+// exported so far, from which an export resumes
+const exportedUntilParam = "wanllmdbExportedUntil"
+
+// lakehouseState is the progress of an export: the last version of the
+// table's log, -1 before the table exists, and the end of the exported days.
+// It is kept next to the log so that runs need not read the whole log, which
+// stays the authority: commits the state missed are read back from it.
+type lakehouseState struct {
+	Version       int64     `json:"version"`
+	ExportedUntil time.Time `json:"exported_until"`
+}
+
+func lakehouseStateKey(prefix string) string {
+	return path.Join(prefix, "_wanllmdb_export.json")
+}
+
+// ExportLakehouse appends the metrics of each day over since the last export
+// to a Delta Lake table, partitioned by the project of their run and their
+// UTC date. Every day is one commit, so an export interrupted halfway resumes
+// at the first day missing from the table. Metrics written after their day
+// was exported, and deletions, are not reflected in the table.
+func (s *MaintenanceService) ExportLakehouse(ctx context.Context, opts LakehouseExportOptions) (*model.LakehouseExport, error) {
+	state, err := s.lakehouseState(ctx, opts.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	if state.ExportedUntil.IsZero() {
+		first, err := s.repo.FirstMetricTime(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			return &model.LakehouseExport{Table: opts.Prefix, Version: state.Version}, nil
+		}
+		state.ExportedUntil = first.UTC().Truncate(24 * time.Hour)
+	}
+
+	export := &model.LakehouseExport{Table: opts.Prefix, Version: state.Version, ExportedUntil: state.ExportedUntil}
+	for export.Days < opts.MaxDays {
+		day := state.ExportedUntil
+		end := day.Add(24 * time.Hour)
+		if time.Now().Before(end.Add(opts.Delay)) {
+			break
+		}
+
+		adds, rows, err := s.exportDay(ctx, opts.Prefix, day)
+		if err != nil {
+			return export, fmt.Errorf("failed to export %s: %w", day.Format(time.DateOnly), err)
+		}
+		if err := s.commitDay(ctx, opts.Prefix, state.Version+1, end, adds); err != nil {
+			return export, err
+		}
+		state = lakehouseState{Version: state.Version + 1, ExportedUntil: end}
+		if err := s.saveLakehouseState(ctx, opts.Prefix, state); err != nil {
+			// The next run reads the commit back from the log
+			s.logger.Warn("Failed to save lakehouse export state", zap.Error(err))
+		}
+
+		export.Version, export.ExportedUntil = state.Version, state.ExportedUntil
+		export.Days++
+		export.Files += len(adds)
+		export.Rows += rows
+	}
+	return export, nil
+}
+
+// exportDay writes the metrics of a day as a Parquet file per project
+func (s *MaintenanceService) exportDay(ctx context.Context, prefix string, day time.Time) ([]delta.Action, int64, error) {
+	date := day.Format(time.DateOnly)
+	var adds []delta.Action
+	var file *lakehouseFile
+	finish := func() error {
+		add, err := file.Close()
+		if err == nil {
+			adds = append(adds, delta.Action{Add: add})
+		}
+		file = nil
+		return err
+	}
+
+	rows, err := s.repo.StreamMetricsBetween(ctx, day, day.Add(24*time.Hour), func(m model.Metric) error {
+		if file != nil && !sameProject(file.project, m.ProjectID) {
+			if err := finish(); err != nil {
+				return err
+			}
+		}
+		if file == nil {
+			file = s.openLakehouseFile(ctx, prefix, m.ProjectID, date)
+		}
+		return file.Write(m)
+	})
+	if file != nil {
+		if err != nil {
+			file.Abort(err)
+		} else {
+			err = finish()
+		}
+	}
+	return adds, rows, err
+}
+
+// commitDay writes the commit of a day's files, with the protocol and
+// metadata of the table in the first one
+func (s *MaintenanceService) commitDay(ctx context.Context, prefix string, version int64, end time.Time, adds []delta.Action) error {
+	now := time.Now().UnixMilli()
+	var actions []delta.Action
+	if version == 0 {
+		actions = append(actions,
+			delta.Action{Protocol: &delta.Protocol{MinReaderVersion: delta.MinReaderVersion, MinWriterVersion: delta.MinWriterVersion}},
+			delta.Action{MetaData: &delta.Metadata{
+				ID:               uuid.NewString(),
+				Format:           delta.Format{Provider: "parquet", Options: map[string]string{}},
+				SchemaString:     delta.SchemaString(lakehouseFields),
+				PartitionColumns: lakehousePartitions,
+				Configuration:    map[string]string{},
+				CreatedTime:      now,
+			}},
+		)
+	}
+	actions = append(actions, adds...)
+	actions = append(actions, delta.Action{CommitInfo: &delta.CommitInfo{
+		Timestamp: now,
+		Operation: "WRITE",
+		OperationParameters: map[string]string{
+			"mode":             "Append",
+			"partitionBy":      `["project_id","date"]`,
+			exportedUntilParam: end.Format(time.RFC3339),
+		},
+		EngineInfo: "wanllmdb-metric-service",
+	}})
+
+	data, err := delta.EncodeCommit(actions)
+	if err != nil {
+		return err
+	}
+	key := path.Join(prefix, delta.LogKey(version))
+	if err := s.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+		return fmt.Errorf("failed to commit version %d of %s: %w", version, prefix, err)
+	}
+	return nil
+}
+
+// lakehouseState reads the progress of the export to a table, catching up
+// with commits made after the state was last saved
+func (s *MaintenanceService) lakehouseState(ctx context.Context, prefix string) (lakehouseState, error) {
+	state := lakehouseState{Version: -1}
+	if data, err := s.readObject(ctx, lakehouseStateKey(prefix)); err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return state, fmt.Errorf("invalid lakehouse export state: %w", err)
+		}
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return state, err
+	}
+
+	for {
+		data, err := s.readObject(ctx, path.Join(prefix, delta.LogKey(state.Version+1)))
+		if errors.Is(err, storage.ErrNotFound) {
+			return state, nil
+		}
+		if err != nil {
+			return state, err
+		}
+		actions, err := delta.DecodeCommit(data)
+		if err != nil {
+			return state, err
+		}
+		state.Version++
+		for _, a := range actions {
+			if a.CommitInfo == nil {
+				continue
+			}
+			if until, err := time.Parse(time.RFC3339, a.CommitInfo.OperationParameters[exportedUntilParam]); err == nil {
+				state.ExportedUntil = until
+			}
+		}
+	}
+}
+
+func (s *MaintenanceService) saveLakehouseState(ctx context.Context, prefix string, state lakehouseState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(ctx, lakehouseStateKey(prefix), bytes.NewReader(data), int64(len(data)), "application/json")
+}
+
+func (s *MaintenanceService) readObject(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// LakehouseExportJob exports the days due to the lakehouse table as a
+// scheduled job
+func (s *MaintenanceService) LakehouseExportJob(opts LakehouseExportOptions, interval time.Duration) Job {
+	return Job{
+		Name:     "lakehouse-export",
+		Interval: interval,
+		Run: func(ctx context.Context) (string, error) {
+			export, err := s.ExportLakehouse(ctx, opts)
+			switch {
+			case export == nil:
+				return "", err
+			case export.ExportedUntil.IsZero():
+				return "no metrics to export", err
+			}
+			return fmt.Sprintf("exported %d days in %d files (%d rows), up to %s",
+				export.Days, export.Files, export.Rows, export.ExportedUntil.Format(time.DateOnly)), err
+		},
+	}
+}
+
+// lakehouseFile is a Parquet file of the table being uploaded as it is written
+type lakehouseFile struct {
+	project *uuid.UUID
+	key     string
+	add     *delta.Add
+	pw      *io.PipeWriter
+	writer  *parquet.Writer
+	rows    int64
+	size    int64
+	done    chan error
+}
+
+func (s *MaintenanceService) openLakehouseFile(ctx context.Context, prefix string, project *uuid.UUID, date string) *lakehouseFile {
+	values := map[string]*string{"project_id": nil, "date": &date}
+	if project != nil {
+		id := project.String()
+		values["project_id"] = &id
+	}
+	rel := delta.PartitionDir(lakehousePartitions, values) + fmt.Sprintf("part-%s.parquet", uuid.NewString())
+
+	pr, pw := io.Pipe()
+	f := &lakehouseFile{
+		project: project,
+		key:     path.Join(prefix, rel),
+		add:     &delta.Add{Path: delta.EscapePath(rel), PartitionValues: values, DataChange: true},
+		pw:      pw,
+		done:    make(chan error, 1),
+	}
+	go func() {
+		// The size of the file is not known up front
+		err := s.store.Put(ctx, f.key, pr, -1, "application/vnd.apache.parquet")
+		pr.CloseWithError(err)
+		f.done <- err
+	}()
+	f.writer = parquet.NewWriter(countingWriter{w: pw, n: &f.size})
+	return f
+}
+
+func (f *lakehouseFile) Write(m model.Metric) error {
+	f.rows++
+	return f.writer.Write(m)
+}
+
+// Close finishes the file and returns its Add action once uploaded
+func (f *lakehouseFile) Close() (*delta.Add, error) {
+	err := f.writer.Close()
+	f.pw.CloseWithError(err)
+	if putErr := <-f.done; err == nil {
+		err = putErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.add.Size = f.size
+	f.add.ModificationTime = time.Now().UnixMilli()
+	f.add.Stats = fmt.Sprintf(`{"numRecords":%d}`, f.rows)
+	return f.add, nil
+}
+
+// Abort stops the upload of the file
+func (f *lakehouseFile) Abort(err error) {
+	f.pw.CloseWithError(err)
+	<-f.done
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+func sameProject(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
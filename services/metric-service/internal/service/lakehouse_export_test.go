@@ -0,0 +1,95 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/delta"
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/storage"
+)
+
+func TestLakehouseState(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewMaintenanceService(nil, nil, store, zap.NewNop())
+	ctx := context.Background()
+	day := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	state, err := s.lakehouseState(ctx, "lake")
+	if err != nil || state.Version != -1 || !state.ExportedUntil.IsZero() {
+		t.Fatalf("state of a new table = %+v, %v; want version -1", state, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.commitDay(ctx, "lake", int64(i), day.Add(time.Duration(i+1)*24*time.Hour), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.saveLakehouseState(ctx, "lake", lakehouseState{Version: 0, ExportedUntil: day.Add(24 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The saved state missed the second commit, which is read back from the log
+	state, err = s.lakehouseState(ctx, "lake")
+	if err != nil || state.Version != 1 || !state.ExportedUntil.Equal(day.Add(48*time.Hour)) {
+		t.Errorf("state = %+v, %v; want version 1 exported until %s", state, err, day.Add(48*time.Hour))
+	}
+
+	data, err := s.readObject(ctx, path.Join("lake", delta.LogKey(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions, err := delta.DecodeCommit(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 3 || actions[0].Protocol == nil || actions[1].MetaData == nil ||
+		len(actions[1].MetaData.PartitionColumns) != 2 {
+		t.Errorf("first commit = %s, want the protocol, metadata and commit info", data)
+	}
+	if data, _ := s.readObject(ctx, path.Join("lake", delta.LogKey(1))); bytes.Contains(data, []byte("metaData")) {
+		t.Errorf("second commit repeats the table metadata: %s", data)
+	}
+}
+
+func TestLakehouseFile(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewMaintenanceService(nil, nil, store, zap.NewNop())
+	ctx := context.Background()
+	project := uuid.New()
+
+	f := s.openLakehouseFile(ctx, "lake", &project, "2026-01-02")
+	for i := 0; i < 3; i++ {
+		if err := f.Write(model.Metric{Time: time.Now(), RunID: uuid.New(), MetricName: "loss", Value: float64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add, err := f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDir := "project_id=" + project.String() + "/date=2026-01-02/"
+	if !strings.HasPrefix(add.Path, wantDir) || add.Stats != `{"numRecords":3}` || *add.PartitionValues["date"] != "2026-01-02" {
+		t.Errorf("add = %+v", add)
+	}
+	data, err := s.readObject(ctx, path.Join("lake", add.Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)) != add.Size || !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Errorf("file of %d bytes, add records %d", len(data), add.Size)
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// leaderboardRefreshInterval bounds how long another replica's new objective
+// can go unnoticed by ingest
+const leaderboardRefreshInterval = 30 * time.Second
+
+// LeaderboardService maintains per-project leaderboards as metrics arrive.
+// Only batches carrying a metric some leaderboard ranks by reach the database.
+type LeaderboardService struct {
+	repo   *repository.LeaderboardRepository
+	logger *zap.Logger
+
+	mu         sync.RWMutex
+	objectives map[string]struct{}
+}
+
+func NewLeaderboardService(repo *repository.LeaderboardRepository, logger *zap.Logger) *LeaderboardService {
+	return &LeaderboardService{
+		repo:       repo,
+		logger:     logger,
+		objectives: make(map[string]struct{}),
+	}
+}
+
+// Run keeps the in-memory set of objectives in sync until the context is cancelled
+func (s *LeaderboardService) Run(ctx context.Context) {
+	ticker := time.NewTicker(leaderboardRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				s.logger.Error("Failed to refresh leaderboard objectives", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Refresh reloads the metric names leaderboards rank by
+func (s *LeaderboardService) Refresh(ctx context.Context) error {
+	names, err := s.repo.ListObjectiveNames(ctx)
+	if err != nil {
+		return err
+	}
+
+	objectives := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		objectives[name] = struct{}{}
+	}
+
+	s.mu.Lock()
+	s.objectives = objectives
+	s.mu.Unlock()
+	return nil
+}
+
+// ObserveMetrics merges the objective values of a persisted batch into the
+// leaderboards of the runs' projects
+func (s *LeaderboardService) ObserveMetrics(ctx context.Context, metrics []model.Metric) {
+	var relevant []model.Metric
+	s.mu.RLock()
+	for _, m := range metrics {
+		if _, ok := s.objectives[m.MetricName]; ok {
+			relevant = append(relevant, m)
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(relevant) == 0 {
+		return
+	}
+
+	// Each leaderboard has one goal, so only the summary under its goal applies
+	summaries := summarizeBatch(relevant, func(string) string { return model.GoalMin })
+	summaries = append(summaries, summarizeBatch(relevant, func(string) string { return model.GoalMax })...)
+
+	if err := s.repo.UpdateEntries(ctx, summaries); err != nil {
+		s.logger.Error("Failed to update leaderboards", zap.Error(err))
+	}
+}
+
+// SetLeaderboard configures the objective of a project and ranks its runs
+// from their history, returning the number of runs ranked
+func (s *LeaderboardService) SetLeaderboard(ctx context.Context, projectID uuid.UUID, req model.SetLeaderboardRequest) (*model.LeaderboardConfig, int64, error) {
+	if projectID == uuid.Nil {
+		return nil, 0, &ValidationError{Message: "project_id is required"}
+	}
+
+	cfg := &model.LeaderboardConfig{
+		ProjectID:  projectID,
+		MetricName: req.MetricName,
+		Goal:       req.Goal,
+		Mode:       req.Mode,
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = model.LeaderboardModeBest
+	}
+
+	// Track the objective before backfilling so no batch in between is missed
+	s.mu.Lock()
+	s.objectives[cfg.MetricName] = struct{}{}
+	s.mu.Unlock()
+
+	ranked, err := s.repo.SetLeaderboard(ctx, cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	return cfg, ranked, nil
+}
+
+// GetLeaderboard retrieves the objective of a project
+func (s *LeaderboardService) GetLeaderboard(ctx context.Context, projectID uuid.UUID) (*model.LeaderboardConfig, error) {
+	return s.repo.GetLeaderboard(ctx, projectID)
+}
+
+// DeleteLeaderboard removes the leaderboard of a project
+func (s *LeaderboardService) DeleteLeaderboard(ctx context.Context, projectID uuid.UUID) (bool, error) {
+	deleted, err := s.repo.DeleteLeaderboard(ctx, projectID)
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.Refresh(ctx); err != nil {
+		s.logger.Error("Failed to refresh leaderboard objectives", zap.Error(err))
+	}
+	return deleted, nil
+}
+
+// ListEntries returns one page of a project's leaderboard and the number of
+// runs on it. The config is nil when the project has no leaderboard.
+func (s *LeaderboardService) ListEntries(ctx context.Context, projectID uuid.UUID, params model.LeaderboardQueryParams) (*model.LeaderboardConfig, []model.LeaderboardEntry, int64, error) {
+	var experimentID *uuid.UUID
+	if params.ExperimentID != "" {
+		id, err := uuid.Parse(params.ExperimentID)
+		if err != nil {
+			return nil, nil, 0, &ValidationError{Message: "invalid experiment_id"}
+		}
+		experimentID = &id
+	}
+
+	cfg, err := s.repo.GetLeaderboard(ctx, projectID)
+	if err != nil || cfg == nil {
+		return nil, nil, 0, err
+	}
+
+	entries, total, err := s.repo.ListEntries(ctx, cfg, experimentID, params)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return cfg, entries, total, nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// maxLogLineBytes truncates pathological lines such as progress bars without newlines
+const maxLogLineBytes = 64 * 1024
+
+// LogService captures console output from runs and fans it out to tailing clients
+type LogService struct {
+	repo   *repository.LogRepository
+	redis  *redis.Client
+	logger *zap.Logger
+}
+
+func NewLogService(repo *repository.LogRepository, redis *redis.Client, logger *zap.Logger) *LogService {
+	return &LogService{
+		repo:   repo,
+		redis:  redis,
+		logger: logger,
+	}
+}
+
+// WriteLogs stores log lines for a run and publishes them to live tails
+func (s *LogService) WriteLogs(ctx context.Context, runID uuid.UUID, lines []model.LogLine) error {
+	now := time.Now()
+	for i := range lines {
+		l := &lines[i]
+		l.RunID = runID
+		if l.Time.IsZero() {
+			l.Time = now
+		}
+		l.Level = strings.ToLower(l.Level)
+		if l.Level == "" {
+			l.Level = model.LogLevelInfo
+		} else if l.Level == "warn" {
+			l.Level = model.LogLevelWarning
+		}
+		if LogLevelsFrom(l.Level) == nil {
+			return &ValidationError{Message: fmt.Sprintf("line %d: unknown level %q", i, l.Level)}
+		}
+		if len(l.Line) > maxLogLineBytes {
+			l.Line = strings.ToValidUTF8(l.Line[:maxLogLineBytes], "")
+		}
+	}
+
+	if err := s.repo.BatchWrite(ctx, lines); err != nil {
+		return err
+	}
+
+	// Lines are already stored; a failed publish only affects live tails
+	data, err := json.Marshal(model.LogPayload{Lines: lines})
+	if err == nil {
+		err = s.redis.Publish(ctx, logChannel(runID), data).Err()
+	}
+	if err != nil {
+		s.logger.Warn("Failed to publish log lines", zap.Error(err))
+	}
+
+	return nil
+}
+
+// GetRunLogs retrieves log lines of a run
+func (s *LogService) GetRunLogs(ctx context.Context, runID uuid.UUID, params model.LogQueryParams) ([]model.LogLine, error) {
+	return s.repo.GetRunLogs(ctx, runID, params, LogLevelsFrom(params.MinLevel))
+}
+
+// GetLatestLogs retrieves the last lines of a run at or above a level
+func (s *LogService) GetLatestLogs(ctx context.Context, runID uuid.UUID, limit int, minLevel string) ([]model.LogLine, error) {
+	return s.repo.GetLatestLogs(ctx, runID, limit, LogLevelsFrom(minLevel))
+}
+
+// SubscribeToLogs subscribes to the live log lines of a run
+func (s *LogService) SubscribeToLogs(ctx context.Context, runID uuid.UUID) *redis.PubSub {
+	return s.redis.Subscribe(ctx, logChannel(runID))
+}
+
+// LogLevelsFrom returns the levels at or above minLevel, or nil for an empty
+// or unknown level
+func LogLevelsFrom(minLevel string) []string {
+	for i, level := range model.LogLevels {
+		if level == minLevel {
+			return model.LogLevels[i:]
+		}
+	}
+	return nil
+}
+
+func logChannel(runID uuid.UUID) string {
+	return "logs:" + runID.String()
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+	"github.com/wanllmdb/metric-service/internal/storage"
+)
+
+// MaintenanceService implements the data lifecycle tasks run by operators
+// through the admin CLI
+type MaintenanceService struct {
+	repo      *repository.MaintenanceRepository
+	summaries *SummaryService
+	store     storage.ObjectStore
+	logger    *zap.Logger
+}
+
+func NewMaintenanceService(repo *repository.MaintenanceRepository, summaries *SummaryService, store storage.ObjectStore, logger *zap.Logger) *MaintenanceService {
+	return &MaintenanceService{
+		repo:      repo,
+		summaries: summaries,
+		store:     store,
+		logger:    logger,
+	}
+}
+
+// EnforceRetention drops the chunks of each hypertable older than its
+// retention period. Tables with a zero period are kept in full.
+func (s *MaintenanceService) EnforceRetention(ctx context.Context, periods map[string]time.Duration, dryRun bool) ([]model.RetentionResult, error) {
+	var results []model.RetentionResult
+	for _, table := range repository.RetentionTables {
+		period := periods[table]
+		if period <= 0 {
+			continue
+		}
+
+		chunks, err := s.repo.DropChunks(ctx, table, period, dryRun)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, model.RetentionResult{Table: table, OlderThan: period, Chunks: chunks, DryRun: dryRun})
+	}
+	return results, nil
+}
+
+// ArchiveKey is where the metrics of an archived run are stored
+func ArchiveKey(runID uuid.UUID) string {
+	return fmt.Sprintf("archives/runs/%s/metrics.jsonl.gz", runID)
+}
+
+// ArchiveRun exports every metric of a run to object storage as gzipped JSON
+// lines and, with deleteAfter, removes them from the database once stored
+func (s *MaintenanceService) ArchiveRun(ctx context.Context, runID uuid.UUID, deleteAfter bool) (*model.RunArchive, error) {
+	archive := &model.RunArchive{RunID: runID, Key: ArchiveKey(runID)}
+
+	pr, pw := io.Pipe()
+	go func() {
+		buf := bufio.NewWriter(pw)
+		gz := gzip.NewWriter(buf)
+		enc := json.NewEncoder(gz)
+
+		count, err := s.repo.StreamRunMetrics(ctx, runID, func(m model.Metric) error {
+			return enc.Encode(m)
+		})
+		if err == nil {
+			err = gz.Close()
+		}
+		if err == nil {
+			err = buf.Flush()
+		}
+		archive.MetricCount = count
+		pw.CloseWithError(err)
+	}()
+
+	// The size of the compressed stream is not known up front
+	if err := s.store.Put(ctx, archive.Key, pr, -1, "application/gzip"); err != nil {
+		pr.CloseWithError(err)
+		return nil, fmt.Errorf("failed to archive run %s: %w", runID, err)
+	}
+
+	if deleteAfter {
+		deleted, err := s.repo.DeleteRunMetrics(ctx, runID)
+		if err != nil {
+			return archive, err
+		}
+		archive.DeletedRows = deleted
+	}
+	return archive, nil
+}
+
+// RecomputeSummaries rebuilds the summaries of the given runs from their
+// history, or of every summarized run when none are given. It returns the
+// number of runs recomputed.
+func (s *MaintenanceService) RecomputeSummaries(ctx context.Context, runIDs []uuid.UUID) (int, error) {
+	if len(runIDs) == 0 {
+		var err error
+		if runIDs, err = s.repo.ListSummarizedRuns(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	for i, runID := range runIDs {
+		if _, err := s.summaries.RecomputeRunSummary(ctx, runID); err != nil {
+			return i, err
+		}
+	}
+	return len(runIDs), nil
+}
+
+// RetentionJob enforces the retention periods as a scheduled job
+func (s *MaintenanceService) RetentionJob(periods map[string]time.Duration, interval time.Duration) Job {
+	return Job{
+		Name:     "retention",
+		Interval: interval,
+		Run: func(ctx context.Context) (string, error) {
+			results, err := s.EnforceRetention(ctx, periods, false)
+			dropped := 0
+			for _, r := range results {
+				dropped += len(r.Chunks)
+			}
+			return fmt.Sprintf("dropped %d chunks from %d tables", dropped, len(results)), err
+		},
+	}
+}
+
+// SummaryRecomputeJob rebuilds every run summary as a scheduled job
+func (s *MaintenanceService) SummaryRecomputeJob(interval time.Duration) Job {
+	return Job{
+		Name:     "summary-recompute",
+		Interval: interval,
+		Run: func(ctx context.Context) (string, error) {
+			count, err := s.RecomputeSummaries(ctx, nil)
+			return fmt.Sprintf("recomputed %d run summaries", count), err
+		},
+	}
+}
+
+// ApplySchema applies an idempotent schema script
+func (s *MaintenanceService) ApplySchema(ctx context.Context, script string) error {
+	return s.repo.ApplySchema(ctx, script)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+	"github.com/wanllmdb/metric-service/internal/storage"
+)
+
+// MediaService stores media samples in object storage and their metadata in Postgres
+type MediaService struct {
+	repo         *repository.MediaRepository
+	store        storage.ObjectStore
+	signedURLTTL time.Duration
+	logger       *zap.Logger
+}
+
+func NewMediaService(repo *repository.MediaRepository, store storage.ObjectStore, logger *zap.Logger) *MediaService {
+	return &MediaService{
+		repo:   repo,
+		store:  store,
+		logger: logger,
+	}
+}
+
+// UseSignedURLs lets downloads go to the object store directly through URLs
+// signed for ttl, when the store can sign them; zero turns this off
+func (s *MediaService) UseSignedURLs(ttl time.Duration) {
+	s.signedURLTTL = ttl
+}
+
+// UploadMedia stores a media file and records it against the run and step
+func (s *MediaService) UploadMedia(ctx context.Context, runID uuid.UUID, req model.MediaUploadRequest, contentType string, body io.Reader, size int64) (*model.MediaItem, error) {
+	item := &model.MediaItem{
+		ID:          uuid.New(),
+		RunID:       runID,
+		Step:        req.Step,
+		Key:         req.Key,
+		Kind:        req.Kind,
+		ContentType: contentType,
+		SizeBytes:   size,
+		Caption:     req.Caption,
+	}
+	item.ObjectKey = fmt.Sprintf("media/%s/%s", runID, item.ID)
+
+	if req.Metadata != "" {
+		if err := json.Unmarshal([]byte(req.Metadata), &item.Metadata); err != nil {
+			return nil, &ValidationError{Message: "metadata must be a JSON object"}
+		}
+	}
+
+	hash := sha256.New()
+	if err := s.store.Put(ctx, item.ObjectKey, io.TeeReader(body, hash), size, contentType); err != nil {
+		return nil, err
+	}
+	item.Digest = "sha256:" + hex.EncodeToString(hash.Sum(nil))
+
+	if err := s.repo.CreateMedia(ctx, item); err != nil {
+		// Don't leave an orphaned object behind
+		if delErr := s.store.Delete(ctx, item.ObjectKey); delErr != nil {
+			s.logger.Warn("Failed to remove orphaned media object",
+				zap.String("object_key", item.ObjectKey),
+				zap.Error(delErr))
+		}
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// GetRunMedia lists the media items of a run
+func (s *MediaService) GetRunMedia(ctx context.Context, runID uuid.UUID, params model.MediaQueryParams) ([]model.MediaItem, error) {
+	return s.repo.GetRunMedia(ctx, runID, params)
+}
+
+// OpenMedia returns a media item with a reader over its content; both are nil
+// when the item does not exist
+func (s *MediaService) OpenMedia(ctx context.Context, runID, mediaID uuid.UUID) (*model.MediaItem, io.ReadCloser, error) {
+	item, err := s.repo.GetMedia(ctx, runID, mediaID)
+	if err != nil || item == nil {
+		return nil, nil, err
+	}
+
+	content, err := s.store.Get(ctx, item.ObjectKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read media %s: %w", item.ID, err)
+	}
+	return item, content, nil
+}
+
+// MediaURL returns a media item with a signed URL its content can be fetched
+// from directly. The URL is empty when signed URLs are off or the store
+// cannot sign them; the item is nil when it does not exist.
+func (s *MediaService) MediaURL(ctx context.Context, runID, mediaID uuid.UUID) (*model.MediaItem, string, error) {
+	signer, ok := s.store.(storage.URLSigner)
+	if !ok || s.signedURLTTL <= 0 {
+		return nil, "", nil
+	}
+	item, err := s.repo.GetMedia(ctx, runID, mediaID)
+	if err != nil || item == nil {
+		return nil, "", err
+	}
+	url, err := signer.SignedURL(http.MethodGet, item.ObjectKey, s.signedURLTTL)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to sign media URL %s: %w", item.ID, err)
+	}
+	return item, url, nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// CompareMetric aligns a metric of several runs by step, on the steps any of
+// them logged, from the first in range up to the request's limit. Repeated
+// runs are compared once, in the order they are first given.
+func (s *MetricService) CompareMetric(ctx context.Context, req model.MetricCompareRequest) (*model.MetricComparison, error) {
+	if req.MinStep != nil && req.MaxStep != nil && *req.MinStep > *req.MaxStep {
+		return nil, &ValidationError{Message: "min_step must not be greater than max_step"}
+	}
+	if req.Limit == 0 {
+		req.Limit = model.DefaultMetricQueryLimit
+	}
+	seen := make(map[uuid.UUID]bool, len(req.RunIDs))
+	runIDs := req.RunIDs[:0:0]
+	for _, runID := range req.RunIDs {
+		if !seen[runID] {
+			seen[runID] = true
+			runIDs = append(runIDs, runID)
+		}
+	}
+	req.RunIDs = runIDs
+
+	// One step past the limit tells whether more follow
+	values, err := s.repo.GetStepValuesForRuns(ctx, req, req.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	return alignByStep(req.MetricName, runIDs, values, req.Limit), nil
+}
+
+// alignByStep lays out values, ordered by step, as one series per run over
+// their first limit steps
+func alignByStep(metricName string, runIDs []uuid.UUID, values []model.RunStepValue, limit int) *model.MetricComparison {
+	comparison := &model.MetricComparison{
+		MetricName: metricName,
+		Steps:      []int64{},
+		Series:     make([]model.ComparisonSeries, len(runIDs)),
+	}
+	index := make(map[uuid.UUID]int, len(runIDs))
+	for i, runID := range runIDs {
+		index[runID] = i
+		comparison.Series[i] = model.ComparisonSeries{RunID: runID, Values: []*float64{}}
+	}
+
+	for _, v := range values {
+		if len(comparison.Steps) == 0 || comparison.Steps[len(comparison.Steps)-1] != v.Step {
+			if len(comparison.Steps) == limit {
+				next := v.Step
+				comparison.NextMinStep = &next
+				break
+			}
+			comparison.Steps = append(comparison.Steps, v.Step)
+			for i := range comparison.Series {
+				comparison.Series[i].Values = append(comparison.Series[i].Values, nil)
+			}
+		}
+		if i, ok := index[v.RunID]; ok {
+			comparison.Series[i].Values[len(comparison.Steps)-1] = v.Value
+		}
+	}
+	return comparison
+}
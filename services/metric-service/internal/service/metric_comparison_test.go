@@ -0,0 +1,55 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestAlignByStep(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	value := func(v float64) *float64 { return &v }
+	values := []model.RunStepValue{
+		{RunID: a, Step: 0, Value: value(2)},
+		{RunID: b, Step: 0, Value: value(3)},
+		{RunID: b, Step: 10, Value: value(1.5)},
+		// A step with only non-finite values
+		{RunID: a, Step: 20, Value: nil},
+		{RunID: a, Step: 30, Value: value(1)},
+	}
+
+	tests := []struct {
+		name     string
+		limit    int
+		wantA    []*float64
+		wantB    []*float64
+		wantNext *int64
+	}{
+		{"every step", 10, []*float64{value(2), nil, nil, value(1)}, []*float64{value(3), value(1.5), nil, nil}, nil},
+		{"limited", 2, []*float64{value(2), nil}, []*float64{value(3), value(1.5)}, func() *int64 { n := int64(20); return &n }()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := alignByStep("loss", []uuid.UUID{a, b}, values, tt.limit)
+			if len(got.Steps) != len(tt.wantA) {
+				t.Fatalf("steps = %v, want %d", got.Steps, len(tt.wantA))
+			}
+			for i, want := range [][]*float64{tt.wantA, tt.wantB} {
+				series := got.Series[i]
+				if len(series.Values) != len(want) {
+					t.Fatalf("series %d = %v, want %d values", i, series.Values, len(want))
+				}
+				for j := range want {
+					if (series.Values[j] == nil) != (want[j] == nil) || (want[j] != nil && *series.Values[j] != *want[j]) {
+						t.Errorf("series %d value at step %d = %v, want %v", i, got.Steps[j], series.Values[j], want[j])
+					}
+				}
+			}
+			if (got.NextMinStep == nil) != (tt.wantNext == nil) || (tt.wantNext != nil && *got.NextMinStep != *tt.wantNext) {
+				t.Errorf("next_min_step = %v, want %v", got.NextMinStep, tt.wantNext)
+			}
+		})
+	}
+}
@@ -3,64 +3,432 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
+	"github.com/wanllmdb/metric-service/internal/bufpool"
 	"github.com/wanllmdb/metric-service/internal/model"
 	"github.com/wanllmdb/metric-service/internal/repository"
 )
 
+// defaultCounterNames are the final name segments of metrics treated as
+// cumulative counters when no definition says otherwise
+var defaultCounterNames = []string{"tokens", "samples", "num_tokens", "num_samples", "tokens_seen", "samples_seen"}
+
+// MetricObserver is notified after a metric batch has been persisted. The
+// call happens on the request path, so slow work should be queued.
+type MetricObserver interface {
+	ObserveMetrics(ctx context.Context, metrics []model.Metric)
+}
+
+// SystemMetricObserver is notified after a system metric batch has been
+// persisted, on the request path
+type SystemMetricObserver interface {
+	ObserveSystemMetrics(ctx context.Context, metrics []model.SystemMetric)
+}
+
+// MetricDeletionObserver is implemented by observers whose state derived
+// from a run's values must be rebuilt when some of them are deleted
+type MetricDeletionObserver interface {
+	ObserveDeletion(ctx context.Context, deletion *model.MetricDeletion)
+}
+
 type MetricService struct {
-	repo   *repository.MetricRepository
-	redis  *redis.Client
-	logger *zap.Logger
+	repo        *repository.MetricRepository
+	definitions *DefinitionService
+	redis       *redis.Client
+	logger      *zap.Logger
+	observers   []MetricObserver
+	systemObs   []SystemMetricObserver
+	ingest      *IngestPool
+	buffer      *WriteBuffer
+	cache       CachePolicy
+	nonFinite   model.NonFinitePolicy
+	events      *CloudEventPublisher
 }
 
-func NewMetricService(repo *repository.MetricRepository, redis *redis.Client, logger *zap.Logger) *MetricService {
+func NewMetricService(repo *repository.MetricRepository, definitions *DefinitionService, redis *redis.Client, logger *zap.Logger) *MetricService {
 	return &MetricService{
-		repo:   repo,
-		redis:  redis,
-		logger: logger,
+		repo:        repo,
+		definitions: definitions,
+		redis:       redis,
+		logger:      logger,
+		cache:       DefaultCachePolicy,
+		nonFinite:   model.NonFiniteNull,
 	}
 }
 
+// RegisterObserver adds an observer that receives every persisted batch
+func (s *MetricService) RegisterObserver(observer MetricObserver) {
+	s.observers = append(s.observers, observer)
+}
+
+// RegisterSystemObserver adds an observer that receives every persisted
+// system metric batch
+func (s *MetricService) RegisterSystemObserver(observer SystemMetricObserver) {
+	s.systemObs = append(s.systemObs, observer)
+}
+
+// UseIngestPool routes batch writes through pool, serializing the writes of
+// each run. Without a pool batches are written on the caller's goroutine.
+func (s *MetricService) UseIngestPool(pool *IngestPool) {
+	s.ingest = pool
+}
+
+// UseWriteBuffer has EnqueueWithOptions queue batches on buffer rather than
+// write them
+func (s *MetricService) UseWriteBuffer(buffer *WriteBuffer) {
+	s.buffer = buffer
+}
+
+// UseCloudEvents publishes metric deletions to events
+func (s *MetricService) UseCloudEvents(events *CloudEventPublisher) {
+	s.events = events
+}
+
+// UseCachePolicy sets how long query results are cached
+func (s *MetricService) UseCachePolicy(policy CachePolicy) {
+	s.cache = policy
+}
+
+// UseNonFinitePolicy sets what ingest does with NaN and infinite values
+func (s *MetricService) UseNonFinitePolicy(policy model.NonFinitePolicy) {
+	s.nonFinite = policy
+}
+
 // BatchWrite writes metrics and publishes to Redis for WebSocket streaming
 func (s *MetricService) BatchWrite(ctx context.Context, metrics []model.Metric) error {
+	return s.BatchWriteWithOptions(ctx, metrics, model.IngestOptions{})
+}
+
+// BatchWriteWithOptions writes metrics, reducing per-rank values as the
+// options and metric definitions configure, and publishes the resulting
+// series to Redis for WebSocket streaming
+func (s *MetricService) BatchWriteWithOptions(ctx context.Context, metrics []model.Metric, opts model.IngestOptions) error {
 	// Validate metrics
 	if err := s.validateMetrics(metrics); err != nil {
 		return err
 	}
+	return s.write(ctx, metrics, opts)
+}
+
+// BatchWritePartial writes the valid metrics of a batch rather than
+// rejecting it whole, returning the items that were not written, in order:
+// those invalid, and those the database rejected. A batch the database
+// rejects a value of is split in halves until the values it rejects are
+// found alone, unless values of the batch are reduced across ranks, which
+// only reduce together. Failures not owed to a value fail the write as
+// BatchWriteWithOptions does.
+func (s *MetricService) BatchWritePartial(ctx context.Context, metrics []model.Metric, opts model.IngestOptions) ([]ItemError, error) {
+	var items []ItemError
+	if err := s.validateMetrics(metrics); err != nil {
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			return nil, err
+		}
+		items = validationErr.Items
+	}
+	invalid := make(map[int]bool, len(items))
+	for _, item := range items {
+		invalid[item.Index] = true
+	}
+	var valid []int
+	for i := range metrics {
+		if !invalid[i] {
+			valid = append(valid, i)
+		}
+	}
 
-	// Write to database
-	if err := s.repo.BatchWrite(ctx, metrics); err != nil {
+	rejected, err := s.writeIsolating(ctx, metrics, valid, opts)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, rejected...)
+	sort.SliceStable(items, func(a, b int) bool { return items[a].Index < items[b].Index })
+	return items, nil
+}
+
+// writeIsolating writes the metrics at indexes, splitting them on values the
+// database rejects, and returns the rejected ones
+func (s *MetricService) writeIsolating(ctx context.Context, metrics []model.Metric, indexes []int, opts model.IngestOptions) ([]ItemError, error) {
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+	batch := make([]model.Metric, len(indexes))
+	for j, i := range indexes {
+		batch[j] = metrics[i]
+	}
+	err := s.write(ctx, batch, opts)
+	if err == nil {
+		return nil, nil
+	}
+	reason, ok := repository.RejectedValue(err)
+	if !ok || len(s.rankReductions(batch, opts)) > 0 {
+		return nil, err
+	}
+	if len(indexes) == 1 {
+		return []ItemError{{Index: indexes[0], Reason: "rejected by the database: " + reason}}, nil
+	}
+
+	mid := len(indexes) / 2
+	left, err := s.writeIsolating(ctx, metrics, indexes[:mid], opts)
+	if err != nil {
+		return nil, err
+	}
+	right, err := s.writeIsolating(ctx, metrics, indexes[mid:], opts)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// EnqueueWithOptions validates metrics and queues them on the write buffer,
+// reporting whether they were queued. Without a buffer they are written as
+// BatchWriteWithOptions does.
+func (s *MetricService) EnqueueWithOptions(ctx context.Context, metrics []model.Metric, opts model.IngestOptions) (bool, error) {
+	if s.buffer == nil {
+		return false, s.BatchWriteWithOptions(ctx, metrics, opts)
+	}
+	if err := s.validateMetrics(metrics); err != nil {
+		return false, err
+	}
+	if err := s.buffer.enqueue(ctx, metrics, opts); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// write writes a validated batch through the ingest pool, if any
+func (s *MetricService) write(ctx context.Context, metrics []model.Metric, opts model.IngestOptions) error {
+	if s.ingest == nil || len(metrics) == 0 {
+		return s.writeBatch(ctx, metrics, opts)
+	}
+
+	// The batch is written as a whole, holding the shard of every run in it
+	runIDs := make([]uuid.UUID, len(metrics))
+	for i, m := range metrics {
+		runIDs[i] = m.RunID
+	}
+	result, err := s.ingest.submit(ctx, s.ingest.Shards(runIDs), func(ctx context.Context) error {
+		return s.writeBatch(ctx, metrics, opts)
+	})
+	if err != nil {
+		return err
+	}
+	return wait(ctx, result)
+}
+
+// writeBuffered writes a batch flushed from the write buffer. The buffer's
+// last flush comes as the service shuts down, once the ingest pool may have
+// stopped; the batch is then written directly.
+func (s *MetricService) writeBuffered(ctx context.Context, metrics []model.Metric, opts model.IngestOptions) error {
+	if err := s.write(ctx, metrics, opts); !errors.Is(err, ErrIngestPoolStopped) {
+		return err
+	}
+	return s.writeBatch(ctx, metrics, opts)
+}
+
+// writeBatch writes a validated batch, derives its counter rates and
+// notifies subscribers and observers
+func (s *MetricService) writeBatch(ctx context.Context, metrics []model.Metric, opts model.IngestOptions) error {
+	// Write to database. Observers and subscribers see the canonical value of
+	// reduced metrics rather than each rank's.
+	written := metrics
+	// Raw values kept per rank, which are written under their own names
+	var rankSeries []model.Metric
+	if reductions := s.rankReductions(metrics, opts); len(reductions) > 0 {
+		canonical, err := s.repo.BatchWriteRanked(ctx, metrics, reductions)
+		if err != nil {
+			return fmt.Errorf("failed to write metrics: %w", err)
+		}
+
+		// Canonical values carry the project of the values they were reduced from
+		projects := make(map[uuid.UUID]model.Metric)
+		for _, m := range metrics {
+			if m.ProjectID != nil {
+				projects[m.RunID] = m
+			}
+		}
+		for i := range canonical {
+			if m, ok := projects[canonical[i].RunID]; ok {
+				canonical[i].ProjectID, canonical[i].ExperimentID = m.ProjectID, m.ExperimentID
+			}
+		}
+
+		written = canonical
+		for _, m := range metrics {
+			if reduction, ok := reductions[m.MetricName]; !ok || m.Rank == nil || m.Step == nil || !m.IsNumeric() {
+				written = append(written, m)
+			} else if reduction.KeepRanks {
+				rankSeries = append(rankSeries, model.Metric{RunID: m.RunID, MetricName: model.RankMetricName(m.MetricName, *m.Rank)})
+			}
+		}
+	} else if err := s.repo.BatchWrite(ctx, metrics); err != nil {
 		return fmt.Errorf("failed to write metrics: %w", err)
 	}
 
+	// Throughput derived from cumulative counters is best effort and never fails the batch
+	if rates, err := s.deriveRates(ctx, written); err != nil {
+		s.logger.Error("Failed to derive counter rates", zap.Error(err))
+	} else if len(rates) > 0 {
+		if err := s.repo.BatchWrite(ctx, rates); err != nil {
+			s.logger.Error("Failed to write counter rates", zap.Error(err))
+		} else {
+			written = append(written, rates...)
+		}
+	}
+
 	// Publish to Redis for real-time streaming
-	if err := s.publishMetrics(ctx, metrics); err != nil {
+	if err := s.publishMetrics(ctx, written); err != nil {
 		s.logger.Error("Failed to publish metrics to Redis", zap.Error(err))
 		// Don't return error, as write succeeded
 	}
 
-	// Invalidate cache
-	s.invalidateCache(ctx, metrics)
+	// Supersede the cached results of the batch's runs
+	s.recordWrites(ctx, append(rankSeries, written...))
+
+	for _, observer := range s.observers {
+		observer.ObserveMetrics(ctx, written)
+	}
 
 	return nil
 }
 
+// rankReductions resolves how each metric in the batch is reduced across
+// ranks. The batch options override the metric definitions; metrics missing
+// from the result, and values sent without a rank or step or that are not
+// numbers, are written as sent.
+func (s *MetricService) rankReductions(metrics []model.Metric, opts model.IngestOptions) map[string]model.RankReduction {
+	reductions := make(map[string]model.RankReduction)
+	for _, m := range metrics {
+		if m.Rank == nil || m.Step == nil || !m.IsNumeric() {
+			continue
+		}
+		if _, ok := reductions[m.MetricName]; ok {
+			continue
+		}
+
+		reduction := model.RankReduction{Reduce: opts.RankReduce}
+		if def, ok := s.definitions.Lookup(projectOf(m), m.MetricName); ok {
+			if reduction.Reduce == "" {
+				reduction.Reduce = def.RankReduce
+			}
+			reduction.KeepRanks = def.KeepRankValues
+		}
+		if opts.KeepRanks != nil {
+			reduction.KeepRanks = *opts.KeepRanks
+		}
+		if reduction.Reduce != "" {
+			reductions[m.MetricName] = reduction
+		}
+	}
+	return reductions
+}
+
+// isCounter reports whether a metric is a cumulative counter: marked so by its
+// definition, or otherwise named like one of defaultCounterNames
+func (s *MetricService) isCounter(m model.Metric) bool {
+	if def, ok := s.definitions.Lookup(projectOf(m), m.MetricName); ok {
+		return def.Cumulative
+	}
+	leaf := m.MetricName[strings.LastIndex(m.MetricName, "/")+1:]
+	for _, name := range defaultCounterNames {
+		if leaf == name {
+			return true
+		}
+	}
+	return false
+}
+
+// deriveRates computes the per-second rate of each cumulative counter in a
+// persisted batch from consecutive values, starting from the value logged
+// before the batch. Counter resets and values sent with a rank yield no rate.
+func (s *MetricService) deriveRates(ctx context.Context, metrics []model.Metric) ([]model.Metric, error) {
+	index := make(map[seriesKey]int)
+	var counters [][]model.Metric
+	for _, m := range metrics {
+		if m.Rank != nil || !m.IsNumeric() || math.IsNaN(m.Value) || math.IsInf(m.Value, 0) || !s.isCounter(m) {
+			continue
+		}
+		key := seriesKey{runID: m.RunID, metricName: m.MetricName}
+		i, ok := index[key]
+		if !ok {
+			i = len(counters)
+			index[key] = i
+			counters = append(counters, nil)
+		}
+		counters[i] = append(counters[i], m)
+	}
+	if len(counters) == 0 {
+		return nil, nil
+	}
+
+	firsts := make([]model.Metric, len(counters))
+	for i, values := range counters {
+		sort.SliceStable(values, func(a, b int) bool { return values[a].Time.Before(values[b].Time) })
+		firsts[i] = values[0]
+	}
+
+	previous, err := s.repo.GetLatestBefore(ctx, firsts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []model.Metric
+	for i, values := range counters {
+		prev := previous[i]
+		for j := range values {
+			m := values[j]
+			if prev != nil {
+				elapsed := m.Time.Sub(prev.Time).Seconds()
+				if delta := m.Value - prev.Value; elapsed > 0 && delta >= 0 {
+					rates = append(rates, model.Metric{
+						Time:         m.Time,
+						RunID:        m.RunID,
+						MetricName:   model.RateMetricName(m.MetricName),
+						Step:         m.Step,
+						Value:        delta / elapsed,
+						NodeID:       m.NodeID,
+						Metadata:     map[string]interface{}{"derived_from": m.MetricName},
+						ProjectID:    m.ProjectID,
+						ExperimentID: m.ExperimentID,
+					})
+				}
+			}
+			prev = &m
+		}
+	}
+	return rates, nil
+}
+
 // BatchWriteSystemMetrics writes system metrics
 func (s *MetricService) BatchWriteSystemMetrics(ctx context.Context, metrics []model.SystemMetric) error {
-	return s.repo.BatchWriteSystemMetrics(ctx, metrics)
+	if err := s.repo.BatchWriteSystemMetrics(ctx, metrics); err != nil {
+		return err
+	}
+	for _, observer := range s.systemObs {
+		observer.ObserveSystemMetrics(ctx, metrics)
+	}
+	return nil
 }
 
 // GetRunMetrics retrieves metrics with caching
 func (s *MetricService) GetRunMetrics(ctx context.Context, runID uuid.UUID, params model.MetricQueryParams) ([]model.Metric, error) {
 	// Try cache first
-	cacheKey := s.getRunMetricsCacheKey(runID, params)
+	version, ttl, cacheable := s.cacheVersion(ctx, runID)
+	if !cacheable {
+		return s.repo.GetRunMetrics(ctx, runID, params)
+	}
+	cacheKey := s.getRunMetricsCacheKey(runID, version, params)
 	if cached, err := s.getFromCache(ctx, cacheKey); err == nil && cached != nil {
 		var metrics []model.Metric
 		if err := json.Unmarshal(cached, &metrics); err == nil {
@@ -77,12 +445,71 @@ func (s *MetricService) GetRunMetrics(ctx context.Context, runID uuid.UUID, para
 
 	// Cache the result
 	if data, err := json.Marshal(metrics); err == nil {
-		s.setCache(ctx, cacheKey, data, 5*time.Minute)
+		s.setCache(ctx, cacheKey, data, ttl)
 	}
 
 	return metrics, nil
 }
 
+// CountRunMetrics counts the metrics of a query regardless of its limit and
+// cursor, with caching
+func (s *MetricService) CountRunMetrics(ctx context.Context, runID uuid.UUID, params model.MetricQueryParams) (int64, error) {
+	if params.MetricName != "" && !s.hasMetric(ctx, runID, params.MetricName) {
+		return 0, nil
+	}
+
+	version, ttl, cacheable := s.cacheVersion(ctx, runID)
+	if !cacheable {
+		return s.repo.CountRunMetrics(ctx, runID, params)
+	}
+	cacheKey := fmt.Sprintf("metrics:run:%s:%d:count:%s", runID.String(), version, countCacheKey(params))
+	if count, err := s.redis.Get(ctx, cacheKey).Int64(); err == nil {
+		return count, nil
+	}
+
+	count, err := s.repo.CountRunMetrics(ctx, runID, params)
+	if err != nil {
+		return 0, err
+	}
+	s.setCache(ctx, cacheKey, []byte(strconv.FormatInt(count, 10)), ttl)
+	return count, nil
+}
+
+// countCacheKey identifies the filters of a count query
+func countCacheKey(params model.MetricQueryParams) string {
+	var b strings.Builder
+	for _, t := range []*time.Time{params.StartTime, params.EndTime} {
+		if t != nil {
+			b.WriteString(strconv.FormatInt(t.UnixNano(), 10))
+		}
+		b.WriteByte(':')
+	}
+	for _, step := range []*int64{params.MinStep, params.MaxStep} {
+		if step != nil {
+			b.WriteString(strconv.FormatInt(*step, 10))
+		}
+		b.WriteByte(':')
+	}
+	b.WriteString(params.MetricName)
+	if len(params.MetricNames) > 0 {
+		b.WriteByte(':')
+		b.WriteString(strings.Join(params.MetricNames, ","))
+	}
+	if params.MetricNamePattern != "" {
+		b.WriteByte(':')
+		b.WriteString(params.PatternType)
+		b.WriteByte(':')
+		b.WriteString(params.MetricNamePattern)
+	}
+	return b.String()
+}
+
+// StreamRunMetrics passes the metrics of a query to fn as they are read.
+// Streamed queries are too large to cache and bypass it.
+func (s *MetricService) StreamRunMetrics(ctx context.Context, runID uuid.UUID, params model.MetricQueryParams, fn func(model.Metric) error) error {
+	return s.repo.StreamRunMetrics(ctx, runID, params, fn)
+}
+
 // GetMetricHistory retrieves metric history
 func (s *MetricService) GetMetricHistory(ctx context.Context, runID uuid.UUID, metricName string, params model.MetricQueryParams) ([]model.Metric, error) {
 	return s.repo.GetMetricHistory(ctx, runID, metricName, params)
@@ -90,7 +517,15 @@ func (s *MetricService) GetMetricHistory(ctx context.Context, runID uuid.UUID, m
 
 // GetLatestMetric retrieves the latest metric value with caching
 func (s *MetricService) GetLatestMetric(ctx context.Context, runID uuid.UUID, metricName string) (*model.Metric, error) {
-	cacheKey := fmt.Sprintf("metric:latest:%s:%s", runID.String(), metricName)
+	if !s.hasMetric(ctx, runID, metricName) {
+		return nil, nil
+	}
+
+	version, ttl, cacheable := s.cacheVersion(ctx, runID)
+	if !cacheable {
+		return s.repo.GetLatestMetric(ctx, runID, metricName)
+	}
+	cacheKey := fmt.Sprintf("metric:latest:%s:%d:%s", runID.String(), version, metricName)
 
 	if cached, err := s.getFromCache(ctx, cacheKey); err == nil && cached != nil {
 		var metric model.Metric
@@ -106,16 +541,45 @@ func (s *MetricService) GetLatestMetric(ctx context.Context, runID uuid.UUID, me
 
 	if metric != nil {
 		if data, err := json.Marshal(metric); err == nil {
-			s.setCache(ctx, cacheKey, data, 1*time.Minute)
+			s.setCache(ctx, cacheKey, data, ttl)
 		}
 	}
 
 	return metric, nil
 }
 
+// GetActiveLatestMetrics returns the latest value of each of the named
+// metrics in every run that logged it within activeWithin
+func (s *MetricService) GetActiveLatestMetrics(ctx context.Context, metricNames []string, activeWithin time.Duration) ([]model.Metric, error) {
+	return s.repo.ListActiveLatestMetrics(ctx, metricNames, time.Now().Add(-activeWithin))
+}
+
+// StreamRollups passes the hourly rollups of the runs' metrics within a time
+// range to fn, ordered by run, metric and bucket
+func (s *MetricService) StreamRollups(ctx context.Context, runIDs []uuid.UUID, params model.RollupQueryParams, fn func(model.MetricRollup) error) error {
+	if len(runIDs) == 0 {
+		return &ValidationError{Message: "run_ids must name at least one run"}
+	}
+	if len(runIDs) > model.MaxRollupRuns {
+		return &ValidationError{Message: fmt.Sprintf("run_ids may name at most %d runs", model.MaxRollupRuns)}
+	}
+	if !params.EndTime.After(*params.StartTime) {
+		return &ValidationError{Message: "end_time must be after start_time"}
+	}
+	return s.repo.StreamRollups(ctx, runIDs, params.MetricName, *params.StartTime, *params.EndTime, fn)
+}
+
 // GetMetricStats retrieves metric statistics
 func (s *MetricService) GetMetricStats(ctx context.Context, runID uuid.UUID, metricName string) (*model.MetricStats, error) {
-	cacheKey := fmt.Sprintf("metric:stats:%s:%s", runID.String(), metricName)
+	if !s.hasMetric(ctx, runID, metricName) {
+		return nil, nil
+	}
+
+	version, ttl, cacheable := s.cacheVersion(ctx, runID)
+	if !cacheable {
+		return s.repo.GetMetricStats(ctx, runID, metricName)
+	}
+	cacheKey := fmt.Sprintf("metric:stats:%s:%d:%s", runID.String(), version, metricName)
 
 	if cached, err := s.getFromCache(ctx, cacheKey); err == nil && cached != nil {
 		var stats model.MetricStats
@@ -131,33 +595,227 @@ func (s *MetricService) GetMetricStats(ctx context.Context, runID uuid.UUID, met
 
 	if stats != nil {
 		if data, err := json.Marshal(stats); err == nil {
-			s.setCache(ctx, cacheKey, data, 5*time.Minute)
+			s.setCache(ctx, cacheKey, data, ttl)
 		}
 	}
 
 	return stats, nil
 }
 
+// GetMetricBuckets aggregates the values of a metric per time bucket of the
+// given width, or returns nil for a metric the run has not logged
+func (s *MetricService) GetMetricBuckets(ctx context.Context, runID uuid.UUID, metricName string, bucket time.Duration, params model.MetricBucketParams) ([]model.AggregatePoint, error) {
+	if params.StartTime != nil && params.EndTime != nil && !params.EndTime.After(*params.StartTime) {
+		return nil, &ValidationError{Message: "end_time must be after start_time"}
+	}
+	if params.MinStep != nil && params.MaxStep != nil && *params.MinStep > *params.MaxStep {
+		return nil, &ValidationError{Message: "min_step must not be greater than max_step"}
+	}
+	if !s.hasMetric(ctx, runID, metricName) {
+		return nil, nil
+	}
+	return s.repo.GetMetricBuckets(ctx, runID, metricName, bucket, params)
+}
+
+// DeleteMetricRange deletes the values of a metric of a run at the steps
+// params bound, or all of them without bounds, and records the deletion for
+// audit. Cached results of the run are superseded and observers that
+// implement MetricDeletionObserver are told.
+func (s *MetricService) DeleteMetricRange(ctx context.Context, runID uuid.UUID, metricName string, params model.DeleteMetricParams) (*model.MetricDeletion, error) {
+	if params.MinStep != nil && params.MaxStep != nil && *params.MinStep > *params.MaxStep {
+		return nil, &ValidationError{Message: "min_step must not exceed max_step"}
+	}
+
+	deletion := &model.MetricDeletion{
+		ID:         uuid.New(),
+		RunID:      runID,
+		MetricName: metricName,
+		MinStep:    params.MinStep,
+		MaxStep:    params.MaxStep,
+		Reason:     params.Reason,
+	}
+	if err := s.repo.DeleteMetricRange(ctx, deletion); err != nil {
+		return nil, err
+	}
+	if deletion.DeletedRows == 0 {
+		return deletion, nil
+	}
+
+	// A new last write supersedes the cached results; the name set is
+	// dropped to be rebuilt, as the metric may be gone
+	pipe := s.redis.Pipeline()
+	pipe.Set(ctx, lastWriteKey(runID), time.Now().UnixNano(), lastWriteRetention)
+	pipe.Del(ctx, metricNamesKey(runID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Error("Failed to invalidate cached results of run", zap.String("run_id", runID.String()), zap.Error(err))
+	}
+
+	for _, observer := range s.observers {
+		if o, ok := observer.(MetricDeletionObserver); ok {
+			o.ObserveDeletion(ctx, deletion)
+		}
+	}
+	if s.events != nil {
+		s.events.Publish(model.CloudEventMetricsDeleted, runID, deletion.DeletedAt, deletion)
+	}
+	return deletion, nil
+}
+
+// GetMetricDeletions retrieves the audit records of a run's metric deletions, latest first
+func (s *MetricService) GetMetricDeletions(ctx context.Context, runID uuid.UUID) ([]model.MetricDeletion, error) {
+	return s.repo.GetMetricDeletions(ctx, runID)
+}
+
+// GetMetricTree nests the metrics of a run by namespace, splitting their
+// names at model.MetricNamespaceSeparator
+func (s *MetricService) GetMetricTree(ctx context.Context, runID uuid.UUID) ([]*model.MetricTreeNode, error) {
+	names, err := s.repo.ListMetricNameCounts(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	return model.BuildMetricTree(names), nil
+}
+
+// ListMetricNames lists the metrics of a run with their number of values,
+// by name
+func (s *MetricService) ListMetricNames(ctx context.Context, runID uuid.UUID) ([]model.MetricNameCount, error) {
+	return s.repo.ListMetricNameCounts(ctx, runID)
+}
+
+// ListMetricNameSummaries lists the metrics of a run with their number of
+// values, step range and latest value, by name
+func (s *MetricService) ListMetricNameSummaries(ctx context.Context, runID uuid.UUID) ([]model.MetricNameSummary, error) {
+	return s.repo.ListMetricNameSummaries(ctx, runID)
+}
+
+// GetMetricStatsBatch retrieves the statistics of several metrics, in the
+// order of metricNames. Metrics not logged are left out. Cached statistics
+// are shared with GetMetricStats; the rest are queried together.
+func (s *MetricService) GetMetricStatsBatch(ctx context.Context, runID uuid.UUID, metricNames []string) ([]model.MetricStats, error) {
+	names := make([]string, 0, len(metricNames))
+	seen := make(map[string]bool, len(metricNames))
+	for _, name := range metricNames {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	version, ttl, cacheable := s.cacheVersion(ctx, runID)
+	cacheKey := func(name string) string {
+		return fmt.Sprintf("metric:stats:%s:%d:%s", runID.String(), version, name)
+	}
+
+	found := make(map[string]model.MetricStats, len(names))
+	missing := names
+	if cacheable {
+		keys := make([]string, len(names))
+		for i, name := range names {
+			keys[i] = cacheKey(name)
+		}
+		if cached, err := s.redis.MGet(ctx, keys...).Result(); err == nil {
+			missing = missing[:0:0]
+			for i, value := range cached {
+				var stats model.MetricStats
+				if str, ok := value.(string); ok && json.Unmarshal([]byte(str), &stats) == nil {
+					found[names[i]] = stats
+					continue
+				}
+				missing = append(missing, names[i])
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		stats, err := s.repo.GetMetricStatsForNames(ctx, runID, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range stats {
+			found[st.MetricName] = st
+			if !cacheable {
+				continue
+			}
+			if data, err := json.Marshal(st); err == nil {
+				s.setCache(ctx, cacheKey(st.MetricName), data, ttl)
+			}
+		}
+	}
+
+	result := make([]model.MetricStats, 0, len(found))
+	for _, name := range names {
+		if st, ok := found[name]; ok {
+			result = append(result, st)
+		}
+	}
+	return result, nil
+}
+
 // GetSystemMetrics retrieves system metrics
-func (s *MetricService) GetSystemMetrics(ctx context.Context, runID uuid.UUID, startTime, endTime *time.Time, limit int) ([]model.SystemMetric, error) {
-	return s.repo.GetSystemMetrics(ctx, runID, startTime, endTime, limit)
+func (s *MetricService) GetSystemMetrics(ctx context.Context, runID uuid.UUID, params model.SystemMetricQueryParams) ([]model.SystemMetric, error) {
+	return s.repo.GetSystemMetrics(ctx, runID, params)
 }
 
 // Helper methods
 
 func (s *MetricService) validateMetrics(metrics []model.Metric) error {
+	var items []ItemError
+	invalid := func(i int, field, reason string, args ...interface{}) {
+		items = append(items, ItemError{Index: i, Field: field, Reason: fmt.Sprintf(reason, args...)})
+	}
 	for i, m := range metrics {
+		if m.Time.IsZero() {
+			metrics[i].Time = time.Now()
+		}
 		if m.RunID == uuid.Nil {
-			return fmt.Errorf("metric %d: run_id is required", i)
+			invalid(i, "run_id", "run_id is required")
 		}
 		if m.MetricName == "" {
-			return fmt.Errorf("metric %d: metric_name is required", i)
+			invalid(i, "metric_name", "metric_name is required")
 		}
-		if m.Time.IsZero() {
-			metrics[i].Time = time.Now()
+		if m.Rank != nil && *m.Rank < 0 {
+			invalid(i, "rank", "rank must be non-negative")
+		}
+		switch m.ValueType {
+		case model.ValueTypeNumber:
+			metrics[i].ValueType = ""
+		case "":
+		case model.ValueTypeString, model.ValueTypeBool:
+			if m.Text == nil {
+				invalid(i, "value", "%s: value is required for value_type %s", m.MetricName, m.ValueType)
+			}
+			metrics[i].Value = 0
+			continue
+		default:
+			invalid(i, "value_type", "%s: value_type must be number, string or bool", m.MetricName)
+			continue
+		}
+		if name := model.NonFiniteName(m.Value); name != "" {
+			switch {
+			case s.nonFinite == model.NonFiniteReject:
+				invalid(i, "value", "%s: value is %s", m.MetricName, name)
+				continue
+			case s.nonFinite == model.NonFiniteClamp && math.IsInf(m.Value, 1):
+				metrics[i].Value = math.MaxFloat64
+			case s.nonFinite == model.NonFiniteClamp && math.IsInf(m.Value, -1):
+				metrics[i].Value = -math.MaxFloat64
+			}
+			m = metrics[i]
+		}
+		if err := s.definitions.CheckRange(projectOf(m), m.MetricName, m.Value); err != nil {
+			invalid(i, "value", "%s: %v", m.MetricName, err)
 		}
 	}
-	return nil
+	return NewBatchValidationError("metric", items)
+}
+
+// projectOf returns the project a metric was logged under, or the nil
+// project whose definitions apply everywhere
+func projectOf(m model.Metric) uuid.UUID {
+	if m.ProjectID != nil {
+		return *m.ProjectID
+	}
+	return uuid.Nil
 }
 
 func (s *MetricService) publishMetrics(ctx context.Context, metrics []model.Metric) error {
@@ -167,12 +825,19 @@ func (s *MetricService) publishMetrics(ctx context.Context, metrics []model.Metr
 		metricsByRun[m.RunID] = append(metricsByRun[m.RunID], m)
 	}
 
+	// Payloads are done with once published
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+	enc := json.NewEncoder(buf)
+
 	for runID, runMetrics := range metricsByRun {
-		payload := model.MetricPayload{Metrics: runMetrics}
-		data, err := json.Marshal(payload)
-		if err != nil {
+		buf.Reset()
+		if err := enc.Encode(model.MetricPayload{Metrics: runMetrics}); err != nil {
 			return err
 		}
+		// Publish has sent the payload when it returns, so the buffer can be
+		// reused; the encoder's trailing newline is left out
+		data := buf.Bytes()[:buf.Len()-1]
 
 		channel := fmt.Sprintf("metrics:%s", runID.String())
 		if err := s.redis.Publish(ctx, channel, data).Err(); err != nil {
@@ -183,20 +848,142 @@ func (s *MetricService) publishMetrics(ctx context.Context, metrics []model.Metr
 	return nil
 }
 
-func (s *MetricService) invalidateCache(ctx context.Context, metrics []model.Metric) {
+// lastWriteRetention bounds how long the last write of a run is kept. Runs
+// not written for longer have no known last write and count as finished.
+const lastWriteRetention = 30 * 24 * time.Hour
+
+func lastWriteKey(runID uuid.UUID) string {
+	return "metric:last_write:" + runID.String()
+}
+
+// metricNamesKey holds the names of the metrics a run has logged, as a set
+// which is complete once it holds completeNames. Writes add to it; a set
+// without the marker, for a run written before the set existed or one whose
+// set expired, is completed from the database on first use.
+func metricNamesKey(runID uuid.UUID) string {
+	return "metric:names:" + runID.String()
+}
+
+// completeNames marks a name set as complete; metric names are never empty
+const completeNames = ""
+
+// recordWrites stores the time of a written batch as the last write of its
+// runs, which moves their cached results to new keys, and adds the batch's
+// metrics to the name sets of their runs
+func (s *MetricService) recordWrites(ctx context.Context, metrics []model.Metric) {
+	now := time.Now().UnixNano()
+	names := make(map[uuid.UUID][]interface{})
 	for _, m := range metrics {
-		// Invalidate latest metric cache
-		cacheKey := fmt.Sprintf("metric:latest:%s:%s", m.RunID.String(), m.MetricName)
-		s.redis.Del(ctx, cacheKey)
+		names[m.RunID] = append(names[m.RunID], m.MetricName)
+	}
+
+	pipe := s.redis.Pipeline()
+	for runID, runNames := range names {
+		pipe.Set(ctx, lastWriteKey(runID), now, lastWriteRetention)
+		pipe.SAdd(ctx, metricNamesKey(runID), runNames...)
+		pipe.Expire(ctx, metricNamesKey(runID), lastWriteRetention)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Error("Failed to record writes of runs; cached results may be stale", zap.Error(err))
+
+		// A name set missing a written name would hide the metric, so the sets
+		// are dropped to be completed from the database again
+		keys := make([]string, 0, len(names))
+		for runID := range names {
+			keys = append(keys, metricNamesKey(runID))
+		}
+		if err := s.redis.Del(ctx, keys...).Err(); err != nil {
+			s.logger.Error("Failed to drop metric name sets", zap.Error(err))
+		}
+	}
+}
+
+// hasMetric reports whether a run has logged a metric, so that polling for
+// metrics not logged yet does not query the database. It answers true when
+// the name set cannot be used, leaving the query to decide.
+func (s *MetricService) hasMetric(ctx context.Context, runID uuid.UUID, metricName string) bool {
+	key := metricNamesKey(runID)
+	found, err := s.redis.SMIsMember(ctx, key, completeNames, metricName).Result()
+	if err != nil {
+		return true
+	}
+	if complete, logged := found[0], found[1]; logged || complete {
+		return logged
+	}
+
+	// Names written meanwhile are added by their writers after they commit,
+	// so completing the set from a read made before then loses none
+	names, err := s.repo.ListMetricNames(ctx, runID)
+	if err != nil {
+		s.logger.Error("Failed to list metric names", zap.String("run_id", runID.String()), zap.Error(err))
+		return true
+	}
+	members := make([]interface{}, 0, len(names)+1)
+	members = append(members, completeNames)
+	logged := false
+	for _, name := range names {
+		members = append(members, name)
+		logged = logged || name == metricName
+	}
+	pipe := s.redis.Pipeline()
+	pipe.SAdd(ctx, key, members...)
+	pipe.Expire(ctx, key, lastWriteRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Error("Failed to fill metric name set", zap.String("run_id", runID.String()), zap.Error(err))
+	}
+	return logged
+}
+
+// cacheVersion returns the last write of a run in Unix nanoseconds, or 0 for
+// none, which the keys of its cached results include, and how long to cache
+// them. Results are not cached when the last write cannot be read.
+func (s *MetricService) cacheVersion(ctx context.Context, runID uuid.UUID) (version int64, ttl time.Duration, ok bool) {
+	version, err := s.redis.Get(ctx, lastWriteKey(runID)).Int64()
+	if err != nil && err != redis.Nil {
+		return 0, 0, false
+	}
 
-		// Invalidate stats cache
-		statsKey := fmt.Sprintf("metric:stats:%s:%s", m.RunID.String(), m.MetricName)
-		s.redis.Del(ctx, statsKey)
+	var lastWrite time.Time
+	if version != 0 {
+		lastWrite = time.Unix(0, version)
+	}
+	return version, s.cache.TTL(lastWrite, time.Now()), true
+}
+
+// cacheKeyPatterns match every cached query result, keyed by run ID and then
+// the run's last write, and the name sets of runs
+var cacheKeyPatterns = []string{"metric:latest:%s:*", "metric:stats:%s:*", "metrics:run:%s:*", "metric:names:%s"}
+
+// FlushCache deletes the cached query results of one run, or of every run
+// when runID is nil, and returns the number of keys deleted
+func (s *MetricService) FlushCache(ctx context.Context, runID *uuid.UUID) (int64, error) {
+	run := "*"
+	if runID != nil {
+		run = runID.String()
+	}
+
+	var deleted int64
+	for _, pattern := range cacheKeyPatterns {
+		iter := s.redis.Scan(ctx, 0, fmt.Sprintf(pattern, run), 1000).Iterator()
+		for iter.Next(ctx) {
+			n, err := s.redis.Del(ctx, iter.Val()).Result()
+			if err != nil {
+				return deleted, fmt.Errorf("failed to delete cache key: %w", err)
+			}
+			deleted += n
+		}
+		if err := iter.Err(); err != nil {
+			return deleted, fmt.Errorf("failed to scan cache keys: %w", err)
+		}
 	}
+	return deleted, nil
 }
 
-func (s *MetricService) getRunMetricsCacheKey(runID uuid.UUID, params model.MetricQueryParams) string {
-	return fmt.Sprintf("metrics:run:%s:%v", runID.String(), params)
+// getRunMetricsCacheKey keys a page of a run's metrics by the values of its
+// query, extending the key of its count with what selects the page
+func (s *MetricService) getRunMetricsCacheKey(runID uuid.UUID, version int64, params model.MetricQueryParams) string {
+	return fmt.Sprintf("metrics:run:%s:%d:%s:%d:%s:%s:%d:%s",
+		runID.String(), version, countCacheKey(params), params.Limit, params.OrderBy, params.Direction, params.Offset, params.Cursor)
 }
 
 func (s *MetricService) getFromCache(ctx context.Context, key string) ([]byte, error) {
@@ -207,7 +994,7 @@ func (s *MetricService) setCache(ctx context.Context, key string, value []byte,
 	return s.redis.Set(ctx, key, value, expiration).Err()
 }
 
-// SubscribeToMetrics subscribes to Redis channel for real-time metrics
-func (s *MetricService) SubscribeToMetrics(ctx context.Context, channel string) *redis.PubSub {
-	return s.redis.Subscribe(ctx, channel)
+// SubscribeToMetrics subscribes to Redis channels for real-time metrics and run events
+func (s *MetricService) SubscribeToMetrics(ctx context.Context, channels ...string) *redis.PubSub {
+	return s.redis.Subscribe(ctx, channels...)
 }
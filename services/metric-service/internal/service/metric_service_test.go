@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestGetRunMetricsCacheKey(t *testing.T) {
+	s := &MetricService{}
+	runID := uuid.New()
+	params := func(minStep int64) model.MetricQueryParams {
+		start := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+		return model.MetricQueryParams{StartTime: &start, MinStep: &minStep, MetricName: "loss", Limit: 100}
+	}
+
+	// Equal queries share a key however their values are held
+	if a, b := s.getRunMetricsCacheKey(runID, 1, params(10)), s.getRunMetricsCacheKey(runID, 1, params(10)); a != b {
+		t.Errorf("keys of equal queries differ: %q, %q", a, b)
+	}
+
+	base := s.getRunMetricsCacheKey(runID, 1, params(10))
+	other := params(10)
+	other.Direction = model.DirectionAsc
+	for name, key := range map[string]string{
+		"min_step":  s.getRunMetricsCacheKey(runID, 1, params(11)),
+		"version":   s.getRunMetricsCacheKey(runID, 2, params(10)),
+		"direction": s.getRunMetricsCacheKey(runID, 1, other),
+	} {
+		if key == base {
+			t.Errorf("key of a query with another %s is the same: %q", name, key)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+const defaultNodeBucketSeconds = 60
+
+// NodeService aggregates metrics of distributed runs across nodes and ranks,
+// or breaks them out per node and rank to find stragglers
+type NodeService struct {
+	repo   *repository.NodeRepository
+	logger *zap.Logger
+}
+
+func NewNodeService(repo *repository.NodeRepository, logger *zap.Logger) *NodeService {
+	return &NodeService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// NodeAggregate is the result of a node query: Points when reduced, Series
+// when broken out per node or rank. Params holds the query with defaults applied.
+type NodeAggregate struct {
+	Params model.NodeQueryParams
+	Points []model.AggregatePoint
+	Series []model.NodeSeries
+}
+
+// GetMetricByRank aggregates a training metric per step across ranks, or per rank
+func (s *NodeService) GetMetricByRank(ctx context.Context, runID uuid.UUID, metricName string, params model.NodeQueryParams) (*NodeAggregate, error) {
+	if err := normalizeNodeParams(&params); err != nil {
+		return nil, err
+	}
+
+	if params.Reduce == model.ReduceNone {
+		series, err := s.repo.GetMetricPerRank(ctx, runID, metricName, params)
+		return &NodeAggregate{Params: params, Series: series}, err
+	}
+	points, err := s.repo.GetMetricAcrossRanks(ctx, runID, metricName, params)
+	return &NodeAggregate{Params: params, Points: points}, err
+}
+
+// GetSystemMetricByNode aggregates one system metric type across nodes, or per node
+func (s *NodeService) GetSystemMetricByNode(ctx context.Context, runID uuid.UUID, params model.NodeQueryParams) (*NodeAggregate, error) {
+	if params.MetricType == "" {
+		return nil, &ValidationError{Message: "metric_type is required"}
+	}
+	if err := normalizeNodeParams(&params); err != nil {
+		return nil, err
+	}
+
+	if params.Reduce == model.ReduceNone {
+		series, err := s.repo.GetSystemMetricPerNode(ctx, runID, params)
+		return &NodeAggregate{Params: params, Series: series}, err
+	}
+	points, err := s.repo.GetSystemMetricAcrossNodes(ctx, runID, params)
+	return &NodeAggregate{Params: params, Points: points}, err
+}
+
+// GetGPUFieldByNode aggregates one GPU reading across all GPUs, or per node
+func (s *NodeService) GetGPUFieldByNode(ctx context.Context, runID uuid.UUID, params model.NodeQueryParams) (*NodeAggregate, error) {
+	if params.Field == "" {
+		params.Field = "utilization"
+	}
+	if !contains(model.GPUAggregateFields, params.Field) {
+		return nil, &ValidationError{Message: fmt.Sprintf("field must be one of %v", model.GPUAggregateFields)}
+	}
+	if err := normalizeNodeParams(&params); err != nil {
+		return nil, err
+	}
+
+	if params.Reduce == model.ReduceNone {
+		series, err := s.repo.GetGPUFieldPerNode(ctx, runID, params)
+		return &NodeAggregate{Params: params, Series: series}, err
+	}
+	points, err := s.repo.GetGPUFieldAcrossNodes(ctx, runID, params)
+	return &NodeAggregate{Params: params, Points: points}, err
+}
+
+// normalizeNodeParams fills in defaults and rejects unknown reductions
+func normalizeNodeParams(params *model.NodeQueryParams) error {
+	switch params.Reduce {
+	case "":
+		params.Reduce = model.ReduceMean
+	case model.ReduceNone, model.ReduceMean, model.ReduceMax, model.ReduceMin, model.ReduceSum:
+	default:
+		return &ValidationError{Message: fmt.Sprintf("unsupported reduce %q (use none, mean, max, min or sum)", params.Reduce)}
+	}
+
+	if params.Bucket == 0 {
+		params.Bucket = defaultNodeBucketSeconds
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+const defaultProjectRunMetric = "loss"
+
+// defaultActiveWithin is how recently a run must have logged to count as
+// active in the latest metrics of a project
+const defaultActiveWithin = 15 * time.Minute
+
+// ProjectService keeps track of which project and experiment each run belongs
+// to and serves project-scoped listings
+type ProjectService struct {
+	repo   *repository.ProjectRepository
+	logger *zap.Logger
+}
+
+func NewProjectService(repo *repository.ProjectRepository, logger *zap.Logger) *ProjectService {
+	return &ProjectService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// ObserveMetrics registers the runs of a persisted batch that name their project
+func (s *ProjectService) ObserveMetrics(ctx context.Context, metrics []model.Metric) {
+	now := time.Now()
+	index := make(map[uuid.UUID]int)
+	var runs []model.RunProject
+
+	for _, m := range metrics {
+		if m.ProjectID == nil {
+			continue
+		}
+		if i, ok := index[m.RunID]; ok {
+			if runs[i].ExperimentID == nil {
+				runs[i].ExperimentID = m.ExperimentID
+			}
+			continue
+		}
+		index[m.RunID] = len(runs)
+		runs = append(runs, model.RunProject{
+			RunID:        m.RunID,
+			ProjectID:    *m.ProjectID,
+			ExperimentID: m.ExperimentID,
+			LastSeenAt:   now,
+		})
+	}
+
+	if err := s.repo.RegisterRuns(ctx, runs); err != nil {
+		s.logger.Error("Failed to register run projects", zap.Error(err))
+	}
+}
+
+// SetRunProject moves a run to a project and experiment
+func (s *ProjectService) SetRunProject(ctx context.Context, runID uuid.UUID, req model.SetRunProjectRequest) (*model.RunProject, error) {
+	if req.ProjectID == uuid.Nil {
+		return nil, &ValidationError{Message: "project_id is required"}
+	}
+
+	run := &model.RunProject{
+		RunID:        runID,
+		ProjectID:    req.ProjectID,
+		ExperimentID: req.ExperimentID,
+	}
+	if err := s.repo.SetRunProject(ctx, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// GetRunProject retrieves the project of a run
+func (s *ProjectService) GetRunProject(ctx context.Context, runID uuid.UUID) (*model.RunProject, error) {
+	return s.repo.GetRunProject(ctx, runID)
+}
+
+// ListProjectRuns lists the runs of a project with the latest value of one metric
+func (s *ProjectService) ListProjectRuns(ctx context.Context, projectID uuid.UUID, params model.ProjectRunQueryParams) ([]model.ProjectRun, error) {
+	var experimentID *uuid.UUID
+	if params.ExperimentID != "" {
+		id, err := uuid.Parse(params.ExperimentID)
+		if err != nil {
+			return nil, &ValidationError{Message: "invalid experiment_id"}
+		}
+		experimentID = &id
+	}
+
+	if params.ActiveWithin < 0 {
+		return nil, &ValidationError{Message: "active_within must not be negative"}
+	}
+	var activeSince *time.Time
+	if params.ActiveWithin > 0 {
+		since := time.Now().Add(-params.ActiveWithin)
+		activeSince = &since
+	}
+
+	if params.MetricName == "" {
+		params.MetricName = defaultProjectRunMetric
+	}
+
+	return s.repo.ListProjectRuns(ctx, projectID, experimentID, activeSince, params.MetricName, params.Limit)
+}
+
+// ListLatestMetrics returns the latest value of a metric for every active run
+// of a project
+func (s *ProjectService) ListLatestMetrics(ctx context.Context, projectID uuid.UUID, params model.ProjectLatestMetricsParams) ([]model.ProjectRunMetric, error) {
+	var experimentID *uuid.UUID
+	if params.ExperimentID != "" {
+		id, err := uuid.Parse(params.ExperimentID)
+		if err != nil {
+			return nil, &ValidationError{Message: "invalid experiment_id"}
+		}
+		experimentID = &id
+	}
+
+	if params.ActiveWithin < 0 {
+		return nil, &ValidationError{Message: "active_within must not be negative"}
+	}
+	if params.ActiveWithin == 0 {
+		params.ActiveWithin = defaultActiveWithin
+	}
+
+	return s.repo.ListLatestMetrics(ctx, projectID, experimentID, time.Now().Add(-params.ActiveWithin), params.MetricName, params.Limit)
+}
+
+// ListExperiments summarizes the experiments of a project
+func (s *ProjectService) ListExperiments(ctx context.Context, projectID uuid.UUID) ([]model.ExperimentInfo, error) {
+	return s.repo.ListExperiments(ctx, projectID)
+}
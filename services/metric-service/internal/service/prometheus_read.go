@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// promMatcher tells whether a label value matches a compiled label matcher
+type promMatcher struct {
+	name  string
+	match func(value string) bool
+}
+
+// PrometheusRead answers a remote-read query with the series its matchers
+// all select. Bools are samples of 1 and 0; strings are left out. Equality
+// matchers on run_id and __name__ narrow what is read, and a query needs at
+// least one of them, so that it never reads the metrics of every run.
+func (s *MetricService) PrometheusRead(ctx context.Context, query model.PromReadQuery) ([]model.PromTimeSeries, error) {
+	if query.End.Before(query.Start) {
+		return nil, &ValidationError{Message: "end must not be before start"}
+	}
+	matchers, err := compilePromMatchers(query.Matchers)
+	if err != nil {
+		return nil, err
+	}
+
+	var runID *uuid.UUID
+	var metricName string
+	for _, m := range query.Matchers {
+		if m.Type != model.PromMatchEqual {
+			continue
+		}
+		switch m.Name {
+		case model.PromLabelRunID:
+			id, err := uuid.Parse(m.Value)
+			if err != nil {
+				// No run has such an ID
+				return []model.PromTimeSeries{}, nil
+			}
+			runID = &id
+		case model.PromLabelName:
+			metricName = m.Value
+		}
+	}
+	if runID == nil && metricName == "" {
+		return nil, &ValidationError{Message: "a query must match run_id or __name__ with ="}
+	}
+
+	series := []model.PromTimeSeries{}
+	var key string
+	matched := false
+	err = s.repo.StreamSeriesMetrics(ctx, runID, metricName, query.Start, query.End, func(m model.Metric) error {
+		labels := promLabels(m)
+		if k := promSeriesKey(labels); k != key {
+			key = k
+			matched = promMatches(matchers, labels)
+			if matched {
+				series = append(series, model.PromTimeSeries{Labels: labels})
+			}
+		}
+		if matched {
+			last := &series[len(series)-1]
+			last.Samples = append(last.Samples, model.PromSample{Value: promValue(m), Time: m.Time})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// compilePromMatchers compiles label matchers. Regular expressions match
+// whole values, as in Prometheus.
+func compilePromMatchers(matchers []model.PromLabelMatcher) ([]promMatcher, error) {
+	compiled := make([]promMatcher, len(matchers))
+	for i, m := range matchers {
+		value := m.Value
+		switch m.Type {
+		case model.PromMatchEqual:
+			compiled[i] = promMatcher{m.Name, func(v string) bool { return v == value }}
+		case model.PromMatchNotEqual:
+			compiled[i] = promMatcher{m.Name, func(v string) bool { return v != value }}
+		case model.PromMatchRegexp, model.PromMatchNotRegexp:
+			re, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return nil, &ValidationError{Message: fmt.Sprintf("invalid regular expression for %s: %v", m.Name, err)}
+			}
+			negate := m.Type == model.PromMatchNotRegexp
+			compiled[i] = promMatcher{m.Name, func(v string) bool { return re.MatchString(v) != negate }}
+		default:
+			return nil, &ValidationError{Message: fmt.Sprintf("unknown matcher type %d for %s", m.Type, m.Name)}
+		}
+	}
+	return compiled, nil
+}
+
+// promMatches tells whether labels match all matchers
+func promMatches(matchers []promMatcher, labels []model.PromLabel) bool {
+	for _, m := range matchers {
+		value := ""
+		for _, l := range labels {
+			if l.Name == m.name {
+				value = l.Value
+				break
+			}
+		}
+		if !m.match(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// promLabels are the labels of the series of m, sorted by name
+func promLabels(m model.Metric) []model.PromLabel {
+	labels := []model.PromLabel{{Name: model.PromLabelName, Value: m.MetricName}}
+	if m.NodeID != "" {
+		labels = append(labels, model.PromLabel{Name: model.PromLabelNodeID, Value: m.NodeID})
+	}
+	if m.Rank != nil {
+		labels = append(labels, model.PromLabel{Name: model.PromLabelRank, Value: strconv.Itoa(*m.Rank)})
+	}
+	return append(labels, model.PromLabel{Name: model.PromLabelRunID, Value: m.RunID.String()})
+}
+
+// promSeriesKey identifies the series of labels
+func promSeriesKey(labels []model.PromLabel) string {
+	var b strings.Builder
+	for _, l := range labels {
+		b.WriteString(l.Name)
+		b.WriteByte(0xff)
+		b.WriteString(l.Value)
+		b.WriteByte(0xff)
+	}
+	return b.String()
+}
+
+// promValue is the sample value of a number or bool
+func promValue(m model.Metric) float64 {
+	if m.ValueType == model.ValueTypeBool {
+		if m.Text != nil && *m.Text == "true" {
+			return 1
+		}
+		return 0
+	}
+	return m.Value
+}
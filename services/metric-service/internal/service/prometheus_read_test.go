@@ -0,0 +1,64 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestPromMatchers(t *testing.T) {
+	rank := 3
+	runID := uuid.MustParse("6f1c1b7e-3f3c-4a7b-9a43-1f0f5a2c9d10")
+	labels := promLabels(model.Metric{RunID: runID, MetricName: "train/loss", Rank: &rank})
+	want := []model.PromLabel{
+		{Name: "__name__", Value: "train/loss"},
+		{Name: "rank", Value: "3"},
+		{Name: "run_id", Value: runID.String()},
+	}
+	if len(labels) != len(want) {
+		t.Fatalf("promLabels = %v, want %v", labels, want)
+	}
+	for i := range want {
+		if labels[i] != want[i] {
+			t.Fatalf("promLabels = %v, want %v", labels, want)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		matcher model.PromLabelMatcher
+		want    bool
+	}{
+		{"equal", promMatcherOf(model.PromMatchEqual, "__name__", "train/loss"), true},
+		{"not equal", promMatcherOf(model.PromMatchNotEqual, "rank", "3"), false},
+		{"regexp is anchored", promMatcherOf(model.PromMatchRegexp, "__name__", "train"), false},
+		{"regexp", promMatcherOf(model.PromMatchRegexp, "__name__", "train/.*"), true},
+		{"not regexp", promMatcherOf(model.PromMatchNotRegexp, "__name__", "eval/.*"), true},
+		{"missing label is empty", promMatcherOf(model.PromMatchEqual, "node_id", ""), true},
+		{"missing label", promMatcherOf(model.PromMatchRegexp, "node_id", ".+"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matchers, err := compilePromMatchers([]model.PromLabelMatcher{tt.matcher})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := promMatches(matchers, labels); got != tt.want {
+				t.Errorf("promMatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := compilePromMatchers([]model.PromLabelMatcher{promMatcherOf(model.PromMatchRegexp, "__name__", "(")}); err == nil {
+		t.Error("compilePromMatchers accepted an invalid regular expression")
+	}
+	if _, err := compilePromMatchers([]model.PromLabelMatcher{promMatcherOf(4, "__name__", "loss")}); err == nil {
+		t.Error("compilePromMatchers accepted an unknown matcher type")
+	}
+}
+
+func promMatcherOf(typ int, name, value string) model.PromLabelMatcher {
+	return model.PromLabelMatcher{Type: typ, Name: name, Value: value}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// reportSnapshotMaxPoints caps the points captured per series in a report snapshot
+const reportSnapshotMaxPoints = 10000
+
+// ReportService persists saved comparison reports and snapshots their data
+type ReportService struct {
+	repo       *repository.ReportRepository
+	metricRepo *repository.MetricRepository
+	// parallelism bounds the series queried at once for a snapshot
+	parallelism int
+	logger      *zap.Logger
+}
+
+func NewReportService(repo *repository.ReportRepository, metricRepo *repository.MetricRepository, parallelism int, logger *zap.Logger) *ReportService {
+	return &ReportService{
+		repo:        repo,
+		metricRepo:  metricRepo,
+		parallelism: parallelism,
+		logger:      logger,
+	}
+}
+
+// CreateReport saves a report, snapshotting the underlying data unless disabled
+func (s *ReportService) CreateReport(ctx context.Context, req model.CreateReportRequest) (*model.Report, error) {
+	report := &model.Report{
+		ID:          uuid.New(),
+		Name:        req.Name,
+		Description: req.Description,
+		RunIDs:      req.RunIDs,
+		MetricNames: req.MetricNames,
+		Config:      req.Config,
+	}
+	if report.Config == nil {
+		report.Config = map[string]interface{}{}
+	}
+
+	if req.Snapshot == nil || *req.Snapshot {
+		snapshot, err := s.buildSnapshot(ctx, report.RunIDs, report.MetricNames)
+		if err != nil {
+			return nil, err
+		}
+		report.Snapshot = snapshot
+	}
+
+	if err := s.repo.CreateReport(ctx, report); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Report saved",
+		zap.String("report_id", report.ID.String()),
+		zap.Int("runs", len(report.RunIDs)),
+		zap.Int("metrics", len(report.MetricNames)))
+	return report, nil
+}
+
+// GetReport retrieves a report, optionally with its snapshot
+func (s *ReportService) GetReport(ctx context.Context, reportID uuid.UUID, withSnapshot bool) (*model.Report, error) {
+	return s.repo.GetReport(ctx, reportID, withSnapshot)
+}
+
+// RenderReport returns the data for a report: the saved snapshot, or the
+// current data when live is set or no snapshot was taken
+func (s *ReportService) RenderReport(ctx context.Context, reportID uuid.UUID, live bool) (*model.Report, error) {
+	report, err := s.repo.GetReport(ctx, reportID, !live)
+	if err != nil || report == nil {
+		return report, err
+	}
+
+	if report.Snapshot == nil {
+		snapshot, err := s.buildSnapshot(ctx, report.RunIDs, report.MetricNames)
+		if err != nil {
+			return nil, err
+		}
+		report.Snapshot = snapshot
+	}
+
+	return report, nil
+}
+
+// ListReports lists saved reports without their snapshots
+func (s *ReportService) ListReports(ctx context.Context, limit, offset int) ([]model.Report, error) {
+	return s.repo.ListReports(ctx, limit, offset)
+}
+
+// UpdateReport changes the presentation of a report; the snapshot is immutable
+func (s *ReportService) UpdateReport(ctx context.Context, reportID uuid.UUID, req model.UpdateReportRequest) (bool, error) {
+	return s.repo.UpdateReport(ctx, reportID, req)
+}
+
+// DeleteReport removes a report
+func (s *ReportService) DeleteReport(ctx context.Context, reportID uuid.UUID) (bool, error) {
+	return s.repo.DeleteReport(ctx, reportID)
+}
+
+// buildSnapshot captures stats and history for every run × metric pair. The
+// series are queried concurrently and kept in run, then metric order.
+func (s *ReportService) buildSnapshot(ctx context.Context, runIDs []uuid.UUID, metricNames []string) (*model.ReportSnapshot, error) {
+	snapshot := &model.ReportSnapshot{
+		TakenAt: time.Now().UTC(),
+		Series:  make([]model.ReportSeries, len(runIDs)*len(metricNames)),
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(s.parallelism)
+	for i, runID := range runIDs {
+		for j, metricName := range metricNames {
+			series := &snapshot.Series[i*len(metricNames)+j]
+			series.RunID, series.MetricName = runID, metricName
+			g.Go(func() error {
+				return s.fillSeries(gctx, series)
+			})
+		}
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// fillSeries queries the stats and points of a snapshot series
+func (s *ReportService) fillSeries(ctx context.Context, series *model.ReportSeries) error {
+	stats, err := s.metricRepo.GetMetricStats(ctx, series.RunID, series.MetricName)
+	if err != nil {
+		return err
+	}
+
+	points, err := s.metricRepo.GetMetricHistory(ctx, series.RunID, series.MetricName, model.MetricQueryParams{
+		Limit: reportSnapshotMaxPoints,
+	})
+	if err != nil {
+		return err
+	}
+
+	series.Stats = stats
+	series.Points = points
+	series.Truncated = stats != nil && stats.Count > int64(len(points))
+	return nil
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// RunConfigService stores run configurations and compares runs by their
+// configuration, tags, placement and metric summaries
+type RunConfigService struct {
+	repo        *repository.RunConfigRepository
+	tagRepo     *repository.TagRepository
+	groupRepo   *repository.GroupRepository
+	projectRepo *repository.ProjectRepository
+	summaryRepo *repository.SummaryRepository
+	// parallelism bounds the lookups run at once for a diff
+	parallelism int
+	logger      *zap.Logger
+}
+
+func NewRunConfigService(
+	repo *repository.RunConfigRepository,
+	tagRepo *repository.TagRepository,
+	groupRepo *repository.GroupRepository,
+	projectRepo *repository.ProjectRepository,
+	summaryRepo *repository.SummaryRepository,
+	parallelism int,
+	logger *zap.Logger,
+) *RunConfigService {
+	return &RunConfigService{
+		repo:        repo,
+		tagRepo:     tagRepo,
+		groupRepo:   groupRepo,
+		projectRepo: projectRepo,
+		summaryRepo: summaryRepo,
+		parallelism: parallelism,
+		logger:      logger,
+	}
+}
+
+// SetRunConfig replaces the configuration of a run
+func (s *RunConfigService) SetRunConfig(ctx context.Context, runID uuid.UUID, req model.SetRunConfigRequest) (*model.RunConfig, error) {
+	cfg := &model.RunConfig{
+		RunID:  runID,
+		Config: req.Config,
+		Source: model.ConfigSourceRun,
+	}
+	if err := s.repo.SetRunConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// GetRunConfig retrieves the configuration of a run
+func (s *RunConfigService) GetRunConfig(ctx context.Context, runID uuid.UUID) (*model.RunConfig, error) {
+	return s.repo.GetRunConfig(ctx, runID)
+}
+
+// DiffRuns compares run B against run A. Both runs' configs, tags,
+// metadata and summaries are looked up concurrently.
+func (s *RunConfigService) DiffRuns(ctx context.Context, req model.RunDiffRequest) (*model.RunDiff, error) {
+	if req.RunA == req.RunB {
+		return nil, &ValidationError{Message: "run_a and run_b must differ"}
+	}
+
+	var (
+		configA, configB       map[string]interface{}
+		tagsA, tagsB           map[string]interface{}
+		metaA, metaB           map[string]interface{}
+		summariesA, summariesB []model.RunMetricSummary
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(s.parallelism)
+	g.Go(func() (err error) { configA, err = s.configValues(gctx, req.RunA); return err })
+	g.Go(func() (err error) { configB, err = s.configValues(gctx, req.RunB); return err })
+	g.Go(func() (err error) { tagsA, err = s.tagValues(gctx, req.RunA); return err })
+	g.Go(func() (err error) { tagsB, err = s.tagValues(gctx, req.RunB); return err })
+	g.Go(func() (err error) { metaA, err = s.metadataValues(gctx, req.RunA); return err })
+	g.Go(func() (err error) { metaB, err = s.metadataValues(gctx, req.RunB); return err })
+	g.Go(func() (err error) { summariesA, err = s.summaryRepo.GetRunSummary(gctx, req.RunA); return err })
+	g.Go(func() (err error) { summariesB, err = s.summaryRepo.GetRunSummary(gctx, req.RunB); return err })
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return &model.RunDiff{
+		RunA:     req.RunA,
+		RunB:     req.RunB,
+		Config:   diffValues(configA, configB, req.IncludeUnchanged),
+		Tags:     diffValues(tagsA, tagsB, req.IncludeUnchanged),
+		Metadata: diffValues(metaA, metaB, req.IncludeUnchanged),
+		Metrics:  diffMetrics(req, summariesA, summariesB),
+	}, nil
+}
+
+func (s *RunConfigService) configValues(ctx context.Context, runID uuid.UUID) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	cfg, err := s.repo.GetRunConfig(ctx, runID)
+	if err != nil || cfg == nil {
+		return values, err
+	}
+	flattenConfig("", cfg.Config, values)
+	return values, nil
+}
+
+func (s *RunConfigService) tagValues(ctx context.Context, runID uuid.UUID) (map[string]interface{}, error) {
+	tags, err := s.tagRepo.GetRunTags(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{}, len(tags))
+	for _, t := range tags {
+		values[t.Key] = t.Value
+	}
+	return values, nil
+}
+
+func (s *RunConfigService) metadataValues(ctx context.Context, runID uuid.UUID) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+
+	group, err := s.groupRepo.GetRunGroup(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if group != nil {
+		values["group"] = group.Group
+		if group.JobType != "" {
+			values["job_type"] = group.JobType
+		}
+	}
+
+	project, err := s.projectRepo.GetRunProject(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if project != nil {
+		values["project_id"] = project.ProjectID.String()
+		if project.ExperimentID != nil {
+			values["experiment_id"] = project.ExperimentID.String()
+		}
+	}
+
+	return values, nil
+}
+
+// diffMetrics sets the summaries of both runs side by side, for the requested
+// metrics or every metric either run has summarized
+func diffMetrics(req model.RunDiffRequest, summariesA, summariesB []model.RunMetricSummary) []model.MetricDiff {
+	wanted := make(map[string]bool, len(req.MetricNames))
+	for _, name := range req.MetricNames {
+		wanted[name] = true
+	}
+
+	index := make(map[string]int)
+	diffs := []model.MetricDiff{}
+	side := func(summaries []model.RunMetricSummary, isA bool) {
+		for i := range summaries {
+			sum := &summaries[i]
+			if len(wanted) > 0 && !wanted[sum.MetricName] {
+				continue
+			}
+			j, ok := index[sum.MetricName]
+			if !ok {
+				j = len(diffs)
+				index[sum.MetricName] = j
+				diffs = append(diffs, model.MetricDiff{MetricName: sum.MetricName, Goal: sum.Goal})
+			}
+			if isA {
+				diffs[j].A = sum
+			} else {
+				diffs[j].B = sum
+			}
+		}
+	}
+	side(summariesA, true)
+	side(summariesB, false)
+
+	for name := range wanted {
+		if _, ok := index[name]; !ok {
+			index[name] = len(diffs)
+			diffs = append(diffs, model.MetricDiff{MetricName: name})
+		}
+	}
+
+	for i := range diffs {
+		d := &diffs[i]
+		if d.A == nil || d.B == nil {
+			continue
+		}
+		finalDelta := d.B.FinalValue - d.A.FinalValue
+		bestDelta := d.B.BestValue - d.A.BestValue
+		d.FinalDelta, d.BestDelta = &finalDelta, &bestDelta
+
+		switch {
+		case bestDelta == 0:
+		case (d.Goal == model.GoalMax) == (bestDelta > 0):
+			d.Better = "b"
+		default:
+			d.Better = "a"
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].MetricName < diffs[j].MetricName })
+	return diffs
+}
+
+// flattenConfig copies nested config maps into values under dotted keys
+func flattenConfig(prefix string, config map[string]interface{}, values map[string]interface{}) {
+	for key, value := range config {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenConfig(key, nested, values)
+			continue
+		}
+		values[key] = value
+	}
+}
+
+// diffValues compares two sets of values by key, sorted by key
+func diffValues(a, b map[string]interface{}, includeUnchanged bool) []model.ValueDiff {
+	diffs := []model.ValueDiff{}
+	for key, va := range a {
+		vb, ok := b[key]
+		switch {
+		case !ok:
+			diffs = append(diffs, model.ValueDiff{Key: key, A: va, Change: model.DiffRemoved})
+		case !reflect.DeepEqual(va, vb):
+			diffs = append(diffs, model.ValueDiff{Key: key, A: va, B: vb, Change: model.DiffChanged})
+		case includeUnchanged:
+			diffs = append(diffs, model.ValueDiff{Key: key, A: va, B: vb, Change: model.DiffUnchanged})
+		}
+	}
+	for key, vb := range b {
+		if _, ok := a[key]; !ok {
+			diffs = append(diffs, model.ValueDiff{Key: key, B: vb, Change: model.DiffAdded})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+	return diffs
+}
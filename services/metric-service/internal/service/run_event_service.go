@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// ErrInvalidTransition is returned for a state change the run's current state does not allow
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// runTransitions lists the states each state may move to. Ended runs may be
+// resumed; a run's first event may have any state.
+var runTransitions = map[string][]string{
+	model.RunStateCreated:  {model.RunStateRunning, model.RunStateFinished, model.RunStateCrashed, model.RunStateKilled},
+	model.RunStateRunning:  {model.RunStatePaused, model.RunStateFinished, model.RunStateCrashed, model.RunStateKilled},
+	model.RunStatePaused:   {model.RunStateRunning, model.RunStateFinished, model.RunStateCrashed, model.RunStateKilled},
+	model.RunStateFinished: {model.RunStateRunning},
+	model.RunStateCrashed:  {model.RunStateRunning, model.RunStateKilled},
+	model.RunStateKilled:   {model.RunStateRunning},
+}
+
+// RunEventService records run state changes and publishes them to live subscribers
+type RunEventService struct {
+	repo   *repository.RunEventRepository
+	redis  *redis.Client
+	logger *zap.Logger
+	events *CloudEventPublisher
+}
+
+func NewRunEventService(repo *repository.RunEventRepository, redis *redis.Client, logger *zap.Logger) *RunEventService {
+	return &RunEventService{
+		repo:   repo,
+		redis:  redis,
+		logger: logger,
+	}
+}
+
+// UseCloudEvents publishes state changes and the alerts they raise to events
+func (s *RunEventService) UseCloudEvents(events *CloudEventPublisher) {
+	s.events = events
+}
+
+// AppendEvent records a run entering a state. It fails with
+// ErrInvalidTransition when the run's current state cannot move to it or the
+// event is older than the current state.
+func (s *RunEventService) AppendEvent(ctx context.Context, runID uuid.UUID, req model.CreateRunEventRequest) (*model.RunEvent, error) {
+	event := &model.RunEvent{
+		ID:       uuid.New(),
+		RunID:    runID,
+		Time:     time.Now(),
+		State:    req.State,
+		Step:     req.Step,
+		Reason:   req.Reason,
+		Source:   req.Source,
+		Metadata: req.Metadata,
+	}
+	if req.Time != nil {
+		event.Time = *req.Time
+	}
+
+	err := s.repo.AppendEvent(ctx, event, func(latest *model.RunEvent) error {
+		if latest == nil {
+			return nil
+		}
+		if event.Time.Before(latest.Time) {
+			return fmt.Errorf("%w: event at %s is older than the current state %s since %s",
+				ErrInvalidTransition, event.Time.Format(time.RFC3339), latest.State, latest.Time.Format(time.RFC3339))
+		}
+		for _, next := range runTransitions[latest.State] {
+			if next == event.State {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: run cannot go from %s to %s", ErrInvalidTransition, latest.State, event.State)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(model.RunEventPayload{Events: []model.RunEvent{*event}})
+	if err == nil {
+		channel := fmt.Sprintf("events:%s", runID.String())
+		err = s.redis.Publish(ctx, channel, data).Err()
+	}
+	if err != nil {
+		s.logger.Warn("Failed to publish run event", zap.Error(err))
+	}
+	if s.events != nil {
+		s.events.Publish(model.CloudEventRunStateChanged, runID, event.Time, event)
+	}
+
+	if event.State == model.RunStateCrashed || event.State == model.RunStateKilled {
+		message := "run " + event.State
+		if event.Reason != "" {
+			message += ": " + event.Reason
+		}
+		publishAlerts(ctx, s.redis, s.events, s.logger, []model.Alert{{
+			Time:     event.Time,
+			RunID:    runID,
+			Severity: model.AlertSeverityCritical,
+			Source:   "run_events",
+			Kind:     "run_" + event.State,
+			Step:     event.Step,
+			Message:  message,
+		}})
+	}
+
+	return event, nil
+}
+
+// GetRunEvents retrieves the state changes of a run
+func (s *RunEventService) GetRunEvents(ctx context.Context, runID uuid.UUID, params model.RunEventQueryParams) ([]model.RunEvent, error) {
+	return s.repo.GetRunEvents(ctx, runID, params)
+}
+
+// GetRunState derives a run's current state and the intervals it spent in
+// each state from its events. It returns nil for a run without events.
+func (s *RunEventService) GetRunState(ctx context.Context, runID uuid.UUID) (*model.RunState, error) {
+	events, err := s.repo.GetRunEvents(ctx, runID, model.RunEventQueryParams{})
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return runStateFromEvents(runID, events, time.Now()), nil
+}
+
+func runStateFromEvents(runID uuid.UUID, events []model.RunEvent, now time.Time) *model.RunState {
+	state := &model.RunState{
+		RunID:     runID,
+		Intervals: make([]model.StateInterval, 0, len(events)),
+		Durations: make(map[string]float64),
+	}
+	for i, e := range events {
+		interval := model.StateInterval{State: e.State, StartTime: e.Time, Reason: e.Reason}
+		end := now
+		if i+1 < len(events) {
+			next := events[i+1].Time
+			interval.EndTime = &next
+			end = next
+		}
+		state.Intervals = append(state.Intervals, interval)
+		if end.After(e.Time) {
+			state.Durations[e.State] += end.Sub(e.Time).Seconds()
+		}
+	}
+
+	latest := events[len(events)-1]
+	state.State, state.Since = latest.State, latest.Time
+	return state
+}
@@ -0,0 +1,427 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+const (
+	schedulerLeaderKey = "scheduler:leader"
+
+	// A job's status outlives several missed runs so a new leader still
+	// knows when the job last ran
+	jobStatusTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+)
+
+// renewScript extends a lock only while it is still held by the caller
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseScript deletes a lock only while it is still held by the caller
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Job is a periodic background task hosted by the scheduler. Run returns a
+// short description of what it did, reported as the job's last result.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) (string, error)
+}
+
+// Scheduler runs periodic jobs on a single instance of the service. Instances
+// elect a leader through a lease in Redis; only the leader starts scheduled
+// runs. Job status is kept in Redis, so schedules carry over when leadership
+// moves, and every run holds a per-job lock so a job never runs twice at once,
+// even when triggered manually on another instance.
+type Scheduler struct {
+	redis    *redis.Client
+	lease    time.Duration
+	instance string
+	logger   *zap.Logger
+
+	mu       sync.RWMutex
+	jobs     map[string]Job
+	isLeader bool
+}
+
+func NewScheduler(redis *redis.Client, lease time.Duration, logger *zap.Logger) *Scheduler {
+	hostname, _ := os.Hostname()
+	return &Scheduler{
+		redis:    redis,
+		lease:    lease,
+		instance: fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8]),
+		logger:   logger,
+		jobs:     make(map[string]Job),
+	}
+}
+
+// Register adds a job. Jobs must be registered before Run is started.
+func (s *Scheduler) Register(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.Name] = job
+}
+
+// Run campaigns for leadership and, while leader, starts the jobs that are
+// due, until the context is cancelled. Leadership is released on return.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		s.campaign(ctx)
+		if s.IsLeader() {
+			s.startDueJobs(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			s.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// IsLeader reports whether this instance currently holds the leader lease
+func (s *Scheduler) IsLeader() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.isLeader
+}
+
+// Status reports the leader and the state of every registered job
+func (s *Scheduler) Status(ctx context.Context) (*model.SchedulerStatus, error) {
+	leader, err := s.redis.Get(ctx, schedulerLeaderKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read scheduler leader: %w", err)
+	}
+
+	status := &model.SchedulerStatus{
+		Instance: s.instance,
+		Leader:   leader,
+		IsLeader: leader == s.instance,
+		Jobs:     []model.JobStatus{},
+	}
+	for _, job := range s.sortedJobs() {
+		js, err := s.jobStatus(ctx, job)
+		if err != nil {
+			return nil, err
+		}
+		status.Jobs = append(status.Jobs, *js)
+	}
+	return status, nil
+}
+
+// Trigger starts a run of a job now, on this instance, whether or not it is
+// the leader. It returns ErrJobRunning when the job is already running.
+func (s *Scheduler) Trigger(ctx context.Context, name string) (*model.JobStatus, error) {
+	s.mu.RLock()
+	job, ok := s.jobs[name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	token, err := s.acquire(ctx, jobLockKey(name), s.lease)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, ErrJobRunning
+	}
+
+	// The run outlives the request that triggered it
+	status, err := s.begin(ctx, job, "manual")
+	if err != nil {
+		s.release(context.Background(), jobLockKey(name), token)
+		return nil, err
+	}
+	started := *status
+	go s.execute(context.WithoutCancel(ctx), job, token, status)
+	return &started, nil
+}
+
+func (s *Scheduler) sortedJobs() []Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+// campaign renews the leader lease if held, or tries to take it otherwise
+func (s *Scheduler) campaign(ctx context.Context) {
+	leader := false
+	if s.IsLeader() {
+		renewed, err := renewScript.Run(ctx, s.redis, []string{schedulerLeaderKey}, s.instance, s.lease.Milliseconds()).Int()
+		if err != nil {
+			s.logger.Error("Failed to renew scheduler leadership", zap.Error(err))
+		}
+		leader = renewed == 1
+	} else {
+		acquired, err := s.redis.SetNX(ctx, schedulerLeaderKey, s.instance, s.lease).Result()
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to campaign for scheduler leadership", zap.Error(err))
+		}
+		leader = acquired
+	}
+
+	s.mu.Lock()
+	changed := leader != s.isLeader
+	s.isLeader = leader
+	s.mu.Unlock()
+
+	if changed {
+		s.logger.Info("Scheduler leadership changed", zap.String("instance", s.instance), zap.Bool("leader", leader))
+	}
+}
+
+func (s *Scheduler) resign() {
+	if !s.IsLeader() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	s.release(ctx, schedulerLeaderKey, s.instance)
+
+	s.mu.Lock()
+	s.isLeader = false
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) startDueJobs(ctx context.Context) {
+	now := time.Now()
+	for _, job := range s.sortedJobs() {
+		status, err := s.loadStatus(ctx, job.Name)
+		if err != nil {
+			s.logger.Error("Failed to load job status", zap.String("job", job.Name), zap.Error(err))
+			continue
+		}
+		// A run still in progress holds the job lock, so the acquire below
+		// skips it; a status left running by a crashed instance does not
+		if status != nil && status.LastStarted != nil && now.Before(status.LastStarted.Add(job.Interval)) {
+			continue
+		}
+
+		token, err := s.acquire(ctx, jobLockKey(job.Name), s.lease)
+		if err != nil {
+			s.logger.Error("Failed to lock job", zap.String("job", job.Name), zap.Error(err))
+			continue
+		}
+		if token == "" {
+			continue
+		}
+
+		js, err := s.begin(ctx, job, "schedule")
+		if err != nil {
+			s.logger.Error("Failed to start job", zap.String("job", job.Name), zap.Error(err))
+			s.release(context.Background(), jobLockKey(job.Name), token)
+			continue
+		}
+		go s.execute(ctx, job, token, js)
+	}
+}
+
+// begin marks a job as running. The caller must hold the job's lock.
+func (s *Scheduler) begin(ctx context.Context, job Job, trigger string) (*model.JobStatus, error) {
+	status, err := s.loadStatus(ctx, job.Name)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		status = &model.JobStatus{Name: job.Name}
+	}
+
+	now := time.Now()
+	status.Running = true
+	status.RunningOn = s.instance
+	status.LastStarted = &now
+	status.LastTrigger = trigger
+	if err := s.saveStatus(ctx, status); err != nil {
+		return nil, err
+	}
+	return s.withSchedule(status, job), nil
+}
+
+// execute runs a job while keeping its lock alive, then records the outcome
+// and releases the lock
+func (s *Scheduler) execute(ctx context.Context, job Job, token string, status *model.JobStatus) {
+	lockKey := jobLockKey(job.Name)
+	defer s.release(context.Background(), lockKey, token)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go s.keepAlive(runCtx, lockKey, token)
+
+	started := time.Now()
+	result, err := s.safeRun(runCtx, job)
+	finished := time.Now()
+
+	status.Running = false
+	status.RunningOn = ""
+	status.LastFinished = &finished
+	status.LastDuration = finished.Sub(started).Seconds()
+	status.LastResult = result
+	status.LastError = ""
+	status.Runs++
+	if err != nil {
+		status.LastError = err.Error()
+		status.Failures++
+		s.logger.Error("Scheduled job failed", zap.String("job", job.Name), zap.Error(err))
+	} else {
+		s.logger.Info("Scheduled job completed",
+			zap.String("job", job.Name),
+			zap.String("result", result),
+			zap.Duration("duration", finished.Sub(started)),
+		)
+	}
+
+	// Record the outcome even when the run was cut short by shutdown
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer saveCancel()
+	if err := s.saveStatus(saveCtx, status); err != nil {
+		s.logger.Error("Failed to save job status", zap.String("job", job.Name), zap.Error(err))
+	}
+}
+
+func (s *Scheduler) safeRun(ctx context.Context, job Job) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+func (s *Scheduler) keepAlive(ctx context.Context, key, token string) {
+	ticker := time.NewTicker(s.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := renewScript.Run(ctx, s.redis, []string{key}, token, s.lease.Milliseconds()).Err(); err != nil && ctx.Err() == nil {
+				s.logger.Warn("Failed to renew job lock", zap.String("key", key), zap.Error(err))
+			}
+		}
+	}
+}
+
+// acquire takes a lock, returning its token, or "" when it is held elsewhere
+func (s *Scheduler) acquire(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	token := s.instance + ":" + uuid.NewString()
+	acquired, err := s.redis.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to acquire lock %s: %w", key, err)
+	}
+	if !acquired {
+		return "", nil
+	}
+	return token, nil
+}
+
+func (s *Scheduler) release(ctx context.Context, key, token string) {
+	if err := releaseScript.Run(ctx, s.redis, []string{key}, token).Err(); err != nil {
+		s.logger.Warn("Failed to release lock", zap.String("key", key), zap.Error(err))
+	}
+}
+
+func (s *Scheduler) jobStatus(ctx context.Context, job Job) (*model.JobStatus, error) {
+	status, err := s.loadStatus(ctx, job.Name)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		status = &model.JobStatus{Name: job.Name}
+	}
+
+	// A run whose lock has expired died with its instance
+	if status.Running {
+		held, err := s.redis.Exists(ctx, jobLockKey(job.Name)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read job lock: %w", err)
+		}
+		if held == 0 {
+			status.Running = false
+			status.RunningOn = ""
+		}
+	}
+	return s.withSchedule(status, job), nil
+}
+
+// withSchedule fills in the fields derived from the job's interval
+func (s *Scheduler) withSchedule(status *model.JobStatus, job Job) *model.JobStatus {
+	status.Interval = job.Interval.String()
+	status.NextRun = nil
+	if status.LastStarted != nil {
+		next := status.LastStarted.Add(job.Interval)
+		status.NextRun = &next
+	}
+	return status
+}
+
+func (s *Scheduler) loadStatus(ctx context.Context, name string) (*model.JobStatus, error) {
+	data, err := s.redis.Get(ctx, jobStatusKey(name)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job status: %w", err)
+	}
+
+	var status model.JobStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("failed to decode job status: %w", err)
+	}
+	return &status, nil
+}
+
+func (s *Scheduler) saveStatus(ctx context.Context, status *model.JobStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	if err := s.redis.Set(ctx, jobStatusKey(status.Name), data, jobStatusTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save job status: %w", err)
+	}
+	return nil
+}
+
+func jobStatusKey(name string) string {
+	return "scheduler:job:" + name
+}
+
+func jobLockKey(name string) string {
+	return "scheduler:lock:" + name
+}
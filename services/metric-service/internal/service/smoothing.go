@@ -0,0 +1,39 @@
+package service
+
+import (
+	"math"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// SmoothingEMA smooths a history with an exponential moving average
+const SmoothingEMA = "ema"
+
+// DefaultSmoothingAlpha is the weight of each new value in the average
+const DefaultSmoothingAlpha = 0.1
+
+// SmoothEMA returns the exponential moving average of a history at each of
+// its metrics, which are newest first as histories are read by default, or
+// oldest first when ascending. Each value weighs alpha against (1-alpha) for
+// the average before it, and the average is debiased, as TensorBoard and W&B
+// do, so that the first values are not pulled towards zero. Metrics without
+// a finite numeric value are skipped and have no smoothed value.
+func SmoothEMA(metrics []model.Metric, alpha float64, ascending bool) []*float64 {
+	smoothed := make([]*float64, len(metrics))
+	var avg, weight float64
+	for j := range metrics {
+		i := len(metrics) - 1 - j
+		if ascending {
+			i = j
+		}
+		m := metrics[i]
+		if !m.IsNumeric() || math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+			continue
+		}
+		avg = (1-alpha)*avg + alpha*m.Value
+		weight = (1-alpha)*weight + alpha
+		v := avg / weight
+		smoothed[i] = &v
+	}
+	return smoothed
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestSmoothEMA(t *testing.T) {
+	text := "warmup"
+	// Newest first, as histories are read
+	metrics := []model.Metric{
+		{Value: 4},
+		{Value: math.NaN()},
+		{ValueType: model.ValueTypeString, Text: &text},
+		{Value: 2},
+		{Value: 1},
+	}
+	got := SmoothEMA(metrics, 0.5, false)
+
+	// Debiased, the first average is the first value; the next weighs 2
+	// against 1 by 0.5/0.75 and 0.25/0.75, then 4 against that by 4/7 and 3/7
+	want := []*float64{ptr(4.0*4/7 + 5.0/3*3/7), nil, nil, ptr(5.0 / 3), ptr(1.0)}
+	for i := range want {
+		if (got[i] == nil) != (want[i] == nil) || (want[i] != nil && math.Abs(*got[i]-*want[i]) > 1e-9) {
+			t.Errorf("smoothed[%d] = %v, want %v", i, deref(got[i]), deref(want[i]))
+		}
+	}
+
+	// Oldest first, as ascending queries read them
+	ascending := []model.Metric{metrics[4], metrics[3], metrics[0]}
+	got = SmoothEMA(ascending, 0.5, true)
+	if got[0] == nil || *got[0] != 1 || got[2] == nil || math.Abs(*got[2]-3) > 1e-9 {
+		t.Errorf("SmoothEMA(ascending) = %v, %v, %v; want 1, 5/3, 3", deref(got[0]), deref(got[1]), deref(got[2]))
+	}
+}
+
+func ptr(v float64) *float64 { return &v }
+
+func deref(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// statsdPacketSize is the largest datagram sent, which the Datadog agent
+// recommends for UDP so that packets are not fragmented
+const statsdPacketSize = 1432
+
+// StatsDOptions configure the StatsD forwarder. Metrics lists the names
+// forwarded: training metrics as they are logged (val/loss), system metrics
+// as system/<metric_type> (system/cpu) and GPU samples as gpu/<field>
+// (gpu/utilization). Tags are added to every value, e.g. env:prod.
+type StatsDOptions struct {
+	Addr    string
+	Prefix  string
+	Metrics []string
+	Tags    []string
+}
+
+// GPU fields forwarded as gpu/<field>
+var statsdGPUFields = []struct {
+	name  string
+	value func(model.GPUMetric) *float64
+}{
+	{"utilization", func(m model.GPUMetric) *float64 { return m.Utilization }},
+	{"memory_utilization", func(m model.GPUMetric) *float64 { return m.MemoryUtilization }},
+	{"memory_used_mb", func(m model.GPUMetric) *float64 { return m.MemoryUsedMB }},
+	{"memory_total_mb", func(m model.GPUMetric) *float64 { return m.MemoryTotalMB }},
+	{"temperature_c", func(m model.GPUMetric) *float64 { return m.TemperatureC }},
+	{"power_w", func(m model.GPUMetric) *float64 { return m.PowerW }},
+}
+
+// StatsDForwarder mirrors selected metrics to a StatsD or Datadog agent as
+// gauges tagged with their run, in the DogStatsD format, so that teams
+// watching infrastructure in those tools see training alongside it.
+// Datagrams are sent on the write path; a missing agent loses them silently.
+type StatsDForwarder struct {
+	conn   net.Conn
+	opts   StatsDOptions
+	names  map[string]bool
+	logger *zap.Logger
+
+	mu sync.Mutex
+}
+
+func NewStatsDForwarder(opts StatsDOptions, logger *zap.Logger) (*StatsDForwarder, error) {
+	conn, err := net.Dial("udp", opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial StatsD agent: %w", err)
+	}
+	names := make(map[string]bool, len(opts.Metrics))
+	for _, name := range opts.Metrics {
+		names[name] = true
+	}
+	for i, tag := range opts.Tags {
+		opts.Tags[i] = statsdTag(tag)
+	}
+	return &StatsDForwarder{conn: conn, opts: opts, names: names, logger: logger}, nil
+}
+
+// ObserveMetrics forwards the selected numeric values of a persisted batch
+func (f *StatsDForwarder) ObserveMetrics(ctx context.Context, metrics []model.Metric) {
+	var lines []string
+	for _, m := range metrics {
+		if !f.names[m.MetricName] || !m.IsNumeric() {
+			continue
+		}
+		tags := []string{"run_id:" + m.RunID.String()}
+		if m.ProjectID != nil {
+			tags = append(tags, "project_id:"+m.ProjectID.String())
+		}
+		tags = appendNodeTags(tags, m.NodeID, m.Rank)
+		lines = f.appendLine(lines, m.MetricName, m.Value, tags)
+	}
+	f.send(lines)
+}
+
+// ObserveSystemMetrics forwards the selected system metrics of a batch
+func (f *StatsDForwarder) ObserveSystemMetrics(ctx context.Context, metrics []model.SystemMetric) {
+	var lines []string
+	for _, m := range metrics {
+		name := "system/" + m.MetricType
+		if !f.names[name] {
+			continue
+		}
+		tags := appendNodeTags([]string{"run_id:" + m.RunID.String()}, m.NodeID, m.Rank)
+		lines = f.appendLine(lines, name, m.Value, tags)
+	}
+	f.send(lines)
+}
+
+// ObserveGPUMetrics forwards the selected fields of a GPU batch, tagged with
+// the device
+func (f *StatsDForwarder) ObserveGPUMetrics(ctx context.Context, metrics []model.GPUMetric) {
+	var lines []string
+	for _, m := range metrics {
+		for _, field := range statsdGPUFields {
+			name := "gpu/" + field.name
+			value := field.value(m)
+			if !f.names[name] || value == nil {
+				continue
+			}
+			tags := appendNodeTags([]string{"run_id:" + m.RunID.String(), "device:" + strconv.Itoa(m.DeviceIndex)}, m.NodeID, nil)
+			lines = f.appendLine(lines, name, *value, tags)
+		}
+	}
+	f.send(lines)
+}
+
+// Close closes the socket to the agent
+func (f *StatsDForwarder) Close() error {
+	return f.conn.Close()
+}
+
+func appendNodeTags(tags []string, nodeID string, rank *int) []string {
+	if nodeID != "" {
+		tags = append(tags, "node_id:"+statsdTag(nodeID))
+	}
+	if rank != nil {
+		tags = append(tags, "rank:"+strconv.Itoa(*rank))
+	}
+	return tags
+}
+
+// appendLine appends a gauge line; non-finite values, which StatsD cannot
+// carry, are left out
+func (f *StatsDForwarder) appendLine(lines []string, name string, value float64, tags []string) []string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return lines
+	}
+	tags = append(tags, f.opts.Tags...)
+	return append(lines, statsdName(f.opts.Prefix+name)+":"+strconv.FormatFloat(value, 'g', -1, 64)+"|g|#"+strings.Join(tags, ","))
+}
+
+// send writes lines in as few datagrams as fit them
+func (f *StatsDForwarder) send(lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var packet []byte
+	flush := func() {
+		if len(packet) == 0 {
+			return
+		}
+		if _, err := f.conn.Write(packet); err != nil {
+			f.logger.Debug("Failed to send StatsD packet", zap.Error(err))
+		}
+		packet = packet[:0]
+	}
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+1+len(line) > statsdPacketSize {
+			flush()
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	flush()
+}
+
+// statsdName maps a metric name to one StatsD and Datadog accept: namespace
+// separators become dots, and other characters outside [A-Za-z0-9_.]
+// underscores, e.g. val/loss@top-1 to val.loss_top_1
+func statsdName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '/':
+			return '.'
+		case r == '.' || r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// statsdTag drops the characters that delimit DogStatsD tags
+func statsdTag(tag string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '|', '#', '\n':
+			return '_'
+		}
+		return r
+	}, tag)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+func TestStatsDName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"val/loss", "val.loss"},
+		{"wanllmdb.gpu/utilization", "wanllmdb.gpu.utilization"},
+		{"val/acc@top-1", "val.acc_top_1"},
+		{"train_loss", "train_loss"},
+	}
+	for _, tt := range tests {
+		if got := statsdName(tt.name); got != tt.want {
+			t.Errorf("statsdName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// listenStatsD returns a forwarder sending to a local socket, and a function
+// reading the next datagram from it
+func listenStatsD(t *testing.T, opts StatsDOptions) (*StatsDForwarder, func() string) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	opts.Addr = conn.LocalAddr().String()
+	f, err := NewStatsDForwarder(opts, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f, func() string {
+		buf := make([]byte, 65536)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return ""
+		}
+		return string(buf[:n])
+	}
+}
+
+func TestStatsDForwarder(t *testing.T) {
+	f, read := listenStatsD(t, StatsDOptions{
+		Prefix:  "wanllmdb.",
+		Metrics: []string{"val/loss", "system/cpu", "gpu/utilization"},
+		Tags:    []string{"env:prod"},
+	})
+	runID := uuid.MustParse("6f1c2a56-0d8e-4f43-9a7c-6a1e0f6a9b11")
+	projectID := uuid.MustParse("0b5e4d2c-2f49-4c8a-8f3e-5a7d9c1b2e33")
+	rank := 3
+	utilization := 87.5
+
+	f.ObserveMetrics(context.Background(), []model.Metric{
+		{RunID: runID, MetricName: "val/loss", Value: 0.25, ProjectID: &projectID, NodeID: "node-1", Rank: &rank},
+		{RunID: runID, MetricName: "train/loss", Value: 0.5},
+		{RunID: runID, MetricName: "val/loss", Value: math.NaN()},
+		{RunID: runID, MetricName: "val/loss", ValueType: model.ValueTypeString},
+	})
+	want := "wanllmdb.val.loss:0.25|g|#run_id:" + runID.String() + ",project_id:" + projectID.String() + ",node_id:node-1,rank:3,env:prod"
+	if got := read(); got != want {
+		t.Errorf("training metrics sent %q, want %q", got, want)
+	}
+
+	f.ObserveSystemMetrics(context.Background(), []model.SystemMetric{
+		{RunID: runID, MetricType: "cpu", Value: 42},
+		{RunID: runID, MetricType: "memory", Value: 10},
+	})
+	want = "wanllmdb.system.cpu:42|g|#run_id:" + runID.String() + ",env:prod"
+	if got := read(); got != want {
+		t.Errorf("system metrics sent %q, want %q", got, want)
+	}
+
+	f.ObserveGPUMetrics(context.Background(), []model.GPUMetric{
+		{RunID: runID, DeviceIndex: 1, Utilization: &utilization, PowerW: &utilization},
+		{RunID: runID, DeviceIndex: 2},
+	})
+	want = "wanllmdb.gpu.utilization:87.5|g|#run_id:" + runID.String() + ",device:1,env:prod"
+	if got := read(); got != want {
+		t.Errorf("GPU metrics sent %q, want %q", got, want)
+	}
+}
+
+func TestStatsDForwarderPacking(t *testing.T) {
+	f, read := listenStatsD(t, StatsDOptions{Metrics: []string{"loss"}})
+	metrics := make([]model.Metric, 100)
+	for i := range metrics {
+		metrics[i] = model.Metric{RunID: uuid.New(), MetricName: "loss", Value: float64(i)}
+	}
+	f.ObserveMetrics(context.Background(), metrics)
+
+	lines := 0
+	for packet := read(); packet != ""; packet = read() {
+		if len(packet) > statsdPacketSize {
+			t.Errorf("packet of %d bytes, want at most %d", len(packet), statsdPacketSize)
+		}
+		lines += len(strings.Split(packet, "\n"))
+	}
+	if lines != len(metrics) {
+		t.Errorf("received %d lines, want %d", lines, len(metrics))
+	}
+}
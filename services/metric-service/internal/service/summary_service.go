@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// maximizedMetricHints are name fragments of metrics where higher is better
+var maximizedMetricHints = []string{"acc", "f1", "auc", "precision", "recall", "bleu", "rouge", "reward", "score", "map", "iou", model.RateSuffix}
+
+// SummaryService maintains the run_summary table of final/best values per run and metric
+type SummaryService struct {
+	repo        *repository.SummaryRepository
+	definitions *DefinitionService
+	logger      *zap.Logger
+}
+
+func NewSummaryService(repo *repository.SummaryRepository, definitions *DefinitionService, logger *zap.Logger) *SummaryService {
+	return &SummaryService{
+		repo:        repo,
+		definitions: definitions,
+		logger:      logger,
+	}
+}
+
+// ObserveMetrics folds a persisted batch into the run summaries
+func (s *SummaryService) ObserveMetrics(ctx context.Context, metrics []model.Metric) {
+	summaries := summarizeBatch(metrics, s.GoalFor)
+	if err := s.repo.UpsertSummaries(ctx, summaries); err != nil {
+		s.logger.Error("Failed to update run summaries", zap.Error(err))
+	}
+}
+
+// ObserveDeletion rebuilds the summaries of a run some of whose values were deleted
+func (s *SummaryService) ObserveDeletion(ctx context.Context, deletion *model.MetricDeletion) {
+	if _, err := s.RecomputeRunSummary(ctx, deletion.RunID); err != nil {
+		s.logger.Error("Failed to recompute run summary", zap.String("run_id", deletion.RunID.String()), zap.Error(err))
+	}
+}
+
+// GetRunSummary retrieves the final/best values of every metric in a run
+func (s *SummaryService) GetRunSummary(ctx context.Context, runID uuid.UUID) ([]model.RunMetricSummary, error) {
+	return s.repo.GetRunSummary(ctx, runID)
+}
+
+// ListSummaries ranks runs by the best or final value of one metric
+func (s *SummaryService) ListSummaries(ctx context.Context, params model.SummaryQueryParams, runIDs []uuid.UUID) ([]model.RunMetricSummary, error) {
+	return s.repo.ListSummaries(ctx, params, runIDs)
+}
+
+// ListGroupSummaries ranks run groups by the mean best or final value of one metric
+func (s *SummaryService) ListGroupSummaries(ctx context.Context, params model.SummaryQueryParams, runIDs []uuid.UUID) ([]model.GroupMetricSummary, error) {
+	return s.repo.ListGroupSummaries(ctx, params, runIDs)
+}
+
+// RecomputeRunSummary rebuilds the summaries of a run from its history
+func (s *SummaryService) RecomputeRunSummary(ctx context.Context, runID uuid.UUID) (int, error) {
+	return s.repo.RecomputeRunSummary(ctx, runID, s.GoalFor)
+}
+
+// GoalFor returns whether a metric is minimized or maximized: from its
+// definition when registered, otherwise guessed from its name
+func (s *SummaryService) GoalFor(metricName string) string {
+	if def, ok := s.definitions.Lookup(uuid.Nil, metricName); ok {
+		return def.Goal
+	}
+
+	name := strings.ToLower(metricName)
+	if strings.Contains(name, "loss") || strings.Contains(name, "error") {
+		return model.GoalMin
+	}
+	for _, hint := range maximizedMetricHints {
+		if strings.Contains(name, hint) {
+			return model.GoalMax
+		}
+	}
+	return model.GoalMin
+}
+
+// summarizeBatch reduces a batch to one summary per (run, metric), ignoring
+// non-finite and non-numeric values
+func summarizeBatch(metrics []model.Metric, goalFor func(string) string) []model.RunMetricSummary {
+	index := make(map[seriesKey]int)
+	var summaries []model.RunMetricSummary
+
+	for _, m := range metrics {
+		if !m.IsNumeric() || math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+			continue
+		}
+
+		key := seriesKey{runID: m.RunID, metricName: m.MetricName}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(summaries)
+			summaries = append(summaries, model.RunMetricSummary{
+				RunID:      m.RunID,
+				MetricName: m.MetricName,
+				Goal:       goalFor(m.MetricName),
+				FinalValue: m.Value,
+				FinalStep:  m.Step,
+				FinalTime:  m.Time,
+				BestValue:  m.Value,
+				BestStep:   m.Step,
+				BestTime:   m.Time,
+				Count:      1,
+			})
+			continue
+		}
+
+		s := &summaries[i]
+		s.Count++
+		if !m.Time.Before(s.FinalTime) {
+			s.FinalValue, s.FinalStep, s.FinalTime = m.Value, m.Step, m.Time
+		}
+		if (s.Goal == model.GoalMax && m.Value > s.BestValue) || (s.Goal != model.GoalMax && m.Value < s.BestValue) {
+			s.BestValue, s.BestStep, s.BestTime = m.Value, m.Step, m.Time
+		}
+	}
+
+	return summaries
+}
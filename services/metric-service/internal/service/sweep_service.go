@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// ErrSweepFinished is returned when a sweep has no more trials to hand out
+var ErrSweepFinished = errors.New("sweep is finished")
+
+// SweepService coordinates hyperparameter sweeps: agents request the next
+// config and report results tied to the objective metric stored in this service
+type SweepService struct {
+	repo       *repository.SweepRepository
+	metricRepo *repository.MetricRepository
+	logger     *zap.Logger
+}
+
+func NewSweepService(repo *repository.SweepRepository, metricRepo *repository.MetricRepository, logger *zap.Logger) *SweepService {
+	return &SweepService{
+		repo:       repo,
+		metricRepo: metricRepo,
+		logger:     logger,
+	}
+}
+
+// CreateSweep validates and stores a sweep definition
+func (s *SweepService) CreateSweep(ctx context.Context, req model.CreateSweepRequest) (*model.Sweep, error) {
+	if err := validateSearchSpace(req.SearchSpace, req.Strategy); err != nil {
+		return nil, &ValidationError{Message: err.Error()}
+	}
+
+	sweep := &model.Sweep{
+		ID:              uuid.New(),
+		Name:            req.Name,
+		ObjectiveMetric: req.ObjectiveMetric,
+		Goal:            req.Goal,
+		Strategy:        req.Strategy,
+		SearchSpace:     req.SearchSpace,
+		MaxTrials:       req.MaxTrials,
+		Status:          model.SweepStatusRunning,
+	}
+	if err := s.repo.CreateSweep(ctx, sweep); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Sweep created", zap.String("sweep_id", sweep.ID.String()), zap.String("strategy", sweep.Strategy))
+	return sweep, nil
+}
+
+// GetSweep retrieves a sweep definition
+func (s *SweepService) GetSweep(ctx context.Context, sweepID uuid.UUID) (*model.Sweep, error) {
+	return s.repo.GetSweep(ctx, sweepID)
+}
+
+// ListTrials retrieves all trials of a sweep
+func (s *SweepService) ListTrials(ctx context.Context, sweepID uuid.UUID) ([]model.SweepTrial, error) {
+	return s.repo.ListTrials(ctx, sweepID)
+}
+
+// NextTrial allocates the next suggested config for an agent. Returns
+// ErrSweepFinished once the search space or trial budget is exhausted.
+func (s *SweepService) NextTrial(ctx context.Context, sweepID uuid.UUID, runID *uuid.UUID) (*model.SweepTrial, error) {
+	trial, err := s.repo.CreateTrial(ctx, sweepID, runID, s.suggest)
+	if errors.Is(err, ErrSweepFinished) {
+		if err := s.repo.SetSweepStatus(ctx, sweepID, model.SweepStatusFinished); err != nil {
+			s.logger.Error("Failed to mark sweep finished", zap.Error(err))
+		}
+		return nil, ErrSweepFinished
+	}
+	return trial, err
+}
+
+// ReportTrial records the outcome of a trial. When no objective value is
+// given, the best logged value of the sweep's objective metric is used.
+func (s *SweepService) ReportTrial(ctx context.Context, sweepID, trialID uuid.UUID, req model.ReportTrialRequest) (*model.SweepTrial, error) {
+	sweep, err := s.repo.GetSweep(ctx, sweepID)
+	if err != nil || sweep == nil {
+		return nil, err
+	}
+
+	objective := req.ObjectiveValue
+	if objective == nil && req.Status == model.TrialStatusCompleted {
+		runID := req.RunID
+		if runID == nil {
+			trial, err := s.repo.GetTrial(ctx, sweepID, trialID)
+			if err != nil || trial == nil {
+				return nil, err
+			}
+			runID = trial.RunID
+		}
+
+		if runID != nil {
+			best, err := s.metricRepo.GetBestStep(ctx, *runID, sweep.ObjectiveMetric, sweep.Goal, 0)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve objective value: %w", err)
+			}
+			if best != nil {
+				value := best.BestValue
+				objective = &value
+			}
+		}
+	}
+
+	return s.repo.CompleteTrial(ctx, sweepID, trialID, req.RunID, req.Status, objective)
+}
+
+// GetLeaderboard ranks a sweep's trials by objective value
+func (s *SweepService) GetLeaderboard(ctx context.Context, sweep *model.Sweep, limit int) ([]model.SweepTrial, error) {
+	return s.repo.GetLeaderboard(ctx, sweep, limit)
+}
+
+// suggest picks the next config according to the sweep strategy
+func (s *SweepService) suggest(sweep *model.Sweep, trials []model.SweepTrial) (map[string]interface{}, error) {
+	if sweep.Status == model.SweepStatusFinished {
+		return nil, ErrSweepFinished
+	}
+	if sweep.MaxTrials != nil && len(trials) >= *sweep.MaxTrials {
+		return nil, ErrSweepFinished
+	}
+
+	switch sweep.Strategy {
+	case model.SweepStrategyGrid:
+		if len(trials) >= gridSize(sweep.SearchSpace) {
+			return nil, ErrSweepFinished
+		}
+		return suggestGrid(sweep.SearchSpace, len(trials)), nil
+	case model.SweepStrategyBayes:
+		return suggestBayes(sweep, trials), nil
+	default:
+		return suggestRandom(sweep.SearchSpace), nil
+	}
+}
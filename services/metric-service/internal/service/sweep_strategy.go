@@ -0,0 +1,214 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+const (
+	// maxGridValues caps how many values an int_uniform range may expand to in grid search
+	maxGridValues = 1000
+
+	// bayesStartupTrials completed trials are required before the bayes strategy
+	// stops sampling at random
+	bayesStartupTrials = 5
+	bayesCandidates    = 64
+	bayesGoodFraction  = 0.25
+)
+
+// validateSearchSpace checks that every parameter spec can be sampled by the strategy
+func validateSearchSpace(space map[string]model.ParameterSpec, strategy string) error {
+	for name, spec := range space {
+		if len(spec.Values) > 0 {
+			continue
+		}
+
+		if spec.Min == nil || spec.Max == nil {
+			return fmt.Errorf("parameter %s: values or min/max are required", name)
+		}
+		if *spec.Min > *spec.Max {
+			return fmt.Errorf("parameter %s: min must not exceed max", name)
+		}
+
+		switch spec.Distribution {
+		case model.DistributionUniform:
+		case model.DistributionLogUniform:
+			if *spec.Min <= 0 {
+				return fmt.Errorf("parameter %s: log_uniform requires min > 0", name)
+			}
+		case model.DistributionIntUniform:
+			if *spec.Max-*spec.Min >= maxGridValues && strategy == model.SweepStrategyGrid {
+				return fmt.Errorf("parameter %s: int_uniform range too large for grid search", name)
+			}
+		default:
+			return fmt.Errorf("parameter %s: unknown distribution %q", name, spec.Distribution)
+		}
+
+		if strategy == model.SweepStrategyGrid && spec.Distribution != model.DistributionIntUniform {
+			return fmt.Errorf("parameter %s: grid search requires discrete values", name)
+		}
+	}
+	return nil
+}
+
+// gridSize returns the number of configurations in a grid search space
+func gridSize(space map[string]model.ParameterSpec) int {
+	size := 1
+	for _, spec := range space {
+		size *= len(gridValues(spec))
+	}
+	return size
+}
+
+// suggestGrid decodes the index-th grid point in mixed radix over the sorted parameter names
+func suggestGrid(space map[string]model.ParameterSpec, index int) map[string]interface{} {
+	config := make(map[string]interface{}, len(space))
+	for _, name := range sortedParamNames(space) {
+		values := gridValues(space[name])
+		config[name] = values[index%len(values)]
+		index /= len(values)
+	}
+	return config
+}
+
+func gridValues(spec model.ParameterSpec) []interface{} {
+	if len(spec.Values) > 0 {
+		return spec.Values
+	}
+
+	var values []interface{}
+	for v := int(*spec.Min); v <= int(*spec.Max); v++ {
+		values = append(values, v)
+	}
+	return values
+}
+
+// suggestRandom samples every parameter independently
+func suggestRandom(space map[string]model.ParameterSpec) map[string]interface{} {
+	config := make(map[string]interface{}, len(space))
+	for name, spec := range space {
+		config[name] = sampleParam(spec)
+	}
+	return config
+}
+
+func sampleParam(spec model.ParameterSpec) interface{} {
+	if len(spec.Values) > 0 {
+		return spec.Values[rand.Intn(len(spec.Values))]
+	}
+
+	lo, hi := *spec.Min, *spec.Max
+	switch spec.Distribution {
+	case model.DistributionLogUniform:
+		return math.Exp(math.Log(lo) + rand.Float64()*(math.Log(hi)-math.Log(lo)))
+	case model.DistributionIntUniform:
+		return int(lo) + rand.Intn(int(hi)-int(lo)+1)
+	default:
+		return lo + rand.Float64()*(hi-lo)
+	}
+}
+
+// suggestBayes implements a small tree-structured Parzen estimator: completed
+// trials are split into a good and a bad set by objective, and the random
+// candidate maximizing the density ratio good/bad is chosen
+func suggestBayes(sweep *model.Sweep, trials []model.SweepTrial) map[string]interface{} {
+	var completed []model.SweepTrial
+	for _, t := range trials {
+		if t.Status == model.TrialStatusCompleted && t.ObjectiveValue != nil {
+			completed = append(completed, t)
+		}
+	}
+	if len(completed) < bayesStartupTrials {
+		return suggestRandom(sweep.SearchSpace)
+	}
+
+	sort.Slice(completed, func(i, j int) bool {
+		if sweep.Goal == model.GoalMax {
+			return *completed[i].ObjectiveValue > *completed[j].ObjectiveValue
+		}
+		return *completed[i].ObjectiveValue < *completed[j].ObjectiveValue
+	})
+	nGood := int(math.Ceil(bayesGoodFraction * float64(len(completed))))
+	good, bad := completed[:nGood], completed[nGood:]
+
+	var best map[string]interface{}
+	bestScore := math.Inf(-1)
+	for i := 0; i < bayesCandidates; i++ {
+		candidate := suggestRandom(sweep.SearchSpace)
+		score := 0.0
+		for name, spec := range sweep.SearchSpace {
+			score += math.Log(paramDensity(spec, name, candidate[name], good))
+			score -= math.Log(paramDensity(spec, name, candidate[name], bad))
+		}
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+// paramDensity estimates the density of value among the trials' values for a
+// parameter, smoothed with a uniform prior so it is never zero
+func paramDensity(spec model.ParameterSpec, name string, value interface{}, trials []model.SweepTrial) float64 {
+	if len(spec.Values) > 0 {
+		matches := 0
+		for _, t := range trials {
+			if fmt.Sprint(t.Config[name]) == fmt.Sprint(value) {
+				matches++
+			}
+		}
+		return (float64(matches) + 1) / (float64(len(trials)) + float64(len(spec.Values)))
+	}
+
+	x, ok := normalizeParam(spec, value)
+	if !ok {
+		return 1
+	}
+
+	bandwidth := math.Max(0.1, 1/math.Sqrt(float64(len(trials))))
+	density := 1.0 // uniform prior on [0, 1]
+	for _, t := range trials {
+		xi, ok := normalizeParam(spec, t.Config[name])
+		if !ok {
+			continue
+		}
+		d := (x - xi) / bandwidth
+		density += math.Exp(-0.5*d*d) / (bandwidth * math.Sqrt(2*math.Pi))
+	}
+	return density / (float64(len(trials)) + 1)
+}
+
+// normalizeParam maps a numeric parameter value onto [0, 1] within its range
+func normalizeParam(spec model.ParameterSpec, value interface{}) (float64, bool) {
+	var v float64
+	switch n := value.(type) {
+	case float64:
+		v = n
+	case int:
+		v = float64(n)
+	default:
+		return 0, false
+	}
+
+	lo, hi := *spec.Min, *spec.Max
+	if spec.Distribution == model.DistributionLogUniform {
+		v, lo, hi = math.Log(v), math.Log(lo), math.Log(hi)
+	}
+	if hi == lo {
+		return 0, true
+	}
+	return (v - lo) / (hi - lo), true
+}
+
+func sortedParamNames(space map[string]model.ParameterSpec) []string {
+	names := make([]string, 0, len(space))
+	for name := range space {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+// Bounds on the size of a single table metric
+const (
+	maxTableColumns      = 100
+	maxTableCells        = 10000
+	maxConfusionClasses  = 100
+	maxTableColumnLength = 255
+)
+
+// TableService handles table and confusion matrix metrics
+type TableService struct {
+	repo   *repository.TableRepository
+	logger *zap.Logger
+}
+
+func NewTableService(repo *repository.TableRepository, logger *zap.Logger) *TableService {
+	return &TableService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// BatchWrite validates and writes table metrics
+func (s *TableService) BatchWrite(ctx context.Context, tables []model.TableMetric) error {
+	if err := validateTables(tables); err != nil {
+		return err
+	}
+	return s.repo.BatchWrite(ctx, tables)
+}
+
+// GetTableHistory retrieves the per-step versions of a table metric
+func (s *TableService) GetTableHistory(ctx context.Context, runID uuid.UUID, metricName string, params model.TableQueryParams) ([]model.TableMetric, error) {
+	tables, err := s.repo.GetTableHistory(ctx, runID, metricName, params)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tables {
+		summarizeTable(&tables[i])
+	}
+	return tables, nil
+}
+
+// GetLatestTable retrieves the newest version of a table metric
+func (s *TableService) GetLatestTable(ctx context.Context, runID uuid.UUID, metricName string) (*model.TableMetric, error) {
+	table, err := s.repo.GetLatestTable(ctx, runID, metricName)
+	if err != nil || table == nil {
+		return nil, err
+	}
+	summarizeTable(table)
+	return table, nil
+}
+
+// ListTableSeries lists the table metrics of a run
+func (s *TableService) ListTableSeries(ctx context.Context, runID uuid.UUID) ([]model.TableSeries, error) {
+	return s.repo.ListTableSeries(ctx, runID)
+}
+
+// summarizeTable derives the summary of a confusion matrix
+func summarizeTable(t *model.TableMetric) {
+	if t.Kind != model.TableKindConfusionMatrix {
+		return
+	}
+
+	n := len(t.Labels)
+	summary := &model.ConfusionSummary{Classes: make([]model.ClassMetrics, n)}
+	predicted := make([]float64, n)
+	var correct float64
+	for i, row := range t.Matrix {
+		for j, count := range row {
+			summary.Total += count
+			predicted[j] += count
+			summary.Classes[i].Support += count
+		}
+		correct += row[i]
+	}
+	if summary.Total > 0 {
+		summary.Accuracy = ratio(correct, summary.Total)
+	}
+
+	var f1Sum float64
+	f1Count := 0
+	for i := range summary.Classes {
+		c := &summary.Classes[i]
+		c.Label = t.Labels[i]
+		c.Predicted = predicted[i]
+		tp := t.Matrix[i][i]
+		c.Precision = ratio(tp, c.Predicted)
+		c.Recall = ratio(tp, c.Support)
+		if c.Precision != nil && c.Recall != nil {
+			p, r := *c.Precision, *c.Recall
+			f1 := 0.0
+			if p+r > 0 {
+				f1 = 2 * p * r / (p + r)
+			}
+			c.F1 = &f1
+		}
+		// Classes never seen or never predicted have no F1 and are left out of the macro average
+		if c.F1 != nil {
+			f1Sum += *c.F1
+			f1Count++
+		}
+	}
+	if f1Count > 0 {
+		summary.MacroF1 = ratio(f1Sum, float64(f1Count))
+	}
+
+	t.Summary = summary
+}
+
+func ratio(num, den float64) *float64 {
+	if den == 0 {
+		return nil
+	}
+	v := num / den
+	return &v
+}
+
+func validateTables(tables []model.TableMetric) error {
+	for i := range tables {
+		t := &tables[i]
+		if t.RunID == uuid.Nil {
+			return &ValidationError{Message: fmt.Sprintf("table %d: run_id is required", i)}
+		}
+		if t.MetricName == "" {
+			return &ValidationError{Message: fmt.Sprintf("table %d: metric_name is required", i)}
+		}
+
+		var err error
+		switch t.Kind {
+		case "", model.TableKindTable:
+			t.Kind = model.TableKindTable
+			err = validateTable(t)
+		case model.TableKindConfusionMatrix:
+			err = validateConfusionMatrix(t)
+		default:
+			err = fmt.Errorf("kind must be %s or %s", model.TableKindTable, model.TableKindConfusionMatrix)
+		}
+		if err != nil {
+			return &ValidationError{Message: fmt.Sprintf("table %d (%s): %s", i, t.MetricName, err.Error())}
+		}
+
+		t.Summary = nil
+		if t.Time.IsZero() {
+			t.Time = time.Now()
+		}
+	}
+	return nil
+}
+
+func validateTable(t *model.TableMetric) error {
+	if len(t.Labels) > 0 || len(t.Matrix) > 0 {
+		return fmt.Errorf("labels and matrix are only for confusion matrices")
+	}
+	if len(t.Columns) == 0 || len(t.Columns) > maxTableColumns {
+		return fmt.Errorf("must have between 1 and %d columns", maxTableColumns)
+	}
+	seen := make(map[string]bool, len(t.Columns))
+	for _, col := range t.Columns {
+		if col == "" || len(col) > maxTableColumnLength || seen[col] {
+			return fmt.Errorf("column names must be unique, non-empty and at most %d characters", maxTableColumnLength)
+		}
+		seen[col] = true
+	}
+	if len(t.Rows)*len(t.Columns) > maxTableCells {
+		return fmt.Errorf("must have at most %d cells", maxTableCells)
+	}
+	for r, row := range t.Rows {
+		if len(row) != len(t.Columns) {
+			return fmt.Errorf("row %d has %d cells for %d columns", r, len(row), len(t.Columns))
+		}
+		for _, cell := range row {
+			switch cell.(type) {
+			case nil, string, bool, float64:
+			default:
+				return fmt.Errorf("row %d: cells must be strings, numbers, booleans or null", r)
+			}
+		}
+	}
+	return nil
+}
+
+func validateConfusionMatrix(t *model.TableMetric) error {
+	if len(t.Columns) > 0 || len(t.Rows) > 0 {
+		return fmt.Errorf("columns and rows are only for tables")
+	}
+	n := len(t.Labels)
+	if n < 2 || n > maxConfusionClasses {
+		return fmt.Errorf("must have between 2 and %d labels", maxConfusionClasses)
+	}
+	if len(t.Matrix) != n {
+		return fmt.Errorf("matrix must have one row per label, got %d rows for %d labels", len(t.Matrix), n)
+	}
+	for r, row := range t.Matrix {
+		if len(row) != n {
+			return fmt.Errorf("matrix row %d must have %d counts, got %d", r, n, len(row))
+		}
+		for _, count := range row {
+			if math.IsNaN(count) || math.IsInf(count, 0) || count < 0 {
+				return fmt.Errorf("matrix counts must be finite and non-negative")
+			}
+		}
+	}
+	return nil
+}
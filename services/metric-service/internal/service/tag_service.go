@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+	"github.com/wanllmdb/metric-service/internal/repository"
+)
+
+const (
+	maxTagKeyLength   = 128
+	maxTagValueLength = 255
+
+	// maxTaggedRuns bounds how many runs a tag query can fan out to
+	maxTaggedRuns = 1000
+)
+
+type TagService struct {
+	repo       *repository.TagRepository
+	metricRepo *repository.MetricRepository
+	logger     *zap.Logger
+}
+
+func NewTagService(repo *repository.TagRepository, metricRepo *repository.MetricRepository, logger *zap.Logger) *TagService {
+	return &TagService{
+		repo:       repo,
+		metricRepo: metricRepo,
+		logger:     logger,
+	}
+}
+
+// SetTags adds or overwrites key/value tags and plain labels on a run
+func (s *TagService) SetTags(ctx context.Context, runID uuid.UUID, req model.SetTagsRequest) error {
+	tags := make(map[string]string, len(req.Tags)+len(req.Labels))
+	for key, value := range req.Tags {
+		tags[key] = value
+	}
+	for _, label := range req.Labels {
+		tags[label] = ""
+	}
+
+	if len(tags) == 0 {
+		return &ValidationError{Message: "at least one tag or label is required"}
+	}
+	for key, value := range tags {
+		if key == "" || strings.Contains(key, ":") {
+			return &ValidationError{Message: fmt.Sprintf("invalid tag key %q: must be non-empty and must not contain ':'", key)}
+		}
+		if len(key) > maxTagKeyLength {
+			return &ValidationError{Message: fmt.Sprintf("tag key %q exceeds %d characters", key, maxTagKeyLength)}
+		}
+		if len(value) > maxTagValueLength {
+			return &ValidationError{Message: fmt.Sprintf("value of tag %q exceeds %d characters", key, maxTagValueLength)}
+		}
+	}
+
+	return s.repo.SetTags(ctx, runID, tags)
+}
+
+// DeleteTag removes a tag or label from a run
+func (s *TagService) DeleteTag(ctx context.Context, runID uuid.UUID, key string) (bool, error) {
+	return s.repo.DeleteTag(ctx, runID, key)
+}
+
+// GetRunTags retrieves the tags of a run
+func (s *TagService) GetRunTags(ctx context.Context, runID uuid.UUID) ([]model.RunTag, error) {
+	return s.repo.GetRunTags(ctx, runID)
+}
+
+// FindRuns lists the runs matching all tag filters
+func (s *TagService) FindRuns(ctx context.Context, filters []model.TagFilter, limit int) ([]model.TaggedRun, error) {
+	return s.repo.FindRuns(ctx, filters, limit)
+}
+
+// FindRunIDs resolves tag filters to the matching run IDs
+func (s *TagService) FindRunIDs(ctx context.Context, filters []model.TagFilter) ([]uuid.UUID, error) {
+	runs, err := s.repo.FindRuns(ctx, filters, maxTaggedRuns)
+	if err != nil {
+		return nil, err
+	}
+
+	runIDs := make([]uuid.UUID, len(runs))
+	for i, run := range runs {
+		runIDs[i] = run.RunID
+	}
+	return runIDs, nil
+}
+
+// GetMetricStatsByTags retrieves statistics for one metric across all runs matching the filters
+func (s *TagService) GetMetricStatsByTags(ctx context.Context, filters []model.TagFilter, metricName string) ([]model.TaggedRunStats, error) {
+	runs, err := s.repo.FindRuns(ctx, filters, maxTaggedRuns)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return []model.TaggedRunStats{}, nil
+	}
+
+	runIDs := make([]uuid.UUID, len(runs))
+	for i, run := range runs {
+		runIDs[i] = run.RunID
+	}
+
+	stats, err := s.metricRepo.GetMetricStatsForRuns(ctx, runIDs, metricName)
+	if err != nil {
+		return nil, err
+	}
+
+	statsByRun := make(map[uuid.UUID]model.MetricStats, len(stats))
+	for _, st := range stats {
+		statsByRun[st.RunID] = st.MetricStats
+	}
+
+	result := make([]model.TaggedRunStats, len(runs))
+	for i, run := range runs {
+		result[i] = model.TaggedRunStats{RunID: run.RunID, Tags: run.Tags}
+		if st, ok := statsByRun[run.RunID]; ok {
+			result[i].Stats = &st
+		}
+	}
+	return result, nil
+}
+
+// ParseTagFilters parses "key:value" and bare "key" filter expressions
+func ParseTagFilters(values []string) ([]model.TagFilter, error) {
+	filters := make([]model.TagFilter, 0, len(values))
+	for _, v := range values {
+		key, value, hasValue := strings.Cut(v, ":")
+		if key == "" {
+			return nil, &ValidationError{Message: fmt.Sprintf("invalid tag filter %q", v)}
+		}
+		filters = append(filters, model.TagFilter{Key: key, Value: value, HasValue: hasValue})
+	}
+	return filters, nil
+}
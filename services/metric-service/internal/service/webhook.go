@@ -0,0 +1,313 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+const (
+	webhookQueueSize  = 1024
+	webhookAttempts   = 3
+	webhookRetryDelay = time.Second
+)
+
+// WebhookOptions configure the ingest webhooks. Every event is posted to each
+// of URLs, signed with Secret when set. EveryNPoints fires run.points each
+// time a run's point count reaches another multiple of it; zero disables it.
+type WebhookOptions struct {
+	URLs         []string
+	Secret       string
+	EveryNPoints int64
+	Thresholds   []model.WebhookThreshold
+	Timeout      time.Duration
+}
+
+// WebhookNotifier turns persisted batches into webhook events on ingest
+// milestones: a run's first metric, every N points of a run, and a metric
+// crossing a threshold. Milestones are claimed in Redis, so that each fires
+// once however many replicas see the run and across restarts.
+type WebhookNotifier struct {
+	redis  *redis.Client
+	sender *webhookSender
+	opts   WebhookOptions
+	logger *zap.Logger
+	queue  chan []model.Metric
+	events chan model.WebhookEvent
+}
+
+func NewWebhookNotifier(redis *redis.Client, opts WebhookOptions, logger *zap.Logger) *WebhookNotifier {
+	return &WebhookNotifier{
+		redis:  redis,
+		sender: newWebhookSender(opts.Secret, opts.Timeout, logger),
+		opts:   opts,
+		logger: logger,
+		queue:  make(chan []model.Metric, webhookQueueSize),
+		events: make(chan model.WebhookEvent, webhookQueueSize),
+	}
+}
+
+// ObserveMetrics queues a persisted batch without blocking the write path
+func (n *WebhookNotifier) ObserveMetrics(ctx context.Context, metrics []model.Metric) {
+	select {
+	case n.queue <- metrics:
+	default:
+		n.logger.Warn("Webhook queue full, dropping batch", zap.Int("count", len(metrics)))
+	}
+}
+
+// Run evaluates queued batches and delivers their events until the context
+// is cancelled. Deliveries run apart, so a slow endpoint does not hold up
+// evaluation.
+func (n *WebhookNotifier) Run(ctx context.Context) {
+	go n.deliver(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case metrics := <-n.queue:
+			events, err := n.evaluate(ctx, metrics)
+			if err != nil {
+				n.logger.Error("Failed to evaluate webhook milestones", zap.Error(err))
+				continue
+			}
+			for _, event := range events {
+				select {
+				case n.events <- event:
+				default:
+					n.logger.Warn("Webhook delivery queue full, dropping event", zap.String("type", event.Type))
+				}
+			}
+		}
+	}
+}
+
+func (n *WebhookNotifier) deliver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-n.events:
+			body, err := json.Marshal(event)
+			if err != nil {
+				n.logger.Error("Failed to encode webhook event", zap.Error(err))
+				continue
+			}
+			headers := map[string]string{
+				"Content-Type":        "application/json",
+				"X-Wanllmdb-Event":    event.Type,
+				"X-Wanllmdb-Delivery": event.ID.String(),
+			}
+			for _, url := range n.opts.URLs {
+				if err := n.sender.post(ctx, url, headers, body); err != nil {
+					n.logger.Warn("Failed to deliver webhook",
+						zap.String("url", url),
+						zap.String("type", event.Type),
+						zap.String("run_id", event.RunID.String()),
+						zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+// webhookRun is what a batch holds of one run
+type webhookRun struct {
+	first     model.Metric
+	points    int64
+	projectID *uuid.UUID
+}
+
+// thresholdHit is the first value of a batch past a threshold
+type thresholdHit struct {
+	runID     uuid.UUID
+	threshold model.WebhookThreshold
+	metric    model.Metric
+}
+
+// evaluate claims the milestones a batch reaches and returns their events
+func (n *WebhookNotifier) evaluate(ctx context.Context, metrics []model.Metric) ([]model.WebhookEvent, error) {
+	runs := make(map[uuid.UUID]*webhookRun)
+	var order []uuid.UUID
+	hits := make(map[string]*thresholdHit)
+	var hitOrder []string
+	for _, m := range metrics {
+		run, ok := runs[m.RunID]
+		if !ok {
+			run = &webhookRun{first: m}
+			runs[m.RunID] = run
+			order = append(order, m.RunID)
+		}
+		run.points++
+		if m.ProjectID != nil {
+			run.projectID = m.ProjectID
+		}
+
+		if !m.IsNumeric() || math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+			continue
+		}
+		for _, t := range n.opts.Thresholds {
+			if t.MetricName != m.MetricName || !t.Crossed(m.Value) {
+				continue
+			}
+			key := thresholdKey(m.RunID, t)
+			if _, ok := hits[key]; !ok {
+				hits[key] = &thresholdHit{runID: m.RunID, threshold: t, metric: m}
+				hitOrder = append(hitOrder, key)
+			}
+		}
+	}
+
+	pipe := n.redis.Pipeline()
+	firsts := make(map[uuid.UUID]*redis.BoolCmd)
+	counts := make(map[uuid.UUID]*redis.IntCmd)
+	for _, runID := range order {
+		firsts[runID] = pipe.SetNX(ctx, "webhook:first:"+runID.String(), 1, lastWriteRetention)
+		if n.opts.EveryNPoints > 0 {
+			key := "webhook:points:" + runID.String()
+			counts[runID] = pipe.IncrBy(ctx, key, runs[runID].points)
+			pipe.Expire(ctx, key, lastWriteRetention)
+		}
+	}
+	claims := make(map[string]*redis.BoolCmd)
+	for _, key := range hitOrder {
+		claims[key] = pipe.SetNX(ctx, key, 1, lastWriteRetention)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to claim webhook milestones: %w", err)
+	}
+
+	now := time.Now().UTC()
+	var events []model.WebhookEvent
+	for _, runID := range order {
+		run := runs[runID]
+		event := func(eventType string, data map[string]interface{}) model.WebhookEvent {
+			return model.WebhookEvent{ID: uuid.New(), Type: eventType, Time: now, RunID: runID, ProjectID: run.projectID, Data: data}
+		}
+		if firsts[runID].Val() {
+			events = append(events, event(model.WebhookRunFirstMetric, map[string]interface{}{
+				"metric_name": run.first.MetricName,
+				"step":        run.first.Step,
+			}))
+		}
+		if count, ok := counts[runID]; ok {
+			total := count.Val()
+			// One event per batch, for the highest multiple it reached
+			if reached := total / n.opts.EveryNPoints; reached > (total-run.points)/n.opts.EveryNPoints {
+				events = append(events, event(model.WebhookRunPoints, map[string]interface{}{
+					"points": reached * n.opts.EveryNPoints,
+				}))
+			}
+		}
+	}
+	for _, key := range hitOrder {
+		if !claims[key].Val() {
+			continue
+		}
+		hit := hits[key]
+		events = append(events, model.WebhookEvent{
+			ID: uuid.New(), Type: model.WebhookThresholdCrossed, Time: now,
+			RunID: hit.runID, ProjectID: runs[hit.runID].projectID,
+			Data: map[string]interface{}{
+				"metric_name": hit.metric.MetricName,
+				"step":        hit.metric.Step,
+				"value":       hit.metric.Value,
+				"threshold":   hit.threshold.String(),
+			},
+		})
+	}
+	return events, nil
+}
+
+// thresholdKey is the Redis key claiming a threshold of a run
+func thresholdKey(runID uuid.UUID, t model.WebhookThreshold) string {
+	return "webhook:threshold:" + runID.String() + ":" + t.String()
+}
+
+// webhookSender posts signed payloads, retrying failures that may pass
+type webhookSender struct {
+	client     *http.Client
+	secret     string
+	retryDelay time.Duration
+	logger     *zap.Logger
+}
+
+func newWebhookSender(secret string, timeout time.Duration, logger *zap.Logger) *webhookSender {
+	return &webhookSender{
+		client:     &http.Client{Timeout: timeout},
+		secret:     secret,
+		retryDelay: webhookRetryDelay,
+		logger:     logger,
+	}
+}
+
+// WebhookSignature signs a webhook body sent at timestamp (Unix seconds):
+// "t=<timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">". Receivers
+// recompute it with the shared secret and reject stale timestamps.
+func WebhookSignature(secret string, timestamp int64, body []byte) string {
+	ts := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// post sends body to url, up to webhookAttempts times with doubling delays.
+// Client errors other than 429 are not retried.
+func (s *webhookSender) post(ctx context.Context, url string, headers map[string]string, body []byte) error {
+	delay := s.retryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		retry, err = s.postOnce(ctx, url, headers, body)
+		if err == nil || !retry || attempt == webhookAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (s *webhookSender) postOnce(ctx context.Context, url string, headers map[string]string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if s.secret != "" {
+		req.Header.Set("X-Wanllmdb-Signature", WebhookSignature(s.secret, time.Now().Unix(), body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("endpoint responded %d", resp.StatusCode)
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	// HMAC-SHA256 of "1700000000.{}" under "secret"
+	const want = "t=1700000000,v1=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	got := WebhookSignature("secret", 1700000000, []byte("{}"))
+	if got != want {
+		t.Fatalf("WebhookSignature() = %q, want %q", got, want)
+	}
+	if got == WebhookSignature("other", 1700000000, []byte("{}")) {
+		t.Fatal("signatures under different secrets match")
+	}
+	if got == WebhookSignature("secret", 1700000001, []byte("{}")) {
+		t.Fatal("signatures at different times match")
+	}
+}
+
+func TestWebhookSenderRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantErr      bool
+		wantAttempts int32
+	}{
+		{"delivered", []int{http.StatusOK}, false, 1},
+		{"retried server error", []int{http.StatusBadGateway, http.StatusNoContent}, false, 2},
+		{"gave up", []int{500, 500, 500, 500}, true, webhookAttempts},
+		{"client error not retried", []int{http.StatusBadRequest, http.StatusOK}, true, 1},
+		{"rate limited retried", []int{http.StatusTooManyRequests, http.StatusOK}, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				if r.Header.Get("X-Wanllmdb-Signature") == "" {
+					t.Error("delivery is not signed")
+				}
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer server.Close()
+
+			sender := newWebhookSender("secret", time.Second, zap.NewNop())
+			sender.retryDelay = time.Millisecond
+			err := sender.post(context.Background(), server.URL, map[string]string{"Content-Type": "application/json"}, []byte("{}"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("post() = %v, want error %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Fatalf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// ErrWriteBufferStopped is returned for writes enqueued after the buffer shut
+// down
+var ErrWriteBufferStopped = errors.New("write buffer stopped")
+
+// WriteBuffer accumulates validated metric batches and writes them together
+// once batchSize metrics are pending, or flushInterval after the last flush,
+// so that clients logging a few values at a time are answered without
+// waiting on the database. Consecutive batches with the same ingest options
+// are merged, and batches are written in the order they were enqueued.
+//
+// Callers are not told how a buffered write went: a failure is logged and
+// the batch dropped. Callers needing durability write synchronously.
+type WriteBuffer struct {
+	write         func(ctx context.Context, metrics []model.Metric, opts model.IngestOptions) error
+	batchSize     int
+	flushInterval time.Duration
+	logger        *zap.Logger
+	queue         chan bufferedBatch
+	// done is closed when the buffer stops, releasing enqueuers waiting on a
+	// full queue
+	done chan struct{}
+
+	mu      sync.RWMutex
+	closed  bool
+	senders sync.WaitGroup
+}
+
+type bufferedBatch struct {
+	metrics []model.Metric
+	opts    model.IngestOptions
+}
+
+// NewWriteBuffer creates a buffer writing through metrics, queueing up to
+// batchSize batches before enqueuers wait. It takes effect once passed to
+// MetricService.UseWriteBuffer.
+func NewWriteBuffer(metrics *MetricService, batchSize int, flushInterval time.Duration, logger *zap.Logger) *WriteBuffer {
+	return &WriteBuffer{
+		write:         metrics.writeBuffered,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		logger:        logger,
+		queue:         make(chan bufferedBatch, batchSize),
+		done:          make(chan struct{}),
+	}
+}
+
+// Run writes enqueued batches until ctx is done, then writes what is still
+// pending before it returns. ctx should end once the servers enqueueing have
+// drained: enqueues after it are refused.
+func (b *WriteBuffer) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	var pending []model.Metric
+	var pendingOpts model.IngestOptions
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		// Writes outlive the request that enqueued them, and shutdown
+		if err := b.write(context.Background(), pending, pendingOpts); err != nil {
+			b.logger.Error("Failed to write buffered metrics", zap.Int("count", len(pending)), zap.Error(err))
+		}
+		pending = nil
+	}
+	add := func(batch bufferedBatch) {
+		if len(pending) > 0 && !sameIngestOptions(batch.opts, pendingOpts) {
+			flush()
+		}
+		pending = append(pending, batch.metrics...)
+		pendingOpts = batch.opts
+		if len(pending) >= b.batchSize {
+			flush()
+		}
+	}
+
+	for {
+		select {
+		case batch := <-b.queue:
+			add(batch)
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			// Refuse new enqueues and release those waiting on a full queue;
+			// once every sender is gone, no send races the close
+			b.mu.Lock()
+			b.closed = true
+			b.mu.Unlock()
+			close(b.done)
+			b.senders.Wait()
+			close(b.queue)
+			for batch := range b.queue {
+				add(batch)
+			}
+			flush()
+			return
+		}
+	}
+}
+
+// QueueDepth reports the batches waiting to be buffered and how many the
+// queue can hold
+func (b *WriteBuffer) QueueDepth() (queued, capacity int) {
+	return len(b.queue), cap(b.queue)
+}
+
+// enqueue queues a validated batch, waiting while the queue is full, until
+// ctx is done or the buffer stops
+func (b *WriteBuffer) enqueue(ctx context.Context, metrics []model.Metric, opts model.IngestOptions) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrWriteBufferStopped
+	}
+	b.senders.Add(1)
+	b.mu.RUnlock()
+	defer b.senders.Done()
+
+	select {
+	case b.queue <- bufferedBatch{metrics: metrics, opts: opts}:
+		return nil
+	case <-b.done:
+		return ErrWriteBufferStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sameIngestOptions reports whether batches written with a and b may be
+// written as one
+func sameIngestOptions(a, b model.IngestOptions) bool {
+	if a.RankReduce != b.RankReduce || (a.KeepRanks == nil) != (b.KeepRanks == nil) {
+		return false
+	}
+	return a.KeepRanks == nil || *a.KeepRanks == *b.KeepRanks
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wanllmdb/metric-service/internal/model"
+)
+
+// bufferedWrites records the batches a write buffer writes
+type bufferedWrites struct {
+	mu      sync.Mutex
+	batches []bufferedBatch
+}
+
+func (w *bufferedWrites) write(ctx context.Context, metrics []model.Metric, opts model.IngestOptions) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.batches = append(w.batches, bufferedBatch{metrics: metrics, opts: opts})
+	return nil
+}
+
+func (w *bufferedWrites) sizes() []int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	sizes := make([]int, len(w.batches))
+	for i, b := range w.batches {
+		sizes[i] = len(b.metrics)
+	}
+	return sizes
+}
+
+func newTestWriteBuffer(batchSize int, flushInterval time.Duration) (*WriteBuffer, *bufferedWrites) {
+	writes := &bufferedWrites{}
+	return &WriteBuffer{
+		write:         writes.write,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		logger:        zap.NewNop(),
+		queue:         make(chan bufferedBatch, batchSize),
+		done:          make(chan struct{}),
+	}, writes
+}
+
+func TestWriteBufferFlushesOnShutdown(t *testing.T) {
+	keep, drop := true, false
+	b, writes := newTestWriteBuffer(3, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+
+	batches := []struct {
+		n    int
+		opts model.IngestOptions
+	}{
+		{1, model.IngestOptions{}},
+		{1, model.IngestOptions{}},
+		// Filling the batch flushes it
+		{2, model.IngestOptions{}},
+		// Other options start a batch of their own
+		{1, model.IngestOptions{RankReduce: "mean"}},
+		{1, model.IngestOptions{RankReduce: "mean", KeepRanks: &keep}},
+		{1, model.IngestOptions{RankReduce: "mean", KeepRanks: &drop}},
+		{1, model.IngestOptions{RankReduce: "mean", KeepRanks: &drop}},
+	}
+	for _, batch := range batches {
+		if err := b.enqueue(context.Background(), make([]model.Metric, batch.n), batch.opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancel()
+	<-done
+
+	want := []int{4, 1, 1, 2}
+	got := writes.sizes()
+	if len(got) != len(want) {
+		t.Fatalf("wrote batches of %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("wrote batches of %v, want %v", got, want)
+		}
+	}
+	if err := b.enqueue(context.Background(), make([]model.Metric, 1), model.IngestOptions{}); err != ErrWriteBufferStopped {
+		t.Errorf("enqueue() after shutdown = %v, want ErrWriteBufferStopped", err)
+	}
+}
+
+func TestWriteBufferFlushInterval(t *testing.T) {
+	b, writes := newTestWriteBuffer(100, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+
+	if err := b.enqueue(context.Background(), make([]model.Metric, 2), model.IngestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(writes.sizes()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("buffered metrics not written after the flush interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := writes.sizes(); len(got) != 1 || got[0] != 2 {
+		t.Errorf("wrote batches of %v, want [2]", got)
+	}
+}
+
+func TestWriteBufferShutdownReleasesBlockedEnqueue(t *testing.T) {
+	b, writes := newTestWriteBuffer(1, time.Hour)
+	b.batchSize = 100
+	// Fill the queue, so the next enqueue waits
+	b.queue <- bufferedBatch{metrics: make([]model.Metric, 1)}
+	enqueued := make(chan error, 1)
+	go func() {
+		enqueued <- b.enqueue(context.Background(), make([]model.Metric, 1), model.IngestOptions{})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return with an enqueue waiting on a full queue")
+	}
+
+	err := <-enqueued
+	want := 1
+	switch err {
+	case nil:
+		// The waiting batch made it into the queue before it closed
+		want = 2
+	case ErrWriteBufferStopped:
+	default:
+		t.Fatalf("enqueue() = %v, want nil or ErrWriteBufferStopped", err)
+	}
+	if got := writes.sizes(); len(got) != 1 || got[0] != want {
+		t.Errorf("wrote batches of %v, want [%d]", got, want)
+	}
+}
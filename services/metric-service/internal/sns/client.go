@@ -0,0 +1,142 @@
+// Package sns publishes messages to Amazon SNS topics through the SNS query
+// API, signed with SigV4
+package sns
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wanllmdb/metric-service/internal/awsv4"
+)
+
+// Options configure a Client. Endpoint defaults to the regional endpoint of
+// the topic; set it to reach an SNS-compatible service such as LocalStack.
+type Options struct {
+	TopicARN        string
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Timeout         time.Duration
+}
+
+// Message is a message published to a topic. GroupID and DeduplicationID
+// are only sent to FIFO topics, which require them.
+type Message struct {
+	Body            string
+	Attributes      map[string]string
+	GroupID         string
+	DeduplicationID string
+}
+
+// Client publishes to one topic
+type Client struct {
+	opts     Options
+	signer   awsv4.Signer
+	endpoint string
+	fifo     bool
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewClient(opts Options) (*Client, error) {
+	// arn:partition:sns:region:account:name
+	parts := strings.Split(opts.TopicARN, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sns" || parts[3] == "" || parts[5] == "" {
+		return nil, fmt.Errorf("invalid SNS topic ARN %q", opts.TopicARN)
+	}
+	if opts.AccessKeyID == "" || opts.SecretAccessKey == "" {
+		return nil, fmt.Errorf("SNS access key ID and secret access key are required")
+	}
+	region := parts[3]
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://sns." + region + ".amazonaws.com/"
+		if parts[1] == "aws-cn" {
+			endpoint = "https://sns." + region + ".amazonaws.com.cn/"
+		}
+	}
+	if u, err := url.Parse(endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid SNS endpoint %q", endpoint)
+	}
+	return &Client{
+		opts: opts,
+		signer: awsv4.Signer{
+			AccessKeyID:     opts.AccessKeyID,
+			SecretAccessKey: opts.SecretAccessKey,
+			SessionToken:    opts.SessionToken,
+			Region:          region,
+			Service:         "sns",
+		},
+		endpoint: endpoint,
+		fifo:     strings.HasSuffix(parts[5], ".fifo"),
+		client:   &http.Client{Timeout: opts.Timeout},
+		now:      time.Now,
+	}, nil
+}
+
+// Publish publishes a message and returns the ID SNS gave it
+func (c *Client) Publish(ctx context.Context, msg Message) (string, error) {
+	form := url.Values{
+		"Action":   {"Publish"},
+		"Version":  {"2010-03-31"},
+		"TopicArn": {c.opts.TopicARN},
+		"Message":  {msg.Body},
+	}
+	names := make([]string, 0, len(msg.Attributes))
+	for name := range msg.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		prefix := "MessageAttributes.entry." + strconv.Itoa(i+1) + "."
+		form.Set(prefix+"Name", name)
+		form.Set(prefix+"Value.DataType", "String")
+		form.Set(prefix+"Value.StringValue", msg.Attributes[name])
+	}
+	if c.fifo {
+		form.Set("MessageGroupId", msg.GroupID)
+		form.Set("MessageDeduplicationId", msg.DeduplicationID)
+	}
+	body := []byte(form.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	c.signer.Sign(req, awsv4.PayloadHash(body), c.now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		if xml.Unmarshal(data, &apiErr) == nil && apiErr.Code != "" {
+			return "", fmt.Errorf("SNS responded %d: %s: %s", resp.StatusCode, apiErr.Code, apiErr.Message)
+		}
+		return "", fmt.Errorf("SNS responded %d", resp.StatusCode)
+	}
+	var result struct {
+		MessageID string `xml:"PublishResult>MessageId"`
+	}
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result.MessageID, nil
+}
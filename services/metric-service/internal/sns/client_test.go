@@ -0,0 +1,85 @@
+package sns
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNewClient(t *testing.T) {
+	c, err := NewClient(Options{TopicARN: "arn:aws:sns:eu-west-1:123456789012:runs.fifo", AccessKeyID: "key", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.endpoint != "https://sns.eu-west-1.amazonaws.com/" || c.signer.Region != "eu-west-1" || !c.fifo {
+		t.Errorf("client of %s at %s in %s, fifo %v", c.opts.TopicARN, c.endpoint, c.signer.Region, c.fifo)
+	}
+
+	invalid := []Options{
+		{TopicARN: "runs", AccessKeyID: "key", SecretAccessKey: "secret"},
+		{TopicARN: "arn:aws:sqs:eu-west-1:123456789012:runs", AccessKeyID: "key", SecretAccessKey: "secret"},
+		{TopicARN: "arn:aws:sns:eu-west-1:123456789012:runs"},
+	}
+	for _, opts := range invalid {
+		if _, err := NewClient(opts); err == nil {
+			t.Errorf("NewClient(%+v) succeeded", opts)
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	var form url.Values
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		form, _ = url.ParseQuery(string(body))
+		auth = r.Header.Get("Authorization")
+		if form.Get("Message") == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `<ErrorResponse><Error><Code>InvalidParameter</Code><Message>bad</Message></Error></ErrorResponse>`)
+			return
+		}
+		io.WriteString(w, `<PublishResponse><PublishResult><MessageId>m-1</MessageId></PublishResult></PublishResponse>`)
+	}))
+	defer server.Close()
+
+	c, err := NewClient(Options{TopicARN: "arn:aws:sns:us-east-1:123456789012:runs.fifo", Endpoint: server.URL, AccessKeyID: "key", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := c.Publish(context.Background(), Message{
+		Body:            `{"id":"1"}`,
+		Attributes:      map[string]string{"content-type": "application/cloudevents+json"},
+		GroupID:         "run-1",
+		DeduplicationID: "1",
+	})
+	if err != nil || id != "m-1" {
+		t.Fatalf("Publish() = %q, %v", id, err)
+	}
+	want := map[string]string{
+		"Action":                         "Publish",
+		"TopicArn":                       "arn:aws:sns:us-east-1:123456789012:runs.fifo",
+		"Message":                        `{"id":"1"}`,
+		"MessageAttributes.entry.1.Name": "content-type",
+		"MessageAttributes.entry.1.Value.DataType":    "String",
+		"MessageAttributes.entry.1.Value.StringValue": "application/cloudevents+json",
+		"MessageGroupId":         "run-1",
+		"MessageDeduplicationId": "1",
+	}
+	for name, value := range want {
+		if form.Get(name) != value {
+			t.Errorf("%s = %q, want %q", name, form.Get(name), value)
+		}
+	}
+	if !strings.Contains(auth, "/us-east-1/sns/aws4_request") {
+		t.Errorf("Authorization = %s", auth)
+	}
+
+	if _, err := c.Publish(context.Background(), Message{Body: "fail"}); err == nil || !strings.Contains(err.Error(), "InvalidParameter") {
+		t.Errorf("Publish() of a rejected message = %v", err)
+	}
+}
@@ -0,0 +1,376 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wanllmdb/metric-service/internal/awsv4"
+)
+
+// URLSigner is an object store that can hand out time-limited URLs, so that
+// clients fetch objects from it directly
+type URLSigner interface {
+	SignedURL(method, key string, expires time.Duration) (string, error)
+}
+
+// S3Options configure an S3Store. Endpoint is the base URL of any
+// S3-compatible service: AWS S3, MinIO, or GCS through its XML API with HMAC
+// keys. ServerSideEncryption is "", "AES256" or "aws:kms", the latter with an
+// optional KMSKeyID.
+type S3Options struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// PathStyle addresses the bucket in the path rather than the host name,
+	// as MinIO and most other S3-compatible services expect
+	PathStyle bool
+
+	ServerSideEncryption string
+	KMSKeyID             string
+
+	// PartSize is the size of the parts of multipart uploads, used for
+	// objects larger than it or of unknown size
+	PartSize int64
+	// Timeout bounds the wait for the response headers of a request; bodies
+	// may take longer to stream
+	Timeout time.Duration
+}
+
+// minPartSize is the smallest part S3 accepts but for the last
+const minPartSize = 5 << 20
+
+// S3Store keeps objects in a bucket of an S3-compatible service, signing
+// requests with AWS Signature Version 4
+type S3Store struct {
+	opts     S3Options
+	signer   awsv4.Signer
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewS3Store(opts S3Options) (*S3Store, error) {
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", opts.Endpoint)
+	}
+	if opts.Bucket == "" || opts.Region == "" {
+		return nil, fmt.Errorf("S3 bucket and region are required")
+	}
+	if opts.AccessKeyID == "" || opts.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3 access key ID and secret access key are required")
+	}
+	switch opts.ServerSideEncryption {
+	case "", "AES256", "aws:kms":
+	default:
+		return nil, fmt.Errorf("unknown S3 server-side encryption %q: must be AES256 or aws:kms", opts.ServerSideEncryption)
+	}
+	if opts.PartSize == 0 {
+		opts.PartSize = 16 << 20
+	}
+	if opts.PartSize < minPartSize {
+		return nil, fmt.Errorf("S3 part size must be at least %d bytes", minPartSize)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = opts.Timeout
+	return &S3Store{
+		opts: opts,
+		signer: awsv4.Signer{
+			AccessKeyID:     opts.AccessKeyID,
+			SecretAccessKey: opts.SecretAccessKey,
+			SessionToken:    opts.SessionToken,
+			Region:          opts.Region,
+			Service:         "s3",
+		},
+		endpoint: endpoint,
+		client:   &http.Client{Transport: transport},
+		now:      time.Now,
+	}, nil
+}
+
+// Put uploads an object in one request when its size is known and within a
+// part, else as a multipart upload
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if size >= 0 && size <= s.opts.PartSize {
+		req, err := s.newRequest(ctx, http.MethodPut, key, nil, r)
+		if err != nil {
+			return err
+		}
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+		req.Header.Set("Content-Type", contentType)
+		s.setEncryption(req)
+		resp, err := s.do(req)
+		if err != nil {
+			return fmt.Errorf("failed to upload object: %w", err)
+		}
+		resp.Body.Close()
+		return nil
+	}
+	return s.putMultipart(ctx, key, r, contentType)
+}
+
+// putMultipart uploads r in parts of PartSize, aborting the upload on failure
+// so that no parts are left behind
+func (s *S3Store) putMultipart(ctx context.Context, key string, r io.Reader, contentType string) error {
+	req, err := s.newRequest(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	s.setEncryption(req)
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := s.doXML(req, &initiated); err != nil {
+		return fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+
+	parts, err := s.uploadParts(ctx, key, initiated.UploadID, r)
+	if err == nil {
+		err = s.completeMultipart(ctx, key, initiated.UploadID, parts)
+	}
+	if err != nil {
+		// A fresh context, as the upload may have failed on ctx
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if req, reqErr := s.newRequest(abortCtx, http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadID}}, nil); reqErr == nil {
+			if resp, abortErr := s.do(req); abortErr == nil {
+				resp.Body.Close()
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// completedPart is a part of a multipart upload, as CompleteMultipartUpload lists it
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (s *S3Store) uploadParts(ctx context.Context, key, uploadID string, r io.Reader) ([]completedPart, error) {
+	var parts []completedPart
+	buf := make([]byte, s.opts.PartSize)
+	for number := 1; ; number++ {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && readErr != io.ErrUnexpectedEOF && readErr != io.EOF {
+			return nil, fmt.Errorf("failed to read object: %w", readErr)
+		}
+		// An empty object is uploaded as one empty part
+		if n == 0 && len(parts) > 0 {
+			return parts, nil
+		}
+
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		req, err := s.newRequest(ctx, http.MethodPut, key, query, bytes.NewReader(buf[:n]))
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload part %d: %w", number, err)
+		}
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+
+		if readErr != nil {
+			return parts, nil
+		}
+	}
+}
+
+func (s *S3Store) completeMultipart(ctx context.Context, key, uploadID string, parts []completedPart) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	req, err := s.newRequest(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", "application/xml")
+	// S3 reports a failed completion in the body of a 200 response
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := s.doXML(req, &result); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("failed to complete multipart upload: %s: %s", result.Code, result.Message)
+	}
+	return nil
+}
+
+// Get opens an object for reading
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// Delete removes an object; deleting a missing object is not an error
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// SignedURL returns a presigned URL that allows method on key until expires
+// has passed, up to the seven days S3 allows
+func (s *S3Store) SignedURL(method, key string, expires time.Duration) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	if expires < time.Second || expires > 7*24*time.Hour {
+		return "", fmt.Errorf("signed URL expiry must be between 1s and 7 days")
+	}
+	u := s.objectURL(key)
+	now := s.now().UTC()
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.opts.AccessKeyID + "/" + s.signer.Scope(now)},
+		"X-Amz-Date":          {now.Format(awsv4.DateFormat)},
+		"X-Amz-Expires":       {strconv.Itoa(int(expires / time.Second))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	if s.opts.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.opts.SessionToken)
+	}
+	header := http.Header{"Host": {u.Host}}
+	query.Set("X-Amz-Signature", s.signer.Signature(method, u, query, header, awsv4.UnsignedPayload, now))
+	u.RawQuery = awsv4.CanonicalQuery(query)
+	return u.String(), nil
+}
+
+func (s *S3Store) newRequest(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := s.objectURL(key)
+	u.RawQuery = awsv4.CanonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	return req, nil
+}
+
+// setEncryption asks for the configured server-side encryption of a new object
+func (s *S3Store) setEncryption(req *http.Request) {
+	if s.opts.ServerSideEncryption == "" {
+		return
+	}
+	req.Header.Set("X-Amz-Server-Side-Encryption", s.opts.ServerSideEncryption)
+	if s.opts.ServerSideEncryption == "aws:kms" && s.opts.KMSKeyID != "" {
+		req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", s.opts.KMSKeyID)
+	}
+}
+
+// do signs and sends req, turning error responses into errors; a 404 is
+// ErrNotFound
+func (s *S3Store) do(req *http.Request) (*http.Response, error) {
+	s.signer.Sign(req, awsv4.UnsignedPayload, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	var apiErr struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(data, &apiErr) == nil && apiErr.Code != "" {
+		return nil, fmt.Errorf("S3 responded %d: %s: %s", resp.StatusCode, apiErr.Code, apiErr.Message)
+	}
+	return nil, fmt.Errorf("S3 responded %d", resp.StatusCode)
+}
+
+// doXML sends req and decodes its XML response into v
+func (s *S3Store) doXML(req *http.Request, v interface{}) error {
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := xml.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// objectURL returns the URL of key, addressing the bucket by path or host
+func (s *S3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+	if s.opts.PathStyle {
+		u.Path = base + "/" + s.opts.Bucket + "/" + key
+	} else {
+		u.Host = s.opts.Bucket + "." + u.Host
+		u.Path = base + "/" + key
+	}
+	u.RawPath = awsv4.EncodePath(u.Path)
+	return &u
+}
+
+// validateKey rejects empty and absolute keys and keys with ..
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	return nil
+}
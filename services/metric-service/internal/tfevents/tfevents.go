@@ -0,0 +1,102 @@
+// Package tfevents writes TensorBoard event files: TFRecord files of
+// tensorflow.Event messages, of which it writes the file version and scalar
+// summaries, encoded field by field.
+package tfevents
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// fileVersion is the version of the event format, which TensorBoard expects
+// in the first event of a file
+const fileVersion = "brain.Event:2"
+
+// Field numbers of tensorflow.Event, Summary and Summary.Value
+const (
+	eventWallTime    = 1
+	eventStep        = 2
+	eventFileVersion = 3
+	eventSummary     = 5
+
+	summaryValue = 1
+
+	valueTag         = 1
+	valueSimpleValue = 2
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// FileName is the name TensorBoard finds an event file by, for a file
+// started at t on host
+func FileName(t time.Time, host string) string {
+	return fmt.Sprintf("events.out.tfevents.%d.%s", t.Unix(), host)
+}
+
+// Writer writes the events of one event file
+type Writer struct {
+	w      io.Writer
+	event  []byte
+	value  []byte
+	record []byte
+}
+
+// NewWriter starts an event file on w with its file version event, stamped
+// with wallTime
+func NewWriter(w io.Writer, wallTime time.Time) (*Writer, error) {
+	ew := &Writer{w: w}
+	ew.event = appendWallTime(ew.event[:0], wallTime)
+	ew.event = protowire.AppendTag(ew.event, eventFileVersion, protowire.BytesType)
+	ew.event = protowire.AppendString(ew.event, fileVersion)
+	if err := ew.writeRecord(ew.event); err != nil {
+		return nil, err
+	}
+	return ew, nil
+}
+
+// WriteScalar writes a scalar summary of tag at step. TensorBoard holds
+// scalars as 32-bit floats, to which value is rounded.
+func (w *Writer) WriteScalar(tag string, step int64, value float64, wallTime time.Time) error {
+	w.value = protowire.AppendTag(w.value[:0], valueTag, protowire.BytesType)
+	w.value = protowire.AppendString(w.value, tag)
+	w.value = protowire.AppendTag(w.value, valueSimpleValue, protowire.Fixed32Type)
+	w.value = protowire.AppendFixed32(w.value, math.Float32bits(float32(value)))
+
+	w.event = appendWallTime(w.event[:0], wallTime)
+	w.event = protowire.AppendTag(w.event, eventStep, protowire.VarintType)
+	w.event = protowire.AppendVarint(w.event, uint64(step))
+	w.event = protowire.AppendTag(w.event, eventSummary, protowire.BytesType)
+	w.event = protowire.AppendVarint(w.event, uint64(protowire.SizeTag(summaryValue)+protowire.SizeBytes(len(w.value))))
+	w.event = protowire.AppendTag(w.event, summaryValue, protowire.BytesType)
+	w.event = protowire.AppendBytes(w.event, w.value)
+	return w.writeRecord(w.event)
+}
+
+func appendWallTime(b []byte, t time.Time) []byte {
+	b = protowire.AppendTag(b, eventWallTime, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(float64(t.UnixNano())/1e9))
+}
+
+// writeRecord frames data as a TFRecord: its length and the masked CRC of
+// the length, then the data and its masked CRC, all little-endian
+func (w *Writer) writeRecord(data []byte) error {
+	w.record = binary.LittleEndian.AppendUint64(w.record[:0], uint64(len(data)))
+	w.record = binary.LittleEndian.AppendUint32(w.record, maskedCRC(w.record))
+	w.record = append(w.record, data...)
+	w.record = binary.LittleEndian.AppendUint32(w.record, maskedCRC(data))
+	_, err := w.w.Write(w.record)
+	return err
+}
+
+// maskedCRC is the CRC-32C of data, masked as TFRecord does so that CRCs of
+// data holding CRCs stay sound
+func maskedCRC(data []byte) uint32 {
+	crc := crc32.Checksum(data, castagnoli)
+	return (crc>>15 | crc<<17) + 0xa282ead8
+}
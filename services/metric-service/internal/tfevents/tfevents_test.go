@@ -0,0 +1,101 @@
+package tfevents
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// readRecords splits a TFRecord file into its records, checking their CRCs
+func readRecords(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	var records [][]byte
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatalf("truncated record header: %x", data)
+		}
+		n := binary.LittleEndian.Uint64(data)
+		if crc := binary.LittleEndian.Uint32(data[8:]); crc != maskedCRC(data[:8]) {
+			t.Fatalf("length CRC = %x, want %x", crc, maskedCRC(data[:8]))
+		}
+		data = data[12:]
+		record := data[:n]
+		if crc := binary.LittleEndian.Uint32(data[n:]); crc != maskedCRC(record) {
+			t.Fatalf("data CRC = %x, want %x", crc, maskedCRC(record))
+		}
+		records = append(records, record)
+		data = data[n+4:]
+	}
+	return records
+}
+
+// fields reads the fields of a message into a map by number, keeping the
+// last value of each
+func fields(t *testing.T, msg []byte) map[protowire.Number][]byte {
+	t.Helper()
+	out := make(map[protowire.Number][]byte)
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		n = protowire.ConsumeFieldValue(num, typ, msg)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		value := msg[:n]
+		if typ == protowire.BytesType {
+			value, _ = protowire.ConsumeBytes(value)
+		}
+		out[num] = value
+		msg = msg[n:]
+	}
+	return out
+}
+
+func TestWriter(t *testing.T) {
+	at := time.Unix(1700000000, 500000000)
+	var b bytes.Buffer
+	w, err := NewWriter(&b, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteScalar("train/loss", 42, 0.25, at); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readRecords(t, b.Bytes())
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	version := fields(t, records[0])
+	if got := string(version[eventFileVersion]); got != fileVersion {
+		t.Errorf("file_version = %q, want %q", got, fileVersion)
+	}
+
+	event := fields(t, records[1])
+	if wall, _ := protowire.ConsumeFixed64(event[eventWallTime]); math.Float64frombits(wall) != 1700000000.5 {
+		t.Errorf("wall_time = %v, want 1700000000.5", math.Float64frombits(wall))
+	}
+	if step, _ := protowire.ConsumeVarint(event[eventStep]); step != 42 {
+		t.Errorf("step = %d, want 42", step)
+	}
+	value := fields(t, fields(t, event[eventSummary])[summaryValue])
+	if tag := string(value[valueTag]); tag != "train/loss" {
+		t.Errorf("tag = %q, want train/loss", tag)
+	}
+	if v, _ := protowire.ConsumeFixed32(value[valueSimpleValue]); math.Float32frombits(v) != 0.25 {
+		t.Errorf("simple_value = %v, want 0.25", math.Float32frombits(v))
+	}
+}
+
+func TestFileName(t *testing.T) {
+	if got := FileName(time.Unix(1700000000, 0), "wanllmdb"); got != "events.out.tfevents.1700000000.wanllmdb" {
+		t.Errorf("FileName = %s", got)
+	}
+}